@@ -0,0 +1,141 @@
+package analytics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/i101dev/microservices-NN/model"
+	"github.com/redis/go-redis/v9"
+)
+
+const leaderboardRetention = 100 * 24 * time.Hour
+
+var ErrUnknownWindow = errors.New("unknown leaderboard window")
+
+// Windows maps each supported window to the number of daily buckets it
+// merges, ending today.
+var Windows = map[string]int{
+	"day":     1,
+	"week":    7,
+	"month":   30,
+	"quarter": 90,
+}
+
+type Entry struct {
+	ID    string `json:"id"`
+	Score int64  `json:"score"`
+}
+
+type Leaderboard struct {
+	Board   string  `json:"board"`
+	Metric  string  `json:"metric"`
+	Window  string  `json:"window"`
+	Entries []Entry `json:"entries"`
+}
+
+func leaderboardKey(board string, metric string, day string) string {
+	return "analytics:leaderboard:" + board + ":" + metric + ":" + day
+}
+
+// recordCompleted adds a completed order to the customer and item boards
+// for the day it completed.
+func (s *Store) recordCompleted(ctx context.Context, pipe redis.Pipeliner, o model.Order) {
+
+	day := s.dayOf(o.CompletedAt)
+
+	customer := o.CustomerID.String()
+
+	var revenue int64
+
+	for _, item := range o.LineItems {
+		amount := int64(item.Quantity) * int64(item.Price)
+		revenue += amount
+
+		pipe.ZIncrBy(ctx, leaderboardKey("items", "quantity", day), float64(item.Quantity), item.ItemID.String())
+		pipe.ZIncrBy(ctx, leaderboardKey("items", "revenue", day), float64(amount), item.ItemID.String())
+	}
+
+	pipe.ZIncrBy(ctx, leaderboardKey("customers", "orders", day), 1, customer)
+	pipe.ZIncrBy(ctx, leaderboardKey("customers", "revenue", day), float64(revenue), customer)
+
+	for _, key := range []string{
+		leaderboardKey("items", "quantity", day),
+		leaderboardKey("items", "revenue", day),
+		leaderboardKey("customers", "orders", day),
+		leaderboardKey("customers", "revenue", day),
+	} {
+		pipe.Expire(ctx, key, leaderboardRetention)
+	}
+}
+
+// Top merges the daily buckets of a board over the window and returns the
+// highest scores. Board is "customers" or "items"; metric is one the board
+// records.
+func (s *Store) Top(ctx context.Context, board string, metric string, window string, limit int) (Leaderboard, error) {
+
+	days, ok := Windows[window]
+	if !ok {
+		return Leaderboard{}, fmt.Errorf("%w: %s", ErrUnknownWindow, window)
+	}
+
+	today := s.now()
+
+	keys := make([]string, 0, days)
+	for i := 0; i < days; i++ {
+		keys = append(keys, leaderboardKey(board, metric, today.AddDate(0, 0, -i).Format(dayLayout)))
+	}
+
+	dest := "analytics:leaderboard:tmp:" + uuid.NewString()
+
+	pipe := s.Client.TxPipeline()
+
+	pipe.ZUnionStore(ctx, dest, &redis.ZStore{Keys: keys})
+	top := pipe.ZRevRangeWithScores(ctx, dest, 0, int64(limit)-1)
+	pipe.Del(ctx, dest)
+
+	if _, err := pipe.Exec(ctx); err != nil {
+		return Leaderboard{}, fmt.Errorf("failed to read leaderboard: %w", err)
+	}
+
+	lb := Leaderboard{
+		Board:   board,
+		Metric:  metric,
+		Window:  window,
+		Entries: make([]Entry, 0, len(top.Val())),
+	}
+
+	for _, z := range top.Val() {
+		id, _ := z.Member.(string)
+		lb.Entries = append(lb.Entries, Entry{
+			ID:    id,
+			Score: int64(z.Score),
+		})
+	}
+
+	return lb, nil
+}
+
+// ForgetCustomer removes the customer from every daily customer board
+// still within retention.
+func (s *Store) ForgetCustomer(ctx context.Context, customer uuid.UUID) error {
+
+	today := s.now()
+	days := int(leaderboardRetention / (24 * time.Hour))
+
+	pipe := s.Client.Pipeline()
+
+	for i := 0; i <= days; i++ {
+		day := today.AddDate(0, 0, -i).Format(dayLayout)
+		pipe.ZRem(ctx, leaderboardKey("customers", "orders", day), customer.String())
+		pipe.ZRem(ctx, leaderboardKey("customers", "revenue", day), customer.String())
+	}
+
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to remove customer from leaderboards: %w", err)
+	}
+
+	return nil
+}
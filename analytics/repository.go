@@ -0,0 +1,143 @@
+package analytics
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/i101dev/microservices-NN/model"
+	"github.com/i101dev/microservices-NN/repository/order"
+)
+
+// Repository feeds successful writes into the analytics store. Failing to
+// record is logged and never fails the write itself.
+type Repository struct {
+	order.Repository
+	Store  *Store
+	Series Series
+}
+
+func (r *Repository) Insert(ctx context.Context, o model.Order) error {
+
+	if err := r.Repository.Insert(ctx, o); err != nil {
+		return err
+	}
+
+	if err := r.Store.RecordCreated(ctx, o); err != nil {
+		fmt.Println("failed to record analytics:", err)
+	}
+
+	if r.Series != nil {
+		r.sample(ctx, o)
+	}
+
+	return nil
+}
+
+func (r *Repository) InsertAll(ctx context.Context, orders []model.Order) error {
+
+	if err := r.Repository.InsertAll(ctx, orders); err != nil {
+		return err
+	}
+
+	for _, o := range orders {
+		if err := r.Store.RecordCreated(ctx, o); err != nil {
+			fmt.Println("failed to record analytics:", err)
+		}
+
+		if r.Series != nil {
+			r.sample(ctx, o)
+		}
+	}
+
+	return nil
+}
+
+func (r *Repository) sample(ctx context.Context, o model.Order) {
+
+	at := r.Store.now()
+	if o.CreatedAt != nil {
+		at = *o.CreatedAt
+	}
+
+	var revenue float64
+	for _, item := range o.LineItems {
+		revenue += float64(item.Quantity) * float64(item.Price)
+	}
+
+	if err := r.Series.Add(ctx, MetricOrders, at, 1); err != nil {
+		fmt.Println("failed to record order sample:", err)
+	}
+
+	if err := r.Series.Add(ctx, MetricRevenue, at, revenue); err != nil {
+		fmt.Println("failed to record revenue sample:", err)
+	}
+}
+
+// Update reads the stored order first so status transitions can be told
+// apart from writes that leave the status alone.
+func (r *Repository) Update(ctx context.Context, o model.Order) error {
+
+	prev, findErr := r.Repository.FindByID(ctx, o.OrderID)
+
+	if err := r.Repository.Update(ctx, o); err != nil {
+		return err
+	}
+
+	if findErr != nil {
+		fmt.Println("failed to read order for analytics:", findErr)
+		return nil
+	}
+
+	if err := r.Store.RecordTransition(ctx, prev, o); err != nil {
+		fmt.Println("failed to record analytics:", err)
+	}
+
+	return nil
+}
+
+// Apply records inserts as created orders and updates as transitions,
+// like the single writes do. Merges and splits are not recorded: the
+// orders they cancel or insert were neither given up nor placed.
+func (r *Repository) Apply(ctx context.Context, batch order.Batch) error {
+
+	if batch.Reshapes() {
+		return r.Repository.Apply(ctx, batch)
+	}
+
+	prev := make(map[uint64]model.Order, len(batch.Update))
+
+	for _, o := range batch.Update {
+		p, err := r.Repository.FindByID(ctx, o.OrderID)
+		if err != nil {
+			fmt.Println("failed to read order for analytics:", err)
+			continue
+		}
+		prev[o.OrderID] = p
+	}
+
+	if err := r.Repository.Apply(ctx, batch); err != nil {
+		return err
+	}
+
+	for _, o := range batch.Insert {
+		if err := r.Store.RecordCreated(ctx, o); err != nil {
+			fmt.Println("failed to record analytics:", err)
+		}
+
+		if r.Series != nil {
+			r.sample(ctx, o)
+		}
+	}
+
+	for _, o := range batch.Update {
+		p, ok := prev[o.OrderID]
+		if !ok {
+			continue
+		}
+		if err := r.Store.RecordTransition(ctx, p, o); err != nil {
+			fmt.Println("failed to record analytics:", err)
+		}
+	}
+
+	return nil
+}
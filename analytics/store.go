@@ -0,0 +1,233 @@
+package analytics
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/i101dev/microservices-NN/clock"
+	"github.com/i101dev/microservices-NN/model"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	dayLayout = "2006-01-02"
+	retention = 400 * 24 * time.Hour
+)
+
+// Store keeps per-day counters in Redis hashes. They are bumped as orders
+// are written, so reports only read one hash per day in the range.
+type Store struct {
+	Client *redis.Client
+	Clock  clock.Clock
+}
+
+func (s *Store) now() time.Time {
+	if s.Clock == nil {
+		return time.Now().UTC()
+	}
+	return s.Clock.Now().UTC()
+}
+
+type Day struct {
+	Date      string `json:"date,omitempty"`
+	Orders    int64  `json:"orders"`
+	LineItems int64  `json:"line_items"`
+	Revenue   int64  `json:"revenue"`
+	Cancelled int64  `json:"cancelled"`
+	Completed int64  `json:"completed"`
+}
+
+type Item struct {
+	ItemID   string `json:"item_id"`
+	Quantity int64  `json:"quantity"`
+	Revenue  int64  `json:"revenue"`
+}
+
+type Report struct {
+	From     string `json:"from"`
+	To       string `json:"to"`
+	Days     []Day  `json:"days"`
+	Totals   Day    `json:"totals"`
+	TopItems []Item `json:"top_items"`
+}
+
+func dayKey(day string) string {
+	return "analytics:day:" + day
+}
+
+func itemQuantityKey(day string) string {
+	return "analytics:items:quantity:" + day
+}
+
+func itemRevenueKey(day string) string {
+	return "analytics:items:revenue:" + day
+}
+
+func (s *Store) dayOf(t *time.Time) string {
+
+	if t == nil {
+		return s.now().Format(dayLayout)
+	}
+
+	return t.UTC().Format(dayLayout)
+}
+
+func (s *Store) RecordCreated(ctx context.Context, o model.Order) error {
+
+	day := s.dayOf(o.CreatedAt)
+
+	pipe := s.Client.TxPipeline()
+
+	var revenue int64
+
+	for _, item := range o.LineItems {
+		amount := int64(item.Quantity) * int64(item.Price)
+		revenue += amount
+
+		pipe.HIncrBy(ctx, itemQuantityKey(day), item.ItemID.String(), int64(item.Quantity))
+		pipe.HIncrBy(ctx, itemRevenueKey(day), item.ItemID.String(), amount)
+	}
+
+	pipe.HIncrBy(ctx, dayKey(day), "orders", 1)
+	pipe.HIncrBy(ctx, dayKey(day), "line_items", int64(len(o.LineItems)))
+	pipe.HIncrBy(ctx, dayKey(day), "revenue", revenue)
+
+	pipe.Expire(ctx, dayKey(day), retention)
+	pipe.Expire(ctx, itemQuantityKey(day), retention)
+	pipe.Expire(ctx, itemRevenueKey(day), retention)
+
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to record order: %w", err)
+	}
+
+	return nil
+}
+
+// RecordTransition counts a status change on the day it happened. Writes
+// that leave the status unchanged are ignored.
+func (s *Store) RecordTransition(ctx context.Context, prev model.Order, next model.Order) error {
+
+	if prev.Status() == next.Status() {
+		return nil
+	}
+
+	var field string
+	var at *time.Time
+
+	switch next.Status() {
+	case model.StatusCancelled:
+		field, at = "cancelled", next.CancelledAt
+	case model.StatusCompleted:
+		field, at = "completed", next.CompletedAt
+	default:
+		return nil
+	}
+
+	day := s.dayOf(at)
+
+	pipe := s.Client.TxPipeline()
+
+	pipe.HIncrBy(ctx, dayKey(day), field, 1)
+	pipe.Expire(ctx, dayKey(day), retention)
+
+	if field == "completed" {
+		s.recordCompleted(ctx, pipe, next)
+	}
+
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to record transition: %w", err)
+	}
+
+	return nil
+}
+
+// Orders reports each day in [from, to], both given as dates.
+func (s *Store) Orders(ctx context.Context, from time.Time, to time.Time, top int) (Report, error) {
+
+	var days []string
+	for d := from; !d.After(to); d = d.AddDate(0, 0, 1) {
+		days = append(days, d.Format(dayLayout))
+	}
+
+	pipe := s.Client.Pipeline()
+
+	counters := make([]*redis.MapStringStringCmd, len(days))
+	quantities := make([]*redis.MapStringStringCmd, len(days))
+	revenues := make([]*redis.MapStringStringCmd, len(days))
+
+	for i, day := range days {
+		counters[i] = pipe.HGetAll(ctx, dayKey(day))
+		quantities[i] = pipe.HGetAll(ctx, itemQuantityKey(day))
+		revenues[i] = pipe.HGetAll(ctx, itemRevenueKey(day))
+	}
+
+	if _, err := pipe.Exec(ctx); err != nil {
+		return Report{}, fmt.Errorf("failed to read analytics: %w", err)
+	}
+
+	report := Report{
+		From: from.Format(dayLayout),
+		To:   to.Format(dayLayout),
+		Days: make([]Day, 0, len(days)),
+	}
+
+	items := map[string]*Item{}
+
+	for i, day := range days {
+		fields := counters[i].Val()
+
+		d := Day{
+			Date:      day,
+			Orders:    parseInt(fields["orders"]),
+			LineItems: parseInt(fields["line_items"]),
+			Revenue:   parseInt(fields["revenue"]),
+			Cancelled: parseInt(fields["cancelled"]),
+			Completed: parseInt(fields["completed"]),
+		}
+
+		report.Days = append(report.Days, d)
+
+		report.Totals.Orders += d.Orders
+		report.Totals.LineItems += d.LineItems
+		report.Totals.Revenue += d.Revenue
+		report.Totals.Cancelled += d.Cancelled
+		report.Totals.Completed += d.Completed
+
+		for id, qty := range quantities[i].Val() {
+			item, ok := items[id]
+			if !ok {
+				item = &Item{ItemID: id}
+				items[id] = item
+			}
+			item.Quantity += parseInt(qty)
+			item.Revenue += parseInt(revenues[i].Val()[id])
+		}
+	}
+
+	report.TopItems = make([]Item, 0, len(items))
+	for _, item := range items {
+		report.TopItems = append(report.TopItems, *item)
+	}
+
+	sort.Slice(report.TopItems, func(i, j int) bool {
+		a, b := report.TopItems[i], report.TopItems[j]
+		if a.Quantity != b.Quantity {
+			return a.Quantity > b.Quantity
+		}
+		return a.ItemID < b.ItemID
+	})
+
+	if len(report.TopItems) > top {
+		report.TopItems = report.TopItems[:top]
+	}
+
+	return report, nil
+}
+
+func parseInt(s string) int64 {
+	n, _ := strconv.ParseInt(s, 10, 64)
+	return n
+}
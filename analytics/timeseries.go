@@ -0,0 +1,208 @@
+package analytics
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	MetricOrders  = "orders"
+	MetricRevenue = "revenue"
+)
+
+type Point struct {
+	Time  time.Time `json:"time"`
+	Value float64   `json:"value"`
+	Rate  float64   `json:"rate_per_second"`
+}
+
+// Series records samples per metric and reads them back summed into
+// buckets.
+type Series interface {
+	Backend() string
+	Add(ctx context.Context, metric string, at time.Time, value float64) error
+	Range(ctx context.Context, metric string, from time.Time, to time.Time, bucket time.Duration) ([]Point, error)
+}
+
+// NewSeries uses RedisTimeSeries when the module is loaded and otherwise
+// falls back to a per-process ring buffer covering the last window.
+func NewSeries(ctx context.Context, client *redis.Client, retention time.Duration, window time.Duration) Series {
+
+	if hasTimeSeries(ctx, client) {
+		return &redisSeries{
+			client:    client,
+			retention: retention,
+		}
+	}
+
+	return newRingSeries(window)
+}
+
+func hasTimeSeries(ctx context.Context, client *redis.Client) bool {
+
+	modules, err := client.Do(ctx, "MODULE", "LIST").Slice()
+	if err != nil {
+		return false
+	}
+
+	for _, m := range modules {
+		if strings.Contains(strings.ToLower(fmt.Sprint(m)), "timeseries") {
+			return true
+		}
+	}
+
+	return false
+}
+
+func fillRates(points []Point, bucket time.Duration) []Point {
+
+	for i := range points {
+		points[i].Rate = points[i].Value / bucket.Seconds()
+	}
+
+	return points
+}
+
+type redisSeries struct {
+	client    *redis.Client
+	retention time.Duration
+}
+
+func seriesKey(metric string) string {
+	return "analytics:ts:" + metric
+}
+
+func (s *redisSeries) Backend() string {
+	return "redistimeseries"
+}
+
+func (s *redisSeries) Add(ctx context.Context, metric string, at time.Time, value float64) error {
+
+	err := s.client.Do(ctx, "TS.ADD", seriesKey(metric), at.UnixMilli(), value,
+		"RETENTION", s.retention.Milliseconds(), "ON_DUPLICATE", "SUM").Err()
+
+	if err != nil {
+		return fmt.Errorf("failed to add sample: %w", err)
+	}
+
+	return nil
+}
+
+func (s *redisSeries) Range(ctx context.Context, metric string, from time.Time, to time.Time, bucket time.Duration) ([]Point, error) {
+
+	rows, err := s.client.Do(ctx, "TS.RANGE", seriesKey(metric), from.UnixMilli(), to.UnixMilli(),
+		"AGGREGATION", "sum", bucket.Milliseconds()).Slice()
+
+	if err != nil {
+		if strings.Contains(err.Error(), "key does not exist") {
+			return []Point{}, nil
+		}
+		return nil, fmt.Errorf("failed to read samples: %w", err)
+	}
+
+	points := make([]Point, 0, len(rows))
+
+	for _, row := range rows {
+		pair, ok := row.([]any)
+		if !ok || len(pair) != 2 {
+			continue
+		}
+
+		ms, _ := pair[0].(int64)
+		value, _ := strconv.ParseFloat(fmt.Sprint(pair[1]), 64)
+
+		points = append(points, Point{
+			Time:  time.UnixMilli(ms).UTC(),
+			Value: value,
+		})
+	}
+
+	return fillRates(points, bucket), nil
+}
+
+type slot struct {
+	second int64
+	sum    float64
+}
+
+// ringSeries keeps one slot per second of the window for each metric. It
+// only sees this replica's writes.
+type ringSeries struct {
+	mu    sync.Mutex
+	size  int64
+	slots map[string][]slot
+}
+
+func newRingSeries(window time.Duration) *ringSeries {
+	return &ringSeries{
+		size:  int64(window / time.Second),
+		slots: map[string][]slot{},
+	}
+}
+
+func (s *ringSeries) Backend() string {
+	return "memory"
+}
+
+func (s *ringSeries) Add(ctx context.Context, metric string, at time.Time, value float64) error {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	slots, ok := s.slots[metric]
+	if !ok {
+		slots = make([]slot, s.size)
+		s.slots[metric] = slots
+	}
+
+	sec := at.Unix()
+	sl := &slots[sec%s.size]
+
+	if sl.second != sec {
+		sl.second, sl.sum = sec, 0
+	}
+	sl.sum += value
+
+	return nil
+}
+
+func (s *ringSeries) Range(ctx context.Context, metric string, from time.Time, to time.Time, bucket time.Duration) ([]Point, error) {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	slots := s.slots[metric]
+	width := int64(bucket / time.Second)
+
+	points := []Point{}
+
+	if slots == nil {
+		return points, nil
+	}
+
+	// Anything older than the window has been overwritten already.
+	first := max(from.Unix(), to.Unix()-s.size+1)
+
+	for sec := first; sec <= to.Unix(); sec++ {
+		sl := slots[sec%s.size]
+		if sl.second != sec || sl.sum == 0 {
+			continue
+		}
+
+		start := time.Unix(sec-sec%width, 0).UTC()
+
+		if n := len(points); n > 0 && points[n-1].Time.Equal(start) {
+			points[n-1].Value += sl.sum
+		} else {
+			points = append(points, Point{Time: start, Value: sl.sum})
+		}
+	}
+
+	return fillRates(points, bucket), nil
+}
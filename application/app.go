@@ -2,42 +2,261 @@ package application
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"time"
 
+	"github.com/i101dev/microservices-NN/clock"
+	"github.com/i101dev/microservices-NN/codec"
+	"github.com/i101dev/microservices-NN/comment"
+	"github.com/i101dev/microservices-NN/errreport"
+	"github.com/i101dev/microservices-NN/events"
+	"github.com/i101dev/microservices-NN/fulfillment"
+	"github.com/i101dev/microservices-NN/intake"
+	"github.com/i101dev/microservices-NN/inventory"
+	"github.com/i101dev/microservices-NN/jobs"
+	"github.com/i101dev/microservices-NN/loadshed"
+	"github.com/i101dev/microservices-NN/logging"
+	"github.com/i101dev/microservices-NN/maintenance"
+	"github.com/i101dev/microservices-NN/migration"
+	"github.com/i101dev/microservices-NN/orderindex"
+	"github.com/i101dev/microservices-NN/org"
+	"github.com/i101dev/microservices-NN/quota"
+	"github.com/i101dev/microservices-NN/readmodel"
+	"github.com/i101dev/microservices-NN/recovery"
+	"github.com/i101dev/microservices-NN/redispool"
+	"github.com/i101dev/microservices-NN/repository/order"
+	"github.com/i101dev/microservices-NN/respcache"
+	"github.com/i101dev/microservices-NN/retention"
+	"github.com/i101dev/microservices-NN/scheduler"
+	"github.com/i101dev/microservices-NN/search"
+	"github.com/i101dev/microservices-NN/service"
+	"github.com/i101dev/microservices-NN/shadow"
+	"github.com/i101dev/microservices-NN/sink"
+	"github.com/i101dev/microservices-NN/slowlog"
+	"github.com/i101dev/microservices-NN/subscription"
+	"github.com/i101dev/microservices-NN/warehouse"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/redis/go-redis/v9"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
 )
 
 type App struct {
-	router http.Handler
-	rdb    *redis.Client
-	config Config
+	router    http.Handler
+	rdb       *redis.Client
+	repo      order.Repository
+	store     *order.RedisRepo
+	orders    *service.Orders
+	clock     clock.Clock
+	shedder   *loadshed.Shedder
+	pool      *redispool.Monitor
+	cache     *respcache.Cache
+	queue     *intake.Queue
+	runner    *jobs.Runner
+	scheduler *scheduler.Scheduler
+	events    *events.Publisher
+	retention *retention.Enforcer
+	readOnly  *maintenance.Mode
+	shadow    *shadow.Shadow
+	metrics   *prometheus.Registry
+	slowlog   *slowlog.Log
+	shadowDB  *redis.Client
+	crashes   *recovery.Recoverer
+	reporter  errreport.Reporter
+	// backorders and restocks are only set when stock is reserved for
+	// new orders.
+	backorders    *inventory.Backorders
+	restocks      *inventory.Listener
+	subscriptions *subscription.Store
+	filters       *orderindex.Filters
+	warehouse     *warehouse.Exporter
+	sink          *sink.Connector
+	searchIndex   *search.Index
+	projector     *search.Projector
+	readModel     *readmodel.Builder
+	views         *readmodel.Views
+	quotas        *quota.Store
+	orgs          *org.Store
+	comments      *comment.Store
+	fulfillment   *fulfillment.Assigner
+	// charger is only set when subscription orders are paid for as they
+	// are placed.
+	charger subscription.Charger
+	config  Config
 }
 
 func New(cfg Config) *App {
 
+	logging.SetLevel("", cfg.LogLevel)
+
+	c, ok := codec.ByName(cfg.RedisCodec)
+	if !ok {
+		fmt.Printf("unknown redis codec %q, using json\n", cfg.RedisCodec)
+		c = codec.JSON
+	}
+
+	rdb := redis.NewClient(&redis.Options{
+		Addr:         cfg.RedisAddress,
+		PoolSize:     cfg.RedisPoolSize,
+		MinIdleConns: cfg.RedisMinIdleConns,
+		PoolTimeout:  cfg.RedisPoolTimeout,
+	})
+
+	repo := &order.RedisRepo{
+		Client: rdb,
+		Codec:  c,
+	}
+
+	// Slow commands are caught below the repository too, so the ones
+	// behind a slow repository call can be told apart.
+	var slow *slowlog.Log
+
+	if cfg.SlowRepoThreshold > 0 {
+		slow = slowlog.New(cfg.SlowRepoThreshold, cfg.SlowLogSize)
+		slow.Logger = logging.Logger(logging.Repository)
+		rdb.AddHook(slow)
+	}
+
 	app := &App{
-		rdb: redis.NewClient(&redis.Options{
-			Addr: cfg.RedisAddress,
-		}),
-		config: cfg,
+		rdb:   rdb,
+		repo:  repo,
+		store: repo,
+		pool: &redispool.Monitor{
+			Client:        rdb,
+			Interval:      5 * time.Second,
+			WaitThreshold: cfg.PoolWaitThreshold,
+		},
+		clock:   cfg.clock(),
+		slowlog: slow,
+		crashes: &recovery.Recoverer{Log: logging.Logger(logging.HTTP)},
+		config:  cfg,
+	}
+
+	if cfg.SentryDSN != "" {
+		reporter, err := errreport.NewSentry(cfg.SentryDSN, cfg.SentryRelease, cfg.SentryEnv)
+		if err != nil {
+			fmt.Println("failed to set up error reporting:", err)
+		} else {
+			app.reporter = reporter
+			app.crashes.Report = errreport.Panics(reporter)
+		}
+	}
+
+	if cfg.ShadowRedisAddr != "" {
+		app.shadowDB = redis.NewClient(&redis.Options{Addr: cfg.ShadowRedisAddr})
+		app.shadow = newShadow(cfg, app.shadowDB, c)
+	}
+
+	app.runner = jobs.New(rdb, "default")
+	app.runner.Clock = app.clock
+	app.runner.Concurrency = cfg.JobConcurrency
+	app.runner.MaxAttempts = cfg.JobMaxAttempts
+
+	if cfg.AsyncCreate {
+		app.queue = &intake.Queue{
+			Client:    rdb,
+			Repo:      repo,
+			Workers:   cfg.AsyncWorkers,
+			Retention: 24 * time.Hour,
+			ClaimIdle: 30 * time.Second,
+		}
 	}
 
 	app.loadRoutes()
 
+	if cfg.SchedulerEnabled {
+		if err := app.loadTasks(repo); err != nil {
+			panic(err)
+		}
+	}
+
 	return app
 }
 
+// newShadow connects the repository that writes are mirrored to. It uses
+// the primary codec unless SHADOW_REDIS_CODEC names another.
+func newShadow(cfg Config, client *redis.Client, primary codec.Codec) *shadow.Shadow {
+
+	c := primary
+
+	if cfg.ShadowRedisCodec != "" {
+		if named, ok := codec.ByName(cfg.ShadowRedisCodec); ok {
+			c = named
+		} else {
+			fmt.Printf("unknown shadow redis codec %q, using %s\n", cfg.ShadowRedisCodec, c.Name())
+		}
+	}
+
+	target := &order.RedisRepo{
+		Client: client,
+		Codec:  c,
+	}
+
+	return shadow.New(target, 10_000)
+}
+
+func Handler(cfg Config, repo order.Repository) http.Handler {
+
+	app := &App{
+		repo:   repo,
+		clock:  cfg.clock(),
+		config: cfg,
+	}
+
+	app.loadRoutes()
+
+	return app.router
+}
+
+func (a *App) migrate(ctx context.Context) error {
+
+	migrator := migration.New(a.rdb)
+
+	if a.config.MigrateOnStart {
+		applied, err := migrator.Up(ctx)
+		if err != nil && !errors.Is(err, migration.ErrLocked) {
+			return fmt.Errorf("failed to migrate: %w", err)
+		}
+		for _, m := range applied {
+			fmt.Printf("applied migration %d: %s\n", m.Version, m.Name)
+		}
+	}
+
+	if err := migrator.Check(ctx); err != nil {
+		return fmt.Errorf("refusing to serve: %w", err)
+	}
+
+	return nil
+}
+
 func (a *App) Start(ctx context.Context) error {
 
+	handler := a.router
+
+	// Internal callers can use HTTP/2 without TLS, multiplexing their
+	// requests over one connection instead of pooling many.
+	if a.config.H2CEnabled {
+		handler = h2c.NewHandler(handler, &http2.Server{IdleTimeout: a.config.IdleTimeout})
+	}
+
 	server := &http.Server{
-		Addr:    fmt.Sprintf(":%d", a.config.ServerPort),
-		Handler: a.router,
+		Addr:              fmt.Sprintf(":%d", a.config.ServerPort),
+		Handler:           handler,
+		ReadHeaderTimeout: a.config.ReadHeaderTimeout,
+		ReadTimeout:       a.config.ReadTimeout,
+		WriteTimeout:      a.config.WriteTimeout,
+		IdleTimeout:       a.config.IdleTimeout,
+		MaxHeaderBytes:    a.config.MaxHeaderBytes,
+	}
+
+	if err := a.preflight(ctx); err != nil {
+		return err
 	}
 
-	if err := a.rdb.Ping(ctx).Err(); err != nil {
-		return fmt.Errorf("failed to connect redis: %w", err)
+	if a.reporter != nil {
+		defer a.reporter.Flush(2 * time.Second)
 	}
 
 	defer func() {
@@ -46,6 +265,68 @@ func (a *App) Start(ctx context.Context) error {
 		}
 	}()
 
+	if err := a.migrate(ctx); err != nil {
+		return err
+	}
+
+	go a.pool.Run(ctx)
+	go a.readOnly.Run(ctx)
+	go logging.ToggleOnSignal(ctx)
+
+	if a.shadow != nil {
+		go a.shadow.Run(ctx)
+	}
+
+	go func() {
+		if err := a.runner.Run(ctx); err != nil {
+			fmt.Println("failed to run jobs:", err)
+		}
+	}()
+
+	if a.scheduler != nil {
+		go a.scheduler.Run(ctx)
+	}
+
+	if a.queue != nil {
+		go func() {
+			if err := a.queue.Run(ctx); err != nil {
+				fmt.Println("failed to run create queue:", err)
+			}
+		}()
+	}
+
+	if a.restocks != nil {
+		go func() {
+			if err := a.restocks.Run(ctx); err != nil {
+				fmt.Println("failed to listen for restocks:", err)
+			}
+		}()
+	}
+
+	if a.sink != nil {
+		go func() {
+			if err := a.sink.Run(ctx); err != nil {
+				fmt.Println("failed to run order sink:", err)
+			}
+		}()
+	}
+
+	if a.projector != nil {
+		go func() {
+			if err := a.projector.Run(ctx); err != nil {
+				fmt.Println("failed to project orders into search:", err)
+			}
+		}()
+	}
+
+	if a.readModel != nil {
+		go func() {
+			if err := a.readModel.Run(ctx); err != nil {
+				fmt.Println("failed to build read model:", err)
+			}
+		}()
+	}
+
 	fmt.Println("Starting server")
 
 	ch := make(chan error, 1)
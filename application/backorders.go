@@ -0,0 +1,73 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/i101dev/microservices-NN/events"
+	"github.com/i101dev/microservices-NN/inventory"
+	"github.com/i101dev/microservices-NN/repository/order"
+	"github.com/i101dev/microservices-NN/tenant"
+)
+
+// loadBackorders sets up the listener that fulfils backorders when the
+// inventory service restocks an item, and returns the interceptor that
+// keeps track of which orders wait for which items.
+func (a *App) loadBackorders() order.Interceptor {
+
+	a.backorders = &inventory.Backorders{
+		Client: a.rdb,
+	}
+
+	a.restocks = &inventory.Listener{
+		Client: a.rdb,
+		Stream: a.config.InventoryStream,
+		Retry:  time.Minute,
+		Handle: a.restock,
+		Paused: a.readOnly.Enabled,
+	}
+
+	return a.backorders.Intercept
+}
+
+// restock tries every order waiting for the item, oldest first. The
+// inventory service decides which of them the new stock covers.
+func (a *App) restock(ctx context.Context, r inventory.Restocked) error {
+
+	ids, err := a.backorders.Waiting(ctx, r.ItemID)
+	if err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		o, fulfilled, err := a.orders.FulfillBackorder(ctx, id)
+		if errors.Is(err, order.ErrNotExist) {
+			if err := a.backorders.Forget(ctx, r.ItemID, id); err != nil {
+				fmt.Println("failed to forget deleted backorder:", err)
+			}
+			continue
+		} else if err != nil {
+			return err
+		}
+
+		if !fulfilled {
+			continue
+		}
+
+		if a.fulfillment != nil {
+			a.scheduleAssignment(ctx, o)
+		}
+
+		if a.events == nil {
+			continue
+		}
+
+		if _, err := a.events.Publish(ctx, events.NewBackorderFulfilled(tenant.FromContext(ctx), o, a.clock.Now())); err != nil {
+			fmt.Println("failed to publish backorder fulfilled:", err)
+		}
+	}
+
+	return nil
+}
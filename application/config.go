@@ -2,20 +2,211 @@ package application
 
 import (
 	"fmt"
+	"log/slog"
 	"os"
 	"strconv"
+	"strings"
+	"time"
+
+	"github.com/i101dev/microservices-NN/carrier"
+	"github.com/i101dev/microservices-NN/clock"
+	"github.com/i101dev/microservices-NN/handler"
+	"github.com/i101dev/microservices-NN/inventory"
+	"github.com/i101dev/microservices-NN/loadshed"
+	"github.com/i101dev/microservices-NN/logging"
+	"github.com/i101dev/microservices-NN/openapi"
+	"github.com/i101dev/microservices-NN/retention"
+	"github.com/i101dev/microservices-NN/storage"
 )
 
 type Config struct {
-	RedisAddress string
-	ServerPort   uint16
+	RedisAddress      string
+	ServerPort        uint16
+	OpenAPIValidation string
+	RedisCodec        string
+	MigrateOnStart    bool
+	ChaosEnabled      bool
+	MaxInFlight       int
+	MaxQueue          int
+	QueueTimeout      time.Duration
+	ReadHeaderTimeout time.Duration
+	ReadTimeout       time.Duration
+	WriteTimeout      time.Duration
+	IdleTimeout       time.Duration
+	MaxHeaderBytes    int
+	MaxBodyBytes      int64
+	RedisPoolSize     int
+	RedisMinIdleConns int
+	RedisPoolTimeout  time.Duration
+	PoolWaitThreshold time.Duration
+	CacheEnabled      bool
+	CacheSize         int
+	CacheTTL          time.Duration
+	AsyncCreate       bool
+	AsyncWorkers      int
+	JobConcurrency    int
+	JobMaxAttempts    int
+	SchedulerEnabled  bool
+	PendingOrderTTL   time.Duration
+	TimeseriesEnabled bool
+	TrustAuthHeaders  bool
+	SlowRepoThreshold time.Duration
+	DuplicateWindow   time.Duration
+	RejectDuplicates  bool
+	ReminderLeadTime  time.Duration
+	FraudCheckURL     string
+	FraudCheckTimeout time.Duration
+	APIKeys           map[string]loadshed.Class
+	Retention         []retention.Policy
+	RetentionDryRun   bool
+	MaintenanceMode   bool
+	ShadowRedisAddr   string
+	ShadowRedisCodec  string
+	MetricsEnabled    bool
+	SlowLogSize       int
+	LogLevel          slog.Level
+	SentryDSN         string
+	SentryRelease     string
+	SentryEnv         string
+	H2CEnabled        bool
+	OutboundIdleConns int
+	FraudCheckH2C     bool
+	InvoiceSeller     []string
+	InvoiceNotes      string
+	InvoiceCurrency   string
+	InvoiceTaxRate    float64
+	InvoiceAsyncItems int
+	DeliveryMinDays   int
+	DeliveryMaxDays   int
+	PickupSecret      string
+	PickupCodeTTL     time.Duration
+	CatalogURL        string
+	CatalogTimeout    time.Duration
+	DeliveryRulesFile string
+	CarrierSecrets    map[string]string
+	PaymentSecret     string
+	TaxRate           float64
+	TaxJurisdiction   string
+	TaxURL            string
+	TaxTimeout        time.Duration
+	InventoryURL      string
+	InventoryTimeout  time.Duration
+	InventoryStream   string
+	ChargeURL         string
+	ChargeTimeout     time.Duration
+	SubscriptionRetry time.Duration
+	MaxChargeFailures int
+	QuoteTTL          time.Duration
+	WarehouseBucket   string
+	WarehouseEndpoint string
+	WarehouseRegion   string
+	WarehousePrefix   string
+	WarehouseFlush    time.Duration
+	WarehouseKeyID    string
+	WarehouseSecret   string
+	WarehouseToken    string
+	SinkProject       string
+	SinkDataset       string
+	SinkTable         string
+	SinkEndpoint      string
+	SinkBatchSize     int
+	SinkFlush         time.Duration
+	SearchURL         string
+	SearchIndex       string
+	SearchUsername    string
+	SearchPassword    string
+	ReadModelEnabled  bool
+	QuotasEnabled     bool
+	ReplayWindow      time.Duration
+	OrgsEnabled       bool
+	TokenSecret       string
+	TokenMaxTTL       time.Duration
+	AuditEnabled      bool
+	UnknownFields     string
+	AttachBucket      string
+	AttachEndpoint    string
+	AttachRegion      string
+	AttachMaxSize     int64
+	AttachURLTTL      time.Duration
+	SLAShipWithin     time.Duration
+	SLAMethods        map[string]time.Duration
+	FulfillmentRules  string
+	StockURL          string
+	StockTimeout      time.Duration
+	TrackSecret       string
+	TrackRateLimit    int
+	TrackRateWindow   time.Duration
+	CancelPolicyFile  string
+	// Clock is never read from the environment. Tests set it to a
+	// clock.Fake; nil means the system clock.
+	Clock clock.Clock
 }
 
-func LoadConfig() Config {
-	cfg := Config{
-		RedisAddress: "localhost:6379",
-		ServerPort:   5000,
+func (c Config) clock() clock.Clock {
+	if c.Clock == nil {
+		return clock.System
+	}
+	return c.Clock
+}
+
+func DefaultConfig() Config {
+	return Config{
+		RedisAddress:      "localhost:6379",
+		ServerPort:        5000,
+		OpenAPIValidation: openapi.ModeOff,
+		RedisCodec:        "json",
+		SlowLogSize:       100,
+		OutboundIdleConns: 32,
+		InvoiceCurrency:   "$",
+		InvoiceAsyncItems: 500,
+		DeliveryMinDays:   2,
+		DeliveryMaxDays:   5,
+		PickupCodeTTL:     72 * time.Hour,
+		TokenMaxTTL:       time.Hour,
+		UnknownFields:     handler.FieldsLenient,
+		AttachRegion:      "us-east-1",
+		AttachMaxSize:     100 << 20,
+		AttachURLTTL:      15 * time.Minute,
+		CatalogTimeout:    2 * time.Second,
+		TaxTimeout:        2 * time.Second,
+		InventoryTimeout:  2 * time.Second,
+		StockTimeout:      2 * time.Second,
+		InventoryStream:   inventory.DefaultStream,
+		ChargeTimeout:     5 * time.Second,
+		SubscriptionRetry: time.Hour,
+		MaxChargeFailures: 3,
+		QuoteTTL:          7 * 24 * time.Hour,
+		WarehouseRegion:   "us-east-1",
+		WarehousePrefix:   "order-events",
+		WarehouseFlush:    5 * time.Minute,
+		SinkTable:         "order_mutations",
+		SinkBatchSize:     500,
+		SinkFlush:         10 * time.Second,
+		SearchIndex:       "orders",
+		MigrateOnStart:    true,
+		MaxQueue:          100,
+		QueueTimeout:      100 * time.Millisecond,
+		ReadHeaderTimeout: 5 * time.Second,
+		ReadTimeout:       15 * time.Second,
+		WriteTimeout:      30 * time.Second,
+		IdleTimeout:       120 * time.Second,
+		MaxHeaderBytes:    64 << 10,
+		MaxBodyBytes:      1 << 20,
+		PoolWaitThreshold: 100 * time.Millisecond,
+		CacheSize:         10000,
+		CacheTTL:          2 * time.Second,
+		FraudCheckTimeout: 2 * time.Second,
+		AsyncWorkers:      4,
+		JobConcurrency:    4,
+		JobMaxAttempts:    5,
+		SchedulerEnabled:  true,
+		TrackRateLimit:    30,
+		TrackRateWindow:   time.Minute,
 	}
+}
+
+func LoadConfig() Config {
+	cfg := DefaultConfig()
 
 	if redisAddr, exists := os.LookupEnv("REDIS_ADDR"); exists {
 		fmt.Println()
@@ -33,7 +224,1029 @@ func LoadConfig() Config {
 		}
 	}
 
-	// fmt.Printf("cfg: %+v\n", cfg)
+	if mode, exists := os.LookupEnv("OPENAPI_VALIDATION"); exists {
+		fmt.Println()
+		fmt.Println("Setting [OPENAPI_VALIDATION]")
+		fmt.Println()
+		cfg.OpenAPIValidation = mode
+	}
 
-	return cfg
+	if redisCodec, exists := os.LookupEnv("REDIS_CODEC"); exists {
+		fmt.Println()
+		fmt.Println("Setting [REDIS_CODEC]")
+		fmt.Println()
+		cfg.RedisCodec = redisCodec
+	}
+
+	if migrate, exists := os.LookupEnv("MIGRATE_ON_START"); exists {
+		if value, err := strconv.ParseBool(migrate); err == nil {
+			fmt.Println()
+			fmt.Println("Setting [MIGRATE_ON_START]")
+			fmt.Println()
+			cfg.MigrateOnStart = value
+		}
+	}
+
+	if chaosEnabled, exists := os.LookupEnv("CHAOS_ENABLED"); exists {
+		if value, err := strconv.ParseBool(chaosEnabled); err == nil {
+			fmt.Println()
+			fmt.Println("Setting [CHAOS_ENABLED]")
+			fmt.Println()
+			cfg.ChaosEnabled = value
+		}
+	}
+
+	if maxInFlight, exists := os.LookupEnv("LOADSHED_MAX_INFLIGHT"); exists {
+		if value, err := strconv.Atoi(maxInFlight); err == nil {
+			fmt.Println()
+			fmt.Println("Setting [LOADSHED_MAX_INFLIGHT]")
+			fmt.Println()
+			cfg.MaxInFlight = value
+		}
+	}
+
+	if maxQueue, exists := os.LookupEnv("LOADSHED_MAX_QUEUE"); exists {
+		if value, err := strconv.Atoi(maxQueue); err == nil {
+			fmt.Println()
+			fmt.Println("Setting [LOADSHED_MAX_QUEUE]")
+			fmt.Println()
+			cfg.MaxQueue = value
+		}
+	}
+
+	if queueTimeout, exists := os.LookupEnv("LOADSHED_QUEUE_TIMEOUT"); exists {
+		if value, err := time.ParseDuration(queueTimeout); err == nil {
+			fmt.Println()
+			fmt.Println("Setting [LOADSHED_QUEUE_TIMEOUT]")
+			fmt.Println()
+			cfg.QueueTimeout = value
+		}
+	}
+
+	if readHeaderTimeout, exists := os.LookupEnv("HTTP_READ_HEADER_TIMEOUT"); exists {
+		if value, err := time.ParseDuration(readHeaderTimeout); err == nil {
+			fmt.Println()
+			fmt.Println("Setting [HTTP_READ_HEADER_TIMEOUT]")
+			fmt.Println()
+			cfg.ReadHeaderTimeout = value
+		}
+	}
+
+	if readTimeout, exists := os.LookupEnv("HTTP_READ_TIMEOUT"); exists {
+		if value, err := time.ParseDuration(readTimeout); err == nil {
+			fmt.Println()
+			fmt.Println("Setting [HTTP_READ_TIMEOUT]")
+			fmt.Println()
+			cfg.ReadTimeout = value
+		}
+	}
+
+	if writeTimeout, exists := os.LookupEnv("HTTP_WRITE_TIMEOUT"); exists {
+		if value, err := time.ParseDuration(writeTimeout); err == nil {
+			fmt.Println()
+			fmt.Println("Setting [HTTP_WRITE_TIMEOUT]")
+			fmt.Println()
+			cfg.WriteTimeout = value
+		}
+	}
+
+	if idleTimeout, exists := os.LookupEnv("HTTP_IDLE_TIMEOUT"); exists {
+		if value, err := time.ParseDuration(idleTimeout); err == nil {
+			fmt.Println()
+			fmt.Println("Setting [HTTP_IDLE_TIMEOUT]")
+			fmt.Println()
+			cfg.IdleTimeout = value
+		}
+	}
+
+	if maxHeaderBytes, exists := os.LookupEnv("HTTP_MAX_HEADER_BYTES"); exists {
+		if value, err := strconv.Atoi(maxHeaderBytes); err == nil {
+			fmt.Println()
+			fmt.Println("Setting [HTTP_MAX_HEADER_BYTES]")
+			fmt.Println()
+			cfg.MaxHeaderBytes = value
+		}
+	}
+
+	if maxBodyBytes, exists := os.LookupEnv("HTTP_MAX_BODY_BYTES"); exists {
+		if value, err := strconv.ParseInt(maxBodyBytes, 10, 64); err == nil {
+			fmt.Println()
+			fmt.Println("Setting [HTTP_MAX_BODY_BYTES]")
+			fmt.Println()
+			cfg.MaxBodyBytes = value
+		}
+	}
+
+	if poolSize, exists := os.LookupEnv("REDIS_POOL_SIZE"); exists {
+		if value, err := strconv.Atoi(poolSize); err == nil {
+			fmt.Println()
+			fmt.Println("Setting [REDIS_POOL_SIZE]")
+			fmt.Println()
+			cfg.RedisPoolSize = value
+		}
+	}
+
+	if minIdle, exists := os.LookupEnv("REDIS_MIN_IDLE_CONNS"); exists {
+		if value, err := strconv.Atoi(minIdle); err == nil {
+			fmt.Println()
+			fmt.Println("Setting [REDIS_MIN_IDLE_CONNS]")
+			fmt.Println()
+			cfg.RedisMinIdleConns = value
+		}
+	}
+
+	if poolTimeout, exists := os.LookupEnv("REDIS_POOL_TIMEOUT"); exists {
+		if value, err := time.ParseDuration(poolTimeout); err == nil {
+			fmt.Println()
+			fmt.Println("Setting [REDIS_POOL_TIMEOUT]")
+			fmt.Println()
+			cfg.RedisPoolTimeout = value
+		}
+	}
+
+	if waitThreshold, exists := os.LookupEnv("REDIS_POOL_WAIT_THRESHOLD"); exists {
+		if value, err := time.ParseDuration(waitThreshold); err == nil {
+			fmt.Println()
+			fmt.Println("Setting [REDIS_POOL_WAIT_THRESHOLD]")
+			fmt.Println()
+			cfg.PoolWaitThreshold = value
+		}
+	}
+
+	if cacheEnabled, exists := os.LookupEnv("RESPONSE_CACHE_ENABLED"); exists {
+		if value, err := strconv.ParseBool(cacheEnabled); err == nil {
+			fmt.Println()
+			fmt.Println("Setting [RESPONSE_CACHE_ENABLED]")
+			fmt.Println()
+			cfg.CacheEnabled = value
+		}
+	}
+
+	if cacheSize, exists := os.LookupEnv("RESPONSE_CACHE_SIZE"); exists {
+		if value, err := strconv.Atoi(cacheSize); err == nil {
+			fmt.Println()
+			fmt.Println("Setting [RESPONSE_CACHE_SIZE]")
+			fmt.Println()
+			cfg.CacheSize = value
+		}
+	}
+
+	if cacheTTL, exists := os.LookupEnv("RESPONSE_CACHE_TTL"); exists {
+		if value, err := time.ParseDuration(cacheTTL); err == nil {
+			fmt.Println()
+			fmt.Println("Setting [RESPONSE_CACHE_TTL]")
+			fmt.Println()
+			cfg.CacheTTL = value
+		}
+	}
+
+	if asyncCreate, exists := os.LookupEnv("ASYNC_CREATE_ENABLED"); exists {
+		if value, err := strconv.ParseBool(asyncCreate); err == nil {
+			fmt.Println()
+			fmt.Println("Setting [ASYNC_CREATE_ENABLED]")
+			fmt.Println()
+			cfg.AsyncCreate = value
+		}
+	}
+
+	if asyncWorkers, exists := os.LookupEnv("ASYNC_CREATE_WORKERS"); exists {
+		if value, err := strconv.Atoi(asyncWorkers); err == nil {
+			fmt.Println()
+			fmt.Println("Setting [ASYNC_CREATE_WORKERS]")
+			fmt.Println()
+			cfg.AsyncWorkers = value
+		}
+	}
+
+	if jobConcurrency, exists := os.LookupEnv("JOBS_CONCURRENCY"); exists {
+		if value, err := strconv.Atoi(jobConcurrency); err == nil {
+			fmt.Println()
+			fmt.Println("Setting [JOBS_CONCURRENCY]")
+			fmt.Println()
+			cfg.JobConcurrency = value
+		}
+	}
+
+	if jobMaxAttempts, exists := os.LookupEnv("JOBS_MAX_ATTEMPTS"); exists {
+		if value, err := strconv.Atoi(jobMaxAttempts); err == nil {
+			fmt.Println()
+			fmt.Println("Setting [JOBS_MAX_ATTEMPTS]")
+			fmt.Println()
+			cfg.JobMaxAttempts = value
+		}
+	}
+
+	if schedulerEnabled, exists := os.LookupEnv("SCHEDULER_ENABLED"); exists {
+		if value, err := strconv.ParseBool(schedulerEnabled); err == nil {
+			fmt.Println()
+			fmt.Println("Setting [SCHEDULER_ENABLED]")
+			fmt.Println()
+			cfg.SchedulerEnabled = value
+		}
+	}
+
+	if pendingTTL, exists := os.LookupEnv("PENDING_ORDER_TTL"); exists {
+		if value, err := time.ParseDuration(pendingTTL); err == nil {
+			fmt.Println()
+			fmt.Println("Setting [PENDING_ORDER_TTL]")
+			fmt.Println()
+			cfg.PendingOrderTTL = value
+		}
+	}
+
+	if timeseries, exists := os.LookupEnv("ANALYTICS_TIMESERIES"); exists {
+		if value, err := strconv.ParseBool(timeseries); err == nil {
+			fmt.Println()
+			fmt.Println("Setting [ANALYTICS_TIMESERIES]")
+			fmt.Println()
+			cfg.TimeseriesEnabled = value
+		}
+	}
+
+	if trustAuth, exists := os.LookupEnv("AUTH_TRUSTED_HEADERS"); exists {
+		if value, err := strconv.ParseBool(trustAuth); err == nil {
+			fmt.Println()
+			fmt.Println("Setting [AUTH_TRUSTED_HEADERS]")
+			fmt.Println()
+			cfg.TrustAuthHeaders = value
+		}
+	}
+
+	if slowRepo, exists := os.LookupEnv("REPOSITORY_SLOW_THRESHOLD"); exists {
+		if value, err := time.ParseDuration(slowRepo); err == nil {
+			fmt.Println()
+			fmt.Println("Setting [REPOSITORY_SLOW_THRESHOLD]")
+			fmt.Println()
+			cfg.SlowRepoThreshold = value
+		}
+	}
+
+	if dupWindow, exists := os.LookupEnv("DUPLICATE_WINDOW"); exists {
+		if value, err := time.ParseDuration(dupWindow); err == nil {
+			fmt.Println()
+			fmt.Println("Setting [DUPLICATE_WINDOW]")
+			fmt.Println()
+			cfg.DuplicateWindow = value
+		}
+	}
+
+	if dupAction, exists := os.LookupEnv("DUPLICATE_ACTION"); exists {
+		switch dupAction {
+		case "flag", "reject":
+			fmt.Println()
+			fmt.Println("Setting [DUPLICATE_ACTION]")
+			fmt.Println()
+			cfg.RejectDuplicates = dupAction == "reject"
+		}
+	}
+
+	if reminder, exists := os.LookupEnv("PAYMENT_REMINDER_BEFORE"); exists {
+		if value, err := time.ParseDuration(reminder); err == nil {
+			fmt.Println()
+			fmt.Println("Setting [PAYMENT_REMINDER_BEFORE]")
+			fmt.Println()
+			cfg.ReminderLeadTime = value
+		}
+	}
+
+	if fraudURL, exists := os.LookupEnv("FRAUD_CHECK_URL"); exists {
+		fmt.Println()
+		fmt.Println("Setting [FRAUD_CHECK_URL]")
+		fmt.Println()
+		cfg.FraudCheckURL = fraudURL
+	}
+
+	if fraudTimeout, exists := os.LookupEnv("FRAUD_CHECK_TIMEOUT"); exists {
+		if value, err := time.ParseDuration(fraudTimeout); err == nil {
+			fmt.Println()
+			fmt.Println("Setting [FRAUD_CHECK_TIMEOUT]")
+			fmt.Println()
+			cfg.FraudCheckTimeout = value
+		}
+	}
+
+	if apiKeys, exists := os.LookupEnv("API_KEYS"); exists {
+		if value, err := parseAPIKeys(apiKeys); err == nil {
+			fmt.Println()
+			fmt.Println("Setting [API_KEYS]")
+			fmt.Println()
+			cfg.APIKeys = value
+		} else {
+			fmt.Println("failed to parse API_KEYS:", err)
+		}
+	}
+
+	if policies, exists := os.LookupEnv("RETENTION_POLICIES"); exists {
+		if value, err := retention.ParsePolicies(policies); err == nil {
+			fmt.Println()
+			fmt.Println("Setting [RETENTION_POLICIES]")
+			fmt.Println()
+			cfg.Retention = value
+		} else {
+			fmt.Println("failed to parse RETENTION_POLICIES:", err)
+		}
+	}
+
+	if dryRun, exists := os.LookupEnv("RETENTION_DRY_RUN"); exists {
+		if value, err := strconv.ParseBool(dryRun); err == nil {
+			fmt.Println()
+			fmt.Println("Setting [RETENTION_DRY_RUN]")
+			fmt.Println()
+			cfg.RetentionDryRun = value
+		}
+	}
+
+	if maintenanceMode, exists := os.LookupEnv("MAINTENANCE_MODE"); exists {
+		if value, err := strconv.ParseBool(maintenanceMode); err == nil {
+			fmt.Println()
+			fmt.Println("Setting [MAINTENANCE_MODE]")
+			fmt.Println()
+			cfg.MaintenanceMode = value
+		}
+	}
+
+	if shadowAddr, exists := os.LookupEnv("SHADOW_REDIS_ADDRESS"); exists {
+		fmt.Println()
+		fmt.Println("Setting [SHADOW_REDIS_ADDRESS]")
+		fmt.Println()
+		cfg.ShadowRedisAddr = shadowAddr
+	}
+
+	if shadowCodec, exists := os.LookupEnv("SHADOW_REDIS_CODEC"); exists {
+		fmt.Println()
+		fmt.Println("Setting [SHADOW_REDIS_CODEC]")
+		fmt.Println()
+		cfg.ShadowRedisCodec = shadowCodec
+	}
+
+	if metricsEnabled, exists := os.LookupEnv("METRICS_ENABLED"); exists {
+		if value, err := strconv.ParseBool(metricsEnabled); err == nil {
+			fmt.Println()
+			fmt.Println("Setting [METRICS_ENABLED]")
+			fmt.Println()
+			cfg.MetricsEnabled = value
+		}
+	}
+
+	if slowLogSize, exists := os.LookupEnv("SLOW_LOG_SIZE"); exists {
+		if value, err := strconv.Atoi(slowLogSize); err == nil && value >= 0 {
+			fmt.Println()
+			fmt.Println("Setting [SLOW_LOG_SIZE]")
+			fmt.Println()
+			cfg.SlowLogSize = value
+		}
+	}
+
+	if logLevel, exists := os.LookupEnv("LOG_LEVEL"); exists {
+		if value, err := logging.ParseLevel(logLevel); err == nil {
+			fmt.Println()
+			fmt.Println("Setting [LOG_LEVEL]")
+			fmt.Println()
+			cfg.LogLevel = value
+		}
+	}
+
+	if sentryDSN, exists := os.LookupEnv("SENTRY_DSN"); exists {
+		fmt.Println()
+		fmt.Println("Setting [SENTRY_DSN]")
+		fmt.Println()
+		cfg.SentryDSN = sentryDSN
+	}
+
+	if sentryRelease, exists := os.LookupEnv("SENTRY_RELEASE"); exists {
+		fmt.Println()
+		fmt.Println("Setting [SENTRY_RELEASE]")
+		fmt.Println()
+		cfg.SentryRelease = sentryRelease
+	}
+
+	if sentryEnv, exists := os.LookupEnv("SENTRY_ENVIRONMENT"); exists {
+		fmt.Println()
+		fmt.Println("Setting [SENTRY_ENVIRONMENT]")
+		fmt.Println()
+		cfg.SentryEnv = sentryEnv
+	}
+
+	if h2cEnabled, exists := os.LookupEnv("H2C_ENABLED"); exists {
+		if value, err := strconv.ParseBool(h2cEnabled); err == nil {
+			fmt.Println()
+			fmt.Println("Setting [H2C_ENABLED]")
+			fmt.Println()
+			cfg.H2CEnabled = value
+		}
+	}
+
+	if outboundIdleConns, exists := os.LookupEnv("OUTBOUND_IDLE_CONNS_PER_HOST"); exists {
+		if value, err := strconv.Atoi(outboundIdleConns); err == nil && value > 0 {
+			fmt.Println()
+			fmt.Println("Setting [OUTBOUND_IDLE_CONNS_PER_HOST]")
+			fmt.Println()
+			cfg.OutboundIdleConns = value
+		}
+	}
+
+	if fraudCheckH2C, exists := os.LookupEnv("FRAUD_CHECK_H2C"); exists {
+		if value, err := strconv.ParseBool(fraudCheckH2C); err == nil {
+			fmt.Println()
+			fmt.Println("Setting [FRAUD_CHECK_H2C]")
+			fmt.Println()
+			cfg.FraudCheckH2C = value
+		}
+	}
+
+	if invoiceSeller, exists := os.LookupEnv("INVOICE_SELLER"); exists {
+		fmt.Println()
+		fmt.Println("Setting [INVOICE_SELLER]")
+		fmt.Println()
+		cfg.InvoiceSeller = strings.Split(invoiceSeller, "|")
+	}
+
+	if invoiceNotes, exists := os.LookupEnv("INVOICE_NOTES"); exists {
+		fmt.Println()
+		fmt.Println("Setting [INVOICE_NOTES]")
+		fmt.Println()
+		cfg.InvoiceNotes = invoiceNotes
+	}
+
+	if invoiceCurrency, exists := os.LookupEnv("INVOICE_CURRENCY"); exists {
+		fmt.Println()
+		fmt.Println("Setting [INVOICE_CURRENCY]")
+		fmt.Println()
+		cfg.InvoiceCurrency = invoiceCurrency
+	}
+
+	if invoiceTaxRate, exists := os.LookupEnv("INVOICE_TAX_RATE"); exists {
+		if value, err := strconv.ParseFloat(invoiceTaxRate, 64); err == nil && value >= 0 && value < 1 {
+			fmt.Println()
+			fmt.Println("Setting [INVOICE_TAX_RATE]")
+			fmt.Println()
+			cfg.InvoiceTaxRate = value
+		}
+	}
+
+	if invoiceAsyncItems, exists := os.LookupEnv("INVOICE_ASYNC_ITEMS"); exists {
+		if value, err := strconv.Atoi(invoiceAsyncItems); err == nil && value >= 0 {
+			fmt.Println()
+			fmt.Println("Setting [INVOICE_ASYNC_ITEMS]")
+			fmt.Println()
+			cfg.InvoiceAsyncItems = value
+		}
+	}
+
+	if deliveryWindow, exists := os.LookupEnv("DELIVERY_WINDOW_DAYS"); exists {
+		minDays, maxDays, ok := strings.Cut(deliveryWindow, "-")
+		low, lowErr := strconv.Atoi(minDays)
+		high, highErr := strconv.Atoi(maxDays)
+		if ok && lowErr == nil && highErr == nil && low >= 0 && low <= high {
+			fmt.Println()
+			fmt.Println("Setting [DELIVERY_WINDOW_DAYS]")
+			fmt.Println()
+			cfg.DeliveryMinDays = low
+			cfg.DeliveryMaxDays = high
+		}
+	}
+
+	if pickupSecret, exists := os.LookupEnv("PICKUP_CODE_SECRET"); exists {
+		fmt.Println()
+		fmt.Println("Setting [PICKUP_CODE_SECRET]")
+		fmt.Println()
+		cfg.PickupSecret = pickupSecret
+	}
+
+	if pickupTTL, exists := os.LookupEnv("PICKUP_CODE_TTL"); exists {
+		if value, err := time.ParseDuration(pickupTTL); err == nil && value > 0 {
+			fmt.Println()
+			fmt.Println("Setting [PICKUP_CODE_TTL]")
+			fmt.Println()
+			cfg.PickupCodeTTL = value
+		}
+	}
+
+	if catalogURL, exists := os.LookupEnv("CATALOG_URL"); exists {
+		fmt.Println()
+		fmt.Println("Setting [CATALOG_URL]")
+		fmt.Println()
+		cfg.CatalogURL = catalogURL
+	}
+
+	if catalogTimeout, exists := os.LookupEnv("CATALOG_TIMEOUT"); exists {
+		if value, err := time.ParseDuration(catalogTimeout); err == nil {
+			fmt.Println()
+			fmt.Println("Setting [CATALOG_TIMEOUT]")
+			fmt.Println()
+			cfg.CatalogTimeout = value
+		}
+	}
+
+	if deliveryRules, exists := os.LookupEnv("DELIVERY_RULES_FILE"); exists {
+		fmt.Println()
+		fmt.Println("Setting [DELIVERY_RULES_FILE]")
+		fmt.Println()
+		cfg.DeliveryRulesFile = deliveryRules
+	}
+
+	if carrierSecrets, exists := os.LookupEnv("CARRIER_WEBHOOK_SECRETS"); exists {
+		if value, err := parseCarrierSecrets(carrierSecrets); err == nil {
+			fmt.Println()
+			fmt.Println("Setting [CARRIER_WEBHOOK_SECRETS]")
+			fmt.Println()
+			cfg.CarrierSecrets = value
+		} else {
+			fmt.Println("failed to parse CARRIER_WEBHOOK_SECRETS:", err)
+		}
+	}
+
+	if paymentSecret, exists := os.LookupEnv("PAYMENT_WEBHOOK_SECRET"); exists {
+		fmt.Println()
+		fmt.Println("Setting [PAYMENT_WEBHOOK_SECRET]")
+		fmt.Println()
+		cfg.PaymentSecret = paymentSecret
+	}
+
+	if taxRate, exists := os.LookupEnv("TAX_RATE"); exists {
+		if value, err := strconv.ParseFloat(taxRate, 64); err == nil && value >= 0 && value < 1 {
+			fmt.Println()
+			fmt.Println("Setting [TAX_RATE]")
+			fmt.Println()
+			cfg.TaxRate = value
+		}
+	}
+
+	if taxJurisdiction, exists := os.LookupEnv("TAX_JURISDICTION"); exists {
+		fmt.Println()
+		fmt.Println("Setting [TAX_JURISDICTION]")
+		fmt.Println()
+		cfg.TaxJurisdiction = taxJurisdiction
+	}
+
+	if taxURL, exists := os.LookupEnv("TAX_URL"); exists {
+		fmt.Println()
+		fmt.Println("Setting [TAX_URL]")
+		fmt.Println()
+		cfg.TaxURL = taxURL
+	}
+
+	if taxTimeout, exists := os.LookupEnv("TAX_TIMEOUT"); exists {
+		if value, err := time.ParseDuration(taxTimeout); err == nil {
+			fmt.Println()
+			fmt.Println("Setting [TAX_TIMEOUT]")
+			fmt.Println()
+			cfg.TaxTimeout = value
+		}
+	}
+
+	if inventoryURL, exists := os.LookupEnv("INVENTORY_URL"); exists {
+		fmt.Println()
+		fmt.Println("Setting [INVENTORY_URL]")
+		fmt.Println()
+		cfg.InventoryURL = inventoryURL
+	}
+
+	if inventoryTimeout, exists := os.LookupEnv("INVENTORY_TIMEOUT"); exists {
+		if value, err := time.ParseDuration(inventoryTimeout); err == nil {
+			fmt.Println()
+			fmt.Println("Setting [INVENTORY_TIMEOUT]")
+			fmt.Println()
+			cfg.InventoryTimeout = value
+		}
+	}
+
+	if inventoryStream, exists := os.LookupEnv("INVENTORY_STREAM"); exists {
+		fmt.Println()
+		fmt.Println("Setting [INVENTORY_STREAM]")
+		fmt.Println()
+		cfg.InventoryStream = inventoryStream
+	}
+
+	if chargeURL, exists := os.LookupEnv("SUBSCRIPTION_CHARGE_URL"); exists {
+		fmt.Println()
+		fmt.Println("Setting [SUBSCRIPTION_CHARGE_URL]")
+		fmt.Println()
+		cfg.ChargeURL = chargeURL
+	}
+
+	if chargeTimeout, exists := os.LookupEnv("SUBSCRIPTION_CHARGE_TIMEOUT"); exists {
+		if value, err := time.ParseDuration(chargeTimeout); err == nil {
+			fmt.Println()
+			fmt.Println("Setting [SUBSCRIPTION_CHARGE_TIMEOUT]")
+			fmt.Println()
+			cfg.ChargeTimeout = value
+		}
+	}
+
+	if retry, exists := os.LookupEnv("SUBSCRIPTION_RETRY"); exists {
+		if value, err := time.ParseDuration(retry); err == nil && value > 0 {
+			fmt.Println()
+			fmt.Println("Setting [SUBSCRIPTION_RETRY]")
+			fmt.Println()
+			cfg.SubscriptionRetry = value
+		}
+	}
+
+	if maxFailures, exists := os.LookupEnv("SUBSCRIPTION_MAX_CHARGE_FAILURES"); exists {
+		if value, err := strconv.Atoi(maxFailures); err == nil && value > 0 {
+			fmt.Println()
+			fmt.Println("Setting [SUBSCRIPTION_MAX_CHARGE_FAILURES]")
+			fmt.Println()
+			cfg.MaxChargeFailures = value
+		}
+	}
+
+	if quoteTTL, exists := os.LookupEnv("QUOTE_TTL"); exists {
+		if value, err := time.ParseDuration(quoteTTL); err == nil && value > 0 {
+			fmt.Println()
+			fmt.Println("Setting [QUOTE_TTL]")
+			fmt.Println()
+			cfg.QuoteTTL = value
+		}
+	}
+
+	if bucket, exists := os.LookupEnv("WAREHOUSE_S3_BUCKET"); exists {
+		fmt.Println()
+		fmt.Println("Setting [WAREHOUSE_S3_BUCKET]")
+		fmt.Println()
+		cfg.WarehouseBucket = bucket
+	}
+
+	if endpoint, exists := os.LookupEnv("WAREHOUSE_S3_ENDPOINT"); exists {
+		fmt.Println()
+		fmt.Println("Setting [WAREHOUSE_S3_ENDPOINT]")
+		fmt.Println()
+		cfg.WarehouseEndpoint = endpoint
+	}
+
+	if region, exists := os.LookupEnv("WAREHOUSE_S3_REGION"); exists {
+		fmt.Println()
+		fmt.Println("Setting [WAREHOUSE_S3_REGION]")
+		fmt.Println()
+		cfg.WarehouseRegion = region
+	}
+
+	if prefix, exists := os.LookupEnv("WAREHOUSE_S3_PREFIX"); exists {
+		fmt.Println()
+		fmt.Println("Setting [WAREHOUSE_S3_PREFIX]")
+		fmt.Println()
+		cfg.WarehousePrefix = prefix
+	}
+
+	if flush, exists := os.LookupEnv("WAREHOUSE_FLUSH_INTERVAL"); exists {
+		if value, err := time.ParseDuration(flush); err == nil && value > 0 {
+			fmt.Println()
+			fmt.Println("Setting [WAREHOUSE_FLUSH_INTERVAL]")
+			fmt.Println()
+			cfg.WarehouseFlush = value
+		}
+	}
+
+	// The credentials use the standard AWS variable names.
+	if keyID, exists := os.LookupEnv("AWS_ACCESS_KEY_ID"); exists {
+		fmt.Println()
+		fmt.Println("Setting [AWS_ACCESS_KEY_ID]")
+		fmt.Println()
+		cfg.WarehouseKeyID = keyID
+	}
+
+	if secret, exists := os.LookupEnv("AWS_SECRET_ACCESS_KEY"); exists {
+		fmt.Println()
+		fmt.Println("Setting [AWS_SECRET_ACCESS_KEY]")
+		fmt.Println()
+		cfg.WarehouseSecret = secret
+	}
+
+	if token, exists := os.LookupEnv("AWS_SESSION_TOKEN"); exists {
+		fmt.Println()
+		fmt.Println("Setting [AWS_SESSION_TOKEN]")
+		fmt.Println()
+		cfg.WarehouseToken = token
+	}
+
+	if project, exists := os.LookupEnv("SINK_BIGQUERY_PROJECT"); exists {
+		fmt.Println()
+		fmt.Println("Setting [SINK_BIGQUERY_PROJECT]")
+		fmt.Println()
+		cfg.SinkProject = project
+	}
+
+	if dataset, exists := os.LookupEnv("SINK_BIGQUERY_DATASET"); exists {
+		fmt.Println()
+		fmt.Println("Setting [SINK_BIGQUERY_DATASET]")
+		fmt.Println()
+		cfg.SinkDataset = dataset
+	}
+
+	if table, exists := os.LookupEnv("SINK_BIGQUERY_TABLE"); exists {
+		fmt.Println()
+		fmt.Println("Setting [SINK_BIGQUERY_TABLE]")
+		fmt.Println()
+		cfg.SinkTable = table
+	}
+
+	if endpoint, exists := os.LookupEnv("SINK_BIGQUERY_ENDPOINT"); exists {
+		fmt.Println()
+		fmt.Println("Setting [SINK_BIGQUERY_ENDPOINT]")
+		fmt.Println()
+		cfg.SinkEndpoint = endpoint
+	}
+
+	if batchSize, exists := os.LookupEnv("SINK_BATCH_SIZE"); exists {
+		if value, err := strconv.Atoi(batchSize); err == nil && value > 0 {
+			fmt.Println()
+			fmt.Println("Setting [SINK_BATCH_SIZE]")
+			fmt.Println()
+			cfg.SinkBatchSize = value
+		}
+	}
+
+	if flush, exists := os.LookupEnv("SINK_FLUSH_INTERVAL"); exists {
+		if value, err := time.ParseDuration(flush); err == nil && value > 0 {
+			fmt.Println()
+			fmt.Println("Setting [SINK_FLUSH_INTERVAL]")
+			fmt.Println()
+			cfg.SinkFlush = value
+		}
+	}
+
+	if url, exists := os.LookupEnv("OPENSEARCH_URL"); exists {
+		fmt.Println()
+		fmt.Println("Setting [OPENSEARCH_URL]")
+		fmt.Println()
+		cfg.SearchURL = url
+	}
+
+	if index, exists := os.LookupEnv("OPENSEARCH_INDEX"); exists {
+		fmt.Println()
+		fmt.Println("Setting [OPENSEARCH_INDEX]")
+		fmt.Println()
+		cfg.SearchIndex = index
+	}
+
+	if username, exists := os.LookupEnv("OPENSEARCH_USERNAME"); exists {
+		fmt.Println()
+		fmt.Println("Setting [OPENSEARCH_USERNAME]")
+		fmt.Println()
+		cfg.SearchUsername = username
+	}
+
+	if password, exists := os.LookupEnv("OPENSEARCH_PASSWORD"); exists {
+		fmt.Println()
+		fmt.Println("Setting [OPENSEARCH_PASSWORD]")
+		fmt.Println()
+		cfg.SearchPassword = password
+	}
+
+	if readModel, exists := os.LookupEnv("READ_MODEL_ENABLED"); exists {
+		if value, err := strconv.ParseBool(readModel); err == nil {
+			fmt.Println()
+			fmt.Println("Setting [READ_MODEL_ENABLED]")
+			fmt.Println()
+			cfg.ReadModelEnabled = value
+		}
+	}
+
+	if quotas, exists := os.LookupEnv("QUOTAS_ENABLED"); exists {
+		if value, err := strconv.ParseBool(quotas); err == nil {
+			fmt.Println()
+			fmt.Println("Setting [QUOTAS_ENABLED]")
+			fmt.Println()
+			cfg.QuotasEnabled = value
+		}
+	}
+
+	if replayWindow, exists := os.LookupEnv("REPLAY_WINDOW"); exists {
+		if value, err := time.ParseDuration(replayWindow); err == nil {
+			fmt.Println()
+			fmt.Println("Setting [REPLAY_WINDOW]")
+			fmt.Println()
+			cfg.ReplayWindow = value
+		}
+	}
+
+	if orgs, exists := os.LookupEnv("ORGS_ENABLED"); exists {
+		if value, err := strconv.ParseBool(orgs); err == nil {
+			fmt.Println()
+			fmt.Println("Setting [ORGS_ENABLED]")
+			fmt.Println()
+			cfg.OrgsEnabled = value
+		}
+	}
+
+	if tokenSecret, exists := os.LookupEnv("DELEGATED_TOKEN_SECRET"); exists {
+		fmt.Println()
+		fmt.Println("Setting [DELEGATED_TOKEN_SECRET]")
+		fmt.Println()
+		cfg.TokenSecret = tokenSecret
+	}
+
+	if tokenTTL, exists := os.LookupEnv("DELEGATED_TOKEN_MAX_TTL"); exists {
+		if value, err := time.ParseDuration(tokenTTL); err == nil && value > 0 {
+			fmt.Println()
+			fmt.Println("Setting [DELEGATED_TOKEN_MAX_TTL]")
+			fmt.Println()
+			cfg.TokenMaxTTL = value
+		}
+	}
+
+	if auditEnabled, exists := os.LookupEnv("AUDIT_ENABLED"); exists {
+		if value, err := strconv.ParseBool(auditEnabled); err == nil {
+			fmt.Println()
+			fmt.Println("Setting [AUDIT_ENABLED]")
+			fmt.Println()
+			cfg.AuditEnabled = value
+		}
+	}
+
+	if unknownFields, exists := os.LookupEnv("UNKNOWN_FIELDS"); exists {
+		if unknownFields == handler.FieldsLenient || unknownFields == handler.FieldsStrict {
+			fmt.Println()
+			fmt.Println("Setting [UNKNOWN_FIELDS]")
+			fmt.Println()
+			cfg.UnknownFields = unknownFields
+		}
+	}
+
+	if bucket, exists := os.LookupEnv("ATTACHMENTS_S3_BUCKET"); exists {
+		fmt.Println()
+		fmt.Println("Setting [ATTACHMENTS_S3_BUCKET]")
+		fmt.Println()
+		cfg.AttachBucket = bucket
+	}
+
+	if endpoint, exists := os.LookupEnv("ATTACHMENTS_S3_ENDPOINT"); exists {
+		fmt.Println()
+		fmt.Println("Setting [ATTACHMENTS_S3_ENDPOINT]")
+		fmt.Println()
+		cfg.AttachEndpoint = endpoint
+	}
+
+	if region, exists := os.LookupEnv("ATTACHMENTS_S3_REGION"); exists {
+		fmt.Println()
+		fmt.Println("Setting [ATTACHMENTS_S3_REGION]")
+		fmt.Println()
+		cfg.AttachRegion = region
+	}
+
+	if maxSize, exists := os.LookupEnv("ATTACHMENTS_MAX_BYTES"); exists {
+		if value, err := strconv.ParseInt(maxSize, 10, 64); err == nil && value > 0 {
+			fmt.Println()
+			fmt.Println("Setting [ATTACHMENTS_MAX_BYTES]")
+			fmt.Println()
+			cfg.AttachMaxSize = value
+		}
+	}
+
+	if ttl, exists := os.LookupEnv("ATTACHMENTS_URL_TTL"); exists {
+		if value, err := time.ParseDuration(ttl); err == nil && value > 0 && value <= storage.MaxURLTTL {
+			fmt.Println()
+			fmt.Println("Setting [ATTACHMENTS_URL_TTL]")
+			fmt.Println()
+			cfg.AttachURLTTL = value
+		}
+	}
+
+	if shipWithin, exists := os.LookupEnv("SLA_SHIP_WITHIN"); exists {
+		if value, err := time.ParseDuration(shipWithin); err == nil && value >= 0 {
+			fmt.Println()
+			fmt.Println("Setting [SLA_SHIP_WITHIN]")
+			fmt.Println()
+			cfg.SLAShipWithin = value
+		}
+	}
+
+	if methods, exists := os.LookupEnv("SLA_SHIP_WITHIN_BY_METHOD"); exists {
+		if value, err := parseSLAMethods(methods); err == nil {
+			fmt.Println()
+			fmt.Println("Setting [SLA_SHIP_WITHIN_BY_METHOD]")
+			fmt.Println()
+			cfg.SLAMethods = value
+		} else {
+			fmt.Println("failed to parse SLA_SHIP_WITHIN_BY_METHOD:", err)
+		}
+	}
+
+	if fulfillmentRules, exists := os.LookupEnv("FULFILLMENT_RULES_FILE"); exists {
+		fmt.Println()
+		fmt.Println("Setting [FULFILLMENT_RULES_FILE]")
+		fmt.Println()
+		cfg.FulfillmentRules = fulfillmentRules
+	}
+
+	if stockURL, exists := os.LookupEnv("FULFILLMENT_STOCK_URL"); exists {
+		fmt.Println()
+		fmt.Println("Setting [FULFILLMENT_STOCK_URL]")
+		fmt.Println()
+		cfg.StockURL = stockURL
+	}
+
+	if stockTimeout, exists := os.LookupEnv("FULFILLMENT_STOCK_TIMEOUT"); exists {
+		if value, err := time.ParseDuration(stockTimeout); err == nil {
+			fmt.Println()
+			fmt.Println("Setting [FULFILLMENT_STOCK_TIMEOUT]")
+			fmt.Println()
+			cfg.StockTimeout = value
+		}
+	}
+
+	if trackSecret, exists := os.LookupEnv("TRACKING_LINK_SECRET"); exists {
+		fmt.Println()
+		fmt.Println("Setting [TRACKING_LINK_SECRET]")
+		fmt.Println()
+		cfg.TrackSecret = trackSecret
+	}
+
+	if trackRateLimit, exists := os.LookupEnv("TRACKING_RATE_LIMIT"); exists {
+		if value, err := strconv.Atoi(trackRateLimit); err == nil && value > 0 {
+			fmt.Println()
+			fmt.Println("Setting [TRACKING_RATE_LIMIT]")
+			fmt.Println()
+			cfg.TrackRateLimit = value
+		}
+	}
+
+	if trackRateWindow, exists := os.LookupEnv("TRACKING_RATE_WINDOW"); exists {
+		if value, err := time.ParseDuration(trackRateWindow); err == nil && value > 0 {
+			fmt.Println()
+			fmt.Println("Setting [TRACKING_RATE_WINDOW]")
+			fmt.Println()
+			cfg.TrackRateWindow = value
+		}
+	}
+
+	if cancelPolicy, exists := os.LookupEnv("CANCELLATION_POLICY_FILE"); exists {
+		fmt.Println()
+		fmt.Println("Setting [CANCELLATION_POLICY_FILE]")
+		fmt.Println()
+		cfg.CancelPolicyFile = cancelPolicy
+	}
+
+	// fmt.Printf("cfg: %+v\n", cfg)
+
+	return cfg
+}
+
+// parseAPIKeys reads "key:class" pairs separated by commas, e.g.
+// "k1:internal,k2:partner".
+func parseAPIKeys(s string) (map[string]loadshed.Class, error) {
+
+	keys := map[string]loadshed.Class{}
+
+	for _, pair := range strings.Split(s, ",") {
+		key, name, ok := strings.Cut(strings.TrimSpace(pair), ":")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid api key entry %q", pair)
+		}
+
+		class, err := loadshed.ParseClass(name)
+		if err != nil {
+			return nil, err
+		}
+
+		keys[key] = class
+	}
+
+	return keys, nil
+}
+
+// parseSLAMethods reads "method:duration" pairs separated by commas, e.g.
+// "express:24h,standard:72h".
+func parseSLAMethods(s string) (map[string]time.Duration, error) {
+
+	methods := map[string]time.Duration{}
+
+	for _, pair := range strings.Split(s, ",") {
+		method, target, ok := strings.Cut(strings.TrimSpace(pair), ":")
+		if !ok || method == "" {
+			return nil, fmt.Errorf("invalid sla entry %q", pair)
+		}
+
+		d, err := time.ParseDuration(target)
+		if err != nil || d < 0 {
+			return nil, fmt.Errorf("invalid sla for %q: %q", method, target)
+		}
+
+		methods[method] = d
+	}
+
+	return methods, nil
+}
+
+// parseCarrierSecrets reads "carrier:secret" pairs separated by commas,
+// e.g. "ups:s1,dhl:s2".
+func parseCarrierSecrets(s string) (map[string]string, error) {
+
+	secrets := map[string]string{}
+
+	for _, pair := range strings.Split(s, ",") {
+		name, secret, ok := strings.Cut(strings.TrimSpace(pair), ":")
+		if !ok || secret == "" {
+			return nil, fmt.Errorf("invalid carrier secret entry for %q", name)
+		}
+
+		if _, err := carrier.New(name, secret); err != nil {
+			return nil, err
+		}
+
+		secrets[name] = secret
+	}
+
+	return secrets, nil
 }
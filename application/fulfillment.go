@@ -0,0 +1,122 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/i101dev/microservices-NN/events"
+	"github.com/i101dev/microservices-NN/fulfillment"
+	"github.com/i101dev/microservices-NN/jobs"
+	"github.com/i101dev/microservices-NN/logging"
+	"github.com/i101dev/microservices-NN/model"
+	"github.com/i101dev/microservices-NN/repository/order"
+	"github.com/i101dev/microservices-NN/tenant"
+	"github.com/i101dev/microservices-NN/tracecontext"
+)
+
+const assignmentJob = "warehouse-assignment"
+
+type assignmentPayload struct {
+	OrderID uint64 `json:"order_id"`
+}
+
+// loadFulfillment reads the fulfillment rules, registers the assignment
+// job and returns the interceptor that queues one for every order that
+// is written ready to be sent and not assigned yet.
+func (a *App) loadFulfillment() (order.Interceptor, error) {
+
+	cfg, err := fulfillment.Load(a.config.FulfillmentRules)
+	if err != nil {
+		return nil, err
+	}
+
+	a.fulfillment = &fulfillment.Assigner{
+		Config: cfg,
+	}
+
+	if a.config.StockURL != "" {
+		a.fulfillment.Stock = &fulfillment.HTTPStock{
+			URL: a.config.StockURL,
+			Client: &http.Client{
+				Timeout:   a.config.StockTimeout,
+				Transport: &tracecontext.Transport{Base: a.outbound(false)},
+			},
+		}
+	}
+
+	a.runner.Register(assignmentJob, a.assignWarehouses)
+
+	return func(ctx context.Context, call *order.Call, next order.Handler) error {
+
+		if err := next(ctx, call); err != nil {
+			return err
+		}
+
+		switch call.Op {
+		case order.OpInsert:
+			a.scheduleAssignment(ctx, call.Order)
+		case order.OpInsertAll:
+			for _, o := range call.Orders {
+				a.scheduleAssignment(ctx, o)
+			}
+		case order.OpApply:
+			for _, o := range call.Batch.Insert {
+				a.scheduleAssignment(ctx, o)
+			}
+			// Merged and split orders lose their assignments, so their
+			// updates are routed again too. Other updates keep theirs.
+			if !call.Batch.Reshapes() {
+				break
+			}
+			for _, o := range call.Batch.Update {
+				a.scheduleAssignment(ctx, o)
+			}
+		}
+
+		return nil
+	}, nil
+}
+
+func (a *App) scheduleAssignment(ctx context.Context, o model.Order) {
+
+	if o.Assignable() != nil {
+		return
+	}
+
+	if _, err := a.runner.Enqueue(ctx, assignmentJob, assignmentPayload{OrderID: o.OrderID}); err != nil {
+		logging.Logger(logging.Events).ErrorContext(ctx, "failed to queue warehouse assignment", "order_id", o.OrderID, "error", err)
+	}
+}
+
+// assignWarehouses routes the order and announces where it goes. Orders
+// that were deleted, assigned already or are no longer to be sent are
+// skipped; orders no rule matches are given up on.
+func (a *App) assignWarehouses(ctx context.Context, job jobs.Job) error {
+
+	var payload assignmentPayload
+	if err := job.Decode(&payload); err != nil {
+		return jobs.Permanent(fmt.Errorf("failed to decode assignment: %w", err))
+	}
+
+	o, err := a.orders.AssignWarehouses(ctx, payload.OrderID)
+	switch {
+	case errors.Is(err, order.ErrNotExist), errors.Is(err, model.ErrAlreadyAssigned), errors.Is(err, model.ErrInvalidTransition):
+		return nil
+	case errors.Is(err, fulfillment.ErrUnroutable):
+		return jobs.Permanent(fmt.Errorf("failed to assign order %d: %w", payload.OrderID, err))
+	case err != nil:
+		return err
+	}
+
+	if a.events == nil {
+		return nil
+	}
+
+	if _, err := a.events.Publish(ctx, events.NewOrderAssigned(tenant.FromContext(ctx), o, a.clock.Now())); err != nil {
+		fmt.Println("failed to publish order assigned:", err)
+	}
+
+	return nil
+}
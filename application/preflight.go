@@ -0,0 +1,52 @@
+package application
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/i101dev/microservices-NN/preflight"
+)
+
+// minRedisVersion is the first release with XAUTOCLAIM, which the job and
+// create queues use to take over stalled work.
+const minRedisVersion = "6.2.0"
+
+// preflight checks the dependencies before the server starts, so a bad
+// setup fails at boot with a hint at the fix instead of on the first
+// request. Migration state is checked afterwards by migrate.
+func (a *App) preflight(ctx context.Context) error {
+
+	checks := []preflight.Check{
+		preflight.Ping("redis", a.rdb, "REDIS_ADDR"),
+		preflight.MinVersion("redis", a.rdb, minRedisVersion),
+		preflight.NoEviction(a.rdb),
+	}
+
+	if a.config.TimeseriesEnabled {
+		checks = append(checks, preflight.Module(a.rdb, "timeseries", "analytics timeseries will be kept in memory on each replica"))
+	}
+
+	if a.shadowDB != nil {
+		checks = append(checks,
+			preflight.Ping("shadow redis", a.shadowDB, "SHADOW_REDIS_ADDRESS"),
+			preflight.MinVersion("shadow redis", a.shadowDB, minRedisVersion),
+		)
+	}
+
+	results, err := preflight.Run(ctx, 3*time.Second, checks...)
+
+	for _, r := range results {
+		if r.Error != "" && !r.Fatal {
+			fmt.Printf("preflight warning: %s: %s\n", r.Name, r.Error)
+		}
+	}
+
+	if err != nil {
+		return fmt.Errorf("preflight failed: %w", err)
+	}
+
+	fmt.Printf("preflight passed %d checks\n", len(results))
+
+	return nil
+}
@@ -0,0 +1,90 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/i101dev/microservices-NN/events"
+	"github.com/i101dev/microservices-NN/jobs"
+	"github.com/i101dev/microservices-NN/logging"
+	"github.com/i101dev/microservices-NN/model"
+	"github.com/i101dev/microservices-NN/repository/order"
+	"github.com/i101dev/microservices-NN/tenant"
+)
+
+const reminderJob = "payment-reminder"
+
+type reminderPayload struct {
+	OrderID uint64 `json:"order_id"`
+}
+
+// loadReminders registers the reminder job and returns the interceptor
+// that schedules one in the delayed queue for every new order, due
+// ReminderLeadTime ahead of its PendingOrderTTL expiry.
+func (a *App) loadReminders() order.Interceptor {
+
+	a.runner.Register(reminderJob, a.sendReminder)
+
+	return func(ctx context.Context, call *order.Call, next order.Handler) error {
+
+		if err := next(ctx, call); err != nil {
+			return err
+		}
+
+		switch call.Op {
+		case order.OpInsert:
+			a.scheduleReminder(ctx, call.Order)
+		case order.OpInsertAll:
+			for _, o := range call.Orders {
+				a.scheduleReminder(ctx, o)
+			}
+		case order.OpApply:
+			for _, o := range call.Batch.Insert {
+				a.scheduleReminder(ctx, o)
+			}
+		}
+
+		return nil
+	}
+}
+
+func (a *App) scheduleReminder(ctx context.Context, o model.Order) {
+
+	if o.CreatedAt == nil {
+		return
+	}
+
+	at := o.CreatedAt.Add(a.config.PendingOrderTTL - a.config.ReminderLeadTime)
+
+	if _, err := a.runner.EnqueueAt(ctx, reminderJob, reminderPayload{OrderID: o.OrderID}, at); err != nil {
+		logging.Logger(logging.Events).ErrorContext(ctx, "failed to schedule payment reminder", "order_id", o.OrderID, "error", err)
+	}
+}
+
+// sendReminder publishes the reminder if the order is still waiting for
+// payment. Orders that moved on or were deleted are skipped.
+func (a *App) sendReminder(ctx context.Context, job jobs.Job) error {
+
+	var payload reminderPayload
+	if err := job.Decode(&payload); err != nil {
+		return jobs.Permanent(fmt.Errorf("failed to decode reminder: %w", err))
+	}
+
+	o, err := a.orders.Get(ctx, payload.OrderID)
+	if errors.Is(err, order.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	if o.Status() != model.StatusPending || o.CreatedAt == nil {
+		return nil
+	}
+
+	expiresAt := o.CreatedAt.Add(a.config.PendingOrderTTL)
+
+	_, err = a.events.Publish(ctx, events.NewPaymentReminder(tenant.FromContext(ctx), o, expiresAt, a.clock.Now()))
+
+	return err
+}
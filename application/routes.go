@@ -1,25 +1,931 @@
 package application
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
 	"net/http"
+	"time"
 
+	"github.com/99designs/gqlgen/graphql"
+	gqlhandler "github.com/99designs/gqlgen/graphql/handler"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
+	"github.com/i101dev/microservices-NN/analytics"
+	"github.com/i101dev/microservices-NN/audit"
+	"github.com/i101dev/microservices-NN/auth"
+	"github.com/i101dev/microservices-NN/cancellation"
+	"github.com/i101dev/microservices-NN/carrier"
+	"github.com/i101dev/microservices-NN/catalog"
+	"github.com/i101dev/microservices-NN/changefeed"
+	"github.com/i101dev/microservices-NN/chaos"
+	"github.com/i101dev/microservices-NN/codec"
+	"github.com/i101dev/microservices-NN/comment"
+	"github.com/i101dev/microservices-NN/dedup"
+	"github.com/i101dev/microservices-NN/dupcheck"
+	"github.com/i101dev/microservices-NN/erasure"
+	"github.com/i101dev/microservices-NN/errreport"
+	"github.com/i101dev/microservices-NN/eta"
+	"github.com/i101dev/microservices-NN/events"
+	"github.com/i101dev/microservices-NN/fraud"
+	"github.com/i101dev/microservices-NN/giftcard"
+	"github.com/i101dev/microservices-NN/graph"
 	"github.com/i101dev/microservices-NN/handler"
+	"github.com/i101dev/microservices-NN/inventory"
+	"github.com/i101dev/microservices-NN/invoice"
+	"github.com/i101dev/microservices-NN/loadshed"
+	"github.com/i101dev/microservices-NN/logging"
+	"github.com/i101dev/microservices-NN/maintenance"
+	"github.com/i101dev/microservices-NN/metrics"
+	"github.com/i101dev/microservices-NN/openapi"
+	"github.com/i101dev/microservices-NN/orderindex"
+	"github.com/i101dev/microservices-NN/orderquery"
+	"github.com/i101dev/microservices-NN/org"
+	"github.com/i101dev/microservices-NN/payment"
+	"github.com/i101dev/microservices-NN/pickup"
+	"github.com/i101dev/microservices-NN/quota"
+	"github.com/i101dev/microservices-NN/quote"
+	"github.com/i101dev/microservices-NN/readmodel"
+	"github.com/i101dev/microservices-NN/replay"
 	"github.com/i101dev/microservices-NN/repository/order"
+	"github.com/i101dev/microservices-NN/respcache"
+	"github.com/i101dev/microservices-NN/retention"
+	"github.com/i101dev/microservices-NN/service"
+	"github.com/i101dev/microservices-NN/storage"
+	"github.com/i101dev/microservices-NN/subscription"
+	"github.com/i101dev/microservices-NN/tax"
+	"github.com/i101dev/microservices-NN/tenant"
+	"github.com/i101dev/microservices-NN/timeline"
+	"github.com/i101dev/microservices-NN/tracecontext"
+	"github.com/i101dev/microservices-NN/track"
+	"github.com/i101dev/microservices-NN/transport"
+	"github.com/i101dev/microservices-NN/warehouse"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/vektah/gqlparser/v2/gqlerror"
 )
 
 func (a *App) loadRoutes() {
 
 	router := chi.NewRouter()
 
-	router.Use(middleware.Logger)
+	router.Use(middleware.RequestID)
+	router.Use(tracecontext.Middleware)
+	router.Use(logging.Requests)
 
-	router.Get("/", func(w http.ResponseWriter, r *http.Request) {
-		w.WriteHeader(http.StatusOK)
+	if a.reporter != nil {
+		router.Use(errreport.Middleware(a.reporter))
+	}
+
+	router.Use(a.crashes.Middleware)
+	router.Use(limitBody(a.config.MaxBodyBytes))
+	router.Use(tenant.Middleware)
+
+	if a.config.TrustAuthHeaders {
+		router.Use(auth.TrustedHeaders)
+	}
+
+	var tokens *auth.Tokens
+
+	if a.config.TokenSecret != "" {
+		tokens = &auth.Tokens{
+			Key:    []byte(a.config.TokenSecret),
+			MaxTTL: a.config.TokenMaxTTL,
+			Clock:  a.clock,
+		}
+
+		router.Use(tokens.Middleware)
+	}
+
+	var auditLog *audit.Log
+
+	if a.rdb != nil && a.config.AuditEnabled {
+		auditLog = &audit.Log{
+			Client: a.rdb,
+			Now:    a.clock.Now,
+		}
+
+		router.Use(auditLog.Middleware)
+	}
+
+	if len(a.config.APIKeys) > 0 {
+		router.Use(loadshed.Classify(a.config.APIKeys))
+	}
+
+	if a.rdb != nil && a.config.ReplayWindow > 0 {
+		guard := &replay.Guard{
+			Client: a.rdb,
+			Window: a.config.ReplayWindow,
+			Now:    a.clock.Now,
+		}
+
+		router.Use(guard.Middleware)
+	}
+
+	if a.rdb != nil && a.config.QuotasEnabled {
+		a.quotas = &quota.Store{
+			Client: a.rdb,
+			Now:    a.clock.Now,
+		}
+
+		router.Use(a.quotas.Middleware)
+	}
+
+	var unknownFields *prometheus.CounterVec
+
+	if a.config.MetricsEnabled {
+		a.metrics = metrics.NewRegistry()
+		a.crashes.Panics = metrics.NewPanicCounter(a.metrics)
+		unknownFields = metrics.NewUnknownFieldsCounter(a.metrics)
+	}
+
+	router.Use(handler.UnknownFields(a.config.UnknownFields, unknownFields))
+
+	// Middlewares must all be in place before the first route. Every
+	// /admin route goes on adminRouter, which takes the admin scope.
+	adminRouter := chi.NewRouter()
+	adminRouter.Use(handler.RequireAdmin)
+	router.Mount("/admin", adminRouter)
+
+	if auditLog != nil {
+		auditHandler := &handler.Audit{
+			Log: auditLog,
+		}
+
+		adminRouter.Get("/audit", auditHandler.Entries)
+		adminRouter.Get("/impersonations", auditHandler.Sessions)
+	}
+
+	if a.quotas != nil {
+		quotasHandler := &handler.Quotas{
+			Store: a.quotas,
+		}
+
+		adminRouter.Get("/quotas", quotasHandler.List)
+		adminRouter.Put("/quotas", quotasHandler.Set)
+		adminRouter.Get("/quotas/{id}", quotasHandler.Get)
+		adminRouter.Delete("/quotas/{id}", quotasHandler.Delete)
+	}
+
+	if a.slowlog != nil {
+		adminRouter.Get("/slowlog", a.slowlog.ServeRecent)
+	}
+
+	if a.metrics != nil {
+		router.Handle("/metrics", metrics.Handler(a.metrics))
+	}
+
+	if a.config.MaxInFlight > 0 {
+		a.shedder = loadshed.New(a.config.MaxInFlight, a.config.MaxQueue, a.config.QueueTimeout)
+		adminRouter.Get("/loadshed", a.shedder.ServeStats)
+	}
+
+	spec, err := openapi.Load(context.Background())
+	if err != nil {
+		panic(err)
+	}
+
+	router.Get("/openapi.json", spec.ServeJSON)
+	router.Get("/docs", spec.ServeDocs)
+
+	if a.pool != nil {
+		adminRouter.Get("/pool", a.pool.ServeStats)
+	}
+
+	if a.runner != nil {
+		jobsHandler := &handler.Jobs{
+			Runner: a.runner,
+		}
+
+		adminRouter.Get("/jobs", a.runner.ServeStats)
+		adminRouter.Get("/jobs/dead", jobsHandler.ListDead)
+		adminRouter.Post("/jobs/dead/requeue", jobsHandler.Requeue)
+		adminRouter.Delete("/jobs/dead", jobsHandler.Purge)
+		adminRouter.Get("/jobs/dead/{id}", jobsHandler.GetDead)
+		adminRouter.Post("/jobs/dead/{id}/requeue", jobsHandler.Requeue)
+		adminRouter.Delete("/jobs/dead/{id}", jobsHandler.Purge)
+	}
+
+	if a.rdb != nil {
+		adminRouter.Get("/stats", a.serveStats)
+	}
+
+	if a.store != nil {
+		adminHandler := &handler.Admin{
+			Store: a.store,
+		}
+
+		adminRouter.Get("/orders/{id}/raw", adminHandler.RawOrder)
+	}
+
+	adminRouter.Get("/events/schemas", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(events.Default.Schemas())
 	})
 
-	router.Route("/orders", a.loadOrderRoutes)
+	reads := dedup.New(a.repo)
+	a.repo = reads
+
+	adminRouter.Get("/dedup", reads.ServeStats)
+
+	if a.rdb != nil {
+		a.events = &events.Publisher{
+			Client: a.rdb,
+			MaxLen: 100_000,
+			Log:    logging.Logger(logging.Events),
+		}
+	}
+
+	if a.events != nil && a.config.WarehouseBucket != "" {
+		a.warehouse = &warehouse.Exporter{
+			Client: a.rdb,
+			Stream: events.DefaultStream,
+			Prefix: a.config.WarehousePrefix,
+			Store: &storage.S3{
+				Endpoint:     a.config.WarehouseEndpoint,
+				Region:       a.config.WarehouseRegion,
+				Bucket:       a.config.WarehouseBucket,
+				AccessKeyID:  a.config.WarehouseKeyID,
+				SecretKey:    a.config.WarehouseSecret,
+				SessionToken: a.config.WarehouseToken,
+				Client: &http.Client{
+					Timeout:   time.Minute,
+					Transport: a.outbound(false),
+				},
+			},
+		}
+	}
+
+	a.readOnly = &maintenance.Mode{
+		Client:   a.rdb,
+		Interval: time.Second,
+		Forced:   a.config.MaintenanceMode,
+	}
+
+	adminRouter.Get("/loglevel", logging.ServeLevels)
+	adminRouter.Put("/loglevel", logging.ServeLevels)
+
+	adminRouter.Get("/maintenance", a.readOnly.ServeState)
+	adminRouter.Put("/maintenance", a.readOnly.ServeState)
+
+	// Background writers wait out maintenance instead of failing against
+	// the read-only repository.
+	if a.runner != nil {
+		a.runner.Paused = a.readOnly.Enabled
+	}
+
+	if a.queue != nil {
+		a.queue.Paused = a.readOnly.Enabled
+	}
+
+	interceptors := []order.Interceptor{
+		a.readOnly.Intercept,
+		order.Log(logging.Logger(logging.Repository), a.config.SlowRepoThreshold),
+	}
+
+	if a.quotas != nil {
+		interceptors = append(interceptors, a.quotas.Intercept)
+	}
+
+	var faults *chaos.Controller
+
+	if a.config.ChaosEnabled {
+		faults = chaos.NewController()
+		interceptors = append(interceptors, faults.Intercept)
+
+		adminRouter.Get("/chaos", faults.ServeSettings)
+		adminRouter.Put("/chaos", faults.ServeSettings)
+	}
+
+	if a.runner != nil && a.config.ReminderLeadTime > 0 {
+		if a.config.ReminderLeadTime >= a.config.PendingOrderTTL {
+			fmt.Println("payment reminders need PAYMENT_REMINDER_BEFORE to be shorter than PENDING_ORDER_TTL, not sending any")
+		} else {
+			interceptors = append(interceptors, a.loadReminders())
+		}
+	}
+
+	var tracking *carrier.Index
+
+	if a.rdb != nil {
+		tracking = &carrier.Index{
+			Client: a.rdb,
+		}
+
+		interceptors = append(interceptors, tracking.Intercept)
+	}
+
+	var index *orderindex.Index
+
+	if a.rdb != nil {
+		index = &orderindex.Index{
+			Client: a.rdb,
+		}
+
+		interceptors = append(interceptors, index.Intercept)
+	}
+
+	if a.rdb != nil && a.config.InventoryURL != "" {
+		interceptors = append(interceptors, a.loadBackorders())
+	}
+
+	if a.runner != nil && a.config.FulfillmentRules != "" {
+		if assignments, err := a.loadFulfillment(); err != nil {
+			fmt.Println("not assigning orders to warehouses:", err)
+		} else {
+			interceptors = append(interceptors, assignments)
+		}
+	}
+
+	if a.rdb != nil && a.config.SinkProject != "" {
+		if err := a.loadSink(); err != nil {
+			fmt.Println("not streaming orders to bigquery:", err)
+		}
+	}
+
+	if a.rdb != nil && a.config.SearchURL != "" {
+		a.loadSearch()
+	}
+
+	if a.rdb != nil && a.config.ReadModelEnabled {
+		a.readModel = &readmodel.Builder{
+			Client: a.rdb,
+			Now:    a.clock.Now,
+		}
+	}
+
+	// The sink, the search index and the read model follow the changefeed.
+	if a.sink != nil || a.projector != nil || a.readModel != nil {
+		feed := &changefeed.Feed{
+			Client: a.rdb,
+		}
+
+		interceptors = append(interceptors, feed.Intercept)
+	}
+
+	if a.reporter != nil {
+		interceptors = append(interceptors, errreport.Corruption(a.reporter))
+	}
+
+	if a.shadow != nil {
+		interceptors = append(interceptors, a.shadow.Intercept)
+
+		adminRouter.Get("/shadow", a.shadow.ServeStats)
+	}
+
+	// Repository metrics go last so they time the store itself, not the
+	// interceptors in front of it.
+	if a.metrics != nil {
+		var c codec.Codec = codec.JSON
+		if a.store != nil && a.store.Codec != nil {
+			c = a.store.Codec
+		}
+
+		interceptors = append(interceptors, metrics.NewRepository(a.metrics, c).Intercept)
+	}
+
+	a.repo = order.Intercept(a.repo, interceptors...)
+
+	var stats *analytics.Store
+	var series analytics.Series
+
+	if a.rdb != nil {
+		stats = &analytics.Store{
+			Client: a.rdb,
+			Clock:  a.clock,
+		}
+
+		if a.config.TimeseriesEnabled {
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			series = analytics.NewSeries(ctx, a.rdb, 7*24*time.Hour, 24*time.Hour)
+			cancel()
+
+			fmt.Println("recording analytics timeseries in", series.Backend())
+		}
+
+		a.repo = &analytics.Repository{
+			Repository: a.repo,
+			Store:      stats,
+			Series:     series,
+		}
+	}
+
+	if a.config.CacheEnabled {
+		a.cache = respcache.New(a.config.CacheSize, a.config.CacheTTL)
+		a.repo = &respcache.Repository{
+			Repository: a.repo,
+			Cache:      a.cache,
+		}
+
+		adminRouter.Get("/cache", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(a.cache.Stats())
+		})
+	}
+
+	// Queued creates are written by the queue workers, so they need the
+	// same decorated repository as direct writes.
+	if a.queue != nil {
+		a.queue.Repo = a.repo
+	}
+
+	if a.sink != nil {
+		a.sink.Repo = a.repo
+	}
+
+	if a.projector != nil {
+		a.projector.Repo = a.repo
+	}
+
+	queries := &handler.Admin{
+		Queries: &orderquery.Runner{
+			Repo:  a.repo,
+			Index: index,
+		},
+	}
+
+	adminRouter.Get("/orders", queries.Orders)
+
+	if a.readModel != nil {
+		a.readModel.Repo = a.repo
+
+		a.views = &readmodel.Views{
+			Client: a.rdb,
+		}
+
+		viewsHandler := &handler.Views{
+			Store:   a.views,
+			Builder: a.readModel,
+			Repo:    a.repo,
+		}
+
+		adminRouter.Get("/views", viewsHandler.Status)
+		adminRouter.Post("/views/rebuild", viewsHandler.Rebuild)
+	}
+
+	if a.rdb != nil && a.config.OrgsEnabled {
+		a.orgs = &org.Store{
+			Client: a.rdb,
+			Now:    a.clock.Now,
+		}
+
+		orgsHandler := &handler.Orgs{
+			Store: a.orgs,
+		}
+
+		adminRouter.Post("/orgs", orgsHandler.Create)
+		adminRouter.Get("/orgs/{id}", orgsHandler.Get)
+		adminRouter.Put("/orgs/{id}/members/{subject}", orgsHandler.SetMember)
+		adminRouter.Delete("/orgs/{id}/members/{subject}", orgsHandler.RemoveMember)
+	}
+
+	if tokens != nil {
+		tokensHandler := &handler.Tokens{
+			Repo:   a.repo,
+			Tokens: tokens,
+		}
+
+		adminRouter.Post("/orders/{id}/tokens", tokensHandler.Mint)
+	}
+
+	a.orders = &service.Orders{
+		Repo:  a.repo,
+		Clock: a.clock,
+		Index: index,
+		Fraud: fraud.AllowAll{},
+	}
+
+	if a.config.FraudCheckURL != "" {
+		a.orders.Fraud = &fraud.HTTPChecker{
+			URL: a.config.FraudCheckURL,
+			Client: &http.Client{
+				Timeout:   a.config.FraudCheckTimeout,
+				Transport: &tracecontext.Transport{Base: a.outbound(a.config.FraudCheckH2C)},
+			},
+		}
+	}
+
+	if a.config.DeliveryRulesFile != "" {
+		rules, err := eta.Load(a.config.DeliveryRulesFile)
+		if err != nil {
+			fmt.Println("not estimating deliveries:", err)
+		} else {
+			a.orders.ETA = rules
+		}
+	}
+
+	if a.config.CancelPolicyFile != "" {
+		policy, err := cancellation.Load(a.config.CancelPolicyFile)
+		if err != nil {
+			fmt.Println("not applying cancellation policy:", err)
+		} else {
+			a.orders.Cancellation = policy
+		}
+	}
+
+	if a.config.CatalogURL != "" {
+		a.orders.Catalog = &catalog.HTTPCatalog{
+			URL: a.config.CatalogURL,
+			Client: &http.Client{
+				Timeout:   a.config.CatalogTimeout,
+				Transport: &tracecontext.Transport{Base: a.outbound(false)},
+			},
+		}
+	}
+
+	if a.backorders != nil {
+		a.orders.Inventory = &inventory.HTTPReserver{
+			URL: a.config.InventoryURL,
+			Client: &http.Client{
+				Timeout:   a.config.InventoryTimeout,
+				Transport: &tracecontext.Transport{Base: a.outbound(false)},
+			},
+		}
+	}
+
+	// A tax provider takes precedence over the flat rate.
+	switch {
+	case a.config.TaxURL != "":
+		a.orders.Tax = &tax.HTTPCalculator{
+			URL: a.config.TaxURL,
+			Client: &http.Client{
+				Timeout:   a.config.TaxTimeout,
+				Transport: &tracecontext.Transport{Base: a.outbound(false)},
+			},
+		}
+	case a.config.TaxRate > 0:
+		a.orders.Tax = tax.FlatRate{
+			Rate:         a.config.TaxRate,
+			Jurisdiction: a.config.TaxJurisdiction,
+		}
+	}
+
+	if a.rdb != nil && a.config.DuplicateWindow > 0 {
+		a.orders.Duplicates = &dupcheck.Detector{
+			Client: a.rdb,
+			Window: a.config.DuplicateWindow,
+			Reject: a.config.RejectDuplicates,
+		}
+	}
+
+	// Attachments are kept with the same AWS credentials as the warehouse
+	// exports.
+	if a.config.AttachBucket != "" {
+		a.orders.Blobs = &storage.S3{
+			Endpoint:     a.config.AttachEndpoint,
+			Region:       a.config.AttachRegion,
+			Bucket:       a.config.AttachBucket,
+			AccessKeyID:  a.config.WarehouseKeyID,
+			SecretKey:    a.config.WarehouseSecret,
+			SessionToken: a.config.WarehouseToken,
+			Client: &http.Client{
+				Timeout:   10 * time.Second,
+				Transport: a.outbound(false),
+			},
+			Now: a.clock.Now,
+		}
+	}
+
+	a.orders.Fulfillment = a.fulfillment
+
+	if a.config.SLAShipWithin > 0 || len(a.config.SLAMethods) > 0 {
+		a.orders.SLA = &service.SLAPolicy{
+			ShipWithin: a.config.SLAShipWithin,
+			Methods:    a.config.SLAMethods,
+		}
+	}
+
+	var giftCards *handler.GiftCards
+
+	if a.rdb != nil {
+		a.orders.Balances = &giftcard.Balances{
+			Client: a.rdb,
+		}
+
+		giftCards = &handler.GiftCards{
+			Orders:   a.orders,
+			Balances: a.orders.Balances,
+		}
+
+		adminRouter.Post("/gift-cards", giftCards.Issue)
+		adminRouter.Get("/gift-cards/{code}", giftCards.Get)
+	}
+
+	var quotes *handler.Quotes
+
+	if a.rdb != nil {
+		a.orders.Quotes = &quote.Store{
+			Client: a.rdb,
+			Keep:   30 * 24 * time.Hour,
+		}
+
+		quotes = &handler.Quotes{
+			Orders: a.orders,
+			TTL:    a.config.QuoteTTL,
+		}
+	}
+
+	var filters *handler.Filters
+
+	if a.rdb != nil {
+		a.filters = &orderindex.Filters{
+			Client: a.rdb,
+		}
+
+		filters = &handler.Filters{
+			Store: a.filters,
+			Clock: a.clock,
+		}
+	}
+
+	var subscriptions *handler.Subscriptions
+
+	if a.rdb != nil {
+		a.subscriptions = &subscription.Store{
+			Client: a.rdb,
+		}
+
+		subscriptions = &handler.Subscriptions{
+			Store: a.subscriptions,
+			Clock: a.clock,
+		}
+	}
+
+	if a.subscriptions != nil && a.config.ChargeURL != "" {
+		a.charger = &subscription.HTTPCharger{
+			URL: a.config.ChargeURL,
+			Client: &http.Client{
+				Timeout:   a.config.ChargeTimeout,
+				Transport: &tracecontext.Transport{Base: a.outbound(false)},
+			},
+		}
+	}
+
+	if a.rdb != nil {
+		a.comments = &comment.Store{
+			Client: a.rdb,
+			Now:    a.clock.Now,
+		}
+	}
+
+	var archive *retention.Archive
+
+	if a.rdb != nil {
+		archive = &retention.Archive{
+			Client: a.rdb,
+		}
+	}
+
+	if archive != nil && len(a.config.Retention) > 0 {
+		a.retention = &retention.Enforcer{
+			Client:   a.rdb,
+			Repo:     a.repo,
+			Archive:  archive,
+			Policies: a.config.Retention,
+			Clock:    a.clock,
+		}
+
+		if a.comments != nil {
+			a.retention.OrderData = append(a.retention.OrderData, a.comments)
+		}
+
+		retentionHandler := &handler.Retention{
+			Enforcer: a.retention,
+		}
+
+		adminRouter.Get("/retention", retentionHandler.Report)
+		adminRouter.Post("/retention/run", retentionHandler.Run)
+	}
+
+	var customers *handler.Customer
+
+	if a.runner != nil {
+		eraser := &erasure.Eraser{
+			Client:    a.rdb,
+			Repo:      a.repo,
+			Runner:    a.runner,
+			Events:    a.events,
+			Retention: 30 * 24 * time.Hour,
+			Clock:     a.clock,
+		}
+
+		if stats != nil {
+			eraser.Indexes = append(eraser.Indexes, stats)
+		}
+
+		if archive != nil {
+			eraser.Indexes = append(eraser.Indexes, archive)
+		}
+
+		if a.comments != nil {
+			eraser.OrderData = append(eraser.OrderData, a.comments)
+		}
+
+		if a.orders.Balances != nil {
+			eraser.Indexes = append(eraser.Indexes, a.orders.Balances)
+		}
+
+		if a.subscriptions != nil {
+			eraser.Indexes = append(eraser.Indexes, a.subscriptions)
+		}
+
+		if auditLog != nil {
+			eraser.Indexes = append(eraser.Indexes, auditLog)
+		}
+
+		eraser.Register()
+
+		customers = &handler.Customer{
+			Erasure: eraser,
+		}
+	}
+
+	router.Group(func(router chi.Router) {
+
+		if faults != nil {
+			router.Use(faults.Middleware)
+		}
+
+		router.Group(func(router chi.Router) {
+
+			router.Use(a.readOnly.Middleware)
+			router.Use(spec.Validator(a.config.OpenAPIValidation))
+
+			router.With(a.shed(loadshed.PriorityCritical)).Get("/", func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			})
+
+			router.Route("/orders", a.loadOrderRoutes)
+
+			if customers != nil {
+				// Erasure deletes orders the caller may not own, organization
+				// orders included, so it is for admins only.
+				router.With(handler.RequireAdmin, a.shed(loadshed.PriorityHigh)).Delete("/customers/{id}/data", customers.EraseData)
+				router.With(handler.RequireAdmin, a.shed(loadshed.PriorityNormal)).Get("/customers/{id}/data/requests/{requestID}", customers.GetErasure)
+			}
+
+			if giftCards != nil {
+				router.With(a.shed(loadshed.PriorityNormal)).Get("/customers/{id}/credit", giftCards.Credit)
+			}
+
+			if quotes != nil {
+				router.With(a.shed(loadshed.PriorityHigh)).Post("/quotes", quotes.Create)
+				router.With(a.shed(loadshed.PriorityNormal)).Get("/quotes/{id}", quotes.Get)
+				router.With(a.shed(loadshed.PriorityHigh)).Post("/quotes/{id}/accept", quotes.Accept)
+				router.With(a.shed(loadshed.PriorityHigh)).Post("/quotes/{id}/convert", quotes.Convert)
+			}
+
+			if filters != nil {
+				high := a.shed(loadshed.PriorityHigh)
+				normal := a.shed(loadshed.PriorityNormal)
+
+				router.With(high).Post("/filters", filters.Create)
+				router.With(normal).Get("/filters", filters.List)
+				router.With(normal).Get("/filters/{id}", filters.Get)
+				router.With(high).Put("/filters/{id}", filters.Update)
+				router.With(high).Delete("/filters/{id}", filters.Delete)
+			}
+
+			if subscriptions != nil {
+				high := a.shed(loadshed.PriorityHigh)
+				normal := a.shed(loadshed.PriorityNormal)
+
+				router.With(high).Post("/subscriptions", subscriptions.Create)
+				router.With(normal).Get("/subscriptions/{id}", subscriptions.Get)
+				router.With(high).Post("/subscriptions/{id}/pause", subscriptions.Pause)
+				router.With(high).Post("/subscriptions/{id}/resume", subscriptions.Resume)
+				router.With(high).Post("/subscriptions/{id}/cancel", subscriptions.Cancel)
+				router.With(normal).Get("/customers/{id}/subscriptions", subscriptions.ForCustomer)
+			}
+
+			if stats != nil {
+				analyticsHandler := &handler.Analytics{
+					Store:  stats,
+					Series: series,
+					Clock:  a.clock,
+				}
+
+				low := a.shed(loadshed.PriorityLow)
+
+				router.With(low).Get("/analytics/orders", analyticsHandler.Orders)
+				router.With(low).Get("/analytics/top-customers", analyticsHandler.TopCustomers)
+				router.With(low).Get("/analytics/top-items", analyticsHandler.TopItems)
+
+				if series != nil {
+					router.With(low).Get("/analytics/timeseries", analyticsHandler.Timeseries)
+				}
+			}
+
+			if a.views != nil {
+				viewsHandler := &handler.Views{
+					Store: a.views,
+					Orgs:  a.orgs,
+				}
+
+				router.With(a.shed(loadshed.PriorityNormal)).Get("/customers/{id}/orders", viewsHandler.CustomerOrders)
+				router.With(a.shed(loadshed.PriorityNormal)).Get("/customers/{id}/summary", viewsHandler.CustomerSummary)
+				router.With(a.shed(loadshed.PriorityLow)).Get("/analytics/days", viewsHandler.Days)
+				router.With(a.shed(loadshed.PriorityLow)).Get("/analytics/days/{date}/orders", viewsHandler.DayOrders)
+			}
+
+			if a.orders.Index != nil {
+				timelineHandler := &handler.Timeline{
+					Builder: &timeline.Builder{
+						Orders:   a.orders,
+						History:  a.store,
+						Comments: a.comments,
+						Orgs:     a.orgs,
+					},
+				}
+
+				router.With(a.shed(loadshed.PriorityLow)).Get("/customers/{id}/timeline", timelineHandler.Customer)
+			}
+
+			if a.orgs != nil {
+				orgsHandler := &handler.Orgs{
+					Store:  a.orgs,
+					Orders: a.orders,
+				}
+
+				router.With(a.shed(loadshed.PriorityNormal)).Get("/orgs/{id}/orders", orgsHandler.ListOrders)
+			}
+
+			if a.fulfillment != nil && index != nil {
+				warehousesHandler := &handler.Warehouses{
+					Orders: a.orders,
+					Config: a.fulfillment.Config,
+					Orgs:   a.orgs,
+				}
+
+				router.With(a.shed(loadshed.PriorityNormal)).Get("/warehouses", warehousesHandler.List)
+				router.With(a.shed(loadshed.PriorityNormal)).Get("/warehouses/{id}/orders", warehousesHandler.ListOrders)
+				router.With(a.shed(loadshed.PriorityLow)).Get("/fulfillment/picklist", warehousesHandler.PickList)
+			}
+
+			if a.quotas != nil {
+				quotasHandler := &handler.Quotas{
+					Store: a.quotas,
+				}
+
+				router.With(a.shed(loadshed.PriorityNormal)).Get("/limits", quotasHandler.Limits)
+			}
+
+			if tracking != nil && len(a.config.CarrierSecrets) > 0 {
+				carrierHandler := &handler.Carrier{
+					Orders:   a.orders,
+					Index:    tracking,
+					Adapters: map[string]carrier.Adapter{},
+					Events:   a.events,
+					Clock:    a.clock,
+				}
+
+				for name, secret := range a.config.CarrierSecrets {
+					adapter, err := carrier.New(name, secret)
+					if err != nil {
+						fmt.Println("not accepting webhooks from carrier:", err)
+						continue
+					}
+					carrierHandler.Adapters[name] = adapter
+				}
+
+				router.With(a.shed(loadshed.PriorityHigh)).Post("/webhooks/carrier/{carrier}", carrierHandler.Webhook)
+			}
+
+			if a.rdb != nil && a.config.PaymentSecret != "" {
+				paymentsHandler := &handler.Payments{
+					Orders: a.orders,
+					Secret: []byte(a.config.PaymentSecret),
+					Dedup: &payment.Dedup{
+						Client: a.rdb,
+						TTL:    30 * 24 * time.Hour,
+					},
+					Events: a.events,
+					Clock:  a.clock,
+				}
+
+				router.With(a.shed(loadshed.PriorityHigh)).Post("/webhooks/payments", paymentsHandler.Webhook)
+			}
+
+			if a.config.TrackSecret != "" {
+				trackHandler := &handler.Track{
+					Repo:   a.repo,
+					Signer: &track.Signer{Key: []byte(a.config.TrackSecret)},
+				}
+
+				if a.rdb != nil {
+					trackHandler.Limiter = &track.Limiter{
+						Client: a.rdb,
+						Limit:  int64(a.config.TrackRateLimit),
+						Window: a.config.TrackRateWindow,
+						Clock:  a.clock,
+					}
+				}
+
+				router.With(a.shed(loadshed.PriorityLow)).Get("/track/{token}", trackHandler.Status)
+			}
+		})
+
+		router.With(a.shed(loadshed.PriorityNormal)).Handle("/graphql", a.graphQLHandler())
+	})
 
 	a.router = router
 }
@@ -27,14 +933,247 @@ func (a *App) loadRoutes() {
 func (a *App) loadOrderRoutes(router chi.Router) {
 
 	orderHandler := &handler.Order{
-		Repo: &order.RedisRepo{
+		Repo:    a.repo,
+		Orders:  a.orders,
+		Cache:   a.cache,
+		Queue:   a.queue,
+		Filters: a.filters,
+
+		SearchIndex: a.searchIndex,
+		Views:       a.views,
+		Orgs:        a.orgs,
+		Comments:    a.comments,
+		Quotas:      a.quotas,
+	}
+
+	high := a.shed(loadshed.PriorityHigh)
+	normal := a.shed(loadshed.PriorityNormal)
+	low := a.shed(loadshed.PriorityLow)
+
+	router.With(high).Post("/", orderHandler.Create)
+	router.With(high).Post("/bulk", orderHandler.CreateBulk)
+	router.With(normal).Get("/", orderHandler.List)
+	router.With(low).Get("/export", orderHandler.Export)
+	router.With(low).Get("/stream", orderHandler.Stream)
+	router.With(low).Get("/search", orderHandler.Search)
+
+	if a.queue != nil {
+		router.With(normal).Get("/requests/{requestID}", orderHandler.GetRequest)
+	}
+
+	router.With(normal).Get("/{id}", orderHandler.GetByID)
+	router.With(high).Put("/{id}", orderHandler.UpdateByID)
+	router.With(high).Post("/{id}/approve", orderHandler.Approve)
+	router.With(high).Post("/{id}/cancel", orderHandler.Cancel)
+	router.With(high).Post("/{id}/duplicate", orderHandler.Duplicate)
+	router.With(high).Post("/merge", orderHandler.Merge)
+	router.With(high).Post("/{id}/split", orderHandler.Split)
+	router.With(high).Post("/{id}/tags", orderHandler.AddTags)
+	router.With(high).Delete("/{id}/tags/{tag}", orderHandler.RemoveTag)
+
+	priorities := &handler.Priorities{
+		Orders: a.orders,
+		Events: a.events,
+		Clock:  a.clock,
+		Orgs:   a.orgs,
+	}
+
+	router.With(high).Post("/{id}/expedite", priorities.Expedite)
+
+	if a.comments != nil {
+		comments := &handler.Comments{
+			Repo:   a.repo,
+			Store:  a.comments,
+			Orgs:   a.orgs,
+			Events: a.events,
+		}
+
+		router.With(high).Post("/{id}/comments", comments.Create)
+		router.With(normal).Get("/{id}/comments", comments.List)
+	}
+
+	if a.orders.Blobs != nil {
+		attachments := &handler.Attachments{
+			Orders:  a.orders,
+			Blobs:   a.orders.Blobs,
+			Orgs:    a.orgs,
+			MaxSize: a.config.AttachMaxSize,
+			URLTTL:  a.config.AttachURLTTL,
+			Now:     a.clock.Now,
+		}
+
+		router.With(high).Post("/{id}/attachments", attachments.Create)
+		router.With(normal).Get("/{id}/attachments", attachments.List)
+		router.With(high).Post("/{id}/attachments/{attachmentID}/complete", attachments.Complete)
+		router.With(normal).Get("/{id}/attachments/{attachmentID}", attachments.Download)
+		router.With(high).Delete("/{id}/attachments/{attachmentID}", attachments.Delete)
+	}
+
+	disputes := &handler.Disputes{
+		Orders: a.orders,
+		Orgs:   a.orgs,
+	}
+
+	router.With(normal).Get("/{id}/disputes", disputes.List)
+	router.With(normal).Get("/{id}/disputes/{disputeID}", disputes.Get)
+	router.With(high).Post("/{id}/disputes/{disputeID}/evidence", disputes.AddEvidence)
+
+	if a.orders.Balances != nil {
+		giftCards := &handler.GiftCards{
+			Orders:   a.orders,
+			Balances: a.orders.Balances,
+			Orgs:     a.orgs,
+		}
+
+		router.With(high).Post("/{id}/gift-cards", giftCards.RedeemCard)
+		router.With(high).Post("/{id}/store-credit", giftCards.RedeemCredit)
+	}
+
+	if a.store != nil {
+		historyHandler := &handler.History{
+			Repo:  a.repo,
+			Store: a.store,
+			Orgs:  a.orgs,
+		}
+
+		router.With(normal).Get("/{id}/history", historyHandler.List)
+	}
+	router.With(high).Delete("/{id}", orderHandler.DeleteByID)
+
+	deliveryHandler := &handler.Delivery{
+		Repo:    a.repo,
+		MinDays: a.config.DeliveryMinDays,
+		MaxDays: a.config.DeliveryMaxDays,
+		Orgs:    a.orgs,
+	}
+
+	router.With(normal).Get("/{id}/delivery.ics", deliveryHandler.ICS)
+
+	if a.config.PickupSecret != "" {
+		pickupHandler := &handler.Pickup{
+			Repo: a.repo,
+			Signer: &pickup.Signer{
+				Key:   []byte(a.config.PickupSecret),
+				TTL:   a.config.PickupCodeTTL,
+				Clock: a.clock,
+			},
+			Orgs: a.orgs,
+		}
+
+		router.With(normal).Get("/{id}/qrcode.png", pickupHandler.QRCode)
+		router.With(high).Post("/verify-code", pickupHandler.Verify)
+	}
+
+	if a.config.TrackSecret != "" {
+		trackHandler := &handler.Track{
+			Repo:   a.repo,
+			Signer: &track.Signer{Key: []byte(a.config.TrackSecret)},
+			Orgs:   a.orgs,
+		}
+
+		router.With(normal).Get("/{id}/tracking-link", trackHandler.Link)
+	}
+
+	if a.rdb != nil {
+		generator := &invoice.Generator{
 			Client: a.rdb,
-		},
+			Repo:   a.repo,
+			Runner: a.runner,
+			Layout: invoice.Layout{
+				Seller:   a.config.InvoiceSeller,
+				Notes:    a.config.InvoiceNotes,
+				Currency: a.config.InvoiceCurrency,
+				TaxRate:  a.config.InvoiceTaxRate,
+			},
+			TTL:        24 * time.Hour,
+			AsyncItems: a.config.InvoiceAsyncItems,
+		}
+
+		if a.runner != nil {
+			generator.Register()
+		}
+
+		invoiceHandler := &handler.Invoice{
+			Repo:      a.repo,
+			Generator: generator,
+			Orgs:      a.orgs,
+		}
+
+		router.With(low).Get("/{id}/invoice.pdf", invoiceHandler.PDF)
 	}
+}
+
+func (a *App) shed(p loadshed.Priority) func(http.Handler) http.Handler {
 
-	router.Post("/", orderHandler.Create)
-	router.Get("/", orderHandler.List)
-	router.Get("/{id}", orderHandler.GetByID)
-	router.Put("/{id}", orderHandler.UpdateByID)
-	router.Delete("/{id}", orderHandler.DeleteByID)
+	return func(next http.Handler) http.Handler {
+
+		if a.shedder != nil {
+			next = a.shedder.Limit(p)(next)
+		}
+
+		if a.pool == nil || p > loadshed.PriorityLow {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if a.pool.Degraded() {
+				w.Header().Set("Retry-After", "5")
+				http.Error(w, "bulk endpoints are paused while redis is saturated", http.StatusServiceUnavailable)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func (a *App) graphQLHandler() http.Handler {
+
+	resolver := &graph.Resolver{
+		Repo:   a.repo,
+		Orders: a.orders,
+		Orgs:   a.orgs,
+	}
+
+	srv := gqlhandler.NewDefaultServer(graph.NewExecutableSchema(graph.Config{
+		Resolvers: resolver,
+	}))
+
+	// gqlgen recovers resolver panics itself; report them like any other.
+	srv.SetRecoverFunc(func(ctx context.Context, p any) error {
+		where := "graphql"
+		if graphql.HasOperationContext(ctx) {
+			where += " " + graphql.GetOperationContext(ctx).OperationName
+		}
+
+		a.crashes.Capture(ctx, p, where)
+		return gqlerror.Errorf("internal server error")
+	})
+
+	return srv
+}
+
+// outbound returns the transport for calls to other services: HTTP/2
+// without TLS when they accept it, pooled HTTP/1.1 otherwise.
+func (a *App) outbound(h2c bool) http.RoundTripper {
+
+	if h2c {
+		return transport.H2C(a.config.IdleTimeout)
+	}
+
+	return transport.Pooled(a.config.OutboundIdleConns, a.config.IdleTimeout)
+}
+
+func limitBody(n int64) func(http.Handler) http.Handler {
+
+	return func(next http.Handler) http.Handler {
+
+		if n <= 0 {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			r.Body = http.MaxBytesReader(w, r.Body, n)
+			next.ServeHTTP(w, r)
+		})
+	}
 }
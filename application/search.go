@@ -0,0 +1,29 @@
+package application
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/i101dev/microservices-NN/search"
+)
+
+// loadSearch sets up the OpenSearch index behind GET /orders/search and
+// the projector that keeps it up to date from the changefeed.
+func (a *App) loadSearch() {
+
+	a.searchIndex = &search.Index{
+		URL:      a.config.SearchURL,
+		Name:     a.config.SearchIndex,
+		Username: a.config.SearchUsername,
+		Password: a.config.SearchPassword,
+		Client: &http.Client{
+			Timeout:   10 * time.Second,
+			Transport: a.outbound(false),
+		},
+	}
+
+	a.projector = &search.Projector{
+		Client: a.rdb,
+		Index:  a.searchIndex,
+	}
+}
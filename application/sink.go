@@ -0,0 +1,52 @@
+package application
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/i101dev/microservices-NN/sink"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+)
+
+// loadSink sets up the connector that streams order mutations into
+// BigQuery from the changefeed. Requests are authorized with the
+// application default credentials; without any, only an emulator set with
+// SINK_BIGQUERY_ENDPOINT can be used.
+func (a *App) loadSink() error {
+
+	if a.config.SinkDataset == "" {
+		return fmt.Errorf("SINK_BIGQUERY_DATASET is not set")
+	}
+
+	client := &http.Client{
+		Timeout:   time.Minute,
+		Transport: a.outbound(false),
+	}
+
+	// The token source keeps the context to refresh tokens with.
+	tokens, err := google.DefaultTokenSource(context.Background(), sink.BigQueryScope)
+	if err != nil && a.config.SinkEndpoint == "" {
+		return fmt.Errorf("failed to find google credentials: %w", err)
+	} else if err == nil {
+		client.Transport = &oauth2.Transport{Source: tokens, Base: client.Transport}
+	}
+
+	a.sink = &sink.Connector{
+		Client: a.rdb,
+		Warehouse: &sink.BigQuery{
+			Endpoint: a.config.SinkEndpoint,
+			Project:  a.config.SinkProject,
+			Dataset:  a.config.SinkDataset,
+			Table:    a.config.SinkTable,
+			Client:   client,
+		},
+		BatchSize: a.config.SinkBatchSize,
+		Interval:  a.config.SinkFlush,
+		Now:       a.clock.Now,
+	}
+
+	return nil
+}
@@ -0,0 +1,119 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/i101dev/microservices-NN/events"
+	"github.com/i101dev/microservices-NN/model"
+	"github.com/i101dev/microservices-NN/service"
+	"github.com/i101dev/microservices-NN/subscription"
+	"github.com/i101dev/microservices-NN/tenant"
+)
+
+// placeDueSubscriptions places the order of every subscription that is
+// due. A subscription that fails is logged and left for its retry; it
+// never holds up the others.
+func (a *App) placeDueSubscriptions(ctx context.Context) error {
+
+	now := a.clock.Now().UTC()
+
+	ids, err := a.subscriptions.Due(ctx, now, 100)
+	if err != nil {
+		return err
+	}
+
+	var placed int
+
+	for _, id := range ids {
+		ok, err := a.renew(ctx, id)
+		if err != nil {
+			fmt.Printf("failed to renew subscription %s: %v\n", id, err)
+			continue
+		}
+		if ok {
+			placed++
+		}
+	}
+
+	if placed > 0 {
+		fmt.Printf("placed %d subscription orders\n", placed)
+	}
+
+	return nil
+}
+
+// renew places and pays for the next order of a subscription. An order
+// whose payment fails is cancelled and the run retried later. The
+// returned bool is true when an order was placed and paid for.
+func (a *App) renew(ctx context.Context, id string) (bool, error) {
+
+	s, err := a.subscriptions.Get(ctx, id)
+	if err != nil {
+		return false, err
+	}
+
+	now := a.clock.Now().UTC()
+
+	// The due index may be behind a pause or cancel.
+	if s.Status != subscription.StatusActive || s.NextRunAt == nil || s.NextRunAt.After(now) {
+		return false, a.subscriptions.Save(ctx, s)
+	}
+
+	ctx = tenant.NewContext(ctx, s.Tenant)
+
+	o, err := a.orders.CreateDraft(ctx, service.Draft{
+		CustomerID: s.CustomerID,
+		LineItems:  s.LineItems,
+		Shipping:   s.Shipping,
+	})
+	if err != nil {
+		s.Retry(err.Error(), a.config.SubscriptionRetry, now)
+		return false, errors.Join(err, a.subscriptions.Save(ctx, s))
+	}
+
+	if a.charger != nil {
+		reference, err := a.charger.Charge(ctx, s, o)
+		if err == nil {
+			_, err = a.orders.AuthorizePayment(ctx, o.OrderID, reference, o.Due())
+		}
+
+		if err != nil {
+			if _, err := a.orders.Transition(ctx, o.OrderID, model.StatusCancelled); err != nil {
+				fmt.Printf("failed to cancel unpaid subscription order %d: %v\n", o.OrderID, err)
+			}
+
+			s.PaymentFailed(err.Error(), a.config.SubscriptionRetry, a.config.MaxChargeFailures, now)
+			if err := a.subscriptions.Save(ctx, s); err != nil {
+				return false, err
+			}
+
+			a.publish(ctx, events.NewSubscriptionPaymentFailed(tenant.FromContext(ctx), s, o.OrderID, now))
+			return false, nil
+		}
+	}
+
+	if err := s.Placed(o.OrderID, now); err != nil {
+		return false, err
+	}
+
+	if err := a.subscriptions.Save(ctx, s); err != nil {
+		return false, err
+	}
+
+	a.publish(ctx, events.NewSubscriptionOrderPlaced(tenant.FromContext(ctx), s, o, now))
+
+	return true, nil
+}
+
+func (a *App) publish(ctx context.Context, e events.Event) {
+
+	if a.events == nil {
+		return
+	}
+
+	if _, err := a.events.Publish(ctx, e); err != nil {
+		fmt.Println("failed to publish event:", err)
+	}
+}
@@ -0,0 +1,351 @@
+package application
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/i101dev/microservices-NN/events"
+	"github.com/i101dev/microservices-NN/model"
+	"github.com/i101dev/microservices-NN/readmodel"
+	"github.com/i101dev/microservices-NN/repository/order"
+	"github.com/i101dev/microservices-NN/scheduler"
+	"github.com/i101dev/microservices-NN/tenant"
+)
+
+const statsKey = "stats:orders"
+
+func (a *App) loadTasks(base *order.RedisRepo) error {
+
+	a.scheduler = scheduler.New(a.rdb)
+
+	err := a.scheduler.Add("index-repair", "@hourly", a.unlessReadOnly(func(ctx context.Context) error {
+		added, removed, err := base.RebuildIndex(ctx)
+		if err == nil && added+removed > 0 {
+			fmt.Printf("index repair added %d, removed %d\n", added, removed)
+		}
+		return err
+	}))
+	if err != nil {
+		return err
+	}
+
+	// The read model keeps the totals itself.
+	if a.readModel == nil {
+		if err := a.scheduler.Add("order-stats", "@every 5m", a.aggregateStats); err != nil {
+			return err
+		}
+	}
+
+	if a.config.PendingOrderTTL > 0 {
+		if err := a.scheduler.Add("stale-order-expiry", "@every 5m", a.unlessReadOnly(a.expireStaleOrders)); err != nil {
+			return err
+		}
+	}
+
+	if a.orders.SLA != nil {
+		if err := a.scheduler.Add("sla-breaches", "@every 1m", a.unlessReadOnly(a.flagSLABreaches)); err != nil {
+			return err
+		}
+	}
+
+	// Disputes only come from the payment provider's webhooks.
+	if a.config.PaymentSecret != "" {
+		if err := a.scheduler.Add("dispute-deadlines", "@every 1m", a.unlessReadOnly(a.flagOverdueDisputes)); err != nil {
+			return err
+		}
+	}
+
+	if a.subscriptions != nil {
+		if err := a.scheduler.Add("subscription-orders", "@every 1m", a.unlessReadOnly(a.placeDueSubscriptions)); err != nil {
+			return err
+		}
+	}
+
+	// Exports only read the store, so they keep going in maintenance mode.
+	if a.warehouse != nil {
+		err := a.scheduler.Add("warehouse-export", "@every "+a.config.WarehouseFlush.String(), func(ctx context.Context) error {
+			n, err := a.warehouse.Flush(ctx)
+			if n > 0 {
+				fmt.Printf("exported %d events to the warehouse\n", n)
+			}
+			return err
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if a.retention != nil {
+		err := a.scheduler.Add("retention", "@daily", a.unlessReadOnly(func(ctx context.Context) error {
+			report, err := a.retention.Run(ctx, a.config.RetentionDryRun)
+			for _, p := range report.Policies {
+				if p.Matched > 0 {
+					fmt.Printf("retention %s %s orders after %s: matched %d, applied %d\n", p.Action, p.Status, p.After, p.Matched, p.Applied)
+				}
+			}
+			return err
+		}))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// unlessReadOnly skips runs of a writing task while maintenance mode is on.
+func (a *App) unlessReadOnly(fn func(ctx context.Context) error) func(ctx context.Context) error {
+
+	return func(ctx context.Context) error {
+
+		if a.readOnly.Enabled() {
+			fmt.Println("skipping task in maintenance mode")
+			return nil
+		}
+
+		return fn(ctx)
+	}
+}
+
+// expireStaleOrders cancels orders that have been pending for longer than
+// the configured TTL.
+func (a *App) expireStaleOrders(ctx context.Context) error {
+
+	cutoff := a.clock.Now().UTC().Add(-a.config.PendingOrderTTL)
+
+	var expired int
+
+	err := order.ForEachPage(ctx, a.repo, 100, func(orders []model.Order) error {
+
+		for _, o := range orders {
+			if o.Status() != model.StatusPending || o.CreatedAt == nil || o.CreatedAt.After(cutoff) {
+				continue
+			}
+
+			_, err := a.orders.Transition(ctx, o.OrderID, model.StatusCancelled)
+
+			// The order may have moved on or vanished since the page was read.
+			if errors.Is(err, model.ErrInvalidTransition) || errors.Is(err, order.ErrNotExist) {
+				continue
+			} else if err != nil {
+				return fmt.Errorf("failed to cancel order %d: %w", o.OrderID, err)
+			}
+			expired++
+		}
+
+		return nil
+	})
+
+	if expired > 0 {
+		fmt.Printf("expired %d stale orders\n", expired)
+	}
+
+	return err
+}
+
+// flagSLABreaches marks the orders that missed their SLA and publishes an
+// alert for each.
+func (a *App) flagSLABreaches(ctx context.Context) error {
+
+	now := a.clock.Now().UTC()
+
+	var breached int
+
+	err := order.ForEachPage(ctx, a.repo, 100, func(orders []model.Order) error {
+
+		for _, o := range orders {
+			if !o.SLAMissed(now) {
+				continue
+			}
+
+			flagged, err := a.orders.BreachSLA(ctx, o.OrderID)
+
+			// The order may have shipped, been cancelled or vanished since the
+			// page was read.
+			if errors.Is(err, model.ErrSLANotBreached) || errors.Is(err, order.ErrNotExist) {
+				continue
+			} else if err != nil {
+				return fmt.Errorf("failed to flag order %d: %w", o.OrderID, err)
+			}
+			breached++
+
+			if _, err := a.events.Publish(ctx, events.NewSLABreached(tenant.FromContext(ctx), flagged, now)); err != nil {
+				fmt.Println("failed to publish sla breach:", err)
+			}
+		}
+
+		return nil
+	})
+
+	if breached > 0 {
+		fmt.Printf("flagged %d orders that missed their sla\n", breached)
+	}
+
+	return err
+}
+
+// flagOverdueDisputes marks the disputes that still need a response after
+// their evidence deadline, and alerts about each.
+func (a *App) flagOverdueDisputes(ctx context.Context) error {
+
+	now := a.clock.Now().UTC()
+
+	var overdue int
+
+	err := order.ForEachPage(ctx, a.repo, 100, func(orders []model.Order) error {
+
+		for _, o := range orders {
+			if !o.DisputeOverdue(now) {
+				continue
+			}
+
+			flagged, missed, err := a.orders.MissDisputeDeadlines(ctx, o.OrderID)
+
+			// The dispute may have been answered or closed, or the order
+			// vanished, since the page was read.
+			if errors.Is(err, model.ErrNoDeadlineMissed) || errors.Is(err, order.ErrNotExist) {
+				continue
+			} else if err != nil {
+				return fmt.Errorf("failed to flag disputes of order %d: %w", o.OrderID, err)
+			}
+			overdue += len(missed)
+
+			for _, d := range missed {
+				if _, err := a.events.Publish(ctx, events.NewDisputeChanged(tenant.FromContext(ctx), events.TypeDisputeOverdue, flagged, d, "", now)); err != nil {
+					fmt.Println("failed to publish missed dispute deadline:", err)
+				}
+			}
+		}
+
+		return nil
+	})
+
+	if overdue > 0 {
+		fmt.Printf("flagged %d disputes that missed their evidence deadline\n", overdue)
+	}
+
+	return err
+}
+
+// aggregateStats stores order totals in a hash so /admin/stats does not
+// have to walk every order on each request.
+func (a *App) aggregateStats(ctx context.Context) error {
+
+	var total, lineItems int
+	var revenue uint64
+	byStatus := map[string]int{}
+
+	err := order.ForEachPage(ctx, a.repo, 100, func(orders []model.Order) error {
+
+		for _, o := range orders {
+			total++
+			byStatus[o.Status()]++
+			lineItems += len(o.LineItems)
+			for _, item := range o.LineItems {
+				revenue += uint64(item.Quantity) * uint64(item.Price)
+			}
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return fmt.Errorf("failed to scan orders: %w", err)
+	}
+
+	fields := map[string]any{
+		"total":      total,
+		"line_items": lineItems,
+		"revenue":    revenue,
+		"updated_at": a.clock.Now().UTC().Format(time.RFC3339),
+	}
+
+	for _, status := range []string{model.StatusPending, model.StatusReview, model.StatusBackordered, model.StatusShipped, model.StatusCompleted, model.StatusCancelled} {
+		fields["status:"+status] = byStatus[status]
+	}
+
+	if err := a.rdb.HSet(ctx, statsKey, fields).Err(); err != nil {
+		return fmt.Errorf("failed to store stats: %w", err)
+	}
+
+	return nil
+}
+
+func (a *App) serveStats(w http.ResponseWriter, r *http.Request) {
+
+	if a.readModel != nil {
+		a.serveViewStats(w, r)
+		return
+	}
+
+	fields, err := a.rdb.HGetAll(r.Context(), statsKey).Result()
+	if err != nil {
+		fmt.Println("failed to get stats:", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	if len(fields) == 0 {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	stats := struct {
+		Total     int            `json:"total"`
+		ByStatus  map[string]int `json:"by_status"`
+		LineItems int            `json:"line_items"`
+		Revenue   uint64         `json:"revenue"`
+		UpdatedAt string         `json:"updated_at"`
+	}{
+		ByStatus:  map[string]int{},
+		UpdatedAt: fields["updated_at"],
+	}
+
+	stats.Total, _ = strconv.Atoi(fields["total"])
+	stats.LineItems, _ = strconv.Atoi(fields["line_items"])
+	stats.Revenue, _ = strconv.ParseUint(fields["revenue"], 10, 64)
+
+	for _, status := range []string{model.StatusPending, model.StatusReview, model.StatusBackordered, model.StatusShipped, model.StatusCompleted, model.StatusCancelled} {
+		stats.ByStatus[status], _ = strconv.Atoi(fields["status:"+status])
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(stats)
+}
+
+// serveViewStats answers the stats from the totals view, in the shape of
+// the scanned ones.
+func (a *App) serveViewStats(w http.ResponseWriter, r *http.Request) {
+
+	totals, err := a.views.Totals(r.Context())
+	if errors.Is(err, readmodel.ErrNotBuilt) {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	} else if err != nil {
+		fmt.Println("failed to get stats:", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	stats := struct {
+		Total     int64            `json:"total"`
+		ByStatus  map[string]int64 `json:"by_status"`
+		LineItems int64            `json:"line_items"`
+		Revenue   int64            `json:"revenue"`
+		UpdatedAt string           `json:"updated_at"`
+	}{
+		Total:     totals.Orders,
+		ByStatus:  totals.ByStatus,
+		LineItems: totals.LineItems,
+		Revenue:   totals.Revenue,
+		UpdatedAt: totals.UpdatedAt,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(stats)
+}
@@ -0,0 +1,232 @@
+// Package audit records who did what through the API, and lets admins act
+// on behalf of customers with every such action on the record.
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/google/uuid"
+	"github.com/i101dev/microservices-NN/auth"
+	"github.com/redis/go-redis/v9"
+)
+
+const streamKey = "audit"
+
+// maxEntries is about how many entries the log keeps; older ones are
+// trimmed as new ones come in.
+const maxEntries = 100000
+
+// Entry is one request in the log. ImpersonatedBy and Session are set on
+// the requests of an admin acting as Subject.
+type Entry struct {
+	ID             string    `json:"id"`
+	At             time.Time `json:"at"`
+	Subject        string    `json:"subject"`
+	ImpersonatedBy string    `json:"impersonated_by,omitempty"`
+	Session        string    `json:"session,omitempty"`
+	Method         string    `json:"method"`
+	Path           string    `json:"path"`
+	Status         int       `json:"status"`
+	RequestID      string    `json:"request_id,omitempty"`
+}
+
+// Log keeps entries in a capped stream, and impersonation sessions beside
+// it.
+type Log struct {
+	Client *redis.Client
+	// Now stamps entries and sessions. Nil means time.Now.
+	Now func() time.Time
+}
+
+func (l *Log) now() time.Time {
+	if l.Now == nil {
+		return time.Now()
+	}
+	return l.Now()
+}
+
+func (l *Log) Append(ctx context.Context, e Entry) error {
+
+	data, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("failed to encode audit entry: %w", err)
+	}
+
+	err = l.Client.XAdd(ctx, &redis.XAddArgs{
+		Stream: streamKey,
+		MaxLen: maxEntries,
+		Approx: true,
+		Values: map[string]any{"entry": data},
+	}).Err()
+
+	if err != nil {
+		return fmt.Errorf("failed to append audit entry: %w", err)
+	}
+
+	return nil
+}
+
+// Query picks entries from the log. Session, when set, keeps the entries
+// of that impersonation session, and Impersonated those of any.
+type Query struct {
+	Session      string
+	Impersonated bool
+	Limit        int
+}
+
+func (q Query) match(e Entry) bool {
+
+	if q.Session != "" && e.Session != q.Session {
+		return false
+	}
+
+	return !q.Impersonated || e.ImpersonatedBy != ""
+}
+
+// Recent returns the latest entries matching q, newest first. It stops
+// after reading 10000 entries, to bound queries that match few.
+func (l *Log) Recent(ctx context.Context, q Query) ([]Entry, error) {
+
+	const batch, budget = 500, 10000
+
+	entries := []Entry{}
+	end := "+"
+
+	for read := 0; read < budget && len(entries) < q.Limit; {
+		msgs, err := l.Client.XRevRangeN(ctx, streamKey, end, "-", batch).Result()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read audit log: %w", err)
+		}
+
+		for _, m := range msgs {
+			read++
+
+			data, _ := m.Values["entry"].(string)
+
+			var e Entry
+			if err := json.Unmarshal([]byte(data), &e); err != nil {
+				fmt.Println("failed to decode audit entry:", err)
+				continue
+			}
+			e.ID = m.ID
+
+			if q.match(e) {
+				entries = append(entries, e)
+				if len(entries) == q.Limit {
+					break
+				}
+			}
+		}
+
+		if len(msgs) < batch {
+			break
+		}
+		end = "(" + msgs[len(msgs)-1].ID
+	}
+
+	return entries, nil
+}
+
+// ForgetCustomer deletes the entries of requests the customer made
+// themselves. Those an admin made as the customer stay, as the record of
+// what was done on their behalf, and so do impersonation sessions.
+func (l *Log) ForgetCustomer(ctx context.Context, customer uuid.UUID) error {
+
+	start := "-"
+
+	for {
+		msgs, err := l.Client.XRangeN(ctx, streamKey, start, "+", 500).Result()
+		if err != nil {
+			return fmt.Errorf("failed to read audit log: %w", err)
+		}
+
+		var ids []string
+
+		for _, m := range msgs {
+			data, _ := m.Values["entry"].(string)
+
+			var e Entry
+			if err := json.Unmarshal([]byte(data), &e); err != nil {
+				continue
+			}
+
+			if e.Subject == customer.String() && e.ImpersonatedBy == "" {
+				ids = append(ids, m.ID)
+			}
+		}
+
+		if len(ids) > 0 {
+			if err := l.Client.XDel(ctx, streamKey, ids...).Err(); err != nil {
+				return fmt.Errorf("failed to delete from audit log: %w", err)
+			}
+		}
+
+		if len(msgs) < 500 {
+			return nil
+		}
+
+		start = "(" + msgs[len(msgs)-1].ID
+	}
+}
+
+// Middleware puts the requests that change something on the log, and every
+// request made while impersonating. An admin with the impersonate scope
+// acts as the customer named in X-Impersonate-Customer: the request runs
+// as that customer, with none of the admin's scopes, and the session it
+// belongs to is sent back in X-Impersonation-Session. Impersonating is
+// refused when the session cannot be recorded.
+func (l *Log) Middleware(next http.Handler) http.Handler {
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		var session string
+
+		if customer := r.Header.Get(ImpersonateHeader); customer != "" {
+			p, s, ok := l.impersonate(w, r, customer)
+			if !ok {
+				return
+			}
+			session = s
+
+			w.Header().Set(SessionHeader, session)
+			r = r.WithContext(auth.NewContext(r.Context(), p))
+		}
+
+		p, authenticated := auth.FromContext(r.Context())
+		read := r.Method == http.MethodGet || r.Method == http.MethodHead || r.Method == http.MethodOptions
+
+		if session == "" && (!authenticated || read) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+
+		next.ServeHTTP(ww, r)
+
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+
+		e := Entry{
+			At:             l.now().UTC(),
+			Subject:        p.Subject,
+			ImpersonatedBy: p.ImpersonatedBy,
+			Session:        session,
+			Method:         r.Method,
+			Path:           r.URL.Path,
+			Status:         status,
+			RequestID:      middleware.GetReqID(r.Context()),
+		}
+
+		if err := l.Append(context.WithoutCancel(r.Context()), e); err != nil {
+			fmt.Println("failed to audit request:", err)
+		}
+	})
+}
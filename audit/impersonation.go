@@ -0,0 +1,143 @@
+package audit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/i101dev/microservices-NN/auth"
+	"github.com/i101dev/microservices-NN/httperr"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	ImpersonateHeader = "X-Impersonate-Customer"
+	SessionHeader     = "X-Impersonation-Session"
+)
+
+// An impersonation session is the requests of an admin acting as one
+// customer, until it makes none for sessionIdle. Sessions are kept for
+// sessionTTL, and the latest maxSessions can be listed.
+const (
+	sessionIdle = 30 * time.Minute
+	sessionTTL  = 30 * 24 * time.Hour
+	maxSessions = 1000
+	sessionsKey = "impersonation:sessions"
+)
+
+func activeKey(admin, customer string) string {
+	return "impersonation:active:" + admin + ":" + customer
+}
+
+func sessionKey(id string) string {
+	return "impersonation:session:" + id
+}
+
+type Session struct {
+	ID         string    `json:"id"`
+	Admin      string    `json:"admin"`
+	Customer   string    `json:"customer"`
+	StartedAt  time.Time `json:"started_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+	Requests   int64     `json:"requests"`
+}
+
+// impersonate checks that the caller may act as customer, and returns the
+// principal to act with and the session the request belongs to. It
+// answers the request when it fails.
+func (l *Log) impersonate(w http.ResponseWriter, r *http.Request, customer string) (auth.Principal, string, bool) {
+
+	id, err := uuid.Parse(customer)
+	if err != nil {
+		httperr.Write(w, http.StatusBadRequest, "invalid_customer_id", ImpersonateHeader+" must be a customer uuid")
+		return auth.Principal{}, "", false
+	}
+
+	admin, ok := auth.FromContext(r.Context())
+	if !ok || !admin.HasScope(auth.ScopeImpersonate) || admin.ImpersonatedBy != "" {
+		httperr.Write(w, http.StatusForbidden, "forbidden", "impersonating customers takes the "+auth.ScopeImpersonate+" scope")
+		return auth.Principal{}, "", false
+	}
+
+	session, err := l.touch(r.Context(), admin.Subject, id.String())
+	if err != nil {
+		fmt.Println("failed to record impersonation session:", err)
+		httperr.Write(w, http.StatusServiceUnavailable, "audit_unavailable", "impersonation cannot be recorded, try again")
+		return auth.Principal{}, "", false
+	}
+
+	return auth.Principal{Subject: id.String(), ImpersonatedBy: admin.Subject}, session, true
+}
+
+// touch returns the session of admin acting as customer, starting one when
+// there is none, and counts a request against it.
+func (l *Log) touch(ctx context.Context, admin, customer string) (string, error) {
+
+	now := l.now().UTC()
+	active := activeKey(admin, customer)
+
+	id := uuid.NewString()
+
+	started, err := l.Client.SetNX(ctx, active, id, sessionIdle).Result()
+	if err != nil {
+		return "", err
+	}
+
+	if !started {
+		if id, err = l.Client.Get(ctx, active).Result(); err != nil {
+			return "", err
+		}
+	}
+
+	pipe := l.Client.TxPipeline()
+
+	if started {
+		pipe.HSet(ctx, sessionKey(id), "admin", admin, "customer", customer, "started_at", now.Format(time.RFC3339Nano))
+	}
+	pipe.HSet(ctx, sessionKey(id), "last_seen_at", now.Format(time.RFC3339Nano))
+	pipe.HIncrBy(ctx, sessionKey(id), "requests", 1)
+	pipe.Expire(ctx, sessionKey(id), sessionTTL)
+	pipe.Expire(ctx, active, sessionIdle)
+	pipe.ZAdd(ctx, sessionsKey, redis.Z{Score: float64(now.UnixMilli()), Member: id})
+	pipe.ZRemRangeByRank(ctx, sessionsKey, 0, -maxSessions-1)
+
+	if _, err := pipe.Exec(ctx); err != nil {
+		return "", err
+	}
+
+	return id, nil
+}
+
+// Sessions returns the latest impersonation sessions, the most recently
+// active first.
+func (l *Log) Sessions(ctx context.Context, limit int) ([]Session, error) {
+
+	ids, err := l.Client.ZRevRange(ctx, sessionsKey, 0, int64(limit)-1).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list impersonation sessions: %w", err)
+	}
+
+	sessions := make([]Session, 0, len(ids))
+
+	for _, id := range ids {
+		fields, err := l.Client.HGetAll(ctx, sessionKey(id)).Result()
+		if errors.Is(err, redis.Nil) || len(fields) == 0 {
+			continue
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to read impersonation session: %w", err)
+		}
+
+		s := Session{ID: id, Admin: fields["admin"], Customer: fields["customer"]}
+		s.StartedAt, _ = time.Parse(time.RFC3339Nano, fields["started_at"])
+		s.LastSeenAt, _ = time.Parse(time.RFC3339Nano, fields["last_seen_at"])
+		s.Requests, _ = strconv.ParseInt(fields["requests"], 10, 64)
+
+		sessions = append(sessions, s)
+	}
+
+	return sessions, nil
+}
@@ -0,0 +1,69 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// Principal is the authenticated caller of a request.
+type Principal struct {
+	Subject string   `json:"subject"`
+	Scopes  []string `json:"scopes,omitempty"`
+	// ImpersonatedBy is the admin acting as Subject, if one is.
+	ImpersonatedBy string `json:"impersonated_by,omitempty"`
+}
+
+// ScopeImpersonate lets admins act on behalf of customers.
+const ScopeImpersonate = "impersonate"
+
+// ScopeInternal is held by staff, who can read and write internal notes.
+const ScopeInternal = "internal"
+
+// ScopeAdmin is held by operators, who can expedite orders.
+const ScopeAdmin = "admin"
+
+func (p Principal) HasScope(scope string) bool {
+	return slices.Contains(p.Scopes, scope)
+}
+
+type contextKey struct{}
+
+func NewContext(ctx context.Context, p Principal) context.Context {
+	return context.WithValue(ctx, contextKey{}, p)
+}
+
+// FromContext returns the principal attached to ctx, if any.
+func FromContext(ctx context.Context) (Principal, bool) {
+	p, ok := ctx.Value(contextKey{}).(Principal)
+	return p, ok
+}
+
+const (
+	SubjectHeader = "X-Auth-Subject"
+	ScopesHeader  = "X-Auth-Scopes"
+)
+
+// TrustedHeaders reads the principal from headers set by an authenticating
+// gateway in front of the service. Only mount it when every request is
+// guaranteed to pass through that gateway: the headers are taken at face
+// value.
+func TrustedHeaders(next http.Handler) http.Handler {
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		subject := r.Header.Get(SubjectHeader)
+		if subject == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		p := Principal{
+			Subject: subject,
+			Scopes:  strings.Fields(strings.ReplaceAll(r.Header.Get(ScopesHeader), ",", " ")),
+		}
+
+		next.ServeHTTP(w, r.WithContext(NewContext(r.Context(), p)))
+	})
+}
@@ -0,0 +1,114 @@
+package calendar
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Event is one all-day iCalendar event spanning the days First to Last.
+// With Alarm set, calendars remind at that offset from the start of the
+// first day.
+type Event struct {
+	UID         string
+	Summary     string
+	Description string
+	First       time.Time
+	Last        time.Time
+	Stamp       time.Time
+	Sequence    int64
+	Alarm       *time.Duration
+}
+
+// Encode writes the events as an RFC 5545 calendar.
+func Encode(prodID string, events ...Event) []byte {
+
+	var b bytes.Buffer
+
+	line := func(s string) {
+		b.WriteString(fold(s))
+		b.WriteString("\r\n")
+	}
+
+	line("BEGIN:VCALENDAR")
+	line("VERSION:2.0")
+	line("PRODID:" + prodID)
+	line("CALSCALE:GREGORIAN")
+	line("METHOD:PUBLISH")
+
+	for _, e := range events {
+		line("BEGIN:VEVENT")
+		line("UID:" + e.UID)
+		line("DTSTAMP:" + e.Stamp.UTC().Format("20060102T150405Z"))
+		line(fmt.Sprintf("SEQUENCE:%d", e.Sequence))
+		line("DTSTART;VALUE=DATE:" + e.First.Format("20060102"))
+		// The end date is exclusive.
+		line("DTEND;VALUE=DATE:" + e.Last.AddDate(0, 0, 1).Format("20060102"))
+		line("SUMMARY:" + escape(e.Summary))
+		if e.Description != "" {
+			line("DESCRIPTION:" + escape(e.Description))
+		}
+		line("TRANSP:TRANSPARENT")
+
+		if e.Alarm != nil {
+			line("BEGIN:VALARM")
+			line("ACTION:DISPLAY")
+			line("DESCRIPTION:" + escape(e.Summary))
+			line("TRIGGER;RELATED=START:" + duration(*e.Alarm))
+			line("END:VALARM")
+		}
+
+		line("END:VEVENT")
+	}
+
+	line("END:VCALENDAR")
+
+	return b.Bytes()
+}
+
+func escape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\n", `\n`).Replace(s)
+}
+
+// fold splits lines longer than 75 octets, as the format requires,
+// without cutting a UTF-8 sequence in two.
+func fold(s string) string {
+
+	if len(s) <= 75 {
+		return s
+	}
+
+	var b strings.Builder
+	limit := 75
+
+	for len(s) > limit {
+		cut := limit
+		for cut > 0 && s[cut]&0xC0 == 0x80 {
+			cut--
+		}
+		b.WriteString(s[:cut])
+		b.WriteString("\r\n ")
+		s = s[cut:]
+		// Continuation lines start with the space.
+		limit = 74
+	}
+
+	b.WriteString(s)
+
+	return b.String()
+}
+
+func duration(d time.Duration) string {
+
+	sign := ""
+	if d < 0 {
+		sign, d = "-", -d
+	}
+
+	if d%time.Hour == 0 {
+		return fmt.Sprintf("%sPT%dH", sign, int64(d/time.Hour))
+	}
+
+	return fmt.Sprintf("%sPT%dM", sign, int64(d/time.Minute))
+}
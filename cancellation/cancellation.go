@@ -0,0 +1,215 @@
+// Package cancellation decides whether an order may be cancelled, and for
+// how much, by rules the store configures, such as no cancelling once an
+// order is shipped or a fee once it is a day old.
+package cancellation
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/i101dev/microservices-NN/model"
+)
+
+// ErrRejected is wrapped by every *Rejection.
+var ErrRejected = errors.New("cancellation rejected")
+
+const (
+	ActionReject = "reject"
+	ActionFee    = "fee"
+)
+
+// StatusRule names the reason given for orders in a status that cannot be
+// cancelled at all, whatever the rules say.
+const StatusRule = "order_status"
+
+// Rule rejects the cancellation of the orders it matches, or charges a fee
+// for it. Orders match when they are in one of Statuses, were placed more
+// than AfterHours ago and ship by Method; empty Statuses and Method and
+// zero AfterHours match anything. The fee is Fee plus FeeRate of the
+// order's total, 0.1 for 10%.
+type Rule struct {
+	Name       string   `json:"name"`
+	Action     string   `json:"action"`
+	Statuses   []string `json:"statuses"`
+	AfterHours float64  `json:"after_hours"`
+	Method     string   `json:"method"`
+	Fee        uint     `json:"fee"`
+	FeeRate    float64  `json:"fee_rate"`
+	// Message is the reason a rejected caller is given.
+	Message string `json:"message"`
+}
+
+// Policy is the rules cancellations are checked against. Every reject
+// rule that matches is given as a reason; of the fee rules, the first
+// that matches wins, so more specific ones go first. The zero Policy only
+// keeps orders that are shipped, completed or cancelled already from
+// being cancelled.
+type Policy struct {
+	Rules []Rule `json:"rules"`
+}
+
+// Load reads a JSON file holding a Policy.
+func Load(path string) (*Policy, error) {
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cancellation policy: %w", err)
+	}
+
+	return Parse(data)
+}
+
+func Parse(data []byte) (*Policy, error) {
+
+	var p Policy
+
+	if err := json.Unmarshal(data, &p); err != nil {
+		return nil, fmt.Errorf("failed to parse cancellation policy: %w", err)
+	}
+
+	for i := range p.Rules {
+		r := &p.Rules[i]
+
+		if r.Name == "" {
+			r.Name = fmt.Sprintf("rule %d", i+1)
+		}
+
+		switch {
+		case r.Action != ActionReject && r.Action != ActionFee:
+			return nil, fmt.Errorf("cancellation rule %q: action must be %q or %q", r.Name, ActionReject, ActionFee)
+		case r.AfterHours < 0:
+			return nil, fmt.Errorf("cancellation rule %q: after_hours cannot be negative", r.Name)
+		case r.Action == ActionFee && r.Fee == 0 && r.FeeRate == 0:
+			return nil, fmt.Errorf("cancellation rule %q: needs a fee or fee_rate", r.Name)
+		case r.FeeRate < 0 || r.FeeRate > 1:
+			return nil, fmt.Errorf("cancellation rule %q: fee_rate must be between 0 and 1", r.Name)
+		}
+	}
+
+	return &p, nil
+}
+
+// Reason is why a cancellation was rejected: the rule that rejected it
+// and its message.
+type Reason struct {
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// Rejection is returned for cancellations the policy does not allow. It
+// also wraps model.ErrInvalidTransition when the order cannot be cancelled
+// in its status.
+type Rejection struct {
+	Reasons []Reason
+}
+
+func (r *Rejection) Error() string {
+
+	messages := make([]string, len(r.Reasons))
+	for i, reason := range r.Reasons {
+		messages[i] = reason.Message
+	}
+
+	return ErrRejected.Error() + ": " + strings.Join(messages, "; ")
+}
+
+func (r *Rejection) Unwrap() []error {
+
+	errs := []error{ErrRejected}
+
+	if slices.ContainsFunc(r.Reasons, func(reason Reason) bool { return reason.Rule == StatusRule }) {
+		errs = append(errs, model.ErrInvalidTransition)
+	}
+
+	return errs
+}
+
+// Decision is what cancelling an order costs, and the fee rule that set
+// it, empty when it is free.
+type Decision struct {
+	Fee  uint
+	Rule string
+}
+
+// Evaluate checks the cancellation of the order at now. It returns a
+// *Rejection with every reason it is not allowed, or what it costs. A nil
+// Policy is the zero Policy.
+func (p *Policy) Evaluate(o model.Order, now time.Time) (Decision, error) {
+
+	var reasons []Reason
+
+	switch status := o.Status(); status {
+	case model.StatusPending, model.StatusReview, model.StatusBackordered:
+	default:
+		reasons = append(reasons, Reason{
+			Rule:    StatusRule,
+			Message: fmt.Sprintf("orders that are %s cannot be cancelled", status),
+		})
+	}
+
+	var d Decision
+
+	if p != nil {
+		for _, r := range p.Rules {
+			if !r.matches(o, now) {
+				continue
+			}
+
+			switch {
+			case r.Action == ActionReject:
+				reasons = append(reasons, Reason{Rule: r.Name, Message: r.message()})
+			case d.Rule == "":
+				d = Decision{Fee: r.fee(o), Rule: r.Name}
+			}
+		}
+	}
+
+	if len(reasons) > 0 {
+		return Decision{}, &Rejection{Reasons: reasons}
+	}
+
+	return d, nil
+}
+
+func (r Rule) matches(o model.Order, now time.Time) bool {
+
+	if len(r.Statuses) > 0 && !slices.Contains(r.Statuses, o.Status()) {
+		return false
+	}
+
+	if r.AfterHours > 0 {
+		if o.CreatedAt == nil || now.Sub(*o.CreatedAt) <= time.Duration(r.AfterHours*float64(time.Hour)) {
+			return false
+		}
+	}
+
+	if r.Method != "" && (o.Shipping == nil || !strings.EqualFold(r.Method, o.Shipping.Method)) {
+		return false
+	}
+
+	return true
+}
+
+func (r Rule) message() string {
+
+	if r.Message != "" {
+		return r.Message
+	}
+
+	return fmt.Sprintf("cancellation is not allowed by rule %q", r.Name)
+}
+
+// fee never comes to more than the order's total.
+func (r Rule) fee(o model.Order) uint {
+
+	total := o.Total()
+	fee := r.Fee + uint(math.Round(r.FeeRate*float64(total)))
+
+	return min(fee, total)
+}
@@ -0,0 +1,76 @@
+package carrier
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+)
+
+var (
+	ErrUnknownCarrier = errors.New("unknown carrier")
+	ErrSignature      = errors.New("webhook signature is invalid")
+	ErrPayload        = errors.New("webhook payload is invalid")
+)
+
+// Update is one tracking event, in the same shape whichever carrier sent
+// it. Status is one of the model.Tracking* statuses.
+type Update struct {
+	Carrier        string    `json:"carrier"`
+	TrackingNumber string    `json:"tracking_number"`
+	Status         string    `json:"status"`
+	Description    string    `json:"description,omitempty"`
+	Location       string    `json:"location,omitempty"`
+	At             time.Time `json:"at"`
+}
+
+// Adapter turns one carrier's webhooks into updates. Verify checks the
+// request was signed with the shared secret before Parse sees the body.
+// Events that say nothing about where the parcel is, such as a label
+// being printed, are left out of the result.
+type Adapter interface {
+	Verify(header http.Header, body []byte, now time.Time) error
+	Parse(body []byte) ([]Update, error)
+}
+
+var adapters = map[string]func(secret []byte) Adapter{
+	"ups":   func(secret []byte) Adapter { return &UPS{Secret: secret} },
+	"fedex": func(secret []byte) Adapter { return &FedEx{Secret: secret} },
+	"dhl":   func(secret []byte) Adapter { return &DHL{Secret: secret} },
+}
+
+// New returns the adapter for a carrier name such as "ups".
+func New(name, secret string) (Adapter, error) {
+
+	fn, ok := adapters[name]
+	if !ok {
+		return nil, fmt.Errorf("%w %q, known carriers are %v", ErrUnknownCarrier, name, Names())
+	}
+
+	return fn([]byte(secret)), nil
+}
+
+func Names() []string {
+
+	names := make([]string, 0, len(adapters))
+	for name := range adapters {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+func sign(secret []byte, parts ...[]byte) []byte {
+
+	mac := hmac.New(sha256.New, secret)
+	for _, p := range parts {
+		mac.Write(p)
+	}
+
+	return mac.Sum(nil)
+}
@@ -0,0 +1,115 @@
+package carrier
+
+import (
+	"crypto/hmac"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/i101dev/microservices-NN/model"
+)
+
+// dhlTolerance is how far the signed timestamp may be from now, so a
+// captured webhook cannot be replayed later.
+const dhlTolerance = 5 * time.Minute
+
+// DHL signs "<unix time>.<body>" and sends DHL-Signature as
+// "t=<unix time>,v1=<hex HMAC-SHA256>".
+type DHL struct {
+	Secret []byte
+}
+
+type dhlPayload struct {
+	Shipments []struct {
+		ID     string `json:"id"`
+		Status struct {
+			StatusCode  string `json:"statusCode"`
+			Description string `json:"description"`
+			// Timestamp has no zone and is in UTC.
+			Timestamp string `json:"timestamp"`
+			Location  struct {
+				Address struct {
+					Locality string `json:"addressLocality"`
+					Country  string `json:"countryCode"`
+				} `json:"address"`
+			} `json:"location"`
+		} `json:"status"`
+	} `json:"shipments"`
+}
+
+func (d *DHL) Verify(header http.Header, body []byte, now time.Time) error {
+
+	var ts, sig string
+
+	for _, part := range strings.Split(header.Get("DHL-Signature"), ",") {
+		k, v, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch k {
+		case "t":
+			ts = v
+		case "v1":
+			sig = v
+		}
+	}
+
+	unix, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return ErrSignature
+	}
+
+	if skew := now.Sub(time.Unix(unix, 0)); skew > dhlTolerance || skew < -dhlTolerance {
+		return fmt.Errorf("%w: timestamp is %s away", ErrSignature, skew.Round(time.Second))
+	}
+
+	got, err := hex.DecodeString(sig)
+	if err != nil || !hmac.Equal(got, sign(d.Secret, []byte(ts), []byte("."), body)) {
+		return ErrSignature
+	}
+
+	return nil
+}
+
+func (d *DHL) Parse(body []byte) ([]Update, error) {
+
+	var p dhlPayload
+
+	if err := json.Unmarshal(body, &p); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrPayload, err)
+	}
+
+	var updates []Update
+
+	for _, s := range p.Shipments {
+		status, ok := map[string]string{
+			"transit":   model.TrackingInTransit,
+			"delivered": model.TrackingDelivered,
+			"failure":   model.TrackingException,
+		}[s.Status.StatusCode]
+		if !ok {
+			continue
+		}
+
+		if s.ID == "" {
+			return nil, fmt.Errorf("%w: shipment without id", ErrPayload)
+		}
+
+		at, err := time.Parse("2006-01-02T15:04:05", s.Status.Timestamp)
+		if err != nil {
+			return nil, fmt.Errorf("%w: bad timestamp %q", ErrPayload, s.Status.Timestamp)
+		}
+
+		updates = append(updates, Update{
+			Carrier:        "dhl",
+			TrackingNumber: s.ID,
+			Status:         status,
+			Description:    s.Status.Description,
+			Location:       location(s.Status.Location.Address.Locality, s.Status.Location.Address.Country),
+			At:             at,
+		})
+	}
+
+	return updates, nil
+}
@@ -0,0 +1,82 @@
+package carrier
+
+import (
+	"crypto/hmac"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/i101dev/microservices-NN/model"
+)
+
+// FedEx batches events for many tracking numbers into one webhook, signed
+// with a base64 HMAC-SHA256 of the body in X-FedEx-Signature.
+type FedEx struct {
+	Secret []byte
+}
+
+type fedexPayload struct {
+	Events []struct {
+		TrackingNumber   string    `json:"trackingNumber"`
+		EventType        string    `json:"eventType"`
+		EventDescription string    `json:"eventDescription"`
+		Timestamp        time.Time `json:"timestamp"`
+		ScanLocation     struct {
+			City    string `json:"city"`
+			Country string `json:"countryCode"`
+		} `json:"scanLocation"`
+	} `json:"events"`
+}
+
+func (f *FedEx) Verify(header http.Header, body []byte, now time.Time) error {
+
+	got, err := base64.StdEncoding.DecodeString(header.Get("X-FedEx-Signature"))
+	if err != nil || len(got) == 0 || !hmac.Equal(got, sign(f.Secret, body)) {
+		return ErrSignature
+	}
+
+	return nil
+}
+
+func (f *FedEx) Parse(body []byte) ([]Update, error) {
+
+	var p fedexPayload
+
+	if err := json.Unmarshal(body, &p); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrPayload, err)
+	}
+
+	var updates []Update
+
+	for _, e := range p.Events {
+		var status string
+
+		switch e.EventType {
+		case "PU", "IT", "AR", "DP", "OD":
+			status = model.TrackingInTransit
+		case "DL":
+			status = model.TrackingDelivered
+		case "DE", "SE":
+			status = model.TrackingException
+		default:
+			continue
+		}
+
+		if e.TrackingNumber == "" {
+			return nil, fmt.Errorf("%w: event without trackingNumber", ErrPayload)
+		}
+
+		updates = append(updates, Update{
+			Carrier:        "fedex",
+			TrackingNumber: e.TrackingNumber,
+			Status:         status,
+			Description:    e.EventDescription,
+			Location:       location(e.ScanLocation.City, e.ScanLocation.Country),
+			At:             e.Timestamp.UTC(),
+		})
+	}
+
+	return updates, nil
+}
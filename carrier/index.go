@@ -0,0 +1,92 @@
+package carrier
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/i101dev/microservices-NN/model"
+	"github.com/i101dev/microservices-NN/repository/order"
+	"github.com/redis/go-redis/v9"
+)
+
+const indexKey = "tracking"
+
+// Index maps carrier tracking numbers to the orders that shipped under
+// them, in one hash keyed by "<carrier>:<number>".
+type Index struct {
+	Client *redis.Client
+}
+
+func indexField(carrier, number string) string {
+	return carrier + ":" + number
+}
+
+// Lookup returns the order shipped under a tracking number, and false for
+// numbers it has never seen.
+func (x *Index) Lookup(ctx context.Context, carrier, number string) (uint64, bool, error) {
+
+	id, err := x.Client.HGet(ctx, indexKey, indexField(carrier, number)).Uint64()
+	if errors.Is(err, redis.Nil) {
+		return 0, false, nil
+	} else if err != nil {
+		return 0, false, fmt.Errorf("failed to look up tracking number: %w", err)
+	}
+
+	return id, true, nil
+}
+
+// Put records o's tracking number. Orders without one are skipped.
+func (x *Index) Put(ctx context.Context, o model.Order) error {
+
+	if o.Tracking == nil {
+		return nil
+	}
+
+	err := x.Client.HSet(ctx, indexKey, indexField(o.Tracking.Carrier, o.Tracking.Number), o.OrderID).Err()
+	if err != nil {
+		return fmt.Errorf("failed to index tracking number: %w", err)
+	}
+
+	return nil
+}
+
+// Forget drops a tracking number, for entries left behind by orders that
+// were deleted or shipped again under another number.
+func (x *Index) Forget(ctx context.Context, carrier, number string) error {
+
+	if err := x.Client.HDel(ctx, indexKey, indexField(carrier, number)).Err(); err != nil {
+		return fmt.Errorf("failed to drop tracking number: %w", err)
+	}
+
+	return nil
+}
+
+// Intercept indexes the tracking numbers of orders as they are written.
+// Failing to update the index is logged but never fails the write, so a
+// webhook for a number it missed is ignored.
+func (x *Index) Intercept(ctx context.Context, call *order.Call, next order.Handler) error {
+
+	if err := next(ctx, call); err != nil {
+		return err
+	}
+
+	var orders []model.Order
+
+	switch call.Op {
+	case order.OpInsert, order.OpUpdate:
+		orders = []model.Order{call.Order}
+	case order.OpInsertAll:
+		orders = call.Orders
+	case order.OpApply:
+		orders = append(append(orders, call.Batch.Insert...), call.Batch.Update...)
+	}
+
+	for _, o := range orders {
+		if err := x.Put(ctx, o); err != nil {
+			fmt.Println("failed to index tracking number:", err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,106 @@
+package carrier
+
+import (
+	"crypto/hmac"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/i101dev/microservices-NN/model"
+)
+
+// UPS takes one tracking number per webhook, signed with an HMAC-SHA256
+// of the body in X-UPS-Signature as "sha256=<hex>".
+type UPS struct {
+	Secret []byte
+}
+
+type upsPayload struct {
+	TrackingNumber string `json:"trackingNumber"`
+	Activity       []struct {
+		Status struct {
+			Type        string `json:"type"`
+			Description string `json:"description"`
+		} `json:"status"`
+		Location struct {
+			City    string `json:"city"`
+			Country string `json:"countryCode"`
+		} `json:"location"`
+		// GMTDate is 20261014 and GMTTime is 142300.
+		GMTDate string `json:"gmtDate"`
+		GMTTime string `json:"gmtTime"`
+	} `json:"activity"`
+}
+
+func (u *UPS) Verify(header http.Header, body []byte, now time.Time) error {
+
+	sig, ok := strings.CutPrefix(header.Get("X-UPS-Signature"), "sha256=")
+	if !ok {
+		return ErrSignature
+	}
+
+	got, err := hex.DecodeString(sig)
+	if err != nil || !hmac.Equal(got, sign(u.Secret, body)) {
+		return ErrSignature
+	}
+
+	return nil
+}
+
+func (u *UPS) Parse(body []byte) ([]Update, error) {
+
+	var p upsPayload
+
+	if err := json.Unmarshal(body, &p); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrPayload, err)
+	}
+
+	if p.TrackingNumber == "" {
+		return nil, fmt.Errorf("%w: trackingNumber is missing", ErrPayload)
+	}
+
+	var updates []Update
+
+	for _, a := range p.Activity {
+		status, ok := map[string]string{
+			"P": model.TrackingInTransit,
+			"I": model.TrackingInTransit,
+			"D": model.TrackingDelivered,
+			"X": model.TrackingException,
+		}[a.Status.Type]
+		if !ok {
+			continue
+		}
+
+		at, err := time.Parse("20060102150405", a.GMTDate+a.GMTTime)
+		if err != nil {
+			return nil, fmt.Errorf("%w: bad activity time %q %q", ErrPayload, a.GMTDate, a.GMTTime)
+		}
+
+		updates = append(updates, Update{
+			Carrier:        "ups",
+			TrackingNumber: p.TrackingNumber,
+			Status:         status,
+			Description:    a.Status.Description,
+			Location:       location(a.Location.City, a.Location.Country),
+			At:             at,
+		})
+	}
+
+	return updates, nil
+}
+
+func location(parts ...string) string {
+
+	var s []string
+	for _, p := range parts {
+		if p != "" {
+			s = append(s, p)
+		}
+	}
+
+	return strings.Join(s, ", ")
+}
@@ -0,0 +1,79 @@
+package catalog
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/google/uuid"
+)
+
+type Item struct {
+	ItemID       uuid.UUID `json:"item_id"`
+	Price        uint      `json:"price"`
+	Discontinued bool      `json:"discontinued,omitempty"`
+}
+
+// Catalog knows the current price of items. Items missing from the result
+// are no longer sold, the same as discontinued ones.
+type Catalog interface {
+	Lookup(ctx context.Context, itemIDs []uuid.UUID) (map[uuid.UUID]Item, error)
+}
+
+// HTTPCatalog posts {"item_ids": [...]} as JSON to URL and expects
+// {"items": [...]} back.
+type HTTPCatalog struct {
+	URL    string
+	Client *http.Client
+}
+
+func (c *HTTPCatalog) httpClient() *http.Client {
+	if c.Client == nil {
+		return http.DefaultClient
+	}
+	return c.Client
+}
+
+func (c *HTTPCatalog) Lookup(ctx context.Context, itemIDs []uuid.UUID) (map[uuid.UUID]Item, error) {
+
+	data, err := json.Marshal(map[string][]uuid.UUID{"item_ids": itemIDs})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode item ids: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.URL, bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to build request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := c.httpClient().Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to call catalog: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(res.Body, 512))
+		return nil, fmt.Errorf("catalog returned %d: %s", res.StatusCode, bytes.TrimSpace(body))
+	}
+
+	var body struct {
+		Items []Item `json:"items"`
+	}
+
+	if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("failed to decode catalog items: %w", err)
+	}
+
+	items := make(map[uuid.UUID]Item, len(body.Items))
+	for _, item := range body.Items {
+		items[item.ItemID] = item
+	}
+
+	return items, nil
+}
@@ -0,0 +1,271 @@
+// Package changefeed records which orders are written on a Redis stream,
+// for read models and sinks kept outside the store to follow. Entries only
+// name the order: consumers load it as it is when they get to it, so
+// several writes to one order between reads come out as one change.
+package changefeed
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/i101dev/microservices-NN/model"
+	"github.com/i101dev/microservices-NN/repository/order"
+	"github.com/redis/go-redis/v9"
+)
+
+const DefaultStream = "changefeed:orders"
+
+// Feed is the interceptor that adds an entry to the stream for every order
+// written or deleted. The stream is trimmed to about MaxLen entries, so a
+// consumer that falls further behind than that misses changes.
+type Feed struct {
+	Client *redis.Client
+	Stream string
+	// MaxLen zero means a million.
+	MaxLen int64
+}
+
+func stream(name string) string {
+	if name == "" {
+		return DefaultStream
+	}
+	return name
+}
+
+// Intercept adds the orders a write touched to the feed. Failing to is
+// logged but never fails the write.
+func (f *Feed) Intercept(ctx context.Context, call *order.Call, next order.Handler) error {
+
+	if err := next(ctx, call); err != nil {
+		return err
+	}
+
+	var ids []uint64
+
+	switch call.Op {
+	case order.OpInsert, order.OpUpdate:
+		ids = []uint64{call.Order.OrderID}
+	case order.OpInsertAll:
+		for _, o := range call.Orders {
+			ids = append(ids, o.OrderID)
+		}
+	case order.OpApply:
+		for _, o := range append(append([]model.Order{}, call.Batch.Insert...), call.Batch.Update...) {
+			ids = append(ids, o.OrderID)
+		}
+	case order.OpDeleteByID:
+		ids = []uint64{call.ID}
+	}
+
+	if len(ids) == 0 {
+		return nil
+	}
+
+	maxLen := f.MaxLen
+	if maxLen <= 0 {
+		maxLen = 1_000_000
+	}
+
+	pipe := f.Client.Pipeline()
+
+	for _, id := range ids {
+		pipe.XAdd(ctx, &redis.XAddArgs{
+			Stream: stream(f.Stream),
+			MaxLen: maxLen,
+			Approx: true,
+			Values: map[string]any{"order_id": id},
+		})
+	}
+
+	if _, err := pipe.Exec(ctx); err != nil {
+		fmt.Println("failed to add orders to the changefeed:", err)
+	}
+
+	return nil
+}
+
+// Change is an order written since the consumer last read the feed. ID is
+// the stream entry of the latest write to it.
+type Change struct {
+	ID      string
+	OrderID uint64
+	// Order is the order as it is now, or nil if it was deleted.
+	Order *model.Order
+}
+
+// Consumer reads the feed in its own group, from the oldest entry kept
+// when the group is new, and hands the changes to Handle in batches. A
+// batch Handle fails is left pending and retried once it has been idle for
+// Retry, so every change is handled at least once.
+type Consumer struct {
+	Client *redis.Client
+	Stream string
+	Group  string
+	Repo   order.Repository
+	// BatchSize is the most changes in one batch. Zero means 500.
+	BatchSize int
+	// Interval is the longest a change waits for its batch to fill. Zero
+	// means 10 seconds.
+	Interval time.Duration
+	// Retry zero means a minute.
+	Retry  time.Duration
+	Handle func(ctx context.Context, changes []Change) error
+}
+
+func (c *Consumer) batchSize() int {
+	if c.BatchSize <= 0 {
+		return 500
+	}
+	return c.BatchSize
+}
+
+func (c *Consumer) interval() time.Duration {
+	if c.Interval <= 0 {
+		return 10 * time.Second
+	}
+	return c.Interval
+}
+
+func (c *Consumer) retry() time.Duration {
+	if c.Retry <= 0 {
+		return time.Minute
+	}
+	return c.Retry
+}
+
+// Run consumes the feed until ctx is cancelled.
+func (c *Consumer) Run(ctx context.Context) error {
+
+	err := c.Client.XGroupCreateMkStream(ctx, stream(c.Stream), c.Group, "0").Err()
+	if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return fmt.Errorf("failed to create consumer group: %w", err)
+	}
+
+	consumer := uuid.NewString()
+
+	for ctx.Err() == nil {
+
+		claimed, _, err := c.Client.XAutoClaim(ctx, &redis.XAutoClaimArgs{
+			Stream:   stream(c.Stream),
+			Group:    c.Group,
+			Consumer: consumer,
+			MinIdle:  c.retry(),
+			Start:    "0-0",
+			Count:    int64(c.batchSize()),
+		}).Result()
+
+		if err != nil && ctx.Err() == nil {
+			fmt.Printf("failed to claim %s changes: %v\n", c.Group, err)
+		}
+
+		if len(claimed) > 0 {
+			c.process(ctx, claimed)
+		}
+
+		if batch := c.collect(ctx, consumer); len(batch) > 0 {
+			c.process(ctx, batch)
+		}
+	}
+
+	return nil
+}
+
+// collect reads new entries until it has a full batch or Interval has
+// passed.
+func (c *Consumer) collect(ctx context.Context, consumer string) []redis.XMessage {
+
+	var batch []redis.XMessage
+
+	deadline := time.Now().Add(c.interval())
+
+	for len(batch) < c.batchSize() {
+
+		// A block of zero would wait forever.
+		block := time.Until(deadline)
+		if block < time.Millisecond {
+			break
+		}
+
+		streams, err := c.Client.XReadGroup(ctx, &redis.XReadGroupArgs{
+			Group:    c.Group,
+			Consumer: consumer,
+			Streams:  []string{stream(c.Stream), ">"},
+			Count:    int64(c.batchSize() - len(batch)),
+			Block:    block,
+		}).Result()
+
+		if errors.Is(err, redis.Nil) {
+			break
+		} else if err != nil {
+			if ctx.Err() == nil {
+				fmt.Printf("failed to read %s changes: %v\n", c.Group, err)
+				time.Sleep(time.Second)
+			}
+			break
+		}
+
+		for _, s := range streams {
+			batch = append(batch, s.Messages...)
+		}
+	}
+
+	return batch
+}
+
+// process hands Handle one change per order in msgs, and acknowledges
+// them all once it succeeds.
+func (c *Consumer) process(ctx context.Context, msgs []redis.XMessage) {
+
+	last := map[uint64]string{}
+	var ids []uint64
+
+	for _, msg := range msgs {
+		s, _ := msg.Values["order_id"].(string)
+		id, err := strconv.ParseUint(s, 10, 64)
+		if err != nil {
+			fmt.Printf("skipping malformed change %s\n", msg.ID)
+			continue
+		}
+		if _, ok := last[id]; !ok {
+			ids = append(ids, id)
+		}
+		last[id] = msg.ID
+	}
+
+	changes := make([]Change, 0, len(ids))
+
+	for _, id := range ids {
+		change := Change{ID: last[id], OrderID: id}
+
+		o, err := c.Repo.FindByID(ctx, id)
+		if err == nil {
+			change.Order = &o
+		} else if !errors.Is(err, order.ErrNotExist) {
+			fmt.Printf("failed to load order %d for %s, retrying later: %v\n", id, c.Group, err)
+			return
+		}
+
+		changes = append(changes, change)
+	}
+
+	if len(changes) > 0 {
+		if err := c.Handle(ctx, changes); err != nil {
+			fmt.Printf("failed to handle %d %s changes, retrying later: %v\n", len(changes), c.Group, err)
+			return
+		}
+	}
+
+	acks := make([]string, len(msgs))
+	for i, msg := range msgs {
+		acks[i] = msg.ID
+	}
+
+	if err := c.Client.XAck(ctx, stream(c.Stream), c.Group, acks...).Err(); err != nil {
+		fmt.Printf("failed to acknowledge %s changes: %v\n", c.Group, err)
+	}
+}
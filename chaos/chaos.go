@@ -0,0 +1,184 @@
+package chaos
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+)
+
+var ErrInjected = errors.New("chaos: injected failure")
+
+type Faults struct {
+	LatencyPercent float64 `json:"latency_percent"`
+	LatencyMS      int     `json:"latency_ms"`
+	ErrorPercent   float64 `json:"error_percent"`
+	DropPercent    float64 `json:"drop_percent,omitempty"`
+}
+
+type Settings struct {
+	Enabled     bool   `json:"enabled"`
+	ErrorStatus int    `json:"error_status"`
+	HTTP        Faults `json:"http"`
+	Repository  Faults `json:"repository"`
+}
+
+type Controller struct {
+	mu       sync.RWMutex
+	settings Settings
+}
+
+func NewController() *Controller {
+	return &Controller{
+		settings: Settings{
+			ErrorStatus: http.StatusServiceUnavailable,
+		},
+	}
+}
+
+func (c *Controller) Settings() Settings {
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.settings
+}
+
+func (c *Controller) SetSettings(s Settings) error {
+
+	for _, f := range []Faults{s.HTTP, s.Repository} {
+		for _, p := range []float64{f.LatencyPercent, f.ErrorPercent, f.DropPercent} {
+			if p < 0 || p > 100 {
+				return fmt.Errorf("percentages must be between 0 and 100, got %v", p)
+			}
+		}
+		if f.LatencyMS < 0 {
+			return fmt.Errorf("latency_ms must not be negative")
+		}
+	}
+
+	if s.Repository.DropPercent != 0 {
+		return fmt.Errorf("drop_percent only applies to http")
+	}
+
+	if s.ErrorStatus == 0 {
+		s.ErrorStatus = http.StatusServiceUnavailable
+	} else if s.ErrorStatus < 400 || s.ErrorStatus > 599 {
+		return fmt.Errorf("error_status must be a 4xx or 5xx code")
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.settings = s
+
+	return nil
+}
+
+func (c *Controller) Middleware(next http.Handler) http.Handler {
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		s := c.Settings()
+
+		if !s.Enabled {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if hit(s.HTTP.DropPercent) {
+			drop(w)
+			return
+		}
+
+		if err := delay(r.Context(), s.HTTP); err != nil {
+			return
+		}
+
+		if hit(s.HTTP.ErrorPercent) {
+			http.Error(w, ErrInjected.Error(), s.ErrorStatus)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (c *Controller) ServeSettings(w http.ResponseWriter, r *http.Request) {
+
+	if r.Method == http.MethodPut {
+
+		var s Settings
+
+		if err := json.NewDecoder(r.Body).Decode(&s); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		if err := c.SetSettings(s); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		fmt.Printf("chaos settings changed: %+v\n", c.Settings())
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(c.Settings())
+}
+
+func (c *Controller) inject(ctx context.Context) error {
+
+	s := c.Settings()
+
+	if !s.Enabled {
+		return nil
+	}
+
+	if err := delay(ctx, s.Repository); err != nil {
+		return err
+	}
+
+	if hit(s.Repository.ErrorPercent) {
+		return ErrInjected
+	}
+
+	return nil
+}
+
+func hit(percent float64) bool {
+	return percent > 0 && rand.Float64()*100 < percent
+}
+
+func delay(ctx context.Context, f Faults) error {
+
+	if f.LatencyMS <= 0 || !hit(f.LatencyPercent) {
+		return nil
+	}
+
+	timer := time.NewTimer(time.Duration(f.LatencyMS) * time.Millisecond)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+func drop(w http.ResponseWriter) {
+
+	if hj, ok := w.(http.Hijacker); ok {
+		if conn, _, err := hj.Hijack(); err == nil {
+			conn.Close()
+			return
+		}
+	}
+
+	panic(http.ErrAbortHandler)
+}
@@ -0,0 +1,17 @@
+package chaos
+
+import (
+	"context"
+
+	"github.com/i101dev/microservices-NN/repository/order"
+)
+
+// Intercept is an order.Interceptor that injects the repository faults.
+func (c *Controller) Intercept(ctx context.Context, call *order.Call, next order.Handler) error {
+
+	if err := c.inject(ctx); err != nil {
+		return err
+	}
+
+	return next(ctx, call)
+}
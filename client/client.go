@@ -0,0 +1,207 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/i101dev/microservices-NN/transport"
+)
+
+var ErrNotFound = errors.New("order not found")
+
+type Error struct {
+	StatusCode int
+	Body       string
+	// RetryAfter is how long the server asked to wait before trying again,
+	// if it said.
+	RetryAfter time.Duration
+}
+
+func (e *Error) Error() string {
+	if e.Body == "" {
+		return fmt.Sprintf("orders api returned %d", e.StatusCode)
+	}
+	return fmt.Sprintf("orders api returned %d: %s", e.StatusCode, e.Body)
+}
+
+// Client calls the orders API. Idempotent requests are retried up to
+// MaxRetries times on network errors and server errors, with exponential
+// backoff starting at Backoff. Requests the server turned away with a 429
+// are retried whatever their method, since they were never handled. A
+// Retry-After from the server replaces the backoff when it is longer, and
+// a request it asks to wait more than MaxRetryWait for is not retried.
+type Client struct {
+	BaseURL      string
+	HTTPClient   *http.Client
+	MaxRetries   int
+	Backoff      time.Duration
+	MaxRetryWait time.Duration
+}
+
+func New(baseURL string) *Client {
+	return newClient(baseURL, transport.Pooled(32, 90*time.Second))
+}
+
+// NewH2C returns a client that talks HTTP/2 without TLS, for services
+// started with H2C_ENABLED. baseURL keeps its http scheme.
+func NewH2C(baseURL string) *Client {
+	return newClient(baseURL, transport.H2C(90*time.Second))
+}
+
+func newClient(baseURL string, rt http.RoundTripper) *Client {
+	return &Client{
+		BaseURL:      strings.TrimRight(baseURL, "/"),
+		HTTPClient:   &http.Client{Timeout: 10 * time.Second, Transport: rt},
+		MaxRetries:   3,
+		Backoff:      100 * time.Millisecond,
+		MaxRetryWait: 30 * time.Second,
+	}
+}
+
+func (c *Client) do(ctx context.Context, method, path string, body, out any) error {
+
+	var payload []byte
+
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("failed to encode request body: %w", err)
+		}
+		payload = data
+	}
+
+	var lastErr error
+	var wait time.Duration
+
+	for attempt := 0; attempt <= c.MaxRetries; attempt++ {
+
+		if attempt > 0 {
+			if err := sleep(ctx, max(wait, c.Backoff<<(attempt-1))); err != nil {
+				return err
+			}
+		}
+
+		retry, err := c.send(ctx, method, path, payload, out)
+		if err == nil {
+			return nil
+		}
+
+		lastErr = err
+		wait = 0
+		rejected := false
+
+		var apiErr *Error
+		if errors.As(err, &apiErr) {
+			wait = apiErr.RetryAfter
+			rejected = apiErr.StatusCode == http.StatusTooManyRequests
+		}
+
+		if !rejected && !(retry && idempotent(method)) {
+			break
+		}
+
+		if c.MaxRetryWait > 0 && wait > c.MaxRetryWait {
+			break
+		}
+	}
+
+	return lastErr
+}
+
+func (c *Client) send(ctx context.Context, method, path string, payload []byte, out any) (bool, error) {
+
+	req, err := http.NewRequestWithContext(ctx, method, c.BaseURL+path, bytes.NewReader(payload))
+	if err != nil {
+		return false, fmt.Errorf("failed to build request: %w", err)
+	}
+
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Accept", "application/json")
+
+	res, err := c.httpClient().Do(req)
+	if err != nil {
+		return ctx.Err() == nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusNotFound {
+		return false, ErrNotFound
+	}
+
+	if res.StatusCode >= http.StatusBadRequest {
+		data, _ := io.ReadAll(io.LimitReader(res.Body, 4096))
+		return res.StatusCode >= http.StatusInternalServerError, &Error{
+			StatusCode: res.StatusCode,
+			Body:       strings.TrimSpace(string(data)),
+			RetryAfter: retryAfter(res.Header.Get("Retry-After")),
+		}
+	}
+
+	if out == nil {
+		return false, nil
+	}
+
+	if err := json.NewDecoder(res.Body).Decode(out); err != nil {
+		return false, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return false, nil
+}
+
+func (c *Client) httpClient() *http.Client {
+	if c.HTTPClient == nil {
+		return http.DefaultClient
+	}
+	return c.HTTPClient
+}
+
+func idempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodDelete:
+		return true
+	default:
+		return false
+	}
+}
+
+// retryAfter reads a Retry-After value, given either in seconds or as an
+// HTTP date.
+func retryAfter(v string) time.Duration {
+
+	if v == "" {
+		return 0
+	}
+
+	if seconds, err := strconv.Atoi(v); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+
+	if at, err := http.ParseTime(v); err == nil {
+		return max(time.Until(at), 0)
+	}
+
+	return 0
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
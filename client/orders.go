@@ -0,0 +1,162 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/google/uuid"
+	"github.com/i101dev/microservices-NN/model"
+)
+
+type CreateOrderRequest struct {
+	CustomerID uuid.UUID        `json:"customer_id"`
+	LineItems  []model.LineItem `json:"line_items"`
+}
+
+type OrderPage struct {
+	Items []model.Order `json:"items"`
+	Next  uint64        `json:"next,omitempty"`
+}
+
+func (c *Client) CreateOrder(ctx context.Context, req CreateOrderRequest) (model.Order, error) {
+
+	var o model.Order
+
+	if err := c.do(ctx, http.MethodPost, "/orders", req, &o); err != nil {
+		return model.Order{}, err
+	}
+
+	return o, nil
+}
+
+func (c *Client) GetOrder(ctx context.Context, id uint64) (model.Order, error) {
+
+	var o model.Order
+
+	if err := c.do(ctx, http.MethodGet, orderPath(id), nil, &o); err != nil {
+		return model.Order{}, err
+	}
+
+	return o, nil
+}
+
+func (c *Client) ListOrders(ctx context.Context, cursor uint64) (OrderPage, error) {
+
+	query := url.Values{}
+	query.Set("cursor", strconv.FormatUint(cursor, 10))
+
+	var page OrderPage
+
+	if err := c.do(ctx, http.MethodGet, "/orders?"+query.Encode(), nil, &page); err != nil {
+		return OrderPage{}, err
+	}
+
+	return page, nil
+}
+
+// OrderIterator walks every order, fetching pages as it goes:
+//
+//	it := c.ListOrdersIterator(ctx)
+//	for it.Next() {
+//		o := it.Order()
+//	}
+//	if err := it.Err(); err != nil {
+type OrderIterator struct {
+	ctx    context.Context
+	client *Client
+	page   []model.Order
+	cursor uint64
+	order  model.Order
+	done   bool
+	err    error
+}
+
+func (c *Client) ListOrdersIterator(ctx context.Context) *OrderIterator {
+	return &OrderIterator{ctx: ctx, client: c}
+}
+
+// Next advances to the next order, fetching the next page when the current
+// one runs out. It returns false at the end or on the first error.
+func (it *OrderIterator) Next() bool {
+
+	for len(it.page) == 0 {
+		if it.done || it.err != nil {
+			return false
+		}
+
+		page, err := it.client.ListOrders(it.ctx, it.cursor)
+		if err != nil {
+			it.err = err
+			return false
+		}
+
+		it.page = page.Items
+		it.cursor = page.Next
+		it.done = page.Next == 0
+	}
+
+	it.order, it.page = it.page[0], it.page[1:]
+
+	return true
+}
+
+func (it *OrderIterator) Order() model.Order {
+	return it.order
+}
+
+func (it *OrderIterator) Err() error {
+	return it.err
+}
+
+func (c *Client) EachOrder(ctx context.Context, fn func(model.Order) error) error {
+
+	it := c.ListOrdersIterator(ctx)
+
+	for it.Next() {
+		if err := fn(it.Order()); err != nil {
+			return err
+		}
+	}
+
+	return it.Err()
+}
+
+func (c *Client) UpdateOrderStatus(ctx context.Context, id uint64, status string) (model.Order, error) {
+
+	body := struct {
+		Status string `json:"status"`
+	}{
+		Status: status,
+	}
+
+	var o model.Order
+
+	if err := c.do(ctx, http.MethodPut, orderPath(id), body, &o); err != nil {
+		return model.Order{}, err
+	}
+
+	return o, nil
+}
+
+func (c *Client) ShipOrder(ctx context.Context, id uint64) (model.Order, error) {
+	return c.UpdateOrderStatus(ctx, id, model.StatusShipped)
+}
+
+func (c *Client) CompleteOrder(ctx context.Context, id uint64) (model.Order, error) {
+	return c.UpdateOrderStatus(ctx, id, model.StatusCompleted)
+}
+
+func (c *Client) CancelOrder(ctx context.Context, id uint64) (model.Order, error) {
+	return c.UpdateOrderStatus(ctx, id, model.StatusCancelled)
+}
+
+func (c *Client) DeleteOrder(ctx context.Context, id uint64) error {
+	return c.do(ctx, http.MethodDelete, orderPath(id), nil, nil)
+}
+
+func orderPath(id uint64) string {
+	return fmt.Sprintf("/orders/%d", id)
+}
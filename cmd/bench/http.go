@@ -0,0 +1,115 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/i101dev/microservices-NN/transport"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+// httpBenchmarks compare the transports internal callers can use against
+// a local server answering with one encoded order, with 32 requests in
+// flight per GOMAXPROCS. With GOMAXPROCS=4 (128 in flight) on a one core
+// linux/amd64 machine they gave:
+//
+//	HTTP/default-2   68-76µs/op   0.26-0.29 conns/op   p50 2.9-4.9ms   p99 49-51ms
+//	HTTP/pooled-32   43-44µs/op   0.02-0.03 conns/op   p50 2.3ms       p99 28-32ms
+//	HTTP/h2c         49-51µs/op   <0.0001 conns/op     p50 5.7-5.9ms   p99 17ms
+//
+// The standard transport keeps only two idle connections per host, so
+// under concurrency a quarter of the requests dial a new one; pooling
+// nearly removes that. h2c puts everything on one connection, trading some
+// median latency for a much shorter tail. At GOMAXPROCS=1 the three are
+// within noise of each other, since few requests overlap.
+func httpBenchmarks() []benchmark {
+
+	body, err := json.Marshal(newOrder(5))
+	if err != nil {
+		panic(err)
+	}
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(body)
+	})
+
+	transports := []struct {
+		name string
+		rt   func() http.RoundTripper
+	}{
+		{"default-2", func() http.RoundTripper { return http.DefaultTransport.(*http.Transport).Clone() }},
+		{"pooled-32", func() http.RoundTripper { return transport.Pooled(32, 90*time.Second) }},
+		{"h2c", func() http.RoundTripper { return transport.H2C(90 * time.Second) }},
+	}
+
+	var benchmarks []benchmark
+
+	for _, t := range transports {
+		t := t
+		benchmarks = append(benchmarks, benchmark{
+			name: "HTTP/" + t.name,
+			fn: func(b *testing.B) {
+				var conns atomic.Int64
+
+				srv := httptest.NewUnstartedServer(h2c.NewHandler(handler, &http2.Server{}))
+				srv.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+					if state == http.StateNew {
+						conns.Add(1)
+					}
+				}
+				srv.Start()
+				defer srv.Close()
+
+				client := &http.Client{Transport: t.rt()}
+				defer client.CloseIdleConnections()
+
+				var mu sync.Mutex
+				var latencies []time.Duration
+
+				b.SetParallelism(32)
+				b.ReportAllocs()
+				b.ResetTimer()
+
+				b.RunParallel(func(pb *testing.PB) {
+					var own []time.Duration
+					for pb.Next() {
+						start := time.Now()
+						res, err := client.Get(srv.URL)
+						if err != nil {
+							b.Error(err)
+							return
+						}
+						io.Copy(io.Discard, res.Body)
+						res.Body.Close()
+						own = append(own, time.Since(start))
+					}
+					mu.Lock()
+					latencies = append(latencies, own...)
+					mu.Unlock()
+				})
+
+				b.StopTimer()
+
+				b.ReportMetric(float64(conns.Load())/float64(b.N), "conns/op")
+
+				if len(latencies) > 0 {
+					slices.Sort(latencies)
+					b.ReportMetric(float64(latencies[len(latencies)/2].Microseconds()), "p50-µs")
+					b.ReportMetric(float64(latencies[len(latencies)*99/100].Microseconds()), "p99-µs")
+				}
+			},
+		})
+	}
+
+	return benchmarks
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"math/rand"
+	"os"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/i101dev/microservices-NN/codec"
+	"github.com/i101dev/microservices-NN/model"
+	"github.com/i101dev/microservices-NN/repository/order"
+	"github.com/redis/go-redis/v9"
+)
+
+type benchmark struct {
+	name string
+	fn   func(b *testing.B)
+}
+
+func main() {
+
+	testing.Init()
+
+	redisAddr := flag.String("redis", "localhost:6379", "redis used for repository benchmarks, empty to skip them")
+	db := flag.Int("db", 15, "redis database, flushed before and after each repository benchmark")
+	force := flag.Bool("force", false, "allow flushing database 0")
+	count := flag.Int("count", 1, "run each benchmark this many times")
+	benchtime := flag.String("benchtime", "1s", "passed through to the testing package")
+	flag.Parse()
+
+	if err := flag.Set("test.benchtime", *benchtime); err != nil {
+		fmt.Println("invalid benchtime:", err)
+		os.Exit(2)
+	}
+
+	benchmarks := append(codecBenchmarks(), httpBenchmarks()...)
+
+	if *redisAddr != "" {
+
+		if *db == 0 && !*force {
+			fmt.Println("refusing to flush redis database 0 without -force")
+			os.Exit(2)
+		}
+
+		client := redis.NewClient(&redis.Options{
+			Addr: *redisAddr,
+			DB:   *db,
+		})
+
+		if err := client.Ping(context.Background()).Err(); err != nil {
+			fmt.Println("failed to connect redis:", err)
+			os.Exit(1)
+		}
+
+		benchmarks = append(benchmarks, repositoryBenchmarks(client)...)
+	}
+
+	fmt.Printf("goos: %s\ngoarch: %s\npkg: github.com/i101dev/microservices-NN/cmd/bench\n", runtime.GOOS, runtime.GOARCH)
+
+	for i := 0; i < *count; i++ {
+		for _, bm := range benchmarks {
+			res := testing.Benchmark(bm.fn)
+			fmt.Printf("Benchmark%s-%d\t%s\t%s\n", bm.name, runtime.GOMAXPROCS(0), res.String(), res.MemString())
+		}
+	}
+}
+
+func newOrder(lineItems int) model.Order {
+
+	now := time.Now().UTC()
+
+	o := model.Order{
+		OrderID:    rand.Uint64(),
+		CustomerID: uuid.New(),
+		LineItems:  make([]model.LineItem, lineItems),
+		CreatedAt:  &now,
+	}
+
+	for i := range o.LineItems {
+		o.LineItems[i] = model.LineItem{
+			ItemID:   uuid.New(),
+			Quantity: uint(1 + rand.Intn(5)),
+			Price:    uint(100 + rand.Intn(10000)),
+		}
+	}
+
+	return o
+}
+
+func codecBenchmarks() []benchmark {
+
+	var benchmarks []benchmark
+
+	o := newOrder(5)
+
+	for _, c := range []codec.Codec{codec.JSON, codec.MsgPack, codec.Protobuf} {
+
+		c := c
+		data, err := c.Marshal(o)
+		if err != nil {
+			panic(err)
+		}
+
+		benchmarks = append(benchmarks,
+			benchmark{
+				name: "Codec/" + c.Name() + "/Marshal",
+				fn: func(b *testing.B) {
+					b.ReportAllocs()
+					b.ReportMetric(float64(len(data)), "bytes/order")
+					for i := 0; i < b.N; i++ {
+						if _, err := c.Marshal(o); err != nil {
+							b.Fatal(err)
+						}
+					}
+				},
+			},
+			benchmark{
+				name: "Codec/" + c.Name() + "/Unmarshal",
+				fn: func(b *testing.B) {
+					b.ReportAllocs()
+					for i := 0; i < b.N; i++ {
+						var out model.Order
+						if err := c.Unmarshal(data, &out); err != nil {
+							b.Fatal(err)
+						}
+					}
+				},
+			},
+		)
+	}
+
+	return benchmarks
+}
+
+func repositoryBenchmarks(client *redis.Client) []benchmark {
+
+	ctx := context.Background()
+
+	flush := func(b *testing.B) {
+		if err := client.FlushDB(ctx).Err(); err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	var benchmarks []benchmark
+
+	for _, c := range []codec.Codec{codec.JSON, codec.MsgPack} {
+
+		repo := &order.RedisRepo{Client: client, Codec: c}
+
+		benchmarks = append(benchmarks, benchmark{
+			name: "Insert/" + c.Name() + "/single",
+			fn: func(b *testing.B) {
+				flush(b)
+				defer flush(b)
+				orders := make([]model.Order, b.N)
+				for i := range orders {
+					orders[i] = newOrder(3)
+				}
+				b.ReportAllocs()
+				b.ResetTimer()
+				for i := range orders {
+					if err := repo.Insert(ctx, orders[i]); err != nil {
+						b.Fatal(err)
+					}
+				}
+			},
+		})
+
+		for _, batch := range []int{10, 100} {
+			batch := batch
+			benchmarks = append(benchmarks, benchmark{
+				name: fmt.Sprintf("Insert/%s/pipelined-%d", c.Name(), batch),
+				fn: func(b *testing.B) {
+					flush(b)
+					defer flush(b)
+					orders := make([]model.Order, b.N)
+					for i := range orders {
+						orders[i] = newOrder(3)
+					}
+					b.ReportAllocs()
+					b.ResetTimer()
+					for start := 0; start < len(orders); start += batch {
+						end := min(start+batch, len(orders))
+						if err := repo.InsertMany(ctx, orders[start:end]); err != nil {
+							b.Fatal(err)
+						}
+					}
+				},
+			})
+		}
+	}
+
+	const stored = 5000
+	repo := &order.RedisRepo{Client: client}
+
+	for _, size := range []uint64{10, 50, 200, 1000} {
+		size := size
+		benchmarks = append(benchmarks, benchmark{
+			name: fmt.Sprintf("FindAll/scan-%d/size-%d", stored, size),
+			fn: func(b *testing.B) {
+				flush(b)
+				defer flush(b)
+				orders := make([]model.Order, stored)
+				for i := range orders {
+					orders[i] = newOrder(3)
+				}
+				if err := repo.InsertMany(ctx, orders); err != nil {
+					b.Fatal(err)
+				}
+				b.ReportAllocs()
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					pages := 0
+					err := order.ForEachPage(ctx, repo, size, func([]model.Order) error {
+						pages++
+						return nil
+					})
+					if err != nil {
+						b.Fatal(err)
+					}
+					b.ReportMetric(float64(pages), "pages/scan")
+				}
+			},
+		})
+	}
+
+	return benchmarks
+}
@@ -0,0 +1,444 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+
+	"github.com/i101dev/microservices-NN/migration"
+	"github.com/i101dev/microservices-NN/model"
+	"github.com/i101dev/microservices-NN/orderindex"
+	"github.com/i101dev/microservices-NN/search"
+	"github.com/i101dev/microservices-NN/sink"
+	"github.com/spf13/cobra"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+)
+
+func newGetCmd(opts *options) *cobra.Command {
+
+	return &cobra.Command{
+		Use:   "get <id>",
+		Short: "Print a single order",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+
+			id, err := parseID(args[0])
+			if err != nil {
+				return err
+			}
+
+			s, err := opts.store()
+			if err != nil {
+				return err
+			}
+
+			o, err := s.Get(cmd.Context(), id)
+			if err != nil {
+				return err
+			}
+
+			return printJSON(cmd.OutOrStdout(), o)
+		},
+	}
+}
+
+func newListCmd(opts *options) *cobra.Command {
+
+	var cursor uint64
+
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "Print one page of orders",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+
+			s, err := opts.store()
+			if err != nil {
+				return err
+			}
+
+			page, err := s.List(cmd.Context(), cursor)
+			if err != nil {
+				return err
+			}
+
+			return printJSON(cmd.OutOrStdout(), page)
+		},
+	}
+
+	cmd.Flags().Uint64Var(&cursor, "cursor", 0, "cursor returned by a previous page")
+
+	return cmd
+}
+
+func newDeleteCmd(opts *options) *cobra.Command {
+
+	return &cobra.Command{
+		Use:   "delete <id>...",
+		Short: "Delete orders by ID",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+
+			s, err := opts.store()
+			if err != nil {
+				return err
+			}
+
+			for _, arg := range args {
+				id, err := parseID(arg)
+				if err != nil {
+					return err
+				}
+
+				if err := s.Delete(cmd.Context(), id); err != nil {
+					return fmt.Errorf("failed to delete %d: %w", id, err)
+				}
+
+				fmt.Fprintln(cmd.OutOrStdout(), "deleted", id)
+			}
+
+			return nil
+		},
+	}
+}
+
+func newRebuildIndexesCmd(opts *options) *cobra.Command {
+
+	return &cobra.Command{
+		Use:   "rebuild-indexes",
+		Short: "Rebuild the orders set from the stored order keys, and the status, customer and tag indexes from the orders",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+
+			repo, err := opts.repo()
+			if err != nil {
+				return err
+			}
+
+			added, removed, err := repo.RebuildIndex(cmd.Context())
+			if err != nil {
+				return err
+			}
+
+			fmt.Fprintf(cmd.OutOrStdout(), "added %d, removed %d stale entries\n", added, removed)
+
+			index := &orderindex.Index{Client: repo.Client}
+
+			indexed, err := index.Rebuild(cmd.Context(), repo)
+			if err != nil {
+				return err
+			}
+
+			fmt.Fprintf(cmd.OutOrStdout(), "indexed %d orders\n", indexed)
+
+			return nil
+		},
+	}
+}
+
+func newExportCmd(opts *options) *cobra.Command {
+
+	var path string
+
+	cmd := &cobra.Command{
+		Use:   "export",
+		Short: "Write every order as NDJSON",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+
+			s, err := opts.store()
+			if err != nil {
+				return err
+			}
+
+			out := cmd.OutOrStdout()
+
+			if path != "-" {
+				f, err := os.Create(path)
+				if err != nil {
+					return fmt.Errorf("failed to create snapshot: %w", err)
+				}
+				defer f.Close()
+				out = f
+			}
+
+			w := bufio.NewWriter(out)
+			enc := json.NewEncoder(w)
+
+			var n int
+			err = s.Each(cmd.Context(), func(o model.Order) error {
+				n++
+				return enc.Encode(o)
+			})
+
+			if err != nil {
+				return err
+			}
+
+			if err := w.Flush(); err != nil {
+				return fmt.Errorf("failed to write snapshot: %w", err)
+			}
+
+			fmt.Fprintf(cmd.ErrOrStderr(), "exported %d orders\n", n)
+
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&path, "output", "o", "-", "snapshot file, - for stdout")
+
+	return cmd
+}
+
+func newImportCmd(opts *options) *cobra.Command {
+
+	var path string
+
+	cmd := &cobra.Command{
+		Use:   "import",
+		Short: "Load an NDJSON snapshot, keeping orders that already exist",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+
+			repo, err := opts.repo()
+			if err != nil {
+				return err
+			}
+
+			var in io.Reader = cmd.InOrStdin()
+
+			if path != "-" {
+				f, err := os.Open(path)
+				if err != nil {
+					return fmt.Errorf("failed to open snapshot: %w", err)
+				}
+				defer f.Close()
+				in = f
+			}
+
+			dec := json.NewDecoder(in)
+
+			var n int
+			for {
+				var o model.Order
+
+				if err := dec.Decode(&o); err == io.EOF {
+					break
+				} else if err != nil {
+					return fmt.Errorf("failed to decode snapshot entry %d: %w", n+1, err)
+				}
+
+				if err := repo.Insert(cmd.Context(), o); err != nil {
+					return fmt.Errorf("failed to import %d: %w", o.OrderID, err)
+				}
+				n++
+			}
+
+			fmt.Fprintf(cmd.ErrOrStderr(), "imported %d orders\n", n)
+
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&path, "input", "i", "-", "snapshot file, - for stdin")
+
+	return cmd
+}
+
+func newStatsCmd(opts *options) *cobra.Command {
+
+	return &cobra.Command{
+		Use:   "stats",
+		Short: "Count orders by status",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+
+			s, err := opts.store()
+			if err != nil {
+				return err
+			}
+
+			stats := struct {
+				Total     int            `json:"total"`
+				ByStatus  map[string]int `json:"by_status"`
+				LineItems int            `json:"line_items"`
+				Revenue   uint64         `json:"revenue"`
+			}{
+				ByStatus: map[string]int{},
+			}
+
+			err = s.Each(cmd.Context(), func(o model.Order) error {
+				stats.Total++
+				stats.ByStatus[o.Status()]++
+				stats.LineItems += len(o.LineItems)
+				for _, item := range o.LineItems {
+					stats.Revenue += uint64(item.Quantity) * uint64(item.Price)
+				}
+				return nil
+			})
+
+			if err != nil {
+				return err
+			}
+
+			return printJSON(cmd.OutOrStdout(), stats)
+		},
+	}
+}
+
+func parseID(arg string) (uint64, error) {
+
+	id, err := strconv.ParseUint(arg, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid order id %q: %w", arg, err)
+	}
+
+	return id, nil
+}
+
+func printJSON(w io.Writer, v any) error {
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+
+	return enc.Encode(v)
+}
+
+func newMigrateCmd(opts *options) *cobra.Command {
+
+	var status bool
+
+	cmd := &cobra.Command{
+		Use:   "migrate",
+		Short: "Apply pending keyspace migrations",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+
+			repo, err := opts.repo()
+			if err != nil {
+				return err
+			}
+
+			migrator := migration.New(repo.Client)
+
+			if status {
+				current, err := migrator.Current(cmd.Context())
+				if err != nil {
+					return err
+				}
+				fmt.Fprintf(cmd.OutOrStdout(), "current %d, latest %d\n", current, migrator.Latest())
+				return nil
+			}
+
+			applied, err := migrator.Up(cmd.Context())
+			for _, m := range applied {
+				fmt.Fprintf(cmd.OutOrStdout(), "applied %d: %s\n", m.Version, m.Name)
+			}
+
+			return err
+		},
+	}
+
+	cmd.Flags().BoolVar(&status, "status", false, "only print the current and latest versions")
+
+	return cmd
+}
+
+func newBackfillSinkCmd(opts *options) *cobra.Command {
+
+	var bq sink.BigQuery
+	var batchSize int
+
+	table := os.Getenv("SINK_BIGQUERY_TABLE")
+	if table == "" {
+		table = "order_mutations"
+	}
+
+	cmd := &cobra.Command{
+		Use:   "backfill-sink",
+		Short: "Write every order to the BigQuery sink table, creating or updating it first",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+
+			repo, err := opts.repo()
+			if err != nil {
+				return err
+			}
+
+			if bq.Dataset == "" {
+				return fmt.Errorf("--bigquery-dataset is required")
+			}
+
+			bq.Client = http.DefaultClient
+
+			tokens, err := google.DefaultTokenSource(cmd.Context(), sink.BigQueryScope)
+			if err != nil && bq.Endpoint == "" {
+				return fmt.Errorf("failed to find google credentials: %w", err)
+			} else if err == nil {
+				bq.Client = oauth2.NewClient(cmd.Context(), tokens)
+			}
+
+			c := &sink.Connector{Warehouse: &bq, BatchSize: batchSize}
+
+			n, err := c.Backfill(cmd.Context(), repo)
+
+			fmt.Fprintf(cmd.OutOrStdout(), "wrote %d orders\n", n)
+
+			return err
+		},
+	}
+
+	cmd.Flags().StringVar(&bq.Project, "bigquery-project", os.Getenv("SINK_BIGQUERY_PROJECT"), "google cloud project of the sink table")
+	cmd.Flags().StringVar(&bq.Dataset, "bigquery-dataset", os.Getenv("SINK_BIGQUERY_DATASET"), "dataset of the sink table")
+	cmd.Flags().StringVar(&bq.Table, "bigquery-table", table, "sink table")
+	cmd.Flags().StringVar(&bq.Endpoint, "bigquery-endpoint", os.Getenv("SINK_BIGQUERY_ENDPOINT"), "bigquery API base URL, for an emulator")
+	cmd.Flags().IntVar(&batchSize, "batch-size", 500, "rows written at once")
+
+	return cmd
+}
+
+func newReindexSearchCmd(opts *options) *cobra.Command {
+
+	index := &search.Index{
+		Username: os.Getenv("OPENSEARCH_USERNAME"),
+		Password: os.Getenv("OPENSEARCH_PASSWORD"),
+	}
+
+	name := os.Getenv("OPENSEARCH_INDEX")
+	if name == "" {
+		name = "orders"
+	}
+
+	cmd := &cobra.Command{
+		Use:   "reindex-search",
+		Short: "Index every order in OpenSearch, creating the index if it is missing",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+
+			repo, err := opts.repo()
+			if err != nil {
+				return err
+			}
+
+			if index.URL == "" {
+				return fmt.Errorf("--opensearch is required")
+			}
+
+			n, err := index.Rebuild(cmd.Context(), repo)
+
+			fmt.Fprintf(cmd.OutOrStdout(), "indexed %d orders\n", n)
+
+			return err
+		},
+	}
+
+	cmd.Flags().StringVar(&index.URL, "opensearch", os.Getenv("OPENSEARCH_URL"), "OpenSearch base URL")
+	cmd.Flags().StringVar(&index.Name, "opensearch-index", name, "index of the orders")
+
+	return cmd
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+
+	"github.com/i101dev/microservices-NN/client"
+	"github.com/i101dev/microservices-NN/codec"
+	"github.com/i101dev/microservices-NN/repository/order"
+	"github.com/redis/go-redis/v9"
+	"github.com/spf13/cobra"
+)
+
+type options struct {
+	redisAddr  string
+	redisCodec string
+	apiURL     string
+}
+
+func main() {
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	if err := newRootCmd().ExecuteContext(ctx); err != nil {
+		os.Exit(1)
+	}
+}
+
+func newRootCmd() *cobra.Command {
+
+	opts := &options{}
+
+	root := &cobra.Command{
+		Use:           "orderctl",
+		Short:         "Operate the order store",
+		SilenceUsage:  true,
+		SilenceErrors: false,
+	}
+
+	root.PersistentFlags().StringVar(&opts.redisAddr, "redis", os.Getenv("REDIS_ADDR"), "talk to redis directly at this address")
+	root.PersistentFlags().StringVar(&opts.redisCodec, "redis-codec", "json", "codec used for values written to redis")
+	root.PersistentFlags().StringVar(&opts.apiURL, "api", "", "talk to the orders HTTP API at this base URL")
+
+	root.AddCommand(
+		newGetCmd(opts),
+		newListCmd(opts),
+		newDeleteCmd(opts),
+		newRebuildIndexesCmd(opts),
+		newExportCmd(opts),
+		newImportCmd(opts),
+		newStatsCmd(opts),
+		newMigrateCmd(opts),
+		newBackfillSinkCmd(opts),
+		newReindexSearchCmd(opts),
+	)
+
+	return root
+}
+
+func (o *options) store() (store, error) {
+
+	if o.apiURL != "" {
+		return &apiStore{client: client.New(o.apiURL)}, nil
+	}
+
+	repo, err := o.repo()
+	if err != nil {
+		return nil, err
+	}
+
+	return &redisStore{repo: repo}, nil
+}
+
+func (o *options) repo() (*order.RedisRepo, error) {
+
+	if o.redisAddr == "" {
+		if o.apiURL != "" {
+			return nil, errRedisOnly
+		}
+		o.redisAddr = "localhost:6379"
+	}
+
+	c, ok := codec.ByName(o.redisCodec)
+	if !ok {
+		return nil, fmt.Errorf("unknown redis codec %q", o.redisCodec)
+	}
+
+	return &order.RedisRepo{
+		Client: redis.NewClient(&redis.Options{
+			Addr: o.redisAddr,
+		}),
+		Codec: c,
+	}, nil
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"errors"
+
+	"github.com/i101dev/microservices-NN/client"
+	"github.com/i101dev/microservices-NN/model"
+	"github.com/i101dev/microservices-NN/repository/order"
+)
+
+var errRedisOnly = errors.New("this command needs direct redis access (--redis)")
+
+type store interface {
+	Get(ctx context.Context, id uint64) (model.Order, error)
+	List(ctx context.Context, cursor uint64) (model.OrderPage, error)
+	Delete(ctx context.Context, id uint64) error
+	Each(ctx context.Context, fn func(model.Order) error) error
+}
+
+type redisStore struct {
+	repo *order.RedisRepo
+}
+
+func (s *redisStore) Get(ctx context.Context, id uint64) (model.Order, error) {
+	return s.repo.FindByID(ctx, id)
+}
+
+func (s *redisStore) List(ctx context.Context, cursor uint64) (model.OrderPage, error) {
+
+	res, err := s.repo.FindAll(ctx, order.FindAllPage{
+		Offset: cursor,
+		Size:   50,
+	})
+
+	if err != nil {
+		return model.OrderPage{}, err
+	}
+
+	return model.OrderPage{
+		Items: res.Orders,
+		Next:  res.Cursor,
+	}, nil
+}
+
+func (s *redisStore) Delete(ctx context.Context, id uint64) error {
+	return s.repo.DeleteByID(ctx, id)
+}
+
+func (s *redisStore) Each(ctx context.Context, fn func(model.Order) error) error {
+
+	return order.ForEachSnapshotPage(ctx, s.repo, 200, func(orders []model.Order) error {
+		for _, o := range orders {
+			if err := fn(o); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+type apiStore struct {
+	client *client.Client
+}
+
+func (s *apiStore) Get(ctx context.Context, id uint64) (model.Order, error) {
+	return s.client.GetOrder(ctx, id)
+}
+
+func (s *apiStore) List(ctx context.Context, cursor uint64) (model.OrderPage, error) {
+
+	page, err := s.client.ListOrders(ctx, cursor)
+	if err != nil {
+		return model.OrderPage{}, err
+	}
+
+	return model.OrderPage{
+		Items: page.Items,
+		Next:  page.Next,
+	}, nil
+}
+
+func (s *apiStore) Delete(ctx context.Context, id uint64) error {
+	return s.client.DeleteOrder(ctx, id)
+}
+
+func (s *apiStore) Each(ctx context.Context, fn func(model.Order) error) error {
+	return s.client.EachOrder(ctx, fn)
+}
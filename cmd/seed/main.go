@@ -0,0 +1,340 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"math/rand"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/i101dev/microservices-NN/client"
+	"github.com/i101dev/microservices-NN/codec"
+	"github.com/i101dev/microservices-NN/model"
+	"github.com/i101dev/microservices-NN/repository/order"
+	"github.com/redis/go-redis/v9"
+)
+
+type options struct {
+	count       int
+	customers   int
+	items       int
+	maxLines    int
+	maxQuantity int
+	statuses    string
+	days        int
+	concurrency int
+	seed        int64
+	redisAddr   string
+	redisCodec  string
+	apiURL      string
+}
+
+type sink interface {
+	Insert(ctx context.Context, o model.Order) error
+}
+
+func main() {
+
+	opts := options{}
+
+	flag.IntVar(&opts.count, "count", 1000, "number of orders to create")
+	flag.IntVar(&opts.customers, "customers", 100, "number of distinct customers")
+	flag.IntVar(&opts.items, "items", 250, "size of the item catalog")
+	flag.IntVar(&opts.maxLines, "max-lines", 5, "maximum line items per order")
+	flag.IntVar(&opts.maxQuantity, "max-quantity", 4, "maximum quantity per line item")
+	flag.StringVar(&opts.statuses, "statuses", "pending=40,shipped=30,completed=25,cancelled=5", "weighted status mix")
+	flag.IntVar(&opts.days, "days", 30, "spread created_at over this many past days")
+	flag.IntVar(&opts.concurrency, "concurrency", 8, "parallel writers")
+	flag.Int64Var(&opts.seed, "seed", time.Now().UnixNano(), "random seed")
+	flag.StringVar(&opts.redisAddr, "redis", "localhost:6379", "write directly to redis at this address")
+	flag.StringVar(&opts.redisCodec, "redis-codec", "json", "codec used for values written to redis")
+	flag.StringVar(&opts.apiURL, "api", "", "create orders through the HTTP API instead of redis")
+	flag.Parse()
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	if err := run(ctx, opts); err != nil {
+		fmt.Println("failed to seed:", err)
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context, opts options) error {
+
+	if opts.customers < 1 || opts.items < 1 || opts.maxLines < 1 || opts.maxQuantity < 1 || opts.days < 1 || opts.concurrency < 1 {
+		return fmt.Errorf("customers, items, max-lines, max-quantity, days and concurrency must be positive")
+	}
+
+	if opts.maxLines > opts.items {
+		opts.maxLines = opts.items
+	}
+
+	weights, err := parseStatuses(opts.statuses)
+	if err != nil {
+		return err
+	}
+
+	s, err := newSink(opts)
+	if err != nil {
+		return err
+	}
+
+	gen := newGenerator(opts, weights)
+	jobs := make(chan model.Order)
+
+	var created, failed atomic.Int64
+	var wg sync.WaitGroup
+
+	for i := 0; i < opts.concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for o := range jobs {
+				if err := s.Insert(ctx, o); err != nil {
+					fmt.Println("failed to create order:", err)
+					failed.Add(1)
+					continue
+				}
+				created.Add(1)
+			}
+		}()
+	}
+
+	start := time.Now()
+
+	for i := 0; i < opts.count && ctx.Err() == nil; i++ {
+		jobs <- gen.order()
+	}
+
+	close(jobs)
+	wg.Wait()
+
+	elapsed := time.Since(start)
+	fmt.Printf("created %d orders (%d failed) in %s, %.0f orders/s\n",
+		created.Load(), failed.Load(), elapsed.Round(time.Millisecond), float64(created.Load())/elapsed.Seconds())
+
+	return ctx.Err()
+}
+
+func newSink(opts options) (sink, error) {
+
+	if opts.apiURL != "" {
+		return &apiSink{client: client.New(opts.apiURL)}, nil
+	}
+
+	c, ok := codec.ByName(opts.redisCodec)
+	if !ok {
+		return nil, fmt.Errorf("unknown redis codec %q", opts.redisCodec)
+	}
+
+	return &order.RedisRepo{
+		Client: redis.NewClient(&redis.Options{
+			Addr: opts.redisAddr,
+		}),
+		Codec: c,
+	}, nil
+}
+
+type apiSink struct {
+	client *client.Client
+}
+
+func (a *apiSink) Insert(ctx context.Context, o model.Order) error {
+
+	created, err := a.client.CreateOrder(ctx, client.CreateOrderRequest{
+		CustomerID: o.CustomerID,
+		LineItems:  o.LineItems,
+	})
+
+	if err != nil {
+		return err
+	}
+
+	for _, status := range transitions(o.Status()) {
+		if _, err := a.client.UpdateOrderStatus(ctx, created.OrderID, status); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func transitions(status string) []string {
+
+	switch status {
+	case model.StatusShipped:
+		return []string{model.StatusShipped}
+	case model.StatusCompleted:
+		return []string{model.StatusShipped, model.StatusCompleted}
+	case model.StatusCancelled:
+		return []string{model.StatusCancelled}
+	default:
+		return nil
+	}
+}
+
+type statusWeight struct {
+	status string
+	weight int
+}
+
+func parseStatuses(spec string) ([]statusWeight, error) {
+
+	var weights []statusWeight
+
+	for _, part := range strings.Split(spec, ",") {
+
+		status, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid status weight %q", part)
+		}
+
+		weight, err := strconv.Atoi(value)
+		if err != nil || weight < 0 {
+			return nil, fmt.Errorf("invalid weight for %s: %q", status, value)
+		}
+
+		switch status {
+		case model.StatusPending, model.StatusShipped, model.StatusCompleted, model.StatusCancelled:
+		default:
+			return nil, fmt.Errorf("unknown status %q", status)
+		}
+
+		weights = append(weights, statusWeight{status: status, weight: weight})
+	}
+
+	return weights, nil
+}
+
+type generator struct {
+	mu        sync.Mutex
+	rnd       *rand.Rand
+	zipf      *rand.Zipf
+	opts      options
+	weights   []statusWeight
+	total     int
+	customers []uuid.UUID
+	items     []model.LineItem
+}
+
+func newGenerator(opts options, weights []statusWeight) *generator {
+
+	rnd := rand.New(rand.NewSource(opts.seed))
+
+	g := &generator{
+		rnd:       rnd,
+		zipf:      rand.NewZipf(rnd, 1.2, 1, uint64(opts.items-1)),
+		opts:      opts,
+		weights:   weights,
+		customers: make([]uuid.UUID, opts.customers),
+		items:     make([]model.LineItem, opts.items),
+	}
+
+	for _, w := range weights {
+		g.total += w.weight
+	}
+
+	for i := range g.customers {
+		g.customers[i] = g.uuid()
+	}
+
+	for i := range g.items {
+		g.items[i] = model.LineItem{
+			ItemID: g.uuid(),
+			Price:  uint(99 + rnd.Intn(20000)),
+		}
+	}
+
+	return g
+}
+
+func (g *generator) uuid() uuid.UUID {
+
+	var id uuid.UUID
+	g.rnd.Read(id[:])
+
+	// version 4, RFC 4122 variant
+	id[6] = (id[6] & 0x0f) | 0x40
+	id[8] = (id[8] & 0x3f) | 0x80
+
+	return id
+}
+
+func (g *generator) order() model.Order {
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	age := time.Duration(g.rnd.Int63n(int64(time.Duration(g.opts.days) * 24 * time.Hour)))
+	createdAt := time.Now().UTC().Add(-age)
+
+	o := model.Order{
+		OrderID:    g.rnd.Uint64(),
+		CustomerID: g.customers[g.rnd.Intn(len(g.customers))],
+		LineItems:  g.lineItems(),
+		CreatedAt:  &createdAt,
+	}
+
+	shippedAt := createdAt.Add(time.Duration(1+g.rnd.Intn(48)) * time.Hour)
+	completedAt := shippedAt.Add(time.Duration(24+g.rnd.Intn(96)) * time.Hour)
+	cancelledAt := createdAt.Add(time.Duration(1+g.rnd.Intn(120)) * time.Minute)
+
+	switch g.status() {
+	case model.StatusShipped:
+		o.Ship(shippedAt)
+	case model.StatusCompleted:
+		o.Ship(shippedAt)
+		o.Complete(completedAt)
+	case model.StatusCancelled:
+		o.Cancel(cancelledAt)
+	}
+
+	return o
+}
+
+func (g *generator) lineItems() []model.LineItem {
+
+	n := 1 + g.rnd.Intn(g.opts.maxLines)
+	seen := map[uint64]bool{}
+	items := make([]model.LineItem, 0, n)
+
+	for len(items) < n {
+		idx := g.zipf.Uint64()
+		if seen[idx] {
+			continue
+		}
+		seen[idx] = true
+
+		item := g.items[idx]
+		item.Quantity = uint(1 + g.rnd.Intn(g.opts.maxQuantity))
+		items = append(items, item)
+	}
+
+	return items
+}
+
+func (g *generator) status() string {
+
+	if g.total == 0 {
+		return model.StatusPending
+	}
+
+	n := g.rnd.Intn(g.total)
+
+	for _, w := range g.weights {
+		if n < w.weight {
+			return w.status
+		}
+		n -= w.weight
+	}
+
+	return model.StatusPending
+}
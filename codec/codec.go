@@ -0,0 +1,59 @@
+package codec
+
+import (
+	"errors"
+	"mime"
+	"strings"
+)
+
+var ErrUnsupportedType = errors.New("type not supported by codec")
+
+type Codec interface {
+	Name() string
+	ContentType() string
+	Marshal(v any) ([]byte, error)
+	Unmarshal(data []byte, v any) error
+}
+
+var (
+	JSON     Codec = jsonCodec{}
+	MsgPack  Codec = msgpackCodec{}
+	Protobuf Codec = protobufCodec{}
+)
+
+var byName = map[string]Codec{
+	JSON.Name():     JSON,
+	MsgPack.Name():  MsgPack,
+	Protobuf.Name(): Protobuf,
+}
+
+var byContentType = map[string]Codec{
+	JSON.ContentType():      JSON,
+	MsgPack.ContentType():   MsgPack,
+	"application/x-msgpack": MsgPack,
+	Protobuf.ContentType():  Protobuf,
+	"application/protobuf":  Protobuf,
+	JSONAPI.ContentType():   JSONAPI,
+}
+
+func ByName(name string) (Codec, bool) {
+	c, ok := byName[name]
+	return c, ok
+}
+
+func Negotiate(accept string) Codec {
+
+	for _, part := range strings.Split(accept, ",") {
+
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+
+		if c, ok := byContentType[mediaType]; ok {
+			return c
+		}
+	}
+
+	return JSON
+}
@@ -0,0 +1,269 @@
+package codec
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/google/uuid"
+	"github.com/i101dev/microservices-NN/model"
+)
+
+// JSONAPI writes orders as JSON:API documents, with the customer, the
+// organization and the items as relationships. The quantity and price of a line item go in the
+// meta of its item. It is only offered to clients, never used for storage.
+var JSONAPI Codec = jsonAPICodec{}
+
+type jsonAPICodec struct{}
+
+func (jsonAPICodec) Name() string {
+	return "jsonapi"
+}
+
+func (jsonAPICodec) ContentType() string {
+	return "application/vnd.api+json"
+}
+
+type jsonAPIIdentifier struct {
+	Type string         `json:"type"`
+	ID   string         `json:"id"`
+	Meta map[string]any `json:"meta,omitempty"`
+}
+
+type jsonAPIRelationship struct {
+	Data any `json:"data"`
+}
+
+type jsonAPIResource struct {
+	Type          string                         `json:"type"`
+	ID            string                         `json:"id"`
+	Attributes    map[string]json.RawMessage     `json:"attributes"`
+	Relationships map[string]jsonAPIRelationship `json:"relationships"`
+	Links         map[string]string              `json:"links,omitempty"`
+}
+
+type jsonAPIDocument struct {
+	Data  any            `json:"data"`
+	Meta  map[string]any `json:"meta,omitempty"`
+	Links map[string]any `json:"links,omitempty"`
+}
+
+func (jsonAPICodec) Marshal(v any) ([]byte, error) {
+
+	switch v := v.(type) {
+	case model.Order:
+		return marshalJSONAPIOrder(&v)
+	case *model.Order:
+		return marshalJSONAPIOrder(v)
+	case model.OrderPage:
+		return marshalJSONAPIPage(&v)
+	case *model.OrderPage:
+		return marshalJSONAPIPage(v)
+	default:
+		return nil, fmt.Errorf("%w: %T", ErrUnsupportedType, v)
+	}
+}
+
+func marshalJSONAPIOrder(o *model.Order) ([]byte, error) {
+
+	r, err := newJSONAPIResource(o)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(jsonAPIDocument{Data: r})
+}
+
+func marshalJSONAPIPage(p *model.OrderPage) ([]byte, error) {
+
+	data := make([]jsonAPIResource, len(p.Items))
+
+	for i := range p.Items {
+		r, err := newJSONAPIResource(&p.Items[i])
+		if err != nil {
+			return nil, err
+		}
+		data[i] = r
+	}
+
+	doc := jsonAPIDocument{Data: data, Meta: map[string]any{}}
+
+	if p.Next != 0 {
+		doc.Meta["next"] = strconv.FormatUint(p.Next, 10)
+	}
+	if p.Snapshot != "" {
+		doc.Meta["snapshot"] = p.Snapshot
+	}
+	if p.Total != nil {
+		doc.Meta["total"] = *p.Total
+		doc.Meta["total_as_of"] = p.TotalAsOf
+	}
+
+	return json.Marshal(doc)
+}
+
+// newJSONAPIResource keeps every field of the order as an attribute, but
+// its ID, customer, organization and items, and adds its status.
+func newJSONAPIResource(o *model.Order) (jsonAPIResource, error) {
+
+	data, err := json.Marshal(o)
+	if err != nil {
+		return jsonAPIResource{}, err
+	}
+
+	var attributes map[string]json.RawMessage
+	if err := json.Unmarshal(data, &attributes); err != nil {
+		return jsonAPIResource{}, err
+	}
+
+	delete(attributes, "order_id")
+	delete(attributes, "customer_id")
+	delete(attributes, "line_items")
+	delete(attributes, "org_id")
+
+	attributes["status"], _ = json.Marshal(o.Status())
+
+	items := make([]jsonAPIIdentifier, len(o.LineItems))
+	for i, item := range o.LineItems {
+		items[i] = jsonAPIIdentifier{
+			Type: "items",
+			ID:   item.ItemID.String(),
+			Meta: map[string]any{"quantity": item.Quantity, "price": item.Price},
+		}
+	}
+
+	id := strconv.FormatUint(o.OrderID, 10)
+
+	relationships := map[string]jsonAPIRelationship{
+		"customer": {Data: jsonAPIIdentifier{Type: "customers", ID: o.CustomerID.String()}},
+		"items":    {Data: items},
+	}
+
+	if o.OrgID != nil {
+		relationships["org"] = jsonAPIRelationship{Data: jsonAPIIdentifier{Type: "orgs", ID: o.OrgID.String()}}
+	}
+
+	return jsonAPIResource{
+		Type:          "orders",
+		ID:            id,
+		Attributes:    attributes,
+		Relationships: relationships,
+		Links:         map[string]string{"self": "/orders/" + id},
+	}, nil
+}
+
+func (jsonAPICodec) Unmarshal(data []byte, v any) error {
+
+	switch v := v.(type) {
+	case *model.Order:
+		var doc struct {
+			Data json.RawMessage `json:"data"`
+		}
+		if err := json.Unmarshal(data, &doc); err != nil {
+			return err
+		}
+		return unmarshalJSONAPIOrder(doc.Data, v)
+
+	case *model.OrderPage:
+		var doc struct {
+			Data []json.RawMessage `json:"data"`
+			Meta struct {
+				Next     string `json:"next"`
+				Snapshot string `json:"snapshot"`
+			} `json:"meta"`
+		}
+		if err := json.Unmarshal(data, &doc); err != nil {
+			return err
+		}
+
+		*v = model.OrderPage{Items: make([]model.Order, len(doc.Data)), Snapshot: doc.Meta.Snapshot}
+		for i, r := range doc.Data {
+			if err := unmarshalJSONAPIOrder(r, &v.Items[i]); err != nil {
+				return err
+			}
+		}
+
+		if doc.Meta.Next != "" {
+			next, err := strconv.ParseUint(doc.Meta.Next, 10, 64)
+			if err != nil {
+				return fmt.Errorf("invalid next cursor %q: %w", doc.Meta.Next, err)
+			}
+			v.Next = next
+		}
+
+		return nil
+
+	default:
+		return fmt.Errorf("%w: %T", ErrUnsupportedType, v)
+	}
+}
+
+func unmarshalJSONAPIOrder(data []byte, o *model.Order) error {
+
+	var r struct {
+		Type          string          `json:"type"`
+		ID            string          `json:"id"`
+		Attributes    json.RawMessage `json:"attributes"`
+		Relationships struct {
+			Customer struct {
+				Data jsonAPIIdentifier `json:"data"`
+			} `json:"customer"`
+			Org *struct {
+				Data jsonAPIIdentifier `json:"data"`
+			} `json:"org"`
+			Items struct {
+				Data []struct {
+					ID   string `json:"id"`
+					Meta struct {
+						Quantity uint `json:"quantity"`
+						Price    uint `json:"price"`
+					} `json:"meta"`
+				} `json:"data"`
+			} `json:"items"`
+		} `json:"relationships"`
+	}
+
+	if err := json.Unmarshal(data, &r); err != nil {
+		return err
+	}
+
+	if r.Type != "orders" {
+		return fmt.Errorf("expected an orders resource, found %q", r.Type)
+	}
+
+	*o = model.Order{}
+
+	if len(r.Attributes) > 0 {
+		if err := json.Unmarshal(r.Attributes, o); err != nil {
+			return err
+		}
+	}
+
+	id, err := strconv.ParseUint(r.ID, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid order id %q: %w", r.ID, err)
+	}
+	o.OrderID = id
+
+	if o.CustomerID, err = uuid.Parse(r.Relationships.Customer.Data.ID); err != nil {
+		return fmt.Errorf("invalid customer id: %w", err)
+	}
+
+	if r.Relationships.Org != nil {
+		org, err := uuid.Parse(r.Relationships.Org.Data.ID)
+		if err != nil {
+			return fmt.Errorf("invalid org id: %w", err)
+		}
+		o.OrgID = &org
+	}
+
+	for _, item := range r.Relationships.Items.Data {
+		itemID, err := uuid.Parse(item.ID)
+		if err != nil {
+			return fmt.Errorf("invalid item id: %w", err)
+		}
+		o.LineItems = append(o.LineItems, model.LineItem{ItemID: itemID, Quantity: item.Meta.Quantity, Price: item.Meta.Price})
+	}
+
+	return nil
+}
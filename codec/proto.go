@@ -0,0 +1,1464 @@
+package codec
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/i101dev/microservices-NN/model"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+type protobufCodec struct{}
+
+func (protobufCodec) Name() string {
+	return "protobuf"
+}
+
+func (protobufCodec) ContentType() string {
+	return "application/x-protobuf"
+}
+
+func (protobufCodec) Marshal(v any) ([]byte, error) {
+
+	switch v := v.(type) {
+	case model.Order:
+		return appendOrder(nil, &v), nil
+	case *model.Order:
+		return appendOrder(nil, v), nil
+	case model.OrderPage:
+		return appendOrderPage(nil, &v), nil
+	case *model.OrderPage:
+		return appendOrderPage(nil, v), nil
+	default:
+		return nil, fmt.Errorf("%w: %T", ErrUnsupportedType, v)
+	}
+}
+
+func (protobufCodec) Unmarshal(data []byte, v any) error {
+
+	switch v := v.(type) {
+	case *model.Order:
+		return consumeOrder(data, v)
+	case *model.OrderPage:
+		return consumeOrderPage(data, v)
+	default:
+		return fmt.Errorf("%w: %T", ErrUnsupportedType, v)
+	}
+}
+
+func appendOrderPage(b []byte, p *model.OrderPage) []byte {
+
+	for i := range p.Items {
+		b = appendMessage(b, 1, appendOrder(nil, &p.Items[i]))
+	}
+
+	if p.Next != 0 {
+		b = protowire.AppendTag(b, 2, protowire.VarintType)
+		b = protowire.AppendVarint(b, p.Next)
+	}
+
+	if p.Snapshot != "" {
+		b = protowire.AppendTag(b, 3, protowire.BytesType)
+		b = protowire.AppendString(b, p.Snapshot)
+	}
+
+	// A total of 0 is still written, so it can be told from no total.
+	if p.Total != nil {
+		b = protowire.AppendTag(b, 4, protowire.VarintType)
+		b = protowire.AppendVarint(b, uint64(*p.Total))
+	}
+
+	b = appendTimestamp(b, 5, p.TotalAsOf)
+
+	return b
+}
+
+func appendOrder(b []byte, o *model.Order) []byte {
+
+	if o.OrderID != 0 {
+		b = protowire.AppendTag(b, 1, protowire.VarintType)
+		b = protowire.AppendVarint(b, o.OrderID)
+	}
+
+	b = protowire.AppendTag(b, 2, protowire.BytesType)
+	b = protowire.AppendString(b, o.CustomerID.String())
+
+	for _, item := range o.LineItems {
+		b = appendMessage(b, 3, appendLineItem(nil, item))
+	}
+
+	b = appendTimestamp(b, 4, o.CreatedAt)
+	b = appendTimestamp(b, 5, o.ShippedAt)
+	b = appendTimestamp(b, 6, o.CompletedAt)
+	b = appendTimestamp(b, 7, o.CancelledAt)
+	b = appendTimestamp(b, 8, o.UpdatedAt)
+
+	if o.PossibleDuplicate {
+		b = protowire.AppendTag(b, 9, protowire.VarintType)
+		b = protowire.AppendVarint(b, 1)
+	}
+
+	b = appendTimestamp(b, 10, o.FlaggedAt)
+	b = appendTimestamp(b, 11, o.ApprovedAt)
+
+	if o.ReviewReason != "" {
+		b = protowire.AppendTag(b, 12, protowire.BytesType)
+		b = protowire.AppendString(b, o.ReviewReason)
+	}
+
+	if o.Shipping != nil {
+		b = appendMessage(b, 13, appendShipping(nil, o.Shipping))
+	}
+
+	if o.EstimatedDelivery != nil {
+		b = appendMessage(b, 14, appendDeliveryEstimate(nil, o.EstimatedDelivery))
+	}
+
+	if o.Tracking != nil {
+		b = appendMessage(b, 15, appendTracking(nil, o.Tracking))
+	}
+
+	if o.Payment != nil {
+		b = appendMessage(b, 16, appendPayment(nil, o.Payment))
+	}
+
+	if o.Tax != nil {
+		b = appendMessage(b, 17, appendTax(nil, o.Tax))
+	}
+
+	if o.Backorder != nil {
+		b = appendMessage(b, 18, appendBackorder(nil, o.Backorder))
+	}
+
+	if o.BackorderID != 0 {
+		b = protowire.AppendTag(b, 19, protowire.VarintType)
+		b = protowire.AppendVarint(b, o.BackorderID)
+	}
+
+	for _, r := range o.Redemptions {
+		b = appendMessage(b, 20, appendRedemption(nil, r))
+	}
+
+	if o.QuoteID != "" {
+		b = protowire.AppendTag(b, 21, protowire.BytesType)
+		b = protowire.AppendString(b, o.QuoteID)
+	}
+
+	for _, tag := range o.Tags {
+		b = protowire.AppendTag(b, 22, protowire.BytesType)
+		b = protowire.AppendString(b, tag)
+	}
+
+	if o.OrgID != nil {
+		b = protowire.AppendTag(b, 23, protowire.BytesType)
+		b = protowire.AppendString(b, o.OrgID.String())
+	}
+
+	keys := make([]string, 0, len(o.Metadata))
+	for key := range o.Metadata {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+
+	for _, key := range keys {
+		var entry []byte
+		entry = protowire.AppendTag(entry, 1, protowire.BytesType)
+		entry = protowire.AppendString(entry, key)
+		entry = protowire.AppendTag(entry, 2, protowire.BytesType)
+		entry = protowire.AppendBytes(entry, o.Metadata[key])
+		b = appendMessage(b, 24, entry)
+	}
+
+	for _, a := range o.Attachments {
+		b = appendMessage(b, 25, appendAttachment(nil, a))
+	}
+
+	if o.SLA != nil {
+		b = appendMessage(b, 26, appendSLA(nil, o.SLA))
+	}
+
+	if o.SLABreached {
+		b = protowire.AppendTag(b, 27, protowire.VarintType)
+		b = protowire.AppendVarint(b, 1)
+	}
+
+	if o.Priority != "" {
+		b = protowire.AppendTag(b, 28, protowire.BytesType)
+		b = protowire.AppendString(b, o.Priority)
+	}
+
+	b = appendTimestamp(b, 29, o.ExpeditedAt)
+
+	for _, a := range o.Assignments {
+		b = appendMessage(b, 30, appendAssignment(nil, a))
+	}
+
+	for _, d := range o.Disputes {
+		b = appendMessage(b, 31, appendDispute(nil, d))
+	}
+
+	if o.Cancellation != nil {
+		b = appendMessage(b, 32, appendCancellation(nil, o.Cancellation))
+	}
+
+	return b
+}
+
+func appendShipping(b []byte, s *model.Shipping) []byte {
+
+	for _, f := range []struct {
+		num   protowire.Number
+		value string
+	}{
+		{1, s.Method},
+		{2, s.Country},
+		{3, s.Region},
+	} {
+		if f.value != "" {
+			b = protowire.AppendTag(b, f.num, protowire.BytesType)
+			b = protowire.AppendString(b, f.value)
+		}
+	}
+
+	return b
+}
+
+func appendDeliveryEstimate(b []byte, e *model.DeliveryEstimate) []byte {
+
+	b = appendTimestamp(b, 1, &e.Earliest)
+	b = appendTimestamp(b, 2, &e.Latest)
+
+	if e.Carrier != "" {
+		b = protowire.AppendTag(b, 3, protowire.BytesType)
+		b = protowire.AppendString(b, e.Carrier)
+	}
+
+	if e.Rule != "" {
+		b = protowire.AppendTag(b, 4, protowire.BytesType)
+		b = protowire.AppendString(b, e.Rule)
+	}
+
+	return b
+}
+
+func appendTracking(b []byte, t *model.Tracking) []byte {
+
+	for _, f := range []struct {
+		num   protowire.Number
+		value string
+	}{
+		{1, t.Carrier},
+		{2, t.Number},
+		{3, t.Status},
+	} {
+		if f.value != "" {
+			b = protowire.AppendTag(b, f.num, protowire.BytesType)
+			b = protowire.AppendString(b, f.value)
+		}
+	}
+
+	return appendTimestamp(b, 4, t.At)
+}
+
+func appendPayment(b []byte, p *model.Payment) []byte {
+
+	for _, f := range []struct {
+		num   protowire.Number
+		value string
+	}{
+		{1, p.Reference},
+		{2, p.Status},
+	} {
+		if f.value != "" {
+			b = protowire.AppendTag(b, f.num, protowire.BytesType)
+			b = protowire.AppendString(b, f.value)
+		}
+	}
+
+	for _, f := range []struct {
+		num   protowire.Number
+		value uint
+	}{
+		{3, p.Authorized},
+		{4, p.Captured},
+		{5, p.Refunded},
+	} {
+		if f.value != 0 {
+			b = protowire.AppendTag(b, f.num, protowire.VarintType)
+			b = protowire.AppendVarint(b, uint64(f.value))
+		}
+	}
+
+	b = appendTimestamp(b, 6, p.AuthorizedAt)
+	b = appendTimestamp(b, 7, p.CapturedAt)
+	b = appendTimestamp(b, 8, p.RefundedAt)
+
+	return b
+}
+
+func appendTax(b []byte, t *model.Tax) []byte {
+
+	if t.Total != 0 {
+		b = protowire.AppendTag(b, 1, protowire.VarintType)
+		b = protowire.AppendVarint(b, uint64(t.Total))
+	}
+
+	for _, line := range t.Lines {
+		b = appendMessage(b, 2, appendTaxLine(nil, line))
+	}
+
+	return b
+}
+
+func appendTaxLine(b []byte, line model.TaxLine) []byte {
+
+	b = protowire.AppendTag(b, 1, protowire.BytesType)
+	b = protowire.AppendString(b, line.ItemID.String())
+
+	if line.Jurisdiction != "" {
+		b = protowire.AppendTag(b, 2, protowire.BytesType)
+		b = protowire.AppendString(b, line.Jurisdiction)
+	}
+
+	if line.Rate != 0 {
+		b = protowire.AppendTag(b, 3, protowire.Fixed64Type)
+		b = protowire.AppendFixed64(b, math.Float64bits(line.Rate))
+	}
+
+	if line.Amount != 0 {
+		b = protowire.AppendTag(b, 4, protowire.VarintType)
+		b = protowire.AppendVarint(b, uint64(line.Amount))
+	}
+
+	return b
+}
+
+func appendBackorder(b []byte, bo *model.Backorder) []byte {
+
+	if bo.SplitFrom != 0 {
+		b = protowire.AppendTag(b, 1, protowire.VarintType)
+		b = protowire.AppendVarint(b, bo.SplitFrom)
+	}
+
+	b = appendTimestamp(b, 2, &bo.Since)
+	b = appendTimestamp(b, 3, bo.FulfilledAt)
+
+	return b
+}
+
+func appendRedemption(b []byte, r model.Redemption) []byte {
+
+	for _, f := range []struct {
+		num   protowire.Number
+		value string
+	}{
+		{1, r.Source},
+		{2, r.Code},
+	} {
+		if f.value != "" {
+			b = protowire.AppendTag(b, f.num, protowire.BytesType)
+			b = protowire.AppendString(b, f.value)
+		}
+	}
+
+	if r.Amount != 0 {
+		b = protowire.AppendTag(b, 3, protowire.VarintType)
+		b = protowire.AppendVarint(b, uint64(r.Amount))
+	}
+
+	return appendTimestamp(b, 4, &r.At)
+}
+
+func appendAttachment(b []byte, a model.Attachment) []byte {
+
+	for _, f := range []struct {
+		num   protowire.Number
+		value string
+	}{
+		{1, a.ID},
+		{2, a.Name},
+		{3, a.ContentType},
+	} {
+		if f.value != "" {
+			b = protowire.AppendTag(b, f.num, protowire.BytesType)
+			b = protowire.AppendString(b, f.value)
+		}
+	}
+
+	if a.Size != 0 {
+		b = protowire.AppendTag(b, 4, protowire.VarintType)
+		b = protowire.AppendVarint(b, uint64(a.Size))
+	}
+
+	b = appendTimestamp(b, 5, &a.CreatedAt)
+
+	return appendTimestamp(b, 6, a.UploadedAt)
+}
+
+func appendSLA(b []byte, s *model.SLA) []byte {
+
+	b = appendTimestamp(b, 1, &s.ShipBy)
+
+	return appendTimestamp(b, 2, s.BreachedAt)
+}
+
+func appendAssignment(b []byte, a model.Assignment) []byte {
+
+	if a.Warehouse != "" {
+		b = protowire.AppendTag(b, 1, protowire.BytesType)
+		b = protowire.AppendString(b, a.Warehouse)
+	}
+
+	for _, item := range a.LineItems {
+		b = appendMessage(b, 2, appendLineItem(nil, item))
+	}
+
+	if a.Rule != "" {
+		b = protowire.AppendTag(b, 3, protowire.BytesType)
+		b = protowire.AppendString(b, a.Rule)
+	}
+
+	return appendTimestamp(b, 4, &a.AssignedAt)
+}
+
+func appendCancellation(b []byte, c *model.Cancellation) []byte {
+
+	if c.Fee != 0 {
+		b = protowire.AppendTag(b, 1, protowire.VarintType)
+		b = protowire.AppendVarint(b, uint64(c.Fee))
+	}
+
+	for _, f := range []struct {
+		num   protowire.Number
+		value string
+	}{
+		{2, c.Rule},
+		{3, c.Reason},
+	} {
+		if f.value != "" {
+			b = protowire.AppendTag(b, f.num, protowire.BytesType)
+			b = protowire.AppendString(b, f.value)
+		}
+	}
+
+	return b
+}
+
+func appendDispute(b []byte, d model.Dispute) []byte {
+
+	for _, f := range []struct {
+		num   protowire.Number
+		value string
+	}{
+		{1, d.ID},
+		{2, d.Status},
+		{3, d.Reason},
+	} {
+		if f.value != "" {
+			b = protowire.AppendTag(b, f.num, protowire.BytesType)
+			b = protowire.AppendString(b, f.value)
+		}
+	}
+
+	if d.Amount != 0 {
+		b = protowire.AppendTag(b, 4, protowire.VarintType)
+		b = protowire.AppendVarint(b, uint64(d.Amount))
+	}
+
+	b = appendTimestamp(b, 5, d.EvidenceDueBy)
+
+	for _, id := range d.Evidence {
+		b = protowire.AppendTag(b, 6, protowire.BytesType)
+		b = protowire.AppendString(b, id)
+	}
+
+	if d.DeadlineMissed {
+		b = protowire.AppendTag(b, 7, protowire.VarintType)
+		b = protowire.AppendVarint(b, 1)
+	}
+
+	b = appendTimestamp(b, 8, &d.OpenedAt)
+	b = appendTimestamp(b, 9, &d.UpdatedAt)
+
+	return appendTimestamp(b, 10, d.ClosedAt)
+}
+
+func appendLineItem(b []byte, item model.LineItem) []byte {
+
+	b = protowire.AppendTag(b, 1, protowire.BytesType)
+	b = protowire.AppendString(b, item.ItemID.String())
+
+	if item.Quantity != 0 {
+		b = protowire.AppendTag(b, 2, protowire.VarintType)
+		b = protowire.AppendVarint(b, uint64(item.Quantity))
+	}
+
+	if item.Price != 0 {
+		b = protowire.AppendTag(b, 3, protowire.VarintType)
+		b = protowire.AppendVarint(b, uint64(item.Price))
+	}
+
+	return b
+}
+
+func appendTimestamp(b []byte, num protowire.Number, t *time.Time) []byte {
+
+	if t == nil {
+		return b
+	}
+
+	var ts []byte
+
+	if secs := t.Unix(); secs != 0 {
+		ts = protowire.AppendTag(ts, 1, protowire.VarintType)
+		ts = protowire.AppendVarint(ts, uint64(secs))
+	}
+
+	if nanos := t.Nanosecond(); nanos != 0 {
+		ts = protowire.AppendTag(ts, 2, protowire.VarintType)
+		ts = protowire.AppendVarint(ts, uint64(nanos))
+	}
+
+	return appendMessage(b, num, ts)
+}
+
+func appendMessage(b []byte, num protowire.Number, msg []byte) []byte {
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, msg)
+}
+
+func consumeFields(data []byte, fn func(num protowire.Number, typ protowire.Type, data []byte) (int, error)) error {
+
+	for len(data) > 0 {
+
+		num, typ, n := protowire.ConsumeTag(data)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		data = data[n:]
+
+		m, err := fn(num, typ, data)
+		if err != nil {
+			return err
+		}
+
+		if m == 0 {
+			m = protowire.ConsumeFieldValue(num, typ, data)
+		}
+		if m < 0 {
+			return protowire.ParseError(m)
+		}
+		data = data[m:]
+	}
+
+	return nil
+}
+
+func consumeOrderPage(data []byte, p *model.OrderPage) error {
+
+	*p = model.OrderPage{Items: []model.Order{}}
+
+	return consumeFields(data, func(num protowire.Number, typ protowire.Type, data []byte) (int, error) {
+
+		switch {
+		case num == 1 && typ == protowire.BytesType:
+			msg, n := protowire.ConsumeBytes(data)
+			if n < 0 {
+				return n, nil
+			}
+			var o model.Order
+			if err := consumeOrder(msg, &o); err != nil {
+				return 0, err
+			}
+			p.Items = append(p.Items, o)
+			return n, nil
+		case num == 2 && typ == protowire.VarintType:
+			v, n := protowire.ConsumeVarint(data)
+			p.Next = v
+			return n, nil
+		case num == 3 && typ == protowire.BytesType:
+			s, n := protowire.ConsumeString(data)
+			if n < 0 {
+				return n, nil
+			}
+			p.Snapshot = s
+			return n, nil
+		case num == 4 && typ == protowire.VarintType:
+			v, n := protowire.ConsumeVarint(data)
+			total := int64(v)
+			p.Total = &total
+			return n, nil
+		case num == 5 && typ == protowire.BytesType:
+			msg, n := protowire.ConsumeBytes(data)
+			if n < 0 {
+				return n, nil
+			}
+			t, err := consumeTimestamp(msg)
+			if err != nil {
+				return 0, err
+			}
+			p.TotalAsOf = &t
+			return n, nil
+		}
+
+		return 0, nil
+	})
+}
+
+func consumeOrder(data []byte, o *model.Order) error {
+
+	*o = model.Order{LineItems: []model.LineItem{}}
+
+	return consumeFields(data, func(num protowire.Number, typ protowire.Type, data []byte) (int, error) {
+
+		switch {
+		case num == 1 && typ == protowire.VarintType:
+			v, n := protowire.ConsumeVarint(data)
+			o.OrderID = v
+			return n, nil
+		case num == 2 && typ == protowire.BytesType:
+			s, n := protowire.ConsumeString(data)
+			if n < 0 {
+				return n, nil
+			}
+			id, err := uuid.Parse(s)
+			if err != nil {
+				return 0, fmt.Errorf("invalid customer_id: %w", err)
+			}
+			o.CustomerID = id
+			return n, nil
+		case num == 3 && typ == protowire.BytesType:
+			msg, n := protowire.ConsumeBytes(data)
+			if n < 0 {
+				return n, nil
+			}
+			item, err := consumeLineItem(msg)
+			if err != nil {
+				return 0, err
+			}
+			o.LineItems = append(o.LineItems, item)
+			return n, nil
+		case num == 9 && typ == protowire.VarintType:
+			v, n := protowire.ConsumeVarint(data)
+			o.PossibleDuplicate = v != 0
+			return n, nil
+		case num == 12 && typ == protowire.BytesType:
+			s, n := protowire.ConsumeString(data)
+			o.ReviewReason = s
+			return n, nil
+		case num == 13 && typ == protowire.BytesType:
+			msg, n := protowire.ConsumeBytes(data)
+			if n < 0 {
+				return n, nil
+			}
+			shipping, err := consumeShipping(msg)
+			if err != nil {
+				return 0, err
+			}
+			o.Shipping = &shipping
+			return n, nil
+		case num == 14 && typ == protowire.BytesType:
+			msg, n := protowire.ConsumeBytes(data)
+			if n < 0 {
+				return n, nil
+			}
+			e, err := consumeDeliveryEstimate(msg)
+			if err != nil {
+				return 0, err
+			}
+			o.EstimatedDelivery = &e
+			return n, nil
+		case num == 15 && typ == protowire.BytesType:
+			msg, n := protowire.ConsumeBytes(data)
+			if n < 0 {
+				return n, nil
+			}
+			t, err := consumeTracking(msg)
+			if err != nil {
+				return 0, err
+			}
+			o.Tracking = &t
+			return n, nil
+		case num == 16 && typ == protowire.BytesType:
+			msg, n := protowire.ConsumeBytes(data)
+			if n < 0 {
+				return n, nil
+			}
+			p, err := consumePayment(msg)
+			if err != nil {
+				return 0, err
+			}
+			o.Payment = &p
+			return n, nil
+		case num == 17 && typ == protowire.BytesType:
+			msg, n := protowire.ConsumeBytes(data)
+			if n < 0 {
+				return n, nil
+			}
+			t, err := consumeTax(msg)
+			if err != nil {
+				return 0, err
+			}
+			o.Tax = &t
+			return n, nil
+		case num == 18 && typ == protowire.BytesType:
+			msg, n := protowire.ConsumeBytes(data)
+			if n < 0 {
+				return n, nil
+			}
+			bo, err := consumeBackorder(msg)
+			if err != nil {
+				return 0, err
+			}
+			o.Backorder = &bo
+			return n, nil
+		case num == 19 && typ == protowire.VarintType:
+			v, n := protowire.ConsumeVarint(data)
+			o.BackorderID = v
+			return n, nil
+		case num == 20 && typ == protowire.BytesType:
+			msg, n := protowire.ConsumeBytes(data)
+			if n < 0 {
+				return n, nil
+			}
+			r, err := consumeRedemption(msg)
+			if err != nil {
+				return 0, err
+			}
+			o.Redemptions = append(o.Redemptions, r)
+			return n, nil
+		case num == 21 && typ == protowire.BytesType:
+			s, n := protowire.ConsumeString(data)
+			o.QuoteID = s
+			return n, nil
+		case num == 22 && typ == protowire.BytesType:
+			s, n := protowire.ConsumeString(data)
+			if n < 0 {
+				return n, nil
+			}
+			o.Tags = append(o.Tags, s)
+			return n, nil
+		case num == 23 && typ == protowire.BytesType:
+			s, n := protowire.ConsumeString(data)
+			if n < 0 {
+				return n, nil
+			}
+			id, err := uuid.Parse(s)
+			if err != nil {
+				return 0, fmt.Errorf("invalid org_id: %w", err)
+			}
+			o.OrgID = &id
+			return n, nil
+		case num == 24 && typ == protowire.BytesType:
+			msg, n := protowire.ConsumeBytes(data)
+			if n < 0 {
+				return n, nil
+			}
+			key, value, err := consumeMetadataEntry(msg)
+			if err != nil {
+				return 0, err
+			}
+			if o.Metadata == nil {
+				o.Metadata = map[string]json.RawMessage{}
+			}
+			o.Metadata[key] = value
+			return n, nil
+		case num == 25 && typ == protowire.BytesType:
+			msg, n := protowire.ConsumeBytes(data)
+			if n < 0 {
+				return n, nil
+			}
+			a, err := consumeAttachment(msg)
+			if err != nil {
+				return 0, err
+			}
+			o.Attachments = append(o.Attachments, a)
+			return n, nil
+		case num == 26 && typ == protowire.BytesType:
+			msg, n := protowire.ConsumeBytes(data)
+			if n < 0 {
+				return n, nil
+			}
+			s, err := consumeSLA(msg)
+			if err != nil {
+				return 0, err
+			}
+			o.SLA = &s
+			return n, nil
+		case num == 27 && typ == protowire.VarintType:
+			v, n := protowire.ConsumeVarint(data)
+			o.SLABreached = v != 0
+			return n, nil
+		case num == 28 && typ == protowire.BytesType:
+			s, n := protowire.ConsumeString(data)
+			o.Priority = s
+			return n, nil
+		case num == 30 && typ == protowire.BytesType:
+			msg, n := protowire.ConsumeBytes(data)
+			if n < 0 {
+				return n, nil
+			}
+			a, err := consumeAssignment(msg)
+			if err != nil {
+				return 0, err
+			}
+			o.Assignments = append(o.Assignments, a)
+			return n, nil
+		case num == 31 && typ == protowire.BytesType:
+			msg, n := protowire.ConsumeBytes(data)
+			if n < 0 {
+				return n, nil
+			}
+			d, err := consumeDispute(msg)
+			if err != nil {
+				return 0, err
+			}
+			o.Disputes = append(o.Disputes, d)
+			return n, nil
+		case num == 32 && typ == protowire.BytesType:
+			msg, n := protowire.ConsumeBytes(data)
+			if n < 0 {
+				return n, nil
+			}
+			c, err := consumeCancellation(msg)
+			if err != nil {
+				return 0, err
+			}
+			o.Cancellation = &c
+			return n, nil
+		case (num >= 4 && num <= 8 || num == 10 || num == 11 || num == 29) && typ == protowire.BytesType:
+			msg, n := protowire.ConsumeBytes(data)
+			if n < 0 {
+				return n, nil
+			}
+			t, err := consumeTimestamp(msg)
+			if err != nil {
+				return 0, err
+			}
+			switch num {
+			case 4:
+				o.CreatedAt = &t
+			case 5:
+				o.ShippedAt = &t
+			case 6:
+				o.CompletedAt = &t
+			case 7:
+				o.CancelledAt = &t
+			case 8:
+				o.UpdatedAt = &t
+			case 10:
+				o.FlaggedAt = &t
+			case 11:
+				o.ApprovedAt = &t
+			case 29:
+				o.ExpeditedAt = &t
+			}
+			return n, nil
+		}
+
+		return 0, nil
+	})
+}
+
+func consumeMetadataEntry(data []byte) (string, json.RawMessage, error) {
+
+	var key string
+	var value json.RawMessage
+
+	err := consumeFields(data, func(num protowire.Number, typ protowire.Type, data []byte) (int, error) {
+		if typ != protowire.BytesType || (num != 1 && num != 2) {
+			return 0, nil
+		}
+		b, n := protowire.ConsumeBytes(data)
+		if n < 0 {
+			return n, nil
+		}
+		if num == 1 {
+			key = string(b)
+		} else {
+			value = json.RawMessage(slices.Clone(b))
+		}
+		return n, nil
+	})
+	if err != nil {
+		return "", nil, err
+	}
+
+	if !json.Valid(value) {
+		return "", nil, fmt.Errorf("invalid metadata %q", key)
+	}
+
+	return key, value, nil
+}
+
+func consumeLineItem(data []byte) (model.LineItem, error) {
+
+	var item model.LineItem
+
+	err := consumeFields(data, func(num protowire.Number, typ protowire.Type, data []byte) (int, error) {
+
+		switch {
+		case num == 1 && typ == protowire.BytesType:
+			s, n := protowire.ConsumeString(data)
+			if n < 0 {
+				return n, nil
+			}
+			id, err := uuid.Parse(s)
+			if err != nil {
+				return 0, fmt.Errorf("invalid item_id: %w", err)
+			}
+			item.ItemID = id
+			return n, nil
+		case num == 2 && typ == protowire.VarintType:
+			v, n := protowire.ConsumeVarint(data)
+			item.Quantity = uint(v)
+			return n, nil
+		case num == 3 && typ == protowire.VarintType:
+			v, n := protowire.ConsumeVarint(data)
+			item.Price = uint(v)
+			return n, nil
+		}
+
+		return 0, nil
+	})
+
+	return item, err
+}
+
+func consumeShipping(data []byte) (model.Shipping, error) {
+
+	var s model.Shipping
+
+	err := consumeFields(data, func(num protowire.Number, typ protowire.Type, data []byte) (int, error) {
+
+		if typ != protowire.BytesType {
+			return 0, nil
+		}
+
+		v, n := protowire.ConsumeString(data)
+
+		switch num {
+		case 1:
+			s.Method = v
+		case 2:
+			s.Country = v
+		case 3:
+			s.Region = v
+		default:
+			return 0, nil
+		}
+
+		return n, nil
+	})
+
+	return s, err
+}
+
+func consumeDeliveryEstimate(data []byte) (model.DeliveryEstimate, error) {
+
+	var e model.DeliveryEstimate
+
+	err := consumeFields(data, func(num protowire.Number, typ protowire.Type, data []byte) (int, error) {
+
+		if typ != protowire.BytesType {
+			return 0, nil
+		}
+
+		switch num {
+		case 1, 2:
+			msg, n := protowire.ConsumeBytes(data)
+			if n < 0 {
+				return n, nil
+			}
+			t, err := consumeTimestamp(msg)
+			if err != nil {
+				return 0, err
+			}
+			if num == 1 {
+				e.Earliest = t
+			} else {
+				e.Latest = t
+			}
+			return n, nil
+		case 3:
+			v, n := protowire.ConsumeString(data)
+			e.Carrier = v
+			return n, nil
+		case 4:
+			v, n := protowire.ConsumeString(data)
+			e.Rule = v
+			return n, nil
+		}
+
+		return 0, nil
+	})
+
+	return e, err
+}
+
+func consumeTracking(data []byte) (model.Tracking, error) {
+
+	var t model.Tracking
+
+	err := consumeFields(data, func(num protowire.Number, typ protowire.Type, data []byte) (int, error) {
+
+		if typ != protowire.BytesType {
+			return 0, nil
+		}
+
+		switch num {
+		case 1, 2, 3:
+			v, n := protowire.ConsumeString(data)
+			switch num {
+			case 1:
+				t.Carrier = v
+			case 2:
+				t.Number = v
+			case 3:
+				t.Status = v
+			}
+			return n, nil
+		case 4:
+			msg, n := protowire.ConsumeBytes(data)
+			if n < 0 {
+				return n, nil
+			}
+			at, err := consumeTimestamp(msg)
+			if err != nil {
+				return 0, err
+			}
+			t.At = &at
+			return n, nil
+		}
+
+		return 0, nil
+	})
+
+	return t, err
+}
+
+func consumePayment(data []byte) (model.Payment, error) {
+
+	var p model.Payment
+
+	err := consumeFields(data, func(num protowire.Number, typ protowire.Type, data []byte) (int, error) {
+
+		switch {
+		case (num == 1 || num == 2) && typ == protowire.BytesType:
+			v, n := protowire.ConsumeString(data)
+			if num == 1 {
+				p.Reference = v
+			} else {
+				p.Status = v
+			}
+			return n, nil
+		case num >= 3 && num <= 5 && typ == protowire.VarintType:
+			v, n := protowire.ConsumeVarint(data)
+			switch num {
+			case 3:
+				p.Authorized = uint(v)
+			case 4:
+				p.Captured = uint(v)
+			case 5:
+				p.Refunded = uint(v)
+			}
+			return n, nil
+		case num >= 6 && num <= 8 && typ == protowire.BytesType:
+			msg, n := protowire.ConsumeBytes(data)
+			if n < 0 {
+				return n, nil
+			}
+			t, err := consumeTimestamp(msg)
+			if err != nil {
+				return 0, err
+			}
+			switch num {
+			case 6:
+				p.AuthorizedAt = &t
+			case 7:
+				p.CapturedAt = &t
+			case 8:
+				p.RefundedAt = &t
+			}
+			return n, nil
+		}
+
+		return 0, nil
+	})
+
+	return p, err
+}
+
+func consumeTax(data []byte) (model.Tax, error) {
+
+	var t model.Tax
+
+	err := consumeFields(data, func(num protowire.Number, typ protowire.Type, data []byte) (int, error) {
+
+		switch {
+		case num == 1 && typ == protowire.VarintType:
+			v, n := protowire.ConsumeVarint(data)
+			t.Total = uint(v)
+			return n, nil
+		case num == 2 && typ == protowire.BytesType:
+			msg, n := protowire.ConsumeBytes(data)
+			if n < 0 {
+				return n, nil
+			}
+			line, err := consumeTaxLine(msg)
+			if err != nil {
+				return 0, err
+			}
+			t.Lines = append(t.Lines, line)
+			return n, nil
+		}
+
+		return 0, nil
+	})
+
+	return t, err
+}
+
+func consumeTaxLine(data []byte) (model.TaxLine, error) {
+
+	var line model.TaxLine
+
+	err := consumeFields(data, func(num protowire.Number, typ protowire.Type, data []byte) (int, error) {
+
+		switch {
+		case num == 1 && typ == protowire.BytesType:
+			s, n := protowire.ConsumeString(data)
+			if n < 0 {
+				return n, nil
+			}
+			id, err := uuid.Parse(s)
+			if err != nil {
+				return 0, fmt.Errorf("invalid tax line item_id: %w", err)
+			}
+			line.ItemID = id
+			return n, nil
+		case num == 2 && typ == protowire.BytesType:
+			s, n := protowire.ConsumeString(data)
+			line.Jurisdiction = s
+			return n, nil
+		case num == 3 && typ == protowire.Fixed64Type:
+			v, n := protowire.ConsumeFixed64(data)
+			line.Rate = math.Float64frombits(v)
+			return n, nil
+		case num == 4 && typ == protowire.VarintType:
+			v, n := protowire.ConsumeVarint(data)
+			line.Amount = uint(v)
+			return n, nil
+		}
+
+		return 0, nil
+	})
+
+	return line, err
+}
+
+func consumeBackorder(data []byte) (model.Backorder, error) {
+
+	var bo model.Backorder
+
+	err := consumeFields(data, func(num protowire.Number, typ protowire.Type, data []byte) (int, error) {
+
+		switch {
+		case num == 1 && typ == protowire.VarintType:
+			v, n := protowire.ConsumeVarint(data)
+			bo.SplitFrom = v
+			return n, nil
+		case (num == 2 || num == 3) && typ == protowire.BytesType:
+			msg, n := protowire.ConsumeBytes(data)
+			if n < 0 {
+				return n, nil
+			}
+			t, err := consumeTimestamp(msg)
+			if err != nil {
+				return 0, err
+			}
+			if num == 2 {
+				bo.Since = t
+			} else {
+				bo.FulfilledAt = &t
+			}
+			return n, nil
+		}
+
+		return 0, nil
+	})
+
+	return bo, err
+}
+
+func consumeRedemption(data []byte) (model.Redemption, error) {
+
+	var r model.Redemption
+
+	err := consumeFields(data, func(num protowire.Number, typ protowire.Type, data []byte) (int, error) {
+
+		switch {
+		case (num == 1 || num == 2) && typ == protowire.BytesType:
+			v, n := protowire.ConsumeString(data)
+			if num == 1 {
+				r.Source = v
+			} else {
+				r.Code = v
+			}
+			return n, nil
+		case num == 3 && typ == protowire.VarintType:
+			v, n := protowire.ConsumeVarint(data)
+			r.Amount = uint(v)
+			return n, nil
+		case num == 4 && typ == protowire.BytesType:
+			msg, n := protowire.ConsumeBytes(data)
+			if n < 0 {
+				return n, nil
+			}
+			t, err := consumeTimestamp(msg)
+			if err != nil {
+				return 0, err
+			}
+			r.At = t
+			return n, nil
+		}
+
+		return 0, nil
+	})
+
+	return r, err
+}
+
+func consumeAttachment(data []byte) (model.Attachment, error) {
+
+	var a model.Attachment
+
+	err := consumeFields(data, func(num protowire.Number, typ protowire.Type, data []byte) (int, error) {
+
+		switch {
+		case num >= 1 && num <= 3 && typ == protowire.BytesType:
+			v, n := protowire.ConsumeString(data)
+			switch num {
+			case 1:
+				a.ID = v
+			case 2:
+				a.Name = v
+			case 3:
+				a.ContentType = v
+			}
+			return n, nil
+		case num == 4 && typ == protowire.VarintType:
+			v, n := protowire.ConsumeVarint(data)
+			a.Size = int64(v)
+			return n, nil
+		case (num == 5 || num == 6) && typ == protowire.BytesType:
+			msg, n := protowire.ConsumeBytes(data)
+			if n < 0 {
+				return n, nil
+			}
+			t, err := consumeTimestamp(msg)
+			if err != nil {
+				return 0, err
+			}
+			if num == 5 {
+				a.CreatedAt = t
+			} else {
+				a.UploadedAt = &t
+			}
+			return n, nil
+		}
+
+		return 0, nil
+	})
+
+	return a, err
+}
+
+func consumeSLA(data []byte) (model.SLA, error) {
+
+	var s model.SLA
+
+	err := consumeFields(data, func(num protowire.Number, typ protowire.Type, data []byte) (int, error) {
+
+		if (num != 1 && num != 2) || typ != protowire.BytesType {
+			return 0, nil
+		}
+
+		msg, n := protowire.ConsumeBytes(data)
+		if n < 0 {
+			return n, nil
+		}
+		t, err := consumeTimestamp(msg)
+		if err != nil {
+			return 0, err
+		}
+		if num == 1 {
+			s.ShipBy = t
+		} else {
+			s.BreachedAt = &t
+		}
+		return n, nil
+	})
+
+	return s, err
+}
+
+func consumeAssignment(data []byte) (model.Assignment, error) {
+
+	var a model.Assignment
+
+	err := consumeFields(data, func(num protowire.Number, typ protowire.Type, data []byte) (int, error) {
+
+		if typ != protowire.BytesType {
+			return 0, nil
+		}
+
+		switch num {
+		case 1, 3:
+			v, n := protowire.ConsumeString(data)
+			if num == 1 {
+				a.Warehouse = v
+			} else {
+				a.Rule = v
+			}
+			return n, nil
+		case 2, 4:
+			msg, n := protowire.ConsumeBytes(data)
+			if n < 0 {
+				return n, nil
+			}
+			if num == 2 {
+				item, err := consumeLineItem(msg)
+				if err != nil {
+					return 0, err
+				}
+				a.LineItems = append(a.LineItems, item)
+			} else {
+				t, err := consumeTimestamp(msg)
+				if err != nil {
+					return 0, err
+				}
+				a.AssignedAt = t
+			}
+			return n, nil
+		}
+
+		return 0, nil
+	})
+
+	return a, err
+}
+
+func consumeDispute(data []byte) (model.Dispute, error) {
+
+	var d model.Dispute
+
+	err := consumeFields(data, func(num protowire.Number, typ protowire.Type, data []byte) (int, error) {
+
+		switch {
+		case (num >= 1 && num <= 3 || num == 6) && typ == protowire.BytesType:
+			v, n := protowire.ConsumeString(data)
+			switch num {
+			case 1:
+				d.ID = v
+			case 2:
+				d.Status = v
+			case 3:
+				d.Reason = v
+			case 6:
+				d.Evidence = append(d.Evidence, v)
+			}
+			return n, nil
+		case (num == 4 || num == 7) && typ == protowire.VarintType:
+			v, n := protowire.ConsumeVarint(data)
+			if num == 4 {
+				d.Amount = uint(v)
+			} else {
+				d.DeadlineMissed = v != 0
+			}
+			return n, nil
+		case (num == 5 || num >= 8 && num <= 10) && typ == protowire.BytesType:
+			msg, n := protowire.ConsumeBytes(data)
+			if n < 0 {
+				return n, nil
+			}
+			t, err := consumeTimestamp(msg)
+			if err != nil {
+				return 0, err
+			}
+			switch num {
+			case 5:
+				d.EvidenceDueBy = &t
+			case 8:
+				d.OpenedAt = t
+			case 9:
+				d.UpdatedAt = t
+			case 10:
+				d.ClosedAt = &t
+			}
+			return n, nil
+		}
+
+		return 0, nil
+	})
+
+	return d, err
+}
+
+func consumeCancellation(data []byte) (model.Cancellation, error) {
+
+	var c model.Cancellation
+
+	err := consumeFields(data, func(num protowire.Number, typ protowire.Type, data []byte) (int, error) {
+
+		switch {
+		case num == 1 && typ == protowire.VarintType:
+			v, n := protowire.ConsumeVarint(data)
+			c.Fee = uint(v)
+			return n, nil
+		case (num == 2 || num == 3) && typ == protowire.BytesType:
+			v, n := protowire.ConsumeString(data)
+			if num == 2 {
+				c.Rule = v
+			} else {
+				c.Reason = v
+			}
+			return n, nil
+		}
+
+		return 0, nil
+	})
+
+	return c, err
+}
+
+func consumeTimestamp(data []byte) (time.Time, error) {
+
+	var secs, nanos int64
+
+	err := consumeFields(data, func(num protowire.Number, typ protowire.Type, data []byte) (int, error) {
+
+		if typ != protowire.VarintType {
+			return 0, nil
+		}
+
+		v, n := protowire.ConsumeVarint(data)
+
+		switch num {
+		case 1:
+			secs = int64(v)
+		case 2:
+			nanos = int64(v)
+		default:
+			return 0, nil
+		}
+
+		return n, nil
+	})
+
+	return time.Unix(secs, nanos).UTC(), err
+}
@@ -0,0 +1,233 @@
+// Package comment keeps the comment thread of each order, where staff
+// leave internal notes for each other and messages for the customer.
+package comment
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	ErrInvalidComment    = errors.New("invalid comment")
+	ErrInvalidVisibility = errors.New("invalid comment visibility")
+	ErrInvalidCursor     = errors.New("invalid comment cursor")
+)
+
+// Internal comments are for staff only; customer comments are shown to the
+// customer too.
+const (
+	VisibilityInternal = "internal"
+	VisibilityCustomer = "customer"
+)
+
+const (
+	// MaxLength is the longest body of a comment, in bytes.
+	MaxLength = 10000
+	// MaxComments a thread keeps, approximately. Older ones are trimmed.
+	MaxComments = 10000
+	// MaxMentions is how many people one comment notifies.
+	MaxMentions = 20
+)
+
+// Comment is one entry in the thread of an order. ID is its stream entry
+// ID, which orders the thread.
+type Comment struct {
+	ID         string    `json:"id"`
+	OrderID    uint64    `json:"order_id"`
+	Author     string    `json:"author"`
+	Body       string    `json:"body"`
+	Visibility string    `json:"visibility"`
+	Mentions   []string  `json:"mentions,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// Page is part of a thread, oldest first. Next is the cursor of the rest,
+// empty at the end.
+type Page struct {
+	Items []Comment `json:"items"`
+	Next  string    `json:"next,omitempty"`
+}
+
+func ValidVisibility(v string) bool {
+	return v == VisibilityInternal || v == VisibilityCustomer
+}
+
+// mentionPattern matches @handle where it starts a word, so addresses
+// such as a@b.com mention no one.
+var mentionPattern = regexp.MustCompile(`(?:^|[^A-Za-z0-9_.@-])@([A-Za-z0-9][A-Za-z0-9_.-]{0,63})`)
+
+// Mentions returns the handles body mentions with @handle, in the order
+// they first appear, up to MaxMentions.
+func Mentions(body string) []string {
+
+	var handles []string
+
+	for _, m := range mentionPattern.FindAllStringSubmatch(body, -1) {
+		handle := strings.TrimRight(m[1], ".-")
+		if handle == "" || slices.Contains(handles, handle) {
+			continue
+		}
+		handles = append(handles, handle)
+		if len(handles) == MaxMentions {
+			break
+		}
+	}
+
+	return handles
+}
+
+func key(orderID uint64) string {
+	return "comments:" + strconv.FormatUint(orderID, 10)
+}
+
+// Store keeps the thread of every order in a stream of its own.
+type Store struct {
+	Client *redis.Client
+	// Now stamps new comments. Nil means time.Now.
+	Now func() time.Time
+}
+
+func (s *Store) now() time.Time {
+	if s.Now == nil {
+		return time.Now().UTC()
+	}
+	return s.Now().UTC()
+}
+
+// Add appends a comment to the thread of the order, with the mentions in
+// its body.
+func (s *Store) Add(ctx context.Context, orderID uint64, author, body, visibility string) (Comment, error) {
+
+	body = strings.TrimSpace(body)
+
+	if author == "" || body == "" || len(body) > MaxLength || !utf8.ValidString(body) {
+		return Comment{}, ErrInvalidComment
+	}
+
+	if !ValidVisibility(visibility) {
+		return Comment{}, ErrInvalidVisibility
+	}
+
+	c := Comment{
+		OrderID:    orderID,
+		Author:     author,
+		Body:       body,
+		Visibility: visibility,
+		Mentions:   Mentions(body),
+		CreatedAt:  s.now(),
+	}
+
+	id, err := s.Client.XAdd(ctx, &redis.XAddArgs{
+		Stream: key(orderID),
+		MaxLen: MaxComments,
+		Approx: true,
+		Values: map[string]any{
+			"author":     c.Author,
+			"body":       c.Body,
+			"visibility": c.Visibility,
+			"mentions":   strings.Join(c.Mentions, " "),
+			"created_at": c.CreatedAt.Format(time.RFC3339Nano),
+		},
+	}).Result()
+
+	if err != nil {
+		return Comment{}, fmt.Errorf("failed to add comment: %w", err)
+	}
+
+	c.ID = id
+
+	return c, nil
+}
+
+// List reads up to limit comments of the thread after the cursor, only
+// the ones of visibility when it is set.
+func (s *Store) List(ctx context.Context, orderID uint64, visibility, cursor string, limit int) (Page, error) {
+
+	start := "-"
+	if cursor != "" {
+		if !validID(cursor) {
+			return Page{}, ErrInvalidCursor
+		}
+		start = "(" + cursor
+	}
+
+	page := Page{Items: []Comment{}}
+
+	for {
+		entries, err := s.Client.XRangeN(ctx, key(orderID), start, "+", int64(limit)).Result()
+		if err != nil {
+			return Page{}, fmt.Errorf("failed to list comments: %w", err)
+		}
+
+		for _, entry := range entries {
+			c := decode(orderID, entry)
+			if visibility == "" || c.Visibility == visibility {
+				page.Items = append(page.Items, c)
+			}
+			start = "(" + entry.ID
+
+			if len(page.Items) == limit {
+				page.Next = entry.ID
+				return page, nil
+			}
+		}
+
+		if len(entries) < limit {
+			return page, nil
+		}
+	}
+}
+
+// Drop deletes the thread of the order.
+func (s *Store) Drop(ctx context.Context, orderID uint64) error {
+
+	if err := s.Client.Del(ctx, key(orderID)).Err(); err != nil {
+		return fmt.Errorf("failed to drop comments: %w", err)
+	}
+
+	return nil
+}
+
+func decode(orderID uint64, entry redis.XMessage) Comment {
+
+	field := func(name string) string {
+		v, _ := entry.Values[name].(string)
+		return v
+	}
+
+	c := Comment{
+		ID:         entry.ID,
+		OrderID:    orderID,
+		Author:     field("author"),
+		Body:       field("body"),
+		Visibility: field("visibility"),
+		Mentions:   strings.Fields(field("mentions")),
+	}
+
+	c.CreatedAt, _ = time.Parse(time.RFC3339Nano, field("created_at"))
+
+	return c
+}
+
+// validID reports whether id is a stream entry ID, such as 1700000000000-0.
+func validID(id string) bool {
+
+	ms, seq, ok := strings.Cut(id, "-")
+	if !ok {
+		return false
+	}
+
+	_, err1 := strconv.ParseUint(ms, 10, 64)
+	_, err2 := strconv.ParseUint(seq, 10, 64)
+
+	return err1 == nil && err2 == nil
+}
@@ -0,0 +1,80 @@
+package dedup
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+
+	"github.com/i101dev/microservices-NN/model"
+	"github.com/i101dev/microservices-NN/repository/order"
+	"golang.org/x/sync/singleflight"
+)
+
+// Repository collapses concurrent FindByID calls for the same ID into a
+// single fetch from the wrapped repository.
+type Repository struct {
+	order.Repository
+
+	group  singleflight.Group
+	calls  atomic.Uint64
+	shared atomic.Uint64
+}
+
+type Stats struct {
+	Calls   uint64  `json:"calls"`
+	Shared  uint64  `json:"shared"`
+	HitRate float64 `json:"hit_rate"`
+}
+
+func New(repo order.Repository) *Repository {
+	return &Repository{
+		Repository: repo,
+	}
+}
+
+func (r *Repository) FindByID(ctx context.Context, id uint64) (model.Order, error) {
+
+	r.calls.Add(1)
+
+	// The fetch runs on behalf of every waiter, so one caller going away
+	// must not cancel it for the rest.
+	fetchCtx := context.WithoutCancel(ctx)
+
+	var fetched bool
+
+	v, err, _ := r.group.Do(strconv.FormatUint(id, 10), func() (any, error) {
+		fetched = true
+		return r.Repository.FindByID(fetchCtx, id)
+	})
+
+	if !fetched {
+		r.shared.Add(1)
+	}
+
+	// Every waiter gets its own copy, since callers change the orders they
+	// are given and the one fetched is shared by all of them.
+	o, _ := v.(model.Order)
+
+	return o.Clone(), err
+}
+
+func (r *Repository) Stats() Stats {
+
+	stats := Stats{
+		Calls:  r.calls.Load(),
+		Shared: r.shared.Load(),
+	}
+
+	if stats.Calls > 0 {
+		stats.HitRate = float64(stats.Shared) / float64(stats.Calls)
+	}
+
+	return stats
+}
+
+func (r *Repository) ServeStats(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(r.Stats())
+}
@@ -0,0 +1,108 @@
+package dupcheck
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/i101dev/microservices-NN/model"
+	"github.com/i101dev/microservices-NN/tenant"
+	"github.com/redis/go-redis/v9"
+)
+
+var ErrDuplicate = errors.New("order looks like a duplicate")
+
+// releaseScript deletes a fingerprint only while it still points at the
+// order that is giving it up.
+var releaseScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+
+// Detector remembers a fingerprint of every new order for Window, so a
+// second order from the same customer with the same items inside the
+// window is caught. With Reject unset duplicates are only flagged.
+type Detector struct {
+	Client *redis.Client
+	Window time.Duration
+	Reject bool
+}
+
+// Check records o's fingerprint and returns the ID of the earlier order
+// that already holds it, or 0 when o is the first.
+func (d *Detector) Check(ctx context.Context, t tenant.ID, o model.Order) (uint64, error) {
+
+	key := fingerprintKey(t, o)
+
+	ok, err := d.Client.SetNX(ctx, key, o.OrderID, d.Window).Result()
+	if err != nil {
+		return 0, fmt.Errorf("failed to record order fingerprint: %w", err)
+	} else if ok {
+		return 0, nil
+	}
+
+	prev, err := d.Client.Get(ctx, key).Uint64()
+	if errors.Is(err, redis.Nil) {
+		// It expired between the two calls.
+		return 0, nil
+	} else if err != nil {
+		return 0, fmt.Errorf("failed to read order fingerprint: %w", err)
+	}
+
+	return prev, nil
+}
+
+// Release forgets o's fingerprint, for orders that were checked but then
+// never stored, so a retry is not taken for a duplicate of itself.
+func (d *Detector) Release(ctx context.Context, t tenant.ID, o model.Order) error {
+
+	err := releaseScript.Run(ctx, d.Client, []string{fingerprintKey(t, o)}, o.OrderID).Err()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return fmt.Errorf("failed to release order fingerprint: %w", err)
+	}
+
+	return nil
+}
+
+// fingerprintKey hashes the tenant, customer and items. Items are sorted
+// first so the same cart in a different order still matches.
+func fingerprintKey(t tenant.ID, o model.Order) string {
+
+	items := make([]model.LineItem, len(o.LineItems))
+	copy(items, o.LineItems)
+
+	sort.Slice(items, func(i, j int) bool {
+		a, b := items[i], items[j]
+		if a.ItemID != b.ItemID {
+			return a.ItemID.String() < b.ItemID.String()
+		}
+		if a.Quantity != b.Quantity {
+			return a.Quantity < b.Quantity
+		}
+		return a.Price < b.Price
+	})
+
+	h := sha256.New()
+
+	h.Write([]byte(t))
+	h.Write([]byte{0})
+	h.Write([]byte(o.CustomerID.String()))
+
+	for _, item := range items {
+		h.Write([]byte{0})
+		h.Write([]byte(item.ItemID.String()))
+		h.Write([]byte{0})
+		h.Write([]byte(strconv.FormatUint(uint64(item.Quantity), 10)))
+		h.Write([]byte{0})
+		h.Write([]byte(strconv.FormatUint(uint64(item.Price), 10)))
+	}
+
+	return "dup:" + hex.EncodeToString(h.Sum(nil))
+}
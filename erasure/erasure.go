@@ -0,0 +1,225 @@
+package erasure
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/i101dev/microservices-NN/clock"
+	"github.com/i101dev/microservices-NN/events"
+	"github.com/i101dev/microservices-NN/jobs"
+	"github.com/i101dev/microservices-NN/model"
+	"github.com/i101dev/microservices-NN/repository/order"
+	"github.com/i101dev/microservices-NN/tenant"
+	"github.com/redis/go-redis/v9"
+)
+
+const jobType = "customer-erasure"
+
+var ErrNotExist = errors.New("erasure request does not exist")
+
+type Status string
+
+const (
+	StatusPending   Status = "pending"
+	StatusCompleted Status = "completed"
+	StatusFailed    Status = "failed"
+)
+
+type Request struct {
+	RequestID  string    `json:"request_id"`
+	CustomerID uuid.UUID `json:"customer_id"`
+	Status     Status    `json:"status"`
+	Orders     int       `json:"orders"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// Index is anything besides the order store that refers to customers and
+// has to drop them on erasure.
+type Index interface {
+	ForgetCustomer(ctx context.Context, customer uuid.UUID) error
+}
+
+// OrderData is anything kept apart from orders that goes when they are
+// deleted.
+type OrderData interface {
+	Drop(ctx context.Context, orderID uint64) error
+}
+
+// Eraser deletes every order of a customer, with its OrderData, and
+// removes the customer from Indexes. The work runs as a job; the request record it returns can be
+// polled with Status until it completes.
+type Eraser struct {
+	Client  *redis.Client
+	Repo    order.Repository
+	Runner  *jobs.Runner
+	Indexes []Index
+	// OrderData is dropped for every order deleted.
+	OrderData []OrderData
+	// Events receives a customer.erased event when an erasure completes.
+	// It is scrubbed of the customer's entries first.
+	Events    *events.Publisher
+	Retention time.Duration
+	Clock     clock.Clock
+}
+
+type payload struct {
+	RequestID  string    `json:"request_id"`
+	CustomerID uuid.UUID `json:"customer_id"`
+}
+
+func requestKey(id string) string {
+	return "erasure_request:" + id
+}
+
+func (e *Eraser) now() time.Time {
+	if e.Clock == nil {
+		return time.Now().UTC()
+	}
+	return e.Clock.Now().UTC()
+}
+
+// Register adds the erasure job to the runner.
+func (e *Eraser) Register() {
+	e.Runner.Register(jobType, e.run)
+}
+
+func (e *Eraser) Request(ctx context.Context, customer uuid.UUID) (Request, error) {
+
+	req := Request{
+		RequestID:  uuid.NewString(),
+		CustomerID: customer,
+		Status:     StatusPending,
+	}
+
+	key := requestKey(req.RequestID)
+
+	txn := e.Client.TxPipeline()
+
+	txn.HSet(ctx, key, "customer_id", customer.String(), "status", string(req.Status), "orders", 0)
+	txn.Expire(ctx, key, e.Retention)
+
+	if _, err := txn.Exec(ctx); err != nil {
+		return Request{}, fmt.Errorf("failed to record erasure request: %w", err)
+	}
+
+	if _, err := e.Runner.Enqueue(ctx, jobType, payload{RequestID: req.RequestID, CustomerID: customer}); err != nil {
+		e.Client.Del(ctx, key)
+		return Request{}, err
+	}
+
+	return req, nil
+}
+
+func (e *Eraser) Status(ctx context.Context, id string) (Request, error) {
+
+	fields, err := e.Client.HGetAll(ctx, requestKey(id)).Result()
+	if err != nil {
+		return Request{}, fmt.Errorf("failed to get erasure request: %w", err)
+	}
+
+	if len(fields) == 0 {
+		return Request{}, ErrNotExist
+	}
+
+	customer, err := uuid.Parse(fields["customer_id"])
+	if err != nil {
+		return Request{}, fmt.Errorf("failed to parse customer id: %w", err)
+	}
+
+	orders, _ := strconv.Atoi(fields["orders"])
+
+	return Request{
+		RequestID:  id,
+		CustomerID: customer,
+		Status:     Status(fields["status"]),
+		Orders:     orders,
+		Error:      fields["error"],
+	}, nil
+}
+
+func (e *Eraser) run(ctx context.Context, job jobs.Job) error {
+
+	var p payload
+	if err := job.Decode(&p); err != nil {
+		return jobs.Permanent(fmt.Errorf("failed to decode erasure: %w", err))
+	}
+
+	key := requestKey(p.RequestID)
+
+	if err := e.erase(ctx, key, p.CustomerID); err != nil {
+		status := StatusPending
+		if job.Attempts+1 >= e.Runner.MaxAttempts {
+			status = StatusFailed
+		}
+		e.Client.HSet(ctx, key, "status", string(status), "error", err.Error())
+		return err
+	}
+
+	n, err := e.Client.HGet(ctx, key, "orders").Int()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return fmt.Errorf("failed to get erasure request: %w", err)
+	}
+
+	if e.Events != nil {
+		event := events.NewCustomerErased(tenant.FromContext(ctx), p.CustomerID, p.RequestID, n, e.now())
+		if _, err := e.Events.Publish(ctx, event); err != nil {
+			return err
+		}
+	}
+
+	return e.Client.HSet(ctx, key, "status", string(StatusCompleted), "error", "").Err()
+}
+
+// erase deletes the customer's orders, counting them on the request, and
+// then scrubs the indexes. A retry after a partial failure picks up where
+// the last attempt stopped.
+func (e *Eraser) erase(ctx context.Context, key string, customer uuid.UUID) error {
+
+	var ids []uint64
+
+	err := order.ForEachPage(ctx, e.Repo, 100, func(orders []model.Order) error {
+		for _, o := range orders {
+			if o.CustomerID == customer {
+				ids = append(ids, o.OrderID)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to find customer orders: %w", err)
+	}
+
+	for _, id := range ids {
+		for _, data := range e.OrderData {
+			if err := data.Drop(ctx, id); err != nil {
+				return err
+			}
+		}
+
+		err := e.Repo.DeleteByID(ctx, id)
+		if errors.Is(err, order.ErrNotExist) {
+			continue
+		} else if err != nil {
+			return fmt.Errorf("failed to delete order %d: %w", id, err)
+		}
+
+		e.Client.HIncrBy(ctx, key, "orders", 1)
+	}
+
+	indexes := e.Indexes
+	if e.Events != nil {
+		indexes = append(indexes[:len(indexes):len(indexes)], e.Events)
+	}
+
+	for _, index := range indexes {
+		if err := index.ForgetCustomer(ctx, customer); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,119 @@
+package errreport
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/i101dev/microservices-NN/auth"
+	"github.com/i101dev/microservices-NN/recovery"
+	"github.com/i101dev/microservices-NN/repository/order"
+	"github.com/i101dev/microservices-NN/tenant"
+	"github.com/i101dev/microservices-NN/tracecontext"
+)
+
+// Reporter sends failures to an error tracker. Tags carry the request
+// context; implementations add their own release and environment.
+type Reporter interface {
+	Error(ctx context.Context, err error, tags map[string]string)
+	Panic(ctx context.Context, c recovery.Crash, tags map[string]string)
+	// Flush waits up to timeout for reports still being sent.
+	Flush(timeout time.Duration)
+}
+
+type contextKey struct{}
+
+type scope struct {
+	reporter Reporter
+	method   string
+	path     string
+}
+
+// Middleware makes the reporter available to Error for the rest of the
+// request.
+func Middleware(r Reporter) func(http.Handler) http.Handler {
+
+	return func(next http.Handler) http.Handler {
+
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			s := scope{reporter: r, method: req.Method, path: req.URL.Path}
+			next.ServeHTTP(w, req.WithContext(context.WithValue(req.Context(), contextKey{}, s)))
+		})
+	}
+}
+
+// Error reports err to the reporter of the request in ctx. Outside a
+// request, or when no reporter is configured, it does nothing.
+func Error(ctx context.Context, op string, err error) {
+
+	s, ok := ctx.Value(contextKey{}).(scope)
+	if !ok {
+		return
+	}
+
+	tags := Tags(ctx)
+	tags["op"] = op
+
+	s.reporter.Error(ctx, err, tags)
+}
+
+// Tags describes the request in ctx, as far as it is known.
+func Tags(ctx context.Context) map[string]string {
+
+	tags := map[string]string{}
+
+	if id := middleware.GetReqID(ctx); id != "" {
+		tags["request_id"] = id
+	}
+
+	if s, ok := tracecontext.FromContext(ctx); ok {
+		tags["trace_id"] = s.TraceIDString()
+	}
+
+	if t := tenant.FromContext(ctx); t != "" {
+		tags["tenant"] = string(t)
+	}
+
+	if p, ok := auth.FromContext(ctx); ok {
+		tags["subject"] = p.Subject
+	}
+
+	if s, ok := ctx.Value(contextKey{}).(scope); ok {
+		tags["method"] = s.method
+		tags["path"] = s.path
+	}
+
+	return tags
+}
+
+// Panics returns a recovery.Recoverer report function that forwards
+// crashes to r.
+func Panics(r Reporter) func(ctx context.Context, c recovery.Crash) {
+
+	return func(ctx context.Context, c recovery.Crash) {
+		tags := Tags(ctx)
+		tags["where"] = c.Where
+		r.Panic(ctx, c, tags)
+	}
+}
+
+// Corruption reports every repository operation that finds a corrupt
+// order, whether or not a request is behind it; callers usually handle the
+// error, so it would not be seen otherwise.
+func Corruption(r Reporter) order.Interceptor {
+
+	return func(ctx context.Context, call *order.Call, next order.Handler) error {
+
+		err := next(ctx, call)
+
+		if errors.Is(err, order.ErrCorrupt) {
+			tags := Tags(ctx)
+			tags["op"] = string(call.Op)
+			r.Error(ctx, err, tags)
+		}
+
+		return err
+	}
+}
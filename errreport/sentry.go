@@ -0,0 +1,67 @@
+package errreport
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/i101dev/microservices-NN/recovery"
+)
+
+// Sentry reports to a Sentry project. Every event is tagged with the
+// release and environment it was created with.
+type Sentry struct {
+	hub *sentry.Hub
+}
+
+func NewSentry(dsn string, release string, environment string) (*Sentry, error) {
+
+	client, err := sentry.NewClient(sentry.ClientOptions{
+		Dsn:         dsn,
+		Release:     release,
+		Environment: environment,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create sentry client: %w", err)
+	}
+
+	return &Sentry{hub: sentry.NewHub(client, sentry.NewScope())}, nil
+}
+
+func (s *Sentry) Error(ctx context.Context, err error, tags map[string]string) {
+
+	s.hub.WithScope(func(scope *sentry.Scope) {
+		scope.SetTags(tags)
+		s.hub.CaptureException(err)
+	})
+}
+
+func (s *Sentry) Panic(ctx context.Context, c recovery.Crash, tags map[string]string) {
+
+	// Sentry lists frames oldest first.
+	frames := make([]sentry.Frame, len(c.Stack))
+	for i, f := range c.Stack {
+		frames[len(frames)-1-i] = sentry.NewFrame(runtime.Frame{Function: f.Function, File: f.File, Line: f.Line})
+	}
+
+	event := sentry.NewEvent()
+	event.Level = sentry.LevelFatal
+	event.Timestamp = c.At
+	event.Message = "panic: " + c.Panic
+	event.Exception = []sentry.Exception{{
+		Type:       "panic",
+		Value:      c.Panic,
+		Stacktrace: &sentry.Stacktrace{Frames: frames},
+	}}
+
+	s.hub.WithScope(func(scope *sentry.Scope) {
+		scope.SetTags(tags)
+		s.hub.CaptureEvent(event)
+	})
+}
+
+func (s *Sentry) Flush(timeout time.Duration) {
+	s.hub.Flush(timeout)
+}
@@ -0,0 +1,145 @@
+package eta
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/i101dev/microservices-NN/model"
+)
+
+// Estimator works out when an order sent at from should arrive. It
+// returns false when it has no estimate for the order's shipping.
+type Estimator interface {
+	Estimate(o model.Order, from time.Time) (model.DeliveryEstimate, bool)
+}
+
+// Rule is one carrier SLA: orders sent by Method to Country and Region
+// arrive MinDays to MaxDays after they leave. Empty Method, Country and
+// Region match anything.
+type Rule struct {
+	Name    string `json:"name"`
+	Method  string `json:"method"`
+	Country string `json:"country"`
+	Region  string `json:"region"`
+	Carrier string `json:"carrier"`
+	MinDays int    `json:"min_days"`
+	MaxDays int    `json:"max_days"`
+	// BusinessDays counts only Monday to Friday, and parcels handed over
+	// at the weekend leave on Monday.
+	BusinessDays bool `json:"business_days"`
+	// CutoffHour is the hour, in UTC, after which parcels leave the next
+	// day. Zero means there is no cutoff.
+	CutoffHour int `json:"cutoff_hour"`
+}
+
+// Rules is checked in order and the first match wins, so more specific
+// rules go first.
+type Rules []Rule
+
+// Load reads rules from a JSON file holding an array of Rule.
+func Load(path string) (Rules, error) {
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read delivery rules: %w", err)
+	}
+
+	return Parse(data)
+}
+
+func Parse(data []byte) (Rules, error) {
+
+	var rules Rules
+
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, fmt.Errorf("failed to parse delivery rules: %w", err)
+	}
+
+	for i := range rules {
+		r := &rules[i]
+
+		if r.Name == "" {
+			r.Name = fmt.Sprintf("rule %d", i+1)
+		}
+
+		if r.MinDays < 0 || r.MaxDays < r.MinDays {
+			return nil, fmt.Errorf("delivery rule %q: need 0 <= min_days <= max_days", r.Name)
+		}
+
+		if r.CutoffHour < 0 || r.CutoffHour > 23 {
+			return nil, fmt.Errorf("delivery rule %q: cutoff_hour must be between 0 and 23", r.Name)
+		}
+	}
+
+	return rules, nil
+}
+
+func (rs Rules) Estimate(o model.Order, from time.Time) (model.DeliveryEstimate, bool) {
+
+	if o.Shipping == nil {
+		return model.DeliveryEstimate{}, false
+	}
+
+	for _, r := range rs {
+		if r.matches(*o.Shipping) {
+			return r.estimate(from), true
+		}
+	}
+
+	return model.DeliveryEstimate{}, false
+}
+
+func (r Rule) matches(s model.Shipping) bool {
+
+	match := func(want, got string) bool {
+		return want == "" || strings.EqualFold(want, got)
+	}
+
+	return match(r.Method, s.Method) && match(r.Country, s.Country) && match(r.Region, s.Region)
+}
+
+func (r Rule) estimate(from time.Time) model.DeliveryEstimate {
+
+	from = from.UTC()
+	day := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, time.UTC)
+
+	if r.CutoffHour > 0 && from.Hour() >= r.CutoffHour {
+		day = day.AddDate(0, 0, 1)
+	}
+
+	if r.BusinessDays {
+		for weekend(day) {
+			day = day.AddDate(0, 0, 1)
+		}
+	}
+
+	return model.DeliveryEstimate{
+		Earliest: r.addDays(day, r.MinDays),
+		Latest:   r.addDays(day, r.MaxDays),
+		Carrier:  r.Carrier,
+		Rule:     r.Name,
+	}
+}
+
+func (r Rule) addDays(day time.Time, n int) time.Time {
+
+	if !r.BusinessDays {
+		return day.AddDate(0, 0, n)
+	}
+
+	for n > 0 {
+		day = day.AddDate(0, 0, 1)
+		if !weekend(day) {
+			n--
+		}
+	}
+
+	return day
+}
+
+func weekend(day time.Time) bool {
+	return day.Weekday() == time.Saturday || day.Weekday() == time.Sunday
+}
@@ -0,0 +1,405 @@
+package events
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/i101dev/microservices-NN/comment"
+	"github.com/i101dev/microservices-NN/model"
+	"github.com/i101dev/microservices-NN/subscription"
+	"github.com/i101dev/microservices-NN/tenant"
+)
+
+const (
+	TypeOrderCreated       = "order.created"
+	TypeOrderStatusChanged = "order.status_changed"
+	TypeOrderDeleted       = "order.deleted"
+	TypePaymentReminder    = "order.payment_reminder"
+	TypeCustomerErased     = "customer.erased"
+	TypeTrackingUpdated    = "order.tracking_updated"
+	TypePaymentAuthorized  = "order.payment_authorized"
+	TypePaymentCaptured    = "order.payment_captured"
+	TypePaymentRefunded    = "order.payment_refunded"
+	TypeBackorderFulfilled = "order.backorder_fulfilled"
+	TypeCommentMentioned   = "order.comment_mentioned"
+	TypeSLABreached        = "order.sla_breached"
+	TypeOrderExpedited     = "order.expedited"
+	TypeOrderAssigned      = "order.assigned"
+	TypeDisputeOpened      = "order.dispute_opened"
+	TypeDisputeUpdated     = "order.dispute_updated"
+	TypeDisputeClosed      = "order.dispute_closed"
+	TypeDisputeOverdue     = "order.dispute_deadline_missed"
+
+	TypeSubscriptionOrderPlaced   = "subscription.order_placed"
+	TypeSubscriptionPaymentFailed = "subscription.payment_failed"
+)
+
+// Header is embedded in every event so the type and schema version travel
+// with the payload.
+type Header struct {
+	Type          string    `json:"type"`
+	SchemaVersion int       `json:"schema_version"`
+	OccurredAt    time.Time `json:"occurred_at"`
+	Tenant        tenant.ID `json:"tenant,omitempty"`
+}
+
+func (h *Header) Meta() *Header {
+	return h
+}
+
+type Event interface {
+	Meta() *Header
+}
+
+type OrderCreated struct {
+	Header
+	OrderID    uint64           `json:"order_id"`
+	CustomerID uuid.UUID        `json:"customer_id"`
+	LineItems  []model.LineItem `json:"line_items"`
+}
+
+type OrderStatusChanged struct {
+	Header
+	OrderID uint64 `json:"order_id"`
+	From    string `json:"from"`
+	To      string `json:"to"`
+}
+
+type OrderDeleted struct {
+	Header
+	OrderID uint64 `json:"order_id"`
+}
+
+// PaymentReminder is sent a while before an unpaid (still pending) order
+// is cancelled for being stale.
+type PaymentReminder struct {
+	Header
+	OrderID    uint64    `json:"order_id"`
+	CustomerID uuid.UUID `json:"customer_id"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
+
+// CustomerErased is sent once a customer's orders and the indexes that
+// referenced them have been erased.
+type CustomerErased struct {
+	Header
+	CustomerID uuid.UUID `json:"customer_id"`
+	RequestID  string    `json:"request_id"`
+	Orders     int       `json:"orders"`
+}
+
+// TrackingUpdated is sent for every new carrier update about the parcel an
+// order shipped in. Status is the order's status after the update.
+type TrackingUpdated struct {
+	Header
+	OrderID        uint64    `json:"order_id"`
+	Carrier        string    `json:"carrier"`
+	TrackingNumber string    `json:"tracking_number"`
+	Tracking       string    `json:"tracking_status"`
+	Description    string    `json:"description,omitempty"`
+	Location       string    `json:"location,omitempty"`
+	ReportedAt     time.Time `json:"reported_at"`
+	Status         string    `json:"status"`
+}
+
+// PaymentChanged is sent, as one of the order.payment_* types, when the
+// payment provider reports an authorization, capture or refund. Amount is
+// what that notification moved; Payment is the payment state after it.
+type PaymentChanged struct {
+	Header
+	OrderID         uint64        `json:"order_id"`
+	ProviderEventID string        `json:"provider_event_id"`
+	Amount          uint          `json:"amount"`
+	Payment         model.Payment `json:"payment"`
+	Status          string        `json:"status"`
+}
+
+// BackorderFulfilled is sent when the stock a backordered order waited
+// for is reserved and the order can be shipped.
+type BackorderFulfilled struct {
+	Header
+	OrderID   uint64           `json:"order_id"`
+	SplitFrom uint64           `json:"split_from,omitempty"`
+	LineItems []model.LineItem `json:"line_items"`
+}
+
+// CommentMentioned is sent to notify someone mentioned with @handle in a
+// comment on an order, once per handle.
+type CommentMentioned struct {
+	Header
+	OrderID    uint64 `json:"order_id"`
+	CommentID  string `json:"comment_id"`
+	Mentioned  string `json:"mentioned"`
+	Author     string `json:"author"`
+	Visibility string `json:"visibility"`
+	Body       string `json:"body"`
+}
+
+// SLABreached is an alert that an order missed the deadline it had to ship
+// by. ShippedAt is set for orders that shipped late.
+type SLABreached struct {
+	Header
+	OrderID    uint64     `json:"order_id"`
+	CustomerID uuid.UUID  `json:"customer_id"`
+	ShipBy     time.Time  `json:"ship_by"`
+	ShippedAt  *time.Time `json:"shipped_at,omitempty"`
+	Status     string     `json:"status"`
+}
+
+// OrderExpedited is sent when an admin expedites an order, for fulfillment
+// to move it to the front of its work.
+type OrderExpedited struct {
+	Header
+	OrderID     uint64           `json:"order_id"`
+	CustomerID  uuid.UUID        `json:"customer_id"`
+	Priority    string           `json:"priority"`
+	Status      string           `json:"status"`
+	LineItems   []model.LineItem `json:"line_items"`
+	ExpeditedBy string           `json:"expedited_by"`
+	Reason      string           `json:"reason,omitempty"`
+}
+
+// OrderAssigned is sent when an order is routed to the warehouses that
+// send it, with the items each sends.
+type OrderAssigned struct {
+	Header
+	OrderID     uint64             `json:"order_id"`
+	CustomerID  uuid.UUID          `json:"customer_id"`
+	Priority    string             `json:"priority,omitempty"`
+	Shipping    *model.Shipping    `json:"shipping,omitempty"`
+	Assignments []model.Assignment `json:"assignments"`
+}
+
+// DisputeChanged is sent, as one of the order.dispute_* types, when the
+// payment provider opens, updates or closes a dispute of an order, and
+// when a dispute still needs a response after its evidence deadline.
+// ProviderEventID is empty for missed deadlines.
+type DisputeChanged struct {
+	Header
+	OrderID         uint64        `json:"order_id"`
+	CustomerID      uuid.UUID     `json:"customer_id"`
+	ProviderEventID string        `json:"provider_event_id,omitempty"`
+	Dispute         model.Dispute `json:"dispute"`
+	Status          string        `json:"status"`
+}
+
+// SubscriptionOrderPlaced is sent for every order a subscription places
+// and pays for.
+type SubscriptionOrderPlaced struct {
+	Header
+	SubscriptionID string           `json:"subscription_id"`
+	OrderID        uint64           `json:"order_id"`
+	CustomerID     uuid.UUID        `json:"customer_id"`
+	LineItems      []model.LineItem `json:"line_items"`
+	NextRunAt      *time.Time       `json:"next_run_at,omitempty"`
+}
+
+// SubscriptionPaymentFailed is sent when the payment for an order a
+// subscription placed fails. The order is cancelled, and the run retried
+// at NextRunAt unless the subscription has become past due.
+type SubscriptionPaymentFailed struct {
+	Header
+	SubscriptionID string     `json:"subscription_id"`
+	OrderID        uint64     `json:"order_id"`
+	CustomerID     uuid.UUID  `json:"customer_id"`
+	Failures       int        `json:"failures"`
+	Reason         string     `json:"reason"`
+	Status         string     `json:"status"`
+	NextRunAt      *time.Time `json:"next_run_at,omitempty"`
+}
+
+func NewOrderCreated(t tenant.ID, o model.Order, at time.Time) *OrderCreated {
+	return &OrderCreated{
+		Header:     newHeader(TypeOrderCreated, t, at),
+		OrderID:    o.OrderID,
+		CustomerID: o.CustomerID,
+		LineItems:  o.LineItems,
+	}
+}
+
+func NewOrderStatusChanged(t tenant.ID, id uint64, from, to string, at time.Time) *OrderStatusChanged {
+	return &OrderStatusChanged{
+		Header:  newHeader(TypeOrderStatusChanged, t, at),
+		OrderID: id,
+		From:    from,
+		To:      to,
+	}
+}
+
+func NewOrderDeleted(t tenant.ID, id uint64, at time.Time) *OrderDeleted {
+	return &OrderDeleted{
+		Header:  newHeader(TypeOrderDeleted, t, at),
+		OrderID: id,
+	}
+}
+
+func NewPaymentReminder(t tenant.ID, o model.Order, expiresAt time.Time, at time.Time) *PaymentReminder {
+	return &PaymentReminder{
+		Header:     newHeader(TypePaymentReminder, t, at),
+		OrderID:    o.OrderID,
+		CustomerID: o.CustomerID,
+		ExpiresAt:  expiresAt.UTC(),
+	}
+}
+
+func NewCustomerErased(t tenant.ID, customer uuid.UUID, requestID string, orders int, at time.Time) *CustomerErased {
+	return &CustomerErased{
+		Header:     newHeader(TypeCustomerErased, t, at),
+		CustomerID: customer,
+		RequestID:  requestID,
+		Orders:     orders,
+	}
+}
+
+func NewTrackingUpdated(t tenant.ID, o model.Order, description, location string, at time.Time) *TrackingUpdated {
+
+	e := &TrackingUpdated{
+		Header:      newHeader(TypeTrackingUpdated, t, at),
+		OrderID:     o.OrderID,
+		Description: description,
+		Location:    location,
+		Status:      o.Status(),
+	}
+
+	if o.Tracking != nil {
+		e.Carrier = o.Tracking.Carrier
+		e.TrackingNumber = o.Tracking.Number
+		e.Tracking = o.Tracking.Status
+		if o.Tracking.At != nil {
+			e.ReportedAt = o.Tracking.At.UTC()
+		}
+	}
+
+	return e
+}
+
+func NewPaymentChanged(t tenant.ID, eventType string, o model.Order, providerEventID string, amount uint, at time.Time) *PaymentChanged {
+
+	e := &PaymentChanged{
+		Header:          newHeader(eventType, t, at),
+		OrderID:         o.OrderID,
+		ProviderEventID: providerEventID,
+		Amount:          amount,
+		Status:          o.Status(),
+	}
+
+	if o.Payment != nil {
+		e.Payment = *o.Payment
+	}
+
+	return e
+}
+
+func NewBackorderFulfilled(t tenant.ID, o model.Order, at time.Time) *BackorderFulfilled {
+
+	e := &BackorderFulfilled{
+		Header:    newHeader(TypeBackorderFulfilled, t, at),
+		OrderID:   o.OrderID,
+		LineItems: o.LineItems,
+	}
+
+	if o.Backorder != nil {
+		e.SplitFrom = o.Backorder.SplitFrom
+	}
+
+	return e
+}
+
+func NewCommentMentioned(t tenant.ID, c comment.Comment, mentioned string, at time.Time) *CommentMentioned {
+	return &CommentMentioned{
+		Header:     newHeader(TypeCommentMentioned, t, at),
+		OrderID:    c.OrderID,
+		CommentID:  c.ID,
+		Mentioned:  mentioned,
+		Author:     c.Author,
+		Visibility: c.Visibility,
+		Body:       c.Body,
+	}
+}
+
+func NewSLABreached(t tenant.ID, o model.Order, at time.Time) *SLABreached {
+
+	e := &SLABreached{
+		Header:     newHeader(TypeSLABreached, t, at),
+		OrderID:    o.OrderID,
+		CustomerID: o.CustomerID,
+		ShippedAt:  o.ShippedAt,
+		Status:     o.Status(),
+	}
+
+	if o.SLA != nil {
+		e.ShipBy = o.SLA.ShipBy.UTC()
+	}
+
+	return e
+}
+
+func NewOrderExpedited(t tenant.ID, o model.Order, by, reason string, at time.Time) *OrderExpedited {
+	return &OrderExpedited{
+		Header:      newHeader(TypeOrderExpedited, t, at),
+		OrderID:     o.OrderID,
+		CustomerID:  o.CustomerID,
+		Priority:    o.Priority,
+		Status:      o.Status(),
+		LineItems:   o.LineItems,
+		ExpeditedBy: by,
+		Reason:      reason,
+	}
+}
+
+func NewOrderAssigned(t tenant.ID, o model.Order, at time.Time) *OrderAssigned {
+	return &OrderAssigned{
+		Header:      newHeader(TypeOrderAssigned, t, at),
+		OrderID:     o.OrderID,
+		CustomerID:  o.CustomerID,
+		Priority:    o.Priority,
+		Shipping:    o.Shipping,
+		Assignments: o.Assignments,
+	}
+}
+
+func NewDisputeChanged(t tenant.ID, eventType string, o model.Order, d model.Dispute, providerEventID string, at time.Time) *DisputeChanged {
+	return &DisputeChanged{
+		Header:          newHeader(eventType, t, at),
+		OrderID:         o.OrderID,
+		CustomerID:      o.CustomerID,
+		ProviderEventID: providerEventID,
+		Dispute:         d,
+		Status:          o.Status(),
+	}
+}
+
+func NewSubscriptionOrderPlaced(t tenant.ID, s subscription.Subscription, o model.Order, at time.Time) *SubscriptionOrderPlaced {
+
+	return &SubscriptionOrderPlaced{
+		Header:         newHeader(TypeSubscriptionOrderPlaced, t, at),
+		SubscriptionID: s.ID,
+		OrderID:        o.OrderID,
+		CustomerID:     o.CustomerID,
+		LineItems:      o.LineItems,
+		NextRunAt:      s.NextRunAt,
+	}
+}
+
+func NewSubscriptionPaymentFailed(t tenant.ID, s subscription.Subscription, orderID uint64, at time.Time) *SubscriptionPaymentFailed {
+
+	return &SubscriptionPaymentFailed{
+		Header:         newHeader(TypeSubscriptionPaymentFailed, t, at),
+		SubscriptionID: s.ID,
+		OrderID:        orderID,
+		CustomerID:     s.CustomerID,
+		Failures:       s.Failures,
+		Reason:         s.LastError,
+		Status:         s.Status,
+		NextRunAt:      s.NextRunAt,
+	}
+}
+
+func newHeader(eventType string, t tenant.ID, at time.Time) Header {
+	return Header{
+		Type:          eventType,
+		SchemaVersion: Default.Version(eventType),
+		OccurredAt:    at.UTC(),
+		Tenant:        t,
+	}
+}
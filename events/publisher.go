@@ -0,0 +1,113 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+
+	"github.com/google/uuid"
+	"github.com/i101dev/microservices-NN/tracecontext"
+	"github.com/redis/go-redis/v9"
+)
+
+const DefaultStream = "events:orders"
+
+// Publisher appends events to a Redis stream for other services to read
+// with their own consumer groups. Each entry has the event type, its schema
+// version and the JSON payload, which Registry.Decode reads back, and the
+// traceparent of the publishing request when there is one.
+type Publisher struct {
+	Client *redis.Client
+	Stream string
+	// MaxLen approximately caps the stream. Zero keeps every entry.
+	MaxLen int64
+	// Log, if set, gets a debug line for every published event.
+	Log *slog.Logger
+}
+
+func (p *Publisher) stream() string {
+	if p.Stream == "" {
+		return DefaultStream
+	}
+	return p.Stream
+}
+
+func (p *Publisher) Publish(ctx context.Context, e Event) (string, error) {
+
+	data, err := json.Marshal(e)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode %s: %w", e.Meta().Type, err)
+	}
+
+	values := map[string]any{
+		"type":    e.Meta().Type,
+		"version": e.Meta().SchemaVersion,
+		"payload": data,
+	}
+
+	for k, v := range tracecontext.Values(ctx) {
+		values[k] = v
+	}
+
+	id, err := p.Client.XAdd(ctx, &redis.XAddArgs{
+		Stream: p.stream(),
+		MaxLen: p.MaxLen,
+		Approx: p.MaxLen > 0,
+		Values: values,
+	}).Result()
+
+	if err != nil {
+		return "", fmt.Errorf("failed to publish %s: %w", e.Meta().Type, err)
+	}
+
+	if p.Log != nil {
+		p.Log.DebugContext(ctx, "published event", "type", e.Meta().Type, "version", e.Meta().SchemaVersion, "stream", p.stream(), "id", id)
+	}
+
+	return id, nil
+}
+
+// ForgetCustomer deletes the entries that name the customer from the
+// stream, apart from customer.erased events. Consumers that already read
+// them are not affected.
+func (p *Publisher) ForgetCustomer(ctx context.Context, customer uuid.UUID) error {
+
+	start := "-"
+
+	for {
+		entries, err := p.Client.XRangeN(ctx, p.stream(), start, "+", 500).Result()
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", p.stream(), err)
+		}
+
+		var ids []string
+
+		for _, entry := range entries {
+			if entry.Values["type"] == TypeCustomerErased {
+				continue
+			}
+
+			payload, _ := entry.Values["payload"].(string)
+
+			var subject struct {
+				CustomerID uuid.UUID `json:"customer_id"`
+			}
+			if err := json.Unmarshal([]byte(payload), &subject); err == nil && subject.CustomerID == customer {
+				ids = append(ids, entry.ID)
+			}
+		}
+
+		if len(ids) > 0 {
+			if err := p.Client.XDel(ctx, p.stream(), ids...).Err(); err != nil {
+				return fmt.Errorf("failed to delete from %s: %w", p.stream(), err)
+			}
+		}
+
+		if len(entries) < 500 {
+			return nil
+		}
+
+		start = "(" + entries[len(entries)-1].ID
+	}
+}
@@ -0,0 +1,197 @@
+package events
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+var ErrUnknownType = errors.New("unknown event type")
+var ErrUnsupportedVersion = errors.New("unsupported event schema version")
+
+// Upcaster rewrites a decoded payload from one schema version to the next,
+// for example by filling in a field that was added with a default.
+type Upcaster func(payload map[string]any) error
+
+type schema struct {
+	version   int
+	new       func() Event
+	upcasters map[int]Upcaster
+}
+
+type Schema struct {
+	Type    string `json:"type"`
+	Version int    `json:"version"`
+}
+
+// Registry knows the current schema version of every event type and how to
+// bring older payloads up to it.
+type Registry struct {
+	mu      sync.RWMutex
+	schemas map[string]*schema
+}
+
+// Default holds the events emitted by this service.
+var Default = NewRegistry()
+
+func init() {
+	Default.Register(TypeOrderCreated, 1, func() Event { return &OrderCreated{} })
+	Default.Register(TypeOrderStatusChanged, 1, func() Event { return &OrderStatusChanged{} })
+	Default.Register(TypeOrderDeleted, 1, func() Event { return &OrderDeleted{} })
+	Default.Register(TypePaymentReminder, 1, func() Event { return &PaymentReminder{} })
+	Default.Register(TypeCustomerErased, 1, func() Event { return &CustomerErased{} })
+	Default.Register(TypeTrackingUpdated, 1, func() Event { return &TrackingUpdated{} })
+	Default.Register(TypeBackorderFulfilled, 1, func() Event { return &BackorderFulfilled{} })
+	Default.Register(TypeCommentMentioned, 1, func() Event { return &CommentMentioned{} })
+	Default.Register(TypeSLABreached, 1, func() Event { return &SLABreached{} })
+	Default.Register(TypeOrderExpedited, 1, func() Event { return &OrderExpedited{} })
+	Default.Register(TypeOrderAssigned, 1, func() Event { return &OrderAssigned{} })
+	Default.Register(TypeSubscriptionOrderPlaced, 1, func() Event { return &SubscriptionOrderPlaced{} })
+	Default.Register(TypeSubscriptionPaymentFailed, 1, func() Event { return &SubscriptionPaymentFailed{} })
+
+	for _, t := range []string{TypePaymentAuthorized, TypePaymentCaptured, TypePaymentRefunded} {
+		Default.Register(t, 1, func() Event { return &PaymentChanged{} })
+	}
+
+	for _, t := range []string{TypeDisputeOpened, TypeDisputeUpdated, TypeDisputeClosed, TypeDisputeOverdue} {
+		Default.Register(t, 1, func() Event { return &DisputeChanged{} })
+	}
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		schemas: map[string]*schema{},
+	}
+}
+
+// Register sets the current version of an event type and the struct its
+// payloads decode into. Bumping the version needs an Upcast from the old
+// one, or older payloads stop decoding.
+func (r *Registry) Register(eventType string, version int, newEvent func() Event) {
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	s := r.schema(eventType)
+	s.version = version
+	s.new = newEvent
+}
+
+// Upcast registers fn to turn version from of eventType into from+1.
+func (r *Registry) Upcast(eventType string, from int, fn Upcaster) {
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.schema(eventType).upcasters[from] = fn
+}
+
+func (r *Registry) schema(eventType string) *schema {
+
+	s, ok := r.schemas[eventType]
+	if !ok {
+		s = &schema{upcasters: map[int]Upcaster{}}
+		r.schemas[eventType] = s
+	}
+
+	return s
+}
+
+// Version returns the current version of eventType, or 0 if it is unknown.
+func (r *Registry) Version(eventType string) int {
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if s, ok := r.schemas[eventType]; ok {
+		return s.version
+	}
+
+	return 0
+}
+
+func (r *Registry) Schemas() []Schema {
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	out := make([]Schema, 0, len(r.schemas))
+	for t, s := range r.schemas {
+		out = append(out, Schema{Type: t, Version: s.version})
+	}
+
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Type < out[j].Type
+	})
+
+	return out
+}
+
+// Decode parses a payload of any known version, running the upcasters
+// between its version and the current one, and returns the current struct.
+func (r *Registry) Decode(data []byte) (Event, error) {
+
+	var payload map[string]any
+
+	// Numbers stay json.Number so order IDs survive the round trip.
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	if err := dec.Decode(&payload); err != nil {
+		return nil, fmt.Errorf("failed to decode event: %w", err)
+	}
+
+	eventType, _ := payload["type"].(string)
+
+	// A payload without a version predates versioning and is v1.
+	version := 1
+	if v, ok := payload["schema_version"].(json.Number); ok {
+		n, err := v.Int64()
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrUnsupportedVersion, v)
+		}
+		version = int(n)
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	s, ok := r.schemas[eventType]
+
+	if !ok || s.new == nil {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownType, eventType)
+	}
+
+	if version < 1 || version > s.version {
+		return nil, fmt.Errorf("%w: %s v%d, current is v%d", ErrUnsupportedVersion, eventType, version, s.version)
+	}
+
+	for ; version < s.version; version++ {
+
+		up, ok := s.upcasters[version]
+		if !ok {
+			return nil, fmt.Errorf("%w: no upcaster for %s v%d", ErrUnsupportedVersion, eventType, version)
+		}
+
+		if err := up(payload); err != nil {
+			return nil, fmt.Errorf("failed to upcast %s v%d: %w", eventType, version, err)
+		}
+	}
+
+	payload["schema_version"] = s.version
+
+	upcast, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode upcast event: %w", err)
+	}
+
+	event := s.new()
+	if err := json.Unmarshal(upcast, event); err != nil {
+		return nil, fmt.Errorf("failed to decode %s: %w", eventType, err)
+	}
+
+	return event, nil
+}
@@ -0,0 +1,85 @@
+package fraud
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/i101dev/microservices-NN/model"
+)
+
+const (
+	DecisionAllow  = "allow"
+	DecisionReview = "review"
+)
+
+type Verdict struct {
+	Decision string `json:"decision"`
+	Reason   string `json:"reason,omitempty"`
+}
+
+// Checker screens a new order before it is accepted. Orders that get a
+// review verdict are stored with status review until approved.
+type Checker interface {
+	Check(ctx context.Context, o model.Order) (Verdict, error)
+}
+
+type AllowAll struct{}
+
+func (AllowAll) Check(ctx context.Context, o model.Order) (Verdict, error) {
+	return Verdict{Decision: DecisionAllow}, nil
+}
+
+// HTTPChecker posts the order as JSON to URL and expects a Verdict back.
+type HTTPChecker struct {
+	URL    string
+	Client *http.Client
+}
+
+func (c *HTTPChecker) httpClient() *http.Client {
+	if c.Client == nil {
+		return http.DefaultClient
+	}
+	return c.Client
+}
+
+func (c *HTTPChecker) Check(ctx context.Context, o model.Order) (Verdict, error) {
+
+	data, err := json.Marshal(o)
+	if err != nil {
+		return Verdict{}, fmt.Errorf("failed to encode order: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.URL, bytes.NewReader(data))
+	if err != nil {
+		return Verdict{}, fmt.Errorf("failed to build request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := c.httpClient().Do(req)
+	if err != nil {
+		return Verdict{}, fmt.Errorf("failed to call fraud check: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(res.Body, 512))
+		return Verdict{}, fmt.Errorf("fraud check returned %d: %s", res.StatusCode, bytes.TrimSpace(body))
+	}
+
+	var v Verdict
+
+	if err := json.NewDecoder(res.Body).Decode(&v); err != nil {
+		return Verdict{}, fmt.Errorf("failed to decode verdict: %w", err)
+	}
+
+	if v.Decision != DecisionAllow && v.Decision != DecisionReview {
+		return Verdict{}, fmt.Errorf("unknown fraud check decision %q", v.Decision)
+	}
+
+	return v, nil
+}
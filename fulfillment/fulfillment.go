@@ -0,0 +1,260 @@
+// Package fulfillment routes orders to the warehouses that send them, by
+// rules on where they ship and what the warehouses have in stock.
+package fulfillment
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/i101dev/microservices-NN/inventory"
+	"github.com/i101dev/microservices-NN/model"
+)
+
+var (
+	ErrUnknownWarehouse = errors.New("unknown warehouse")
+	// ErrUnroutable is returned for orders no rule matches.
+	ErrUnroutable = errors.New("no fulfillment rule matches the order")
+)
+
+type Warehouse struct {
+	ID   string `json:"id"`
+	Name string `json:"name,omitempty"`
+	// Country is an ISO 3166-1 alpha-2 code.
+	Country string `json:"country,omitempty"`
+}
+
+// Rule sends orders shipped by Method to Country and Region to one of
+// Warehouses, the first that has everything in stock, or splits them
+// across them, in order, when none has. Empty Method, Country and Region
+// match anything.
+type Rule struct {
+	Name       string   `json:"name"`
+	Method     string   `json:"method"`
+	Country    string   `json:"country"`
+	Region     string   `json:"region"`
+	Warehouses []string `json:"warehouses"`
+}
+
+// Config is the warehouses orders are sent from and the rules that pick
+// them. Rules are checked in order and the first match wins, so more
+// specific rules go first.
+type Config struct {
+	Warehouses []Warehouse `json:"warehouses"`
+	Rules      []Rule      `json:"rules"`
+}
+
+var idPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_.-]{0,63}$`)
+
+// Load reads a JSON file holding a Config.
+func Load(path string) (*Config, error) {
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read fulfillment rules: %w", err)
+	}
+
+	return Parse(data)
+}
+
+func Parse(data []byte) (*Config, error) {
+
+	var c Config
+
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("failed to parse fulfillment rules: %w", err)
+	}
+
+	for i, w := range c.Warehouses {
+		if !idPattern.MatchString(w.ID) {
+			return nil, fmt.Errorf("warehouse %q: ids are up to 64 lower case letters, digits, dots, dashes and underscores", w.ID)
+		}
+		if slices.ContainsFunc(c.Warehouses[:i], func(other Warehouse) bool { return other.ID == w.ID }) {
+			return nil, fmt.Errorf("warehouse %q is listed twice", w.ID)
+		}
+	}
+
+	for i := range c.Rules {
+		r := &c.Rules[i]
+
+		if r.Name == "" {
+			r.Name = fmt.Sprintf("rule %d", i+1)
+		}
+
+		if len(r.Warehouses) == 0 {
+			return nil, fmt.Errorf("fulfillment rule %q: needs at least one warehouse", r.Name)
+		}
+
+		for _, id := range r.Warehouses {
+			if _, ok := c.Warehouse(id); !ok {
+				return nil, fmt.Errorf("fulfillment rule %q: %w %q", r.Name, ErrUnknownWarehouse, id)
+			}
+		}
+	}
+
+	return &c, nil
+}
+
+func (c *Config) Warehouse(id string) (Warehouse, bool) {
+
+	i := slices.IndexFunc(c.Warehouses, func(w Warehouse) bool {
+		return w.ID == id
+	})
+	if i < 0 {
+		return Warehouse{}, false
+	}
+
+	return c.Warehouses[i], true
+}
+
+// Route returns the first rule that matches the order. Orders without
+// shipping details only match rules that match anything.
+func (c *Config) Route(o model.Order) (Rule, error) {
+
+	var s model.Shipping
+	if o.Shipping != nil {
+		s = *o.Shipping
+	}
+
+	for _, r := range c.Rules {
+		if r.matches(s) {
+			return r, nil
+		}
+	}
+
+	return Rule{}, ErrUnroutable
+}
+
+func (r Rule) matches(s model.Shipping) bool {
+
+	match := func(want, got string) bool {
+		return want == "" || strings.EqualFold(want, got)
+	}
+
+	return match(r.Method, s.Method) && match(r.Country, s.Country) && match(r.Region, s.Region)
+}
+
+// Stock tells how much of items a warehouse has. Available returns the
+// quantities it can send, which may be less than asked for or nothing.
+type Stock interface {
+	Available(ctx context.Context, warehouse string, items []model.LineItem) ([]model.LineItem, error)
+}
+
+// Assigner works out which warehouses send an order.
+type Assigner struct {
+	Config *Config
+	// Stock, when set, is asked which warehouses have the items. Nil sends
+	// every order from the first warehouse of its rule.
+	Stock Stock
+}
+
+// Assign routes the order by its rule. It goes whole to the first of the
+// rule's warehouses that has all of it, or is split across them in order,
+// each sending what it has. Whatever none has is left to the first
+// warehouse, to send once it is restocked.
+func (a *Assigner) Assign(ctx context.Context, o model.Order) ([]model.Assignment, error) {
+
+	rule, err := a.Config.Route(o)
+	if err != nil {
+		return nil, err
+	}
+
+	if a.Stock == nil {
+		return []model.Assignment{{Warehouse: rule.Warehouses[0], LineItems: o.LineItems, Rule: rule.Name}}, nil
+	}
+
+	available := make([][]model.LineItem, len(rule.Warehouses))
+
+	for i, id := range rule.Warehouses {
+		items, err := a.Stock.Available(ctx, id, o.LineItems)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check stock at %s: %w", id, err)
+		}
+
+		if _, short := inventory.Shortfall(o.LineItems, items); len(short) == 0 {
+			return []model.Assignment{{Warehouse: id, LineItems: o.LineItems, Rule: rule.Name}}, nil
+		}
+
+		available[i] = items
+	}
+
+	var assignments []model.Assignment
+	remaining := o.LineItems
+
+	for i, id := range rule.Warehouses {
+		fulfilled, short := inventory.Shortfall(remaining, available[i])
+		if len(fulfilled) > 0 {
+			assignments = append(assignments, model.Assignment{Warehouse: id, LineItems: fulfilled, Rule: rule.Name})
+		}
+
+		remaining = short
+		if len(remaining) == 0 {
+			return assignments, nil
+		}
+	}
+
+	if len(assignments) > 0 && assignments[0].Warehouse == rule.Warehouses[0] {
+		assignments[0].LineItems = model.CombineLineItems(assignments[0].LineItems, remaining)
+		return assignments, nil
+	}
+
+	return append([]model.Assignment{{Warehouse: rule.Warehouses[0], LineItems: remaining, Rule: rule.Name}}, assignments...), nil
+}
+
+// HTTPStock posts {"warehouse", "line_items"} as JSON to URL+"/availability"
+// and expects {"available": [...]} back.
+type HTTPStock struct {
+	URL    string
+	Client *http.Client
+}
+
+func (c *HTTPStock) httpClient() *http.Client {
+	if c.Client == nil {
+		return http.DefaultClient
+	}
+	return c.Client
+}
+
+func (c *HTTPStock) Available(ctx context.Context, warehouse string, items []model.LineItem) ([]model.LineItem, error) {
+
+	data, err := json.Marshal(map[string]any{"warehouse": warehouse, "line_items": items})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode availability: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.URL+"/availability", bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to build request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := c.httpClient().Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to call stock service: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(res.Body, 512))
+		return nil, fmt.Errorf("stock service returned %d: %s", res.StatusCode, bytes.TrimSpace(body))
+	}
+
+	var body struct {
+		Available []model.LineItem `json:"available"`
+	}
+
+	if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("failed to decode availability: %w", err)
+	}
+
+	return body.Available, nil
+}
@@ -0,0 +1,245 @@
+package fulfillment
+
+import (
+	"bytes"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/go-pdf/fpdf"
+	"github.com/google/uuid"
+	"github.com/i101dev/microservices-NN/model"
+)
+
+// PickList is what a warehouse picks for its open orders: every item
+// once, with the total to pick across the orders, and a packing slip for
+// each order with its part of them.
+type PickList struct {
+	Warehouse string `json:"warehouse"`
+	// Date is the day the orders were placed, or empty for all of them.
+	Date        string        `json:"date,omitempty"`
+	GeneratedAt time.Time     `json:"generated_at"`
+	Lines       []PickLine    `json:"lines"`
+	Slips       []PackingSlip `json:"packing_slips"`
+}
+
+type PickLine struct {
+	ItemID   uuid.UUID `json:"item_id"`
+	Quantity uint      `json:"quantity"`
+	// Orders is how many orders the item goes in.
+	Orders int `json:"orders"`
+}
+
+// PackingSlip is the part of an order one warehouse packs.
+type PackingSlip struct {
+	OrderID    uint64           `json:"order_id"`
+	CustomerID uuid.UUID        `json:"customer_id"`
+	Priority   string           `json:"priority,omitempty"`
+	Shipping   *model.Shipping  `json:"shipping,omitempty"`
+	LineItems  []model.LineItem `json:"line_items"`
+}
+
+// NewPickList gathers what the warehouse sends of orders, which are
+// listed oldest first. Lines are sorted by item, and expedited orders
+// are packed first.
+func NewPickList(warehouse string, orders []model.Order, now time.Time) PickList {
+
+	p := PickList{
+		Warehouse:   warehouse,
+		GeneratedAt: now.UTC(),
+		Lines:       []PickLine{},
+		Slips:       []PackingSlip{},
+	}
+
+	for _, o := range orders {
+		slip := PackingSlip{
+			OrderID:    o.OrderID,
+			CustomerID: o.CustomerID,
+			Priority:   o.Priority,
+			Shipping:   o.Shipping,
+		}
+
+		for _, a := range o.Assignments {
+			if a.Warehouse == warehouse {
+				slip.LineItems = append(slip.LineItems, a.LineItems...)
+			}
+		}
+
+		if len(slip.LineItems) == 0 {
+			continue
+		}
+
+		var picked []uuid.UUID
+
+		for _, item := range slip.LineItems {
+			i := slices.IndexFunc(p.Lines, func(l PickLine) bool { return l.ItemID == item.ItemID })
+			if i < 0 {
+				p.Lines = append(p.Lines, PickLine{ItemID: item.ItemID})
+				i = len(p.Lines) - 1
+			}
+
+			p.Lines[i].Quantity += item.Quantity
+			if !slices.Contains(picked, item.ItemID) {
+				p.Lines[i].Orders++
+				picked = append(picked, item.ItemID)
+			}
+		}
+
+		p.Slips = append(p.Slips, slip)
+	}
+
+	slices.SortFunc(p.Lines, func(a, b PickLine) int {
+		return strings.Compare(a.ItemID.String(), b.ItemID.String())
+	})
+
+	slices.SortStableFunc(p.Slips, func(a, b PackingSlip) int {
+		switch {
+		case a.Priority == b.Priority:
+			return 0
+		case a.Priority == model.PriorityExpedited:
+			return -1
+		case b.Priority == model.PriorityExpedited:
+			return 1
+		}
+		return 0
+	})
+
+	return p
+}
+
+// Rows lays the pick list out as a table with a header row, for CSV.
+func (p PickList) Rows() [][]string {
+
+	rows := [][]string{{"item_id", "quantity", "orders"}}
+
+	for _, l := range p.Lines {
+		rows = append(rows, []string{l.ItemID.String(), fmt.Sprint(l.Quantity), fmt.Sprint(l.Orders)})
+	}
+
+	return rows
+}
+
+// RenderPickList lays the pick list out as an A4 PDF, the list itself
+// first and then one packing slip per page.
+func RenderPickList(p PickList) ([]byte, error) {
+
+	title := "Pick list " + p.Warehouse
+	if p.Date != "" {
+		title += " " + p.Date
+	}
+
+	pdf := fpdf.New("P", "mm", "A4", "")
+	pdf.SetCatalogSort(true)
+	pdf.SetTitle(title, false)
+	pdf.SetCreationDate(p.GeneratedAt)
+	pdf.SetModificationDate(p.GeneratedAt)
+	pdf.SetAutoPageBreak(true, 20)
+	pdf.AliasNbPages("")
+
+	pdf.SetFooterFunc(func() {
+		pdf.SetY(-15)
+		pdf.SetFont("Helvetica", "", 8)
+		pdf.CellFormat(0, 10, fmt.Sprintf("%s - page %d of {nb}", title, pdf.PageNo()), "", 0, "C", false, 0, "")
+	})
+
+	_, pageHeight := pdf.GetPageSize()
+
+	// table writes rows under a bold header, repeating it on every page.
+	table := func(widths []float64, header []string, rows [][]string) {
+		head := func() {
+			pdf.SetFont("Helvetica", "B", 10)
+			for i, title := range header {
+				pdf.CellFormat(widths[i], 8, title, "B", 0, align(i), false, 0, "")
+			}
+			pdf.Ln(-1)
+			pdf.SetFont("Helvetica", "", 10)
+		}
+
+		head()
+		for _, row := range rows {
+			if pdf.GetY() > pageHeight-30 {
+				pdf.AddPage()
+				head()
+			}
+			for i, cell := range row {
+				pdf.CellFormat(widths[i], 7, cell, "", 0, align(i), false, 0, "")
+			}
+			pdf.Ln(-1)
+		}
+	}
+
+	placed := p.Date
+	if placed == "" {
+		placed = "any day"
+	}
+
+	pdf.AddPage()
+
+	pdf.SetFont("Helvetica", "B", 20)
+	pdf.CellFormat(0, 12, "Pick list", "", 1, "R", false, 0, "")
+	fields(pdf, [][2]string{
+		{"Warehouse", p.Warehouse},
+		{"Orders placed", placed},
+		{"Generated", p.GeneratedAt.Format("2 January 2006 15:04 MST")},
+		{"Orders", fmt.Sprint(len(p.Slips))},
+	})
+
+	rows := p.Rows()
+	table([]float64{110, 35, 35}, []string{"Item", "Qty", "Orders"}, rows[1:])
+
+	for _, slip := range p.Slips {
+		pdf.AddPage()
+
+		pdf.SetFont("Helvetica", "B", 20)
+		pdf.CellFormat(0, 12, "Packing slip", "", 1, "R", false, 0, "")
+
+		info := [][2]string{
+			{"Order", fmt.Sprint(slip.OrderID)},
+			{"Customer", slip.CustomerID.String()},
+			{"Warehouse", p.Warehouse},
+		}
+		if slip.Priority != "" {
+			info = append(info, [2]string{"Priority", slip.Priority})
+		}
+		if s := slip.Shipping; s != nil {
+			info = append(info, [2]string{"Method", s.Method}, [2]string{"Ship to", strings.TrimSuffix(s.Country+" "+s.Region, " ")})
+		}
+		fields(pdf, info)
+
+		lines := make([][]string, len(slip.LineItems))
+		for i, item := range slip.LineItems {
+			lines[i] = []string{item.ItemID.String(), fmt.Sprint(item.Quantity), ""}
+		}
+		table([]float64{110, 35, 35}, []string{"Item", "Qty", "Packed"}, lines)
+	}
+
+	var out bytes.Buffer
+
+	if err := pdf.Output(&out); err != nil {
+		return nil, fmt.Errorf("failed to render pick list: %w", err)
+	}
+
+	return out.Bytes(), nil
+}
+
+// fields writes label and value pairs, one per line.
+func fields(pdf *fpdf.Fpdf, pairs [][2]string) {
+
+	for _, field := range pairs {
+		pdf.SetFont("Helvetica", "B", 10)
+		pdf.CellFormat(35, 6, field[0], "", 0, "L", false, 0, "")
+		pdf.SetFont("Helvetica", "", 10)
+		pdf.CellFormat(0, 6, field[1], "", 1, "L", false, 0, "")
+	}
+	pdf.Ln(6)
+}
+
+// align puts the first column of a table on the left and numbers on the
+// right.
+func align(column int) string {
+	if column == 0 {
+		return "L"
+	}
+	return "R"
+}
@@ -0,0 +1,184 @@
+// Package giftcard keeps the balances of gift cards and of customers'
+// store credit, which orders can be paid with in part or in full.
+package giftcard
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"regexp"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	ErrUnknownCard = errors.New("unknown gift card")
+	ErrCardExists  = errors.New("gift card already exists")
+	ErrNoBalance   = errors.New("no balance left")
+)
+
+// validCode is what codes may look like, whether issued here or not.
+var validCode = regexp.MustCompile(`^[A-Za-z0-9-]{4,64}$`)
+
+func ValidCode(code string) bool {
+	return validCode.MatchString(code)
+}
+
+// NewCode returns a random code in four groups of four, like
+// "K7QM-2XTP-9VHD-A3LR".
+func NewCode() string {
+
+	var raw [10]byte
+	rand.Read(raw[:])
+
+	s := base32.StdEncoding.EncodeToString(raw[:])
+
+	return s[0:4] + "-" + s[4:8] + "-" + s[8:12] + "-" + s[12:16]
+}
+
+// redeemScript takes up to ARGV[1] from the balance at KEYS[1] and
+// returns what it took, or -1 when there is no balance to take from.
+var redeemScript = redis.NewScript(`
+local balance = tonumber(redis.call('GET', KEYS[1]))
+if not balance then
+	return -1
+end
+local take = math.min(balance, tonumber(ARGV[1]))
+if take > 0 then
+	redis.call('DECRBY', KEYS[1], take)
+end
+return take
+`)
+
+// Balances stores one counter per gift card code and one per customer
+// with store credit. Amounts are in the same minor units as line item
+// prices.
+type Balances struct {
+	Client *redis.Client
+}
+
+func cardKey(code string) string {
+	return "giftcard:" + code
+}
+
+func creditKey(customer uuid.UUID) string {
+	return "credit:" + customer.String()
+}
+
+// Issue creates a gift card holding amount. It fails with ErrCardExists
+// when the code is taken.
+func (b *Balances) Issue(ctx context.Context, code string, amount uint) error {
+
+	ok, err := b.Client.SetNX(ctx, cardKey(code), amount, 0).Result()
+	if err != nil {
+		return fmt.Errorf("failed to issue gift card: %w", err)
+	} else if !ok {
+		return ErrCardExists
+	}
+
+	return nil
+}
+
+// Card returns what is left on a gift card.
+func (b *Balances) Card(ctx context.Context, code string) (uint, error) {
+
+	n, err := b.Client.Get(ctx, cardKey(code)).Uint64()
+	if errors.Is(err, redis.Nil) {
+		return 0, ErrUnknownCard
+	} else if err != nil {
+		return 0, fmt.Errorf("failed to read gift card: %w", err)
+	}
+
+	return uint(n), nil
+}
+
+// Credit returns the store credit of a customer, zero for customers who
+// never had any.
+func (b *Balances) Credit(ctx context.Context, customer uuid.UUID) (uint, error) {
+
+	n, err := b.Client.Get(ctx, creditKey(customer)).Uint64()
+	if errors.Is(err, redis.Nil) {
+		return 0, nil
+	} else if err != nil {
+		return 0, fmt.Errorf("failed to read store credit: %w", err)
+	}
+
+	return uint(n), nil
+}
+
+// RedeemCard takes up to amount from a gift card and returns what it
+// took, which is less when the card holds less. It fails with
+// ErrNoBalance when the card is empty.
+func (b *Balances) RedeemCard(ctx context.Context, code string, amount uint) (uint, error) {
+
+	taken, err := b.redeem(ctx, cardKey(code), amount)
+	if errors.Is(err, redis.Nil) {
+		return 0, ErrUnknownCard
+	}
+
+	return taken, err
+}
+
+// RedeemCredit takes up to amount from a customer's store credit, like
+// RedeemCard.
+func (b *Balances) RedeemCredit(ctx context.Context, customer uuid.UUID, amount uint) (uint, error) {
+
+	taken, err := b.redeem(ctx, creditKey(customer), amount)
+	if errors.Is(err, redis.Nil) {
+		return 0, ErrNoBalance
+	}
+
+	return taken, err
+}
+
+// redeem returns redis.Nil when there is no balance at key at all.
+func (b *Balances) redeem(ctx context.Context, key string, amount uint) (uint, error) {
+
+	n, err := redeemScript.Run(ctx, b.Client, []string{key}, amount).Int64()
+	if err != nil {
+		return 0, fmt.Errorf("failed to redeem balance: %w", err)
+	}
+
+	switch {
+	case n < 0:
+		return 0, redis.Nil
+	case n == 0:
+		return 0, ErrNoBalance
+	}
+
+	return uint(n), nil
+}
+
+// RefundCard puts amount back on a gift card, for redemptions that were
+// never recorded on an order.
+func (b *Balances) RefundCard(ctx context.Context, code string, amount uint) error {
+
+	if err := b.Client.IncrBy(ctx, cardKey(code), int64(amount)).Err(); err != nil {
+		return fmt.Errorf("failed to refund gift card: %w", err)
+	}
+
+	return nil
+}
+
+// AddCredit adds amount to a customer's store credit.
+func (b *Balances) AddCredit(ctx context.Context, customer uuid.UUID, amount uint) error {
+
+	if err := b.Client.IncrBy(ctx, creditKey(customer), int64(amount)).Err(); err != nil {
+		return fmt.Errorf("failed to add store credit: %w", err)
+	}
+
+	return nil
+}
+
+// ForgetCustomer drops a customer's store credit.
+func (b *Balances) ForgetCustomer(ctx context.Context, customer uuid.UUID) error {
+
+	if err := b.Client.Del(ctx, creditKey(customer)).Err(); err != nil {
+		return fmt.Errorf("failed to drop store credit: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,110 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/i101dev/microservices-NN/cancellation"
+	"github.com/i101dev/microservices-NN/graph/model"
+	"github.com/i101dev/microservices-NN/maintenance"
+	model1 "github.com/i101dev/microservices-NN/model"
+	"github.com/i101dev/microservices-NN/org"
+	"github.com/i101dev/microservices-NN/repository/order"
+)
+
+const defaultPageSize = 50
+const maxPageSize = 500
+
+// A cursor of the orders query is the repository cursor of the page to
+// read next, with how many orders of that page were already returned in
+// its low skipBits bits, so a query can stop partway through a page.
+// Repository pages are never near 1<<skipBits orders, and scan cursors
+// never near 1<<(64-skipBits).
+const skipBits = 16
+
+// scanSize is the size of the repository pages the orders query reads.
+// It does not follow first, so a cursor points into the same page
+// whatever size the next query asks for.
+const scanSize = 100
+
+func joinCursor(offset, skip uint64) uint64 {
+	return offset<<skipBits | skip
+}
+
+func splitCursor(cursor uint64) (offset, skip uint64) {
+	return cursor >> skipBits, cursor & (1<<skipBits - 1)
+}
+
+var errInternal = errors.New("internal server error")
+
+func (r *mutationResolver) transition(ctx context.Context, id uint64, status string) (*model1.Order, error) {
+
+	if err := r.authorizeChange(ctx, id); err != nil {
+		return nil, err
+	}
+
+	if status == model1.StatusCancelled {
+		return changed(r.Orders.Cancel(ctx, id, ""))
+	}
+
+	return changed(r.Orders.Transition(ctx, id, status))
+}
+
+// authorizeChange checks the caller may change the order, which takes the
+// manager role in its organization.
+func (r *mutationResolver) authorizeChange(ctx context.Context, id uint64) error {
+
+	if r.Orgs == nil {
+		return nil
+	}
+
+	o, err := r.Repo.FindByID(ctx, id)
+	if err != nil {
+		_, err = changed(o, err)
+		return err
+	}
+
+	return authorized(r.Orgs.CanCancel(ctx, o))
+}
+
+// authorized passes on the errors of an organization check the caller
+// should see, and hides the rest.
+func authorized(err error) error {
+
+	if err == nil || errors.Is(err, org.ErrUnauthenticated) || errors.Is(err, org.ErrForbidden) {
+		return err
+	}
+
+	fmt.Println("failed to authorize order:", err)
+	return errInternal
+}
+
+func changed(o model1.Order, err error) (*model1.Order, error) {
+
+	if errors.Is(err, order.ErrNotExist) || errors.Is(err, model1.ErrInvalidTransition) || errors.Is(err, cancellation.ErrRejected) || errors.Is(err, maintenance.ErrReadOnly) {
+		return nil, err
+	} else if err != nil {
+		fmt.Println("failed to transition:", err)
+		return nil, errInternal
+	}
+
+	return &o, nil
+}
+
+func matchesFilter(o *model1.Order, filter *model.OrderFilter) bool {
+
+	if filter == nil {
+		return true
+	}
+
+	if filter.CustomerID != nil && o.CustomerID != *filter.CustomerID {
+		return false
+	}
+
+	if filter.Status != nil && o.Status() != *filter.Status {
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,29 @@
+// Code generated by github.com/99designs/gqlgen, DO NOT EDIT.
+
+package model
+
+import (
+	"github.com/google/uuid"
+)
+
+type LineItemInput struct {
+	ItemID   uuid.UUID `json:"itemID"`
+	Quantity uint      `json:"quantity"`
+	Price    uint      `json:"price"`
+}
+
+type Mutation struct {
+}
+
+type NewOrder struct {
+	CustomerID uuid.UUID        `json:"customerID"`
+	LineItems  []*LineItemInput `json:"lineItems"`
+}
+
+type OrderFilter struct {
+	CustomerID *uuid.UUID `json:"customerID,omitempty"`
+	Status     *string    `json:"status,omitempty"`
+}
+
+type Query struct {
+}
@@ -0,0 +1,13 @@
+package model
+
+import "github.com/i101dev/microservices-NN/model"
+
+// OrderPage is bound explicitly in gqlgen.yml. Autobind would otherwise
+// pick model.OrderPage, whose Next cannot be null.
+type OrderPage struct {
+	Items []*model.Order `json:"items"`
+	Next  *uint64        `json:"next,omitempty"`
+	// Snapshot is the copy of the orders the pages are read from, to be
+	// sent back with Next.
+	Snapshot *string `json:"snapshot,omitempty"`
+}
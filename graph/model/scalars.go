@@ -0,0 +1,35 @@
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/99designs/gqlgen/graphql"
+)
+
+func MarshalOrderID(id uint64) graphql.Marshaler {
+	return graphql.WriterFunc(func(w io.Writer) {
+		io.WriteString(w, strconv.Quote(strconv.FormatUint(id, 10)))
+	})
+}
+
+func UnmarshalOrderID(v any) (uint64, error) {
+
+	const base = 10
+	const bitSize = 64
+
+	switch v := v.(type) {
+	case string:
+		return strconv.ParseUint(v, base, bitSize)
+	case json.Number:
+		return strconv.ParseUint(string(v), base, bitSize)
+	case int:
+		return uint64(v), nil
+	case int64:
+		return uint64(v), nil
+	default:
+		return 0, fmt.Errorf("%T is not a valid order ID", v)
+	}
+}
@@ -0,0 +1,18 @@
+package graph
+
+import (
+	"github.com/i101dev/microservices-NN/org"
+	"github.com/i101dev/microservices-NN/repository/order"
+	"github.com/i101dev/microservices-NN/service"
+)
+
+// This file will not be regenerated automatically.
+//
+// It serves as dependency injection for your app, add any dependencies you require here.
+
+type Resolver struct {
+	Repo   order.Repository
+	Orders *service.Orders
+	// Orgs, when set, keeps the orders of organizations to their members.
+	Orgs *org.Store
+}
@@ -0,0 +1,192 @@
+package graph
+
+// This file will be automatically regenerated based on the schema, any resolver implementations
+// will be copied through when generating and any unknown code will be moved to the end.
+// Code generated by github.com/99designs/gqlgen version v0.17.45
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/i101dev/microservices-NN/dupcheck"
+	"github.com/i101dev/microservices-NN/graph/model"
+	"github.com/i101dev/microservices-NN/maintenance"
+	model1 "github.com/i101dev/microservices-NN/model"
+	"github.com/i101dev/microservices-NN/org"
+	"github.com/i101dev/microservices-NN/repository/order"
+)
+
+// CreateOrder is the resolver for the createOrder field.
+func (r *mutationResolver) CreateOrder(ctx context.Context, input model.NewOrder) (*model1.Order, error) {
+	lineItems := make([]model1.LineItem, len(input.LineItems))
+
+	for i, item := range input.LineItems {
+		lineItems[i] = model1.LineItem{
+			ItemID:   item.ItemID,
+			Quantity: item.Quantity,
+			Price:    item.Price,
+		}
+	}
+
+	o, err := r.Orders.Create(ctx, input.CustomerID, lineItems)
+	if errors.Is(err, dupcheck.ErrDuplicate) || errors.Is(err, maintenance.ErrReadOnly) {
+		return nil, err
+	} else if err != nil {
+		fmt.Println("failed to create:", err)
+		return nil, errInternal
+	}
+
+	return &o, nil
+}
+
+// ShipOrder is the resolver for the shipOrder field.
+func (r *mutationResolver) ShipOrder(ctx context.Context, id uint64) (*model1.Order, error) {
+	return r.transition(ctx, id, model1.StatusShipped)
+}
+
+// CompleteOrder is the resolver for the completeOrder field.
+func (r *mutationResolver) CompleteOrder(ctx context.Context, id uint64) (*model1.Order, error) {
+	return r.transition(ctx, id, model1.StatusCompleted)
+}
+
+// CancelOrder is the resolver for the cancelOrder field.
+func (r *mutationResolver) CancelOrder(ctx context.Context, id uint64) (*model1.Order, error) {
+	return r.transition(ctx, id, model1.StatusCancelled)
+}
+
+// ApproveOrder is the resolver for the approveOrder field.
+func (r *mutationResolver) ApproveOrder(ctx context.Context, id uint64) (*model1.Order, error) {
+	if err := r.authorizeChange(ctx, id); err != nil {
+		return nil, err
+	}
+
+	return changed(r.Orders.Approve(ctx, id))
+}
+
+// Order is the resolver for the order field.
+func (r *queryResolver) Order(ctx context.Context, id uint64) (*model1.Order, error) {
+	o, err := r.Repo.FindByID(ctx, id)
+
+	if errors.Is(err, order.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		fmt.Println("failed to find by id:", err)
+		return nil, errInternal
+	}
+
+	if r.Orgs != nil {
+		if err := authorized(r.Orgs.CanView(ctx, o)); err != nil {
+			return nil, err
+		}
+	}
+
+	return &o, nil
+}
+
+// Orders is the resolver for the orders field.
+func (r *queryResolver) Orders(ctx context.Context, filter *model.OrderFilter, cursor *uint64, snapshot *string, first *int) (*model.OrderPage, error) {
+	size := defaultPageSize
+
+	if first != nil {
+		if *first <= 0 || *first > maxPageSize {
+			return nil, fmt.Errorf("first must be between 1 and %d", maxPageSize)
+		}
+		size = *first
+	}
+
+	var offset, skip uint64
+	var name string
+
+	if cursor != nil {
+		if snapshot == nil {
+			return nil, errors.New("cursor must be sent with the snapshot it came with")
+		}
+		offset, skip = splitCursor(*cursor)
+	}
+
+	if snapshot != nil {
+		name = *snapshot
+	}
+
+	page := &model.OrderPage{
+		Items: []*model1.Order{},
+	}
+
+	var viewer *org.Viewer
+	if r.Orgs != nil {
+		viewer = r.Orgs.Viewer(ctx)
+	}
+
+	// Pages are read from a snapshot, kept until it expires, so the
+	// repository page a cursor points into is the same when it is read
+	// again, however orders were written in between.
+	for {
+		res, err := r.Repo.FindAll(ctx, order.FindAllPage{
+			Offset:     offset,
+			Size:       scanSize,
+			Consistent: true,
+			Snapshot:   name,
+			Keep:       true,
+		})
+
+		if errors.Is(err, order.ErrSnapshotExpired) {
+			return nil, errors.New("snapshot has expired, start again without a cursor")
+		} else if err != nil {
+			fmt.Println("failed to find all:", err)
+			return nil, errInternal
+		}
+
+		name = res.Snapshot
+
+		for i := skip; i < uint64(len(res.Orders)); i++ {
+			o := &res.Orders[i]
+
+			if !matchesFilter(o, filter) {
+				continue
+			}
+
+			if viewer != nil {
+				visible, err := viewer.Sees(o.OrderID, o.OrgID)
+				if err != nil {
+					return nil, authorized(err)
+				}
+				if !visible {
+					continue
+				}
+			}
+
+			// The page is full: the next one starts at this order.
+			if len(page.Items) == size {
+				next := joinCursor(offset, i)
+				page.Next, page.Snapshot = &next, &name
+				return page, nil
+			}
+
+			page.Items = append(page.Items, o)
+		}
+
+		offset, skip = res.Cursor, 0
+
+		if offset == 0 {
+			break
+		}
+
+		if len(page.Items) == size {
+			next := joinCursor(offset, 0)
+			page.Next, page.Snapshot = &next, &name
+			break
+		}
+	}
+
+	return page, nil
+}
+
+// Mutation returns MutationResolver implementation.
+func (r *Resolver) Mutation() MutationResolver { return &mutationResolver{r} }
+
+// Query returns QueryResolver implementation.
+func (r *Resolver) Query() QueryResolver { return &queryResolver{r} }
+
+type mutationResolver struct{ *Resolver }
+type queryResolver struct{ *Resolver }
@@ -0,0 +1,166 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+	"unicode/utf8"
+
+	"github.com/i101dev/microservices-NN/auth"
+	"github.com/i101dev/microservices-NN/model"
+	"github.com/i101dev/microservices-NN/orderquery"
+	"github.com/i101dev/microservices-NN/repository/order"
+)
+
+type Admin struct {
+	Store *order.RedisRepo
+	// Queries runs the filter expressions of GET /admin/orders.
+	Queries *orderquery.Runner
+}
+
+// RequireAdmin answers 403 to callers without the admin scope, or holding
+// it only through someone they impersonate.
+func RequireAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !admin(r) {
+			writeError(w, http.StatusForbidden, errorDetail{
+				Code:    "forbidden",
+				Message: "admin endpoints take the " + auth.ScopeAdmin + " scope",
+			})
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (h *Admin) RawOrder(w http.ResponseWriter, r *http.Request) {
+
+	orderID, ok := orderIDParam(w, r)
+	if !ok {
+		return
+	}
+
+	raw, err := h.Store.Raw(r.Context(), orderID)
+
+	status := http.StatusOK
+
+	// A missing value is still worth reporting: an index that points at it
+	// is exactly the kind of damage this endpoint is for.
+	if errors.Is(err, order.ErrNotExist) {
+		status = http.StatusNotFound
+	} else if err != nil {
+		writeFailure(w, r, "get raw order", err)
+		return
+	}
+
+	if r.URL.Query().Get("download") == "true" && status == http.StatusOK {
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Write(raw.Data)
+		return
+	}
+
+	body := struct {
+		Key         string   `json:"key"`
+		Exists      bool     `json:"exists"`
+		Codec       string   `json:"codec,omitempty"`
+		Size        int      `json:"size"`
+		TTLMillis   int64    `json:"ttl_ms"` // -1 means no expiry
+		Indexes     []string `json:"indexes"`
+		Data        []byte   `json:"data"`
+		Text        string   `json:"text,omitempty"`
+		DecodeError string   `json:"decode_error,omitempty"`
+	}{
+		Key:       raw.Key,
+		Exists:    status == http.StatusOK,
+		Codec:     raw.Codec,
+		Size:      len(raw.Data),
+		TTLMillis: raw.TTL.Milliseconds(),
+		Indexes:   raw.Indexes,
+		Data:      raw.Data,
+	}
+
+	if raw.TTL < 0 {
+		body.TTLMillis = -1
+	}
+
+	if body.Indexes == nil {
+		body.Indexes = []string{}
+	}
+
+	if raw.Codec == "json" && utf8.Valid(raw.Data) {
+		body.Text = string(raw.Data)
+	}
+
+	if raw.DecodeError != nil {
+		body.DecodeError = raw.DecodeError.Error()
+	}
+
+	respondJSON(w, status, body)
+}
+
+var errLimitReached = errors.New("limit reached")
+
+// Orders streams the orders matching the expression in q as ndjson, all of
+// them when q is empty, up to limit when it is set. X-Query-Plan tells
+// whether the index was used or every order was scanned.
+func (h *Admin) Orders(w http.ResponseWriter, r *http.Request) {
+
+	query := r.URL.Query()
+
+	q, err := orderquery.Parse(query.Get("q"), time.Now())
+	if err != nil {
+		writeFailure(w, r, "parse query", err)
+		return
+	}
+
+	var limit uint64
+	if s := query.Get("limit"); s != "" {
+		if limit, err = strconv.ParseUint(s, 10, 64); err != nil || limit == 0 {
+			writeError(w, http.StatusBadRequest, errorDetail{
+				Code:    "invalid_limit",
+				Message: "limit must be a positive whole number",
+				Param:   "limit",
+			})
+			return
+		}
+	}
+
+	// bulk downloads outlive the server write timeout
+	http.NewResponseController(w).SetWriteDeadline(time.Time{})
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Set("X-Query-Plan", h.Queries.Plan(q))
+
+	flusher, _ := w.(http.Flusher)
+	encoder := json.NewEncoder(w)
+
+	var n uint64
+
+	err = h.Queries.Run(r.Context(), q, func(o model.Order) error {
+
+		if err := encoder.Encode(o); err != nil {
+			return fmt.Errorf("failed to write order: %w", err)
+		}
+
+		if n++; n%100 == 0 && flusher != nil {
+			flusher.Flush()
+		}
+
+		if limit > 0 && n == limit {
+			return errLimitReached
+		}
+
+		return nil
+	})
+
+	if errors.Is(err, errLimitReached) {
+		return
+	} else if err != nil && n == 0 {
+		writeFailure(w, r, "query orders", err)
+	} else if err != nil {
+		fmt.Println("failed to query orders:", err)
+	}
+}
@@ -0,0 +1,189 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/i101dev/microservices-NN/analytics"
+	"github.com/i101dev/microservices-NN/clock"
+)
+
+const maxAnalyticsDays = 366
+
+type Analytics struct {
+	Store  *analytics.Store
+	Series analytics.Series
+	Clock  clock.Clock
+}
+
+func (h *Analytics) now() time.Time {
+	if h.Clock == nil {
+		return time.Now().UTC()
+	}
+	return h.Clock.Now().UTC()
+}
+
+func (h *Analytics) Orders(w http.ResponseWriter, r *http.Request) {
+
+	query := r.URL.Query()
+
+	to := h.now().Truncate(24 * time.Hour)
+	from := to.AddDate(0, 0, -29)
+
+	var err error
+
+	if s := query.Get("to"); s != "" {
+		if to, err = time.Parse(time.DateOnly, s); err != nil {
+			http.Error(w, "to must be a YYYY-MM-DD date", http.StatusBadRequest)
+			return
+		}
+		if query.Get("from") == "" {
+			from = to.AddDate(0, 0, -29)
+		}
+	}
+
+	if s := query.Get("from"); s != "" {
+		if from, err = time.Parse(time.DateOnly, s); err != nil {
+			http.Error(w, "from must be a YYYY-MM-DD date", http.StatusBadRequest)
+			return
+		}
+	}
+
+	if to.Before(from) || to.Sub(from) >= maxAnalyticsDays*24*time.Hour {
+		http.Error(w, fmt.Sprintf("the range must cover 1 to %d days", maxAnalyticsDays), http.StatusBadRequest)
+		return
+	}
+
+	top := 10
+
+	if s := query.Get("top"); s != "" {
+		if top, err = strconv.Atoi(s); err != nil || top < 0 || top > 100 {
+			http.Error(w, "top must be between 0 and 100", http.StatusBadRequest)
+			return
+		}
+	}
+
+	report, err := h.Store.Orders(r.Context(), from, to, top)
+	if err != nil {
+		writeFailure(w, r, "get analytics", err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, report)
+}
+
+func (h *Analytics) TopCustomers(w http.ResponseWriter, r *http.Request) {
+	h.top(w, r, "customers", "orders", "revenue")
+}
+
+func (h *Analytics) TopItems(w http.ResponseWriter, r *http.Request) {
+	h.top(w, r, "items", "quantity", "revenue")
+}
+
+func (h *Analytics) top(w http.ResponseWriter, r *http.Request, board string, metrics ...string) {
+
+	query := r.URL.Query()
+
+	metric := query.Get("metric")
+	if metric == "" {
+		metric = metrics[0]
+	}
+
+	known := false
+	for _, m := range metrics {
+		known = known || m == metric
+	}
+
+	if !known {
+		http.Error(w, fmt.Sprintf("metric must be one of %v", metrics), http.StatusBadRequest)
+		return
+	}
+
+	window := query.Get("window")
+	if window == "" {
+		window = "week"
+	}
+
+	limit := 10
+
+	if s := query.Get("limit"); s != "" {
+		var err error
+		if limit, err = strconv.Atoi(s); err != nil || limit < 1 || limit > 100 {
+			http.Error(w, "limit must be between 1 and 100", http.StatusBadRequest)
+			return
+		}
+	}
+
+	lb, err := h.Store.Top(r.Context(), board, metric, window, limit)
+	if err != nil {
+		writeFailure(w, r, "get leaderboard", err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, lb)
+}
+
+func (h *Analytics) Timeseries(w http.ResponseWriter, r *http.Request) {
+
+	query := r.URL.Query()
+
+	metric := query.Get("metric")
+	if metric == "" {
+		metric = analytics.MetricOrders
+	}
+
+	if metric != analytics.MetricOrders && metric != analytics.MetricRevenue {
+		http.Error(w, "metric must be orders or revenue", http.StatusBadRequest)
+		return
+	}
+
+	window, err := durationParam(query.Get("range"), time.Hour)
+	if err != nil || window <= 0 || window > 7*24*time.Hour {
+		http.Error(w, "range must be a duration of at most 168h", http.StatusBadRequest)
+		return
+	}
+
+	bucket, err := durationParam(query.Get("bucket"), time.Minute)
+	if err != nil || bucket < time.Second || bucket > window || window/bucket > 1440 {
+		http.Error(w, "bucket must be at least 1s, at most the range, and give no more than 1440 points", http.StatusBadRequest)
+		return
+	}
+
+	bucket = bucket.Truncate(time.Second)
+
+	to := h.now()
+	from := to.Add(-window)
+
+	points, err := h.Series.Range(r.Context(), metric, from, to, bucket)
+	if err != nil {
+		writeFailure(w, r, "get timeseries", err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, struct {
+		Metric  string            `json:"metric"`
+		Backend string            `json:"backend"`
+		From    time.Time         `json:"from"`
+		To      time.Time         `json:"to"`
+		Bucket  string            `json:"bucket"`
+		Points  []analytics.Point `json:"points"`
+	}{
+		Metric:  metric,
+		Backend: h.Series.Backend(),
+		From:    from,
+		To:      to,
+		Bucket:  bucket.String(),
+		Points:  points,
+	})
+}
+
+func durationParam(s string, fallback time.Duration) (time.Duration, error) {
+
+	if s == "" {
+		return fallback, nil
+	}
+
+	return time.ParseDuration(s)
+}
@@ -0,0 +1,241 @@
+package handler
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/i101dev/microservices-NN/model"
+	"github.com/i101dev/microservices-NN/org"
+	"github.com/i101dev/microservices-NN/service"
+	"github.com/i101dev/microservices-NN/storage"
+)
+
+// Attachments keeps files with orders. Their bytes go between clients and
+// blob storage with presigned URLs, never through the service, so files of
+// any size can be attached.
+type Attachments struct {
+	Orders *service.Orders
+	Blobs  storage.Blob
+	// Orgs, when set, keeps the attachments of organization orders to the
+	// members who can see them.
+	Orgs *org.Store
+	// MaxSize is the largest file accepted, in bytes.
+	MaxSize int64
+	// URLTTL is how long presigned URLs work.
+	URLTTL time.Duration
+	// Now is used to tell when presigned URLs expire. Nil means time.Now.
+	Now func() time.Time
+}
+
+func (h *Attachments) now() time.Time {
+	if h.Now == nil {
+		return time.Now().UTC()
+	}
+	return h.Now().UTC()
+}
+
+// presignedURL is a request for a client to send to blob storage. Headers
+// must be sent as given.
+type presignedURL struct {
+	Method    string            `json:"method"`
+	URL       string            `json:"url"`
+	Headers   map[string]string `json:"headers,omitempty"`
+	ExpiresAt time.Time         `json:"expires_at"`
+}
+
+type attachmentUpload struct {
+	Attachment model.Attachment `json:"attachment"`
+	Upload     presignedURL     `json:"upload"`
+}
+
+// Create adds a pending attachment to the order, and answers with the
+// URL to upload its file to. The upload is confirmed with Complete.
+func (h *Attachments) Create(w http.ResponseWriter, r *http.Request) {
+
+	orderID, ok := h.authorize(w, r)
+	if !ok {
+		return
+	}
+
+	var body struct {
+		Name        string `json:"name"`
+		ContentType string `json:"content_type"`
+		Size        int64  `json:"size"`
+	}
+
+	if !decodeJSON(w, r, &body) {
+		return
+	}
+
+	if !model.ValidAttachmentName(body.Name) {
+		writeError(w, http.StatusBadRequest, errorDetail{
+			Code:    "invalid_attachment_name",
+			Message: "name must be up to 255 bytes without slashes or control characters",
+			Param:   "name",
+		})
+		return
+	}
+
+	if body.ContentType == "" {
+		body.ContentType = "application/octet-stream"
+	}
+
+	if _, _, err := mime.ParseMediaType(body.ContentType); err != nil {
+		writeError(w, http.StatusBadRequest, errorDetail{
+			Code:    "invalid_content_type",
+			Message: "content_type must be a media type such as image/png",
+			Param:   "content_type",
+		})
+		return
+	}
+
+	if body.Size <= 0 || body.Size > h.MaxSize {
+		writeError(w, http.StatusBadRequest, errorDetail{
+			Code:    "invalid_attachment_size",
+			Message: fmt.Sprintf("size must be between 1 and %d bytes", h.MaxSize),
+			Param:   "size",
+		})
+		return
+	}
+
+	_, a, err := h.Orders.Attach(r.Context(), orderID, body.Name, body.ContentType, body.Size)
+	if err != nil {
+		writeFailure(w, r, "attach", err)
+		return
+	}
+
+	upload, err := h.Blobs.PresignPut(service.AttachmentKey(orderID, a.ID), a.ContentType, a.Size, h.URLTTL)
+	if err != nil {
+		writeFailure(w, r, "presign upload", err)
+		return
+	}
+
+	w.Header().Set("Location", fmt.Sprintf("/orders/%d/attachments/%s", orderID, a.ID))
+	respondJSON(w, http.StatusCreated, attachmentUpload{
+		Attachment: a,
+		Upload: presignedURL{
+			Method: http.MethodPut,
+			URL:    upload,
+			Headers: map[string]string{
+				"Content-Type":   a.ContentType,
+				"Content-Length": fmt.Sprint(a.Size),
+			},
+			ExpiresAt: h.now().Add(h.URLTTL),
+		},
+	})
+}
+
+// Complete confirms the file of the attachment in the path was uploaded.
+func (h *Attachments) Complete(w http.ResponseWriter, r *http.Request) {
+
+	orderID, ok := h.authorize(w, r)
+	if !ok {
+		return
+	}
+
+	o, err := h.Orders.ConfirmAttachment(r.Context(), orderID, chi.URLParam(r, "attachmentID"))
+	if err != nil {
+		writeFailure(w, r, "confirm attachment", err)
+		return
+	}
+
+	a, _ := o.FindAttachment(chi.URLParam(r, "attachmentID"))
+
+	respondJSON(w, http.StatusOK, a)
+}
+
+func (h *Attachments) List(w http.ResponseWriter, r *http.Request) {
+
+	orderID, ok := h.authorize(w, r)
+	if !ok {
+		return
+	}
+
+	o, err := h.Orders.Get(r.Context(), orderID)
+	if err != nil {
+		writeFailure(w, r, "find by id", err)
+		return
+	}
+
+	attachments := o.Attachments
+	if attachments == nil {
+		attachments = []model.Attachment{}
+	}
+
+	respondJSON(w, http.StatusOK, map[string]any{"attachments": attachments})
+}
+
+// Download redirects to a URL the file of the attachment in the path can
+// be downloaded from for a while.
+func (h *Attachments) Download(w http.ResponseWriter, r *http.Request) {
+
+	orderID, ok := h.authorize(w, r)
+	if !ok {
+		return
+	}
+
+	o, err := h.Orders.Get(r.Context(), orderID)
+	if err != nil {
+		writeFailure(w, r, "find by id", err)
+		return
+	}
+
+	a, err := o.FindAttachment(chi.URLParam(r, "attachmentID"))
+	if err == nil && a.UploadedAt == nil {
+		err = model.ErrAttachmentPending
+	}
+	if err != nil {
+		writeFailure(w, r, "find attachment", err)
+		return
+	}
+
+	download, err := h.Blobs.PresignGet(service.AttachmentKey(orderID, a.ID), a.Name, h.URLTTL)
+	if err != nil {
+		writeFailure(w, r, "presign download", err)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	http.Redirect(w, r, download, http.StatusFound)
+}
+
+func (h *Attachments) Delete(w http.ResponseWriter, r *http.Request) {
+
+	orderID, ok := h.authorize(w, r)
+	if !ok {
+		return
+	}
+
+	if _, err := h.Orders.Detach(r.Context(), orderID, chi.URLParam(r, "attachmentID")); err != nil {
+		writeFailure(w, r, "detach", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// authorize reads the order ID in the path, and tells whether the caller
+// can see the order, answering the request when it cannot.
+func (h *Attachments) authorize(w http.ResponseWriter, r *http.Request) (uint64, bool) {
+
+	orderID, ok := orderIDParam(w, r)
+	if !ok || h.Orgs == nil {
+		return orderID, ok
+	}
+
+	o, err := h.Orders.Get(r.Context(), orderID)
+	if err != nil {
+		writeFailure(w, r, "find by id", err)
+		return 0, false
+	}
+
+	if err := h.Orgs.CanView(r.Context(), o); err != nil {
+		writeFailure(w, r, "authorize order", err)
+		return 0, false
+	}
+
+	return orderID, true
+}
@@ -0,0 +1,75 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/i101dev/microservices-NN/audit"
+)
+
+// Audit shows the audit log and the impersonation sessions of admins.
+type Audit struct {
+	Log *audit.Log
+}
+
+// limitParam reads the limit query parameter, fallback when it is not set
+// and at most max.
+func limitParam(w http.ResponseWriter, r *http.Request, fallback, max int) (int, bool) {
+
+	s := r.URL.Query().Get("limit")
+	if s == "" {
+		return fallback, true
+	}
+
+	limit, err := strconv.Atoi(s)
+	if err != nil || limit <= 0 || limit > max {
+		writeError(w, http.StatusBadRequest, errorDetail{
+			Code:    "invalid_limit",
+			Message: "limit must be a whole number from 1 to " + strconv.Itoa(max),
+			Param:   "limit",
+		})
+		return 0, false
+	}
+
+	return limit, true
+}
+
+// Entries lists the latest entries of the log, newest first, only the
+// impersonated ones with impersonated=true, or those of one session.
+func (h *Audit) Entries(w http.ResponseWriter, r *http.Request) {
+
+	limit, ok := limitParam(w, r, 100, 1000)
+	if !ok {
+		return
+	}
+
+	entries, err := h.Log.Recent(r.Context(), audit.Query{
+		Session:      r.URL.Query().Get("session"),
+		Impersonated: r.URL.Query().Get("impersonated") == "true",
+		Limit:        limit,
+	})
+	if err != nil {
+		writeFailure(w, r, "read audit log", err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string][]audit.Entry{"items": entries})
+}
+
+// Sessions lists the latest impersonation sessions, the most recently
+// active first.
+func (h *Audit) Sessions(w http.ResponseWriter, r *http.Request) {
+
+	limit, ok := limitParam(w, r, 50, 1000)
+	if !ok {
+		return
+	}
+
+	sessions, err := h.Log.Sessions(r.Context(), limit)
+	if err != nil {
+		writeFailure(w, r, "list impersonation sessions", err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string][]audit.Session{"items": sessions})
+}
@@ -0,0 +1,119 @@
+package handler
+
+import (
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/i101dev/microservices-NN/carrier"
+	"github.com/i101dev/microservices-NN/clock"
+	"github.com/i101dev/microservices-NN/events"
+	"github.com/i101dev/microservices-NN/model"
+	"github.com/i101dev/microservices-NN/repository/order"
+	"github.com/i101dev/microservices-NN/service"
+	"github.com/i101dev/microservices-NN/tenant"
+)
+
+// Carrier takes the tracking webhooks carriers send for parcels, keyed by
+// the carrier name in the path. Only carriers with a secret configured
+// have an adapter.
+type Carrier struct {
+	Orders   *service.Orders
+	Index    *carrier.Index
+	Adapters map[string]carrier.Adapter
+	// Events, when set, gets an order.tracking_updated for every update
+	// that was applied.
+	Events *events.Publisher
+	Clock  clock.Clock
+}
+
+type webhookResponse struct {
+	Applied int `json:"applied"`
+	Ignored int `json:"ignored"`
+}
+
+// Webhook applies every update in the payload it can match to an order.
+// Updates for unknown tracking numbers, and repeated or out of date ones,
+// are counted as ignored rather than failing, so the carrier does not
+// keep retrying them. Store errors fail the whole webhook for a retry;
+// updates applied before it are ignored the second time around.
+func (h *Carrier) Webhook(w http.ResponseWriter, r *http.Request) {
+
+	name := chi.URLParam(r, "carrier")
+
+	adapter, ok := h.Adapters[name]
+	if !ok {
+		writeError(w, http.StatusNotFound, errorDetail{
+			Code:    "unknown_carrier",
+			Message: "no webhook for carrier " + name,
+			Param:   "carrier",
+		})
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			w.WriteHeader(http.StatusRequestEntityTooLarge)
+			return
+		}
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	if err := adapter.Verify(r.Header, body, h.Clock.Now()); err != nil {
+		writeFailure(w, r, "verify webhook", err)
+		return
+	}
+
+	updates, err := adapter.Parse(body)
+	if err != nil {
+		writeFailure(w, r, "parse webhook", err)
+		return
+	}
+
+	var res webhookResponse
+
+	for _, u := range updates {
+		id, found, err := h.Index.Lookup(r.Context(), u.Carrier, u.TrackingNumber)
+		if err != nil {
+			writeFailure(w, r, "look up tracking number", err)
+			return
+		} else if !found {
+			res.Ignored++
+			continue
+		}
+
+		o, applied, err := h.Orders.Track(r.Context(), id, model.Tracking{
+			Carrier: u.Carrier,
+			Number:  u.TrackingNumber,
+			Status:  u.Status,
+			At:      &u.At,
+		})
+
+		// The order was deleted or shipped again under another number.
+		if errors.Is(err, order.ErrNotExist) || errors.Is(err, service.ErrUntracked) {
+			h.Index.Forget(r.Context(), u.Carrier, u.TrackingNumber)
+			res.Ignored++
+			continue
+		} else if err != nil {
+			writeFailure(w, r, "track", err)
+			return
+		}
+
+		if !applied {
+			res.Ignored++
+			continue
+		}
+
+		res.Applied++
+
+		if h.Events != nil {
+			h.Events.Publish(r.Context(), events.NewTrackingUpdated(tenant.FromContext(r.Context()), o, u.Description, u.Location, h.Clock.Now()))
+		}
+	}
+
+	respondJSON(w, http.StatusOK, res)
+}
@@ -0,0 +1,174 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/i101dev/microservices-NN/auth"
+	"github.com/i101dev/microservices-NN/comment"
+	"github.com/i101dev/microservices-NN/events"
+	"github.com/i101dev/microservices-NN/org"
+	"github.com/i101dev/microservices-NN/repository/order"
+	"github.com/i101dev/microservices-NN/tenant"
+)
+
+// Comments serves the comment threads of orders. Internal comments are
+// kept to staff, which are callers with the internal scope, or any caller
+// when requests are not authenticated.
+type Comments struct {
+	Repo  order.Repository
+	Store *comment.Store
+	// Orgs, when set, keeps the threads of organization orders to the
+	// members who can see them.
+	Orgs *org.Store
+	// Events, when set, gets an event for everyone mentioned in a comment.
+	Events *events.Publisher
+}
+
+func staff(r *http.Request) bool {
+	p, ok := auth.FromContext(r.Context())
+	return !ok || p.HasScope(auth.ScopeInternal)
+}
+
+// Create adds a comment to the order in the path, internal by default for
+// staff and for the customer otherwise. The author is the caller when it
+// is authenticated, or author otherwise.
+func (h *Comments) Create(w http.ResponseWriter, r *http.Request) {
+
+	orderID, ok := h.authorize(w, r)
+	if !ok {
+		return
+	}
+
+	var body struct {
+		Body       string `json:"body"`
+		Visibility string `json:"visibility"`
+		Author     string `json:"author"`
+	}
+
+	if !decodeJSON(w, r, &body) {
+		return
+	}
+
+	if p, ok := auth.FromContext(r.Context()); ok {
+		body.Author = p.Subject
+	}
+
+	if body.Author == "" {
+		writeError(w, http.StatusBadRequest, errorDetail{
+			Code:    "invalid_author",
+			Message: "author is required for unauthenticated callers",
+			Param:   "author",
+		})
+		return
+	}
+
+	if body.Visibility == "" {
+		body.Visibility = comment.VisibilityCustomer
+		if staff(r) {
+			body.Visibility = comment.VisibilityInternal
+		}
+	}
+
+	if !h.checkVisibility(w, r, body.Visibility) {
+		return
+	}
+
+	c, err := h.Store.Add(r.Context(), orderID, body.Author, body.Body, body.Visibility)
+	if err != nil {
+		writeFailure(w, r, "add comment", err)
+		return
+	}
+
+	if h.Events != nil {
+		for _, mentioned := range c.Mentions {
+			if _, err := h.Events.Publish(r.Context(), events.NewCommentMentioned(tenant.FromContext(r.Context()), c, mentioned, c.CreatedAt)); err != nil {
+				fmt.Println("failed to publish mention:", err)
+			}
+		}
+	}
+
+	respondJSON(w, http.StatusCreated, c)
+}
+
+// List pages through the thread of the order in the path, oldest first,
+// with only the comments of visibility when it is given. Callers other
+// than staff only get the comments for the customer.
+func (h *Comments) List(w http.ResponseWriter, r *http.Request) {
+
+	orderID, ok := h.authorize(w, r)
+	if !ok {
+		return
+	}
+
+	limit, ok := limitParam(w, r, 50, 200)
+	if !ok {
+		return
+	}
+
+	visibility := r.URL.Query().Get("visibility")
+
+	if visibility == "" && !staff(r) {
+		visibility = comment.VisibilityCustomer
+	}
+
+	if visibility != "" && !h.checkVisibility(w, r, visibility) {
+		return
+	}
+
+	page, err := h.Store.List(r.Context(), orderID, visibility, r.URL.Query().Get("cursor"), limit)
+	if err != nil {
+		writeFailure(w, r, "list comments", err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, page)
+}
+
+func (h *Comments) checkVisibility(w http.ResponseWriter, r *http.Request, visibility string) bool {
+
+	if !comment.ValidVisibility(visibility) {
+		writeError(w, http.StatusBadRequest, errorDetail{
+			Code:    "invalid_visibility",
+			Message: "visibility must be internal or customer",
+			Param:   "visibility",
+		})
+		return false
+	}
+
+	if visibility == comment.VisibilityInternal && !staff(r) {
+		writeError(w, http.StatusForbidden, errorDetail{
+			Code:    "internal_comments_forbidden",
+			Message: "internal comments take the " + auth.ScopeInternal + " scope",
+			Param:   "visibility",
+		})
+		return false
+	}
+
+	return true
+}
+
+// authorize reads the order ID in the path, and tells whether the order
+// exists and the caller can see it, answering the request when not.
+func (h *Comments) authorize(w http.ResponseWriter, r *http.Request) (uint64, bool) {
+
+	orderID, ok := orderIDParam(w, r)
+	if !ok {
+		return 0, false
+	}
+
+	o, err := h.Repo.FindByID(r.Context(), orderID)
+	if err != nil {
+		writeFailure(w, r, "find by id", err)
+		return 0, false
+	}
+
+	if h.Orgs != nil {
+		if err := h.Orgs.CanView(r.Context(), o); err != nil {
+			writeFailure(w, r, "authorize order", err)
+			return 0, false
+		}
+	}
+
+	return orderID, true
+}
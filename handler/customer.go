@@ -0,0 +1,51 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/i101dev/microservices-NN/erasure"
+)
+
+type Customer struct {
+	Erasure *erasure.Eraser
+}
+
+// EraseData queues the erasure of every order of the customer and
+// returns the request to poll.
+func (h *Customer) EraseData(w http.ResponseWriter, r *http.Request) {
+
+	customer, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, errorDetail{
+			Code:    "invalid_customer_id",
+			Message: "customer id must be a uuid",
+			Param:   "id",
+		})
+		return
+	}
+
+	req, err := h.Erasure.Request(r.Context(), customer)
+	if err != nil {
+		writeFailure(w, r, "request erasure", err)
+		return
+	}
+
+	w.Header().Set("Location", "/customers/"+customer.String()+"/data/requests/"+req.RequestID)
+	respondJSON(w, http.StatusAccepted, req)
+}
+
+func (h *Customer) GetErasure(w http.ResponseWriter, r *http.Request) {
+
+	req, err := h.Erasure.Status(r.Context(), chi.URLParam(r, "requestID"))
+	if err == nil && req.CustomerID.String() != chi.URLParam(r, "id") {
+		err = erasure.ErrNotExist
+	}
+	if err != nil {
+		writeFailure(w, r, "get erasure request", err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, req)
+}
@@ -0,0 +1,106 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/i101dev/microservices-NN/calendar"
+	"github.com/i101dev/microservices-NN/model"
+	"github.com/i101dev/microservices-NN/org"
+	"github.com/i101dev/microservices-NN/repository/order"
+)
+
+// Delivery exports the expected delivery window of an order as a
+// calendar event. Orders with an estimated delivery use it; the others
+// arrive MinDays to MaxDays after they ship, or after they were placed
+// while they have not shipped yet.
+type Delivery struct {
+	Repo    order.Repository
+	MinDays int
+	MaxDays int
+	Orgs    *org.Store
+}
+
+func (h *Delivery) ICS(w http.ResponseWriter, r *http.Request) {
+
+	orderID, ok := orderIDParam(w, r)
+	if !ok {
+		return
+	}
+
+	o, err := h.Repo.FindByID(r.Context(), orderID)
+	if err != nil {
+		writeFailure(w, r, "find by id", err)
+		return
+	}
+
+	if !canView(w, r, h.Orgs, o) {
+		return
+	}
+
+	first, last, ok := h.window(o)
+	if !ok {
+		writeError(w, http.StatusNotFound, errorDetail{
+			Code:    "no_delivery_expected",
+			Message: "order is " + o.Status() + ", no delivery is expected",
+		})
+		return
+	}
+
+	summary := fmt.Sprintf("Delivery of order %d", o.OrderID)
+	description := fmt.Sprintf("Order %d is expected between %s and %s.", o.OrderID, first.Format("2 Jan"), last.Format("2 Jan 2006"))
+	if o.ShippedAt == nil {
+		description += " The estimate is updated once it ships."
+	}
+
+	// Remind at 9 in the morning of the first day.
+	alarm := 9 * time.Hour
+
+	var stamp time.Time
+	if o.UpdatedAt != nil {
+		stamp = *o.UpdatedAt
+	}
+
+	data := calendar.Encode("-//microservices-NN//orders//EN", calendar.Event{
+		UID:         fmt.Sprintf("delivery-%d@orders", o.OrderID),
+		Summary:     summary,
+		Description: description,
+		First:       first,
+		Last:        last,
+		Stamp:       stamp,
+		Sequence:    stamp.Unix(),
+		Alarm:       &alarm,
+	})
+
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"delivery-%d.ics\"", orderID))
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	w.WriteHeader(http.StatusOK)
+	w.Write(data)
+}
+
+func (h *Delivery) window(o model.Order) (time.Time, time.Time, bool) {
+
+	from := o.ShippedAt
+
+	switch o.Status() {
+	case model.StatusCompleted, model.StatusCancelled, model.StatusBackordered:
+		return time.Time{}, time.Time{}, false
+	case model.StatusPending, model.StatusReview:
+		from = o.CreatedAt
+	}
+
+	if o.EstimatedDelivery != nil {
+		return o.EstimatedDelivery.Earliest, o.EstimatedDelivery.Latest, true
+	}
+
+	if from == nil {
+		return time.Time{}, time.Time{}, false
+	}
+
+	day := from.UTC().Truncate(24 * time.Hour)
+
+	return day.AddDate(0, 0, h.MinDays), day.AddDate(0, 0, h.MaxDays), true
+}
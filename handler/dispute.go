@@ -0,0 +1,110 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/i101dev/microservices-NN/model"
+	"github.com/i101dev/microservices-NN/org"
+	"github.com/i101dev/microservices-NN/service"
+)
+
+// Disputes shows the chargebacks against orders, which the payment
+// provider's webhooks open and settle, and takes the evidence for them.
+type Disputes struct {
+	Orders *service.Orders
+	// Orgs, when set, keeps the disputes of organization orders to the
+	// members who can see them.
+	Orgs *org.Store
+}
+
+func (h *Disputes) List(w http.ResponseWriter, r *http.Request) {
+
+	o, ok := h.order(w, r)
+	if !ok {
+		return
+	}
+
+	disputes := o.Disputes
+	if disputes == nil {
+		disputes = []model.Dispute{}
+	}
+
+	respondJSON(w, http.StatusOK, map[string]any{"disputes": disputes})
+}
+
+func (h *Disputes) Get(w http.ResponseWriter, r *http.Request) {
+
+	o, ok := h.order(w, r)
+	if !ok {
+		return
+	}
+
+	d, err := o.FindDispute(chi.URLParam(r, "disputeID"))
+	if err != nil {
+		writeFailure(w, r, "find dispute", err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, d)
+}
+
+// AddEvidence refers the dispute in the path to uploaded attachments of
+// the order, to be sent to the provider in response.
+func (h *Disputes) AddEvidence(w http.ResponseWriter, r *http.Request) {
+
+	o, ok := h.order(w, r)
+	if !ok {
+		return
+	}
+
+	var body struct {
+		AttachmentIDs []string `json:"attachment_ids"`
+	}
+
+	if !decodeJSON(w, r, &body) {
+		return
+	}
+
+	if len(body.AttachmentIDs) == 0 {
+		writeError(w, http.StatusBadRequest, errorDetail{
+			Code:    "missing_attachment_ids",
+			Message: "attachment_ids must list at least one attachment",
+			Param:   "attachment_ids",
+		})
+		return
+	}
+
+	_, d, err := h.Orders.AddDisputeEvidence(r.Context(), o.OrderID, chi.URLParam(r, "disputeID"), body.AttachmentIDs)
+	if err != nil {
+		writeFailure(w, r, "add dispute evidence", err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, d)
+}
+
+// order reads the order in the path, answering the request when it does
+// not exist or the caller cannot see it.
+func (h *Disputes) order(w http.ResponseWriter, r *http.Request) (model.Order, bool) {
+
+	orderID, ok := orderIDParam(w, r)
+	if !ok {
+		return model.Order{}, false
+	}
+
+	o, err := h.Orders.Get(r.Context(), orderID)
+	if err != nil {
+		writeFailure(w, r, "find by id", err)
+		return model.Order{}, false
+	}
+
+	if h.Orgs != nil {
+		if err := h.Orgs.CanView(r.Context(), o); err != nil {
+			writeFailure(w, r, "authorize order", err)
+			return model.Order{}, false
+		}
+	}
+
+	return o, true
+}
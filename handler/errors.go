@@ -0,0 +1,148 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/i101dev/microservices-NN/analytics"
+	"github.com/i101dev/microservices-NN/cancellation"
+	"github.com/i101dev/microservices-NN/carrier"
+	"github.com/i101dev/microservices-NN/comment"
+	"github.com/i101dev/microservices-NN/dupcheck"
+	"github.com/i101dev/microservices-NN/erasure"
+	"github.com/i101dev/microservices-NN/errreport"
+	"github.com/i101dev/microservices-NN/fulfillment"
+	"github.com/i101dev/microservices-NN/giftcard"
+	"github.com/i101dev/microservices-NN/intake"
+	"github.com/i101dev/microservices-NN/jobs"
+	"github.com/i101dev/microservices-NN/maintenance"
+	"github.com/i101dev/microservices-NN/model"
+	"github.com/i101dev/microservices-NN/orderindex"
+	"github.com/i101dev/microservices-NN/orderquery"
+	"github.com/i101dev/microservices-NN/org"
+	"github.com/i101dev/microservices-NN/payment"
+	"github.com/i101dev/microservices-NN/pickup"
+	"github.com/i101dev/microservices-NN/quota"
+	"github.com/i101dev/microservices-NN/quote"
+	"github.com/i101dev/microservices-NN/readmodel"
+	"github.com/i101dev/microservices-NN/repository/order"
+	"github.com/i101dev/microservices-NN/retention"
+	"github.com/i101dev/microservices-NN/search"
+	"github.com/i101dev/microservices-NN/service"
+	"github.com/i101dev/microservices-NN/subscription"
+	"github.com/i101dev/microservices-NN/timeline"
+)
+
+type errorMapping struct {
+	err    error
+	status int
+	code   string
+}
+
+// errorMappings is checked in order with errors.Is, so more specific errors
+// go first.
+var errorMappings = []errorMapping{
+	{order.ErrNotExist, http.StatusNotFound, "order_not_found"},
+	{order.ErrExist, http.StatusConflict, "order_exists"},
+	{order.ErrConflict, http.StatusConflict, "order_conflict"},
+	{order.ErrUnavailable, http.StatusServiceUnavailable, "store_unavailable"},
+	{order.ErrCorrupt, http.StatusInternalServerError, "order_corrupt"},
+	{order.ErrSnapshotExpired, http.StatusGone, "snapshot_expired"},
+	{model.ErrInvalidTransition, http.StatusBadRequest, "invalid_transition"},
+	{cancellation.ErrRejected, http.StatusConflict, "cancellation_rejected"},
+	{model.ErrInvalidPayment, http.StatusConflict, "invalid_payment_transition"},
+	{model.ErrNotRedeemable, http.StatusConflict, "not_redeemable"},
+	{model.ErrInvalidTag, http.StatusBadRequest, "invalid_tag"},
+	{model.ErrTooManyTags, http.StatusBadRequest, "too_many_tags"},
+	{model.ErrInvalidAttachment, http.StatusBadRequest, "invalid_attachment"},
+	{model.ErrTooManyAttachments, http.StatusBadRequest, "too_many_attachments"},
+	{model.ErrAttachmentNotExist, http.StatusNotFound, "attachment_not_found"},
+	{model.ErrAttachmentPending, http.StatusConflict, "attachment_pending"},
+	{model.ErrInvalidPriority, http.StatusBadRequest, "invalid_priority"},
+	{model.ErrAlreadyExpedited, http.StatusConflict, "already_expedited"},
+	{model.ErrDisputeNotExist, http.StatusNotFound, "dispute_not_found"},
+	{model.ErrInvalidDispute, http.StatusConflict, "invalid_dispute_transition"},
+	{model.ErrInvalidEvidence, http.StatusBadRequest, "invalid_evidence"},
+	{fulfillment.ErrUnknownWarehouse, http.StatusNotFound, "warehouse_not_found"},
+	{dupcheck.ErrDuplicate, http.StatusConflict, "possible_duplicate"},
+	{intake.ErrNotExist, http.StatusNotFound, "request_not_found"},
+	{erasure.ErrNotExist, http.StatusNotFound, "request_not_found"},
+	{jobs.ErrNotDead, http.StatusNotFound, "job_not_found"},
+	{analytics.ErrUnknownWindow, http.StatusBadRequest, "unknown_window"},
+	{retention.ErrNoReport, http.StatusNotFound, "report_not_found"},
+	{maintenance.ErrReadOnly, http.StatusServiceUnavailable, "maintenance"},
+	{service.ErrNothingToOrder, http.StatusConflict, "nothing_to_order"},
+	{service.ErrTaxUnavailable, http.StatusServiceUnavailable, "tax_unavailable"},
+	{service.ErrInventoryUnavailable, http.StatusServiceUnavailable, "inventory_unavailable"},
+	{service.ErrNotMergeable, http.StatusConflict, "not_mergeable"},
+	{service.ErrInvalidSplit, http.StatusBadRequest, "invalid_split"},
+	{giftcard.ErrUnknownCard, http.StatusNotFound, "gift_card_not_found"},
+	{giftcard.ErrCardExists, http.StatusConflict, "gift_card_exists"},
+	{giftcard.ErrNoBalance, http.StatusConflict, "no_balance"},
+	{subscription.ErrNotExist, http.StatusNotFound, "subscription_not_found"},
+	{subscription.ErrInvalidSchedule, http.StatusBadRequest, "invalid_schedule"},
+	{subscription.ErrInvalidTransition, http.StatusConflict, "invalid_subscription_transition"},
+	{quote.ErrNotExist, http.StatusNotFound, "quote_not_found"},
+	{quote.ErrExpired, http.StatusConflict, "quote_expired"},
+	{quote.ErrInvalidTransition, http.StatusConflict, "invalid_quote_transition"},
+	{quote.ErrConflict, http.StatusConflict, "quote_conflict"},
+	{orderindex.ErrInvalidFilter, http.StatusBadRequest, "invalid_filter"},
+	{orderindex.ErrFilterNotExist, http.StatusNotFound, "filter_not_found"},
+	{orderindex.ErrTooManyFilters, http.StatusConflict, "too_many_filters"},
+	{service.ErrNotIndexed, http.StatusNotImplemented, "filters_unavailable"},
+	{service.ErrNoBlobs, http.StatusNotImplemented, "attachments_unavailable"},
+	{comment.ErrInvalidComment, http.StatusBadRequest, "invalid_comment"},
+	{comment.ErrInvalidVisibility, http.StatusBadRequest, "invalid_visibility"},
+	{comment.ErrInvalidCursor, http.StatusBadRequest, "invalid_cursor"},
+	{timeline.ErrInvalidCursor, http.StatusBadRequest, "invalid_cursor"},
+	{search.ErrInvalidQuery, http.StatusBadRequest, "invalid_search"},
+	{orderquery.ErrInvalidQuery, http.StatusBadRequest, "invalid_query"},
+	{quota.ErrNotExist, http.StatusNotFound, "quota_not_found"},
+	{quota.ErrExceeded, http.StatusTooManyRequests, "quota_exceeded"},
+	{org.ErrNotExist, http.StatusNotFound, "org_not_found"},
+	{org.ErrNotMember, http.StatusNotFound, "member_not_found"},
+	{org.ErrInvalidRole, http.StatusBadRequest, "invalid_role"},
+	{org.ErrUnauthenticated, http.StatusUnauthorized, "unauthenticated"},
+	{org.ErrForbidden, http.StatusForbidden, "forbidden"},
+	{readmodel.ErrNotBuilt, http.StatusServiceUnavailable, "views_not_built"},
+	{readmodel.ErrRebuilding, http.StatusConflict, "rebuild_in_progress"},
+	{pickup.ErrInvalid, http.StatusBadRequest, "invalid_code"},
+	{pickup.ErrExpired, http.StatusBadRequest, "code_expired"},
+	{carrier.ErrSignature, http.StatusUnauthorized, "invalid_signature"},
+	{carrier.ErrPayload, http.StatusBadRequest, "invalid_payload"},
+	{payment.ErrSignature, http.StatusUnauthorized, "invalid_signature"},
+	{payment.ErrPayload, http.StatusBadRequest, "invalid_payload"},
+}
+
+// writeFailure answers with the status and code mapped to err, or a 500 for
+// anything unmapped. Server errors are logged and reported with op and only
+// the kind of failure is sent to the client; client errors carry the full
+// message.
+func writeFailure(w http.ResponseWriter, r *http.Request, op string, err error) {
+
+	status, detail := http.StatusInternalServerError, errorDetail{
+		Code:    "internal",
+		Message: "internal server error",
+	}
+
+	for _, m := range errorMappings {
+		if errors.Is(err, m.err) {
+			status, detail = m.status, errorDetail{Code: m.code, Message: m.err.Error()}
+			break
+		}
+	}
+
+	if status >= http.StatusInternalServerError {
+		fmt.Printf("failed to %s: %v\n", op, err)
+		errreport.Error(r.Context(), op, err)
+	} else {
+		detail.Message = err.Error()
+	}
+
+	if status == http.StatusServiceUnavailable {
+		w.Header().Set("Retry-After", "1")
+	}
+
+	writeError(w, status, detail)
+}
@@ -0,0 +1,269 @@
+package handler
+
+import (
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/i101dev/microservices-NN/model"
+	"github.com/i101dev/microservices-NN/repository/order"
+	"github.com/xuri/excelize/v2"
+)
+
+var exportHeader = []string{
+	"order_id",
+	"customer_id",
+	"status",
+	"created_at",
+	"shipped_at",
+	"completed_at",
+	"cancelled_at",
+	"item_id",
+	"quantity",
+	"price",
+	"line_total",
+}
+
+type rowWriter interface {
+	WriteRow(row []string) error
+	Flush() error
+	Close() error
+}
+
+type csvRowWriter struct {
+	w  http.ResponseWriter
+	cw *csv.Writer
+}
+
+func (c *csvRowWriter) WriteRow(row []string) error {
+	return c.cw.Write(row)
+}
+
+func (c *csvRowWriter) Flush() error {
+
+	c.cw.Flush()
+
+	if f, ok := c.w.(http.Flusher); ok {
+		f.Flush()
+	}
+
+	return c.cw.Error()
+}
+
+func (c *csvRowWriter) Close() error {
+	return c.Flush()
+}
+
+type xlsxRowWriter struct {
+	w    http.ResponseWriter
+	file *excelize.File
+	sw   *excelize.StreamWriter
+	row  int
+}
+
+func newXLSXRowWriter(w http.ResponseWriter) (*xlsxRowWriter, error) {
+
+	file := excelize.NewFile()
+
+	sw, err := file.NewStreamWriter("Sheet1")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create xlsx stream: %w", err)
+	}
+
+	return &xlsxRowWriter{
+		w:    w,
+		file: file,
+		sw:   sw,
+	}, nil
+}
+
+func (x *xlsxRowWriter) WriteRow(row []string) error {
+
+	x.row++
+
+	cell, err := excelize.CoordinatesToCellName(1, x.row)
+	if err != nil {
+		return err
+	}
+
+	values := make([]interface{}, len(row))
+	for i, v := range row {
+		values[i] = v
+	}
+
+	return x.sw.SetRow(cell, values)
+}
+
+func (x *xlsxRowWriter) Flush() error {
+	return nil
+}
+
+func (x *xlsxRowWriter) Close() error {
+
+	defer x.file.Close()
+
+	if err := x.sw.Flush(); err != nil {
+		return fmt.Errorf("failed to flush xlsx stream: %w", err)
+	}
+
+	return x.file.Write(x.w)
+}
+
+func (h *Order) Export(w http.ResponseWriter, r *http.Request) {
+
+	query := r.URL.Query()
+
+	from, err := parseExportTime(query.Get("from"))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	to, err := parseExportTime(query.Get("to"))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	// bulk downloads outlive the server write timeout
+	http.NewResponseController(w).SetWriteDeadline(time.Time{})
+
+	var rw rowWriter
+
+	switch query.Get("format") {
+	case "", "csv":
+		w.Header().Set("Content-Type", "text/csv")
+		w.Header().Set("Content-Disposition", `attachment; filename="orders.csv"`)
+		rw = &csvRowWriter{w: w, cw: csv.NewWriter(w)}
+	case "xlsx":
+		xw, err := newXLSXRowWriter(w)
+		if err != nil {
+			fmt.Println("failed to start export:", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
+		w.Header().Set("Content-Disposition", `attachment; filename="orders.xlsx"`)
+		rw = xw
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	if err := rw.WriteRow(exportHeader); err != nil {
+		fmt.Println("failed to write export header:", err)
+		return
+	}
+
+	const size = 100
+	err = order.ForEachSnapshotPage(r.Context(), h.Repo, size, func(orders []model.Order) error {
+
+		orders, err := h.visiblePage(r, orders)
+		if err != nil {
+			return err
+		}
+
+		for _, o := range orders {
+			if !inRange(o.CreatedAt, from, to) {
+				continue
+			}
+			for _, row := range exportRows(o) {
+				if err := rw.WriteRow(row); err != nil {
+					return fmt.Errorf("failed to write export row: %w", err)
+				}
+			}
+		}
+
+		return rw.Flush()
+	})
+
+	if err != nil {
+		fmt.Println("failed to export orders:", err)
+		return
+	}
+
+	if err := rw.Close(); err != nil {
+		fmt.Println("failed to finish export:", err)
+	}
+}
+
+func parseExportTime(value string) (*time.Time, error) {
+
+	if value == "" {
+		return nil, nil
+	}
+
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return &t, nil
+	}
+
+	t, err := time.Parse(time.DateOnly, value)
+	if err != nil {
+		return nil, err
+	}
+
+	return &t, nil
+}
+
+func inRange(t, from, to *time.Time) bool {
+
+	if from == nil && to == nil {
+		return true
+	}
+
+	if t == nil {
+		return false
+	}
+
+	if from != nil && t.Before(*from) {
+		return false
+	}
+
+	if to != nil && !t.Before(*to) {
+		return false
+	}
+
+	return true
+}
+
+func exportRows(o model.Order) [][]string {
+
+	base := []string{
+		strconv.FormatUint(o.OrderID, 10),
+		o.CustomerID.String(),
+		o.Status(),
+		formatTime(o.CreatedAt),
+		formatTime(o.ShippedAt),
+		formatTime(o.CompletedAt),
+		formatTime(o.CancelledAt),
+	}
+
+	if len(o.LineItems) == 0 {
+		return [][]string{append(base, "", "", "", "")}
+	}
+
+	rows := make([][]string, len(o.LineItems))
+
+	for i, item := range o.LineItems {
+		row := append([]string{}, base...)
+		rows[i] = append(row,
+			item.ItemID.String(),
+			strconv.FormatUint(uint64(item.Quantity), 10),
+			strconv.FormatUint(uint64(item.Price), 10),
+			strconv.FormatUint(uint64(item.Quantity)*uint64(item.Price), 10),
+		)
+	}
+
+	return rows
+}
+
+func formatTime(t *time.Time) string {
+
+	if t == nil {
+		return ""
+	}
+
+	return t.Format(time.RFC3339)
+}
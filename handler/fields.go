@@ -0,0 +1,267 @@
+package handler
+
+import (
+	"context"
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// How request bodies treat fields they have no place for. Lenient bodies
+// go through, keeping the unknown fields of orders in their metadata, and
+// strict ones are rejected with a 400.
+const (
+	FieldsLenient = "lenient"
+	FieldsStrict  = "strict"
+)
+
+type fieldsKey struct{}
+
+type fieldsMode struct {
+	mode    string
+	counter *prometheus.CounterVec
+}
+
+// UnknownFields sets how the bodies of the requests it serves treat the
+// fields they do not know, which are logged in either mode, and counted by
+// route in counter when it is set.
+func UnknownFields(mode string, counter *prometheus.CounterVec) func(http.Handler) http.Handler {
+
+	fm := fieldsMode{mode: mode, counter: counter}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), fieldsKey{}, fm)))
+		})
+	}
+}
+
+// unknownField is a field of a body at path, made of the keys of objects
+// and the indexes of arrays down to it.
+type unknownField struct {
+	path  []any
+	value json.RawMessage
+}
+
+func (f unknownField) String() string {
+
+	var b strings.Builder
+
+	for i, p := range f.path {
+		switch p := p.(type) {
+		case int:
+			b.WriteString("[" + strconv.Itoa(p) + "]")
+		case string:
+			if i > 0 {
+				b.WriteByte('.')
+			}
+			b.WriteString(p)
+		}
+	}
+
+	return b.String()
+}
+
+// topLevel returns the unknown fields at the root of the body, or under
+// prefix, by name.
+func topLevel(fields []unknownField, prefix ...any) map[string]json.RawMessage {
+
+	var kept map[string]json.RawMessage
+
+	for _, f := range fields {
+		if len(f.path) != len(prefix)+1 || !slices.Equal(f.path[:len(prefix)], prefix) {
+			continue
+		}
+		name, ok := f.path[len(prefix)].(string)
+		if !ok {
+			continue
+		}
+		if kept == nil {
+			kept = map[string]json.RawMessage{}
+		}
+		kept[name] = f.value
+	}
+
+	return kept
+}
+
+var (
+	jsonUnmarshaler = reflect.TypeFor[json.Unmarshaler]()
+	textUnmarshaler = reflect.TypeFor[encoding.TextUnmarshaler]()
+)
+
+// findUnknown adds the fields of data that t has no place for to found.
+// It follows the field names encoding/json decodes into, so a field is
+// unknown exactly when decoding drops it. Types that decode themselves are
+// taken to know all of their fields.
+func findUnknown(data json.RawMessage, t reflect.Type, path []any, found *[]unknownField) {
+
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	if reflect.PointerTo(t).Implements(jsonUnmarshaler) || reflect.PointerTo(t).Implements(textUnmarshaler) {
+		return
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		var obj map[string]json.RawMessage
+		if json.Unmarshal(data, &obj) != nil {
+			return
+		}
+
+		fields := structFields(t)
+
+		keys := make([]string, 0, len(obj))
+		for key := range obj {
+			keys = append(keys, key)
+		}
+		slices.Sort(keys)
+
+		for _, key := range keys {
+			at := append(slices.Clone(path), key)
+
+			ft, ok := fields[key]
+			if !ok {
+				ft, ok = fields[strings.ToLower(key)]
+			}
+			if !ok {
+				*found = append(*found, unknownField{path: at, value: obj[key]})
+				continue
+			}
+			findUnknown(obj[key], ft, at, found)
+		}
+
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return
+		}
+
+		var arr []json.RawMessage
+		if json.Unmarshal(data, &arr) != nil {
+			return
+		}
+
+		for i, elem := range arr {
+			findUnknown(elem, t.Elem(), append(slices.Clone(path), i), found)
+		}
+
+	case reflect.Map:
+		var obj map[string]json.RawMessage
+		if json.Unmarshal(data, &obj) != nil {
+			return
+		}
+
+		for key, value := range obj {
+			findUnknown(value, t.Elem(), append(slices.Clone(path), key), found)
+		}
+	}
+}
+
+var fieldCache sync.Map
+
+// structFields maps the JSON names of the fields of t, and of the structs
+// it embeds, to their types. Each is there as named and in lower case, for
+// the case-insensitive matches of encoding/json.
+func structFields(t reflect.Type) map[string]reflect.Type {
+
+	if fields, ok := fieldCache.Load(t); ok {
+		return fields.(map[string]reflect.Type)
+	}
+
+	fields := map[string]reflect.Type{}
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				for n, t := range structFields(ft) {
+					if _, ok := fields[n]; !ok {
+						fields[n] = t
+					}
+				}
+				continue
+			}
+		}
+
+		if !f.IsExported() {
+			continue
+		}
+
+		if name == "" {
+			name = f.Name
+		}
+		fields[name] = f.Type
+		if _, ok := fields[strings.ToLower(name)]; !ok {
+			fields[strings.ToLower(name)] = f.Type
+		}
+	}
+
+	fieldCache.Store(t, fields)
+
+	return fields
+}
+
+// checkUnknown logs and counts the unknown fields of the body decoded into
+// v, and answers the request when they are not tolerated.
+func checkUnknown(w http.ResponseWriter, r *http.Request, data []byte, v any) ([]unknownField, bool) {
+
+	fm, ok := r.Context().Value(fieldsKey{}).(fieldsMode)
+	if !ok {
+		return nil, true
+	}
+
+	var found []unknownField
+	findUnknown(data, reflect.TypeOf(v), nil, &found)
+
+	if len(found) == 0 {
+		return nil, true
+	}
+
+	route := r.URL.Path
+	if rc := chi.RouteContext(r.Context()); rc != nil && rc.RoutePattern() != "" {
+		route = rc.RoutePattern()
+	}
+
+	names := make([]string, len(found))
+	for i, f := range found {
+		names[i] = f.String()
+	}
+
+	fmt.Printf("unknown fields in %s %s: %s\n", r.Method, route, strings.Join(names, ", "))
+
+	if fm.counter != nil {
+		fm.counter.WithLabelValues(r.Method + " " + route).Add(float64(len(found)))
+	}
+
+	if fm.mode == FieldsStrict {
+		writeError(w, http.StatusBadRequest, errorDetail{
+			Code:    "unknown_field",
+			Message: "unknown field " + names[0],
+			Param:   names[0],
+		})
+		return nil, false
+	}
+
+	return found, true
+}
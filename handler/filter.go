@@ -0,0 +1,299 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/i101dev/microservices-NN/clock"
+	"github.com/i101dev/microservices-NN/loadshed"
+	"github.com/i101dev/microservices-NN/orderindex"
+)
+
+// Filters manages the order filters saved by each API key. They are
+// applied with GET /orders?filter={id}.
+type Filters struct {
+	Store *orderindex.Filters
+	Clock clock.Clock
+}
+
+// apiKey returns the key the filters of a request are saved under,
+// writing a 401 when the request has none.
+func apiKey(w http.ResponseWriter, r *http.Request) (string, bool) {
+
+	key := r.Header.Get(loadshed.APIKeyHeader)
+	if key == "" {
+		writeError(w, http.StatusUnauthorized, errorDetail{
+			Code:    "missing_api_key",
+			Message: "saved filters need an " + loadshed.APIKeyHeader + " header",
+		})
+		return "", false
+	}
+
+	return key, true
+}
+
+type savedFilterBody struct {
+	Name   string            `json:"name"`
+	Filter orderindex.Filter `json:"filter"`
+}
+
+func (b *savedFilterBody) check(w http.ResponseWriter) bool {
+
+	if b.Name == "" || len(b.Name) > 100 {
+		writeError(w, http.StatusBadRequest, errorDetail{
+			Code:    "invalid_name",
+			Message: "name must be between 1 and 100 characters",
+			Param:   "name",
+		})
+		return false
+	}
+
+	if b.Filter.Empty() {
+		writeError(w, http.StatusBadRequest, errorDetail{
+			Code:    "invalid_filter",
+			Message: "filter must set at least one field",
+			Param:   "filter",
+		})
+		return false
+	}
+
+	return true
+}
+
+func (h *Filters) Create(w http.ResponseWriter, r *http.Request) {
+
+	key, ok := apiKey(w, r)
+	if !ok {
+		return
+	}
+
+	var body savedFilterBody
+
+	if !decodeJSON(w, r, &body) || !body.check(w) {
+		return
+	}
+
+	if err := body.Filter.Validate(); err != nil {
+		writeFailure(w, r, "validate filter", err)
+		return
+	}
+
+	now := h.Clock.Now().UTC()
+	s := orderindex.Saved{
+		ID:        uuid.NewString(),
+		Name:      body.Name,
+		Filter:    body.Filter,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	if err := h.Store.Save(r.Context(), key, s); err != nil {
+		writeFailure(w, r, "save filter", err)
+		return
+	}
+
+	w.Header().Set("Location", "/filters/"+s.ID)
+	respondJSON(w, http.StatusCreated, s)
+}
+
+func (h *Filters) List(w http.ResponseWriter, r *http.Request) {
+
+	key, ok := apiKey(w, r)
+	if !ok {
+		return
+	}
+
+	saved, err := h.Store.List(r.Context(), key)
+	if err != nil {
+		writeFailure(w, r, "list filters", err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string][]orderindex.Saved{"items": saved})
+}
+
+func (h *Filters) Get(w http.ResponseWriter, r *http.Request) {
+
+	key, ok := apiKey(w, r)
+	if !ok {
+		return
+	}
+
+	s, err := h.Store.Get(r.Context(), key, chi.URLParam(r, "id"))
+	if err != nil {
+		writeFailure(w, r, "get filter", err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, s)
+}
+
+func (h *Filters) Update(w http.ResponseWriter, r *http.Request) {
+
+	key, ok := apiKey(w, r)
+	if !ok {
+		return
+	}
+
+	var body savedFilterBody
+
+	if !decodeJSON(w, r, &body) || !body.check(w) {
+		return
+	}
+
+	if err := body.Filter.Validate(); err != nil {
+		writeFailure(w, r, "validate filter", err)
+		return
+	}
+
+	s, err := h.Store.Get(r.Context(), key, chi.URLParam(r, "id"))
+	if err != nil {
+		writeFailure(w, r, "get filter", err)
+		return
+	}
+
+	s.Name = body.Name
+	s.Filter = body.Filter
+	s.UpdatedAt = h.Clock.Now().UTC()
+
+	if err := h.Store.Save(r.Context(), key, s); err != nil {
+		writeFailure(w, r, "save filter", err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, s)
+}
+
+func (h *Filters) Delete(w http.ResponseWriter, r *http.Request) {
+
+	key, ok := apiKey(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.Store.Delete(r.Context(), key, chi.URLParam(r, "id")); err != nil {
+		writeFailure(w, r, "delete filter", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// listFilter reads the filter of GET /orders from the status,
+// customer_id, tag, from, to, min_total, sla and has_open_dispute parameters,
+// on top of the saved filter named by filter, if any. Parameters replace the fields of the
+// saved filter, except tags, which they add to.
+func (h *Order) listFilter(w http.ResponseWriter, r *http.Request) (orderindex.Filter, bool) {
+
+	q := r.URL.Query()
+
+	var f orderindex.Filter
+
+	if id := q.Get("filter"); id != "" {
+		if h.Filters == nil {
+			writeError(w, http.StatusNotImplemented, errorDetail{
+				Code:    "filters_unavailable",
+				Message: "saved filters are not enabled",
+				Param:   "filter",
+			})
+			return f, false
+		}
+
+		key, ok := apiKey(w, r)
+		if !ok {
+			return f, false
+		}
+
+		s, err := h.Filters.Get(r.Context(), key, id)
+		if err != nil {
+			writeFailure(w, r, "get filter", err)
+			return f, false
+		}
+
+		f = s.Filter
+	}
+
+	if status := q.Get("status"); status != "" {
+		f.Status = status
+	}
+
+	if c := q.Get("customer_id"); c != "" {
+		customer, err := uuid.Parse(c)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, errorDetail{
+				Code:    "invalid_customer_id",
+				Message: "customer id must be a uuid",
+				Param:   "customer_id",
+			})
+			return f, false
+		}
+		f.CustomerID = &customer
+	}
+
+	f.Tags = append(f.Tags, q["tag"]...)
+
+	from, err := parseExportTime(q.Get("from"))
+	if err != nil {
+		writeInvalidTime(w, "from")
+		return f, false
+	} else if from != nil {
+		f.From = from
+	}
+
+	to, err := parseExportTime(q.Get("to"))
+	if err != nil {
+		writeInvalidTime(w, "to")
+		return f, false
+	} else if to != nil {
+		f.To = to
+	}
+
+	if s := q.Get("min_total"); s != "" {
+		total, err := strconv.ParseUint(s, 10, 0)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, errorDetail{
+				Code:    "invalid_min_total",
+				Message: "min_total must be a whole number",
+				Param:   "min_total",
+			})
+			return f, false
+		}
+		f.MinTotal = uint(total)
+	}
+
+	if sla := q.Get("sla"); sla != "" {
+		if sla != orderindex.SLABreached {
+			writeError(w, http.StatusBadRequest, errorDetail{
+				Code:    "invalid_sla",
+				Message: "sla must be breached",
+				Param:   "sla",
+			})
+			return f, false
+		}
+		f.SLA = sla
+	}
+
+	if open := q.Get("has_open_dispute"); open != "" {
+		if open != "true" {
+			writeError(w, http.StatusBadRequest, errorDetail{
+				Code:    "invalid_has_open_dispute",
+				Message: "has_open_dispute must be true",
+				Param:   "has_open_dispute",
+			})
+			return f, false
+		}
+		f.HasOpenDispute = true
+	}
+
+	return f, true
+}
+
+func writeInvalidTime(w http.ResponseWriter, param string) {
+	writeError(w, http.StatusBadRequest, errorDetail{
+		Code:    "invalid_time",
+		Message: param + " must be an RFC 3339 timestamp or a date",
+		Param:   param,
+	})
+}
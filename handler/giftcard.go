@@ -0,0 +1,192 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/i101dev/microservices-NN/giftcard"
+	"github.com/i101dev/microservices-NN/org"
+	"github.com/i101dev/microservices-NN/service"
+)
+
+// GiftCards issues gift cards and pays orders with them and with store
+// credit.
+type GiftCards struct {
+	Orders   *service.Orders
+	Balances *giftcard.Balances
+	Orgs     *org.Store
+}
+
+type giftCardBalance struct {
+	Code    string `json:"code"`
+	Balance uint   `json:"balance"`
+}
+
+type storeCredit struct {
+	CustomerID uuid.UUID `json:"customer_id"`
+	Balance    uint      `json:"balance"`
+}
+
+// Issue creates a gift card, with a random code unless the body names
+// one.
+func (h *GiftCards) Issue(w http.ResponseWriter, r *http.Request) {
+
+	var body struct {
+		Code    string `json:"code"`
+		Balance uint   `json:"balance"`
+	}
+
+	if !decodeJSON(w, r, &body) {
+		return
+	}
+
+	if body.Code == "" {
+		body.Code = giftcard.NewCode()
+	} else if !giftcard.ValidCode(body.Code) {
+		writeError(w, http.StatusBadRequest, errorDetail{
+			Code:    "invalid_gift_card_code",
+			Message: "code must be 4 to 64 letters, digits or dashes",
+			Param:   "code",
+		})
+		return
+	}
+
+	if body.Balance == 0 {
+		writeError(w, http.StatusBadRequest, errorDetail{
+			Code:    "invalid_balance",
+			Message: "balance must be positive",
+			Param:   "balance",
+		})
+		return
+	}
+
+	if err := h.Balances.Issue(r.Context(), body.Code, body.Balance); err != nil {
+		writeFailure(w, r, "issue gift card", err)
+		return
+	}
+
+	w.Header().Set("Location", "/admin/gift-cards/"+body.Code)
+	respondJSON(w, http.StatusCreated, giftCardBalance{Code: body.Code, Balance: body.Balance})
+}
+
+func (h *GiftCards) Get(w http.ResponseWriter, r *http.Request) {
+
+	code := chi.URLParam(r, "code")
+
+	balance, err := h.Balances.Card(r.Context(), code)
+	if err != nil {
+		writeFailure(w, r, "get gift card", err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, giftCardBalance{Code: code, Balance: balance})
+}
+
+func (h *GiftCards) Credit(w http.ResponseWriter, r *http.Request) {
+
+	customer, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, errorDetail{
+			Code:    "invalid_customer_id",
+			Message: "customer id must be a uuid",
+			Param:   "id",
+		})
+		return
+	}
+
+	balance, err := h.Balances.Credit(r.Context(), customer)
+	if err != nil {
+		writeFailure(w, r, "get store credit", err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, storeCredit{CustomerID: customer, Balance: balance})
+}
+
+// RedeemCard pays an order with a gift card. Without an amount it pays
+// as much of what is due as the card holds.
+func (h *GiftCards) RedeemCard(w http.ResponseWriter, r *http.Request) {
+
+	orderID, ok := orderIDParam(w, r)
+	if !ok {
+		return
+	}
+
+	var body struct {
+		Code   string `json:"code"`
+		Amount uint   `json:"amount"`
+	}
+
+	if !decodeJSON(w, r, &body) {
+		return
+	}
+
+	if !giftcard.ValidCode(body.Code) {
+		writeError(w, http.StatusBadRequest, errorDetail{
+			Code:    "invalid_gift_card_code",
+			Message: "code must be 4 to 64 letters, digits or dashes",
+			Param:   "code",
+		})
+		return
+	}
+
+	if !h.checkChange(w, r, orderID) {
+		return
+	}
+
+	theOrder, err := h.Orders.RedeemGiftCard(r.Context(), orderID, body.Code, body.Amount)
+	if err != nil {
+		writeFailure(w, r, "redeem gift card", err)
+		return
+	}
+
+	respond(w, r, http.StatusOK, theOrder)
+}
+
+// RedeemCredit pays an order with its customer's store credit, like
+// RedeemCard.
+func (h *GiftCards) RedeemCredit(w http.ResponseWriter, r *http.Request) {
+
+	orderID, ok := orderIDParam(w, r)
+	if !ok {
+		return
+	}
+
+	var body struct {
+		Amount uint `json:"amount"`
+	}
+
+	if !decodeJSON(w, r, &body) {
+		return
+	}
+
+	if !h.checkChange(w, r, orderID) {
+		return
+	}
+
+	theOrder, err := h.Orders.RedeemStoreCredit(r.Context(), orderID, body.Amount)
+	if err != nil {
+		writeFailure(w, r, "redeem store credit", err)
+		return
+	}
+
+	respond(w, r, http.StatusOK, theOrder)
+}
+
+// checkChange tells whether the caller may pay the order, answering the
+// request when it may not.
+func (h *GiftCards) checkChange(w http.ResponseWriter, r *http.Request, orderID uint64) bool {
+
+	if h.Orgs == nil {
+		return true
+	}
+
+	o, err := h.Orders.Get(r.Context(), orderID)
+	if err != nil {
+		writeFailure(w, r, "find by id", err)
+		return false
+	}
+
+	return canChange(w, r, h.Orgs, o)
+}
@@ -0,0 +1,42 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/i101dev/microservices-NN/org"
+	"github.com/i101dev/microservices-NN/repository/order"
+)
+
+type History struct {
+	Repo  order.Repository
+	Store *order.RedisRepo
+	Orgs  *org.Store
+}
+
+// List answers with the merges and splits the order took part in, oldest
+// first.
+func (h *History) List(w http.ResponseWriter, r *http.Request) {
+
+	orderID, ok := orderIDParam(w, r)
+	if !ok {
+		return
+	}
+
+	o, err := h.Repo.FindByID(r.Context(), orderID)
+	if err != nil {
+		writeFailure(w, r, "find by id", err)
+		return
+	}
+
+	if !canView(w, r, h.Orgs, o) {
+		return
+	}
+
+	entries, err := h.Store.History(r.Context(), orderID)
+	if err != nil {
+		writeFailure(w, r, "get history", err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]any{"items": entries})
+}
@@ -0,0 +1,56 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/i101dev/microservices-NN/invoice"
+	"github.com/i101dev/microservices-NN/org"
+	"github.com/i101dev/microservices-NN/repository/order"
+)
+
+type Invoice struct {
+	Repo      order.Repository
+	Generator *invoice.Generator
+	Orgs      *org.Store
+}
+
+// PDF serves the invoice of an order. Large orders are rendered in the
+// background: until the PDF is ready the answer is a 202 to retry.
+func (h *Invoice) PDF(w http.ResponseWriter, r *http.Request) {
+
+	orderID, ok := orderIDParam(w, r)
+	if !ok {
+		return
+	}
+
+	o, err := h.Repo.FindByID(r.Context(), orderID)
+	if err != nil {
+		writeFailure(w, r, "find by id", err)
+		return
+	}
+
+	if !canView(w, r, h.Orgs, o) {
+		return
+	}
+
+	data, err := h.Generator.PDF(r.Context(), o)
+	if errors.Is(err, invoice.ErrPending) {
+		w.Header().Set("Retry-After", "5")
+		respondJSON(w, http.StatusAccepted, map[string]string{"status": "pending"})
+		return
+	}
+	if err != nil {
+		writeFailure(w, r, "render invoice", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("inline; filename=\"invoice-%d.pdf\"", orderID))
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	w.Header().Set("Cache-Control", "private, max-age=300")
+	w.WriteHeader(http.StatusOK)
+	w.Write(data)
+}
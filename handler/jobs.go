@@ -0,0 +1,86 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/i101dev/microservices-NN/jobs"
+)
+
+type Jobs struct {
+	Runner *jobs.Runner
+}
+
+func (h *Jobs) ListDead(w http.ResponseWriter, r *http.Request) {
+
+	query := r.URL.Query()
+
+	offset, limit := 0, 50
+
+	var err error
+
+	if s := query.Get("offset"); s != "" {
+		if offset, err = strconv.Atoi(s); err != nil || offset < 0 {
+			http.Error(w, "offset must be a non-negative integer", http.StatusBadRequest)
+			return
+		}
+	}
+
+	if s := query.Get("limit"); s != "" {
+		if limit, err = strconv.Atoi(s); err != nil || limit < 1 || limit > 500 {
+			http.Error(w, "limit must be between 1 and 500", http.StatusBadRequest)
+			return
+		}
+	}
+
+	dead, total, err := h.Runner.Dead(r.Context(), query.Get("type"), offset, limit)
+	if err != nil {
+		writeFailure(w, r, "list dead jobs", err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, struct {
+		Total int        `json:"total"`
+		Items []jobs.Job `json:"items"`
+	}{
+		Total: total,
+		Items: dead,
+	})
+}
+
+func (h *Jobs) GetDead(w http.ResponseWriter, r *http.Request) {
+
+	job, err := h.Runner.DeadJob(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		writeFailure(w, r, "get dead job", err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, job)
+}
+
+// Requeue and Purge act on one job when the route has an {id}, and on every
+// dead job (optionally of ?type=) otherwise.
+
+func (h *Jobs) Requeue(w http.ResponseWriter, r *http.Request) {
+	h.settle(w, r, "requeued", h.Runner.Requeue)
+}
+
+func (h *Jobs) Purge(w http.ResponseWriter, r *http.Request) {
+	h.settle(w, r, "purged", h.Runner.Purge)
+}
+
+func (h *Jobs) settle(w http.ResponseWriter, r *http.Request, verb string, fn func(ctx context.Context, id string, jobType string) (int, error)) {
+
+	n, err := fn(r.Context(), chi.URLParam(r, "id"), r.URL.Query().Get("type"))
+
+	if err != nil {
+		writeFailure(w, r, fmt.Sprintf("settle dead jobs (%s %d so far)", verb, n), err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]int{verb: n})
+}
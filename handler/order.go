@@ -1,60 +1,230 @@
 package handler
 
 import (
-	"encoding/json"
 	"errors"
 	"fmt"
-	"math/rand"
 	"net/http"
 	"strconv"
-	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
+	"github.com/i101dev/microservices-NN/cancellation"
+	"github.com/i101dev/microservices-NN/codec"
+	"github.com/i101dev/microservices-NN/comment"
+	"github.com/i101dev/microservices-NN/intake"
 	"github.com/i101dev/microservices-NN/model"
+	"github.com/i101dev/microservices-NN/orderindex"
+	"github.com/i101dev/microservices-NN/org"
+	"github.com/i101dev/microservices-NN/quota"
+	"github.com/i101dev/microservices-NN/readmodel"
 	"github.com/i101dev/microservices-NN/repository/order"
+	"github.com/i101dev/microservices-NN/respcache"
+	"github.com/i101dev/microservices-NN/search"
+	"github.com/i101dev/microservices-NN/service"
 )
 
 type Order struct {
-	Repo *order.RedisRepo
+	Repo   order.Repository
+	Orders *service.Orders
+	Cache  *respcache.Cache
+	Queue  *intake.Queue
+	// Filters, when set, resolves the saved filter named in listings.
+	Filters *orderindex.Filters
+	// SearchIndex, when set, answers GET /orders/search.
+	SearchIndex *search.Index
+	// Views, when set, counts the orders of listings that ask for a total.
+	Views *readmodel.Views
+	// Orgs, when set, takes orders for organizations, and keeps their
+	// orders to their members.
+	Orgs *org.Store
+	// Comments, when set, has the thread of deleted orders dropped.
+	Comments *comment.Store
+	// Quotas, when set, counts queued orders against the order quota of
+	// the request's API key, as the intake worker that writes them
+	// cannot.
+	Quotas *quota.Store
 }
 
 func (h *Order) Create(w http.ResponseWriter, r *http.Request) {
 
 	var body struct {
 		CustomerID uuid.UUID        `json:"customer_id"`
+		OrgID      *uuid.UUID       `json:"org_id"`
 		LineItems  []model.LineItem `json:"line_items"`
+		Shipping   *model.Shipping  `json:"shipping"`
+		Priority   string           `json:"priority"`
+		readOnlyTimestamps
 	}
 
-	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
-		w.WriteHeader(http.StatusBadRequest)
+	unknown, ok := decodeJSONFields(w, r, &body)
+	if !ok || !body.check(w) || !checkShipping(w, body.Shipping) || !checkPriority(w, r, body.Priority) {
 		return
 	}
 
-	now := time.Now().UTC()
+	if body.OrgID != nil && !h.checkMember(w, r, *body.OrgID) {
+		return
+	}
 
-	order := model.Order{
-		OrderID:    rand.Uint64(),
+	draft := service.Draft{
 		CustomerID: body.CustomerID,
+		OrgID:      body.OrgID,
 		LineItems:  body.LineItems,
-		CreatedAt:  &now,
+		Shipping:   body.Shipping,
+		Metadata:   topLevel(unknown),
+		Priority:   body.Priority,
 	}
 
-	if err := h.Repo.Insert(r.Context(), order); err != nil {
-		fmt.Println("failed to insert:", err)
-		w.WriteHeader(http.StatusInternalServerError)
+	// Reserving stock can split off a backorder, which only a synchronous
+	// write can answer with.
+	if h.Queue != nil && h.Orders.Inventory == nil {
+		order, err := h.Orders.PrepareDraft(r.Context(), draft)
+		if err != nil {
+			writeFailure(w, r, "prepare", err)
+			return
+		}
+
+		charged, err := h.takeOrderQuota(r)
+		if err != nil {
+			h.Orders.Discard(r.Context(), order)
+			writeFailure(w, r, "enqueue", err)
+			return
+		}
+
+		req, err := h.Queue.Enqueue(r.Context(), order)
+		if err != nil {
+			if charged {
+				h.returnOrderQuota(r)
+			}
+			h.Orders.Discard(r.Context(), order)
+			writeFailure(w, r, "enqueue", err)
+			return
+		}
+
+		w.Header().Set("Location", "/orders/requests/"+req.RequestID)
+		respondJSON(w, http.StatusAccepted, req)
 		return
 	}
 
-	res, err := json.Marshal(order)
+	order, err := h.Orders.CreateDraft(r.Context(), draft)
 	if err != nil {
-		fmt.Println("failed to marshal:", err)
-		w.WriteHeader(http.StatusInternalServerError)
+		writeFailure(w, r, "create", err)
 		return
 	}
 
-	w.Write(res)
-	w.WriteHeader(http.StatusCreated)
+	respond(w, r, http.StatusCreated, order)
+}
+
+// takeOrderQuota counts an order to be queued against the order quota of
+// the request's API key, failing with quota.ErrExceeded once it is used
+// up. It reports whether anything was taken. Queued orders go through
+// when the store cannot be reached, as written ones do.
+func (h *Order) takeOrderQuota(r *http.Request) (bool, error) {
+
+	apiKey, ok := quota.FromContext(r.Context())
+	if h.Quotas == nil || !ok {
+		return false, nil
+	}
+
+	if _, _, err := h.Quotas.Take(r.Context(), apiKey, quota.MetricOrders, 1); errors.Is(err, quota.ErrExceeded) {
+		return false, err
+	} else if err != nil {
+		fmt.Println("failed to check order quota:", err)
+		return false, nil
+	}
+
+	return true, nil
+}
+
+// returnOrderQuota gives back what takeOrderQuota took for an order that
+// was not queued.
+func (h *Order) returnOrderQuota(r *http.Request) {
+
+	apiKey, _ := quota.FromContext(r.Context())
+
+	if err := h.Quotas.Return(r.Context(), apiKey, quota.MetricOrders, 1); err != nil {
+		fmt.Println(err)
+	}
+}
+
+const maxBulkOrders = 100
+
+type bulkResult struct {
+	Status string      `json:"status"`
+	Order  model.Order `json:"order"`
+}
+
+// CreateBulk creates every order in the body or none of them, answering
+// with one result per order in request order. It always writes
+// synchronously, even when creates are queued.
+func (h *Order) CreateBulk(w http.ResponseWriter, r *http.Request) {
+
+	var body struct {
+		Orders []struct {
+			CustomerID uuid.UUID        `json:"customer_id"`
+			LineItems  []model.LineItem `json:"line_items"`
+			Shipping   *model.Shipping  `json:"shipping"`
+			Priority   string           `json:"priority"`
+			readOnlyTimestamps
+		} `json:"orders"`
+	}
+
+	unknown, ok := decodeJSONFields(w, r, &body)
+	if !ok {
+		return
+	}
+
+	if len(body.Orders) == 0 || len(body.Orders) > maxBulkOrders {
+		writeError(w, http.StatusBadRequest, errorDetail{
+			Code:    "invalid_bulk_size",
+			Message: fmt.Sprintf("orders must hold between 1 and %d orders", maxBulkOrders),
+			Param:   "orders",
+		})
+		return
+	}
+
+	drafts := make([]service.Draft, len(body.Orders))
+
+	for i, o := range body.Orders {
+		if !o.check(w) || !checkShipping(w, o.Shipping) || !checkPriority(w, r, o.Priority) {
+			return
+		}
+		drafts[i] = service.Draft{
+			CustomerID: o.CustomerID,
+			LineItems:  o.LineItems,
+			Shipping:   o.Shipping,
+			Metadata:   topLevel(unknown, "orders", i),
+			Priority:   o.Priority,
+		}
+	}
+
+	orders, err := h.Orders.CreateAll(r.Context(), drafts)
+	if err != nil {
+		writeFailure(w, r, "create bulk", err)
+		return
+	}
+
+	results := make([]bulkResult, len(orders))
+	for i, o := range orders {
+		results[i] = bulkResult{Status: "created", Order: o}
+	}
+
+	respondJSON(w, http.StatusCreated, map[string][]bulkResult{"results": results})
+}
+
+func (h *Order) GetRequest(w http.ResponseWriter, r *http.Request) {
+
+	if h.Queue == nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	req, err := h.Queue.Status(r.Context(), chi.URLParam(r, "requestID"))
+	if err != nil {
+		writeFailure(w, r, "get create request", err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, req)
 }
 
 func (h *Order) List(w http.ResponseWriter, r *http.Request) {
@@ -75,158 +245,443 @@ func (h *Order) List(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	f, ok := h.listFilter(w, r)
+	if !ok {
+		return
+	}
+
 	const size = 50
-	res, err := h.Repo.FindAll(r.Context(), order.FindAllPage{
-		Offset: cursor,
-		Size:   size,
-	})
+
+	snapshot := r.URL.Query().Get("snapshot")
+	consistent := snapshot != "" || r.URL.Query().Get("consistent") == "true"
+
+	if consistent && !f.Empty() {
+		writeError(w, http.StatusBadRequest, errorDetail{
+			Code:    "invalid_snapshot",
+			Message: "consistent listings cannot be filtered",
+			Param:   "consistent",
+		})
+		return
+	}
+
+	var page model.OrderPage
+	if consistent {
+		page, err = h.Orders.ListConsistent(r.Context(), snapshot, cursor, size)
+	} else if f.Empty() {
+		page, err = h.Orders.List(r.Context(), cursor, size)
+	} else {
+		page, err = h.Orders.Filter(r.Context(), f, cursor, size)
+	}
 
 	if err != nil {
-		fmt.Println("failed to find all:", err)
-		w.WriteHeader(http.StatusInternalServerError)
+		writeFailure(w, r, "find all", err)
 		return
 	}
 
-	var response struct {
-		Items []model.Order `json:"items"`
-		Next  uint64        `json:"next,omitempty"`
+	if page.Items, ok = visible(w, r, h.Orgs, page.Items); !ok {
+		return
 	}
 
-	response.Items = res.Orders
-	response.Next = res.Cursor
+	if r.URL.Query().Get("include_total") == "true" {
+		if !h.countTotal(w, r, f, &page) {
+			return
+		}
+	}
 
-	data, err := json.Marshal(response)
+	respond(w, r, http.StatusOK, page)
+}
+
+// countTotal sets the total of the listing from the read model, which only
+// counts orders by status and customer.
+func (h *Order) countTotal(w http.ResponseWriter, r *http.Request, f orderindex.Filter, page *model.OrderPage) bool {
+
+	if h.Views == nil {
+		writeError(w, http.StatusNotImplemented, errorDetail{
+			Code:    "total_unavailable",
+			Message: "order totals are not kept",
+			Param:   "include_total",
+		})
+		return false
+	}
+
+	if len(f.Tags) > 0 || f.From != nil || f.To != nil || f.MinTotal > 0 || f.SLA != "" ||
+		f.Warehouse != "" || f.HasOpenDispute {
+		writeError(w, http.StatusBadRequest, errorDetail{
+			Code:    "total_unavailable",
+			Message: "totals are only kept by status and customer_id",
+			Param:   "include_total",
+		})
+		return false
+	}
+
+	total, asOf, err := h.Views.Count(r.Context(), f.CustomerID, f.Status)
 	if err != nil {
-		fmt.Println("failed to marshal data @ [list] - ", err)
-		w.WriteHeader(http.StatusInternalServerError)
-		return
+		writeFailure(w, r, "count orders", err)
+		return false
 	}
 
-	w.Write(data)
+	page.Total, page.TotalAsOf = &total, &asOf
+
+	return true
 }
 
 func (h *Order) GetByID(w http.ResponseWriter, r *http.Request) {
 
-	idParam := chi.URLParam(r, "id")
+	orderID, ok := orderIDParam(w, r)
+	if !ok {
+		return
+	}
 
-	const base = 10
-	const bitSize = 64
+	c := codec.Negotiate(r.Header.Get("Accept"))
 
-	orderID, err := strconv.ParseUint(idParam, base, bitSize)
+	var gen uint64
 
-	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		return
+	if h.Cache != nil {
+		if data, ok := h.Cache.Get(orderID, c.Name()); ok {
+			writeEncoded(w, c, http.StatusOK, data)
+			return
+		}
+		gen = h.Cache.Generation(orderID)
 	}
 
 	o, err := h.Repo.FindByID(r.Context(), orderID)
-
-	if errors.Is(err, order.ErrNotExist) {
-		w.WriteHeader(http.StatusNotFound)
+	if err != nil {
+		writeFailure(w, r, "find by id", err)
 		return
-	} else if err != nil {
-		fmt.Println("failed to find by id:", err)
-		w.WriteHeader(http.StatusInternalServerError)
+	}
+
+	if !canView(w, r, h.Orgs, o) {
 		return
 	}
 
-	if err := json.NewEncoder(w).Encode(o); err != nil {
-		fmt.Println("failed to marshal JSON: ", err)
+	data, err := c.Marshal(o)
+	if err != nil {
+		fmt.Printf("failed to marshal %s: %v\n", c.Name(), err)
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
+
+	// Cache hits are not authorized, so only orders anyone may see are
+	// cached.
+	if h.Cache != nil && o.OrgID == nil {
+		h.Cache.Add(orderID, c.Name(), gen, data)
+	}
+
+	writeEncoded(w, c, http.StatusOK, data)
 }
 
 func (h *Order) UpdateByID(w http.ResponseWriter, r *http.Request) {
 
 	var body struct {
-		Status string `json:"status"`
+		Status   string          `json:"status"`
+		Tracking *model.Tracking `json:"tracking"`
+		readOnlyTimestamps
 	}
 
-	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
-		w.WriteHeader(http.StatusBadRequest)
+	if !decodeJSON(w, r, &body) || !body.check(w) || !checkTracking(w, body.Status, body.Tracking) {
 		return
 	}
 
-	idParam := chi.URLParam(r, "id")
+	orderID, ok := orderIDParam(w, r)
+	if !ok {
+		return
+	}
 
-	const base = 10
-	const bitSize = 64
+	if !h.checkChange(w, r, orderID) {
+		return
+	}
+
+	var theOrder model.Order
+	var err error
 
-	orderID, err := strconv.ParseUint(idParam, base, bitSize)
+	switch {
+	case body.Tracking != nil:
+		theOrder, err = h.Orders.ShipTracked(r.Context(), orderID, *body.Tracking)
+	case body.Status == model.StatusCancelled:
+		theOrder, err = h.Orders.Cancel(r.Context(), orderID, "")
+	default:
+		theOrder, err = h.Orders.Transition(r.Context(), orderID, body.Status)
+	}
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
+		writeFailure(w, r, "transition", err)
 		return
 	}
 
-	theOrder, err := h.Repo.FindByID(r.Context(), orderID)
-	if errors.Is(err, order.ErrNotExist) {
-		w.WriteHeader(http.StatusNotFound)
+	respond(w, r, http.StatusOK, theOrder)
+}
+
+func (h *Order) Approve(w http.ResponseWriter, r *http.Request) {
+
+	orderID, ok := orderIDParam(w, r)
+	if !ok {
+		return
+	}
+
+	if !h.checkChange(w, r, orderID) {
+		return
+	}
+
+	theOrder, err := h.Orders.Approve(r.Context(), orderID)
+	if err != nil {
+		writeFailure(w, r, "approve", err)
+		return
+	}
+
+	respond(w, r, http.StatusOK, theOrder)
+}
+
+type cancelRejection struct {
+	Error   errorDetail           `json:"error"`
+	Reasons []cancellation.Reason `json:"reasons"`
+}
+
+// Cancel cancels the order in the path if the cancellation policy allows
+// it. Reason, if given, is kept with the order. Rejections list every
+// rule that stood in the way.
+func (h *Order) Cancel(w http.ResponseWriter, r *http.Request) {
+
+	orderID, ok := orderIDParam(w, r)
+	if !ok {
+		return
+	}
+
+	var body struct {
+		Reason string `json:"reason"`
+	}
+
+	if r.ContentLength != 0 && !decodeJSON(w, r, &body) {
+		return
+	}
+
+	if len(body.Reason) > 500 {
+		writeError(w, http.StatusBadRequest, errorDetail{
+			Code:    "invalid_reason",
+			Message: "reason must be up to 500 characters",
+			Param:   "reason",
+		})
+		return
+	}
+
+	if !h.checkChange(w, r, orderID) {
+		return
+	}
+
+	theOrder, err := h.Orders.Cancel(r.Context(), orderID, body.Reason)
+
+	var rejection *cancellation.Rejection
+	if errors.As(err, &rejection) {
+		respondJSON(w, http.StatusConflict, cancelRejection{
+			Error: errorDetail{
+				Code:    "cancellation_rejected",
+				Message: rejection.Error(),
+			},
+			Reasons: rejection.Reasons,
+		})
 		return
 	} else if err != nil {
-		fmt.Println("failed to find by ID: ", err)
-		w.WriteHeader(http.StatusInternalServerError)
+		writeFailure(w, r, "cancel", err)
 		return
 	}
 
-	const completedStatus = "completed"
-	const shippedStatus = "shipped"
+	respond(w, r, http.StatusOK, theOrder)
+}
+
+func (h *Order) AddTags(w http.ResponseWriter, r *http.Request) {
+
+	var body struct {
+		Tags []string `json:"tags"`
+	}
 
-	now := time.Now().UTC()
+	if !decodeJSON(w, r, &body) {
+		return
+	}
+
+	orderID, ok := orderIDParam(w, r)
+	if !ok {
+		return
+	}
+
+	if !h.checkChange(w, r, orderID) {
+		return
+	}
+
+	theOrder, err := h.Orders.Tag(r.Context(), orderID, body.Tags)
+	if err != nil {
+		writeFailure(w, r, "tag", err)
+		return
+	}
+
+	respond(w, r, http.StatusOK, theOrder)
+}
+
+func (h *Order) RemoveTag(w http.ResponseWriter, r *http.Request) {
+
+	orderID, ok := orderIDParam(w, r)
+	if !ok {
+		return
+	}
+
+	if !h.checkChange(w, r, orderID) {
+		return
+	}
+
+	theOrder, err := h.Orders.Untag(r.Context(), orderID, chi.URLParam(r, "tag"))
+	if err != nil {
+		writeFailure(w, r, "untag", err)
+		return
+	}
+
+	respond(w, r, http.StatusOK, theOrder)
+}
+
+// Duplicate reorders the items of an order. Like bulk creates it always
+// writes synchronously, so the new order can be returned.
+func (h *Order) Duplicate(w http.ResponseWriter, r *http.Request) {
+
+	orderID, ok := orderIDParam(w, r)
+	if !ok {
+		return
+	}
 
-	switch body.Status {
-	case shippedStatus:
-		if theOrder.ShippedAt != nil {
-			w.WriteHeader(http.StatusBadRequest)
+	// The duplicate is placed for no organization, so seeing the order is
+	// enough.
+	if h.Orgs != nil {
+		src, err := h.Repo.FindByID(r.Context(), orderID)
+		if err != nil {
+			writeFailure(w, r, "find by id", err)
 			return
 		}
-		theOrder.ShippedAt = &now
-	case completedStatus:
-		if theOrder.CompletedAt != nil || theOrder.ShippedAt == nil {
-			w.WriteHeader(http.StatusBadRequest)
+		if !canView(w, r, h.Orgs, src) {
 			return
 		}
-		theOrder.CompletedAt = &now
-	default:
-		w.WriteHeader(http.StatusBadRequest)
+	}
+
+	theOrder, err := h.Orders.Duplicate(r.Context(), orderID)
+	if err != nil {
+		writeFailure(w, r, "duplicate", err)
 		return
 	}
 
-	if err = h.Repo.Update(r.Context(), theOrder); err != nil {
-		fmt.Println("failed to insert: ", err)
-		w.WriteHeader(http.StatusInternalServerError)
+	w.Header().Set("Location", fmt.Sprintf("/orders/%d", theOrder.OrderID))
+	respond(w, r, http.StatusCreated, theOrder)
+}
+
+func (h *Order) Merge(w http.ResponseWriter, r *http.Request) {
+
+	var body struct {
+		OrderIDs []uint64 `json:"order_ids"`
+	}
+
+	if !decodeJSON(w, r, &body) {
 		return
 	}
 
-	if err := json.NewEncoder(w).Encode(theOrder); err != nil {
-		fmt.Println("failed to marshal JSON: ", err)
-		w.WriteHeader(http.StatusInternalServerError)
+	for _, id := range body.OrderIDs {
+		if !h.checkChange(w, r, id) {
+			return
+		}
+	}
+
+	theOrder, err := h.Orders.Merge(r.Context(), body.OrderIDs)
+	if err != nil {
+		writeFailure(w, r, "merge", err)
 		return
 	}
+
+	respond(w, r, http.StatusOK, theOrder)
 }
 
-func (h *Order) DeleteByID(w http.ResponseWriter, r *http.Request) {
+type splitResult struct {
+	Order model.Order `json:"order"`
+	Split model.Order `json:"split"`
+}
 
-	idParam := chi.URLParam(r, "id")
+func (h *Order) Split(w http.ResponseWriter, r *http.Request) {
 
-	const base = 10
-	const bitSize = 64
+	orderID, ok := orderIDParam(w, r)
+	if !ok {
+		return
+	}
 
-	orderID, err := strconv.ParseUint(idParam, base, bitSize)
+	var body struct {
+		LineItems []model.LineItem `json:"line_items"`
+	}
+
+	if !decodeJSON(w, r, &body) {
+		return
+	}
+
+	if !h.checkChange(w, r, orderID) {
+		return
+	}
 
+	source, split, err := h.Orders.Split(r.Context(), orderID, body.LineItems)
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
+		writeFailure(w, r, "split", err)
 		return
 	}
 
-	err = h.Repo.DeleteByID(r.Context(), orderID)
+	w.Header().Set("Location", fmt.Sprintf("/orders/%d", split.OrderID))
+	respond(w, r, http.StatusCreated, splitResult{Order: source, Split: split})
+}
 
-	if errors.Is(err, order.ErrNotExist) {
-		w.WriteHeader(http.StatusNotFound)
+func (h *Order) DeleteByID(w http.ResponseWriter, r *http.Request) {
+
+	orderID, ok := orderIDParam(w, r)
+	if !ok {
 		return
-	} else if err != nil {
-		fmt.Println("failed to find by ID:", err)
-		w.WriteHeader(http.StatusInternalServerError)
+	}
+
+	if !h.checkChange(w, r, orderID) {
+		return
+	}
+
+	if err := h.Repo.DeleteByID(r.Context(), orderID); err != nil {
+		writeFailure(w, r, "delete by id", err)
 		return
 	}
+
+	if h.Comments != nil {
+		if err := h.Comments.Drop(r.Context(), orderID); err != nil {
+			fmt.Println("failed to drop comments:", err)
+		}
+	}
+}
+
+// checkMember tells whether the caller may place orders for the
+// organization, answering the request when it may not.
+func (h *Order) checkMember(w http.ResponseWriter, r *http.Request, id uuid.UUID) bool {
+
+	if h.Orgs == nil {
+		writeError(w, http.StatusNotImplemented, errorDetail{
+			Code:    "orgs_unavailable",
+			Message: "organizations are not enabled",
+			Param:   "org_id",
+		})
+		return false
+	}
+
+	if _, err := h.Orgs.Role(r.Context(), id); err != nil {
+		writeFailure(w, r, "authorize organization", err)
+		return false
+	}
+
+	return true
+}
+
+// checkChange tells whether the caller may change the order, answering
+// the request when it may not.
+func (h *Order) checkChange(w http.ResponseWriter, r *http.Request, orderID uint64) bool {
+
+	if h.Orgs == nil {
+		return true
+	}
+
+	o, err := h.Repo.FindByID(r.Context(), orderID)
+	if err != nil {
+		writeFailure(w, r, "find by id", err)
+		return false
+	}
+
+	return canChange(w, r, h.Orgs, o)
 }
@@ -0,0 +1,209 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/i101dev/microservices-NN/model"
+	"github.com/i101dev/microservices-NN/orderindex"
+	"github.com/i101dev/microservices-NN/org"
+	"github.com/i101dev/microservices-NN/service"
+)
+
+// Orgs manages organizations and their members, and lists the orders of
+// an organization to its members.
+type Orgs struct {
+	Store  *org.Store
+	Orders *service.Orders
+}
+
+type orgReport struct {
+	org.Org
+	Members []org.Member `json:"members"`
+}
+
+func orgParam(w http.ResponseWriter, r *http.Request) (uuid.UUID, bool) {
+
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, errorDetail{
+			Code:    "invalid_org_id",
+			Message: "organization id must be a uuid",
+			Param:   "id",
+		})
+		return uuid.UUID{}, false
+	}
+
+	return id, true
+}
+
+func (h *Orgs) Create(w http.ResponseWriter, r *http.Request) {
+
+	var body struct {
+		Name string `json:"name"`
+	}
+
+	if !decodeJSON(w, r, &body) {
+		return
+	}
+
+	if body.Name == "" {
+		writeError(w, http.StatusBadRequest, errorDetail{
+			Code:    "invalid_name",
+			Message: "name is required",
+			Param:   "name",
+		})
+		return
+	}
+
+	o, err := h.Store.Create(r.Context(), body.Name)
+	if err != nil {
+		writeFailure(w, r, "create organization", err)
+		return
+	}
+
+	w.Header().Set("Location", "/admin/orgs/"+o.ID.String())
+	respondJSON(w, http.StatusCreated, o)
+}
+
+func (h *Orgs) Get(w http.ResponseWriter, r *http.Request) {
+
+	id, ok := orgParam(w, r)
+	if !ok {
+		return
+	}
+
+	o, err := h.Store.Get(r.Context(), id)
+	if err != nil {
+		writeFailure(w, r, "get organization", err)
+		return
+	}
+
+	members, err := h.Store.Members(r.Context(), id)
+	if err != nil {
+		writeFailure(w, r, "list organization members", err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, orgReport{Org: o, Members: members})
+}
+
+// SetMember adds the subject in the path to the organization with the role
+// in the body, or changes the one it had.
+func (h *Orgs) SetMember(w http.ResponseWriter, r *http.Request) {
+
+	id, ok := orgParam(w, r)
+	if !ok {
+		return
+	}
+
+	var body struct {
+		Role string `json:"role"`
+	}
+
+	if !decodeJSON(w, r, &body) {
+		return
+	}
+
+	m := org.Member{Subject: chi.URLParam(r, "subject"), Role: body.Role}
+
+	if err := h.Store.SetMember(r.Context(), id, m); err != nil {
+		writeFailure(w, r, "set organization member", err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, m)
+}
+
+func (h *Orgs) RemoveMember(w http.ResponseWriter, r *http.Request) {
+
+	id, ok := orgParam(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.Store.RemoveMember(r.Context(), id, chi.URLParam(r, "subject")); err != nil {
+		writeFailure(w, r, "remove organization member", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListOrders lists the orders placed for the organization, oldest first, to
+// its members.
+func (h *Orgs) ListOrders(w http.ResponseWriter, r *http.Request) {
+
+	id, ok := orgParam(w, r)
+	if !ok {
+		return
+	}
+
+	cursor, ok := cursorParam(w, r)
+	if !ok {
+		return
+	}
+
+	if _, err := h.Store.Role(r.Context(), id); err != nil {
+		writeFailure(w, r, "authorize organization", err)
+		return
+	}
+
+	page, err := h.Orders.Filter(r.Context(), orderindex.Filter{OrgID: &id}, cursor, 50)
+	if err != nil {
+		writeFailure(w, r, "list organization orders", err)
+		return
+	}
+
+	respond(w, r, http.StatusOK, page)
+}
+
+// canView tells whether the caller may see the order, answering the
+// request when it may not. Without organizations every order is visible.
+func canView(w http.ResponseWriter, r *http.Request, orgs *org.Store, o model.Order) bool {
+
+	if orgs == nil {
+		return true
+	}
+
+	if err := orgs.CanView(r.Context(), o); err != nil {
+		writeFailure(w, r, "authorize order", err)
+		return false
+	}
+
+	return true
+}
+
+// canChange is canView for requests that change the order, which take the
+// manager role in its organization.
+func canChange(w http.ResponseWriter, r *http.Request, orgs *org.Store, o model.Order) bool {
+
+	if orgs == nil {
+		return true
+	}
+
+	if err := orgs.CanCancel(r.Context(), o); err != nil {
+		writeFailure(w, r, "authorize order", err)
+		return false
+	}
+
+	return true
+}
+
+// visible drops the orders the caller may not see from a listing,
+// answering the request when it fails.
+func visible(w http.ResponseWriter, r *http.Request, orgs *org.Store, orders []model.Order) ([]model.Order, bool) {
+
+	if orgs == nil {
+		return orders, true
+	}
+
+	orders, err := orgs.Visible(r.Context(), orders)
+	if err != nil {
+		writeFailure(w, r, "authorize orders", err)
+		return nil, false
+	}
+
+	return orders, true
+}
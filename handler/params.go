@@ -0,0 +1,172 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/i101dev/microservices-NN/model"
+)
+
+// Older clients send IDs as 16 hex digits or with a 0x prefix. Anything
+// else that is all digits is decimal.
+var hexOrderID = regexp.MustCompile(`^[0-9a-fA-F]{16}$`)
+
+type errorBody struct {
+	Error errorDetail `json:"error"`
+}
+
+type errorDetail struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Param   string `json:"param,omitempty"`
+}
+
+func writeError(w http.ResponseWriter, status int, detail errorDetail) {
+	respondJSON(w, status, errorBody{Error: detail})
+}
+
+func parseOrderID(s string) (uint64, error) {
+
+	const bitSize = 64
+
+	switch {
+	case strings.HasPrefix(s, "0x") || strings.HasPrefix(s, "0X"):
+		return strconv.ParseUint(s[2:], 16, bitSize)
+	case hexOrderID.MatchString(s) && strings.ContainsAny(s, "abcdefABCDEF"):
+		return strconv.ParseUint(s, 16, bitSize)
+	default:
+		return strconv.ParseUint(s, 10, bitSize)
+	}
+}
+
+// orderIDParam parses the {id} path parameter, writing a 400 with a
+// structured error and returning false when it is not a valid order ID.
+func orderIDParam(w http.ResponseWriter, r *http.Request) (uint64, bool) {
+
+	param := chi.URLParam(r, "id")
+
+	id, err := parseOrderID(param)
+	if err == nil {
+		return id, true
+	}
+
+	detail := errorDetail{
+		Code:    "invalid_order_id",
+		Message: "order id must be a decimal or hex encoded unsigned 64-bit integer",
+		Param:   "id",
+	}
+
+	if errors.Is(err, strconv.ErrRange) {
+		detail.Code = "order_id_out_of_range"
+		detail.Message = "order id does not fit in an unsigned 64-bit integer"
+	}
+
+	writeError(w, http.StatusBadRequest, detail)
+	return 0, false
+}
+
+// readOnlyTimestamps is embedded in request bodies so that a client trying
+// to set a server-managed timestamp gets a 400 instead of being ignored.
+type readOnlyTimestamps struct {
+	CreatedAt   json.RawMessage `json:"created_at"`
+	UpdatedAt   json.RawMessage `json:"updated_at"`
+	ShippedAt   json.RawMessage `json:"shipped_at"`
+	CompletedAt json.RawMessage `json:"completed_at"`
+	CancelledAt json.RawMessage `json:"cancelled_at"`
+	Estimated   json.RawMessage `json:"estimated_delivery"`
+	Tax         json.RawMessage `json:"tax"`
+	Redemptions json.RawMessage `json:"redemptions"`
+}
+
+func (t readOnlyTimestamps) check(w http.ResponseWriter) bool {
+
+	fields := []struct {
+		name  string
+		value json.RawMessage
+	}{
+		{"created_at", t.CreatedAt},
+		{"updated_at", t.UpdatedAt},
+		{"shipped_at", t.ShippedAt},
+		{"completed_at", t.CompletedAt},
+		{"cancelled_at", t.CancelledAt},
+		{"estimated_delivery", t.Estimated},
+		{"tax", t.Tax},
+		{"redemptions", t.Redemptions},
+	}
+
+	for _, f := range fields {
+		if f.value != nil && string(f.value) != "null" {
+			writeError(w, http.StatusBadRequest, errorDetail{
+				Code:    "read_only_field",
+				Message: f.name + " is set by the server",
+				Param:   f.name,
+			})
+			return false
+		}
+	}
+
+	return true
+}
+
+// checkShipping accepts a missing shipping, or one with a method and a
+// two letter country code.
+func checkShipping(w http.ResponseWriter, s *model.Shipping) bool {
+
+	if s == nil {
+		return true
+	}
+
+	if s.Method == "" {
+		writeError(w, http.StatusBadRequest, errorDetail{
+			Code:    "invalid_shipping",
+			Message: "shipping.method is required",
+			Param:   "shipping.method",
+		})
+		return false
+	}
+
+	if len(s.Country) != 2 {
+		writeError(w, http.StatusBadRequest, errorDetail{
+			Code:    "invalid_shipping",
+			Message: "shipping.country must be an ISO 3166-1 alpha-2 code",
+			Param:   "shipping.country",
+		})
+		return false
+	}
+
+	return true
+}
+
+// checkTracking accepts a missing tracking, or a carrier and number on an
+// order being shipped.
+func checkTracking(w http.ResponseWriter, status string, t *model.Tracking) bool {
+
+	if t == nil {
+		return true
+	}
+
+	if status != model.StatusShipped {
+		writeError(w, http.StatusBadRequest, errorDetail{
+			Code:    "invalid_tracking",
+			Message: "tracking can only be given when shipping",
+			Param:   "tracking",
+		})
+		return false
+	}
+
+	if t.Carrier == "" || t.Number == "" {
+		writeError(w, http.StatusBadRequest, errorDetail{
+			Code:    "invalid_tracking",
+			Message: "tracking.carrier and tracking.number are required",
+			Param:   "tracking",
+		})
+		return false
+	}
+
+	return true
+}
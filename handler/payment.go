@@ -0,0 +1,118 @@
+package handler
+
+import (
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/i101dev/microservices-NN/clock"
+	"github.com/i101dev/microservices-NN/events"
+	"github.com/i101dev/microservices-NN/model"
+	"github.com/i101dev/microservices-NN/payment"
+	"github.com/i101dev/microservices-NN/service"
+	"github.com/i101dev/microservices-NN/tenant"
+)
+
+// Payments takes the payment provider's webhooks. Each provider event is
+// applied at most once, however often it is delivered.
+type Payments struct {
+	Orders *service.Orders
+	Secret []byte
+	Dedup  *payment.Dedup
+	// Events, when set, gets an order.payment_* or order.dispute_* event
+	// for every notification applied.
+	Events *events.Publisher
+	Clock  clock.Clock
+}
+
+type paymentWebhookResponse struct {
+	EventID   string `json:"event_id"`
+	Duplicate bool   `json:"duplicate,omitempty"`
+}
+
+// Webhook answers a redelivered event with 200 and duplicate set, without
+// applying it again. An event that fails to apply, for example a capture
+// that arrives before its authorization, is answered with an error and
+// not remembered, so the provider's retry gets another go.
+func (h *Payments) Webhook(w http.ResponseWriter, r *http.Request) {
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			w.WriteHeader(http.StatusRequestEntityTooLarge)
+			return
+		}
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	if err := payment.Verify(h.Secret, r.Header, body, h.Clock.Now()); err != nil {
+		writeFailure(w, r, "verify webhook", err)
+		return
+	}
+
+	n, err := payment.Parse(body)
+	if err != nil {
+		writeFailure(w, r, "parse webhook", err)
+		return
+	}
+
+	claimed, err := h.Dedup.Claim(r.Context(), n.ID)
+	if err != nil {
+		writeFailure(w, r, "claim payment event", err)
+		return
+	} else if !claimed {
+		respondJSON(w, http.StatusOK, paymentWebhookResponse{EventID: n.ID, Duplicate: true})
+		return
+	}
+
+	var o model.Order
+	var d model.Dispute
+	var eventType string
+
+	switch n.Type {
+	case payment.TypeAuthorized:
+		o, err = h.Orders.AuthorizePayment(r.Context(), n.OrderID, n.Reference, n.Amount)
+		eventType = events.TypePaymentAuthorized
+	case payment.TypeCaptured:
+		o, err = h.Orders.CapturePayment(r.Context(), n.OrderID, n.Amount)
+		eventType = events.TypePaymentCaptured
+	case payment.TypeRefunded:
+		o, err = h.Orders.RefundPayment(r.Context(), n.OrderID, n.Amount)
+		eventType = events.TypePaymentRefunded
+	case payment.TypeDisputeOpened:
+		o, d, err = h.Orders.OpenDispute(r.Context(), n.OrderID, model.Dispute{
+			ID:            n.DisputeID,
+			Reason:        n.Reason,
+			Amount:        n.Amount,
+			EvidenceDueBy: n.EvidenceDueBy,
+		})
+		eventType = events.TypeDisputeOpened
+	case payment.TypeDisputeUpdated:
+		o, d, err = h.Orders.UpdateDispute(r.Context(), n.OrderID, n.DisputeID, n.DisputeStatus, n.EvidenceDueBy)
+		eventType = events.TypeDisputeUpdated
+	case payment.TypeDisputeClosed:
+		o, d, err = h.Orders.CloseDispute(r.Context(), n.OrderID, n.DisputeID, n.DisputeStatus)
+		eventType = events.TypeDisputeClosed
+	}
+
+	if err != nil {
+		if err := h.Dedup.Release(r.Context(), n.ID); err != nil {
+			writeFailure(w, r, "release payment event", err)
+			return
+		}
+		writeFailure(w, r, "apply payment event", err)
+		return
+	}
+
+	switch {
+	case h.Events == nil:
+	case n.DisputeID != "":
+		h.Events.Publish(r.Context(), events.NewDisputeChanged(tenant.FromContext(r.Context()), eventType, o, d, n.ID, h.Clock.Now()))
+	default:
+		h.Events.Publish(r.Context(), events.NewPaymentChanged(tenant.FromContext(r.Context()), eventType, o, n.ID, n.Amount, h.Clock.Now()))
+	}
+
+	respondJSON(w, http.StatusOK, paymentWebhookResponse{EventID: n.ID})
+}
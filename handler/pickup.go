@@ -0,0 +1,118 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/i101dev/microservices-NN/model"
+	"github.com/i101dev/microservices-NN/org"
+	"github.com/i101dev/microservices-NN/pickup"
+	"github.com/i101dev/microservices-NN/repository/order"
+)
+
+// Pickup issues the QR codes customers show to collect an order in
+// store, and checks them at the counter.
+type Pickup struct {
+	Repo   order.Repository
+	Signer *pickup.Signer
+	Orgs   *org.Store
+}
+
+func (h *Pickup) QRCode(w http.ResponseWriter, r *http.Request) {
+
+	orderID, ok := orderIDParam(w, r)
+	if !ok {
+		return
+	}
+
+	o, err := h.Repo.FindByID(r.Context(), orderID)
+	if err != nil {
+		writeFailure(w, r, "find by id", err)
+		return
+	}
+
+	if !canView(w, r, h.Orgs, o) {
+		return
+	}
+
+	if !collectable(w, o) {
+		return
+	}
+
+	code, issued := h.Signer.Sign(o.OrderID)
+
+	data, err := pickup.PNG(code, 256)
+	if err != nil {
+		writeFailure(w, r, "encode pickup code", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("inline; filename=\"pickup-%d.png\"", orderID))
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	w.Header().Set("Cache-Control", "private, no-store")
+	w.Header().Set("Expires", issued.ExpiresAt.Format(http.TimeFormat))
+	w.WriteHeader(http.StatusOK)
+	w.Write(data)
+}
+
+type verifyCodeResponse struct {
+	OrderID   uint64    `json:"order_id"`
+	Status    string    `json:"status"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// Verify checks a scanned code and that its order can still be handed
+// over.
+func (h *Pickup) Verify(w http.ResponseWriter, r *http.Request) {
+
+	var body struct {
+		Code string `json:"code"`
+	}
+
+	if !decodeJSON(w, r, &body) {
+		return
+	}
+
+	c, err := h.Signer.Verify(body.Code)
+	if err != nil {
+		writeFailure(w, r, "verify pickup code", err)
+		return
+	}
+
+	o, err := h.Repo.FindByID(r.Context(), c.OrderID)
+	if err != nil {
+		writeFailure(w, r, "find by id", err)
+		return
+	}
+
+	if !canView(w, r, h.Orgs, o) {
+		return
+	}
+
+	if !collectable(w, o) {
+		return
+	}
+
+	respondJSON(w, http.StatusOK, verifyCodeResponse{
+		OrderID:   o.OrderID,
+		Status:    o.Status(),
+		ExpiresAt: c.ExpiresAt,
+	})
+}
+
+func collectable(w http.ResponseWriter, o model.Order) bool {
+
+	switch o.Status() {
+	case model.StatusCompleted, model.StatusCancelled:
+		writeError(w, http.StatusConflict, errorDetail{
+			Code:    "not_collectable",
+			Message: "order is " + o.Status() + " and cannot be collected",
+		})
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,119 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/i101dev/microservices-NN/auth"
+	"github.com/i101dev/microservices-NN/clock"
+	"github.com/i101dev/microservices-NN/events"
+	"github.com/i101dev/microservices-NN/model"
+	"github.com/i101dev/microservices-NN/org"
+	"github.com/i101dev/microservices-NN/service"
+	"github.com/i101dev/microservices-NN/tenant"
+)
+
+// Priorities lets admins expedite orders that are still to ship.
+type Priorities struct {
+	Orders *service.Orders
+	// Events, when set, gets an order.expedited event for every order
+	// expedited, for fulfillment to re-sequence its work.
+	Events *events.Publisher
+	Clock  clock.Clock
+	Orgs   *org.Store
+}
+
+// admin reports whether the caller holds the admin scope itself, rather
+// than through someone it impersonates.
+func admin(r *http.Request) bool {
+	p, ok := auth.FromContext(r.Context())
+	return ok && p.HasScope(auth.ScopeAdmin) && p.ImpersonatedBy == ""
+}
+
+func writeNotAdmin(w http.ResponseWriter, param string) {
+	writeError(w, http.StatusForbidden, errorDetail{
+		Code:    "forbidden",
+		Message: "expediting orders takes the " + auth.ScopeAdmin + " scope",
+		Param:   param,
+	})
+}
+
+// checkPriority accepts an empty or normal priority from anyone, and the
+// expedited one from admins.
+func checkPriority(w http.ResponseWriter, r *http.Request, priority string) bool {
+
+	if priority == "" {
+		return true
+	}
+
+	if !model.ValidPriority(priority) {
+		writeError(w, http.StatusBadRequest, errorDetail{
+			Code:    "invalid_priority",
+			Message: "priority must be normal or expedited",
+			Param:   "priority",
+		})
+		return false
+	}
+
+	if priority == model.PriorityExpedited && !admin(r) {
+		writeNotAdmin(w, "priority")
+		return false
+	}
+
+	return true
+}
+
+// Expedite gives the order in the path the expedited priority. Reason, if
+// given, is passed on to fulfillment.
+func (h *Priorities) Expedite(w http.ResponseWriter, r *http.Request) {
+
+	if !admin(r) {
+		writeNotAdmin(w, "")
+		return
+	}
+
+	orderID, ok := orderIDParam(w, r)
+	if !ok {
+		return
+	}
+
+	var body struct {
+		Reason string `json:"reason"`
+	}
+
+	if r.ContentLength != 0 && !decodeJSON(w, r, &body) {
+		return
+	}
+
+	if len(body.Reason) > 500 {
+		writeError(w, http.StatusBadRequest, errorDetail{
+			Code:    "invalid_reason",
+			Message: "reason must be up to 500 characters",
+			Param:   "reason",
+		})
+		return
+	}
+
+	if h.Orgs != nil {
+		o, err := h.Orders.Get(r.Context(), orderID)
+		if err != nil {
+			writeFailure(w, r, "find by id", err)
+			return
+		}
+		if !canChange(w, r, h.Orgs, o) {
+			return
+		}
+	}
+
+	o, err := h.Orders.Expedite(r.Context(), orderID)
+	if err != nil {
+		writeFailure(w, r, "expedite", err)
+		return
+	}
+
+	if h.Events != nil {
+		p, _ := auth.FromContext(r.Context())
+		h.Events.Publish(r.Context(), events.NewOrderExpedited(tenant.FromContext(r.Context()), o, p.Subject, body.Reason, h.Clock.Now()))
+	}
+
+	respond(w, r, http.StatusOK, o)
+}
@@ -0,0 +1,137 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/i101dev/microservices-NN/loadshed"
+	"github.com/i101dev/microservices-NN/quota"
+)
+
+// Quotas manages the daily quotas of API keys, and shows callers what is
+// left of theirs.
+type Quotas struct {
+	Store *quota.Store
+}
+
+type quotaReport struct {
+	quota.Quota
+	Usage []quota.Usage `json:"usage"`
+}
+
+// Set gives the API key in the body its limits. Keys are only sent here;
+// the quota is named by an ID derived from the key from then on.
+func (h *Quotas) Set(w http.ResponseWriter, r *http.Request) {
+
+	var body struct {
+		APIKey string `json:"api_key"`
+		quota.Limits
+	}
+
+	if !decodeJSON(w, r, &body) {
+		return
+	}
+
+	if body.APIKey == "" {
+		writeError(w, http.StatusBadRequest, errorDetail{
+			Code:    "invalid_api_key",
+			Message: "api_key is required",
+			Param:   "api_key",
+		})
+		return
+	}
+
+	if body.RequestsPerDay < 0 || body.OrdersPerDay < 0 {
+		writeError(w, http.StatusBadRequest, errorDetail{
+			Code:    "invalid_limit",
+			Message: "limits cannot be negative; zero is no limit",
+		})
+		return
+	}
+
+	q, err := h.Store.Set(r.Context(), body.APIKey, body.Limits)
+	if err != nil {
+		writeFailure(w, r, "set quota", err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, q)
+}
+
+func (h *Quotas) List(w http.ResponseWriter, r *http.Request) {
+
+	quotas, err := h.Store.List(r.Context())
+	if err != nil {
+		writeFailure(w, r, "list quotas", err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string][]quota.Quota{"items": quotas})
+}
+
+func (h *Quotas) Get(w http.ResponseWriter, r *http.Request) {
+
+	report, err := h.report(r, chi.URLParam(r, "id"))
+	if err != nil {
+		writeFailure(w, r, "get quota", err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, report)
+}
+
+func (h *Quotas) Delete(w http.ResponseWriter, r *http.Request) {
+
+	if err := h.Store.Delete(r.Context(), chi.URLParam(r, "id")); err != nil {
+		writeFailure(w, r, "delete quota", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Limits reports the quota of the caller's API key. Keys without one have
+// no limits, and an empty usage list.
+func (h *Quotas) Limits(w http.ResponseWriter, r *http.Request) {
+
+	apiKey := r.Header.Get(loadshed.APIKeyHeader)
+	if apiKey == "" {
+		writeError(w, http.StatusUnauthorized, errorDetail{
+			Code:    "missing_api_key",
+			Message: "limits are kept per " + loadshed.APIKeyHeader,
+		})
+		return
+	}
+
+	report, err := h.report(r, quota.ID(apiKey))
+	if err == nil {
+		respondJSON(w, http.StatusOK, report)
+		return
+	}
+
+	if !errors.Is(err, quota.ErrNotExist) {
+		writeFailure(w, r, "get limits", err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, quotaReport{
+		Quota: quota.Quota{ID: quota.ID(apiKey)},
+		Usage: []quota.Usage{},
+	})
+}
+
+func (h *Quotas) report(r *http.Request, id string) (quotaReport, error) {
+
+	q, err := h.Store.Get(r.Context(), id)
+	if err != nil {
+		return quotaReport{}, err
+	}
+
+	usage, err := h.Store.Usage(r.Context(), id)
+	if err != nil {
+		return quotaReport{}, err
+	}
+
+	return quotaReport{Quota: q, Usage: usage}, nil
+}
@@ -0,0 +1,82 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/i101dev/microservices-NN/model"
+	"github.com/i101dev/microservices-NN/service"
+)
+
+// Quotes prices orders that customers have not committed to yet, and
+// turns accepted quotes into orders.
+type Quotes struct {
+	Orders *service.Orders
+	// TTL is how long a quote holds its prices.
+	TTL time.Duration
+}
+
+func (h *Quotes) Create(w http.ResponseWriter, r *http.Request) {
+
+	var body struct {
+		CustomerID uuid.UUID        `json:"customer_id"`
+		LineItems  []model.LineItem `json:"line_items"`
+		Shipping   *model.Shipping  `json:"shipping"`
+	}
+
+	if !decodeJSON(w, r, &body) || !checkShipping(w, body.Shipping) {
+		return
+	}
+
+	q, err := h.Orders.Quote(r.Context(), service.Draft{
+		CustomerID: body.CustomerID,
+		LineItems:  body.LineItems,
+		Shipping:   body.Shipping,
+	}, h.TTL)
+	if err != nil {
+		writeFailure(w, r, "quote", err)
+		return
+	}
+
+	w.Header().Set("Location", "/quotes/"+q.ID)
+	respondJSON(w, http.StatusCreated, q)
+}
+
+func (h *Quotes) Get(w http.ResponseWriter, r *http.Request) {
+
+	q, err := h.Orders.GetQuote(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		writeFailure(w, r, "get quote", err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, q)
+}
+
+func (h *Quotes) Accept(w http.ResponseWriter, r *http.Request) {
+
+	q, err := h.Orders.AcceptQuote(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		writeFailure(w, r, "accept quote", err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, q)
+}
+
+// Convert places the order of an accepted quote. Like bulk creates it
+// always writes synchronously, so the order can be returned.
+func (h *Quotes) Convert(w http.ResponseWriter, r *http.Request) {
+
+	o, err := h.Orders.ConvertQuote(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		writeFailure(w, r, "convert quote", err)
+		return
+	}
+
+	w.Header().Set("Location", fmt.Sprintf("/orders/%d", o.OrderID))
+	respond(w, r, http.StatusCreated, o)
+}
@@ -0,0 +1,89 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/i101dev/microservices-NN/codec"
+)
+
+func respond(w http.ResponseWriter, r *http.Request, status int, v any) {
+
+	c := codec.Negotiate(r.Header.Get("Accept"))
+
+	data, err := c.Marshal(v)
+
+	// Resources the codec has no representation of are still sent as JSON.
+	if errors.Is(err, codec.ErrUnsupportedType) {
+		c = codec.JSON
+		data, err = c.Marshal(v)
+	}
+
+	if err != nil {
+		fmt.Printf("failed to marshal %s: %v\n", c.Name(), err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	writeEncoded(w, c, status, data)
+}
+
+// respondJSON is for resources that only have a JSON representation.
+func respondJSON(w http.ResponseWriter, status int, v any) {
+
+	data, err := codec.JSON.Marshal(v)
+	if err != nil {
+		fmt.Println("failed to marshal json:", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	writeEncoded(w, codec.JSON, status, data)
+}
+
+func writeEncoded(w http.ResponseWriter, c codec.Codec, status int, data []byte) {
+	w.Header().Set("Content-Type", c.ContentType())
+	w.WriteHeader(status)
+	w.Write(data)
+}
+
+func decodeJSON(w http.ResponseWriter, r *http.Request, v any) bool {
+	_, ok := decodeJSONFields(w, r, v)
+	return ok
+}
+
+// decodeJSONFields is decodeJSON returning the fields of the body v has no
+// place for, when UnknownFields tolerates them.
+func decodeJSONFields(w http.ResponseWriter, r *http.Request, v any) ([]unknownField, bool) {
+
+	if _, ok := r.Context().Value(fieldsKey{}).(fieldsMode); !ok {
+		return nil, decoded(w, json.NewDecoder(r.Body).Decode(v))
+	}
+
+	var data json.RawMessage
+	if !decoded(w, json.NewDecoder(r.Body).Decode(&data)) || !decoded(w, json.Unmarshal(data, v)) {
+		return nil, false
+	}
+
+	return checkUnknown(w, r, data, v)
+}
+
+// decoded answers the request when decoding failed with err, and tells
+// whether it went through.
+func decoded(w http.ResponseWriter, err error) bool {
+
+	if err == nil {
+		return true
+	}
+
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		w.WriteHeader(http.StatusRequestEntityTooLarge)
+		return false
+	}
+
+	w.WriteHeader(http.StatusBadRequest)
+	return false
+}
@@ -0,0 +1,47 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/i101dev/microservices-NN/retention"
+)
+
+type Retention struct {
+	Enforcer *retention.Enforcer
+}
+
+func (h *Retention) Report(w http.ResponseWriter, r *http.Request) {
+
+	report, err := h.Enforcer.LastReport(r.Context())
+	if err != nil {
+		writeFailure(w, r, "get retention report", err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, report)
+}
+
+// Run enforces the policies now. It is a dry run unless the caller passes
+// dry_run=false.
+func (h *Retention) Run(w http.ResponseWriter, r *http.Request) {
+
+	dryRun := true
+
+	if s := r.URL.Query().Get("dry_run"); s != "" {
+		value, err := strconv.ParseBool(s)
+		if err != nil {
+			http.Error(w, "dry_run must be a boolean", http.StatusBadRequest)
+			return
+		}
+		dryRun = value
+	}
+
+	report, err := h.Enforcer.Run(r.Context(), dryRun)
+	if err != nil {
+		writeFailure(w, r, "enforce retention", err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, report)
+}
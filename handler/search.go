@@ -0,0 +1,98 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/i101dev/microservices-NN/search"
+)
+
+type searchPage struct {
+	search.Result
+	Next uint64 `json:"next,omitempty"`
+}
+
+// Search answers the queries listings cannot, free text in q and the
+// aggregations named in agg, from the search index. The filter parameters
+// are the ones of GET /orders. Results can lag writes by a moment, as the
+// index is updated from the changefeed.
+func (h *Order) Search(w http.ResponseWriter, r *http.Request) {
+
+	if h.SearchIndex == nil {
+		writeError(w, http.StatusNotImplemented, errorDetail{
+			Code:    "search_unavailable",
+			Message: "order search is not enabled",
+		})
+		return
+	}
+
+	q := r.URL.Query()
+
+	query := search.Query{
+		Text: strings.TrimSpace(q.Get("q")),
+		Size: 50,
+	}
+
+	if s := q.Get("cursor"); s != "" {
+		cursor, err := strconv.Atoi(s)
+		if err != nil || cursor < 0 {
+			writeError(w, http.StatusBadRequest, errorDetail{
+				Code:    "invalid_cursor",
+				Message: "cursor must be a whole number",
+				Param:   "cursor",
+			})
+			return
+		}
+		query.From = cursor
+	}
+
+	if s := q.Get("size"); s != "" {
+		size, err := strconv.Atoi(s)
+		if err != nil || size < 0 || size > 100 {
+			writeError(w, http.StatusBadRequest, errorDetail{
+				Code:    "invalid_size",
+				Message: "size must be between 0 and 100",
+				Param:   "size",
+			})
+			return
+		}
+		query.Size = size
+	}
+
+	for _, agg := range q["agg"] {
+		for _, name := range strings.Split(agg, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				query.Aggregations = append(query.Aggregations, name)
+			}
+		}
+	}
+
+	f, ok := h.listFilter(w, r)
+	if !ok {
+		return
+	}
+	query.Filter = f
+
+	if h.Orgs != nil {
+		orgs, err := h.Orgs.Orgs(r.Context())
+		if err != nil {
+			writeFailure(w, r, "authorize orders", err)
+			return
+		}
+		query.RestrictOrgs, query.Orgs = true, orgs
+	}
+
+	res, err := h.SearchIndex.Search(r.Context(), query)
+	if err != nil {
+		writeFailure(w, r, "search orders", err)
+		return
+	}
+
+	page := searchPage{Result: res}
+	if end := query.From + query.Size; query.Size > 0 && end < res.Total && end < search.MaxResults {
+		page.Next = uint64(end)
+	}
+
+	respondJSON(w, http.StatusOK, page)
+}
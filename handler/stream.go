@@ -0,0 +1,63 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/i101dev/microservices-NN/model"
+	"github.com/i101dev/microservices-NN/repository/order"
+)
+
+func (h *Order) Stream(w http.ResponseWriter, r *http.Request) {
+
+	// bulk downloads outlive the server write timeout
+	http.NewResponseController(w).SetWriteDeadline(time.Time{})
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+
+	flusher, _ := w.(http.Flusher)
+	encoder := json.NewEncoder(w)
+
+	const size = 200
+	err := order.ForEachSnapshotPage(r.Context(), h.Repo, size, func(orders []model.Order) error {
+
+		orders, err := h.visiblePage(r, orders)
+		if err != nil {
+			return err
+		}
+
+		for _, o := range orders {
+			if err := encoder.Encode(o); err != nil {
+				return fmt.Errorf("failed to write order: %w", err)
+			}
+		}
+
+		if flusher != nil {
+			flusher.Flush()
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		fmt.Println("failed to stream orders:", err)
+	}
+}
+
+// visiblePage is visible for responses that are already under way, which
+// can only be cut short.
+func (h *Order) visiblePage(r *http.Request, orders []model.Order) ([]model.Order, error) {
+
+	if h.Orgs == nil {
+		return orders, nil
+	}
+
+	orders, err := h.Orgs.Visible(r.Context(), orders)
+	if err != nil {
+		return nil, fmt.Errorf("failed to authorize orders: %w", err)
+	}
+
+	return orders, nil
+}
@@ -0,0 +1,114 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/i101dev/microservices-NN/clock"
+	"github.com/i101dev/microservices-NN/model"
+	"github.com/i101dev/microservices-NN/subscription"
+	"github.com/i101dev/microservices-NN/tenant"
+)
+
+// Subscriptions manages the recurring orders of customers. The orders
+// themselves are placed by a scheduled task.
+type Subscriptions struct {
+	Store *subscription.Store
+	Clock clock.Clock
+}
+
+func (h *Subscriptions) Create(w http.ResponseWriter, r *http.Request) {
+
+	var body struct {
+		CustomerID uuid.UUID        `json:"customer_id"`
+		LineItems  []model.LineItem `json:"line_items"`
+		Shipping   *model.Shipping  `json:"shipping"`
+		Schedule   string           `json:"schedule"`
+	}
+
+	if !decodeJSON(w, r, &body) || !checkShipping(w, body.Shipping) {
+		return
+	}
+
+	s, err := subscription.New(tenant.FromContext(r.Context()), body.CustomerID, body.LineItems, body.Shipping, body.Schedule, h.Clock.Now().UTC())
+	if err != nil {
+		writeFailure(w, r, "create subscription", err)
+		return
+	}
+
+	if err := h.Store.Save(r.Context(), s); err != nil {
+		writeFailure(w, r, "save subscription", err)
+		return
+	}
+
+	w.Header().Set("Location", "/subscriptions/"+s.ID)
+	respondJSON(w, http.StatusCreated, s)
+}
+
+func (h *Subscriptions) Get(w http.ResponseWriter, r *http.Request) {
+
+	s, err := h.Store.Get(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		writeFailure(w, r, "get subscription", err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, s)
+}
+
+func (h *Subscriptions) ForCustomer(w http.ResponseWriter, r *http.Request) {
+
+	customer, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, errorDetail{
+			Code:    "invalid_customer_id",
+			Message: "customer id must be a uuid",
+			Param:   "id",
+		})
+		return
+	}
+
+	subs, err := h.Store.ForCustomer(r.Context(), customer)
+	if err != nil {
+		writeFailure(w, r, "list subscriptions", err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string][]subscription.Subscription{"items": subs})
+}
+
+func (h *Subscriptions) Pause(w http.ResponseWriter, r *http.Request) {
+	h.change(w, r, "pause", (*subscription.Subscription).Pause)
+}
+
+func (h *Subscriptions) Resume(w http.ResponseWriter, r *http.Request) {
+	h.change(w, r, "resume", (*subscription.Subscription).Resume)
+}
+
+func (h *Subscriptions) Cancel(w http.ResponseWriter, r *http.Request) {
+	h.change(w, r, "cancel", (*subscription.Subscription).Cancel)
+}
+
+func (h *Subscriptions) change(w http.ResponseWriter, r *http.Request, op string, fn func(*subscription.Subscription, time.Time) error) {
+
+	s, err := h.Store.Get(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		writeFailure(w, r, "get subscription", err)
+		return
+	}
+
+	if err := fn(&s, h.Clock.Now().UTC()); err != nil {
+		writeFailure(w, r, op+" subscription", fmt.Errorf("cannot %s %s subscription: %w", op, s.Status, err))
+		return
+	}
+
+	if err := h.Store.Save(r.Context(), s); err != nil {
+		writeFailure(w, r, "save subscription", err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, s)
+}
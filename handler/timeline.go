@@ -0,0 +1,45 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/i101dev/microservices-NN/timeline"
+)
+
+type Timeline struct {
+	Builder *timeline.Builder
+}
+
+type timelinePage struct {
+	Items []timeline.Entry `json:"items"`
+	Next  string           `json:"next,omitempty"`
+}
+
+// Customer answers with a page of everything that happened to the
+// customer's orders, oldest first.
+func (h *Timeline) Customer(w http.ResponseWriter, r *http.Request) {
+
+	customer, ok := customerParam(w, r)
+	if !ok {
+		return
+	}
+
+	limit, ok := limitParam(w, r, 50, 200)
+	if !ok {
+		return
+	}
+
+	entries, err := h.Builder.Build(r.Context(), customer)
+	if err != nil {
+		writeFailure(w, r, "build timeline", err)
+		return
+	}
+
+	items, next, err := timeline.Page(entries, r.URL.Query().Get("cursor"), limit)
+	if err != nil {
+		writeFailure(w, r, "page timeline", err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, timelinePage{Items: items, Next: next})
+}
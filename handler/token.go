@@ -0,0 +1,74 @@
+package handler
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/i101dev/microservices-NN/auth"
+	"github.com/i101dev/microservices-NN/repository/order"
+)
+
+// Tokens mints the delegated tokens support agents share with customers,
+// each letting its holder view one order for a while.
+type Tokens struct {
+	Repo   order.Repository
+	Tokens *auth.Tokens
+}
+
+type mintTokenResponse struct {
+	AccessToken string `json:"token"`
+	auth.Token
+	// URL is the order with the token in its query, ready to share.
+	URL string `json:"url"`
+}
+
+// Mint issues a token for the order in the path to the caller, an admin
+// as the route takes RequireAdmin. The token expires after ttl, capped to
+// the longest allowed; without a body it gets the default.
+func (h *Tokens) Mint(w http.ResponseWriter, r *http.Request) {
+
+	p, _ := auth.FromContext(r.Context())
+
+	orderID, ok := orderIDParam(w, r)
+	if !ok {
+		return
+	}
+
+	var body struct {
+		TTL string `json:"ttl"`
+	}
+
+	if r.ContentLength != 0 && !decodeJSON(w, r, &body) {
+		return
+	}
+
+	var ttl time.Duration
+
+	if body.TTL != "" {
+		d, err := time.ParseDuration(body.TTL)
+		if err != nil || d <= 0 {
+			writeError(w, http.StatusBadRequest, errorDetail{
+				Code:    "invalid_ttl",
+				Message: "ttl must be a positive duration such as 15m",
+				Param:   "ttl",
+			})
+			return
+		}
+		ttl = d
+	}
+
+	if _, err := h.Repo.FindByID(r.Context(), orderID); err != nil {
+		writeFailure(w, r, "find by id", err)
+		return
+	}
+
+	token, tok := h.Tokens.Mint(orderID, p.Subject, ttl)
+
+	respondJSON(w, http.StatusCreated, mintTokenResponse{
+		AccessToken: token,
+		Token:       tok,
+		URL:         "/orders/" + strconv.FormatUint(orderID, 10) + "?" + url.Values{auth.TokenParam: {token}}.Encode(),
+	})
+}
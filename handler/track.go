@@ -0,0 +1,94 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/i101dev/microservices-NN/org"
+	"github.com/i101dev/microservices-NN/repository/order"
+	"github.com/i101dev/microservices-NN/track"
+)
+
+// Track serves the public tracking links of orders, and issues them.
+type Track struct {
+	Repo    order.Repository
+	Signer  *track.Signer
+	Limiter *track.Limiter
+	Orgs    *org.Store
+}
+
+type trackingLink struct {
+	Token string `json:"token"`
+	URL   string `json:"url"`
+}
+
+// Link answers with the tracking link of the order.
+func (h *Track) Link(w http.ResponseWriter, r *http.Request) {
+
+	orderID, ok := orderIDParam(w, r)
+	if !ok {
+		return
+	}
+
+	o, err := h.Repo.FindByID(r.Context(), orderID)
+	if err != nil {
+		writeFailure(w, r, "find by id", err)
+		return
+	}
+
+	if !canView(w, r, h.Orgs, o) {
+		return
+	}
+
+	token := h.Signer.Sign(o.OrderID)
+
+	respondJSON(w, http.StatusOK, trackingLink{Token: token, URL: "/track/" + token})
+}
+
+// Status answers with where the order of the token is and when it should
+// arrive. Forged tokens and tokens of deleted orders get the same 404, so
+// the answer tells nothing about which orders exist.
+func (h *Track) Status(w http.ResponseWriter, r *http.Request) {
+
+	notFound := errorDetail{
+		Code:    "tracking_not_found",
+		Message: "no order is tracked by this link",
+	}
+
+	orderID, err := h.Signer.Verify(chi.URLParam(r, "token"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, notFound)
+		return
+	}
+
+	if h.Limiter != nil {
+		allowed, retry, err := h.Limiter.Allow(r.Context(), orderID)
+		if err != nil {
+			fmt.Println("failed to check tracking rate limit:", err)
+		}
+		if !allowed {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retry.Seconds()))))
+			writeError(w, http.StatusTooManyRequests, errorDetail{
+				Code:    "rate_limited",
+				Message: "too many lookups of this tracking link",
+			})
+			return
+		}
+	}
+
+	o, err := h.Repo.FindByID(r.Context(), orderID)
+	if errors.Is(err, order.ErrNotExist) {
+		writeError(w, http.StatusNotFound, notFound)
+		return
+	} else if err != nil {
+		writeFailure(w, r, "find by id", err)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	respondJSON(w, http.StatusOK, track.StatusOf(o))
+}
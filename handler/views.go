@@ -0,0 +1,260 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/i101dev/microservices-NN/org"
+	"github.com/i101dev/microservices-NN/readmodel"
+	"github.com/i101dev/microservices-NN/repository/order"
+)
+
+// Views serves the listings and reports kept in the read model, which
+// follows writes by a moment.
+type Views struct {
+	Store   *readmodel.Views
+	Builder *readmodel.Builder
+	Repo    order.Repository
+	// Orgs, when set, leaves the orders the caller may not see out of
+	// the listings. Summaries kept before they had an organization need
+	// a rebuild.
+	Orgs *org.Store
+}
+
+type summaryPage struct {
+	Items     []readmodel.Summary `json:"items"`
+	Next      uint64              `json:"next,omitempty"`
+	Total     *int64              `json:"total,omitempty"`
+	TotalAsOf *time.Time          `json:"total_as_of,omitempty"`
+}
+
+func customerParam(w http.ResponseWriter, r *http.Request) (uuid.UUID, bool) {
+
+	customer, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, errorDetail{
+			Code:    "invalid_customer_id",
+			Message: "customer id must be a uuid",
+			Param:   "id",
+		})
+		return uuid.UUID{}, false
+	}
+
+	return customer, true
+}
+
+func cursorParam(w http.ResponseWriter, r *http.Request) (uint64, bool) {
+
+	s := r.URL.Query().Get("cursor")
+	if s == "" {
+		return 0, true
+	}
+
+	cursor, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, errorDetail{
+			Code:    "invalid_cursor",
+			Message: "cursor must be a whole number",
+			Param:   "cursor",
+		})
+		return 0, false
+	}
+
+	return cursor, true
+}
+
+// visible drops the summaries of orders the caller may not see.
+func (h *Views) visible(w http.ResponseWriter, r *http.Request, items []readmodel.Summary) ([]readmodel.Summary, bool) {
+
+	if h.Orgs == nil {
+		return items, true
+	}
+
+	v := h.Orgs.Viewer(r.Context())
+	kept := make([]readmodel.Summary, 0, len(items))
+
+	for _, s := range items {
+		ok, err := v.Sees(s.OrderID, s.OrgID)
+		if err != nil {
+			writeFailure(w, r, "authorize orders", err)
+			return nil, false
+		}
+		if ok {
+			kept = append(kept, s)
+		}
+	}
+
+	return kept, true
+}
+
+func (h *Views) CustomerOrders(w http.ResponseWriter, r *http.Request) {
+
+	customer, ok := customerParam(w, r)
+	if !ok {
+		return
+	}
+
+	cursor, ok := cursorParam(w, r)
+	if !ok {
+		return
+	}
+
+	items, next, err := h.Store.CustomerOrders(r.Context(), customer, cursor, 50)
+	if err != nil {
+		writeFailure(w, r, "list customer orders", err)
+		return
+	}
+
+	if items, ok = h.visible(w, r, items); !ok {
+		return
+	}
+
+	page := summaryPage{Items: items, Next: next}
+
+	if r.URL.Query().Get("include_total") == "true" {
+		total, asOf, err := h.Store.Count(r.Context(), &customer, "")
+		if err != nil {
+			writeFailure(w, r, "count customer orders", err)
+			return
+		}
+		page.Total, page.TotalAsOf = &total, &asOf
+	}
+
+	respondJSON(w, http.StatusOK, page)
+}
+
+func (h *Views) CustomerSummary(w http.ResponseWriter, r *http.Request) {
+
+	customer, ok := customerParam(w, r)
+	if !ok {
+		return
+	}
+
+	s, err := h.Store.Customer(r.Context(), customer)
+	if err != nil {
+		writeFailure(w, r, "get customer summary", err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, s)
+}
+
+// Days reports the orders created on each day in [from, to], by their
+// status now. Defaults to the last 30 days.
+func (h *Views) Days(w http.ResponseWriter, r *http.Request) {
+
+	query := r.URL.Query()
+
+	to := time.Now().UTC().Truncate(24 * time.Hour)
+	from := to.AddDate(0, 0, -29)
+
+	var err error
+
+	if s := query.Get("to"); s != "" {
+		if to, err = time.Parse(time.DateOnly, s); err != nil {
+			writeInvalidDate(w, "to")
+			return
+		}
+		if query.Get("from") == "" {
+			from = to.AddDate(0, 0, -29)
+		}
+	}
+
+	if s := query.Get("from"); s != "" {
+		if from, err = time.Parse(time.DateOnly, s); err != nil {
+			writeInvalidDate(w, "from")
+			return
+		}
+	}
+
+	if to.Before(from) || to.Sub(from) >= maxAnalyticsDays*24*time.Hour {
+		writeError(w, http.StatusBadRequest, errorDetail{
+			Code:    "invalid_range",
+			Message: fmt.Sprintf("the range must cover 1 to %d days", maxAnalyticsDays),
+		})
+		return
+	}
+
+	days, err := h.Store.Days(r.Context(), from, to)
+	if err != nil {
+		writeFailure(w, r, "get day views", err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string][]readmodel.Day{"days": days})
+}
+
+func (h *Views) DayOrders(w http.ResponseWriter, r *http.Request) {
+
+	day, err := time.Parse(time.DateOnly, chi.URLParam(r, "date"))
+	if err != nil {
+		writeInvalidDate(w, "date")
+		return
+	}
+
+	cursor, ok := cursorParam(w, r)
+	if !ok {
+		return
+	}
+
+	items, next, err := h.Store.DayOrders(r.Context(), day, cursor, 50)
+	if err != nil {
+		writeFailure(w, r, "list day orders", err)
+		return
+	}
+
+	if items, ok = h.visible(w, r, items); !ok {
+		return
+	}
+
+	respondJSON(w, http.StatusOK, summaryPage{Items: items, Next: next})
+}
+
+func (h *Views) Status(w http.ResponseWriter, r *http.Request) {
+
+	s, err := h.Store.Status(r.Context())
+	if err != nil {
+		writeFailure(w, r, "get read model status", err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, s)
+}
+
+// Rebuild starts rebuilding the read model from the store, and answers
+// 202 straight away. GET /admin/views reports when it is done.
+func (h *Views) Rebuild(w http.ResponseWriter, r *http.Request) {
+
+	s, err := h.Store.Status(r.Context())
+	if err != nil {
+		writeFailure(w, r, "get read model status", err)
+		return
+	} else if s.Rebuilding {
+		writeFailure(w, r, "rebuild read model", readmodel.ErrRebuilding)
+		return
+	}
+
+	go func() {
+		n, err := h.Builder.Rebuild(context.Background(), h.Repo)
+		if err != nil {
+			fmt.Println("failed to rebuild read model:", err)
+			return
+		}
+		fmt.Printf("rebuilt read model of %d orders\n", n)
+	}()
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func writeInvalidDate(w http.ResponseWriter, param string) {
+	writeError(w, http.StatusBadRequest, errorDetail{
+		Code:    "invalid_date",
+		Message: param + " must be a YYYY-MM-DD date",
+		Param:   param,
+	})
+}
@@ -0,0 +1,149 @@
+package handler
+
+import (
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/i101dev/microservices-NN/fulfillment"
+	"github.com/i101dev/microservices-NN/model"
+	"github.com/i101dev/microservices-NN/orderindex"
+	"github.com/i101dev/microservices-NN/org"
+	"github.com/i101dev/microservices-NN/service"
+)
+
+// Warehouses lists the warehouses orders are sent from, the orders
+// assigned to each and what they have to pick for them.
+type Warehouses struct {
+	Orders *service.Orders
+	Config *fulfillment.Config
+	Orgs   *org.Store
+}
+
+func (h *Warehouses) List(w http.ResponseWriter, r *http.Request) {
+
+	warehouses := h.Config.Warehouses
+	if warehouses == nil {
+		warehouses = []fulfillment.Warehouse{}
+	}
+
+	respondJSON(w, http.StatusOK, map[string]any{"warehouses": warehouses})
+}
+
+// ListOrders lists the orders assigned to the warehouse in the path,
+// oldest first, only the ones in status when it is set.
+func (h *Warehouses) ListOrders(w http.ResponseWriter, r *http.Request) {
+
+	id := chi.URLParam(r, "id")
+
+	if _, ok := h.Config.Warehouse(id); !ok {
+		writeFailure(w, r, "find warehouse", fulfillment.ErrUnknownWarehouse)
+		return
+	}
+
+	cursor, ok := cursorParam(w, r)
+	if !ok {
+		return
+	}
+
+	f := orderindex.Filter{Warehouse: id, Status: r.URL.Query().Get("status")}
+
+	page, err := h.Orders.Filter(r.Context(), f, cursor, 50)
+	if err != nil {
+		writeFailure(w, r, "list warehouse orders", err)
+		return
+	}
+
+	if page.Items, ok = visible(w, r, h.Orgs, page.Items); !ok {
+		return
+	}
+
+	respond(w, r, http.StatusOK, page)
+}
+
+// PickList serves what the warehouse in the query has to pick for its
+// pending orders, placed on the date in the query when it is set, as
+// JSON, CSV or a PDF with a packing slip per order.
+func (h *Warehouses) PickList(w http.ResponseWriter, r *http.Request) {
+
+	query := r.URL.Query()
+	id := query.Get("warehouse")
+
+	if id == "" {
+		writeError(w, http.StatusBadRequest, errorDetail{
+			Code:    "missing_warehouse",
+			Message: "warehouse is required",
+			Param:   "warehouse",
+		})
+		return
+	}
+
+	if _, ok := h.Config.Warehouse(id); !ok {
+		writeFailure(w, r, "find warehouse", fulfillment.ErrUnknownWarehouse)
+		return
+	}
+
+	var day *time.Time
+	if s := query.Get("date"); s != "" {
+		t, err := time.Parse(time.DateOnly, s)
+		if err != nil {
+			writeInvalidDate(w, "date")
+			return
+		}
+		day = &t
+	}
+
+	format := query.Get("format")
+	if format != "" && format != "json" && format != "csv" && format != "pdf" {
+		writeError(w, http.StatusBadRequest, errorDetail{
+			Code:    "invalid_format",
+			Message: "format must be json, csv or pdf",
+			Param:   "format",
+		})
+		return
+	}
+
+	var keep func([]model.Order) ([]model.Order, error)
+	if h.Orgs != nil {
+		keep = func(orders []model.Order) ([]model.Order, error) {
+			return h.Orgs.Visible(r.Context(), orders)
+		}
+	}
+
+	p, err := h.Orders.PickList(r.Context(), id, day, keep)
+	if err != nil {
+		writeFailure(w, r, "build pick list", err)
+		return
+	}
+
+	name := "picklist-" + id
+	if p.Date != "" {
+		name += "-" + p.Date
+	}
+
+	switch format {
+	case "", "json":
+		respondJSON(w, http.StatusOK, p)
+	case "csv":
+		w.Header().Set("Content-Type", "text/csv")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name+".csv"))
+		cw := csv.NewWriter(w)
+		if err := cw.WriteAll(p.Rows()); err != nil {
+			fmt.Println("failed to write pick list:", err)
+		}
+	case "pdf":
+		data, err := fulfillment.RenderPickList(p)
+		if err != nil {
+			writeFailure(w, r, "render pick list", err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/pdf")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("inline; filename=%q", name+".pdf"))
+		w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+		w.WriteHeader(http.StatusOK)
+		w.Write(data)
+	}
+}
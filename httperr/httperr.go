@@ -0,0 +1,23 @@
+// Package httperr answers requests with the JSON error body of the API,
+// for middlewares that run before package handler and cannot use its
+// helpers.
+package httperr
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// Write answers with status and {"error": {"code", "message"}}. Headers
+// set on w before it are sent along.
+func Write(w http.ResponseWriter, status int, code, message string) {
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]any{
+		"error": map[string]string{
+			"code":    code,
+			"message": message,
+		},
+	})
+}
@@ -0,0 +1,304 @@
+package intake
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/i101dev/microservices-NN/auth"
+	"github.com/i101dev/microservices-NN/model"
+	"github.com/i101dev/microservices-NN/repository/order"
+	"github.com/i101dev/microservices-NN/tenant"
+	"github.com/i101dev/microservices-NN/tracecontext"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	stream = "orders:create"
+	group  = "order-writers"
+	// expeditedStream holds the creates of expedited orders, which workers
+	// take before any on stream.
+	expeditedStream = "orders:create:expedited"
+)
+
+// streams are read in order of priority.
+var streams = []string{expeditedStream, stream}
+
+func streamFor(o model.Order) string {
+	if o.Expedited() {
+		return expeditedStream
+	}
+	return stream
+}
+
+var ErrNotExist = errors.New("create request does not exist")
+
+type Status string
+
+const (
+	StatusPending   Status = "pending"
+	StatusCompleted Status = "completed"
+	StatusFailed    Status = "failed"
+)
+
+type Request struct {
+	RequestID string `json:"request_id"`
+	Status    Status `json:"status"`
+	OrderID   uint64 `json:"order_id"`
+	Error     string `json:"error,omitempty"`
+}
+
+// Queue accepts order creates onto Redis streams and writes them to Repo
+// from a pool of consumer-group workers, expedited orders first.
+type Queue struct {
+	Client    *redis.Client
+	Repo      order.Repository
+	Workers   int
+	Retention time.Duration
+	// ClaimIdle is how long a delivered entry may go unacknowledged before
+	// another worker takes it over from a consumer that died.
+	ClaimIdle time.Duration
+	// Paused, when set and true, stops workers from taking new creates.
+	Paused func() bool
+}
+
+func requestKey(id string) string {
+	return "create_request:" + id
+}
+
+func (q *Queue) Enqueue(ctx context.Context, o model.Order) (Request, error) {
+
+	data, err := json.Marshal(o)
+	if err != nil {
+		return Request{}, fmt.Errorf("failed to encode order: %w", err)
+	}
+
+	req := Request{
+		RequestID: uuid.NewString(),
+		Status:    StatusPending,
+		OrderID:   o.OrderID,
+	}
+
+	values := map[string]any{
+		"request_id": req.RequestID,
+		"order":      data,
+		"tenant":     string(tenant.FromContext(ctx)),
+	}
+
+	if p, ok := auth.FromContext(ctx); ok {
+		principal, err := json.Marshal(p)
+		if err != nil {
+			return Request{}, fmt.Errorf("failed to encode principal: %w", err)
+		}
+		values["principal"] = principal
+	}
+
+	for k, v := range tracecontext.Values(ctx) {
+		values[k] = v
+	}
+
+	key := requestKey(req.RequestID)
+
+	txn := q.Client.TxPipeline()
+
+	txn.HSet(ctx, key, "status", string(req.Status), "order_id", req.OrderID)
+	txn.Expire(ctx, key, q.Retention)
+	txn.XAdd(ctx, &redis.XAddArgs{
+		Stream: streamFor(o),
+		Values: values,
+	})
+
+	if _, err := txn.Exec(ctx); err != nil {
+		return Request{}, fmt.Errorf("failed to enqueue create: %w", err)
+	}
+
+	return req, nil
+}
+
+func (q *Queue) Status(ctx context.Context, id string) (Request, error) {
+
+	fields, err := q.Client.HGetAll(ctx, requestKey(id)).Result()
+	if err != nil {
+		return Request{}, fmt.Errorf("failed to get create request: %w", err)
+	}
+
+	if len(fields) == 0 {
+		return Request{}, ErrNotExist
+	}
+
+	orderID, err := strconv.ParseUint(fields["order_id"], 10, 64)
+	if err != nil {
+		return Request{}, fmt.Errorf("failed to parse order id: %w", err)
+	}
+
+	return Request{
+		RequestID: id,
+		Status:    Status(fields["status"]),
+		OrderID:   orderID,
+		Error:     fields["error"],
+	}, nil
+}
+
+// Run consumes the stream until ctx is cancelled.
+func (q *Queue) Run(ctx context.Context) error {
+
+	for _, s := range streams {
+		err := q.Client.XGroupCreateMkStream(ctx, s, group, "0").Err()
+		if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+			return fmt.Errorf("failed to create consumer group: %w", err)
+		}
+	}
+
+	done := make(chan struct{})
+
+	for i := 0; i < q.Workers; i++ {
+		go func() {
+			q.work(ctx, uuid.NewString())
+			done <- struct{}{}
+		}()
+	}
+
+	for i := 0; i < q.Workers; i++ {
+		<-done
+	}
+
+	return nil
+}
+
+func (q *Queue) work(ctx context.Context, consumer string) {
+
+	for ctx.Err() == nil {
+
+		if q.Paused != nil && q.Paused() {
+			select {
+			case <-ctx.Done():
+			case <-time.After(time.Second):
+			}
+			continue
+		}
+
+		for _, s := range streams {
+			claimed, _, err := q.Client.XAutoClaim(ctx, &redis.XAutoClaimArgs{
+				Stream:   s,
+				Group:    group,
+				Consumer: consumer,
+				MinIdle:  q.ClaimIdle,
+				Start:    "0-0",
+				Count:    10,
+			}).Result()
+
+			if err != nil && ctx.Err() == nil {
+				fmt.Println("failed to claim create requests:", err)
+			}
+
+			for _, msg := range claimed {
+				q.process(ctx, s, msg)
+			}
+		}
+
+		// Expedited creates are looked for without waiting first, so a
+		// backlog of normal ones never holds them up. Only when there are
+		// none does the worker wait on both streams.
+		read, err := q.read(ctx, consumer, []string{expeditedStream}, -1)
+		if errors.Is(err, redis.Nil) {
+			read, err = q.read(ctx, consumer, streams, 2*time.Second)
+		}
+
+		if errors.Is(err, redis.Nil) {
+			continue
+		} else if err != nil {
+			if ctx.Err() == nil {
+				fmt.Println("failed to read create requests:", err)
+				time.Sleep(time.Second)
+			}
+			continue
+		}
+
+		for _, s := range read {
+			for _, msg := range s.Messages {
+				q.process(ctx, s.Stream, msg)
+			}
+		}
+	}
+}
+
+// read takes new entries from the streams, in the order given, waiting up
+// to block for some to arrive. A negative block does not wait.
+func (q *Queue) read(ctx context.Context, consumer string, keys []string, block time.Duration) ([]redis.XStream, error) {
+
+	args := make([]string, 0, 2*len(keys))
+	args = append(args, keys...)
+	for range keys {
+		args = append(args, ">")
+	}
+
+	return q.Client.XReadGroup(ctx, &redis.XReadGroupArgs{
+		Group:    group,
+		Consumer: consumer,
+		Streams:  args,
+		Count:    10,
+		Block:    block,
+	}).Result()
+}
+
+// requestContext restores the tenant, principal and trace of the request
+// that enqueued msg, so the insert runs as that caller.
+func requestContext(ctx context.Context, msg redis.XMessage) context.Context {
+
+	if id, _ := msg.Values["tenant"].(string); id != "" {
+		ctx = tenant.NewContext(ctx, tenant.ID(id))
+	}
+
+	if data, _ := msg.Values["principal"].(string); data != "" {
+		var p auth.Principal
+		if err := json.Unmarshal([]byte(data), &p); err == nil {
+			ctx = auth.NewContext(ctx, p)
+		}
+	}
+
+	traceparent, _ := msg.Values[tracecontext.ParentHeader].(string)
+	state, _ := msg.Values[tracecontext.StateHeader].(string)
+
+	return tracecontext.Resume(ctx, traceparent, state)
+}
+
+func (q *Queue) process(ctx context.Context, key string, msg redis.XMessage) {
+
+	id, _ := msg.Values["request_id"].(string)
+	data, _ := msg.Values["order"].(string)
+
+	ctx = requestContext(ctx, msg)
+
+	status, reason := StatusCompleted, ""
+
+	var o model.Order
+	if err := json.Unmarshal([]byte(data), &o); err != nil {
+		status, reason = StatusFailed, "malformed order"
+	} else if err := q.Repo.Insert(ctx, o); err != nil && !errors.Is(err, order.ErrExist) {
+		// A redelivered entry whose insert already landed is not a failure.
+		if ctx.Err() != nil {
+			return
+		}
+		status, reason = StatusFailed, err.Error()
+	}
+
+	// Acknowledged entries are deleted so the stream only holds the backlog.
+	pipe := q.Client.TxPipeline()
+
+	if id != "" {
+		pipe.HSet(ctx, requestKey(id), "status", string(status), "error", reason)
+		pipe.Expire(ctx, requestKey(id), q.Retention)
+	}
+
+	pipe.XAck(ctx, key, group, msg.ID)
+	pipe.XDel(ctx, key, msg.ID)
+
+	if _, err := pipe.Exec(ctx); err != nil {
+		fmt.Println("failed to settle create request:", err)
+	}
+}
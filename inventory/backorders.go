@@ -0,0 +1,106 @@
+package inventory
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/google/uuid"
+	"github.com/i101dev/microservices-NN/model"
+	"github.com/i101dev/microservices-NN/repository/order"
+	"github.com/redis/go-redis/v9"
+)
+
+// Backorders indexes backordered orders by the items they wait for, in
+// one sorted set per item scored by when the order started waiting.
+type Backorders struct {
+	Client *redis.Client
+}
+
+func backordersKey(itemID uuid.UUID) string {
+	return "backorders:" + itemID.String()
+}
+
+// Waiting returns the orders waiting for an item, oldest first.
+func (b *Backorders) Waiting(ctx context.Context, itemID uuid.UUID) ([]uint64, error) {
+
+	members, err := b.Client.ZRange(ctx, backordersKey(itemID), 0, -1).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list backorders: %w", err)
+	}
+
+	ids := make([]uint64, 0, len(members))
+
+	for _, m := range members {
+		id, err := strconv.ParseUint(m, 10, 64)
+		if err != nil {
+			continue
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, nil
+}
+
+// Put adds a backordered order under each of its items, and takes it off
+// them again once it is fulfilled or cancelled.
+func (b *Backorders) Put(ctx context.Context, o model.Order) error {
+
+	pipe := b.Client.Pipeline()
+
+	for _, item := range o.LineItems {
+		if o.Status() == model.StatusBackordered {
+			pipe.ZAdd(ctx, backordersKey(item.ItemID), redis.Z{Score: float64(o.Backorder.Since.UnixMilli()), Member: o.OrderID})
+		} else if o.Backorder != nil {
+			pipe.ZRem(ctx, backordersKey(item.ItemID), o.OrderID)
+		}
+	}
+
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to index backorder: %w", err)
+	}
+
+	return nil
+}
+
+// Forget takes an order off an item, for entries left behind by orders
+// that were deleted.
+func (b *Backorders) Forget(ctx context.Context, itemID uuid.UUID, id uint64) error {
+
+	if err := b.Client.ZRem(ctx, backordersKey(itemID), id).Err(); err != nil {
+		return fmt.Errorf("failed to drop backorder: %w", err)
+	}
+
+	return nil
+}
+
+// Intercept keeps the index up to date as orders are written. Failing to
+// update it is logged but never fails the write.
+func (b *Backorders) Intercept(ctx context.Context, call *order.Call, next order.Handler) error {
+
+	if err := next(ctx, call); err != nil {
+		return err
+	}
+
+	var orders []model.Order
+
+	switch call.Op {
+	case order.OpInsert, order.OpUpdate:
+		orders = []model.Order{call.Order}
+	case order.OpInsertAll:
+		orders = call.Orders
+	case order.OpApply:
+		orders = append(append(orders, call.Batch.Insert...), call.Batch.Update...)
+	}
+
+	for _, o := range orders {
+		if o.Backorder == nil {
+			continue
+		}
+		if err := b.Put(ctx, o); err != nil {
+			fmt.Println("failed to index backorder:", err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,127 @@
+// Package inventory reserves stock for new orders, keeps track of the
+// orders waiting on items that were out of stock, and listens for the
+// inventory service announcing a restock.
+package inventory
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/google/uuid"
+	"github.com/i101dev/microservices-NN/model"
+)
+
+// Reserver holds stock for an order. Reserve returns what it could hold,
+// which may be less than asked for or nothing at all, and keeps it held
+// until Release.
+type Reserver interface {
+	Reserve(ctx context.Context, orderID uint64, items []model.LineItem) ([]model.LineItem, error)
+	Release(ctx context.Context, orderID uint64) error
+}
+
+// Shortfall splits items into the parts that reserved covers and the
+// parts it does not. Reserved quantities are matched to items in order,
+// so an item listed twice is filled from the top.
+func Shortfall(items, reserved []model.LineItem) (fulfilled, short []model.LineItem) {
+
+	held := map[uuid.UUID]uint{}
+	for _, r := range reserved {
+		held[r.ItemID] += r.Quantity
+	}
+
+	for _, item := range items {
+		n := min(item.Quantity, held[item.ItemID])
+		held[item.ItemID] -= n
+
+		if n > 0 {
+			line := item
+			line.Quantity = n
+			fulfilled = append(fulfilled, line)
+		}
+
+		if n < item.Quantity {
+			line := item
+			line.Quantity = item.Quantity - n
+			short = append(short, line)
+		}
+	}
+
+	return fulfilled, short
+}
+
+// HTTPReserver posts {"order_id", "line_items"} as JSON to URL+"/reservations"
+// and expects {"reserved": [...]} back. Release deletes
+// URL+"/reservations/<order id>".
+type HTTPReserver struct {
+	URL    string
+	Client *http.Client
+}
+
+func (c *HTTPReserver) httpClient() *http.Client {
+	if c.Client == nil {
+		return http.DefaultClient
+	}
+	return c.Client
+}
+
+func (c *HTTPReserver) Reserve(ctx context.Context, orderID uint64, items []model.LineItem) ([]model.LineItem, error) {
+
+	data, err := json.Marshal(map[string]any{"order_id": orderID, "line_items": items})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode reservation: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.URL+"/reservations", bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to build request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := c.httpClient().Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to call inventory: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(res.Body, 512))
+		return nil, fmt.Errorf("inventory returned %d: %s", res.StatusCode, bytes.TrimSpace(body))
+	}
+
+	var body struct {
+		Reserved []model.LineItem `json:"reserved"`
+	}
+
+	if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("failed to decode reservation: %w", err)
+	}
+
+	return body.Reserved, nil
+}
+
+func (c *HTTPReserver) Release(ctx context.Context, orderID uint64) error {
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, c.URL+"/reservations/"+strconv.FormatUint(orderID, 10), nil)
+	if err != nil {
+		return fmt.Errorf("failed to build request: %w", err)
+	}
+
+	res, err := c.httpClient().Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to call inventory: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK && res.StatusCode != http.StatusNoContent && res.StatusCode != http.StatusNotFound {
+		body, _ := io.ReadAll(io.LimitReader(res.Body, 512))
+		return fmt.Errorf("inventory returned %d: %s", res.StatusCode, bytes.TrimSpace(body))
+	}
+
+	return nil
+}
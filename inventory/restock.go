@@ -0,0 +1,131 @@
+package inventory
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	DefaultStream = "events:inventory"
+	group         = "order-backorders"
+
+	TypeRestocked = "inventory.restocked"
+)
+
+// Restocked is the payload of an inventory.restocked event.
+type Restocked struct {
+	ItemID   uuid.UUID `json:"item_id"`
+	Quantity uint      `json:"quantity"`
+}
+
+// Listener reads the inventory service's event stream, entries with a
+// type and a JSON payload like the ones events.Publisher writes, in its
+// own consumer group, and calls Handle for every restock. Other events are
+// acknowledged and skipped. A restock Handle fails on is left pending and
+// retried once it has been idle for Retry.
+type Listener struct {
+	Client *redis.Client
+	Stream string
+	Retry  time.Duration
+	Handle func(ctx context.Context, r Restocked) error
+	// Paused, when set and true, stops the listener taking new events.
+	Paused func() bool
+}
+
+func (l *Listener) stream() string {
+	if l.Stream == "" {
+		return DefaultStream
+	}
+	return l.Stream
+}
+
+// Run consumes the stream until ctx is cancelled.
+func (l *Listener) Run(ctx context.Context) error {
+
+	err := l.Client.XGroupCreateMkStream(ctx, l.stream(), group, "$").Err()
+	if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return fmt.Errorf("failed to create consumer group: %w", err)
+	}
+
+	consumer := uuid.NewString()
+
+	for ctx.Err() == nil {
+
+		if l.Paused != nil && l.Paused() {
+			select {
+			case <-ctx.Done():
+			case <-time.After(time.Second):
+			}
+			continue
+		}
+
+		claimed, _, err := l.Client.XAutoClaim(ctx, &redis.XAutoClaimArgs{
+			Stream:   l.stream(),
+			Group:    group,
+			Consumer: consumer,
+			MinIdle:  l.Retry,
+			Start:    "0-0",
+			Count:    10,
+		}).Result()
+
+		if err != nil && ctx.Err() == nil {
+			fmt.Println("failed to claim inventory events:", err)
+		}
+
+		for _, msg := range claimed {
+			l.process(ctx, msg)
+		}
+
+		streams, err := l.Client.XReadGroup(ctx, &redis.XReadGroupArgs{
+			Group:    group,
+			Consumer: consumer,
+			Streams:  []string{l.stream(), ">"},
+			Count:    10,
+			Block:    2 * time.Second,
+		}).Result()
+
+		if errors.Is(err, redis.Nil) {
+			continue
+		} else if err != nil {
+			if ctx.Err() == nil {
+				fmt.Println("failed to read inventory events:", err)
+				time.Sleep(time.Second)
+			}
+			continue
+		}
+
+		for _, s := range streams {
+			for _, msg := range s.Messages {
+				l.process(ctx, msg)
+			}
+		}
+	}
+
+	return nil
+}
+
+func (l *Listener) process(ctx context.Context, msg redis.XMessage) {
+
+	if eventType, _ := msg.Values["type"].(string); eventType == TypeRestocked {
+		payload, _ := msg.Values["payload"].(string)
+
+		var r Restocked
+		if err := json.Unmarshal([]byte(payload), &r); err != nil {
+			fmt.Printf("skipping malformed restock %s: %v\n", msg.ID, err)
+		} else if err := l.Handle(ctx, r); err != nil {
+			fmt.Printf("failed to handle restock %s, retrying later: %v\n", msg.ID, err)
+			return
+		}
+	}
+
+	if err := l.Client.XAck(ctx, l.stream(), group, msg.ID).Err(); err != nil {
+		fmt.Println("failed to acknowledge inventory event:", err)
+	}
+}
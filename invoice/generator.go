@@ -0,0 +1,135 @@
+package invoice
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"time"
+
+	"github.com/i101dev/microservices-NN/jobs"
+	"github.com/i101dev/microservices-NN/model"
+	"github.com/i101dev/microservices-NN/repository/order"
+	"github.com/redis/go-redis/v9"
+)
+
+const jobType = "invoice-render"
+
+// ErrPending is returned while an invoice is being rendered in the
+// background.
+var ErrPending = errors.New("invoice is being generated")
+
+// Generator renders invoices and keeps them in Redis for TTL, keyed by the
+// order's last update so a changed order gets a fresh one. Orders with at
+// least AsyncItems line items are rendered by a job instead of during the
+// request; AsyncItems zero, or no Runner, renders everything in place.
+type Generator struct {
+	Client     *redis.Client
+	Repo       order.Repository
+	Runner     *jobs.Runner
+	Layout     Layout
+	TTL        time.Duration
+	AsyncItems int
+}
+
+type renderPayload struct {
+	OrderID uint64 `json:"order_id"`
+}
+
+// Register adds the render job to the runner.
+func (g *Generator) Register() {
+	g.Runner.Register(jobType, g.run)
+}
+
+// PDF returns the invoice of the order, from the cache when it is there.
+// For an order big enough to render in the background it queues the job,
+// once, and returns ErrPending until the job has stored the result.
+func (g *Generator) PDF(ctx context.Context, o model.Order) ([]byte, error) {
+
+	key := g.key(o)
+
+	data, err := g.Client.Get(ctx, key).Bytes()
+	if err == nil {
+		return data, nil
+	}
+	if err != redis.Nil {
+		return nil, fmt.Errorf("failed to read cached invoice: %w", err)
+	}
+
+	if g.Runner != nil && g.AsyncItems > 0 && len(o.LineItems) >= g.AsyncItems {
+		return nil, g.enqueue(ctx, o, key)
+	}
+
+	return g.render(ctx, o, key)
+}
+
+func (g *Generator) enqueue(ctx context.Context, o model.Order, key string) error {
+
+	queued, err := g.Client.SetNX(ctx, key+":pending", 1, 10*time.Minute).Result()
+	if err != nil {
+		return fmt.Errorf("failed to mark invoice pending: %w", err)
+	}
+
+	if queued {
+		if _, err := g.Runner.Enqueue(ctx, jobType, renderPayload{OrderID: o.OrderID}); err != nil {
+			g.Client.Del(ctx, key+":pending")
+			return fmt.Errorf("failed to queue invoice: %w", err)
+		}
+	}
+
+	return ErrPending
+}
+
+func (g *Generator) render(ctx context.Context, o model.Order, key string) ([]byte, error) {
+
+	data, err := Render(New(o, g.Layout.TaxRate), g.Layout)
+	if err != nil {
+		return nil, err
+	}
+
+	txn := g.Client.TxPipeline()
+	txn.Set(ctx, key, data, g.TTL)
+	txn.Del(ctx, key+":pending")
+
+	if _, err := txn.Exec(ctx); err != nil {
+		fmt.Println("failed to cache invoice:", err)
+	}
+
+	return data, nil
+}
+
+func (g *Generator) run(ctx context.Context, job jobs.Job) error {
+
+	var payload renderPayload
+	if err := job.Decode(&payload); err != nil {
+		return jobs.Permanent(fmt.Errorf("failed to decode invoice job: %w", err))
+	}
+
+	o, err := g.Repo.FindByID(ctx, payload.OrderID)
+	if errors.Is(err, order.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	_, err = g.render(ctx, o, g.key(o))
+
+	return err
+}
+
+// key names the cached invoice of one version of an order under the
+// current layout.
+func (g *Generator) key(o model.Order) string {
+
+	var version int64
+	if o.UpdatedAt != nil {
+		version = o.UpdatedAt.UnixNano()
+	}
+
+	h := fnv.New32a()
+	fmt.Fprint(h, g.Layout)
+
+	return "invoice:" + strconv.FormatUint(o.OrderID, 10) + ":" + strconv.FormatInt(version, 36) + ":" + strconv.FormatUint(uint64(h.Sum32()), 36)
+}
@@ -0,0 +1,230 @@
+package invoice
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"text/template"
+	"time"
+
+	"github.com/go-pdf/fpdf"
+	"github.com/google/uuid"
+	"github.com/i101dev/microservices-NN/model"
+)
+
+// Layout is what every invoice shares. Notes is a text/template executed
+// with the Invoice, for payment terms and the like.
+type Layout struct {
+	Seller   []string
+	Notes    string
+	Currency string
+	// TaxRate is a fraction of the subtotal, 0.2 for 20%.
+	TaxRate float64
+}
+
+type Invoice struct {
+	Number     string
+	OrderID    uint64
+	CustomerID uuid.UUID
+	Status     string
+	IssuedAt   time.Time
+	Lines      []Line
+	Subtotal   uint
+	TaxRate    float64
+	Tax        uint
+	// Taxes is the order's own tax by jurisdiction, when it has one. It
+	// takes the place of TaxRate.
+	Taxes []JurisdictionTax
+	Total uint
+}
+
+type JurisdictionTax struct {
+	Jurisdiction string
+	Amount       uint
+}
+
+type Line struct {
+	ItemID    uuid.UUID
+	Quantity  uint
+	UnitPrice uint
+	Amount    uint
+}
+
+// New works out the invoice of an order. Prices are in minor units, and
+// tax is rounded half away from zero. Orders that were taxed when placed
+// are invoiced with that tax instead of taxRate.
+func New(o model.Order, taxRate float64) Invoice {
+
+	inv := Invoice{
+		Number:     fmt.Sprintf("INV-%d", o.OrderID),
+		OrderID:    o.OrderID,
+		CustomerID: o.CustomerID,
+		Status:     o.Status(),
+		TaxRate:    taxRate,
+		Lines:      make([]Line, len(o.LineItems)),
+	}
+
+	if o.CreatedAt != nil {
+		inv.IssuedAt = o.CreatedAt.UTC()
+	}
+
+	for i, item := range o.LineItems {
+		inv.Lines[i] = Line{
+			ItemID:    item.ItemID,
+			Quantity:  item.Quantity,
+			UnitPrice: item.Price,
+			Amount:    item.Quantity * item.Price,
+		}
+		inv.Subtotal += inv.Lines[i].Amount
+	}
+
+	if o.Tax != nil {
+		inv.TaxRate = 0
+		inv.Tax = o.Tax.Total
+
+		for _, line := range o.Tax.Lines {
+			i := slices.IndexFunc(inv.Taxes, func(t JurisdictionTax) bool { return t.Jurisdiction == line.Jurisdiction })
+			if i < 0 {
+				inv.Taxes = append(inv.Taxes, JurisdictionTax{Jurisdiction: line.Jurisdiction})
+				i = len(inv.Taxes) - 1
+			}
+			inv.Taxes[i].Amount += line.Amount
+		}
+	} else {
+		inv.Tax = uint(math.Round(float64(inv.Subtotal) * taxRate))
+	}
+
+	inv.Total = inv.Subtotal + inv.Tax
+
+	return inv
+}
+
+// Render lays the invoice out as an A4 PDF. The output only depends on
+// the invoice and layout, so it can be cached.
+func Render(inv Invoice, layout Layout) ([]byte, error) {
+
+	var notes bytes.Buffer
+
+	if layout.Notes != "" {
+		t, err := template.New("notes").Parse(layout.Notes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse invoice notes: %w", err)
+		}
+		if err := t.Execute(&notes, inv); err != nil {
+			return nil, fmt.Errorf("failed to execute invoice notes: %w", err)
+		}
+	}
+
+	money := func(v uint) string {
+		return fmt.Sprintf("%s%d.%02d", layout.Currency, v/100, v%100)
+	}
+
+	pdf := fpdf.New("P", "mm", "A4", "")
+	pdf.SetCatalogSort(true)
+	pdf.SetTitle(inv.Number, false)
+	pdf.SetCreationDate(inv.IssuedAt)
+	pdf.SetModificationDate(inv.IssuedAt)
+	pdf.SetAutoPageBreak(true, 20)
+	pdf.AliasNbPages("")
+
+	pdf.SetFooterFunc(func() {
+		pdf.SetY(-15)
+		pdf.SetFont("Helvetica", "", 8)
+		pdf.CellFormat(0, 10, fmt.Sprintf("%s - page %d of {nb}", inv.Number, pdf.PageNo()), "", 0, "C", false, 0, "")
+	})
+
+	// Column widths: item, quantity, unit price, amount.
+	widths := []float64{100, 20, 35, 35}
+
+	tableHeader := func() {
+		pdf.SetFont("Helvetica", "B", 10)
+		for i, title := range []string{"Item", "Qty", "Unit price", "Amount"} {
+			align := "R"
+			if i == 0 {
+				align = "L"
+			}
+			pdf.CellFormat(widths[i], 8, title, "B", 0, align, false, 0, "")
+		}
+		pdf.Ln(-1)
+		pdf.SetFont("Helvetica", "", 10)
+	}
+
+	pdf.AddPage()
+
+	pdf.SetFont("Helvetica", "B", 20)
+	pdf.CellFormat(0, 12, "Invoice", "", 1, "R", false, 0, "")
+
+	pdf.SetFont("Helvetica", "", 10)
+	for _, line := range layout.Seller {
+		pdf.CellFormat(0, 5, line, "", 1, "L", false, 0, "")
+	}
+	pdf.Ln(6)
+
+	for _, field := range [][2]string{
+		{"Invoice", inv.Number},
+		{"Order", fmt.Sprint(inv.OrderID)},
+		{"Customer", inv.CustomerID.String()},
+		{"Date", inv.IssuedAt.Format("2 January 2006")},
+		{"Status", inv.Status},
+	} {
+		pdf.SetFont("Helvetica", "B", 10)
+		pdf.CellFormat(30, 6, field[0], "", 0, "L", false, 0, "")
+		pdf.SetFont("Helvetica", "", 10)
+		pdf.CellFormat(0, 6, field[1], "", 1, "L", false, 0, "")
+	}
+	pdf.Ln(6)
+
+	tableHeader()
+
+	_, pageHeight := pdf.GetPageSize()
+
+	for _, line := range inv.Lines {
+		if pdf.GetY() > pageHeight-30 {
+			pdf.AddPage()
+			tableHeader()
+		}
+		pdf.CellFormat(widths[0], 7, line.ItemID.String(), "", 0, "L", false, 0, "")
+		pdf.CellFormat(widths[1], 7, fmt.Sprint(line.Quantity), "", 0, "R", false, 0, "")
+		pdf.CellFormat(widths[2], 7, money(line.UnitPrice), "", 0, "R", false, 0, "")
+		pdf.CellFormat(widths[3], 7, money(line.Amount), "", 1, "R", false, 0, "")
+	}
+
+	totals := [][2]string{{"Subtotal", money(inv.Subtotal)}}
+	if inv.TaxRate > 0 {
+		rate := strconv.FormatFloat(math.Round(inv.TaxRate*10000)/100, 'f', -1, 64)
+		totals = append(totals, [2]string{"Tax (" + rate + "%)", money(inv.Tax)})
+	}
+	for _, t := range inv.Taxes {
+		totals = append(totals, [2]string{"Tax (" + t.Jurisdiction + ")", money(t.Amount)})
+	}
+	totals = append(totals, [2]string{"Total", money(inv.Total)})
+
+	label := widths[0] + widths[1] + widths[2]
+
+	pdf.Ln(2)
+	for i, row := range totals {
+		style, border := "", ""
+		if i == len(totals)-1 {
+			style, border = "B", "T"
+		}
+		pdf.SetFont("Helvetica", style, 10)
+		pdf.CellFormat(label, 7, row[0], border, 0, "R", false, 0, "")
+		pdf.CellFormat(widths[3], 7, row[1], border, 1, "R", false, 0, "")
+	}
+
+	if notes.Len() > 0 {
+		pdf.Ln(8)
+		pdf.SetFont("Helvetica", "", 9)
+		pdf.MultiCell(0, 5, notes.String(), "", "L", false)
+	}
+
+	var out bytes.Buffer
+
+	if err := pdf.Output(&out); err != nil {
+		return nil, fmt.Errorf("failed to render invoice: %w", err)
+	}
+
+	return out.Bytes(), nil
+}
@@ -0,0 +1,161 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/redis/go-redis/v9"
+)
+
+var ErrNotDead = errors.New("job is not in the dead-letter list")
+
+// requeueScript only re-adds the job if this caller was the one to remove
+// it, so concurrent requeues of the same entry run it once.
+var requeueScript = redis.NewScript(`
+if redis.call('LREM', KEYS[1], 1, ARGV[1]) == 1 then
+	redis.call('XADD', KEYS[2], '*', 'job', ARGV[2])
+	return 1
+end
+return 0
+`)
+
+type deadEntry struct {
+	raw string
+	job Job
+}
+
+func (r *Runner) deadEntries(ctx context.Context) ([]deadEntry, error) {
+
+	values, err := r.Client.LRange(ctx, r.deadKey(), 0, -1).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read dead-letter list: %w", err)
+	}
+
+	entries := make([]deadEntry, 0, len(values))
+
+	for _, v := range values {
+		var job Job
+		if err := json.Unmarshal([]byte(v), &job); err != nil {
+			job = Job{LastError: "undecodable entry: " + err.Error()}
+		}
+		entries = append(entries, deadEntry{raw: v, job: job})
+	}
+
+	return entries, nil
+}
+
+// Dead lists dead-lettered jobs, newest first, optionally only those of
+// one type.
+func (r *Runner) Dead(ctx context.Context, jobType string, offset int, limit int) ([]Job, int, error) {
+
+	entries, err := r.deadEntries(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var matched []Job
+	for _, e := range entries {
+		if jobType == "" || e.job.Type == jobType {
+			matched = append(matched, e.job)
+		}
+	}
+
+	total := len(matched)
+
+	if offset >= total {
+		return []Job{}, total, nil
+	}
+
+	end := min(offset+limit, total)
+
+	return matched[offset:end], total, nil
+}
+
+func (r *Runner) DeadJob(ctx context.Context, id string) (Job, error) {
+
+	entries, err := r.deadEntries(ctx)
+	if err != nil {
+		return Job{}, err
+	}
+
+	for _, e := range entries {
+		if e.job.ID == id {
+			return e.job, nil
+		}
+	}
+
+	return Job{}, ErrNotDead
+}
+
+// Requeue puts matching dead jobs back on the stream with their attempts
+// reset. An empty id matches every job, narrowed by jobType if set.
+func (r *Runner) Requeue(ctx context.Context, id string, jobType string) (int, error) {
+
+	return r.settleDead(ctx, id, jobType, func(e deadEntry) (bool, error) {
+
+		job := e.job
+		job.Attempts = 0
+		job.FailedAt = nil
+
+		encoded, err := json.Marshal(job)
+		if err != nil {
+			return false, fmt.Errorf("failed to encode job: %w", err)
+		}
+
+		n, err := requeueScript.Run(ctx, r.Client, []string{r.deadKey(), r.streamKey()}, e.raw, encoded).Int64()
+		if err != nil {
+			return false, fmt.Errorf("failed to requeue job: %w", err)
+		}
+
+		return n == 1, nil
+	})
+}
+
+// Purge drops matching dead jobs for good.
+func (r *Runner) Purge(ctx context.Context, id string, jobType string) (int, error) {
+
+	return r.settleDead(ctx, id, jobType, func(e deadEntry) (bool, error) {
+
+		n, err := r.Client.LRem(ctx, r.deadKey(), 1, e.raw).Result()
+		if err != nil {
+			return false, fmt.Errorf("failed to purge job: %w", err)
+		}
+
+		return n == 1, nil
+	})
+}
+
+func (r *Runner) settleDead(ctx context.Context, id string, jobType string, fn func(deadEntry) (bool, error)) (int, error) {
+
+	entries, err := r.deadEntries(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	var settled int
+
+	for _, e := range entries {
+		if id != "" && e.job.ID != id {
+			continue
+		}
+		if jobType != "" && e.job.Type != jobType {
+			continue
+		}
+
+		ok, err := fn(e)
+		if err != nil {
+			return settled, err
+		}
+		if ok {
+			settled++
+		}
+	}
+
+	if id != "" && settled == 0 {
+		return 0, ErrNotDead
+	}
+
+	return settled, nil
+}
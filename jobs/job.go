@@ -0,0 +1,55 @@
+package jobs
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/i101dev/microservices-NN/auth"
+	"github.com/i101dev/microservices-NN/tenant"
+)
+
+var ErrUnknownType = errors.New("no handler registered for job type")
+
+type Job struct {
+	ID         string          `json:"id"`
+	Type       string          `json:"type"`
+	Payload    json.RawMessage `json:"payload"`
+	Attempts   int             `json:"attempts"`
+	LastError  string          `json:"last_error,omitempty"`
+	EnqueuedAt time.Time       `json:"enqueued_at"`
+	FailedAt   *time.Time      `json:"failed_at,omitempty"`
+	// Tenant, Principal and Trace are captured from the enqueuing context
+	// and restored on the context the handler runs with.
+	Tenant    tenant.ID         `json:"tenant,omitempty"`
+	Principal *auth.Principal   `json:"principal,omitempty"`
+	Trace     map[string]string `json:"trace,omitempty"`
+}
+
+// Decode unmarshals the job payload into v.
+func (j Job) Decode(v any) error {
+	return json.Unmarshal(j.Payload, v)
+}
+
+type permanentError struct {
+	err error
+}
+
+func (e permanentError) Error() string {
+	return e.err.Error()
+}
+
+func (e permanentError) Unwrap() error {
+	return e.err
+}
+
+// Permanent marks err as not worth retrying. The job goes straight to the
+// dead-letter list.
+func Permanent(err error) error {
+	return permanentError{err: err}
+}
+
+func isPermanent(err error) bool {
+	var p permanentError
+	return errors.As(err, &p)
+}
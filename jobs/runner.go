@@ -0,0 +1,411 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/i101dev/microservices-NN/auth"
+	"github.com/i101dev/microservices-NN/clock"
+	"github.com/i101dev/microservices-NN/tenant"
+	"github.com/i101dev/microservices-NN/tracecontext"
+	"github.com/redis/go-redis/v9"
+)
+
+const group = "workers"
+
+type HandlerFunc func(ctx context.Context, job Job) error
+
+// Runner executes jobs from a Redis stream. Failed jobs are parked in a
+// sorted set until their backoff elapses and dead-lettered once they run
+// out of attempts.
+type Runner struct {
+	Client      *redis.Client
+	Queue       string
+	Concurrency int
+	MaxAttempts int
+	Backoff     time.Duration
+	MaxBackoff  time.Duration
+	// ClaimIdle is how long a delivered job may go unacknowledged before
+	// another worker takes it over.
+	ClaimIdle time.Duration
+	// DeadLimit caps the dead-letter list; the oldest entries fall off.
+	DeadLimit int64
+	Clock     clock.Clock
+	// Paused, when set and true, stops workers from taking new jobs.
+	Paused func() bool
+
+	mu       sync.RWMutex
+	handlers map[string]HandlerFunc
+
+	processed atomic.Uint64
+	succeeded atomic.Uint64
+	retried   atomic.Uint64
+	dead      atomic.Uint64
+	inFlight  atomic.Int64
+}
+
+type Stats struct {
+	Processed uint64 `json:"processed"`
+	Succeeded uint64 `json:"succeeded"`
+	Retried   uint64 `json:"retried"`
+	Dead      uint64 `json:"dead"`
+	InFlight  int64  `json:"in_flight"`
+	Backlog   int64  `json:"backlog"`
+	Delayed   int64  `json:"delayed"`
+	DeadQueue int64  `json:"dead_queue"`
+}
+
+func New(client *redis.Client, queue string) *Runner {
+	return &Runner{
+		Client:      client,
+		Queue:       queue,
+		Concurrency: 4,
+		MaxAttempts: 5,
+		Backoff:     time.Second,
+		MaxBackoff:  5 * time.Minute,
+		ClaimIdle:   time.Minute,
+		DeadLimit:   10000,
+		handlers:    map[string]HandlerFunc{},
+	}
+}
+
+func (r *Runner) now() time.Time {
+	if r.Clock == nil {
+		return time.Now().UTC()
+	}
+	return r.Clock.Now().UTC()
+}
+
+func (r *Runner) streamKey() string {
+	return "jobs:" + r.Queue + ":stream"
+}
+
+func (r *Runner) delayedKey() string {
+	return "jobs:" + r.Queue + ":delayed"
+}
+
+func (r *Runner) deadKey() string {
+	return "jobs:" + r.Queue + ":dead"
+}
+
+func (r *Runner) Register(jobType string, fn HandlerFunc) {
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.handlers[jobType] = fn
+}
+
+func (r *Runner) handler(jobType string) (HandlerFunc, bool) {
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	fn, ok := r.handlers[jobType]
+	return fn, ok
+}
+
+func (r *Runner) Enqueue(ctx context.Context, jobType string, payload any) (string, error) {
+	return r.EnqueueAt(ctx, jobType, payload, time.Time{})
+}
+
+// EnqueueAt schedules the job to become runnable at the given time. A zero
+// or past time makes it runnable immediately.
+func (r *Runner) EnqueueAt(ctx context.Context, jobType string, payload any, at time.Time) (string, error) {
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode payload: %w", err)
+	}
+
+	job := Job{
+		ID:         uuid.NewString(),
+		Type:       jobType,
+		Payload:    data,
+		EnqueuedAt: r.now(),
+		Tenant:     tenant.FromContext(ctx),
+		Trace:      tracecontext.Values(ctx),
+	}
+
+	if p, ok := auth.FromContext(ctx); ok {
+		job.Principal = &p
+	}
+
+	encoded, err := json.Marshal(job)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode job: %w", err)
+	}
+
+	if at.After(r.now()) {
+		err = r.Client.ZAdd(ctx, r.delayedKey(), redis.Z{
+			Score:  float64(at.UnixMilli()),
+			Member: encoded,
+		}).Err()
+	} else {
+		err = r.Client.XAdd(ctx, &redis.XAddArgs{
+			Stream: r.streamKey(),
+			Values: map[string]any{"job": encoded},
+		}).Err()
+	}
+
+	if err != nil {
+		return "", fmt.Errorf("failed to enqueue job: %w", err)
+	}
+
+	return job.ID, nil
+}
+
+// Run starts the workers and the delayed-job promoter and blocks until ctx
+// is cancelled.
+func (r *Runner) Run(ctx context.Context) error {
+
+	err := r.Client.XGroupCreateMkStream(ctx, r.streamKey(), group, "0").Err()
+	if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return fmt.Errorf("failed to create consumer group: %w", err)
+	}
+
+	var wg sync.WaitGroup
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		r.promote(ctx)
+	}()
+
+	for i := 0; i < r.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r.work(ctx, uuid.NewString())
+		}()
+	}
+
+	wg.Wait()
+
+	return nil
+}
+
+// promoteScript moves due jobs from the delayed set onto the stream in one
+// step, so two replicas can never both promote the same job.
+var promoteScript = redis.NewScript(`
+local due = redis.call('ZRANGEBYSCORE', KEYS[1], '-inf', ARGV[1], 'LIMIT', 0, ARGV[2])
+for _, job in ipairs(due) do
+	redis.call('ZREM', KEYS[1], job)
+	redis.call('XADD', KEYS[2], '*', 'job', job)
+end
+return #due
+`)
+
+func (r *Runner) promote(ctx context.Context) {
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		now := r.now().UnixMilli()
+
+		err := promoteScript.Run(ctx, r.Client, []string{r.delayedKey(), r.streamKey()}, now, 100).Err()
+		if err != nil && ctx.Err() == nil {
+			fmt.Println("failed to promote delayed jobs:", err)
+		}
+	}
+}
+
+func (r *Runner) work(ctx context.Context, consumer string) {
+
+	for ctx.Err() == nil {
+
+		if r.Paused != nil && r.Paused() {
+			select {
+			case <-ctx.Done():
+			case <-time.After(time.Second):
+			}
+			continue
+		}
+
+		claimed, _, err := r.Client.XAutoClaim(ctx, &redis.XAutoClaimArgs{
+			Stream:   r.streamKey(),
+			Group:    group,
+			Consumer: consumer,
+			MinIdle:  r.ClaimIdle,
+			Start:    "0-0",
+			Count:    10,
+		}).Result()
+
+		if err != nil && ctx.Err() == nil {
+			fmt.Println("failed to claim jobs:", err)
+		}
+
+		for _, msg := range claimed {
+			r.process(ctx, msg)
+		}
+
+		streams, err := r.Client.XReadGroup(ctx, &redis.XReadGroupArgs{
+			Group:    group,
+			Consumer: consumer,
+			Streams:  []string{r.streamKey(), ">"},
+			Count:    1,
+			Block:    2 * time.Second,
+		}).Result()
+
+		if errors.Is(err, redis.Nil) {
+			continue
+		} else if err != nil {
+			if ctx.Err() == nil {
+				fmt.Println("failed to read jobs:", err)
+				time.Sleep(time.Second)
+			}
+			continue
+		}
+
+		for _, s := range streams {
+			for _, msg := range s.Messages {
+				r.process(ctx, msg)
+			}
+		}
+	}
+}
+
+func (r *Runner) process(ctx context.Context, msg redis.XMessage) {
+
+	r.inFlight.Add(1)
+	defer r.inFlight.Add(-1)
+
+	data, _ := msg.Values["job"].(string)
+
+	var job Job
+	err := json.Unmarshal([]byte(data), &job)
+
+	if err != nil {
+		err = Permanent(fmt.Errorf("failed to decode job: %w", err))
+	} else if fn, ok := r.handler(job.Type); !ok {
+		err = Permanent(fmt.Errorf("%w: %s", ErrUnknownType, job.Type))
+	} else {
+		err = r.call(jobContext(ctx, job), fn, job)
+	}
+
+	// A job interrupted by shutdown stays pending and is reclaimed later.
+	if err != nil && ctx.Err() != nil {
+		return
+	}
+
+	r.processed.Add(1)
+
+	txn := r.Client.TxPipeline()
+
+	if err == nil {
+		r.succeeded.Add(1)
+	} else {
+		now := r.now()
+
+		job.Attempts++
+		job.LastError = err.Error()
+
+		if isPermanent(err) || job.Attempts >= r.MaxAttempts {
+			r.dead.Add(1)
+			job.FailedAt = &now
+			encoded, _ := json.Marshal(job)
+			txn.LPush(ctx, r.deadKey(), encoded)
+			txn.LTrim(ctx, r.deadKey(), 0, r.DeadLimit-1)
+		} else {
+			r.retried.Add(1)
+			encoded, _ := json.Marshal(job)
+			txn.ZAdd(ctx, r.delayedKey(), redis.Z{
+				Score:  float64(now.Add(r.backoff(job.Attempts)).UnixMilli()),
+				Member: encoded,
+			})
+		}
+	}
+
+	txn.XAck(ctx, r.streamKey(), group, msg.ID)
+	txn.XDel(ctx, r.streamKey(), msg.ID)
+
+	if _, err := txn.Exec(ctx); err != nil {
+		fmt.Println("failed to settle job:", err)
+	}
+}
+
+func jobContext(ctx context.Context, job Job) context.Context {
+
+	if job.Tenant != "" {
+		ctx = tenant.NewContext(ctx, job.Tenant)
+	}
+
+	if job.Principal != nil {
+		ctx = auth.NewContext(ctx, *job.Principal)
+	}
+
+	return tracecontext.Resume(ctx, job.Trace[tracecontext.ParentHeader], job.Trace[tracecontext.StateHeader])
+}
+
+func (r *Runner) call(ctx context.Context, fn HandlerFunc, job Job) (err error) {
+
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("job panicked: %v", p)
+		}
+	}()
+
+	return fn(ctx, job)
+}
+
+func (r *Runner) backoff(attempt int) time.Duration {
+
+	d := r.Backoff
+	for i := 1; i < attempt && d < r.MaxBackoff; i++ {
+		d *= 2
+	}
+
+	return min(d, r.MaxBackoff)
+}
+
+func (r *Runner) Stats(ctx context.Context) (Stats, error) {
+
+	pipe := r.Client.Pipeline()
+
+	backlog := pipe.XLen(ctx, r.streamKey())
+	delayed := pipe.ZCard(ctx, r.delayedKey())
+	dead := pipe.LLen(ctx, r.deadKey())
+
+	if _, err := pipe.Exec(ctx); err != nil && !errors.Is(err, redis.Nil) {
+		return Stats{}, fmt.Errorf("failed to read queue lengths: %w", err)
+	}
+
+	return Stats{
+		Processed: r.processed.Load(),
+		Succeeded: r.succeeded.Load(),
+		Retried:   r.retried.Load(),
+		Dead:      r.dead.Load(),
+		InFlight:  r.inFlight.Load(),
+		Backlog:   backlog.Val(),
+		Delayed:   delayed.Val(),
+		DeadQueue: dead.Val(),
+	}, nil
+}
+
+func (r *Runner) ServeStats(w http.ResponseWriter, req *http.Request) {
+
+	stats, err := r.Stats(req.Context())
+	if err != nil {
+		fmt.Println("failed to get job stats:", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(stats)
+}
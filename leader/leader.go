@@ -0,0 +1,204 @@
+package leader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+var ErrFenced = errors.New("fencing token is older than one already seen")
+
+// acquireScript takes the lease if it is free and hands out the next
+// fencing token for the new term.
+var acquireScript = redis.NewScript(`
+if redis.call('SET', KEYS[1], ARGV[1], 'NX', 'PX', ARGV[2]) then
+	return redis.call('INCR', KEYS[2])
+end
+return 0
+`)
+
+var renewScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('PEXPIRE', KEYS[1], ARGV[2])
+end
+return 0
+`)
+
+var releaseScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+
+var fenceScript = redis.NewScript(`
+local seen = tonumber(redis.call('GET', KEYS[1]) or '0')
+if tonumber(ARGV[1]) < seen then
+	return 0
+end
+redis.call('SET', KEYS[1], ARGV[1])
+return 1
+`)
+
+// Elector holds a Redis lease that at most one replica owns at a time. The
+// owner renews it well before it expires; everyone else keeps trying to
+// take it.
+type Elector struct {
+	client *redis.Client
+	key    string
+	id     string
+	ttl    time.Duration
+
+	mu     sync.Mutex
+	ctx    context.Context
+	cancel context.CancelFunc
+	token  uint64
+}
+
+func New(client *redis.Client, key string, ttl time.Duration) *Elector {
+	return &Elector{
+		client: client,
+		key:    key,
+		id:     uuid.NewString(),
+		ttl:    ttl,
+	}
+}
+
+// Run campaigns for and renews the lease until ctx is cancelled, then
+// releases it.
+func (e *Elector) Run(ctx context.Context) {
+
+	ticker := time.NewTicker(e.ttl / 3)
+	defer ticker.Stop()
+
+	for {
+		e.refresh(ctx)
+
+		select {
+		case <-ctx.Done():
+			e.release()
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (e *Elector) refresh(ctx context.Context) {
+
+	var n int64
+	var err error
+
+	if e.IsLeader() {
+		n, err = renewScript.Run(ctx, e.client, []string{e.key}, e.id, e.ttl.Milliseconds()).Int64()
+	} else {
+		n, err = acquireScript.Run(ctx, e.client, []string{e.key, e.key + ":fence"}, e.id, e.ttl.Milliseconds()).Int64()
+	}
+
+	if errors.Is(err, redis.Nil) {
+		err = nil
+	}
+
+	if err != nil && ctx.Err() == nil {
+		fmt.Printf("failed to refresh lease %s: %v\n", e.key, err)
+	}
+
+	// A renewal that errored is treated as lost: the key may expire before
+	// the next attempt and another replica could take over.
+	if n > 0 && err == nil {
+		e.acquire(ctx, uint64(n))
+	} else {
+		e.lose()
+	}
+}
+
+func (e *Elector) acquire(ctx context.Context, token uint64) {
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	// Renewals return 1 rather than a token; keep the one from this term.
+	if e.ctx == nil {
+		e.ctx, e.cancel = context.WithCancel(ctx)
+		e.token = token
+	}
+}
+
+func (e *Elector) lose() {
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.cancel != nil {
+		e.cancel()
+	}
+	e.ctx, e.cancel, e.token = nil, nil, 0
+}
+
+func (e *Elector) IsLeader() bool {
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return e.ctx != nil
+}
+
+// Token is the fencing token of the current term, or 0 when not leading.
+// Tokens only ever increase across terms.
+func (e *Elector) Token() uint64 {
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return e.token
+}
+
+// Context is cancelled as soon as leadership is lost, so long-running work
+// stops instead of overlapping with the next leader.
+func (e *Elector) Context() context.Context {
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.ctx == nil {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		return ctx
+	}
+
+	return e.ctx
+}
+
+func (e *Elector) release() {
+
+	e.lose()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	if err := releaseScript.Run(ctx, e.client, []string{e.key}, e.id).Err(); err != nil && !errors.Is(err, redis.Nil) {
+		fmt.Printf("failed to release lease %s: %v\n", e.key, err)
+	}
+}
+
+// Fence records token as the newest seen for resource and returns
+// ErrFenced if a newer term has already touched it. Call it before acting on
+// a shared resource so a paused former leader cannot overwrite the work of
+// its successor.
+func Fence(ctx context.Context, client *redis.Client, resource string, token uint64) error {
+
+	ok, err := fenceScript.Run(ctx, client, []string{"fence:" + resource}, token).Int64()
+	if err != nil {
+		return fmt.Errorf("failed to check fence: %w", err)
+	}
+
+	if ok == 0 {
+		return ErrFenced
+	}
+
+	return nil
+}
@@ -0,0 +1,96 @@
+package loadshed
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+// Class is who a request comes from. It shifts the route's priority: under
+// load public traffic is shed first and internal traffic last.
+type Class int
+
+const (
+	ClassUnknown Class = iota
+	ClassPublic
+	ClassPartner
+	ClassInternal
+)
+
+func (c Class) String() string {
+	switch c {
+	case ClassPublic:
+		return "public"
+	case ClassPartner:
+		return "partner"
+	case ClassInternal:
+		return "internal"
+	default:
+		return "unknown"
+	}
+}
+
+func ParseClass(s string) (Class, error) {
+	switch s {
+	case "public":
+		return ClassPublic, nil
+	case "partner":
+		return ClassPartner, nil
+	case "internal":
+		return ClassInternal, nil
+	default:
+		return ClassUnknown, fmt.Errorf("unknown priority class %q", s)
+	}
+}
+
+// adjust moves p one step down for public callers and one step up for
+// internal ones. Nothing is promoted to critical, which skips the limits
+// altogether, and critical routes stay critical.
+func (p Priority) adjust(c Class) Priority {
+
+	if p >= PriorityCritical {
+		return p
+	}
+
+	switch {
+	case c == ClassPublic && p > PriorityLow:
+		return p - 1
+	case c == ClassInternal && p < PriorityHigh:
+		return p + 1
+	default:
+		return p
+	}
+}
+
+type classKey struct{}
+
+func NewContext(ctx context.Context, c Class) context.Context {
+	return context.WithValue(ctx, classKey{}, c)
+}
+
+// ClassFromContext returns ClassUnknown for requests that were never
+// classified, which leaves route priorities as they are.
+func ClassFromContext(ctx context.Context) Class {
+	c, _ := ctx.Value(classKey{}).(Class)
+	return c
+}
+
+const APIKeyHeader = "X-API-Key"
+
+// Classify resolves the class of each request from its API key. Requests
+// without a known key are public.
+func Classify(keys map[string]Class) func(http.Handler) http.Handler {
+
+	return func(next http.Handler) http.Handler {
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+			c, ok := keys[r.Header.Get(APIKeyHeader)]
+			if !ok {
+				c = ClassPublic
+			}
+
+			next.ServeHTTP(w, r.WithContext(NewContext(r.Context(), c)))
+		})
+	}
+}
@@ -0,0 +1,169 @@
+package loadshed
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+type Priority int
+
+const (
+	PriorityLow Priority = iota
+	PriorityNormal
+	PriorityHigh
+	PriorityCritical
+)
+
+func (p Priority) String() string {
+	switch p {
+	case PriorityLow:
+		return "low"
+	case PriorityNormal:
+		return "normal"
+	case PriorityHigh:
+		return "high"
+	case PriorityCritical:
+		return "critical"
+	default:
+		return fmt.Sprintf("priority(%d)", int(p))
+	}
+}
+
+// share is the percentage of MaxInFlight a priority may occupy before it is
+// shed, so bulk traffic backs off well before writes start failing.
+func (p Priority) share() int {
+	switch p {
+	case PriorityLow:
+		return 50
+	case PriorityNormal:
+		return 80
+	default:
+		return 100
+	}
+}
+
+type Shedder struct {
+	maxInFlight  int
+	maxQueue     int64
+	queueTimeout time.Duration
+	sem          chan struct{}
+	inflight     atomic.Int64
+	queued       atomic.Int64
+	shed         atomic.Int64
+	shedByClass  [ClassInternal + 1]atomic.Int64
+}
+
+func New(maxInFlight, maxQueue int, queueTimeout time.Duration) *Shedder {
+	return &Shedder{
+		maxInFlight:  maxInFlight,
+		maxQueue:     int64(maxQueue),
+		queueTimeout: queueTimeout,
+		sem:          make(chan struct{}, maxInFlight),
+	}
+}
+
+type Stats struct {
+	InFlight    int64            `json:"in_flight"`
+	Queued      int64            `json:"queued"`
+	Shed        int64            `json:"shed"`
+	ShedByClass map[string]int64 `json:"shed_by_class"`
+}
+
+func (s *Shedder) Stats() Stats {
+
+	byClass := map[string]int64{}
+	for c := range s.shedByClass {
+		byClass[Class(c).String()] = s.shedByClass[c].Load()
+	}
+
+	return Stats{
+		InFlight:    s.inflight.Load(),
+		Queued:      s.queued.Load(),
+		Shed:        s.shed.Load(),
+		ShedByClass: byClass,
+	}
+}
+
+func (s *Shedder) ServeStats(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.Stats())
+}
+
+func (s *Shedder) Limit(p Priority) func(http.Handler) http.Handler {
+
+	return func(next http.Handler) http.Handler {
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+			c := ClassFromContext(r.Context())
+			p := p.adjust(c)
+
+			if !s.acquire(r.Context(), p) {
+				s.shed.Add(1)
+				s.shedByClass[c].Add(1)
+				w.Header().Set("Retry-After", "1")
+				http.Error(w, "server is overloaded, retry later", http.StatusServiceUnavailable)
+				return
+			}
+			defer s.release(p)
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func (s *Shedder) acquire(ctx context.Context, p Priority) bool {
+
+	if p >= PriorityCritical {
+		s.inflight.Add(1)
+		return true
+	}
+
+	threshold := int64(s.maxInFlight * p.share() / 100)
+	if s.inflight.Load() >= threshold && p < PriorityHigh {
+		return false
+	}
+
+	select {
+	case s.sem <- struct{}{}:
+		s.inflight.Add(1)
+		return true
+	default:
+	}
+
+	if p == PriorityLow || s.queueTimeout <= 0 {
+		return false
+	}
+
+	if s.queued.Add(1) > s.maxQueue {
+		s.queued.Add(-1)
+		return false
+	}
+	defer s.queued.Add(-1)
+
+	timer := time.NewTimer(s.queueTimeout)
+	defer timer.Stop()
+
+	select {
+	case s.sem <- struct{}{}:
+		s.inflight.Add(1)
+		return true
+	case <-timer.C:
+		return false
+	case <-ctx.Done():
+		return false
+	}
+}
+
+func (s *Shedder) release(p Priority) {
+
+	s.inflight.Add(-1)
+
+	if p < PriorityCritical {
+		<-s.sem
+	}
+}
@@ -0,0 +1,166 @@
+package logging
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+const (
+	HTTP       = "http"
+	Repository = "repository"
+	Events     = "events"
+)
+
+// Each subsystem logs through its own level so one can be turned up to
+// debug without flooding the output with the others.
+var (
+	mu      sync.Mutex
+	levels  = map[string]*slog.LevelVar{}
+	loggers = map[string]*slog.Logger{}
+	base    = map[string]slog.Level{}
+	toggled bool
+)
+
+func init() {
+	for _, name := range []string{HTTP, Repository, Events} {
+		levels[name] = new(slog.LevelVar)
+		handler := slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: levels[name]})
+		loggers[name] = slog.New(handler).With("subsystem", name)
+	}
+}
+
+// Logger returns the logger of a subsystem. It panics on an unknown name.
+func Logger(subsystem string) *slog.Logger {
+
+	l, ok := loggers[subsystem]
+	if !ok {
+		panic("unknown log subsystem " + subsystem)
+	}
+
+	return l
+}
+
+func ParseLevel(s string) (slog.Level, error) {
+
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(s)); err != nil {
+		return 0, fmt.Errorf("unknown log level %q", s)
+	}
+
+	return level, nil
+}
+
+// SetLevel changes the level of one subsystem, or of all of them when
+// subsystem is empty.
+func SetLevel(subsystem string, level slog.Level) error {
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if subsystem == "" {
+		for _, v := range levels {
+			v.Set(level)
+		}
+		return nil
+	}
+
+	v, ok := levels[subsystem]
+	if !ok {
+		return fmt.Errorf("unknown log subsystem %q", subsystem)
+	}
+
+	v.Set(level)
+
+	return nil
+}
+
+func Levels() map[string]string {
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	out := make(map[string]string, len(levels))
+	for name, v := range levels {
+		out[name] = strings.ToLower(v.Level().String())
+	}
+
+	return out
+}
+
+// Toggle switches every subsystem to debug, or back to the levels they had
+// before the last switch.
+func Toggle() {
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	for name, v := range levels {
+		if toggled {
+			v.Set(base[name])
+		} else {
+			base[name] = v.Level()
+			v.Set(slog.LevelDebug)
+		}
+	}
+
+	toggled = !toggled
+}
+
+// ToggleOnSignal calls Toggle on every SIGUSR1 until ctx is cancelled.
+func ToggleOnSignal(ctx context.Context) {
+
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, syscall.SIGUSR1)
+	defer signal.Stop(ch)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ch:
+			Toggle()
+			fmt.Printf("log levels toggled: %v\n", Levels())
+		}
+	}
+}
+
+// ServeLevels reports the levels, and on PUT sets one from a body such as
+// {"subsystem": "http", "level": "debug"}. Leaving out the subsystem sets
+// all of them.
+func ServeLevels(w http.ResponseWriter, r *http.Request) {
+
+	if r.Method == http.MethodPut {
+
+		var body struct {
+			Subsystem string `json:"subsystem"`
+			Level     string `json:"level"`
+		}
+
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		level, err := ParseLevel(body.Level)
+		if err == nil {
+			err = SetLevel(body.Subsystem, level)
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		fmt.Printf("log levels changed: %v\n", Levels())
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(Levels())
+}
@@ -0,0 +1,61 @@
+package logging
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/i101dev/microservices-NN/tracecontext"
+)
+
+// Requests logs every request to the http subsystem once it is served:
+// server errors at error level, everything else at info, with the client
+// address and user agent added at debug.
+func Requests(next http.Handler) http.Handler {
+
+	log := Logger(HTTP)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		start := time.Now()
+
+		next.ServeHTTP(ww, r)
+
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+
+		level := slog.LevelInfo
+		if status >= 500 {
+			level = slog.LevelError
+		}
+
+		ctx := context.WithoutCancel(r.Context())
+		if !log.Enabled(ctx, level) {
+			return
+		}
+
+		attrs := []any{
+			"method", r.Method,
+			"path", r.URL.Path,
+			"status", status,
+			"bytes", ww.BytesWritten(),
+			"duration", time.Since(start),
+			"request_id", middleware.GetReqID(r.Context()),
+		}
+
+		if s, ok := tracecontext.FromContext(r.Context()); ok {
+			attrs = append(attrs, "trace_id", s.TraceIDString())
+		}
+
+		if log.Enabled(ctx, slog.LevelDebug) {
+			attrs = append(attrs, "remote", r.RemoteAddr, "user_agent", r.UserAgent())
+		}
+
+		log.Log(ctx, level, "request", attrs...)
+	})
+}
@@ -0,0 +1,200 @@
+package maintenance
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/i101dev/microservices-NN/httperr"
+	"github.com/i101dev/microservices-NN/repository/order"
+	"github.com/redis/go-redis/v9"
+)
+
+const key = "maintenance"
+
+const defaultMessage = "the service is read-only for maintenance, retry later"
+
+var ErrReadOnly = errors.New("service is in read-only maintenance mode")
+
+type State struct {
+	Enabled bool       `json:"enabled"`
+	Message string     `json:"message,omitempty"`
+	Since   *time.Time `json:"since,omitempty"`
+	// Forced is set when MAINTENANCE_MODE turned it on; it cannot be
+	// switched off at runtime.
+	Forced bool `json:"forced,omitempty"`
+}
+
+// Mode is the read-only switch. The state lives in Redis so that a toggle
+// reaches every replica; each one polls it every Interval. Without a Client
+// the state is local to the process.
+type Mode struct {
+	Client   *redis.Client
+	Interval time.Duration
+	Forced   bool
+
+	mu    sync.RWMutex
+	state State
+}
+
+func (m *Mode) Run(ctx context.Context) {
+
+	ticker := time.NewTicker(m.Interval)
+	defer ticker.Stop()
+
+	for {
+		m.refresh(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (m *Mode) refresh(ctx context.Context) {
+
+	data, err := m.Client.Get(ctx, key).Bytes()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		// Keep the last known state rather than flapping on a blip.
+		if ctx.Err() == nil {
+			fmt.Println("failed to get maintenance state:", err)
+		}
+		return
+	}
+
+	var s State
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &s); err != nil {
+			fmt.Println("failed to decode maintenance state:", err)
+			return
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if s.Enabled != m.state.Enabled {
+		fmt.Println("maintenance mode:", s.Enabled)
+	}
+
+	m.state = s
+}
+
+func (m *Mode) State() State {
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	s := m.state
+
+	if m.Forced && !s.Enabled {
+		s = State{Enabled: true}
+	}
+	s.Forced = m.Forced
+
+	if s.Enabled && s.Message == "" {
+		s.Message = defaultMessage
+	}
+
+	return s
+}
+
+func (m *Mode) Enabled() bool {
+	return m.State().Enabled
+}
+
+func (m *Mode) Set(ctx context.Context, s State) error {
+
+	if !s.Enabled {
+		s = State{}
+	} else if s.Since == nil {
+		now := time.Now().UTC()
+		s.Since = &now
+	}
+	s.Forced = false
+
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+
+	if m.Client != nil {
+		if err := m.Client.Set(ctx, key, data, 0).Err(); err != nil {
+			return fmt.Errorf("failed to set maintenance state: %w", err)
+		}
+	}
+
+	m.mu.Lock()
+	m.state = s
+	m.mu.Unlock()
+
+	return nil
+}
+
+func (m *Mode) ServeState(w http.ResponseWriter, r *http.Request) {
+
+	if r.Method == http.MethodPut {
+
+		var s State
+
+		if err := json.NewDecoder(r.Body).Decode(&s); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		if err := m.Set(r.Context(), s); err != nil {
+			fmt.Println(err)
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		fmt.Printf("maintenance state changed: %+v\n", m.State())
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(m.State())
+}
+
+// Middleware rejects every request that is not a read while maintenance
+// is on.
+func (m *Mode) Middleware(next http.Handler) http.Handler {
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		s := m.State()
+		if !s.Enabled {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Set("Retry-After", "30")
+		httperr.Write(w, http.StatusServiceUnavailable, "maintenance", s.Message)
+	})
+}
+
+// Intercept fails repository writes with ErrReadOnly while maintenance is
+// on. It catches the writers the middleware does not see, such as GraphQL
+// mutations.
+func (m *Mode) Intercept(ctx context.Context, call *order.Call, next order.Handler) error {
+
+	switch call.Op {
+	case order.OpInsert, order.OpInsertAll, order.OpUpdate, order.OpDeleteByID, order.OpApply:
+		if m.Enabled() {
+			return ErrReadOnly
+		}
+	}
+
+	return next(ctx, call)
+}
@@ -0,0 +1,55 @@
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// NewRegistry returns a registry with the Go runtime and process
+// collectors already registered.
+func NewRegistry() *prometheus.Registry {
+
+	reg := prometheus.NewRegistry()
+
+	reg.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+
+	return reg
+}
+
+func Handler(reg *prometheus.Registry) http.Handler {
+	return promhttp.HandlerFor(reg, promhttp.HandlerOpts{})
+}
+
+// NewPanicCounter registers the count of panics recovered from handlers.
+func NewPanicCounter(reg prometheus.Registerer) prometheus.Counter {
+
+	c := prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "orders_http_panics_total",
+		Help: "Panics recovered from HTTP and GraphQL handlers.",
+	})
+
+	reg.MustRegister(c)
+
+	return c
+}
+
+// NewUnknownFieldsCounter registers the count of request body fields the
+// service has no place for, by route, which grows as clients drift from
+// the API.
+func NewUnknownFieldsCounter(reg prometheus.Registerer) *prometheus.CounterVec {
+
+	c := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "orders_http_unknown_fields_total",
+		Help: "Unknown fields found in request bodies.",
+	}, []string{"route"})
+
+	reg.MustRegister(c)
+
+	return c
+}
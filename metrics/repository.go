@@ -0,0 +1,126 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/i101dev/microservices-NN/codec"
+	"github.com/i101dev/microservices-NN/model"
+	"github.com/i101dev/microservices-NN/repository/order"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Repository records latency, errors, payload size and key count for
+// every repository operation it intercepts; order.Intercept(repo,
+// m.Intercept) gives the decorated repository. Payload size is the order
+// encoded with Codec, so it matches what the store writes or reads when
+// they share one.
+type Repository struct {
+	Codec codec.Codec
+
+	latency *prometheus.HistogramVec
+	errors  *prometheus.CounterVec
+	payload *prometheus.HistogramVec
+	keys    *prometheus.HistogramVec
+}
+
+func NewRepository(reg prometheus.Registerer, c codec.Codec) *Repository {
+
+	m := &Repository{
+		Codec: c,
+		latency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "orders_repository_duration_seconds",
+			Help:    "Latency of repository operations.",
+			Buckets: []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1},
+		}, []string{"op"}),
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "orders_repository_errors_total",
+			Help: "Failed repository operations by error kind.",
+		}, []string{"op", "kind"}),
+		payload: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "orders_repository_payload_bytes",
+			Help:    "Encoded size of the orders written or read.",
+			Buckets: prometheus.ExponentialBuckets(64, 2, 12),
+		}, []string{"op"}),
+		keys: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "orders_repository_keys",
+			Help:    "Number of orders an operation touched.",
+			Buckets: []float64{0, 1, 2, 5, 10, 25, 50, 100, 250, 500},
+		}, []string{"op"}),
+	}
+
+	reg.MustRegister(m.latency, m.errors, m.payload, m.keys)
+
+	return m
+}
+
+func (m *Repository) Intercept(ctx context.Context, call *order.Call, next order.Handler) error {
+
+	start := time.Now()
+
+	err := next(ctx, call)
+
+	op := string(call.Op)
+
+	m.latency.WithLabelValues(op).Observe(time.Since(start).Seconds())
+
+	if err != nil {
+		m.errors.WithLabelValues(op, kind(err)).Inc()
+		return err
+	}
+
+	var orders []model.Order
+
+	switch call.Op {
+	case order.OpInsert, order.OpUpdate, order.OpFindByID:
+		orders = []model.Order{call.Order}
+	case order.OpInsertAll:
+		orders = call.Orders
+	case order.OpApply:
+		orders = append(slices.Clone(call.Batch.Insert), call.Batch.Update...)
+	case order.OpFindAll:
+		orders = call.Result.Orders
+	}
+
+	n := len(orders)
+	if call.Op == order.OpDeleteByID {
+		n = 1
+	}
+
+	m.keys.WithLabelValues(op).Observe(float64(n))
+
+	if m.Codec != nil && len(orders) > 0 {
+		var size int
+		for _, o := range orders {
+			data, err := m.Codec.Marshal(o)
+			if err == nil {
+				size += len(data)
+			}
+		}
+		m.payload.WithLabelValues(op).Observe(float64(size))
+	}
+
+	return nil
+}
+
+func kind(err error) string {
+
+	switch {
+	case errors.Is(err, order.ErrNotExist):
+		return "not_exist"
+	case errors.Is(err, order.ErrExist):
+		return "exist"
+	case errors.Is(err, order.ErrConflict):
+		return "conflict"
+	case errors.Is(err, order.ErrUnavailable):
+		return "unavailable"
+	case errors.Is(err, order.ErrCorrupt):
+		return "corrupt"
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(err, context.Canceled):
+		return "context"
+	default:
+		return "other"
+	}
+}
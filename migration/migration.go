@@ -0,0 +1,142 @@
+package migration
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const versionKey = "schema:version"
+const lockKey = "schema:lock"
+
+var ErrVersionMismatch = errors.New("schema version mismatch")
+var ErrLocked = errors.New("another migration is running")
+
+type Migration struct {
+	Version int
+	Name    string
+	Up      func(ctx context.Context, client *redis.Client) error
+}
+
+type Migrator struct {
+	Client     *redis.Client
+	Migrations []Migration
+	LockTTL    time.Duration
+}
+
+func New(client *redis.Client) *Migrator {
+	return &Migrator{
+		Client:     client,
+		Migrations: All,
+		LockTTL:    time.Minute,
+	}
+}
+
+func (m *Migrator) Latest() int {
+
+	latest := 0
+
+	for _, mig := range m.Migrations {
+		if mig.Version > latest {
+			latest = mig.Version
+		}
+	}
+
+	return latest
+}
+
+func (m *Migrator) Current(ctx context.Context) (int, error) {
+
+	value, err := m.Client.Get(ctx, versionKey).Result()
+
+	if errors.Is(err, redis.Nil) {
+		return 0, nil
+	} else if err != nil {
+		return 0, fmt.Errorf("failed to get schema version: %w", err)
+	}
+
+	version, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid schema version %q: %w", value, err)
+	}
+
+	return version, nil
+}
+
+func (m *Migrator) Check(ctx context.Context) error {
+
+	current, err := m.Current(ctx)
+	if err != nil {
+		return err
+	}
+
+	if latest := m.Latest(); current != latest {
+		return fmt.Errorf("%w: store is at %d, binary expects %d", ErrVersionMismatch, current, latest)
+	}
+
+	return nil
+}
+
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+
+	ok, err := m.Client.SetNX(ctx, lockKey, "1", m.LockTTL).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to take migration lock: %w", err)
+	} else if !ok {
+		return nil, ErrLocked
+	}
+
+	defer func() {
+		if err := m.Client.Del(context.Background(), lockKey).Err(); err != nil {
+			fmt.Println("failed to release migration lock:", err)
+		}
+	}()
+
+	current, err := m.Current(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if latest := m.Latest(); current > latest {
+		return nil, fmt.Errorf("%w: store is at %d, binary only knows up to %d", ErrVersionMismatch, current, latest)
+	}
+
+	var applied []Migration
+
+	for _, mig := range m.pending(current) {
+
+		if err := mig.Up(ctx, m.Client); err != nil {
+			return applied, fmt.Errorf("failed to apply migration %d (%s): %w", mig.Version, mig.Name, err)
+		}
+
+		if err := m.Client.Set(ctx, versionKey, mig.Version, 0).Err(); err != nil {
+			return applied, fmt.Errorf("failed to record schema version %d: %w", mig.Version, err)
+		}
+
+		applied = append(applied, mig)
+	}
+
+	return applied, nil
+}
+
+func (m *Migrator) pending(current int) []Migration {
+
+	var pending []Migration
+
+	for _, mig := range m.Migrations {
+		if mig.Version > current {
+			pending = append(pending, mig)
+		}
+	}
+
+	sort.Slice(pending, func(i, j int) bool {
+		return pending[i].Version < pending[j].Version
+	})
+
+	return pending
+}
@@ -0,0 +1,20 @@
+package migration
+
+import (
+	"context"
+
+	"github.com/i101dev/microservices-NN/repository/order"
+	"github.com/redis/go-redis/v9"
+)
+
+var All = []Migration{
+	{
+		Version: 1,
+		Name:    "index existing order keys",
+		Up: func(ctx context.Context, client *redis.Client) error {
+			repo := &order.RedisRepo{Client: client}
+			_, _, err := repo.RebuildIndex(ctx)
+			return err
+		},
+	},
+}
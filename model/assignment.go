@@ -0,0 +1,65 @@
+package model
+
+import (
+	"errors"
+	"slices"
+	"time"
+)
+
+var ErrAlreadyAssigned = errors.New("order is already assigned to warehouses")
+
+// Assignment is the part of an order one warehouse sends, and the rule
+// that routed it there. An order split across warehouses has one per
+// warehouse.
+type Assignment struct {
+	Warehouse  string     `json:"warehouse"`
+	LineItems  []LineItem `json:"line_items"`
+	Rule       string     `json:"rule,omitempty"`
+	AssignedAt time.Time  `json:"assigned_at"`
+}
+
+// Assignable tells why the order cannot be assigned to warehouses, if it
+// cannot. Orders are assigned once, before they ship, and not while they
+// wait for stock.
+func (o *Order) Assignable() error {
+
+	if s := o.Status(); s != StatusPending && s != StatusReview {
+		return ErrInvalidTransition
+	}
+
+	if len(o.Assignments) > 0 {
+		return ErrAlreadyAssigned
+	}
+
+	return nil
+}
+
+// Assign records which warehouses send the order.
+func (o *Order) Assign(assignments []Assignment, now time.Time) error {
+
+	if err := o.Assignable(); err != nil {
+		return err
+	}
+
+	o.Assignments = slices.Clone(assignments)
+	for i := range o.Assignments {
+		o.Assignments[i].AssignedAt = now
+	}
+
+	return nil
+}
+
+// Warehouses returns the warehouses the order is assigned to, in the
+// order of its assignments.
+func (o *Order) Warehouses() []string {
+
+	var ids []string
+
+	for _, a := range o.Assignments {
+		if !slices.Contains(ids, a.Warehouse) {
+			ids = append(ids, a.Warehouse)
+		}
+	}
+
+	return ids
+}
@@ -0,0 +1,92 @@
+package model
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+var (
+	ErrInvalidAttachment  = errors.New("invalid attachment")
+	ErrTooManyAttachments = errors.New("too many attachments")
+	ErrAttachmentNotExist = errors.New("attachment does not exist")
+	// ErrAttachmentPending is returned for attachments whose file has not
+	// been uploaded yet.
+	ErrAttachmentPending = errors.New("attachment is not uploaded")
+)
+
+// MaxAttachments is how many files an order can have attached.
+const MaxAttachments = 20
+
+// Attachment is a file kept with an order, such as a signed proof of
+// delivery. The file itself is in blob storage. UploadedAt is nil until its
+// upload is confirmed.
+type Attachment struct {
+	ID          string     `json:"id"`
+	Name        string     `json:"name"`
+	ContentType string     `json:"content_type"`
+	Size        int64      `json:"size"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UploadedAt  *time.Time `json:"uploaded_at,omitempty"`
+}
+
+// ValidAttachmentName reports whether name can name an attached file: up
+// to 255 bytes of UTF-8 without slashes or control characters.
+func ValidAttachmentName(name string) bool {
+
+	if name == "" || len(name) > 255 || !utf8.ValidString(name) || name == "." || name == ".." {
+		return false
+	}
+
+	return !strings.ContainsFunc(name, func(r rune) bool {
+		return r == '/' || r == '\\' || unicode.IsControl(r)
+	})
+}
+
+// FindAttachment returns the attachment with the ID.
+func (o *Order) FindAttachment(id string) (*Attachment, error) {
+
+	i := slices.IndexFunc(o.Attachments, func(a Attachment) bool {
+		return a.ID == id
+	})
+	if i < 0 {
+		return nil, ErrAttachmentNotExist
+	}
+
+	return &o.Attachments[i], nil
+}
+
+func (o *Order) AddAttachment(a Attachment) error {
+
+	if !ValidAttachmentName(a.Name) || a.Size <= 0 {
+		return ErrInvalidAttachment
+	}
+
+	if len(o.Attachments) >= MaxAttachments {
+		return ErrTooManyAttachments
+	}
+
+	o.Attachments = append(slices.Clone(o.Attachments), a)
+
+	return nil
+}
+
+func (o *Order) RemoveAttachment(id string) error {
+
+	if _, err := o.FindAttachment(id); err != nil {
+		return err
+	}
+
+	o.Attachments = slices.DeleteFunc(slices.Clone(o.Attachments), func(a Attachment) bool {
+		return a.ID == id
+	})
+
+	if len(o.Attachments) == 0 {
+		o.Attachments = nil
+	}
+
+	return nil
+}
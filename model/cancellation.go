@@ -0,0 +1,24 @@
+package model
+
+import "time"
+
+// Cancellation records what an order was cancelled for: the fee kept back
+// from it and the policy rule that set the fee, and the reason the caller
+// gave.
+type Cancellation struct {
+	Fee    uint   `json:"fee,omitempty"`
+	Rule   string `json:"rule,omitempty"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// CancelFor cancels the order and records c with it.
+func (o *Order) CancelFor(c Cancellation, now time.Time) error {
+
+	if err := o.Cancel(now); err != nil {
+		return err
+	}
+
+	o.Cancellation = &c
+
+	return nil
+}
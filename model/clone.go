@@ -0,0 +1,107 @@
+package model
+
+import (
+	"encoding/json"
+	"maps"
+	"slices"
+)
+
+// Clone returns a deep copy of the order, which shares nothing with o, so
+// either can be changed without the other seeing it.
+func (o Order) Clone() Order {
+
+	c := o
+
+	c.CreatedAt = clonePtr(o.CreatedAt)
+	c.ShippedAt = clonePtr(o.ShippedAt)
+	c.CompletedAt = clonePtr(o.CompletedAt)
+	c.CancelledAt = clonePtr(o.CancelledAt)
+	c.UpdatedAt = clonePtr(o.UpdatedAt)
+	c.FlaggedAt = clonePtr(o.FlaggedAt)
+	c.ApprovedAt = clonePtr(o.ApprovedAt)
+	c.ExpeditedAt = clonePtr(o.ExpeditedAt)
+
+	c.LineItems = slices.Clone(o.LineItems)
+	c.Shipping = clonePtr(o.Shipping)
+	c.EstimatedDelivery = clonePtr(o.EstimatedDelivery)
+	c.Backorder = clonePtr(o.Backorder)
+	c.Redemptions = slices.Clone(o.Redemptions)
+	c.Tags = slices.Clone(o.Tags)
+	c.OrgID = clonePtr(o.OrgID)
+	c.Cancellation = clonePtr(o.Cancellation)
+
+	if o.Backorder != nil {
+		c.Backorder.FulfilledAt = clonePtr(o.Backorder.FulfilledAt)
+	}
+
+	if o.Tracking != nil {
+		t := *o.Tracking
+		t.At = clonePtr(t.At)
+		c.Tracking = &t
+	}
+
+	if o.Payment != nil {
+		p := *o.Payment
+		p.AuthorizedAt = clonePtr(p.AuthorizedAt)
+		p.CapturedAt = clonePtr(p.CapturedAt)
+		p.RefundedAt = clonePtr(p.RefundedAt)
+		c.Payment = &p
+	}
+
+	if o.Tax != nil {
+		t := *o.Tax
+		t.Lines = slices.Clone(t.Lines)
+		c.Tax = &t
+	}
+
+	if o.SLA != nil {
+		s := *o.SLA
+		s.BreachedAt = clonePtr(s.BreachedAt)
+		c.SLA = &s
+	}
+
+	if o.Attachments != nil {
+		c.Attachments = make([]Attachment, len(o.Attachments))
+		for i, a := range o.Attachments {
+			a.UploadedAt = clonePtr(a.UploadedAt)
+			c.Attachments[i] = a
+		}
+	}
+
+	if o.Assignments != nil {
+		c.Assignments = make([]Assignment, len(o.Assignments))
+		for i, a := range o.Assignments {
+			a.LineItems = slices.Clone(a.LineItems)
+			c.Assignments[i] = a
+		}
+	}
+
+	if o.Disputes != nil {
+		c.Disputes = make([]Dispute, len(o.Disputes))
+		for i, d := range o.Disputes {
+			d.EvidenceDueBy = clonePtr(d.EvidenceDueBy)
+			d.Evidence = slices.Clone(d.Evidence)
+			d.ClosedAt = clonePtr(d.ClosedAt)
+			c.Disputes[i] = d
+		}
+	}
+
+	if o.Metadata != nil {
+		c.Metadata = maps.Clone(o.Metadata)
+		for k, v := range c.Metadata {
+			c.Metadata[k] = json.RawMessage(slices.Clone(v))
+		}
+	}
+
+	return c
+}
+
+func clonePtr[T any](p *T) *T {
+
+	if p == nil {
+		return nil
+	}
+
+	v := *p
+	return &v
+}
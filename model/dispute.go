@@ -0,0 +1,213 @@
+package model
+
+import (
+	"errors"
+	"slices"
+	"time"
+)
+
+var (
+	ErrDisputeNotExist = errors.New("dispute does not exist")
+	ErrInvalidDispute  = errors.New("invalid dispute transition")
+	ErrInvalidEvidence = errors.New("invalid dispute evidence")
+	// ErrNoDeadlineMissed is returned when no dispute of the order is
+	// overdue.
+	ErrNoDeadlineMissed = errors.New("no dispute missed its deadline")
+)
+
+// A dispute needs a response until evidence is sent to the provider, is
+// under review while the card network decides, and is then won or lost.
+const (
+	DisputeNeedsResponse = "needs_response"
+	DisputeUnderReview   = "under_review"
+	DisputeWon           = "won"
+	DisputeLost          = "lost"
+)
+
+// MaxEvidence is how many attachments one dispute can refer to.
+const MaxEvidence = 20
+
+// Dispute is a chargeback the customer's bank raised against the payment
+// of the order. ID is the provider's. Evidence holds the IDs of the
+// order's attachments that back the response.
+type Dispute struct {
+	ID            string     `json:"id"`
+	Status        string     `json:"status"`
+	Reason        string     `json:"reason,omitempty"`
+	Amount        uint       `json:"amount"`
+	EvidenceDueBy *time.Time `json:"evidence_due_by,omitempty"`
+	Evidence      []string   `json:"evidence,omitempty"`
+	// DeadlineMissed is set once EvidenceDueBy passed while the dispute
+	// still needed a response.
+	DeadlineMissed bool       `json:"deadline_missed,omitempty"`
+	OpenedAt       time.Time  `json:"opened_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+	ClosedAt       *time.Time `json:"closed_at,omitempty"`
+}
+
+func (d Dispute) Open() bool {
+	return d.Status == DisputeNeedsResponse || d.Status == DisputeUnderReview
+}
+
+// Overdue reports whether the dispute still needs a response after its
+// evidence deadline, and has not been marked for it yet.
+func (d Dispute) Overdue(now time.Time) bool {
+	return d.Status == DisputeNeedsResponse && !d.DeadlineMissed && d.EvidenceDueBy != nil && now.After(*d.EvidenceDueBy)
+}
+
+func (o *Order) HasOpenDispute() bool {
+	return slices.ContainsFunc(o.Disputes, Dispute.Open)
+}
+
+// DisputeOverdue reports whether any dispute of the order is overdue.
+func (o *Order) DisputeOverdue(now time.Time) bool {
+	return slices.ContainsFunc(o.Disputes, func(d Dispute) bool {
+		return d.Overdue(now)
+	})
+}
+
+// FindDispute returns the dispute with the ID.
+func (o *Order) FindDispute(id string) (*Dispute, error) {
+
+	i := slices.IndexFunc(o.Disputes, func(d Dispute) bool {
+		return d.ID == id
+	})
+	if i < 0 {
+		return nil, ErrDisputeNotExist
+	}
+
+	return &o.Disputes[i], nil
+}
+
+// The dispute methods replace o.Disputes instead of changing it in place,
+// since orders read through the caches share it.
+
+// OpenDispute records a new dispute of the payment, needing a response.
+func (o *Order) OpenDispute(d Dispute, now time.Time) error {
+
+	if o.Payment == nil || d.ID == "" || d.Amount == 0 {
+		return ErrInvalidDispute
+	}
+
+	if _, err := o.FindDispute(d.ID); err == nil {
+		return ErrInvalidDispute
+	}
+
+	d.Status = DisputeNeedsResponse
+	d.Evidence = nil
+	d.DeadlineMissed = false
+	d.OpenedAt, d.UpdatedAt, d.ClosedAt = now, now, nil
+
+	o.Disputes = append(slices.Clone(o.Disputes), d)
+
+	return nil
+}
+
+// UpdateDispute moves an open dispute on to status. A new dueBy, when
+// given, replaces the evidence deadline.
+func (o *Order) UpdateDispute(id, status string, dueBy *time.Time, now time.Time) error {
+
+	i := slices.IndexFunc(o.Disputes, func(d Dispute) bool {
+		return d.ID == id
+	})
+	if i < 0 {
+		return ErrDisputeNotExist
+	}
+
+	d := o.Disputes[i]
+
+	switch {
+	case !d.Open():
+		return ErrInvalidDispute
+	case status == DisputeWon || status == DisputeLost:
+		d.ClosedAt = &now
+	case status == DisputeUnderReview, status == d.Status:
+	default:
+		return ErrInvalidDispute
+	}
+
+	d.Status = status
+	d.UpdatedAt = now
+	if dueBy != nil {
+		d.EvidenceDueBy = dueBy
+		d.DeadlineMissed = false
+	}
+
+	o.Disputes = slices.Clone(o.Disputes)
+	o.Disputes[i] = d
+
+	return nil
+}
+
+// AddDisputeEvidence refers an open dispute to attachments of the order,
+// which must have been uploaded. Attachments it refers to already are
+// skipped.
+func (o *Order) AddDisputeEvidence(id string, attachmentIDs []string, now time.Time) error {
+
+	i := slices.IndexFunc(o.Disputes, func(d Dispute) bool {
+		return d.ID == id
+	})
+	if i < 0 {
+		return ErrDisputeNotExist
+	}
+
+	d := o.Disputes[i]
+
+	if !d.Open() {
+		return ErrInvalidDispute
+	}
+
+	evidence := slices.Clone(d.Evidence)
+
+	for _, a := range attachmentIDs {
+		att, err := o.FindAttachment(a)
+		if err != nil {
+			return err
+		}
+		if att.UploadedAt == nil {
+			return ErrAttachmentPending
+		}
+		if !slices.Contains(evidence, a) {
+			evidence = append(evidence, a)
+		}
+	}
+
+	if len(attachmentIDs) == 0 || len(evidence) > MaxEvidence {
+		return ErrInvalidEvidence
+	}
+
+	d.Evidence = evidence
+	d.UpdatedAt = now
+
+	o.Disputes = slices.Clone(o.Disputes)
+	o.Disputes[i] = d
+
+	return nil
+}
+
+// MissDisputeDeadlines marks the overdue disputes and returns them.
+func (o *Order) MissDisputeDeadlines(now time.Time) ([]Dispute, error) {
+
+	var missed []Dispute
+
+	disputes := slices.Clone(o.Disputes)
+
+	for i, d := range disputes {
+		if !d.Overdue(now) {
+			continue
+		}
+
+		d.DeadlineMissed = true
+		d.UpdatedAt = now
+		disputes[i] = d
+		missed = append(missed, d)
+	}
+
+	if len(missed) == 0 {
+		return nil, ErrNoDeadlineMissed
+	}
+
+	o.Disputes = disputes
+
+	return missed, nil
+}
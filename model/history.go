@@ -0,0 +1,24 @@
+package model
+
+import "time"
+
+const (
+	HistoryMergedInto = "merged_into"
+	HistoryMergedFrom = "merged_from"
+	HistorySplitInto  = "split_into"
+	HistorySplitFrom  = "split_from"
+	// An order placed with items out of stock is split, and the short
+	// items are backordered into a new order.
+	HistoryBackorderedInto = "backordered_into"
+	HistoryBackorderedFrom = "backordered_from"
+)
+
+// HistoryEntry records an operation that involved other orders, such as a
+// merge, on the order it happened to. Related names the other orders.
+type HistoryEntry struct {
+	OrderID   uint64     `json:"order_id"`
+	At        time.Time  `json:"at"`
+	Action    string     `json:"action"`
+	Related   []uint64   `json:"related,omitempty"`
+	LineItems []LineItem `json:"line_items,omitempty"`
+}
@@ -1,11 +1,27 @@
 package model
 
 import (
+	"encoding/json"
+	"errors"
+	"slices"
 	"time"
 
 	"github.com/google/uuid"
 )
 
+var ErrInvalidTransition = errors.New("invalid order status transition")
+
+const (
+	StatusPending   = "pending"
+	StatusReview    = "review"
+	StatusShipped   = "shipped"
+	StatusCompleted = "completed"
+	StatusCancelled = "cancelled"
+	// StatusBackordered is an order waiting for stock. It can only be
+	// cancelled until it is fulfilled and becomes pending.
+	StatusBackordered = "backordered"
+)
+
 type Order struct {
 	OrderID     uint64     `json:"order_id"`
 	CustomerID  uuid.UUID  `json:"customer_id"`
@@ -13,6 +29,121 @@ type Order struct {
 	CreatedAt   *time.Time `json:"created_at"`
 	ShippedAt   *time.Time `json:"shipped_at"`
 	CompletedAt *time.Time `json:"completed_at"`
+	CancelledAt *time.Time `json:"cancelled_at"`
+	UpdatedAt   *time.Time `json:"updated_at"`
+	// FlaggedAt is set when the fraud check held the order for review, and
+	// ApprovedAt once someone released it.
+	FlaggedAt    *time.Time `json:"flagged_at"`
+	ApprovedAt   *time.Time `json:"approved_at"`
+	ReviewReason string     `json:"review_reason,omitempty"`
+	// PossibleDuplicate is set at creation when the same customer placed an
+	// order with the same items shortly before.
+	PossibleDuplicate bool `json:"possible_duplicate,omitempty"`
+	// Shipping is how and where the customer asked for the order to be
+	// sent. The estimate is worked out from it at creation and again when
+	// the order ships.
+	Shipping          *Shipping         `json:"shipping,omitempty"`
+	EstimatedDelivery *DeliveryEstimate `json:"estimated_delivery,omitempty"`
+	// Tracking is the parcel the order shipped in, with the last update the
+	// carrier sent about it.
+	Tracking *Tracking `json:"tracking,omitempty"`
+	Payment  *Payment  `json:"payment,omitempty"`
+	// Tax is worked out when the order is placed, and again when its items
+	// change.
+	Tax *Tax `json:"tax,omitempty"`
+	// Backorder is set on orders placed for items that were out of stock.
+	// BackorderID names the backorder the short items of this order were
+	// moved to.
+	Backorder   *Backorder `json:"backorder,omitempty"`
+	BackorderID uint64     `json:"backorder_id,omitempty"`
+	// Redemptions are the parts of the order paid with gift cards or store
+	// credit. They go back to the customer as store credit if the order is
+	// cancelled.
+	Redemptions []Redemption `json:"redemptions,omitempty"`
+	// QuoteID is the quote the order was converted from. Its prices and
+	// tax are the quoted ones.
+	QuoteID string `json:"quote_id,omitempty"`
+	// Tags are free-form labels kept sorted, such as "vip". Orders can be
+	// listed by them.
+	Tags []string `json:"tags,omitempty"`
+	// OrgID is the organization the customer placed the order for. Its
+	// members can see the order, and only its managers cancel it.
+	OrgID *uuid.UUID `json:"org_id,omitempty"`
+	// Attachments are the files kept with the order.
+	Attachments []Attachment `json:"attachments,omitempty"`
+	// Metadata holds the fields the order was created with that it has no
+	// place for, as they were sent.
+	Metadata map[string]json.RawMessage `json:"metadata,omitempty"`
+	// SLA is when the order has to ship by. SLABreached is set once it
+	// missed it, and stays set after it ships.
+	SLA         *SLA `json:"sla,omitempty"`
+	SLABreached bool `json:"sla_breached,omitempty"`
+	// Priority is empty for normal orders. ExpeditedAt is when the order
+	// became expedited.
+	Priority    string     `json:"priority,omitempty"`
+	ExpeditedAt *time.Time `json:"expedited_at,omitempty"`
+	// Assignments are the warehouses the order is sent from, and which of
+	// its items each sends.
+	Assignments []Assignment `json:"assignments,omitempty"`
+	// Disputes are the chargebacks raised against the payment, oldest
+	// first.
+	Disputes []Dispute `json:"disputes,omitempty"`
+	// Cancellation is set for orders cancelled through the cancellation
+	// policy.
+	Cancellation *Cancellation `json:"cancellation,omitempty"`
+}
+
+// Backorder records when an order started waiting for stock and when it
+// got it. SplitFrom is the order it was split from, or zero when nothing
+// the order asked for was in stock.
+type Backorder struct {
+	SplitFrom   uint64     `json:"split_from,omitempty"`
+	Since       time.Time  `json:"since"`
+	FulfilledAt *time.Time `json:"fulfilled_at,omitempty"`
+}
+
+const (
+	TrackingInTransit = "in_transit"
+	TrackingDelivered = "delivered"
+	TrackingException = "exception"
+)
+
+type Tracking struct {
+	Carrier string     `json:"carrier"`
+	Number  string     `json:"number"`
+	Status  string     `json:"status,omitempty"`
+	At      *time.Time `json:"at,omitempty"`
+}
+
+type Shipping struct {
+	Method string `json:"method"`
+	// Country is an ISO 3166-1 alpha-2 code.
+	Country string `json:"country"`
+	Region  string `json:"region,omitempty"`
+}
+
+// DeliveryEstimate is the window the order should arrive in, both days
+// included, and the carrier and rule it came from.
+type DeliveryEstimate struct {
+	Earliest time.Time `json:"earliest"`
+	Latest   time.Time `json:"latest"`
+	Carrier  string    `json:"carrier,omitempty"`
+	Rule     string    `json:"rule,omitempty"`
+}
+
+// Tax is the tax due on an order, with one line per item and
+// jurisdiction that taxes it.
+type Tax struct {
+	Total uint      `json:"total"`
+	Lines []TaxLine `json:"lines"`
+}
+
+type TaxLine struct {
+	ItemID       uuid.UUID `json:"item_id"`
+	Jurisdiction string    `json:"jurisdiction"`
+	// Rate is a fraction of the line amount, 0.2 for 20%.
+	Rate   float64 `json:"rate"`
+	Amount uint    `json:"amount"`
 }
 
 type LineItem struct {
@@ -20,3 +151,127 @@ type LineItem struct {
 	Quantity uint      `json:"quantity"`
 	Price    uint      `json:"price"`
 }
+
+// CombineLineItems adds items to a copy of into, summing the quantities of
+// lines for the same item at the same price.
+func CombineLineItems(into, items []LineItem) []LineItem {
+
+	into = slices.Clone(into)
+
+	for _, item := range items {
+		i := slices.IndexFunc(into, func(l LineItem) bool {
+			return l.ItemID == item.ItemID && l.Price == item.Price
+		})
+		if i < 0 {
+			into = append(into, item)
+			continue
+		}
+		into[i].Quantity += item.Quantity
+	}
+
+	return into
+}
+
+func (o *Order) Status() string {
+
+	switch {
+	case o.CancelledAt != nil:
+		return StatusCancelled
+	case o.CompletedAt != nil:
+		return StatusCompleted
+	case o.ShippedAt != nil:
+		return StatusShipped
+	case o.Backorder != nil && o.Backorder.FulfilledAt == nil:
+		return StatusBackordered
+	case o.FlaggedAt != nil && o.ApprovedAt == nil:
+		return StatusReview
+	default:
+		return StatusPending
+	}
+}
+
+func (o *Order) Ship(now time.Time) error {
+
+	if o.Status() != StatusPending {
+		return ErrInvalidTransition
+	}
+
+	o.ShippedAt = &now
+
+	return nil
+}
+
+func (o *Order) Complete(now time.Time) error {
+
+	if o.Status() != StatusShipped {
+		return ErrInvalidTransition
+	}
+
+	o.CompletedAt = &now
+
+	return nil
+}
+
+// Approve releases an order held for review so it can be shipped.
+func (o *Order) Approve(now time.Time) error {
+
+	if o.Status() != StatusReview {
+		return ErrInvalidTransition
+	}
+
+	o.ApprovedAt = &now
+
+	return nil
+}
+
+// Fulfill releases a backordered order once its items are in stock.
+func (o *Order) Fulfill(now time.Time) error {
+
+	if o.Status() != StatusBackordered {
+		return ErrInvalidTransition
+	}
+
+	b := *o.Backorder
+	b.FulfilledAt = &now
+	o.Backorder = &b
+
+	return nil
+}
+
+// Cancel also rejects orders held for review, and drops backorders.
+func (o *Order) Cancel(now time.Time) error {
+
+	if s := o.Status(); s != StatusPending && s != StatusReview && s != StatusBackordered {
+		return ErrInvalidTransition
+	}
+
+	o.CancelledAt = &now
+
+	return nil
+}
+
+func (o *Order) Transition(status string, now time.Time) error {
+
+	switch status {
+	case StatusShipped:
+		return o.Ship(now)
+	case StatusCompleted:
+		return o.Complete(now)
+	case StatusCancelled:
+		return o.Cancel(now)
+	default:
+		return ErrInvalidTransition
+	}
+}
+
+type OrderPage struct {
+	Items []Order `json:"items"`
+	Next  uint64  `json:"next,omitempty"`
+	// Snapshot is set while a consistent listing has pages left.
+	Snapshot string `json:"snapshot,omitempty"`
+	// Total, when asked for, is how many orders the listing has in all.
+	// It is read from counters kept behind the writes, so it counts every
+	// write made before TotalAsOf and maybe some made since.
+	Total     *int64     `json:"total,omitempty"`
+	TotalAsOf *time.Time `json:"total_as_of,omitempty"`
+}
@@ -0,0 +1,87 @@
+package model
+
+import (
+	"errors"
+	"time"
+)
+
+var ErrInvalidPayment = errors.New("invalid payment transition")
+
+const (
+	PaymentAuthorized = "authorized"
+	PaymentCaptured   = "captured"
+	PaymentRefunded   = "refunded"
+)
+
+// Payment follows the money for an order at the payment provider. It is
+// authorized, then captured, then refunded in one or more parts. Amounts
+// are in the same minor units as line item prices.
+type Payment struct {
+	Reference    string     `json:"reference"`
+	Status       string     `json:"status"`
+	Authorized   uint       `json:"authorized"`
+	Captured     uint       `json:"captured,omitempty"`
+	Refunded     uint       `json:"refunded,omitempty"`
+	AuthorizedAt *time.Time `json:"authorized_at,omitempty"`
+	CapturedAt   *time.Time `json:"captured_at,omitempty"`
+	RefundedAt   *time.Time `json:"refunded_at,omitempty"`
+}
+
+// The payment methods replace o.Payment instead of changing it in place,
+// since orders read through the caches share it.
+
+func (o *Order) AuthorizePayment(reference string, amount uint, now time.Time) error {
+
+	if o.Payment != nil || o.Status() == StatusCancelled {
+		return ErrInvalidPayment
+	}
+
+	o.Payment = &Payment{
+		Reference:    reference,
+		Status:       PaymentAuthorized,
+		Authorized:   amount,
+		AuthorizedAt: &now,
+	}
+
+	return nil
+}
+
+// CapturePayment takes up to the authorized amount.
+func (o *Order) CapturePayment(amount uint, now time.Time) error {
+
+	if o.Payment == nil || o.Payment.Status != PaymentAuthorized || amount > o.Payment.Authorized {
+		return ErrInvalidPayment
+	}
+
+	p := *o.Payment
+	p.Status = PaymentCaptured
+	p.Captured = amount
+	p.CapturedAt = &now
+	o.Payment = &p
+
+	return nil
+}
+
+// RefundPayment gives back part or all of the captured amount. An order
+// refunded in full before it shipped is cancelled.
+func (o *Order) RefundPayment(amount uint, now time.Time) error {
+
+	if o.Payment == nil || o.Payment.Status != PaymentCaptured || o.Payment.Refunded+amount > o.Payment.Captured {
+		return ErrInvalidPayment
+	}
+
+	p := *o.Payment
+	p.Refunded += amount
+	p.RefundedAt = &now
+
+	if p.Refunded == p.Captured {
+		p.Status = PaymentRefunded
+		if s := o.Status(); s == StatusPending || s == StatusReview {
+			o.CancelledAt = &now
+		}
+	}
+
+	o.Payment = &p
+
+	return nil
+}
@@ -0,0 +1,45 @@
+package model
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	ErrInvalidPriority  = errors.New("invalid order priority")
+	ErrAlreadyExpedited = errors.New("order is already expedited")
+)
+
+// Expedited orders are written ahead of normal ones when creates are
+// queued, and fulfillment is told to send them first.
+const (
+	PriorityNormal    = "normal"
+	PriorityExpedited = "expedited"
+)
+
+func ValidPriority(p string) bool {
+	return p == PriorityNormal || p == PriorityExpedited
+}
+
+// Expedited reports whether the order has the expedited priority.
+func (o *Order) Expedited() bool {
+	return o.Priority == PriorityExpedited
+}
+
+// Expedite raises the priority of an order that is still to ship.
+func (o *Order) Expedite(now time.Time) error {
+
+	switch o.Status() {
+	case StatusShipped, StatusCompleted, StatusCancelled:
+		return ErrInvalidTransition
+	}
+
+	if o.Expedited() {
+		return ErrAlreadyExpedited
+	}
+
+	o.Priority = PriorityExpedited
+	o.ExpeditedAt = &now
+
+	return nil
+}
@@ -0,0 +1,86 @@
+package model
+
+import (
+	"errors"
+	"slices"
+	"time"
+)
+
+var ErrNotRedeemable = errors.New("order cannot be paid with a balance")
+
+const (
+	RedemptionGiftCard    = "gift_card"
+	RedemptionStoreCredit = "store_credit"
+)
+
+// Redemption is an amount taken from a gift card, named by Code, or from
+// the customer's store credit.
+type Redemption struct {
+	Source string    `json:"source"`
+	Code   string    `json:"code,omitempty"`
+	Amount uint      `json:"amount"`
+	At     time.Time `json:"at"`
+}
+
+// Total is what the order costs with its tax.
+func (o *Order) Total() uint {
+
+	var total uint
+	for _, item := range o.LineItems {
+		total += item.Price * item.Quantity
+	}
+
+	if o.Tax != nil {
+		total += o.Tax.Total
+	}
+
+	return total
+}
+
+func (o *Order) Redeemed() uint {
+
+	var redeemed uint
+	for _, r := range o.Redemptions {
+		redeemed += r.Amount
+	}
+
+	return redeemed
+}
+
+// Due is what is left to pay after redemptions. It is zero when an order
+// redeemed more than a later split left on it.
+func (o *Order) Due() uint {
+
+	total, redeemed := o.Total(), o.Redeemed()
+	if redeemed >= total {
+		return 0
+	}
+
+	return total - redeemed
+}
+
+// Redeemable reports whether an order that has not shipped still has
+// something due.
+func (o *Order) Redeemable() bool {
+
+	switch o.Status() {
+	case StatusPending, StatusReview, StatusBackordered:
+		return o.Due() > 0
+	default:
+		return false
+	}
+}
+
+// Redeem records r against an order. It fails with ErrNotRedeemable when
+// the order is not redeemable or r is more than is due.
+func (o *Order) Redeem(r Redemption) error {
+
+	if !o.Redeemable() || r.Amount == 0 || r.Amount > o.Due() {
+		return ErrNotRedeemable
+	}
+
+	// Appending to a clone keeps orders shared through the caches intact.
+	o.Redemptions = append(slices.Clone(o.Redemptions), r)
+
+	return nil
+}
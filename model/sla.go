@@ -0,0 +1,48 @@
+package model
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrSLANotBreached is returned when an order is marked as having missed
+// its SLA before it did.
+var ErrSLANotBreached = errors.New("order sla is not breached")
+
+// SLA is the deadline an order has to ship by, set when its payment is
+// authorized. BreachedAt is when the order was found to have missed it.
+type SLA struct {
+	ShipBy     time.Time  `json:"ship_by"`
+	BreachedAt *time.Time `json:"breached_at,omitempty"`
+}
+
+// SLAMissed reports whether the order missed its SLA by now and has not
+// been marked for it yet: it shipped late, or is still waiting to ship
+// past the deadline. Cancelled orders never miss it.
+func (o *Order) SLAMissed(now time.Time) bool {
+
+	if o.SLA == nil || o.SLA.BreachedAt != nil || o.CancelledAt != nil {
+		return false
+	}
+
+	if o.ShippedAt != nil {
+		return o.ShippedAt.After(o.SLA.ShipBy)
+	}
+
+	return now.After(o.SLA.ShipBy)
+}
+
+// BreachSLA marks the order as having missed its SLA.
+func (o *Order) BreachSLA(now time.Time) error {
+
+	if !o.SLAMissed(now) {
+		return ErrSLANotBreached
+	}
+
+	s := *o.SLA
+	s.BreachedAt = &now
+	o.SLA = &s
+	o.SLABreached = true
+
+	return nil
+}
@@ -0,0 +1,60 @@
+package model
+
+import (
+	"errors"
+	"regexp"
+	"slices"
+)
+
+var (
+	ErrInvalidTag  = errors.New("invalid tag")
+	ErrTooManyTags = errors.New("too many tags")
+)
+
+// MaxTags is how many tags an order can have.
+const MaxTags = 20
+
+var tagPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_.-]{0,31}$`)
+
+// ValidTag reports whether t can be used as a tag: up to 32 lower case
+// letters, digits, dots, dashes and underscores.
+func ValidTag(t string) bool {
+	return tagPattern.MatchString(t)
+}
+
+// AddTags adds tags the order does not have yet.
+func (o *Order) AddTags(tags ...string) error {
+
+	merged := slices.Clone(o.Tags)
+
+	for _, t := range tags {
+		if !ValidTag(t) {
+			return ErrInvalidTag
+		}
+		if !slices.Contains(merged, t) {
+			merged = append(merged, t)
+		}
+	}
+
+	if len(merged) > MaxTags {
+		return ErrTooManyTags
+	}
+
+	slices.Sort(merged)
+	o.Tags = merged
+
+	return nil
+}
+
+// RemoveTag drops a tag. Removing one the order does not have is not an
+// error.
+func (o *Order) RemoveTag(tag string) {
+
+	o.Tags = slices.DeleteFunc(slices.Clone(o.Tags), func(t string) bool {
+		return t == tag
+	})
+
+	if len(o.Tags) == 0 {
+		o.Tags = nil
+	}
+}
@@ -0,0 +1,117 @@
+package openapi
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/getkin/kin-openapi/routers/gorillamux"
+)
+
+const (
+	ModeOff    = "off"
+	ModeWarn   = "warn"
+	ModeStrict = "strict"
+)
+
+//go:embed openapi.yaml
+var spec []byte
+
+//go:embed swagger.html
+var swaggerPage []byte
+
+type Spec struct {
+	doc    *openapi3.T
+	router routers.Router
+	json   []byte
+}
+
+func Load(ctx context.Context) (*Spec, error) {
+
+	openapi3.SchemaErrorDetailsDisabled = true
+
+	loader := openapi3.NewLoader()
+
+	doc, err := loader.LoadFromData(spec)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load openapi spec: %w", err)
+	}
+
+	if err := doc.Validate(ctx); err != nil {
+		return nil, fmt.Errorf("invalid openapi spec: %w", err)
+	}
+
+	router, err := gorillamux.NewRouter(doc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build openapi router: %w", err)
+	}
+
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode openapi spec to JSON: %w", err)
+	}
+
+	return &Spec{
+		doc:    doc,
+		router: router,
+		json:   data,
+	}, nil
+}
+
+func (s *Spec) ServeJSON(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(s.json)
+}
+
+func (s *Spec) ServeDocs(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(swaggerPage)
+}
+
+func (s *Spec) Validator(mode string) func(http.Handler) http.Handler {
+
+	return func(next http.Handler) http.Handler {
+
+		if mode != ModeWarn && mode != ModeStrict {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+			err := s.validate(r)
+
+			if err != nil && mode == ModeStrict {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			} else if err != nil {
+				fmt.Println("request does not match openapi spec:", err)
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func (s *Spec) validate(r *http.Request) error {
+
+	route, pathParams, err := s.router.FindRoute(r)
+	if err != nil {
+		return fmt.Errorf("%s %s: %w", r.Method, r.URL.Path, err)
+	}
+
+	input := &openapi3filter.RequestValidationInput{
+		Request:    r,
+		PathParams: pathParams,
+		Route:      route,
+		Options: &openapi3filter.Options{
+			AuthenticationFunc: openapi3filter.NoopAuthenticationFunc,
+		},
+	}
+
+	return openapi3filter.ValidateRequest(r.Context(), input)
+}
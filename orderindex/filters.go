@@ -0,0 +1,131 @@
+package orderindex
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	ErrFilterNotExist = errors.New("saved filter does not exist")
+	ErrTooManyFilters = errors.New("too many saved filters")
+)
+
+// MaxSaved is how many filters one API key can save.
+const MaxSaved = 100
+
+// Saved is a filter stored under a name by the holder of an API key.
+type Saved struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Filter    Filter    `json:"filter"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Filters keeps saved filters in one hash per API key. Keys are hashed
+// before they are used in key names, so they never appear in the store.
+type Filters struct {
+	Client *redis.Client
+}
+
+func filtersKey(apiKey string) string {
+	sum := sha256.Sum256([]byte(apiKey))
+	return "filters:" + hex.EncodeToString(sum[:])
+}
+
+// Save stores s, replacing the filter with the same ID.
+func (fs *Filters) Save(ctx context.Context, apiKey string, s Saved) error {
+
+	data, err := json.Marshal(s)
+	if err != nil {
+		return fmt.Errorf("failed to encode filter: %w", err)
+	}
+
+	key := filtersKey(apiKey)
+
+	n, err := fs.Client.HLen(ctx, key).Result()
+	if err != nil {
+		return fmt.Errorf("failed to count filters: %w", err)
+	}
+
+	if n >= MaxSaved {
+		exists, err := fs.Client.HExists(ctx, key, s.ID).Result()
+		if err != nil {
+			return fmt.Errorf("failed to look up filter: %w", err)
+		}
+		if !exists {
+			return ErrTooManyFilters
+		}
+	}
+
+	if err := fs.Client.HSet(ctx, key, s.ID, data).Err(); err != nil {
+		return fmt.Errorf("failed to save filter: %w", err)
+	}
+
+	return nil
+}
+
+func (fs *Filters) Get(ctx context.Context, apiKey, id string) (Saved, error) {
+
+	data, err := fs.Client.HGet(ctx, filtersKey(apiKey), id).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return Saved{}, ErrFilterNotExist
+	} else if err != nil {
+		return Saved{}, fmt.Errorf("failed to get filter: %w", err)
+	}
+
+	var s Saved
+
+	if err := json.Unmarshal(data, &s); err != nil {
+		return Saved{}, fmt.Errorf("failed to decode filter: %w", err)
+	}
+
+	return s, nil
+}
+
+// List returns the filters of an API key by name.
+func (fs *Filters) List(ctx context.Context, apiKey string) ([]Saved, error) {
+
+	all, err := fs.Client.HGetAll(ctx, filtersKey(apiKey)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list filters: %w", err)
+	}
+
+	saved := make([]Saved, 0, len(all))
+
+	for id, data := range all {
+		var s Saved
+		if err := json.Unmarshal([]byte(data), &s); err != nil {
+			fmt.Printf("failed to decode filter %s: %v\n", id, err)
+			continue
+		}
+		saved = append(saved, s)
+	}
+
+	slices.SortFunc(saved, func(a, b Saved) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+
+	return saved, nil
+}
+
+func (fs *Filters) Delete(ctx context.Context, apiKey, id string) error {
+
+	n, err := fs.Client.HDel(ctx, filtersKey(apiKey), id).Result()
+	if err != nil {
+		return fmt.Errorf("failed to delete filter: %w", err)
+	} else if n == 0 {
+		return ErrFilterNotExist
+	}
+
+	return nil
+}
@@ -0,0 +1,512 @@
+// Package orderindex indexes orders by status, customer, organization,
+// tag, missed SLA, warehouse and open dispute, so they can be listed by
+// any mix of them, and keeps the filters callers saved.
+package orderindex
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/i101dev/microservices-NN/model"
+	"github.com/i101dev/microservices-NN/repository/order"
+	"github.com/redis/go-redis/v9"
+)
+
+var ErrInvalidFilter = errors.New("invalid filter")
+
+var statuses = []string{
+	model.StatusPending,
+	model.StatusReview,
+	model.StatusBackordered,
+	model.StatusShipped,
+	model.StatusCompleted,
+	model.StatusCancelled,
+}
+
+// Filter picks the orders that match every field set. Orders match a
+// filter with several tags when they have all of them. From and To bound
+// when orders were created, From included and To not.
+type Filter struct {
+	Status     string     `json:"status,omitempty"`
+	CustomerID *uuid.UUID `json:"customer_id,omitempty"`
+	OrgID      *uuid.UUID `json:"org_id,omitempty"`
+	Tags       []string   `json:"tags,omitempty"`
+	From       *time.Time `json:"from,omitempty"`
+	To         *time.Time `json:"to,omitempty"`
+	// MinTotal is the least an order costs with its tax.
+	MinTotal uint `json:"min_total,omitempty"`
+	// SLA is SLABreached to only match orders that missed their SLA.
+	SLA string `json:"sla,omitempty"`
+	// Warehouse is a warehouse the order is assigned to.
+	Warehouse string `json:"warehouse,omitempty"`
+	// HasOpenDispute only matches orders with a dispute that is not
+	// settled yet.
+	HasOpenDispute bool `json:"has_open_dispute,omitempty"`
+}
+
+// SLABreached is the only value of Filter.SLA.
+const SLABreached = "breached"
+
+func (f Filter) Empty() bool {
+	return f.Status == "" && f.CustomerID == nil && f.OrgID == nil && len(f.Tags) == 0 &&
+		f.From == nil && f.To == nil && f.MinTotal == 0 && f.SLA == "" && f.Warehouse == "" &&
+		!f.HasOpenDispute
+}
+
+func (f Filter) Validate() error {
+
+	if f.Status != "" && !slices.Contains(statuses, f.Status) {
+		return fmt.Errorf("unknown status %q: %w", f.Status, ErrInvalidFilter)
+	}
+
+	if len(f.Tags) > model.MaxTags {
+		return fmt.Errorf("at most %d tags: %w", model.MaxTags, ErrInvalidFilter)
+	}
+
+	for _, t := range f.Tags {
+		if !model.ValidTag(t) {
+			return fmt.Errorf("invalid tag %q: %w", t, ErrInvalidFilter)
+		}
+	}
+
+	if f.SLA != "" && f.SLA != SLABreached {
+		return fmt.Errorf("unknown sla %q: %w", f.SLA, ErrInvalidFilter)
+	}
+
+	if strings.Contains(f.Warehouse, ",") {
+		return fmt.Errorf("invalid warehouse %q: %w", f.Warehouse, ErrInvalidFilter)
+	}
+
+	if f.From != nil && f.To != nil && !f.From.Before(*f.To) {
+		return fmt.Errorf("from must be before to: %w", ErrInvalidFilter)
+	}
+
+	return nil
+}
+
+// sets returns the sets an order must be in to match f.
+func (f Filter) sets() []string {
+
+	var keys []string
+
+	if f.Status != "" {
+		keys = append(keys, statusKey(f.Status))
+	}
+
+	if f.CustomerID != nil {
+		keys = append(keys, customerKey(f.CustomerID.String()))
+	}
+
+	if f.OrgID != nil {
+		keys = append(keys, orgKey(f.OrgID.String()))
+	}
+
+	for _, t := range f.Tags {
+		keys = append(keys, tagKey(t))
+	}
+
+	if f.SLA == SLABreached {
+		keys = append(keys, breachedKey)
+	}
+
+	if f.Warehouse != "" {
+		keys = append(keys, warehouseKey(f.Warehouse))
+	}
+
+	if f.HasOpenDispute {
+		keys = append(keys, disputedKey)
+	}
+
+	return keys
+}
+
+// id names the search for f, so the pages of one listing can share it.
+func (f Filter) id() string {
+	data, _ := json.Marshal(f)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:8])
+}
+
+func statusKey(status string) string {
+	return "orders:status:" + status
+}
+
+func customerKey(customer string) string {
+	return "orders:customer:" + customer
+}
+
+func orgKey(org string) string {
+	return "orders:org:" + org
+}
+
+func tagKey(tag string) string {
+	return "orders:tag:" + tag
+}
+
+func warehouseKey(warehouse string) string {
+	return "orders:warehouse:" + warehouse
+}
+
+// breachedKey holds the orders that missed their SLA.
+const breachedKey = "orders:sla:breached"
+
+// disputedKey holds the orders with an open dispute.
+const disputedKey = "orders:dispute:open"
+
+// createdKey and totalKey score every order by when it was created and
+// what it costs.
+const (
+	createdKey = "orders:created"
+	totalKey   = "orders:total"
+)
+
+func searchKey(id, step string) string {
+	return "orders:search:" + id + ":" + step
+}
+
+// disputeOpen marks orders with an open dispute where they were indexed.
+const disputeOpen = "open"
+
+// indexedKey remembers where an order was indexed, so it can be taken off
+// sets it no longer belongs in without reading the order it was.
+func indexedKey(id uint64) string {
+	return fmt.Sprintf("orders:indexed:%d", id)
+}
+
+// Index keeps one set of order IDs per status, customer, organization, tag
+// and warehouse, one of the orders that missed their SLA, one of the
+// orders with an open dispute, and sorted sets of every order by creation
+// time and total.
+type Index struct {
+	Client *redis.Client
+	// SearchTTL is how long the result of a search is kept for the pages
+	// after its first. Zero keeps it for 30 seconds.
+	SearchTTL time.Duration
+}
+
+type entry struct {
+	status     string
+	customer   string
+	org        string
+	tags       []string
+	breached   bool
+	warehouses []string
+	disputed   bool
+}
+
+func (x *Index) indexed(ctx context.Context, id uint64) (entry, error) {
+
+	fields, err := x.Client.HGetAll(ctx, indexedKey(id)).Result()
+	if err != nil {
+		return entry{}, fmt.Errorf("failed to read order index: %w", err)
+	}
+
+	e := entry{status: fields["status"], customer: fields["customer"], org: fields["org"], breached: fields["sla"] == SLABreached, disputed: fields["dispute"] == disputeOpen}
+	if fields["tags"] != "" {
+		e.tags = strings.Split(fields["tags"], ",")
+	}
+	if fields["warehouses"] != "" {
+		e.warehouses = strings.Split(fields["warehouses"], ",")
+	}
+
+	return e, nil
+}
+
+// Put indexes o under its status, customer, organization, tags, missed
+// SLA, warehouses and open dispute, and takes it off the ones it had
+// before.
+func (x *Index) Put(ctx context.Context, o model.Order) error {
+
+	old, err := x.indexed(ctx, o.OrderID)
+	if err != nil {
+		return err
+	}
+
+	cur := entry{status: o.Status(), customer: o.CustomerID.String(), tags: o.Tags, breached: o.SLABreached, warehouses: o.Warehouses(), disputed: o.HasOpenDispute()}
+	if o.OrgID != nil {
+		cur.org = o.OrgID.String()
+	}
+	member := strconv.FormatUint(o.OrderID, 10)
+
+	pipe := x.Client.TxPipeline()
+
+	if old.status != "" && old.status != cur.status {
+		pipe.SRem(ctx, statusKey(old.status), member)
+	}
+	pipe.SAdd(ctx, statusKey(cur.status), member)
+
+	if old.customer != "" && old.customer != cur.customer {
+		pipe.SRem(ctx, customerKey(old.customer), member)
+	}
+	pipe.SAdd(ctx, customerKey(cur.customer), member)
+
+	if old.org != "" && old.org != cur.org {
+		pipe.SRem(ctx, orgKey(old.org), member)
+	}
+	if cur.org != "" {
+		pipe.SAdd(ctx, orgKey(cur.org), member)
+	}
+
+	for _, t := range old.tags {
+		if !slices.Contains(cur.tags, t) {
+			pipe.SRem(ctx, tagKey(t), member)
+		}
+	}
+	for _, t := range cur.tags {
+		pipe.SAdd(ctx, tagKey(t), member)
+	}
+
+	if cur.breached {
+		pipe.SAdd(ctx, breachedKey, member)
+	} else if old.breached {
+		pipe.SRem(ctx, breachedKey, member)
+	}
+
+	for _, w := range old.warehouses {
+		if !slices.Contains(cur.warehouses, w) {
+			pipe.SRem(ctx, warehouseKey(w), member)
+		}
+	}
+	for _, w := range cur.warehouses {
+		pipe.SAdd(ctx, warehouseKey(w), member)
+	}
+
+	if cur.disputed {
+		pipe.SAdd(ctx, disputedKey, member)
+	} else if old.disputed {
+		pipe.SRem(ctx, disputedKey, member)
+	}
+
+	var sla, dispute string
+	if cur.breached {
+		sla = SLABreached
+	}
+	if cur.disputed {
+		dispute = disputeOpen
+	}
+
+	if o.CreatedAt != nil {
+		pipe.ZAdd(ctx, createdKey, redis.Z{Score: float64(o.CreatedAt.UnixMilli()), Member: member})
+	}
+	pipe.ZAdd(ctx, totalKey, redis.Z{Score: float64(o.Total()), Member: member})
+
+	pipe.HSet(ctx, indexedKey(o.OrderID), "status", cur.status, "customer", cur.customer, "org", cur.org, "tags", strings.Join(cur.tags, ","), "sla", sla, "warehouses", strings.Join(cur.warehouses, ","), "dispute", dispute)
+
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to index order: %w", err)
+	}
+
+	return nil
+}
+
+// Remove takes a deleted order off every set it was in.
+func (x *Index) Remove(ctx context.Context, id uint64) error {
+
+	old, err := x.indexed(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	member := strconv.FormatUint(id, 10)
+
+	pipe := x.Client.TxPipeline()
+
+	if old.status != "" {
+		pipe.SRem(ctx, statusKey(old.status), member)
+	}
+	if old.customer != "" {
+		pipe.SRem(ctx, customerKey(old.customer), member)
+	}
+	if old.org != "" {
+		pipe.SRem(ctx, orgKey(old.org), member)
+	}
+	for _, t := range old.tags {
+		pipe.SRem(ctx, tagKey(t), member)
+	}
+	if old.breached {
+		pipe.SRem(ctx, breachedKey, member)
+	}
+	for _, w := range old.warehouses {
+		pipe.SRem(ctx, warehouseKey(w), member)
+	}
+	if old.disputed {
+		pipe.SRem(ctx, disputedKey, member)
+	}
+	pipe.ZRem(ctx, createdKey, member)
+	pipe.ZRem(ctx, totalKey, member)
+	pipe.Del(ctx, indexedKey(id))
+
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to drop order from index: %w", err)
+	}
+
+	return nil
+}
+
+// Rebuild indexes every order in repo, for orders written before the
+// index was kept or while it could not be updated.
+func (x *Index) Rebuild(ctx context.Context, repo order.Repository) (int, error) {
+
+	var n int
+
+	err := order.ForEachPage(ctx, repo, 100, func(orders []model.Order) error {
+		for _, o := range orders {
+			if err := x.Put(ctx, o); err != nil {
+				return err
+			}
+			n++
+		}
+		return nil
+	})
+
+	return n, err
+}
+
+// Find returns a page of the IDs of orders matching f, oldest first, and
+// the offset of the next page, which is zero after the last one. The
+// search runs in Redis: the sets are intersected into a temporary key,
+// which is intersected with the total and creation time sorted sets and
+// cut down to their ranges. The result is kept for SearchTTL, and later
+// pages read it rather than search again, so a listing is one snapshot.
+// The first page always searches afresh.
+func (x *Index) Find(ctx context.Context, f Filter, offset, size uint64) ([]uint64, uint64, error) {
+
+	if f.Empty() {
+		return nil, 0, fmt.Errorf("nothing to filter by: %w", ErrInvalidFilter)
+	}
+
+	id := f.id()
+	result := searchKey(id, "result")
+
+	found := int64(0)
+	if offset > 0 {
+		n, err := x.Client.Exists(ctx, result).Result()
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to look up search: %w", err)
+		}
+		found = n
+	}
+
+	if found == 0 {
+		if err := x.search(ctx, f, id); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	pipe := x.Client.Pipeline()
+	members := pipe.ZRange(ctx, result, int64(offset), int64(offset+size)-1)
+	count := pipe.ZCard(ctx, result)
+
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, 0, fmt.Errorf("failed to read search: %w", err)
+	}
+
+	ids := make([]uint64, 0, len(members.Val()))
+
+	for _, m := range members.Val() {
+		id, err := strconv.ParseUint(m, 10, 64)
+		if err != nil {
+			continue
+		}
+		ids = append(ids, id)
+	}
+
+	var next uint64
+	if end := offset + size; end < uint64(count.Val()) {
+		next = end
+	}
+
+	return ids, next, nil
+}
+
+// search stores the orders matching f in a sorted set scored by creation
+// time, in one transaction.
+func (x *Index) search(ctx context.Context, f Filter, id string) error {
+
+	ttl := x.SearchTTL
+	if ttl == 0 {
+		ttl = 30 * time.Second
+	}
+
+	pipe := x.Client.TxPipeline()
+
+	base := createdKey
+	var temp []string
+
+	if sets := f.sets(); len(sets) > 0 {
+		base = searchKey(id, "sets")
+		temp = append(temp, base)
+		pipe.SInterStore(ctx, base, sets...)
+	}
+
+	if f.MinTotal > 0 {
+		key := searchKey(id, "total")
+		temp = append(temp, key)
+		pipe.ZInterStore(ctx, key, &redis.ZStore{Keys: []string{base, totalKey}, Weights: []float64{0, 1}})
+		pipe.ZRemRangeByScore(ctx, key, "-inf", "("+strconv.FormatUint(uint64(f.MinTotal), 10))
+		base = key
+	}
+
+	result := searchKey(id, "result")
+	pipe.ZInterStore(ctx, result, &redis.ZStore{Keys: []string{base, createdKey}, Weights: []float64{0, 1}})
+
+	if f.From != nil {
+		pipe.ZRemRangeByScore(ctx, result, "-inf", "("+strconv.FormatInt(f.From.UnixMilli(), 10))
+	}
+	if f.To != nil {
+		pipe.ZRemRangeByScore(ctx, result, strconv.FormatInt(f.To.UnixMilli(), 10), "+inf")
+	}
+
+	pipe.Expire(ctx, result, ttl)
+
+	if len(temp) > 0 {
+		pipe.Del(ctx, temp...)
+	}
+
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to search orders: %w", err)
+	}
+
+	return nil
+}
+
+// Intercept keeps the index up to date as orders are written. Failing to
+// update it is logged but never fails the write.
+func (x *Index) Intercept(ctx context.Context, call *order.Call, next order.Handler) error {
+
+	if err := next(ctx, call); err != nil {
+		return err
+	}
+
+	var orders []model.Order
+
+	switch call.Op {
+	case order.OpInsert, order.OpUpdate:
+		orders = []model.Order{call.Order}
+	case order.OpInsertAll:
+		orders = call.Orders
+	case order.OpApply:
+		orders = append(append(orders, call.Batch.Insert...), call.Batch.Update...)
+	case order.OpDeleteByID:
+		if err := x.Remove(ctx, call.ID); err != nil {
+			fmt.Println("failed to drop order from index:", err)
+		}
+	}
+
+	for _, o := range orders {
+		if err := x.Put(ctx, o); err != nil {
+			fmt.Println("failed to index order:", err)
+		}
+	}
+
+	return nil
+}
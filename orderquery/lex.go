@@ -0,0 +1,124 @@
+package orderquery
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokIdent
+	tokString
+	tokNumber
+	tokDuration
+	tokOp
+	tokLParen
+	tokRParen
+	tokComma
+	tokPlus
+	tokMinus
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+func (t token) String() string {
+	if t.kind == tokEOF {
+		return "end of query"
+	}
+	return fmt.Sprintf("%q", t.text)
+}
+
+// lex splits src into tokens. Durations are numbers followed by units, as
+// in 24h or 7d.
+func lex(src string) ([]token, error) {
+
+	var tokens []token
+
+	for i := 0; i < len(src); {
+		c := src[i]
+
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+
+		case c == '(':
+			tokens = append(tokens, token{tokLParen, "(", i})
+			i++
+
+		case c == ')':
+			tokens = append(tokens, token{tokRParen, ")", i})
+			i++
+
+		case c == ',':
+			tokens = append(tokens, token{tokComma, ",", i})
+			i++
+
+		case c == '+':
+			tokens = append(tokens, token{tokPlus, "+", i})
+			i++
+
+		case c == '-':
+			tokens = append(tokens, token{tokMinus, "-", i})
+			i++
+
+		case c == '=' || c == '!' || c == '<' || c == '>':
+			op := string(c)
+			if i+1 < len(src) && src[i+1] == '=' {
+				op += "="
+			}
+			if op == "!" {
+				return nil, invalid(i, "expected != after !")
+			}
+			tokens = append(tokens, token{tokOp, op, i})
+			i += len(op)
+
+		case c == '"':
+			var b strings.Builder
+			j := i + 1
+			for ; j < len(src) && src[j] != '"'; j++ {
+				if src[j] == '\\' && j+1 < len(src) {
+					j++
+				}
+				b.WriteByte(src[j])
+			}
+			if j == len(src) {
+				return nil, invalid(i, "unterminated string")
+			}
+			tokens = append(tokens, token{tokString, b.String(), i})
+			i = j + 1
+
+		case c >= '0' && c <= '9':
+			j := i
+			for j < len(src) && src[j] >= '0' && src[j] <= '9' {
+				j++
+			}
+			kind := tokNumber
+			for j < len(src) && (unicode.IsLetter(rune(src[j])) || src[j] >= '0' && src[j] <= '9') {
+				kind = tokDuration
+				j++
+			}
+			tokens = append(tokens, token{kind, src[i:j], i})
+			i = j
+
+		case c == '_' || unicode.IsLetter(rune(c)):
+			j := i
+			for j < len(src) && (src[j] == '_' || unicode.IsLetter(rune(src[j])) || src[j] >= '0' && src[j] <= '9') {
+				j++
+			}
+			tokens = append(tokens, token{tokIdent, src[i:j], i})
+			i = j
+
+		default:
+			return nil, invalid(i, fmt.Sprintf("unexpected %q", c))
+		}
+	}
+
+	return append(tokens, token{tokEOF, "", len(src)}), nil
+}
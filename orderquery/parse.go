@@ -0,0 +1,346 @@
+package orderquery
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// MaxLength is the longest query accepted.
+const MaxLength = 2048
+
+// Parse parses src, reading now() as now. The grammar is
+//
+//	query      = or
+//	or         = and { OR and }
+//	and        = unary { AND unary }
+//	unary      = NOT unary | "(" query ")" | comparison
+//	comparison = field op value | field [NOT] IN "(" value { "," value } ")"
+//	value      = string | number | now() [ ("+" | "-") duration ]
+//
+// with the keywords in any case. Times are given as RFC 3339 or YYYY-MM-DD
+// strings, or from now(), and durations in the units of Go and d for days.
+// An empty query matches every order.
+func Parse(src string, now time.Time) (*Query, error) {
+
+	if len(src) > MaxLength {
+		return nil, fmt.Errorf("longer than %d bytes: %w", MaxLength, ErrInvalidQuery)
+	}
+
+	if strings.TrimSpace(src) == "" {
+		return &Query{expr: all{}}, nil
+	}
+
+	tokens, err := lex(src)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{tokens: tokens, now: now}
+
+	e, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+
+	if t := p.peek(); t.kind != tokEOF {
+		return nil, invalid(t.pos, "unexpected "+t.String())
+	}
+
+	return &Query{expr: e}, nil
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+	now    time.Time
+	depth  int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) keyword(word string) bool {
+
+	if t := p.peek(); t.kind == tokIdent && strings.EqualFold(t.text, word) {
+		p.pos++
+		return true
+	}
+
+	return false
+}
+
+func (p *parser) expect(kind tokenKind, what string) (token, error) {
+
+	t := p.next()
+	if t.kind != kind {
+		return t, invalid(t.pos, fmt.Sprintf("expected %s, found %s", what, t))
+	}
+
+	return t, nil
+}
+
+func (p *parser) or() (expr, error) {
+
+	left, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.keyword("or") {
+		right, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		left = or{left, right}
+	}
+
+	return left, nil
+}
+
+func (p *parser) and() (expr, error) {
+
+	left, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.keyword("and") {
+		right, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		left = and{left, right}
+	}
+
+	return left, nil
+}
+
+func (p *parser) unary() (expr, error) {
+
+	// Deep nesting is never needed and would only grow the stack.
+	if p.depth++; p.depth > 50 {
+		return nil, invalid(p.peek().pos, "nested too deep")
+	}
+	defer func() { p.depth-- }()
+
+	if p.keyword("not") {
+		e, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return not{e}, nil
+	}
+
+	if p.peek().kind == tokLParen {
+		p.next()
+		e, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if _, err := p.expect(tokRParen, ")"); err != nil {
+			return nil, err
+		}
+		return e, nil
+	}
+
+	return p.comparison()
+}
+
+func (p *parser) comparison() (expr, error) {
+
+	name, err := p.expect(tokIdent, "a field")
+	if err != nil {
+		return nil, err
+	}
+
+	f, ok := fields[strings.ToLower(name.text)]
+	if !ok {
+		return nil, invalid(name.pos, fmt.Sprintf("unknown field %q, expected one of %s", name.text, strings.Join(Fields(), ", ")))
+	}
+
+	c := comparison{field: f}
+
+	t := p.peek()
+
+	switch {
+	case t.kind == tokOp:
+		p.next()
+		c.op = t.text
+
+		if f.kind != kindNumber && f.kind != kindTime && c.op != "=" && c.op != "!=" {
+			return nil, invalid(t.pos, fmt.Sprintf("%s can only be compared with = and !=", f.name))
+		}
+
+		v, err := p.value(f)
+		if err != nil {
+			return nil, err
+		}
+		c.values = []value{v}
+
+	case p.keyword("in"):
+		c.op = "in"
+		if c.values, err = p.list(f, t); err != nil {
+			return nil, err
+		}
+
+	case p.keyword("not"):
+		if !p.keyword("in") {
+			return nil, invalid(p.peek().pos, "expected in after not, found "+p.peek().String())
+		}
+		c.op = "not in"
+		if c.values, err = p.list(f, t); err != nil {
+			return nil, err
+		}
+
+	default:
+		return nil, invalid(t.pos, "expected a comparison after "+f.name+", found "+t.String())
+	}
+
+	return c, nil
+}
+
+// list reads the values of an in, which started at t.
+func (p *parser) list(f field, t token) ([]value, error) {
+
+	if f.kind == kindTime {
+		return nil, invalid(t.pos, fmt.Sprintf("%s cannot be used with in", f.name))
+	}
+
+	if _, err := p.expect(tokLParen, "("); err != nil {
+		return nil, err
+	}
+
+	var values []value
+
+	for {
+		v, err := p.value(f)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+
+		if p.peek().kind != tokComma {
+			break
+		}
+		p.next()
+	}
+
+	if _, err := p.expect(tokRParen, ")"); err != nil {
+		return nil, err
+	}
+
+	return values, nil
+}
+
+func (p *parser) value(f field) (value, error) {
+
+	t := p.next()
+
+	switch f.kind {
+	case kindString, kindSet:
+		if t.kind != tokString {
+			return value{}, invalid(t.pos, f.name+" takes a quoted string")
+		}
+		if f.values != nil && !slices.Contains(f.values, t.text) {
+			return value{}, invalid(t.pos, fmt.Sprintf("unknown %s %q", f.name, t.text))
+		}
+		return value{s: t.text}, nil
+
+	case kindUUID:
+		id, err := uuid.Parse(t.text)
+		if t.kind != tokString || err != nil {
+			return value{}, invalid(t.pos, f.name+" takes a quoted uuid")
+		}
+		return value{s: id.String()}, nil
+
+	case kindNumber:
+		n, err := strconv.ParseUint(t.text, 10, 64)
+		if t.kind != tokNumber || err != nil {
+			return value{}, invalid(t.pos, f.name+" takes a whole number")
+		}
+		return value{n: n}, nil
+
+	case kindTime:
+		if t.kind == tokString {
+			if ts, err := time.Parse(time.RFC3339, t.text); err == nil {
+				return value{t: ts}, nil
+			}
+			if ts, err := time.Parse(time.DateOnly, t.text); err == nil {
+				return value{t: ts}, nil
+			}
+			return value{}, invalid(t.pos, f.name+" takes an RFC 3339 time or a YYYY-MM-DD date")
+		}
+
+		if t.kind != tokIdent || !strings.EqualFold(t.text, "now") {
+			return value{}, invalid(t.pos, f.name+" takes a quoted time or now()")
+		}
+		if _, err := p.expect(tokLParen, "("); err != nil {
+			return value{}, err
+		}
+		if _, err := p.expect(tokRParen, ")"); err != nil {
+			return value{}, err
+		}
+
+		ts := p.now
+
+		if sign := p.peek(); sign.kind == tokPlus || sign.kind == tokMinus {
+			p.next()
+			d, err := p.duration()
+			if err != nil {
+				return value{}, err
+			}
+			if sign.kind == tokMinus {
+				d = -d
+			}
+			ts = ts.Add(d)
+		}
+
+		return value{t: ts}, nil
+	}
+
+	return value{}, invalid(t.pos, "unexpected "+t.String())
+}
+
+// duration reads a Go duration, which may start with a number of days.
+func (p *parser) duration() (time.Duration, error) {
+
+	t := p.next()
+	if t.kind != tokDuration && t.kind != tokNumber {
+		return 0, invalid(t.pos, "expected a duration such as 24h, found "+t.String())
+	}
+
+	s := t.text
+	var d time.Duration
+
+	if days, rest, ok := strings.Cut(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, invalid(t.pos, fmt.Sprintf("invalid duration %q", s))
+		}
+		d, s = time.Duration(n)*24*time.Hour, rest
+	}
+
+	if s != "" {
+		rest, err := time.ParseDuration(s)
+		if err != nil {
+			return 0, invalid(t.pos, fmt.Sprintf("invalid duration %q", t.text))
+		}
+		d += rest
+	}
+
+	return d, nil
+}
@@ -0,0 +1,163 @@
+package orderquery
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/i101dev/microservices-NN/model"
+	"github.com/i101dev/microservices-NN/orderindex"
+	"github.com/i101dev/microservices-NN/repository/order"
+)
+
+const (
+	PlanIndex = "index"
+	PlanScan  = "scan"
+)
+
+// filter returns an index filter that every order matching q matches too,
+// from the comparisons q needs all of. It may match more orders than q,
+// which are checked against q afterwards.
+func (q *Query) filter() orderindex.Filter {
+
+	var f orderindex.Filter
+
+	var walk func(e expr)
+	walk = func(e expr) {
+		switch e := e.(type) {
+		case and:
+			walk(e.left)
+			walk(e.right)
+		case comparison:
+			narrow(&f, e)
+		}
+	}
+
+	walk(q.expr)
+
+	return f
+}
+
+func narrow(f *orderindex.Filter, c comparison) {
+
+	single := c.op == "=" || (c.op == "in" && len(c.values) == 1)
+
+	switch c.field.name {
+	case "status":
+		if single && f.Status == "" {
+			f.Status = c.values[0].s
+		}
+
+	case "customer_id":
+		if single && f.CustomerID == nil {
+			id := uuid.MustParse(c.values[0].s)
+			f.CustomerID = &id
+		}
+
+	case "tag":
+		if single && len(f.Tags) < model.MaxTags && !slices.Contains(f.Tags, c.values[0].s) {
+			f.Tags = append(f.Tags, c.values[0].s)
+		}
+
+	case "total":
+		min := c.values[0].n
+		if c.op == ">" {
+			min++
+		}
+		if (c.op == ">" || c.op == ">=") && min > uint64(f.MinTotal) && min <= uint64(^uint(0)) {
+			f.MinTotal = uint(min)
+		}
+
+	case "created_at":
+		// The index keeps times to the millisecond, so the bounds are
+		// widened by one to be sure to keep every match.
+		t := c.values[0].t
+		switch c.op {
+		case ">", ">=":
+			if f.From == nil || t.After(*f.From) {
+				f.From = &t
+			}
+		case "<", "<=":
+			t = t.Add(time.Millisecond)
+			if f.To == nil || t.Before(*f.To) {
+				f.To = &t
+			}
+		}
+	}
+}
+
+// Runner runs queries against the store.
+type Runner struct {
+	Repo order.Repository
+	// Index, when set, narrows down the orders queries read.
+	Index *orderindex.Index
+}
+
+// Plan tells whether q reads the orders the index finds for its filter,
+// or every order.
+func (r *Runner) Plan(q *Query) string {
+
+	if r.Index == nil {
+		return PlanScan
+	}
+
+	if f := q.filter(); f.Empty() || f.Validate() != nil {
+		return PlanScan
+	}
+
+	return PlanIndex
+}
+
+// Run calls fn with every order matching q until fn fails. The index
+// lists orders oldest first; scans go through a snapshot of the orders in
+// no particular order.
+func (r *Runner) Run(ctx context.Context, q *Query, fn func(model.Order) error) error {
+
+	if r.Plan(q) == PlanScan {
+		return order.ForEachSnapshotPage(ctx, r.Repo, 200, func(orders []model.Order) error {
+			for _, o := range orders {
+				if !q.Match(o) {
+					continue
+				}
+				if err := fn(o); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	}
+
+	f := q.filter()
+
+	var offset uint64
+
+	for {
+		ids, next, err := r.Index.Find(ctx, f, offset, 200)
+		if err != nil {
+			return err
+		}
+
+		for _, id := range ids {
+			o, err := r.Repo.FindByID(ctx, id)
+			if errors.Is(err, order.ErrNotExist) {
+				continue
+			} else if err != nil {
+				return err
+			}
+
+			if !q.Match(o) {
+				continue
+			}
+			if err := fn(o); err != nil {
+				return err
+			}
+		}
+
+		offset = next
+		if offset == 0 {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,201 @@
+// Package orderquery parses the filter expressions of the admin order
+// listing, such as
+//
+//	status = "shipped" AND total > 100 AND created_at > now()-24h
+//
+// and runs them against the order index where it can, or by scanning every
+// order where it cannot.
+package orderquery
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/i101dev/microservices-NN/model"
+)
+
+var ErrInvalidQuery = errors.New("invalid query")
+
+func invalid(pos int, msg string) error {
+	return fmt.Errorf("at %d: %s: %w", pos, msg, ErrInvalidQuery)
+}
+
+type kind int
+
+const (
+	kindString kind = iota
+	kindUUID
+	kindNumber
+	kindTime
+	// kindSet fields hold several values, and = asks whether one of them
+	// is the one given.
+	kindSet
+)
+
+type field struct {
+	name string
+	kind kind
+	// values, when set, are the only values the field takes.
+	values []string
+}
+
+var fields = map[string]field{
+	"order_id":    {name: "order_id", kind: kindNumber},
+	"status":      {name: "status", kind: kindString, values: statuses},
+	"customer_id": {name: "customer_id", kind: kindUUID},
+	"tag":         {name: "tag", kind: kindSet},
+	"total":       {name: "total", kind: kindNumber},
+	"line_items":  {name: "line_items", kind: kindNumber},
+	"created_at":  {name: "created_at", kind: kindTime},
+	"updated_at":  {name: "updated_at", kind: kindTime},
+}
+
+var statuses = []string{
+	model.StatusPending,
+	model.StatusReview,
+	model.StatusBackordered,
+	model.StatusShipped,
+	model.StatusCompleted,
+	model.StatusCancelled,
+}
+
+// Fields are the names a query can filter on.
+func Fields() []string {
+
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	return names
+}
+
+type value struct {
+	s string
+	n uint64
+	t time.Time
+}
+
+type expr interface {
+	match(o model.Order) bool
+}
+
+type and struct{ left, right expr }
+
+type or struct{ left, right expr }
+
+type not struct{ e expr }
+
+type all struct{}
+
+func (all) match(model.Order) bool     { return true }
+func (e and) match(o model.Order) bool { return e.left.match(o) && e.right.match(o) }
+func (e or) match(o model.Order) bool  { return e.left.match(o) || e.right.match(o) }
+func (e not) match(o model.Order) bool { return !e.e.match(o) }
+
+// comparison is a field against one value, or against a list of them for
+// in and not in.
+type comparison struct {
+	field  field
+	op     string
+	values []value
+}
+
+func (c comparison) match(o model.Order) bool {
+
+	switch c.field.kind {
+	case kindString:
+		s := o.Status()
+		return c.is(func(v value) bool { return v.s == s })
+
+	case kindUUID:
+		s := o.CustomerID.String()
+		return c.is(func(v value) bool { return v.s == s })
+
+	case kindSet:
+		return c.is(func(v value) bool { return slices.Contains(o.Tags, v.s) })
+
+	case kindNumber:
+		var n uint64
+		switch c.field.name {
+		case "order_id":
+			n = o.OrderID
+		case "total":
+			n = uint64(o.Total())
+		case "line_items":
+			n = uint64(len(o.LineItems))
+		}
+		if c.op == "in" || c.op == "not in" {
+			return c.is(func(v value) bool { return v.n == n })
+		}
+		return compare(c.op, cmpUint(n, c.values[0].n))
+
+	case kindTime:
+		t := o.CreatedAt
+		if c.field.name == "updated_at" {
+			t = o.UpdatedAt
+		}
+		// Orders with no time match no comparison with it.
+		if t == nil {
+			return false
+		}
+		return compare(c.op, t.Compare(c.values[0].t))
+	}
+
+	return false
+}
+
+// is applies =, !=, in and not in, with eq telling whether the field holds
+// a value.
+func (c comparison) is(eq func(value) bool) bool {
+
+	found := slices.ContainsFunc(c.values, eq)
+
+	if c.op == "!=" || c.op == "not in" {
+		return !found
+	}
+	return found
+}
+
+func cmpUint(a, b uint64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func compare(op string, c int) bool {
+
+	switch op {
+	case "=":
+		return c == 0
+	case "!=":
+		return c != 0
+	case "<":
+		return c < 0
+	case "<=":
+		return c <= 0
+	case ">":
+		return c > 0
+	case ">=":
+		return c >= 0
+	}
+
+	return false
+}
+
+// Query is a parsed filter expression.
+type Query struct {
+	expr expr
+}
+
+// Match tells whether o matches q.
+func (q *Query) Match(o model.Order) bool {
+	return q.expr.match(o)
+}
@@ -0,0 +1,329 @@
+package ordertest
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/i101dev/microservices-NN/model"
+	"github.com/i101dev/microservices-NN/repository/order"
+)
+
+type Op = order.Op
+
+const (
+	OpInsert     = order.OpInsert
+	OpInsertAll  = order.OpInsertAll
+	OpFindByID   = order.OpFindByID
+	OpDeleteByID = order.OpDeleteByID
+	OpUpdate     = order.OpUpdate
+	OpFindAll    = order.OpFindAll
+	OpApply      = order.OpApply
+)
+
+type FakeRepo struct {
+	mu      sync.Mutex
+	orders  map[uint64]model.Order
+	history map[uint64][]model.HistoryEntry
+	errs    map[Op]error
+	latency map[Op]time.Duration
+	calls   map[Op]int
+}
+
+var _ order.Repository = (*FakeRepo)(nil)
+
+func NewFakeRepo(orders ...model.Order) *FakeRepo {
+
+	f := &FakeRepo{
+		orders:  map[uint64]model.Order{},
+		history: map[uint64][]model.HistoryEntry{},
+		errs:    map[Op]error{},
+		latency: map[Op]time.Duration{},
+		calls:   map[Op]int{},
+	}
+
+	for _, o := range orders {
+		f.orders[o.OrderID] = o.Clone()
+	}
+
+	return f
+}
+
+// FailWith makes every call to op return err. A nil err clears the failure.
+func (f *FakeRepo) FailWith(op Op, err error) {
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err == nil {
+		delete(f.errs, op)
+		return
+	}
+
+	f.errs[op] = err
+}
+
+func (f *FakeRepo) SetLatency(op Op, d time.Duration) {
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.latency[op] = d
+}
+
+func (f *FakeRepo) Calls(op Op) int {
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.calls[op]
+}
+
+func (f *FakeRepo) Orders() []model.Order {
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	orders := make([]model.Order, 0, len(f.orders))
+
+	for _, id := range f.sortedIDs() {
+		orders = append(orders, f.orders[id].Clone())
+	}
+
+	return orders
+}
+
+func (f *FakeRepo) Insert(ctx context.Context, o model.Order) error {
+
+	if err := f.enter(ctx, OpInsert); err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, exists := f.orders[o.OrderID]; exists {
+		return order.ErrExist
+	}
+
+	f.orders[o.OrderID] = o.Clone()
+
+	return nil
+}
+
+func (f *FakeRepo) InsertAll(ctx context.Context, orders []model.Order) error {
+
+	if err := f.enter(ctx, OpInsertAll); err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, o := range orders {
+		if _, exists := f.orders[o.OrderID]; exists {
+			return &order.Error{
+				Kind: order.ErrExist,
+				Op:   "insert all",
+				Key:  fmt.Sprintf("order:%d", o.OrderID),
+				ID:   o.OrderID,
+			}
+		}
+	}
+
+	for _, o := range orders {
+		f.orders[o.OrderID] = o.Clone()
+	}
+
+	return nil
+}
+
+func (f *FakeRepo) FindByID(ctx context.Context, id uint64) (model.Order, error) {
+
+	if err := f.enter(ctx, OpFindByID); err != nil {
+		return model.Order{}, err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	o, exists := f.orders[id]
+	if !exists {
+		return model.Order{}, order.ErrNotExist
+	}
+
+	return o.Clone(), nil
+}
+
+func (f *FakeRepo) DeleteByID(ctx context.Context, id uint64) error {
+
+	if err := f.enter(ctx, OpDeleteByID); err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, exists := f.orders[id]; !exists {
+		return order.ErrNotExist
+	}
+
+	delete(f.orders, id)
+	delete(f.history, id)
+
+	return nil
+}
+
+func (f *FakeRepo) Update(ctx context.Context, o model.Order) error {
+
+	if err := f.enter(ctx, OpUpdate); err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, exists := f.orders[o.OrderID]; !exists {
+		return order.ErrNotExist
+	}
+
+	f.orders[o.OrderID] = o.Clone()
+
+	return nil
+}
+
+// FindAll pages through orders sorted by ID. The cursor is an offset into
+// that ordering, so it stays stable as long as no orders are added.
+func (f *FakeRepo) FindAll(ctx context.Context, page order.FindAllPage) (order.FindResult, error) {
+
+	if err := f.enter(ctx, OpFindAll); err != nil {
+		return order.FindResult{}, err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	ids := f.sortedIDs()
+
+	size := page.Size
+	if size == 0 {
+		size = 10
+	}
+
+	start := page.Offset
+	if start > uint64(len(ids)) {
+		start = uint64(len(ids))
+	}
+
+	end := start + size
+	if end > uint64(len(ids)) {
+		end = uint64(len(ids))
+	}
+
+	res := order.FindResult{
+		Orders: make([]model.Order, 0, end-start),
+	}
+
+	for _, id := range ids[start:end] {
+		res.Orders = append(res.Orders, f.orders[id].Clone())
+	}
+
+	if end < uint64(len(ids)) {
+		res.Cursor = end
+	}
+
+	return res, nil
+}
+
+func (f *FakeRepo) Apply(ctx context.Context, batch order.Batch) error {
+
+	if err := f.enter(ctx, OpApply); err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, o := range batch.Insert {
+		if _, exists := f.orders[o.OrderID]; exists {
+			return order.ErrExist
+		}
+	}
+
+	for _, o := range batch.Update {
+		stored, exists := f.orders[o.OrderID]
+		if !exists {
+			return order.ErrNotExist
+		}
+		want, ok := batch.Expect[o.OrderID]
+		if ok && !sameTime(stored.UpdatedAt, want) {
+			return order.ErrConflict
+		}
+	}
+
+	for _, o := range batch.Insert {
+		f.orders[o.OrderID] = o.Clone()
+	}
+
+	for _, o := range batch.Update {
+		f.orders[o.OrderID] = o.Clone()
+	}
+
+	for _, entry := range batch.History {
+		f.history[entry.OrderID] = append(f.history[entry.OrderID], entry)
+	}
+
+	return nil
+}
+
+// History returns what Apply recorded for the order.
+func (f *FakeRepo) History(id uint64) []model.HistoryEntry {
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return append([]model.HistoryEntry{}, f.history[id]...)
+}
+
+func (f *FakeRepo) enter(ctx context.Context, op Op) error {
+
+	f.mu.Lock()
+	f.calls[op]++
+	err := f.errs[op]
+	delay := f.latency[op]
+	f.mu.Unlock()
+
+	if delay > 0 {
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+
+	return err
+}
+
+func (f *FakeRepo) sortedIDs() []uint64 {
+
+	ids := make([]uint64, 0, len(f.orders))
+	for id := range f.orders {
+		ids = append(ids, id)
+	}
+
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	return ids
+}
+
+func sameTime(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
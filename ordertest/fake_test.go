@@ -0,0 +1,23 @@
+package ordertest_test
+
+import (
+	"testing"
+
+	"github.com/i101dev/microservices-NN/ordertest"
+	"github.com/i101dev/microservices-NN/repository/order"
+	"github.com/i101dev/microservices-NN/repository/order/repotest"
+)
+
+func fakeFactory(t *testing.T) order.Repository {
+	return ordertest.NewFakeRepo()
+}
+
+func TestFakeRepo(t *testing.T) {
+	repotest.RunSuite(t, fakeFactory)
+}
+
+// Update is a plain write, as it is in RedisRepo, so NoLostUpdates is
+// skipped.
+func TestFakeRepoConcurrency(t *testing.T) {
+	repotest.RunConcurrencySuite(t, fakeFactory, repotest.ConcurrencyOptions{})
+}
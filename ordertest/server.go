@@ -0,0 +1,41 @@
+package ordertest
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/i101dev/microservices-NN/application"
+	"github.com/i101dev/microservices-NN/client"
+	"github.com/i101dev/microservices-NN/clock"
+	"github.com/i101dev/microservices-NN/openapi"
+	"github.com/i101dev/microservices-NN/repository/order"
+)
+
+// NewServer serves the real HTTP routes on top of repo, with strict OpenAPI
+// validation so requests that would be rejected in production fail here too.
+func NewServer(t testing.TB, repo order.Repository) *httptest.Server {
+	return NewServerWithClock(t, repo, nil)
+}
+
+// NewServerWithClock is NewServer with every timestamp the service stamps
+// taken from clk, typically a *clock.Fake.
+func NewServerWithClock(t testing.TB, repo order.Repository, clk clock.Clock) *httptest.Server {
+
+	cfg := application.DefaultConfig()
+	cfg.OpenAPIValidation = openapi.ModeStrict
+	cfg.Clock = clk
+
+	srv := httptest.NewServer(application.Handler(cfg, repo))
+	t.Cleanup(srv.Close)
+
+	return srv
+}
+
+func NewClient(srv *httptest.Server) *client.Client {
+
+	c := client.New(srv.URL)
+	c.HTTPClient = srv.Client()
+	c.MaxRetries = 0
+
+	return c
+}
@@ -0,0 +1,303 @@
+// Package org keeps the organizations orders can be placed for, and who
+// among their users may see and cancel those orders.
+package org
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/i101dev/microservices-NN/auth"
+	"github.com/i101dev/microservices-NN/model"
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	ErrNotExist        = errors.New("organization does not exist")
+	ErrNotMember       = errors.New("not a member of the organization")
+	ErrForbidden       = errors.New("not allowed for orders of the organization")
+	ErrInvalidRole     = errors.New("invalid organization role")
+	ErrUnauthenticated = errors.New("organization orders need an authenticated caller")
+)
+
+// Members can place orders for their organization and see all of its
+// orders. Only managers can cancel them.
+const (
+	RoleMember  = "member"
+	RoleManager = "manager"
+)
+
+func ValidRole(role string) bool {
+	return role == RoleMember || role == RoleManager
+}
+
+type Org struct {
+	ID        uuid.UUID `json:"id"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type Member struct {
+	Subject string `json:"subject"`
+	Role    string `json:"role"`
+}
+
+func orgKey(id uuid.UUID) string {
+	return "org:" + id.String()
+}
+
+func membersKey(id uuid.UUID) string {
+	return "org:" + id.String() + ":members"
+}
+
+func subjectKey(subject string) string {
+	return "org:subject:" + subject
+}
+
+// Store keeps each organization in a hash, with a hash of the roles of its
+// members by the subject they authenticate as, and a set of the
+// organizations of each subject.
+type Store struct {
+	Client *redis.Client
+	// Now stamps new organizations. Nil means time.Now.
+	Now func() time.Time
+}
+
+func (s *Store) now() time.Time {
+	if s.Now == nil {
+		return time.Now()
+	}
+	return s.Now()
+}
+
+func (s *Store) Create(ctx context.Context, name string) (Org, error) {
+
+	o := Org{ID: uuid.New(), Name: name, CreatedAt: s.now().UTC()}
+
+	if err := s.Client.HSet(ctx, orgKey(o.ID), "name", o.Name, "created_at", o.CreatedAt.Format(time.RFC3339)).Err(); err != nil {
+		return Org{}, fmt.Errorf("failed to save organization: %w", err)
+	}
+
+	return o, nil
+}
+
+func (s *Store) Get(ctx context.Context, id uuid.UUID) (Org, error) {
+
+	fields, err := s.Client.HGetAll(ctx, orgKey(id)).Result()
+	if err != nil {
+		return Org{}, fmt.Errorf("failed to read organization: %w", err)
+	}
+
+	if len(fields) == 0 {
+		return Org{}, ErrNotExist
+	}
+
+	o := Org{ID: id, Name: fields["name"]}
+	o.CreatedAt, _ = time.Parse(time.RFC3339, fields["created_at"])
+
+	return o, nil
+}
+
+func (s *Store) Members(ctx context.Context, id uuid.UUID) ([]Member, error) {
+
+	if _, err := s.Get(ctx, id); err != nil {
+		return nil, err
+	}
+
+	roles, err := s.Client.HGetAll(ctx, membersKey(id)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read organization members: %w", err)
+	}
+
+	members := make([]Member, 0, len(roles))
+	for subject, role := range roles {
+		members = append(members, Member{Subject: subject, Role: role})
+	}
+
+	return members, nil
+}
+
+// SetMember adds the subject to the organization with role, or changes the
+// role it had.
+func (s *Store) SetMember(ctx context.Context, id uuid.UUID, m Member) error {
+
+	if !ValidRole(m.Role) {
+		return fmt.Errorf("%q, expected %s or %s: %w", m.Role, RoleMember, RoleManager, ErrInvalidRole)
+	}
+
+	if _, err := s.Get(ctx, id); err != nil {
+		return err
+	}
+
+	pipe := s.Client.TxPipeline()
+	pipe.HSet(ctx, membersKey(id), m.Subject, m.Role)
+	pipe.SAdd(ctx, subjectKey(m.Subject), id.String())
+
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to save organization member: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Store) RemoveMember(ctx context.Context, id uuid.UUID, subject string) error {
+
+	pipe := s.Client.TxPipeline()
+	del := pipe.HDel(ctx, membersKey(id), subject)
+	pipe.SRem(ctx, subjectKey(subject), id.String())
+
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to remove organization member: %w", err)
+	}
+
+	if del.Val() == 0 {
+		return ErrNotMember
+	}
+
+	return nil
+}
+
+// Role returns the role the caller of ctx has in the organization, failing
+// with ErrUnauthenticated when there is no caller and ErrForbidden when it
+// has none. Organizations that do not exist have no members.
+func (s *Store) Role(ctx context.Context, id uuid.UUID) (string, error) {
+
+	p, ok := auth.FromContext(ctx)
+	if !ok {
+		return "", ErrUnauthenticated
+	}
+
+	role, err := s.Client.HGet(ctx, membersKey(id), p.Subject).Result()
+	if errors.Is(err, redis.Nil) {
+		return "", fmt.Errorf("%s is not a member: %w", p.Subject, ErrForbidden)
+	} else if err != nil {
+		return "", fmt.Errorf("failed to read organization member: %w", err)
+	}
+
+	return role, nil
+}
+
+// Orgs returns the organizations the caller of ctx is a member of, none
+// when there is no caller.
+func (s *Store) Orgs(ctx context.Context) ([]uuid.UUID, error) {
+
+	p, ok := auth.FromContext(ctx)
+	if !ok {
+		return nil, nil
+	}
+
+	members, err := s.Client.SMembers(ctx, subjectKey(p.Subject)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list organizations of member: %w", err)
+	}
+
+	ids := make([]uuid.UUID, 0, len(members))
+	for _, m := range members {
+		if id, err := uuid.Parse(m); err == nil {
+			ids = append(ids, id)
+		}
+	}
+
+	return ids, nil
+}
+
+// CanView tells whether the caller of ctx may see o, which everyone may
+// for orders placed for no organization, and holders of a delegated token
+// for it may too.
+func (s *Store) CanView(ctx context.Context, o model.Order) error {
+
+	if o.OrgID == nil {
+		return nil
+	}
+
+	if p, ok := auth.FromContext(ctx); ok && p.HasScope(auth.ViewOrderScope(o.OrderID)) {
+		return nil
+	}
+
+	_, err := s.Role(ctx, *o.OrgID)
+
+	return err
+}
+
+// CanCancel tells whether the caller of ctx may cancel o, which takes a
+// manager for orders placed for an organization.
+func (s *Store) CanCancel(ctx context.Context, o model.Order) error {
+
+	if o.OrgID == nil {
+		return nil
+	}
+
+	role, err := s.Role(ctx, *o.OrgID)
+	if err != nil {
+		return err
+	}
+
+	if role != RoleManager {
+		return fmt.Errorf("only managers can cancel: %w", ErrForbidden)
+	}
+
+	return nil
+}
+
+// Viewer tells which of many orders the caller of a request may see, as
+// CanView does, looking up its membership of each organization once.
+type Viewer struct {
+	store   *Store
+	ctx     context.Context
+	members map[uuid.UUID]bool
+}
+
+func (s *Store) Viewer(ctx context.Context) *Viewer {
+	return &Viewer{store: s, ctx: ctx, members: map[uuid.UUID]bool{}}
+}
+
+// Sees tells whether the caller may see the order, placed for orgID or
+// for no organization when it is nil. Only failures to read the
+// membership are errors.
+func (v *Viewer) Sees(orderID uint64, orgID *uuid.UUID) (bool, error) {
+
+	if orgID == nil {
+		return true, nil
+	}
+
+	if p, ok := auth.FromContext(v.ctx); ok && p.HasScope(auth.ViewOrderScope(orderID)) {
+		return true, nil
+	}
+
+	member, seen := v.members[*orgID]
+	if !seen {
+		_, err := v.store.Role(v.ctx, *orgID)
+		switch {
+		case err == nil:
+			member = true
+		case errors.Is(err, ErrUnauthenticated), errors.Is(err, ErrForbidden):
+		default:
+			return false, err
+		}
+		v.members[*orgID] = member
+	}
+
+	return member, nil
+}
+
+// Visible returns the orders the caller of ctx may see, in the order
+// given.
+func (s *Store) Visible(ctx context.Context, orders []model.Order) ([]model.Order, error) {
+
+	v := s.Viewer(ctx)
+	visible := make([]model.Order, 0, len(orders))
+
+	for _, o := range orders {
+		ok, err := v.Sees(o.OrderID, o.OrgID)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			visible = append(visible, o)
+		}
+	}
+
+	return visible, nil
+}
@@ -0,0 +1,190 @@
+// Package payment reads the webhooks the payment provider sends as the
+// money for an order moves, and remembers which ones were handled so a
+// redelivered notification is only applied once.
+package payment
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	ErrSignature = errors.New("webhook signature is invalid")
+	ErrPayload   = errors.New("webhook payload is invalid")
+)
+
+const (
+	TypeAuthorized = "payment.authorized"
+	TypeCaptured   = "payment.captured"
+	TypeRefunded   = "payment.refunded"
+
+	TypeDisputeOpened  = "dispute.opened"
+	TypeDisputeUpdated = "dispute.updated"
+	TypeDisputeClosed  = "dispute.closed"
+)
+
+// Tolerance is how far the signed timestamp may be from now.
+const Tolerance = 5 * time.Minute
+
+// Notification is one webhook. ID is the provider's event ID, the same on
+// every delivery of the event.
+type Notification struct {
+	ID        string
+	Type      string
+	OrderID   uint64
+	Reference string
+	Amount    uint
+	At        time.Time
+	// DisputeID, DisputeStatus, Reason and EvidenceDueBy are only set for
+	// the dispute.* types.
+	DisputeID     string
+	DisputeStatus string
+	Reason        string
+	EvidenceDueBy *time.Time
+}
+
+type payload struct {
+	ID      string `json:"id"`
+	Type    string `json:"type"`
+	Created int64  `json:"created"`
+	Data    struct {
+		OrderID   uint64 `json:"order_id"`
+		PaymentID string `json:"payment_id"`
+		Amount    uint   `json:"amount"`
+		DisputeID string `json:"dispute_id"`
+		Status    string `json:"status"`
+		Reason    string `json:"reason"`
+		// EvidenceDueBy is a unix time.
+		EvidenceDueBy int64 `json:"evidence_due_by"`
+	} `json:"data"`
+}
+
+// Verify checks the X-Payment-Signature header, "t=<unix time>,v1=<hex>",
+// where v1 is the HMAC-SHA256 of "<unix time>.<body>" with secret.
+func Verify(secret []byte, header http.Header, body []byte, now time.Time) error {
+
+	var ts, sig string
+
+	for _, part := range strings.Split(header.Get("X-Payment-Signature"), ",") {
+		k, v, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch k {
+		case "t":
+			ts = v
+		case "v1":
+			sig = v
+		}
+	}
+
+	unix, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return ErrSignature
+	}
+
+	if skew := now.Sub(time.Unix(unix, 0)); skew > Tolerance || skew < -Tolerance {
+		return fmt.Errorf("%w: timestamp is %s away", ErrSignature, skew.Round(time.Second))
+	}
+
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(ts + "."))
+	mac.Write(body)
+
+	got, err := hex.DecodeString(sig)
+	if err != nil || !hmac.Equal(got, mac.Sum(nil)) {
+		return ErrSignature
+	}
+
+	return nil
+}
+
+func Parse(body []byte) (Notification, error) {
+
+	var p payload
+
+	if err := json.Unmarshal(body, &p); err != nil {
+		return Notification{}, fmt.Errorf("%w: %v", ErrPayload, err)
+	}
+
+	dispute := p.Type == TypeDisputeOpened || p.Type == TypeDisputeUpdated || p.Type == TypeDisputeClosed
+
+	switch {
+	case p.ID == "":
+		return Notification{}, fmt.Errorf("%w: id is missing", ErrPayload)
+	case p.Type != TypeAuthorized && p.Type != TypeCaptured && p.Type != TypeRefunded && !dispute:
+		return Notification{}, fmt.Errorf("%w: unknown type %q", ErrPayload, p.Type)
+	case p.Data.OrderID == 0:
+		return Notification{}, fmt.Errorf("%w: data.order_id is missing", ErrPayload)
+	case p.Data.Amount == 0 && (!dispute || p.Type == TypeDisputeOpened):
+		return Notification{}, fmt.Errorf("%w: data.amount is missing", ErrPayload)
+	case dispute && p.Data.DisputeID == "":
+		return Notification{}, fmt.Errorf("%w: data.dispute_id is missing", ErrPayload)
+	case (p.Type == TypeDisputeUpdated || p.Type == TypeDisputeClosed) && p.Data.Status == "":
+		return Notification{}, fmt.Errorf("%w: data.status is missing", ErrPayload)
+	}
+
+	n := Notification{
+		ID:        p.ID,
+		Type:      p.Type,
+		OrderID:   p.Data.OrderID,
+		Reference: p.Data.PaymentID,
+		Amount:    p.Data.Amount,
+		At:        time.Unix(p.Created, 0).UTC(),
+	}
+
+	if dispute {
+		n.DisputeID = p.Data.DisputeID
+		n.DisputeStatus = p.Data.Status
+		n.Reason = p.Data.Reason
+		if p.Data.EvidenceDueBy != 0 {
+			due := time.Unix(p.Data.EvidenceDueBy, 0).UTC()
+			n.EvidenceDueBy = &due
+		}
+	}
+
+	return n, nil
+}
+
+// Dedup remembers provider event IDs for TTL, which should be longer than
+// the provider keeps retrying.
+type Dedup struct {
+	Client *redis.Client
+	TTL    time.Duration
+}
+
+func eventKey(id string) string {
+	return "payment-event:" + id
+}
+
+// Claim records an event as handled and reports whether it was new. The
+// claim is taken before the event is applied, so a delivery arriving
+// while another is being applied is already a duplicate.
+func (d *Dedup) Claim(ctx context.Context, id string) (bool, error) {
+
+	ok, err := d.Client.SetNX(ctx, eventKey(id), 1, d.TTL).Result()
+	if err != nil {
+		return false, fmt.Errorf("failed to claim payment event: %w", err)
+	}
+
+	return ok, nil
+}
+
+// Release forgets an event that failed to apply, so the provider's retry
+// is not taken for a duplicate.
+func (d *Dedup) Release(ctx context.Context, id string) error {
+
+	if err := d.Client.Del(ctx, eventKey(id)).Err(); err != nil {
+		return fmt.Errorf("failed to release payment event: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,109 @@
+package pickup
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/i101dev/microservices-NN/clock"
+	"github.com/skip2/go-qrcode"
+)
+
+const version = "P1"
+
+var (
+	ErrInvalid = errors.New("pickup code is invalid")
+	ErrExpired = errors.New("pickup code has expired")
+)
+
+type Code struct {
+	OrderID   uint64    `json:"order_id"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// Signer issues pickup codes that name an order and expire after TTL.
+// They are signed with an HMAC of Key, so the store can trust a scanned
+// code without a lookup, while a customer cannot forge one for another
+// order.
+type Signer struct {
+	Key   []byte
+	TTL   time.Duration
+	Clock clock.Clock
+}
+
+func (s *Signer) now() time.Time {
+	if s.Clock == nil {
+		return time.Now().UTC()
+	}
+	return s.Clock.Now().UTC()
+}
+
+// Sign returns a code for the order, such as P1.2n9c.sl1m2o.<signature>.
+func (s *Signer) Sign(orderID uint64) (string, Code) {
+
+	c := Code{
+		OrderID:   orderID,
+		ExpiresAt: s.now().Add(s.TTL).Truncate(time.Second),
+	}
+
+	payload := version + "." + strconv.FormatUint(orderID, 36) + "." + strconv.FormatInt(c.ExpiresAt.Unix(), 36)
+
+	return payload + "." + s.signature(payload), c
+}
+
+func (s *Signer) Verify(code string) (Code, error) {
+
+	parts := strings.Split(strings.TrimSpace(code), ".")
+	if len(parts) != 4 || parts[0] != version {
+		return Code{}, ErrInvalid
+	}
+
+	payload := strings.Join(parts[:3], ".")
+
+	if !hmac.Equal([]byte(parts[3]), []byte(s.signature(payload))) {
+		return Code{}, ErrInvalid
+	}
+
+	orderID, err := strconv.ParseUint(parts[1], 36, 64)
+	if err != nil {
+		return Code{}, ErrInvalid
+	}
+
+	expires, err := strconv.ParseInt(parts[2], 36, 64)
+	if err != nil {
+		return Code{}, ErrInvalid
+	}
+
+	c := Code{OrderID: orderID, ExpiresAt: time.Unix(expires, 0).UTC()}
+
+	if !s.now().Before(c.ExpiresAt) {
+		return c, ErrExpired
+	}
+
+	return c, nil
+}
+
+// signature is truncated to 128 bits to keep the QR code small.
+func (s *Signer) signature(payload string) string {
+
+	mac := hmac.New(sha256.New, s.Key)
+	mac.Write([]byte(payload))
+
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil)[:16])
+}
+
+// PNG encodes the code as a QR code image size pixels wide.
+func PNG(code string, size int) ([]byte, error) {
+
+	data, err := qrcode.Encode(code, qrcode.Medium, size)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode qr code: %w", err)
+	}
+
+	return data, nil
+}
@@ -0,0 +1,177 @@
+package preflight
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Check is one startup requirement. A failed Fatal check stops the
+// service; any other failure is only reported.
+type Check struct {
+	Name  string
+	Fatal bool
+	Run   func(ctx context.Context) error
+}
+
+type Result struct {
+	Name  string `json:"name"`
+	Fatal bool   `json:"fatal"`
+	Error string `json:"error,omitempty"`
+}
+
+// Run runs the checks in order, each with its own timeout, and stops at
+// the first fatal one that fails: later checks usually depend on it.
+func Run(ctx context.Context, timeout time.Duration, checks ...Check) ([]Result, error) {
+
+	results := make([]Result, 0, len(checks))
+
+	for _, c := range checks {
+		checkCtx, cancel := context.WithTimeout(ctx, timeout)
+		err := c.Run(checkCtx)
+		cancel()
+
+		result := Result{Name: c.Name, Fatal: c.Fatal}
+
+		if err != nil {
+			result.Error = err.Error()
+		}
+
+		results = append(results, result)
+
+		if err != nil && c.Fatal {
+			return results, fmt.Errorf("%s: %w", c.Name, err)
+		}
+	}
+
+	return results, nil
+}
+
+// Ping fails when the server cannot be reached. setting names the variable
+// that configures its address, for the error message.
+func Ping(name string, client *redis.Client, setting string) Check {
+
+	return Check{
+		Name:  name + " connectivity",
+		Fatal: true,
+		Run: func(ctx context.Context) error {
+			if err := client.Ping(ctx).Err(); err != nil {
+				return fmt.Errorf("cannot reach redis at %s (check %s and that the server is up): %w", client.Options().Addr, setting, err)
+			}
+			return nil
+		},
+	}
+}
+
+// MinVersion fails when the server reports a version older than min. A
+// server that does not report one, such as a proxy that refuses INFO, is
+// let through.
+func MinVersion(name string, client *redis.Client, min string) Check {
+
+	return Check{
+		Name:  name + " version",
+		Fatal: true,
+		Run: func(ctx context.Context) error {
+
+			info, err := client.Info(ctx, "server").Result()
+			if err != nil && ctx.Err() != nil {
+				return fmt.Errorf("failed to read server info: %w", err)
+			}
+
+			version := infoField(info, "redis_version")
+			if version == "" {
+				return nil
+			}
+
+			if compareVersions(version, min) < 0 {
+				return fmt.Errorf("redis %s is too old, %s or newer is required for consumer-group claims (XAUTOCLAIM)", version, min)
+			}
+
+			return nil
+		},
+	}
+}
+
+// Module reports when the server does not have the module loaded.
+func Module(client *redis.Client, module string, why string) Check {
+
+	return Check{
+		Name: "redis module " + module,
+		Run: func(ctx context.Context) error {
+
+			modules, err := client.Do(ctx, "MODULE", "LIST").Slice()
+			if err != nil {
+				return fmt.Errorf("cannot list modules (%v), %s", err, why)
+			}
+
+			for _, m := range modules {
+				if strings.Contains(strings.ToLower(fmt.Sprint(m)), module) {
+					return nil
+				}
+			}
+
+			return fmt.Errorf("module not loaded, %s", why)
+		},
+	}
+}
+
+// NoEviction reports a maxmemory policy that lets Redis evict keys, which
+// would silently drop orders and queued work once memory runs out. Servers
+// that hide CONFIG are let through.
+func NoEviction(client *redis.Client) Check {
+
+	return Check{
+		Name: "redis eviction policy",
+		Run: func(ctx context.Context) error {
+
+			config, err := client.ConfigGet(ctx, "maxmemory-policy").Result()
+			if err != nil {
+				return nil
+			}
+
+			if policy, ok := config["maxmemory-policy"]; ok && policy != "noeviction" {
+				return fmt.Errorf("maxmemory-policy is %q, set it to noeviction so Redis never evicts orders or queued jobs", policy)
+			}
+
+			return nil
+		},
+	}
+}
+
+func infoField(info string, field string) string {
+
+	for _, line := range strings.Split(info, "\n") {
+		if value, ok := strings.CutPrefix(strings.TrimSpace(line), field+":"); ok {
+			return value
+		}
+	}
+
+	return ""
+}
+
+func compareVersions(a string, b string) int {
+
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+
+	for i := 0; i < len(as) || i < len(bs); i++ {
+		var x, y int
+		if i < len(as) {
+			x, _ = strconv.Atoi(as[i])
+		}
+		if i < len(bs) {
+			y, _ = strconv.Atoi(bs[i])
+		}
+		if x != y {
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+
+	return 0
+}
@@ -0,0 +1,98 @@
+package quota
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/i101dev/microservices-NN/loadshed"
+	"github.com/i101dev/microservices-NN/repository/order"
+)
+
+type contextKey struct{}
+
+// NewContext attaches the API key of a request, for the orders it creates
+// to be counted against.
+func NewContext(ctx context.Context, apiKey string) context.Context {
+	return context.WithValue(ctx, contextKey{}, apiKey)
+}
+
+func FromContext(ctx context.Context) (string, bool) {
+	key, ok := ctx.Value(contextKey{}).(string)
+	return key, ok && key != ""
+}
+
+// Middleware counts each request with an API key against its request
+// quota, and answers 429 once it is used up. The limit and what is left
+// of it are sent in X-Quota-Limit and X-Quota-Remaining. Requests go
+// through when the store cannot be reached.
+func (s *Store) Middleware(next http.Handler) http.Handler {
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		apiKey := r.Header.Get(loadshed.APIKeyHeader)
+		if apiKey == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		limit, remaining, err := s.Take(r.Context(), apiKey, MetricRequests, 1)
+
+		if limit > 0 {
+			w.Header().Set("X-Quota-Limit", strconv.FormatInt(limit, 10))
+			w.Header().Set("X-Quota-Remaining", strconv.FormatInt(remaining, 10))
+		}
+
+		if errors.Is(err, ErrExceeded) {
+			w.Header().Set("Retry-After", "3600")
+			http.Error(w, "daily request quota exceeded", http.StatusTooManyRequests)
+			return
+		} else if err != nil {
+			fmt.Println("failed to check request quota:", err)
+		}
+
+		next.ServeHTTP(w, r.WithContext(NewContext(r.Context(), apiKey)))
+	})
+}
+
+// Intercept counts the orders created for a request with an API key
+// against its order quota, and fails with ErrExceeded before creating any
+// once they would go over it. Orders created by the intake workers, apart
+// from the request, are not counted here: they are counted by the order
+// handler when it queues them.
+func (s *Store) Intercept(ctx context.Context, call *order.Call, next order.Handler) error {
+
+	apiKey, ok := FromContext(ctx)
+	if !ok {
+		return next(ctx, call)
+	}
+
+	var n int64
+
+	switch call.Op {
+	case order.OpInsert:
+		n = 1
+	case order.OpInsertAll:
+		n = int64(len(call.Orders))
+	default:
+		return next(ctx, call)
+	}
+
+	if _, _, err := s.Take(ctx, apiKey, MetricOrders, n); errors.Is(err, ErrExceeded) {
+		return err
+	} else if err != nil {
+		fmt.Println("failed to check order quota:", err)
+		return next(ctx, call)
+	}
+
+	err := next(ctx, call)
+	if err != nil {
+		if err := s.Return(ctx, apiKey, MetricOrders, n); err != nil {
+			fmt.Println(err)
+		}
+	}
+
+	return err
+}
@@ -0,0 +1,300 @@
+// Package quota keeps daily quotas per API key, of requests made and of
+// orders created, counted over a rolling window of the last 24 hours.
+package quota
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	ErrExceeded = errors.New("quota exceeded")
+	ErrNotExist = errors.New("quota does not exist")
+)
+
+const (
+	MetricRequests = "requests"
+	MetricOrders   = "orders"
+)
+
+// Window is how far back usage counts against a quota. Usage is kept in
+// buckets of an hour, so it leaves the window an hour at a time.
+const (
+	Window = 24 * time.Hour
+	bucket = time.Hour
+)
+
+const quotasKey = "quotas"
+
+// ID names the quota of an API key. Keys are hashed so they never appear
+// in the store or in admin URLs.
+func ID(apiKey string) string {
+	sum := sha256.Sum256([]byte(apiKey))
+	return hex.EncodeToString(sum[:8])
+}
+
+func limitsKey(id string) string {
+	return "quota:" + id
+}
+
+func usageKey(id, metric string) string {
+	return "quota:" + id + ":usage:" + metric
+}
+
+// Limits are how many requests and created orders a key gets in the
+// window. Zero is no limit.
+type Limits struct {
+	RequestsPerDay int64 `json:"requests_per_day"`
+	OrdersPerDay   int64 `json:"orders_per_day"`
+}
+
+func (l Limits) of(metric string) int64 {
+	if metric == MetricOrders {
+		return l.OrdersPerDay
+	}
+	return l.RequestsPerDay
+}
+
+type Quota struct {
+	ID string `json:"id"`
+	Limits
+	UpdatedAt *time.Time `json:"updated_at,omitempty"`
+}
+
+// Usage is how much of one limit was used in the window. FreesAt is when
+// the oldest usage counted leaves it.
+type Usage struct {
+	Metric    string     `json:"metric"`
+	Limit     int64      `json:"limit"`
+	Used      int64      `json:"used"`
+	Remaining int64      `json:"remaining"`
+	FreesAt   *time.Time `json:"frees_at,omitempty"`
+}
+
+// Store keeps the limits of each key in a hash, and its usage of each
+// metric in a hash of hourly buckets. The IDs of every quota are in the
+// quotas set.
+type Store struct {
+	Client *redis.Client
+	// Now is used to pick buckets. Nil means time.Now.
+	Now func() time.Time
+}
+
+func (s *Store) now() time.Time {
+	if s.Now == nil {
+		return time.Now()
+	}
+	return s.Now()
+}
+
+func bucketOf(t time.Time) int64 {
+	return t.Unix() / int64(bucket/time.Second)
+}
+
+// Set gives the key limits, replacing the ones it had.
+func (s *Store) Set(ctx context.Context, apiKey string, l Limits) (Quota, error) {
+
+	now := s.now().UTC()
+	q := Quota{ID: ID(apiKey), Limits: l, UpdatedAt: &now}
+
+	pipe := s.Client.TxPipeline()
+	pipe.HSet(ctx, limitsKey(q.ID),
+		MetricRequests, l.RequestsPerDay,
+		MetricOrders, l.OrdersPerDay,
+		"updated_at", now.Format(time.RFC3339),
+	)
+	pipe.SAdd(ctx, quotasKey, q.ID)
+
+	if _, err := pipe.Exec(ctx); err != nil {
+		return Quota{}, fmt.Errorf("failed to save quota: %w", err)
+	}
+
+	return q, nil
+}
+
+func (s *Store) Get(ctx context.Context, id string) (Quota, error) {
+
+	fields, err := s.Client.HGetAll(ctx, limitsKey(id)).Result()
+	if err != nil {
+		return Quota{}, fmt.Errorf("failed to read quota: %w", err)
+	}
+
+	if len(fields) == 0 {
+		return Quota{}, ErrNotExist
+	}
+
+	q := Quota{ID: id}
+	q.RequestsPerDay, _ = strconv.ParseInt(fields[MetricRequests], 10, 64)
+	q.OrdersPerDay, _ = strconv.ParseInt(fields[MetricOrders], 10, 64)
+	if t, err := time.Parse(time.RFC3339, fields["updated_at"]); err == nil {
+		q.UpdatedAt = &t
+	}
+
+	return q, nil
+}
+
+func (s *Store) List(ctx context.Context) ([]Quota, error) {
+
+	ids, err := s.Client.SMembers(ctx, quotasKey).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list quotas: %w", err)
+	}
+
+	quotas := make([]Quota, 0, len(ids))
+
+	for _, id := range ids {
+		q, err := s.Get(ctx, id)
+		if errors.Is(err, ErrNotExist) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		quotas = append(quotas, q)
+	}
+
+	return quotas, nil
+}
+
+// Delete drops the quota and its usage, which leaves the key unlimited.
+func (s *Store) Delete(ctx context.Context, id string) error {
+
+	pipe := s.Client.TxPipeline()
+	del := pipe.Del(ctx, limitsKey(id))
+	pipe.Del(ctx, usageKey(id, MetricRequests), usageKey(id, MetricOrders))
+	pipe.SRem(ctx, quotasKey, id)
+
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to delete quota: %w", err)
+	}
+
+	if del.Val() == 0 {
+		return ErrNotExist
+	}
+
+	return nil
+}
+
+// Usage reports the use of each limit the quota sets.
+func (s *Store) Usage(ctx context.Context, id string) ([]Usage, error) {
+
+	q, err := s.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	now := bucketOf(s.now())
+	first := now - int64(Window/bucket) + 1
+
+	usage := []Usage{}
+
+	for _, metric := range []string{MetricRequests, MetricOrders} {
+		limit := q.Limits.of(metric)
+		if limit <= 0 {
+			continue
+		}
+
+		fields, err := s.Client.HGetAll(ctx, usageKey(id, metric)).Result()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read quota usage: %w", err)
+		}
+
+		u := Usage{Metric: metric, Limit: limit}
+		oldest := int64(-1)
+
+		for field, value := range fields {
+			b, err := strconv.ParseInt(field, 10, 64)
+			if err != nil || b < first {
+				continue
+			}
+			n, _ := strconv.ParseInt(value, 10, 64)
+			u.Used += n
+			if n > 0 && (oldest < 0 || b < oldest) {
+				oldest = b
+			}
+		}
+
+		u.Remaining = max(limit-u.Used, 0)
+
+		if oldest >= 0 {
+			t := time.Unix((oldest+1)*int64(bucket/time.Second), 0).Add(Window - bucket).UTC()
+			u.FreesAt = &t
+		}
+
+		usage = append(usage, u)
+	}
+
+	return usage, nil
+}
+
+// takeScript counts n against the limit of ARGV[1] in KEYS[1] unless that
+// would go over it, in the usage buckets of KEYS[2]. ARGV[2] is the bucket
+// of now and ARGV[4] the number of buckets in the window, older ones are
+// dropped, and ARGV[5] how long the usage is kept. It returns the limit,
+// or 0 when there is none, and what is left of it, or -1 when it was not
+// counted.
+var takeScript = redis.NewScript(`
+local limit = tonumber(redis.call('HGET', KEYS[1], ARGV[1]) or '0')
+if limit <= 0 then
+	return {0, 0}
+end
+local now = tonumber(ARGV[2])
+local first = now - tonumber(ARGV[4]) + 1
+local used = 0
+local fields = redis.call('HGETALL', KEYS[2])
+for i = 1, #fields, 2 do
+	if tonumber(fields[i]) < first then
+		redis.call('HDEL', KEYS[2], fields[i])
+	else
+		used = used + tonumber(fields[i + 1])
+	end
+end
+local n = tonumber(ARGV[3])
+if used + n > limit then
+	return {limit, -1}
+end
+redis.call('HINCRBY', KEYS[2], ARGV[2], n)
+redis.call('EXPIRE', KEYS[2], tonumber(ARGV[5]))
+return {limit, limit - used - n}
+`)
+
+// Take counts n of metric against the quota of the key, or fails with
+// ErrExceeded when that would go over its limit. It returns the limit and
+// what is left of it, both zero for keys without a limit on metric.
+func (s *Store) Take(ctx context.Context, apiKey, metric string, n int64) (int64, int64, error) {
+
+	id := ID(apiKey)
+
+	res, err := takeScript.Run(ctx, s.Client,
+		[]string{limitsKey(id), usageKey(id, metric)},
+		metric, bucketOf(s.now()), n, int64(Window/bucket), int64((Window+bucket)/time.Second),
+	).Int64Slice()
+
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to take from quota: %w", err)
+	}
+
+	if res[1] < 0 {
+		return res[0], 0, fmt.Errorf("%d %s a day: %w", res[0], metric, ErrExceeded)
+	}
+
+	return res[0], res[1], nil
+}
+
+// Return gives back n of metric taken for something that did not happen.
+func (s *Store) Return(ctx context.Context, apiKey, metric string, n int64) error {
+
+	key := usageKey(ID(apiKey), metric)
+
+	if err := s.Client.HIncrBy(ctx, key, strconv.FormatInt(bucketOf(s.now()), 10), -n).Err(); err != nil {
+		return fmt.Errorf("failed to return to quota: %w", err)
+	}
+
+	return nil
+}
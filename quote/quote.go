@@ -0,0 +1,117 @@
+// Package quote keeps priced orders that a customer has not committed to
+// yet. A quote holds its prices and tax until it expires, and can be
+// turned into an order at those prices once it is accepted.
+package quote
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/i101dev/microservices-NN/model"
+)
+
+var (
+	ErrNotExist          = errors.New("quote does not exist")
+	ErrInvalidTransition = errors.New("invalid quote transition")
+	ErrExpired           = errors.New("quote has expired")
+	ErrConflict          = errors.New("quote was changed concurrently")
+)
+
+const (
+	StatusOpen      = "open"
+	StatusAccepted  = "accepted"
+	StatusConverted = "converted"
+	StatusExpired   = "expired"
+)
+
+type Quote struct {
+	ID         string           `json:"id"`
+	CustomerID uuid.UUID        `json:"customer_id"`
+	LineItems  []model.LineItem `json:"line_items"`
+	Shipping   *model.Shipping  `json:"shipping,omitempty"`
+	Tax        *model.Tax       `json:"tax,omitempty"`
+	Total      uint             `json:"total"`
+	// Status is worked out from the timestamps by Refresh. Open and
+	// accepted quotes become expired at ExpiresAt.
+	Status      string     `json:"status"`
+	ExpiresAt   time.Time  `json:"expires_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+	AcceptedAt  *time.Time `json:"accepted_at,omitempty"`
+	ConvertedAt *time.Time `json:"converted_at,omitempty"`
+	// OrderID is the order the quote was converted into.
+	OrderID uint64 `json:"order_id,omitempty"`
+}
+
+// ConvertTimeout is as long as converting a quote may take to store its
+// order. A quote converted longer ago whose order does not exist was left
+// behind by a conversion that stopped halfway.
+const ConvertTimeout = time.Minute
+
+// Refresh sets Status as of now.
+func (q *Quote) Refresh(now time.Time) {
+
+	switch {
+	case q.ConvertedAt != nil:
+		q.Status = StatusConverted
+	case !now.Before(q.ExpiresAt):
+		q.Status = StatusExpired
+	case q.AcceptedAt != nil:
+		q.Status = StatusAccepted
+	default:
+		q.Status = StatusOpen
+	}
+}
+
+func (q *Quote) Accept(now time.Time) error {
+
+	q.Refresh(now)
+
+	switch q.Status {
+	case StatusOpen:
+	case StatusExpired:
+		return ErrExpired
+	default:
+		return ErrInvalidTransition
+	}
+
+	q.AcceptedAt = &now
+	q.Refresh(now)
+
+	return nil
+}
+
+// Convert marks an accepted quote as turned into order orderID.
+func (q *Quote) Convert(orderID uint64, now time.Time) error {
+
+	q.Refresh(now)
+
+	switch q.Status {
+	case StatusAccepted:
+	case StatusExpired:
+		return ErrExpired
+	default:
+		return ErrInvalidTransition
+	}
+
+	q.ConvertedAt = &now
+	q.OrderID = orderID
+	q.Refresh(now)
+
+	return nil
+}
+
+// Unconvert undoes Convert for an order that could not be stored, or that
+// no longer exists ConvertTimeout after it.
+func (q *Quote) Unconvert(orderID uint64, now time.Time) error {
+
+	if q.ConvertedAt == nil || q.OrderID != orderID {
+		return ErrInvalidTransition
+	}
+
+	q.ConvertedAt = nil
+	q.OrderID = 0
+	q.Refresh(now)
+
+	return nil
+}
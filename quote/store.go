@@ -0,0 +1,116 @@
+package quote
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Store keeps quotes as JSON. Quotes that were never converted are
+// deleted a while after they expire; converted ones are kept for good.
+type Store struct {
+	Client *redis.Client
+	// Keep is how long quotes are kept after they expire.
+	Keep time.Duration
+}
+
+func quoteKey(id string) string {
+	return "quote:" + id
+}
+
+func (st *Store) encode(q Quote) ([]byte, time.Duration, error) {
+
+	data, err := json.Marshal(q)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to encode quote: %w", err)
+	}
+
+	if q.ConvertedAt != nil {
+		return data, 0, nil
+	}
+
+	// A zero or negative TTL would keep the quote for good.
+	return data, max(time.Until(q.ExpiresAt.Add(st.Keep)), time.Second), nil
+}
+
+// Insert stores a new quote.
+func (st *Store) Insert(ctx context.Context, q Quote) error {
+
+	data, ttl, err := st.encode(q)
+	if err != nil {
+		return err
+	}
+
+	if err := st.Client.Set(ctx, quoteKey(q.ID), data, ttl).Err(); err != nil {
+		return fmt.Errorf("failed to save quote: %w", err)
+	}
+
+	return nil
+}
+
+func (st *Store) Get(ctx context.Context, id string) (Quote, error) {
+	return st.get(ctx, st.Client, id)
+}
+
+func (st *Store) get(ctx context.Context, c redis.Cmdable, id string) (Quote, error) {
+
+	data, err := c.Get(ctx, quoteKey(id)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return Quote{}, ErrNotExist
+	} else if err != nil {
+		return Quote{}, fmt.Errorf("failed to get quote: %w", err)
+	}
+
+	var q Quote
+
+	if err := json.Unmarshal(data, &q); err != nil {
+		return Quote{}, fmt.Errorf("failed to decode quote: %w", err)
+	}
+
+	return q, nil
+}
+
+// Update applies fn to a quote and stores the result, in one transaction.
+// It fails with ErrConflict when the quote changes in between, so two
+// callers can never both move it on.
+func (st *Store) Update(ctx context.Context, id string, fn func(*Quote) error) (Quote, error) {
+
+	var q Quote
+	key := quoteKey(id)
+
+	err := st.Client.Watch(ctx, func(tx *redis.Tx) error {
+
+		var err error
+		if q, err = st.get(ctx, tx, id); err != nil {
+			return err
+		}
+
+		if err := fn(&q); err != nil {
+			return err
+		}
+
+		data, ttl, err := st.encode(q)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.Set(ctx, key, data, ttl)
+			return nil
+		})
+
+		return err
+	}, key)
+
+	if errors.Is(err, redis.TxFailedErr) {
+		return Quote{}, ErrConflict
+	} else if err != nil {
+		return Quote{}, err
+	}
+
+	return q, nil
+}
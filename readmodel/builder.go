@@ -0,0 +1,189 @@
+package readmodel
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/i101dev/microservices-NN/changefeed"
+	"github.com/i101dev/microservices-NN/model"
+	"github.com/i101dev/microservices-NN/repository/order"
+	"github.com/redis/go-redis/v9"
+)
+
+const group = "order-views"
+
+// buildingTTL is how long a rebuild holds its claim without making
+// progress. A rebuild that dies lets go of it once it runs out.
+const buildingTTL = 5 * time.Minute
+
+// Builder keeps the views up to date from the changefeed, in its own
+// consumer group.
+type Builder struct {
+	Client *redis.Client
+	// Stream is the changefeed stream.
+	Stream string
+	Repo   order.Repository
+	// Interval is how long changes wait to be applied together. Zero means
+	// a second.
+	Interval time.Duration
+	// Now is used to stamp the views. Nil means time.Now.
+	Now func() time.Time
+}
+
+func (b *Builder) now() time.Time {
+	if b.Now == nil {
+		return time.Now()
+	}
+	return b.Now()
+}
+
+// Run applies the changes until ctx is cancelled. The first time it runs
+// against a store with no views, it builds them.
+func (b *Builder) Run(ctx context.Context) error {
+
+	cur, _, err := generations(ctx, b.Client)
+	if err != nil {
+		return err
+	}
+
+	if cur == 0 {
+		go func() {
+			n, err := b.Rebuild(ctx, b.Repo)
+			if errors.Is(err, ErrRebuilding) {
+				return
+			} else if err != nil {
+				fmt.Println("failed to build read model:", err)
+				return
+			}
+			fmt.Printf("built read model of %d orders\n", n)
+		}()
+	}
+
+	interval := b.Interval
+	if interval <= 0 {
+		interval = time.Second
+	}
+
+	feed := &changefeed.Consumer{
+		Client:   b.Client,
+		Stream:   b.Stream,
+		Group:    group,
+		Repo:     b.Repo,
+		Interval: interval,
+		Handle:   b.apply,
+	}
+
+	return feed.Run(ctx)
+}
+
+func (b *Builder) apply(ctx context.Context, changes []changefeed.Change) error {
+
+	now := b.now()
+
+	for _, c := range changes {
+		if err := apply(ctx, b.Client, nil, c.OrderID, c.Order, now); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Rebuild builds a new generation of the views from every order in repo,
+// makes it current and drops the old one. It returns how many orders it
+// read. Changes that come in meanwhile go to both generations, so nothing
+// is lost; only one rebuild runs at a time.
+func (b *Builder) Rebuild(ctx context.Context, repo order.Repository) (int, error) {
+
+	cur, _, err := generations(ctx, b.Client)
+	if err != nil {
+		return 0, err
+	}
+
+	next := cur + 1
+
+	claimed, err := b.Client.SetNX(ctx, buildingKey, next, buildingTTL).Result()
+	if err != nil {
+		return 0, fmt.Errorf("failed to claim view rebuild: %w", err)
+	} else if !claimed {
+		return 0, ErrRebuilding
+	}
+
+	// A rebuild that died may have left part of this generation.
+	if err := b.drop(ctx, next); err != nil {
+		b.Client.Del(ctx, buildingKey)
+		return 0, err
+	}
+
+	var n int
+	now := b.now()
+
+	err = order.ForEachPage(ctx, repo, 100, func(orders []model.Order) error {
+
+		for _, o := range orders {
+			if err := apply(ctx, b.Client, []int64{next}, o.OrderID, &o, now); err != nil {
+				return err
+			}
+			n++
+		}
+
+		return b.Client.Expire(ctx, buildingKey, buildingTTL).Err()
+	})
+
+	if err != nil {
+		b.Client.Del(ctx, buildingKey)
+		if err := b.drop(ctx, next); err != nil {
+			fmt.Println("failed to drop unfinished views:", err)
+		}
+		return n, fmt.Errorf("failed to rebuild views: %w", err)
+	}
+
+	pipe := b.Client.TxPipeline()
+	pipe.HSet(ctx, metaKey, "generation", next, "built_at", b.now().UTC().Format(time.RFC3339), "orders", n)
+	pipe.Del(ctx, buildingKey)
+
+	if _, err := pipe.Exec(ctx); err != nil {
+		return n, fmt.Errorf("failed to switch to the rebuilt views: %w", err)
+	}
+
+	if cur > 0 {
+		if err := b.drop(ctx, cur); err != nil {
+			fmt.Println("failed to drop old views:", err)
+		}
+	}
+
+	return n, nil
+}
+
+// drop deletes every key of generation gen.
+func (b *Builder) drop(ctx context.Context, gen int64) error {
+
+	iter := b.Client.Scan(ctx, 0, prefix(gen)+"*", 500).Iterator()
+
+	var keys []string
+
+	for iter.Next(ctx) {
+		keys = append(keys, iter.Val())
+
+		if len(keys) == 500 {
+			if err := b.Client.Unlink(ctx, keys...).Err(); err != nil {
+				return fmt.Errorf("failed to drop views: %w", err)
+			}
+			keys = keys[:0]
+		}
+	}
+
+	if err := iter.Err(); err != nil {
+		return fmt.Errorf("failed to scan views: %w", err)
+	}
+
+	if len(keys) > 0 {
+		if err := b.Client.Unlink(ctx, keys...).Err(); err != nil {
+			return fmt.Errorf("failed to drop views: %w", err)
+		}
+	}
+
+	return nil
+}
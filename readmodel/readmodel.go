@@ -0,0 +1,295 @@
+// Package readmodel keeps denormalized views of the orders, per customer
+// and per day of creation, so listings and reports read a handful of keys
+// instead of scanning every order. The views are built from the
+// changefeed and can be rebuilt from scratch from the store.
+//
+// Every view belongs to a generation. Readers use the current one, named
+// in view:meta; a rebuild fills the next generation while changes keep
+// going to both, then makes it current and drops the old one.
+package readmodel
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/i101dev/microservices-NN/model"
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	ErrNotBuilt   = errors.New("read model is not built yet")
+	ErrRebuilding = errors.New("read model is already being rebuilt")
+)
+
+const (
+	metaKey     = "view:meta"
+	buildingKey = "view:building"
+	dayLayout   = "2006-01-02"
+)
+
+var statuses = []string{
+	model.StatusPending,
+	model.StatusReview,
+	model.StatusBackordered,
+	model.StatusShipped,
+	model.StatusCompleted,
+	model.StatusCancelled,
+}
+
+func prefix(gen int64) string {
+	return "view:" + strconv.FormatInt(gen, 10) + ":"
+}
+
+func orderKey(gen int64, id uint64) string {
+	return prefix(gen) + "order:" + strconv.FormatUint(id, 10)
+}
+
+func customerKey(gen int64, customer uuid.UUID) string {
+	return prefix(gen) + "customer:" + customer.String()
+}
+
+func customerOrdersKey(gen int64, customer uuid.UUID) string {
+	return customerKey(gen, customer) + ":orders"
+}
+
+func dayKey(gen int64, day string) string {
+	return prefix(gen) + "day:" + day
+}
+
+func dayOrdersKey(gen int64, day string) string {
+	return dayKey(gen, day) + ":orders"
+}
+
+func totalsKey(gen int64) string {
+	return prefix(gen) + "totals"
+}
+
+// Summary is an order as the views keep it. Revenue is what the items
+// cost before tax, as in the order stats.
+type Summary struct {
+	OrderID    uint64     `json:"order_id"`
+	CustomerID uuid.UUID  `json:"customer_id"`
+	OrgID      *uuid.UUID `json:"org_id,omitempty"`
+	Status     string     `json:"status"`
+	LineItems  int        `json:"line_items"`
+	Revenue    uint64     `json:"revenue"`
+	Total      uint       `json:"total"`
+	Tags       []string   `json:"tags,omitempty"`
+	CreatedAt  *time.Time `json:"created_at"`
+	UpdatedAt  *time.Time `json:"updated_at"`
+}
+
+func summarize(o model.Order) Summary {
+
+	s := Summary{
+		OrderID:    o.OrderID,
+		CustomerID: o.CustomerID,
+		OrgID:      o.OrgID,
+		Status:     o.Status(),
+		LineItems:  len(o.LineItems),
+		Total:      o.Total(),
+		Tags:       o.Tags,
+		CreatedAt:  o.CreatedAt,
+		UpdatedAt:  o.UpdatedAt,
+	}
+
+	for _, item := range o.LineItems {
+		s.Revenue += uint64(item.Quantity) * uint64(item.Price)
+	}
+
+	return s
+}
+
+func (s Summary) day() string {
+	if s.CreatedAt == nil {
+		return ""
+	}
+	return s.CreatedAt.UTC().Format(dayLayout)
+}
+
+func (s Summary) score() float64 {
+	if s.CreatedAt == nil {
+		return 0
+	}
+	return float64(s.CreatedAt.UnixMilli())
+}
+
+// count adds s to the views of gen, or takes it off them when sign is -1.
+// Orders created on no known day are left out of the day views.
+func count(ctx context.Context, pipe redis.Pipeliner, gen int64, s Summary, sign int64) {
+
+	member := strconv.FormatUint(s.OrderID, 10)
+
+	hashes := []string{totalsKey(gen), customerKey(gen, s.CustomerID)}
+
+	if sign > 0 {
+		pipe.ZAdd(ctx, customerOrdersKey(gen, s.CustomerID), redis.Z{Score: s.score(), Member: member})
+	} else {
+		pipe.ZRem(ctx, customerOrdersKey(gen, s.CustomerID), member)
+	}
+
+	if day := s.day(); day != "" {
+		hashes = append(hashes, dayKey(gen, day))
+
+		if sign > 0 {
+			pipe.ZAdd(ctx, dayOrdersKey(gen, day), redis.Z{Score: s.score(), Member: member})
+		} else {
+			pipe.ZRem(ctx, dayOrdersKey(gen, day), member)
+		}
+	}
+
+	for _, key := range hashes {
+		pipe.HIncrBy(ctx, key, "orders", sign)
+		pipe.HIncrBy(ctx, key, "status:"+s.Status, sign)
+		pipe.HIncrBy(ctx, key, "line_items", sign*int64(s.LineItems))
+		pipe.HIncrBy(ctx, key, "revenue", sign*int64(s.Revenue))
+	}
+}
+
+func sameSummary(a, b *Summary) bool {
+
+	if a == nil || b == nil {
+		return a == b
+	}
+
+	return a.OrderID == b.OrderID && a.CustomerID == b.CustomerID && a.Status == b.Status &&
+		a.LineItems == b.LineItems && a.Revenue == b.Revenue && a.Total == b.Total &&
+		slices.Equal(a.Tags, b.Tags) && sameTime(a.CreatedAt, b.CreatedAt) && sameTime(a.UpdatedAt, b.UpdatedAt)
+}
+
+func sameTime(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
+
+// generations returns the current generation and the one being rebuilt,
+// either of which is zero when there is none.
+func generations(ctx context.Context, c redis.Cmdable) (cur int64, next int64, err error) {
+
+	cur, err = c.HGet(ctx, metaKey, "generation").Int64()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return 0, 0, fmt.Errorf("failed to read view generation: %w", err)
+	}
+
+	next, err = c.Get(ctx, buildingKey).Int64()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return 0, 0, fmt.Errorf("failed to read view rebuild: %w", err)
+	}
+
+	return cur, next, nil
+}
+
+// apply moves the views of the generations gens to o, or takes the order
+// off them when o is nil, by the difference from the summary they had of
+// it. Applying the same state twice changes nothing, so changes can be
+// handled more than once. When gens is nil the current generation and the
+// one being rebuilt are used, read in the same transaction so a rebuild
+// starting part way cannot miss the change.
+func apply(ctx context.Context, client *redis.Client, gens []int64, id uint64, o *model.Order, now time.Time) error {
+
+	var next *Summary
+	if o != nil {
+		s := summarize(*o)
+		next = &s
+	}
+
+	var data []byte
+	if next != nil {
+		data, _ = json.Marshal(next)
+	}
+
+	update := func(tx *redis.Tx) error {
+
+		targets := gens
+
+		if targets == nil {
+			cur, building, err := generations(ctx, tx)
+			if err != nil {
+				return err
+			}
+			for _, g := range []int64{cur, building} {
+				if g > 0 && !slices.Contains(targets, g) {
+					targets = append(targets, g)
+				}
+			}
+		}
+
+		if len(targets) == 0 {
+			return nil
+		}
+
+		keys := make([]string, len(targets))
+		for i, g := range targets {
+			keys[i] = orderKey(g, id)
+		}
+
+		if err := tx.Watch(ctx, keys...).Err(); err != nil {
+			return err
+		}
+
+		prevs := make([]*Summary, len(targets))
+
+		for i, key := range keys {
+			raw, err := tx.Get(ctx, key).Bytes()
+			if errors.Is(err, redis.Nil) {
+				continue
+			} else if err != nil {
+				return fmt.Errorf("failed to read order view: %w", err)
+			}
+
+			var prev Summary
+			if err := json.Unmarshal(raw, &prev); err != nil {
+				return fmt.Errorf("failed to decode order view %s: %w", key, err)
+			}
+			prevs[i] = &prev
+		}
+
+		_, err := tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+
+			for i, g := range targets {
+				if sameSummary(prevs[i], next) {
+					continue
+				}
+
+				if prevs[i] != nil {
+					count(ctx, pipe, g, *prevs[i], -1)
+				}
+
+				if next != nil {
+					count(ctx, pipe, g, *next, 1)
+					pipe.Set(ctx, keys[i], data, 0)
+				} else {
+					pipe.Del(ctx, keys[i])
+				}
+
+				pipe.HSet(ctx, totalsKey(g), "updated_at", now.UTC().Format(time.RFC3339))
+			}
+
+			return nil
+		})
+
+		return err
+	}
+
+	watch := []string{metaKey, buildingKey}
+	if gens != nil {
+		watch = nil
+	}
+
+	for attempt := 0; attempt < 10; attempt++ {
+		err := client.Watch(ctx, update, watch...)
+		if !errors.Is(err, redis.TxFailedErr) {
+			return err
+		}
+	}
+
+	return fmt.Errorf("failed to update views of order %d: too much contention", id)
+}
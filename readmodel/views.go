@@ -0,0 +1,345 @@
+package readmodel
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/i101dev/microservices-NN/changefeed"
+	"github.com/redis/go-redis/v9"
+)
+
+// Views reads the current generation of the views.
+type Views struct {
+	Client *redis.Client
+	// Stream is the changefeed stream, which tells how far behind the views
+	// are.
+	Stream string
+}
+
+// Counts are the orders in a view, by their current status.
+type Counts struct {
+	Orders    int64            `json:"orders"`
+	ByStatus  map[string]int64 `json:"by_status"`
+	LineItems int64            `json:"line_items"`
+	Revenue   int64            `json:"revenue"`
+}
+
+func newCounts(fields map[string]string) Counts {
+
+	c := Counts{ByStatus: map[string]int64{}}
+
+	c.Orders, _ = strconv.ParseInt(fields["orders"], 10, 64)
+	c.LineItems, _ = strconv.ParseInt(fields["line_items"], 10, 64)
+	c.Revenue, _ = strconv.ParseInt(fields["revenue"], 10, 64)
+
+	for _, s := range statuses {
+		c.ByStatus[s], _ = strconv.ParseInt(fields["status:"+s], 10, 64)
+	}
+
+	return c
+}
+
+type CustomerSummary struct {
+	CustomerID uuid.UUID `json:"customer_id"`
+	Counts
+	FirstOrderAt *time.Time `json:"first_order_at"`
+	LastOrderAt  *time.Time `json:"last_order_at"`
+}
+
+type Day struct {
+	Date string `json:"date"`
+	Counts
+}
+
+type Totals struct {
+	Counts
+	UpdatedAt string `json:"updated_at"`
+}
+
+// Status reports the generation in use and any rebuild under way.
+type Status struct {
+	Generation int64  `json:"generation"`
+	BuiltAt    string `json:"built_at,omitempty"`
+	Orders     int64  `json:"orders"`
+	Rebuilding bool   `json:"rebuilding"`
+}
+
+func (v *Views) Status(ctx context.Context) (Status, error) {
+
+	fields, err := v.Client.HGetAll(ctx, metaKey).Result()
+	if err != nil {
+		return Status{}, fmt.Errorf("failed to read view status: %w", err)
+	}
+
+	n, err := v.Client.Exists(ctx, buildingKey).Result()
+	if err != nil {
+		return Status{}, fmt.Errorf("failed to read view status: %w", err)
+	}
+
+	s := Status{BuiltAt: fields["built_at"], Rebuilding: n > 0}
+	s.Generation, _ = strconv.ParseInt(fields["generation"], 10, 64)
+	s.Orders, _ = strconv.ParseInt(fields["orders"], 10, 64)
+
+	return s, nil
+}
+
+func (v *Views) generation(ctx context.Context) (int64, error) {
+
+	gen, _, err := generations(ctx, v.Client)
+	if err != nil {
+		return 0, err
+	}
+
+	if gen == 0 {
+		return 0, ErrNotBuilt
+	}
+
+	return gen, nil
+}
+
+func (v *Views) Totals(ctx context.Context) (Totals, error) {
+
+	gen, err := v.generation(ctx)
+	if err != nil {
+		return Totals{}, err
+	}
+
+	fields, err := v.Client.HGetAll(ctx, totalsKey(gen)).Result()
+	if err != nil {
+		return Totals{}, fmt.Errorf("failed to read totals view: %w", err)
+	}
+
+	return Totals{Counts: newCounts(fields), UpdatedAt: fields["updated_at"]}, nil
+}
+
+func (v *Views) Customer(ctx context.Context, customer uuid.UUID) (CustomerSummary, error) {
+
+	gen, err := v.generation(ctx)
+	if err != nil {
+		return CustomerSummary{}, err
+	}
+
+	pipe := v.Client.Pipeline()
+	fields := pipe.HGetAll(ctx, customerKey(gen, customer))
+	first := pipe.ZRangeWithScores(ctx, customerOrdersKey(gen, customer), 0, 0)
+	last := pipe.ZRevRangeWithScores(ctx, customerOrdersKey(gen, customer), 0, 0)
+
+	if _, err := pipe.Exec(ctx); err != nil {
+		return CustomerSummary{}, fmt.Errorf("failed to read customer view: %w", err)
+	}
+
+	s := CustomerSummary{CustomerID: customer, Counts: newCounts(fields.Val())}
+
+	if z := first.Val(); len(z) > 0 {
+		t := time.UnixMilli(int64(z[0].Score)).UTC()
+		s.FirstOrderAt = &t
+	}
+
+	if z := last.Val(); len(z) > 0 {
+		t := time.UnixMilli(int64(z[0].Score)).UTC()
+		s.LastOrderAt = &t
+	}
+
+	return s, nil
+}
+
+// CustomerOrders returns a page of the customer's orders, newest first,
+// and the offset of the next page, which is zero after the last one.
+func (v *Views) CustomerOrders(ctx context.Context, customer uuid.UUID, offset, size uint64) ([]Summary, uint64, error) {
+
+	gen, err := v.generation(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return v.page(ctx, gen, customerOrdersKey(gen, customer), true, offset, size)
+}
+
+// Days returns the view of every day in [from, to], both given as dates.
+func (v *Views) Days(ctx context.Context, from, to time.Time) ([]Day, error) {
+
+	gen, err := v.generation(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	pipe := v.Client.Pipeline()
+
+	var days []string
+	var cmds []*redis.MapStringStringCmd
+
+	for d := from; !d.After(to); d = d.AddDate(0, 0, 1) {
+		day := d.Format(dayLayout)
+		days = append(days, day)
+		cmds = append(cmds, pipe.HGetAll(ctx, dayKey(gen, day)))
+	}
+
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, fmt.Errorf("failed to read day views: %w", err)
+	}
+
+	out := make([]Day, len(days))
+	for i, day := range days {
+		out[i] = Day{Date: day, Counts: newCounts(cmds[i].Val())}
+	}
+
+	return out, nil
+}
+
+// DayOrders returns a page of the orders created on day, oldest first.
+func (v *Views) DayOrders(ctx context.Context, day time.Time, offset, size uint64) ([]Summary, uint64, error) {
+
+	gen, err := v.generation(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return v.page(ctx, gen, dayOrdersKey(gen, day.Format(dayLayout)), false, offset, size)
+}
+
+// page reads a page of the summaries in the sorted set key.
+func (v *Views) page(ctx context.Context, gen int64, key string, newest bool, offset, size uint64) ([]Summary, uint64, error) {
+
+	start, stop := int64(offset), int64(offset+size)-1
+
+	pipe := v.Client.Pipeline()
+
+	var members *redis.StringSliceCmd
+	if newest {
+		members = pipe.ZRevRange(ctx, key, start, stop)
+	} else {
+		members = pipe.ZRange(ctx, key, start, stop)
+	}
+	count := pipe.ZCard(ctx, key)
+
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, 0, fmt.Errorf("failed to read view: %w", err)
+	}
+
+	items := []Summary{}
+
+	if ids := members.Val(); len(ids) > 0 {
+		keys := make([]string, len(ids))
+		for i, id := range ids {
+			n, _ := strconv.ParseUint(id, 10, 64)
+			keys[i] = orderKey(gen, n)
+		}
+
+		values, err := v.Client.MGet(ctx, keys...).Result()
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to read order views: %w", err)
+		}
+
+		for _, value := range values {
+			raw, ok := value.(string)
+			if !ok {
+				continue
+			}
+			var s Summary
+			if err := json.Unmarshal([]byte(raw), &s); err != nil {
+				return nil, 0, fmt.Errorf("failed to decode order view: %w", err)
+			}
+			items = append(items, s)
+		}
+	}
+
+	var next uint64
+	if end := offset + size; end < uint64(count.Val()) {
+		next = end
+	}
+
+	return items, next, nil
+}
+
+// Count returns how many orders have status, or any status when it is
+// empty, among those of customer when it is set. Every write made before
+// the time it returns is counted, and maybe some made since.
+func (v *Views) Count(ctx context.Context, customer *uuid.UUID, status string) (int64, time.Time, error) {
+
+	gen, err := v.generation(ctx)
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+
+	// Reading how far the views got first keeps the bound on the safe side.
+	asOf, err := v.appliedThrough(ctx)
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+
+	key := totalsKey(gen)
+	if customer != nil {
+		key = customerKey(gen, *customer)
+	}
+
+	field := "orders"
+	if status != "" {
+		field = "status:" + status
+	}
+
+	n, err := v.Client.HGet(ctx, key, field).Int64()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return 0, time.Time{}, fmt.Errorf("failed to read order count: %w", err)
+	}
+
+	return n, asOf, nil
+}
+
+// appliedThrough returns the time of the oldest change the views have not
+// applied yet, or now when they have applied them all. Changefeed entry
+// IDs start with the time they were added.
+func (v *Views) appliedThrough(ctx context.Context) (time.Time, error) {
+
+	stream := v.Stream
+	if stream == "" {
+		stream = changefeed.DefaultStream
+	}
+
+	now := time.Now()
+
+	groups, err := v.Client.XInfoGroups(ctx, stream).Result()
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to read view progress: %w", err)
+	}
+
+	i := slices.IndexFunc(groups, func(g redis.XInfoGroup) bool { return g.Name == group })
+	if i < 0 {
+		return time.Time{}, ErrNotBuilt
+	}
+
+	var oldest []string
+
+	if groups[i].Pending > 0 {
+		pending, err := v.Client.XPending(ctx, stream, group).Result()
+		if err != nil {
+			return time.Time{}, fmt.Errorf("failed to read view progress: %w", err)
+		}
+		oldest = append(oldest, pending.Lower)
+	}
+
+	next, err := v.Client.XRangeN(ctx, stream, "("+groups[i].LastDeliveredID, "+", 1).Result()
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to read view progress: %w", err)
+	}
+	if len(next) > 0 {
+		oldest = append(oldest, next[0].ID)
+	}
+
+	asOf := now
+
+	for _, id := range oldest {
+		ms, _, _ := strings.Cut(id, "-")
+		if n, err := strconv.ParseInt(ms, 10, 64); err == nil && time.UnixMilli(n).Before(asOf) {
+			asOf = time.UnixMilli(n)
+		}
+	}
+
+	return asOf.UTC(), nil
+}
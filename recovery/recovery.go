@@ -0,0 +1,145 @@
+package recovery
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Crash is the report of one recovered panic.
+type Crash struct {
+	At        time.Time `json:"at"`
+	RequestID string    `json:"request_id,omitempty"`
+	Where     string    `json:"where"`
+	Panic     string    `json:"panic"`
+	Stack     []Frame   `json:"stack"`
+	// Value is what was passed to panic.
+	Value any `json:"-"`
+}
+
+type Frame struct {
+	Function string `json:"function"`
+	File     string `json:"file"`
+	Line     int    `json:"line"`
+}
+
+// Recoverer turns panics into crash reports: it logs each one with its
+// stack, counts it in Panics and hands it to Report, both of which are
+// optional. Report is where an error tracker hooks in.
+type Recoverer struct {
+	Log    *slog.Logger
+	Panics prometheus.Counter
+	Report func(ctx context.Context, c Crash)
+}
+
+// Capture records a recovered panic. where says what was running, such as
+// the request method and path.
+func (rc *Recoverer) Capture(ctx context.Context, p any, where string) Crash {
+
+	c := Crash{
+		At:        time.Now().UTC(),
+		RequestID: middleware.GetReqID(ctx),
+		Where:     where,
+		Panic:     fmt.Sprint(p),
+		Stack:     stack(),
+		Value:     p,
+	}
+
+	if rc.Log != nil {
+		frames := make([]string, len(c.Stack))
+		for i, f := range c.Stack {
+			frames[i] = fmt.Sprintf("%s %s:%d", f.Function, f.File, f.Line)
+		}
+
+		rc.Log.ErrorContext(ctx, "panic recovered",
+			"panic", c.Panic,
+			"where", c.Where,
+			"request_id", c.RequestID,
+			"stack", frames,
+		)
+	}
+
+	if rc.Panics != nil {
+		rc.Panics.Inc()
+	}
+
+	if rc.Report != nil {
+		rc.Report(ctx, c)
+	}
+
+	return c
+}
+
+// Middleware answers a panicking handler with a 500 that carries the
+// request ID, so the client can quote it and the crash can be found.
+// http.ErrAbortHandler is let through, since it is how a handler asks the
+// server to drop the connection.
+func (rc *Recoverer) Middleware(next http.Handler) http.Handler {
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+
+		defer func() {
+
+			p := recover()
+			if p == nil {
+				return
+			}
+			if p == http.ErrAbortHandler {
+				panic(p)
+			}
+
+			c := rc.Capture(r.Context(), p, r.Method+" "+r.URL.Path)
+
+			// Nothing can be done about a response that is already on
+			// its way.
+			if ww.Status() != 0 {
+				return
+			}
+
+			ww.Header().Set("Content-Type", "application/json")
+			ww.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(ww).Encode(map[string]any{
+				"error": map[string]string{
+					"code":       "internal",
+					"message":    "internal server error",
+					"request_id": c.RequestID,
+				},
+			})
+		}()
+
+		next.ServeHTTP(ww, r)
+	})
+}
+
+// stack returns the frames from the panicking call up, leaving out the
+// runtime and this package.
+func stack() []Frame {
+
+	pcs := make([]uintptr, 64)
+	n := runtime.Callers(3, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+
+	var out []Frame
+
+	for {
+		f, more := frames.Next()
+
+		if !strings.HasPrefix(f.Function, "runtime.") && !strings.Contains(f.Function, "/recovery.") {
+			out = append(out, Frame{Function: f.Function, File: f.File, Line: f.Line})
+		}
+
+		if !more {
+			return out
+		}
+	}
+}
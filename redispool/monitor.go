@@ -0,0 +1,113 @@
+package redispool
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+type Snapshot struct {
+	Hits       uint32        `json:"hits"`
+	Misses     uint32        `json:"misses"`
+	Timeouts   uint32        `json:"timeouts"`
+	TotalConns uint32        `json:"total_conns"`
+	IdleConns  uint32        `json:"idle_conns"`
+	StaleConns uint32        `json:"stale_conns"`
+	PoolSize   int           `json:"pool_size"`
+	ProbeWait  time.Duration `json:"probe_wait_ns"`
+	Degraded   bool          `json:"degraded"`
+}
+
+// Monitor samples pool stats and times a PING, which waits for a pool
+// connection exactly like a request would. Slow probes or new pool timeouts
+// put the monitor into degraded mode until a clean interval is seen.
+type Monitor struct {
+	Client        *redis.Client
+	Interval      time.Duration
+	WaitThreshold time.Duration
+
+	mu       sync.RWMutex
+	snapshot Snapshot
+}
+
+func (m *Monitor) Run(ctx context.Context) {
+
+	ticker := time.NewTicker(m.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			m.sample(ctx)
+		}
+	}
+}
+
+func (m *Monitor) sample(ctx context.Context) {
+
+	probeCtx, cancel := context.WithTimeout(ctx, m.Client.Options().PoolTimeout+m.WaitThreshold)
+	defer cancel()
+
+	start := time.Now()
+	err := m.Client.Ping(probeCtx).Err()
+	wait := time.Since(start)
+
+	stats := m.Client.PoolStats()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	prev := m.snapshot
+	newTimeouts := stats.Timeouts - prev.Timeouts
+
+	degraded := err != nil || wait > m.WaitThreshold || newTimeouts > 0
+
+	if newTimeouts > 0 {
+		fmt.Printf("redis pool exhausted: %d wait timeouts in the last %s (%d/%d conns in use)\n",
+			newTimeouts, m.Interval, stats.TotalConns-stats.IdleConns, m.Client.Options().PoolSize)
+	}
+
+	if wait > m.WaitThreshold {
+		fmt.Printf("redis pool wait %s exceeds %s\n", wait.Round(time.Millisecond), m.WaitThreshold)
+	}
+
+	if degraded != prev.Degraded {
+		fmt.Println("redis pool degraded mode:", degraded)
+	}
+
+	m.snapshot = Snapshot{
+		Hits:       stats.Hits,
+		Misses:     stats.Misses,
+		Timeouts:   stats.Timeouts,
+		TotalConns: stats.TotalConns,
+		IdleConns:  stats.IdleConns,
+		StaleConns: stats.StaleConns,
+		PoolSize:   m.Client.Options().PoolSize,
+		ProbeWait:  wait,
+		Degraded:   degraded,
+	}
+}
+
+func (m *Monitor) Snapshot() Snapshot {
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.snapshot
+}
+
+func (m *Monitor) Degraded() bool {
+	return m.Snapshot().Degraded
+}
+
+func (m *Monitor) ServeStats(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(m.Snapshot())
+}
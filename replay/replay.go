@@ -0,0 +1,124 @@
+// Package replay rejects mutations sent more than once, for partners that
+// send a nonce and a timestamp with their requests instead of idempotency
+// keys. Neither is signed, so this catches retries and duplicates, not
+// forged requests.
+package replay
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/i101dev/microservices-NN/httperr"
+	"github.com/i101dev/microservices-NN/loadshed"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	NonceHeader     = "X-Request-Nonce"
+	TimestampHeader = "X-Request-Timestamp"
+)
+
+var validNonce = regexp.MustCompile(`^[a-zA-Z0-9_.-]{16,128}$`)
+
+// Guard checks the nonce and timestamp of mutations that carry them.
+// Requests without a nonce are let through as before.
+type Guard struct {
+	Client *redis.Client
+	// Window is how far the timestamp of a request may be from now, either
+	// way.
+	Window time.Duration
+	// Now is the time timestamps are checked against. Nil means time.Now.
+	Now func() time.Time
+}
+
+func (g *Guard) now() time.Time {
+	if g.Now == nil {
+		return time.Now()
+	}
+	return g.Now()
+}
+
+// nonceKey scopes nonces to the API key sending them, hashed so it never
+// appears in the store, for partners not to collide with each other.
+func nonceKey(apiKey, nonce string) string {
+
+	scope := "anonymous"
+	if apiKey != "" {
+		sum := sha256.Sum256([]byte(apiKey))
+		scope = hex.EncodeToString(sum[:8])
+	}
+
+	return "replay:" + scope + ":" + nonce
+}
+
+// Middleware rejects a mutation whose nonce was already used with a 409,
+// and one whose timestamp, in Unix seconds, is outside the window with a
+// 400. Nonces are kept for twice the window, which covers every timestamp
+// still accepted, so a replay is always caught before it could pass as a
+// new request. Without the store requests are refused, not let through.
+// A request that fails with a 5xx gives its nonce back, so it can be
+// retried as sent.
+func (g *Guard) Middleware(next http.Handler) http.Handler {
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		nonce := r.Header.Get(NonceHeader)
+		if nonce == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if !validNonce.MatchString(nonce) {
+			httperr.Write(w, http.StatusBadRequest, "invalid_nonce", NonceHeader+" must be 16 to 128 letters, digits, '_', '.' or '-'")
+			return
+		}
+
+		sent, err := strconv.ParseInt(r.Header.Get(TimestampHeader), 10, 64)
+		if err != nil {
+			httperr.Write(w, http.StatusBadRequest, "invalid_timestamp", TimestampHeader+" must be a Unix time in seconds")
+			return
+		}
+
+		if skew := g.now().Sub(time.Unix(sent, 0)).Abs(); skew > g.Window {
+			httperr.Write(w, http.StatusBadRequest, "stale_timestamp", fmt.Sprintf("%s is %s away from now, more than %s", TimestampHeader, skew.Round(time.Second), g.Window))
+			return
+		}
+
+		key := nonceKey(r.Header.Get(loadshed.APIKeyHeader), nonce)
+
+		fresh, err := g.Client.SetNX(r.Context(), key, sent, 2*g.Window).Result()
+		if err != nil {
+			fmt.Println("failed to record request nonce:", err)
+			httperr.Write(w, http.StatusServiceUnavailable, "replay_check_unavailable", "the nonce could not be checked, try again")
+			return
+		}
+
+		if !fresh {
+			httperr.Write(w, http.StatusConflict, "replayed_request", "the request with this "+NonceHeader+" was already received")
+			return
+		}
+
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+
+		next.ServeHTTP(ww, r)
+
+		if ww.Status() >= http.StatusInternalServerError {
+			if err := g.Client.Del(context.WithoutCancel(r.Context()), key).Err(); err != nil {
+				fmt.Println("failed to release request nonce:", err)
+			}
+		}
+	})
+}
@@ -0,0 +1,53 @@
+package order
+
+import (
+	"errors"
+	"fmt"
+)
+
+var ErrNotExist = errors.New("order does not exist")
+var ErrExist = errors.New("order already exists")
+var ErrConflict = errors.New("order was modified concurrently")
+var ErrUnavailable = errors.New("order store is unavailable")
+var ErrCorrupt = errors.New("stored order is corrupt")
+var ErrSnapshotExpired = errors.New("listing snapshot has expired")
+
+// Error is returned by the repository for failures that callers may want to
+// tell apart. errors.Is matches it against its Kind, which is one of the
+// sentinels above, and errors.As exposes the order it was about.
+type Error struct {
+	Kind error
+	Op   string
+	Key  string
+	ID   uint64
+	Err  error
+}
+
+func (e *Error) Error() string {
+
+	msg := fmt.Sprintf("%s %s: %v", e.Op, e.Key, e.Kind)
+
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+
+	return msg
+}
+
+func (e *Error) Is(target error) bool {
+	return target == e.Kind
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+func orderError(kind error, op string, id uint64, err error) error {
+	return &Error{
+		Kind: kind,
+		Op:   op,
+		Key:  orderIDKey(id),
+		ID:   id,
+		Err:  err,
+	}
+}
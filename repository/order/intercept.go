@@ -0,0 +1,155 @@
+package order
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/i101dev/microservices-NN/model"
+)
+
+type Op string
+
+const (
+	OpInsert     Op = "insert"
+	OpInsertAll  Op = "insert_all"
+	OpFindByID   Op = "find_by_id"
+	OpDeleteByID Op = "delete_by_id"
+	OpUpdate     Op = "update"
+	OpFindAll    Op = "find_all"
+	OpApply      Op = "apply"
+)
+
+// Call is one repository operation on its way through the interceptors.
+// Order holds the order being written, and the order read once a
+// find_by_id returns. Orders is only used by insert_all, and Page and
+// Result only by find_all, and Batch only by apply.
+type Call struct {
+	Op     Op
+	ID     uint64
+	Order  model.Order
+	Orders []model.Order
+	Page   FindAllPage
+	Result FindResult
+	Batch  Batch
+}
+
+type Handler func(ctx context.Context, call *Call) error
+
+// Interceptor wraps a repository operation in the style of a grpc
+// interceptor: it can act before and after calling next, or answer the
+// call itself by filling in its results and not calling next at all.
+type Interceptor func(ctx context.Context, call *Call, next Handler) error
+
+type intercepted struct {
+	repo    Repository
+	handler Handler
+}
+
+// Intercept returns repo with the interceptors applied. The first one is
+// the outermost and sees every call first.
+func Intercept(repo Repository, interceptors ...Interceptor) Repository {
+
+	i := &intercepted{repo: repo}
+
+	i.handler = i.invoke
+
+	for n := len(interceptors) - 1; n >= 0; n-- {
+		interceptor, next := interceptors[n], i.handler
+		i.handler = func(ctx context.Context, call *Call) error {
+			return interceptor(ctx, call, next)
+		}
+	}
+
+	return i
+}
+
+func (i *intercepted) invoke(ctx context.Context, call *Call) error {
+
+	var err error
+
+	switch call.Op {
+	case OpInsert:
+		err = i.repo.Insert(ctx, call.Order)
+	case OpInsertAll:
+		err = i.repo.InsertAll(ctx, call.Orders)
+	case OpFindByID:
+		call.Order, err = i.repo.FindByID(ctx, call.ID)
+	case OpDeleteByID:
+		err = i.repo.DeleteByID(ctx, call.ID)
+	case OpUpdate:
+		err = i.repo.Update(ctx, call.Order)
+	case OpFindAll:
+		call.Result, err = i.repo.FindAll(ctx, call.Page)
+	case OpApply:
+		err = i.repo.Apply(ctx, call.Batch)
+	default:
+		err = fmt.Errorf("unknown repository operation %q", call.Op)
+	}
+
+	return err
+}
+
+func (i *intercepted) Insert(ctx context.Context, order model.Order) error {
+	return i.handler(ctx, &Call{Op: OpInsert, ID: order.OrderID, Order: order})
+}
+
+func (i *intercepted) InsertAll(ctx context.Context, orders []model.Order) error {
+	return i.handler(ctx, &Call{Op: OpInsertAll, Orders: orders})
+}
+
+func (i *intercepted) FindByID(ctx context.Context, id uint64) (model.Order, error) {
+
+	call := &Call{Op: OpFindByID, ID: id}
+
+	if err := i.handler(ctx, call); err != nil {
+		return model.Order{}, err
+	}
+
+	return call.Order, nil
+}
+
+func (i *intercepted) DeleteByID(ctx context.Context, id uint64) error {
+	return i.handler(ctx, &Call{Op: OpDeleteByID, ID: id})
+}
+
+func (i *intercepted) Update(ctx context.Context, order model.Order) error {
+	return i.handler(ctx, &Call{Op: OpUpdate, ID: order.OrderID, Order: order})
+}
+
+func (i *intercepted) FindAll(ctx context.Context, page FindAllPage) (FindResult, error) {
+
+	call := &Call{Op: OpFindAll, Page: page}
+
+	if err := i.handler(ctx, call); err != nil {
+		return FindResult{}, err
+	}
+
+	return call.Result, nil
+}
+
+func (i *intercepted) Apply(ctx context.Context, batch Batch) error {
+	return i.handler(ctx, &Call{Op: OpApply, Batch: batch})
+}
+
+// Log logs every operation at debug level, and at warn level those that
+// take longer than threshold. A zero threshold turns the warnings off.
+func Log(log *slog.Logger, threshold time.Duration) Interceptor {
+
+	return func(ctx context.Context, call *Call, next Handler) error {
+
+		start := time.Now()
+		err := next(ctx, call)
+		took := time.Since(start)
+
+		switch {
+		case threshold > 0 && took > threshold:
+			log.WarnContext(ctx, "slow repository operation", "op", call.Op, "id", call.ID, "duration", took, "error", err)
+		case log.Enabled(ctx, slog.LevelDebug):
+			log.DebugContext(ctx, "repository operation", "op", call.Op, "id", call.ID, "duration", took, "error", err)
+		}
+
+		return err
+	}
+}
@@ -2,57 +2,114 @@ package order
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"time"
 
+	"github.com/i101dev/microservices-NN/codec"
 	"github.com/i101dev/microservices-NN/model"
 	"github.com/redis/go-redis/v9"
 )
 
-var ErrNotExist = errors.New("order does not exist")
-
 type RedisRepo struct {
 	Client *redis.Client
+	Codec  codec.Codec
 }
 type FindAllPage struct {
 	Size   uint64
 	Offset uint64
+	// Consistent pages through a copy of the order set taken on the first
+	// page, so orders written meanwhile are neither repeated nor missed.
+	// The result names the copy in Snapshot, to be passed back with the
+	// cursor. Orders are still read as they are now, and those deleted
+	// since the copy are left out.
+	Consistent bool
+	Snapshot   string
+	// Keep leaves the snapshot, still named in the result, once its last
+	// page is read, for callers that may read that page again. It expires
+	// snapshotTTL later.
+	Keep bool
 }
 
 type FindResult struct {
-	Orders []model.Order
-	Cursor uint64
+	Orders   []model.Order
+	Cursor   uint64
+	Snapshot string
+}
+
+// snapshotTTL is how long a snapshot lasts after its last page was read.
+// The one read last deletes it, unless the page is read with Keep.
+const snapshotTTL = 10 * time.Minute
+
+func snapshotKey(name string) string {
+	return "orders:snapshot:" + name
 }
 
 func orderIDKey(id uint64) string {
 	return fmt.Sprintf("order:%d", id)
 }
 
+// historyKey is outside the order: prefix so index rebuilds skip it.
+func historyKey(id uint64) string {
+	return fmt.Sprintf("history:%d", id)
+}
+
+func (r *RedisRepo) codec() codec.Codec {
+	if r.Codec == nil {
+		return codec.JSON
+	}
+	return r.Codec
+}
+
+func (r *RedisRepo) encode(order model.Order) ([]byte, error) {
+
+	data, err := r.codec().Marshal(order)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode order to %s: %w", r.codec().Name(), err)
+	}
+
+	return data, nil
+}
+
+func (r *RedisRepo) decode(data []byte, order *model.Order) error {
+
+	c := r.codec()
+
+	// Values written before a codec switch are still JSON.
+	if len(data) > 0 && data[0] == '{' {
+		c = codec.JSON
+	}
+
+	if err := c.Unmarshal(data, order); err != nil {
+		return fmt.Errorf("failed to decode order from %s: %w", c.Name(), err)
+	}
+
+	return nil
+}
+
 func (r *RedisRepo) Insert(ctx context.Context, order model.Order) error {
 
-	data, err := json.Marshal(order)
+	data, err := r.encode(order)
 
 	if err != nil {
-		return fmt.Errorf("failed to encode order to JSON: %w", err)
+		return err
 	}
 
-	key := orderIDKey(uint64(order.OrderID))
+	key := orderIDKey(order.OrderID)
 	txn := r.Client.TxPipeline()
 
 	res := txn.SetNX(ctx, key, string(data), 0)
-	if err := res.Err(); err != nil {
-		txn.Discard()
-		return fmt.Errorf("failed to set: %w", err)
-	}
+	txn.SAdd(ctx, "orders", key)
 
-	if err := txn.SAdd(ctx, "orders", key).Err(); err != nil {
-		txn.Discard()
-		return fmt.Errorf("failed to add orders to set: %w", err)
+	if _, err := txn.Exec(ctx); err != nil {
+		return orderError(ErrUnavailable, "insert", order.OrderID, err)
 	}
 
-	if _, err := txn.Exec(ctx); err != nil {
-		return fmt.Errorf("failed to execute [insert] transaction: %w", err)
+	if !res.Val() {
+		return orderError(ErrExist, "insert", order.OrderID, nil)
 	}
 
 	return nil
@@ -65,15 +122,15 @@ func (r *RedisRepo) FindByID(ctx context.Context, id uint64) (model.Order, error
 	value, err := r.Client.Get(ctx, key).Result()
 
 	if errors.Is(err, redis.Nil) {
-		return model.Order{}, ErrNotExist
+		return model.Order{}, orderError(ErrNotExist, "find", id, nil)
 	} else if err != nil {
-		return model.Order{}, fmt.Errorf("error getting order: %w", err)
+		return model.Order{}, orderError(ErrUnavailable, "find", id, err)
 	}
 
 	var order model.Order
 
-	if err = json.Unmarshal([]byte(value), &order); err != nil {
-		return model.Order{}, fmt.Errorf("failed to decode order to JSON: %w", err)
+	if err = r.decode([]byte(value), &order); err != nil {
+		return model.Order{}, orderError(ErrCorrupt, "find", id, err)
 	}
 
 	return order, nil
@@ -84,23 +141,16 @@ func (r *RedisRepo) DeleteByID(ctx context.Context, id uint64) error {
 	key := orderIDKey(id)
 
 	txn := r.Client.TxPipeline()
-	err := txn.Del(ctx, key).Err()
-
-	if errors.Is(err, redis.Nil) {
-		txn.Discard()
-		return ErrNotExist
-	} else if err != nil {
-		txn.Discard()
-		return fmt.Errorf("error getting order: %w", err)
-	}
+	del := txn.Del(ctx, key)
+	txn.Del(ctx, historyKey(id))
+	txn.SRem(ctx, "orders", key)
 
-	if err := txn.SRem(ctx, "orders", key).Err(); err != nil {
-		txn.Discard()
-		return fmt.Errorf("failed to remove from orders set: %w", err)
+	if _, err := txn.Exec(ctx); err != nil {
+		return orderError(ErrUnavailable, "delete", id, err)
 	}
 
-	if _, err := txn.Exec(ctx); err != nil {
-		return fmt.Errorf("failed to execute [delete] transaction: %w", err)
+	if del.Val() == 0 {
+		return orderError(ErrNotExist, "delete", id, nil)
 	}
 
 	return nil
@@ -108,20 +158,20 @@ func (r *RedisRepo) DeleteByID(ctx context.Context, id uint64) error {
 
 func (r *RedisRepo) Update(ctx context.Context, order model.Order) error {
 
-	data, err := json.Marshal(order)
+	data, err := r.encode(order)
 
 	if err != nil {
-		return fmt.Errorf("failed to encode order to JSON: %w", err)
+		return err
 	}
 
-	key := orderIDKey(uint64(order.OrderID))
+	key := orderIDKey(order.OrderID)
 
-	err = r.Client.SetXX(ctx, key, string(data), 0).Err()
+	updated, err := r.Client.SetXX(ctx, key, string(data), 0).Result()
 
-	if errors.Is(err, redis.Nil) {
-		return ErrNotExist
-	} else if err != nil {
-		return fmt.Errorf("error getting order: %w", err)
+	if err != nil {
+		return orderError(ErrUnavailable, "update", order.OrderID, err)
+	} else if !updated {
+		return orderError(ErrNotExist, "update", order.OrderID, nil)
 	}
 
 	return nil
@@ -129,41 +179,427 @@ func (r *RedisRepo) Update(ctx context.Context, order model.Order) error {
 
 func (r *RedisRepo) FindAll(ctx context.Context, page FindAllPage) (FindResult, error) {
 
-	res := r.Client.SScan(ctx, "orders", uint64(page.Offset), "*", int64(page.Size))
+	set := "orders"
+
+	taken := false
+
+	if page.Consistent && page.Snapshot == "" {
+		name, err := r.snapshot(ctx)
+		if err != nil {
+			return FindResult{}, err
+		}
+		page.Snapshot, page.Offset, taken = name, 0, true
+	}
+
+	if page.Snapshot != "" {
+		set = snapshotKey(page.Snapshot)
+	}
+
+	if page.Snapshot != "" && !taken {
+
+		// Reading a page keeps the snapshot for another while.
+		kept, err := r.Client.Expire(ctx, set, snapshotTTL).Result()
+		if err != nil {
+			return FindResult{}, &Error{Kind: ErrUnavailable, Op: "scan", Key: set, Err: err}
+		} else if !kept {
+			return FindResult{}, &Error{Kind: ErrSnapshotExpired, Op: "scan", Key: set}
+		}
+	}
+
+	res := r.Client.SScan(ctx, set, uint64(page.Offset), "*", int64(page.Size))
 
 	keys, cursor, err := res.Result()
 
+	if err != nil {
+		return FindResult{}, &Error{Kind: ErrUnavailable, Op: "scan", Key: set, Err: err}
+	}
+
+	snapshot := page.Snapshot
+
+	if snapshot != "" && cursor == 0 && !page.Keep {
+		if err := r.Client.Unlink(ctx, set).Err(); err != nil {
+			fmt.Println("failed to drop listing snapshot:", err)
+		}
+		snapshot = ""
+	}
+
 	if len(keys) == 0 {
 		return FindResult{
-			Orders: []model.Order{},
+			Orders:   []model.Order{},
+			Cursor:   cursor,
+			Snapshot: snapshot,
 		}, nil
 	}
 
-	if err != nil {
-		return FindResult{}, fmt.Errorf("failed to get order IDs: %w", err)
-	}
-
 	xs, err := r.Client.MGet(ctx, keys...).Result()
 
 	if err != nil {
-		return FindResult{}, fmt.Errorf("failed to [MGet] orders: %w", err)
+		return FindResult{}, &Error{Kind: ErrUnavailable, Op: "find all", Key: "orders", Err: err}
 	}
 
-	orders := make([]model.Order, len(xs))
+	orders := make([]model.Order, 0, len(xs))
 
 	for i, x := range xs {
-		x := x.(string)
+		x, ok := x.(string)
+		if !ok {
+			// the key was deleted between SSCAN and MGET
+			continue
+		}
 
 		var order model.Order
-		if err := json.Unmarshal([]byte(x), &order); err != nil {
-			return FindResult{}, fmt.Errorf("failed to decode order to JSON: %w", err)
+		if err := r.decode([]byte(x), &order); err != nil {
+			return FindResult{}, &Error{Kind: ErrCorrupt, Op: "find all", Key: keys[i], Err: err}
 		}
 
-		orders[i] = order
+		orders = append(orders, order)
 	}
 
 	return FindResult{
-		Orders: orders,
-		Cursor: cursor,
+		Orders:   orders,
+		Cursor:   cursor,
+		Snapshot: snapshot,
 	}, nil
 }
+
+// snapshot copies the order set under a new name and returns it. The copy
+// of an empty set is no key at all, which the first page reads as empty.
+func (r *RedisRepo) snapshot(ctx context.Context) (string, error) {
+
+	b := make([]byte, 8)
+	rand.Read(b)
+	name := hex.EncodeToString(b)
+
+	pipe := r.Client.TxPipeline()
+	pipe.SUnionStore(ctx, snapshotKey(name), "orders")
+	pipe.Expire(ctx, snapshotKey(name), snapshotTTL)
+
+	if _, err := pipe.Exec(ctx); err != nil {
+		return "", &Error{Kind: ErrUnavailable, Op: "snapshot", Key: "orders", Err: err}
+	}
+
+	return name, nil
+}
+
+func (r *RedisRepo) RebuildIndex(ctx context.Context) (added int64, removed int64, err error) {
+
+	iter := r.Client.Scan(ctx, 0, "order:*", 100).Iterator()
+
+	for iter.Next(ctx) {
+		n, err := r.Client.SAdd(ctx, "orders", iter.Val()).Result()
+		if err != nil {
+			return added, removed, fmt.Errorf("failed to add key to orders set: %w", err)
+		}
+		added += n
+	}
+
+	if err := iter.Err(); err != nil {
+		return added, removed, fmt.Errorf("failed to scan order keys: %w", err)
+	}
+
+	members := r.Client.SScan(ctx, "orders", 0, "*", 100).Iterator()
+
+	for members.Next(ctx) {
+		key := members.Val()
+
+		exists, err := r.Client.Exists(ctx, key).Result()
+		if err != nil {
+			return added, removed, fmt.Errorf("failed to check order key: %w", err)
+		}
+
+		if exists == 0 {
+			if err := r.Client.SRem(ctx, "orders", key).Err(); err != nil {
+				return added, removed, fmt.Errorf("failed to remove stale key from orders set: %w", err)
+			}
+			removed++
+		}
+	}
+
+	if err := members.Err(); err != nil {
+		return added, removed, fmt.Errorf("failed to scan orders set: %w", err)
+	}
+
+	return added, removed, nil
+}
+
+type RawOrder struct {
+	Key   string
+	Data  []byte
+	Codec string
+	// TTL is negative when the key has no expiry.
+	TTL         time.Duration
+	Indexes     []string
+	DecodeError error
+}
+
+// Raw returns the stored bytes for an order as they are, along with the
+// metadata needed to debug a value that no longer decodes.
+func (r *RedisRepo) Raw(ctx context.Context, id uint64) (RawOrder, error) {
+
+	key := orderIDKey(id)
+
+	pipe := r.Client.Pipeline()
+
+	get := pipe.Get(ctx, key)
+	ttl := pipe.PTTL(ctx, key)
+	indexed := pipe.SIsMember(ctx, "orders", key)
+
+	if _, err := pipe.Exec(ctx); err != nil && !errors.Is(err, redis.Nil) {
+		return RawOrder{}, orderError(ErrUnavailable, "raw", id, err)
+	}
+
+	raw := RawOrder{
+		Key: key,
+		TTL: ttl.Val(),
+	}
+
+	if indexed.Val() {
+		raw.Indexes = append(raw.Indexes, "orders")
+	}
+
+	data, err := get.Bytes()
+	if errors.Is(err, redis.Nil) {
+		return raw, orderError(ErrNotExist, "raw", id, nil)
+	}
+
+	raw.Data = data
+
+	raw.Codec = r.codec().Name()
+	if len(data) > 0 && data[0] == '{' {
+		raw.Codec = codec.JSON.Name()
+	}
+
+	var o model.Order
+	raw.DecodeError = r.decode(data, &o)
+
+	return raw, nil
+}
+
+// insertAllScript writes nothing unless none of the order keys exist.
+// KEYS[1] is the orders set and the rest are order keys, with their values
+// in the same position in ARGV. It returns the 1-based position (in ARGV)
+// of the first existing order, or 0 once everything is written.
+var insertAllScript = redis.NewScript(`
+for i = 2, #KEYS do
+	if redis.call('EXISTS', KEYS[i]) == 1 then
+		return i - 1
+	end
+end
+for i = 2, #KEYS do
+	redis.call('SET', KEYS[i], ARGV[i - 1])
+	redis.call('SADD', KEYS[1], KEYS[i])
+end
+return 0
+`)
+
+func (r *RedisRepo) InsertAll(ctx context.Context, orders []model.Order) error {
+
+	if len(orders) == 0 {
+		return nil
+	}
+
+	keys := make([]string, 0, len(orders)+1)
+	values := make([]any, 0, len(orders))
+
+	keys = append(keys, "orders")
+
+	for _, order := range orders {
+
+		data, err := r.encode(order)
+		if err != nil {
+			return err
+		}
+
+		keys = append(keys, orderIDKey(order.OrderID))
+		values = append(values, string(data))
+	}
+
+	existing, err := insertAllScript.Run(ctx, r.Client, keys, values...).Int()
+	if err != nil {
+		return &Error{Kind: ErrUnavailable, Op: "insert all", Key: "orders", Err: err}
+	}
+
+	if existing > 0 {
+		return orderError(ErrExist, "insert all", orders[existing-1].OrderID, nil)
+	}
+
+	return nil
+}
+
+// InsertMany writes orders in one pipeline without any atomicity: orders
+// before a conflict are still inserted. Use InsertAll for all or nothing.
+func (r *RedisRepo) InsertMany(ctx context.Context, orders []model.Order) error {
+
+	pipe := r.Client.Pipeline()
+	results := make([]*redis.BoolCmd, len(orders))
+
+	for i, order := range orders {
+
+		data, err := r.encode(order)
+		if err != nil {
+			return err
+		}
+
+		key := orderIDKey(order.OrderID)
+
+		results[i] = pipe.SetNX(ctx, key, string(data), 0)
+		pipe.SAdd(ctx, "orders", key)
+	}
+
+	if _, err := pipe.Exec(ctx); err != nil {
+		return &Error{Kind: ErrUnavailable, Op: "insert many", Key: "orders", Err: err}
+	}
+
+	for i, res := range results {
+		if !res.Val() {
+			return orderError(ErrExist, "insert many", orders[i].OrderID, nil)
+		}
+	}
+
+	return nil
+}
+
+// Apply watches every order in the batch, checks them, and writes the
+// batch in one MULTI. A concurrent write to any of them between the check
+// and the write fails it with ErrConflict.
+func (r *RedisRepo) Apply(ctx context.Context, batch Batch) error {
+
+	inserts := make(map[string]string, len(batch.Insert))
+	updates := make(map[string]string, len(batch.Update))
+	keys := make([]string, 0, len(batch.Insert)+len(batch.Update))
+
+	for _, o := range batch.Insert {
+		data, err := r.encode(o)
+		if err != nil {
+			return err
+		}
+		key := orderIDKey(o.OrderID)
+		inserts[key] = string(data)
+		keys = append(keys, key)
+	}
+
+	for _, o := range batch.Update {
+		data, err := r.encode(o)
+		if err != nil {
+			return err
+		}
+		key := orderIDKey(o.OrderID)
+		updates[key] = string(data)
+		keys = append(keys, key)
+	}
+
+	history := make([]string, len(batch.History))
+
+	for i, entry := range batch.History {
+		data, err := json.Marshal(entry)
+		if err != nil {
+			return fmt.Errorf("failed to encode history entry: %w", err)
+		}
+		history[i] = string(data)
+	}
+
+	if len(keys) == 0 && len(history) == 0 {
+		return nil
+	}
+
+	err := r.Client.Watch(ctx, func(tx *redis.Tx) error {
+
+		for _, o := range batch.Insert {
+			n, err := tx.Exists(ctx, orderIDKey(o.OrderID)).Result()
+			if err != nil {
+				return orderError(ErrUnavailable, "apply", o.OrderID, err)
+			}
+			if n > 0 {
+				return orderError(ErrExist, "apply", o.OrderID, nil)
+			}
+		}
+
+		for _, o := range batch.Update {
+			if err := r.expect(ctx, tx, o.OrderID, batch.Expect); err != nil {
+				return err
+			}
+		}
+
+		_, err := tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			for key, data := range inserts {
+				pipe.Set(ctx, key, data, 0)
+				pipe.SAdd(ctx, "orders", key)
+			}
+			for key, data := range updates {
+				pipe.Set(ctx, key, data, 0)
+			}
+			for i, entry := range batch.History {
+				pipe.RPush(ctx, historyKey(entry.OrderID), history[i])
+			}
+			return nil
+		})
+
+		return err
+	}, keys...)
+
+	var orderErr *Error
+
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, redis.TxFailedErr):
+		return &Error{Kind: ErrConflict, Op: "apply", Key: "orders"}
+	case errors.As(err, &orderErr):
+		return err
+	default:
+		return &Error{Kind: ErrUnavailable, Op: "apply", Key: "orders", Err: err}
+	}
+}
+
+// expect checks that the stored order exists and, when expect has an
+// entry for it, that it was last updated at that time.
+func (r *RedisRepo) expect(ctx context.Context, tx *redis.Tx, id uint64, expect map[uint64]*time.Time) error {
+
+	value, err := tx.Get(ctx, orderIDKey(id)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return orderError(ErrNotExist, "apply", id, nil)
+	} else if err != nil {
+		return orderError(ErrUnavailable, "apply", id, err)
+	}
+
+	want, ok := expect[id]
+	if !ok {
+		return nil
+	}
+
+	var stored model.Order
+	if err := r.decode(value, &stored); err != nil {
+		return orderError(ErrCorrupt, "apply", id, err)
+	}
+
+	if !sameTime(stored.UpdatedAt, want) {
+		return orderError(ErrConflict, "apply", id, nil)
+	}
+
+	return nil
+}
+
+func sameTime(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
+
+// History returns the history entries of an order, oldest first.
+func (r *RedisRepo) History(ctx context.Context, id uint64) ([]model.HistoryEntry, error) {
+
+	values, err := r.Client.LRange(ctx, historyKey(id), 0, -1).Result()
+	if err != nil {
+		return nil, orderError(ErrUnavailable, "history", id, err)
+	}
+
+	entries := make([]model.HistoryEntry, len(values))
+
+	for i, v := range values {
+		if err := json.Unmarshal([]byte(v), &entries[i]); err != nil {
+			return nil, orderError(ErrCorrupt, "history", id, err)
+		}
+	}
+
+	return entries, nil
+}
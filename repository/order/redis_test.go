@@ -0,0 +1,238 @@
+package order_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/i101dev/microservices-NN/model"
+	"github.com/i101dev/microservices-NN/repository/order"
+	"github.com/i101dev/microservices-NN/repository/order/repotest"
+	"github.com/redis/go-redis/v9"
+	"github.com/testcontainers/testcontainers-go"
+	tcredis "github.com/testcontainers/testcontainers-go/modules/redis"
+)
+
+// redisAddrEnv holds the address of a Redis to run the suites against,
+// such as localhost:6379. When it is unset, a redisImage container is
+// started instead, and the tests are skipped without Docker.
+const redisAddrEnv = "REPOTEST_REDIS_ADDR"
+
+const redisImage = "redis:7"
+
+// redisTestDB is flushed before every subtest, so it must not hold
+// anything worth keeping.
+const redisTestDB = 15
+
+// The container is started by the first test that needs it and stopped
+// by TestMain once they have all run.
+var (
+	containerOnce sync.Once
+	container     *tcredis.RedisContainer
+	containerAddr string
+	containerErr  error
+)
+
+func TestMain(m *testing.M) {
+
+	code := m.Run()
+
+	if container != nil {
+		if err := container.Terminate(context.Background()); err != nil {
+			fmt.Println("failed to stop redis container:", err)
+		}
+	}
+
+	os.Exit(code)
+}
+
+func redisAddr(t *testing.T) string {
+
+	if addr := os.Getenv(redisAddrEnv); addr != "" {
+		return addr
+	}
+
+	if testing.Short() {
+		t.Skipf("%s is not set, and no container is started in short mode", redisAddrEnv)
+	}
+
+	skipWithoutDocker(t)
+
+	containerOnce.Do(func() {
+		ctx := context.Background()
+		if container, containerErr = tcredis.Run(ctx, redisImage); containerErr == nil {
+			containerAddr, containerErr = container.Endpoint(ctx, "")
+		}
+	})
+
+	if containerErr != nil {
+		t.Fatalf("failed to start %s: %v", redisImage, containerErr)
+	}
+
+	return containerAddr
+}
+
+// skipWithoutDocker is testcontainers.SkipIfProviderIsNotHealthy, which
+// panics rather than skips when it finds no Docker host at all.
+func skipWithoutDocker(t *testing.T) {
+
+	defer func() {
+		if r := recover(); r != nil {
+			t.Skipf("no Docker to start %s in: %v", redisImage, r)
+		}
+	}()
+
+	testcontainers.SkipIfProviderIsNotHealthy(t)
+}
+
+// newClient connects to the test database and flushes it.
+func newClient(t *testing.T, addr string, opts redis.Options) *redis.Client {
+
+	opts.Addr = addr
+	opts.DB = redisTestDB
+
+	client := redis.NewClient(&opts)
+	t.Cleanup(func() { client.Close() })
+
+	if err := client.FlushDB(context.Background()).Err(); err != nil {
+		t.Fatalf("failed to flush redis db %d: %v", redisTestDB, err)
+	}
+
+	return client
+}
+
+func redisFactory(t *testing.T) repotest.Factory {
+
+	addr := redisAddr(t)
+
+	return func(t *testing.T) order.Repository {
+		return &order.RedisRepo{Client: newClient(t, addr, redis.Options{})}
+	}
+}
+
+func TestRedisRepo(t *testing.T) {
+	repotest.RunSuite(t, redisFactory(t))
+}
+
+// Update is a plain write, last one wins, so NoLostUpdates is skipped.
+// The service reads, modifies and writes through Apply with Expect, which
+// NoLostApplies covers.
+func TestRedisRepoConcurrency(t *testing.T) {
+	repotest.RunConcurrencySuite(t, redisFactory(t), repotest.ConcurrencyOptions{})
+}
+
+// cutConn drops the connection halfway through the first write made once
+// cut is set, like a connection killed while a transaction is being sent.
+type cutConn struct {
+	net.Conn
+	cut *atomic.Bool
+}
+
+func (c *cutConn) Write(b []byte) (int, error) {
+
+	if !c.cut.CompareAndSwap(true, false) {
+		return c.Conn.Write(b)
+	}
+
+	n, _ := c.Conn.Write(b[:len(b)/2])
+	c.Conn.Close()
+
+	return n, net.ErrClosed
+}
+
+// TestRedisRepoPipelineError checks that a transaction cut off by a
+// dropped connection is reported as ErrUnavailable and leaves nothing
+// behind.
+func TestRedisRepoPipelineError(t *testing.T) {
+
+	ctx := context.Background()
+	addr := redisAddr(t)
+
+	var cut atomic.Bool
+
+	dial := func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := (&net.Dialer{}).DialContext(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+		return &cutConn{Conn: conn, cut: &cut}, nil
+	}
+
+	// Without retries the cut transaction is not sent again on a fresh
+	// connection.
+	repo := &order.RedisRepo{Client: newClient(t, addr, redis.Options{Dialer: dial, MaxRetries: -1})}
+	check := &order.RedisRepo{Client: newClient(t, addr, redis.Options{})}
+
+	o := repotest.NewOrder()
+
+	cut.Store(true)
+
+	if err := repo.Insert(ctx, o); !errors.Is(err, order.ErrUnavailable) {
+		t.Fatalf("Insert on a dropped connection = %v, want ErrUnavailable", err)
+	}
+
+	if _, err := check.FindByID(ctx, o.OrderID); !errors.Is(err, order.ErrNotExist) {
+		t.Errorf("FindByID after the failed insert = %v, want ErrNotExist", err)
+	}
+
+	if n, err := check.Client.SCard(ctx, "orders").Result(); err != nil || n != 0 {
+		t.Errorf("orders set after the failed insert has %d members (%v), want 0", n, err)
+	}
+
+	if err := repo.Insert(ctx, o); err != nil {
+		t.Errorf("Insert on a new connection = %v", err)
+	}
+}
+
+// TestRedisRepoFindAllPages lists more orders than one SSCAN returns, so
+// FindAll has to follow the cursor.
+func TestRedisRepoFindAllPages(t *testing.T) {
+
+	ctx := context.Background()
+	repo := &order.RedisRepo{Client: newClient(t, redisAddr(t), redis.Options{})}
+
+	const total, size = 1000, 100
+
+	want := map[uint64]bool{}
+
+	for i := 0; i < total; i++ {
+		o := repotest.NewOrder()
+		if err := repo.Insert(ctx, o); err != nil {
+			t.Fatalf("Insert = %v", err)
+		}
+		want[o.OrderID] = true
+	}
+
+	pages := 0
+	seen := map[uint64]int{}
+
+	err := order.ForEachPage(ctx, repo, size, func(orders []model.Order) error {
+		pages++
+		for _, o := range orders {
+			seen[o.OrderID]++
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ForEachPage = %v", err)
+	}
+
+	if pages < 2 {
+		t.Errorf("read %d orders in %d page, want more than one", total, pages)
+	}
+
+	for id := range want {
+		if seen[id] != 1 {
+			t.Errorf("order %d listed %d times, want once", id, seen[id])
+		}
+	}
+
+	if len(seen) != total {
+		t.Errorf("listed %d orders, want %d", len(seen), total)
+	}
+}
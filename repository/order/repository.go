@@ -0,0 +1,78 @@
+package order
+
+import (
+	"context"
+	"time"
+
+	"github.com/i101dev/microservices-NN/model"
+)
+
+type Repository interface {
+	Insert(ctx context.Context, order model.Order) error
+	// InsertAll inserts every order or none of them. If one already
+	// exists it returns an *Error of kind ErrExist naming that order.
+	InsertAll(ctx context.Context, orders []model.Order) error
+	FindByID(ctx context.Context, id uint64) (model.Order, error)
+	DeleteByID(ctx context.Context, id uint64) error
+	Update(ctx context.Context, order model.Order) error
+	FindAll(ctx context.Context, page FindAllPage) (FindResult, error)
+	// Apply makes every write in the batch or none of them.
+	Apply(ctx context.Context, batch Batch) error
+}
+
+// Batch is a set of writes that only make sense together, such as the
+// orders of a merge, with the history entries that record them. Apply
+// fails with ErrExist if an inserted order exists, ErrNotExist if an
+// updated one does not, and ErrConflict if an updated order's UpdatedAt
+// no longer matches the one in Expect, meaning it changed after it was
+// read. Updates without an entry in Expect are not checked.
+type Batch struct {
+	Insert  []model.Order
+	Update  []model.Order
+	Expect  map[uint64]*time.Time
+	History []model.HistoryEntry
+}
+
+// Reshapes reports whether the batch is a merge or a split, which moves
+// items between orders rather than placing or cancelling any.
+func (b Batch) Reshapes() bool {
+
+	for _, entry := range b.History {
+		switch entry.Action {
+		case model.HistoryMergedInto, model.HistoryMergedFrom, model.HistorySplitInto, model.HistorySplitFrom:
+			return true
+		}
+	}
+
+	return false
+}
+
+func ForEachPage(ctx context.Context, repo Repository, size uint64, fn func([]model.Order) error) error {
+	return forEachPage(ctx, repo, FindAllPage{Size: size}, fn)
+}
+
+// ForEachSnapshotPage is ForEachPage over a snapshot of the orders, for
+// exports that must see each order once while others are written.
+func ForEachSnapshotPage(ctx context.Context, repo Repository, size uint64, fn func([]model.Order) error) error {
+	return forEachPage(ctx, repo, FindAllPage{Size: size, Consistent: true}, fn)
+}
+
+func forEachPage(ctx context.Context, repo Repository, page FindAllPage, fn func([]model.Order) error) error {
+
+	for {
+		res, err := repo.FindAll(ctx, page)
+
+		if err != nil {
+			return err
+		}
+
+		if err := fn(res.Orders); err != nil {
+			return err
+		}
+
+		page.Offset, page.Snapshot = res.Cursor, res.Snapshot
+		if page.Offset == 0 {
+			return nil
+		}
+	}
+}
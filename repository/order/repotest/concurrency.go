@@ -0,0 +1,244 @@
+package repotest
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/i101dev/microservices-NN/model"
+	"github.com/i101dev/microservices-NN/repository/order"
+)
+
+// StressEnv holds a duration such as "5m". When it is set, the concurrency
+// suite keeps running rounds until that much time has passed.
+const StressEnv = "REPOTEST_STRESS"
+
+type ConcurrencyOptions struct {
+	// OptimisticLocking enables the lost-update check. Backends that set it
+	// must reject a stale Update with order.ErrConflict.
+	OptimisticLocking bool
+	Writers           int
+	Rounds            int
+}
+
+func RunConcurrencySuite(t *testing.T, factory Factory, opts ConcurrencyOptions) {
+
+	if opts.Writers == 0 {
+		opts.Writers = 8
+	}
+
+	if opts.Rounds == 0 {
+		opts.Rounds = 20
+	}
+
+	t.Run("UpdateDeleteRace", func(t *testing.T) {
+		repo := factory(t)
+		soak(t, opts.Rounds, func() { raceUpdateDelete(t, repo, opts) })
+	})
+
+	t.Run("ConcurrentWriters", func(t *testing.T) {
+		repo := factory(t)
+		soak(t, opts.Rounds, func() { testConcurrentUpdates(t, repo) })
+	})
+
+	t.Run("NoLostApplies", func(t *testing.T) {
+		repo := factory(t)
+		soak(t, opts.Rounds, func() { testNoLostApplies(t, repo, opts) })
+	})
+
+	t.Run("NoLostUpdates", func(t *testing.T) {
+		if !opts.OptimisticLocking {
+			t.Skip("backend does not implement optimistic locking")
+		}
+		repo := factory(t)
+		soak(t, opts.Rounds, func() { testNoLostUpdates(t, repo, opts) })
+	})
+}
+
+func soak(t *testing.T, rounds int, fn func()) {
+
+	deadline := time.Time{}
+
+	if value := os.Getenv(StressEnv); value != "" {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			t.Fatalf("invalid %s=%q: %v", StressEnv, value, err)
+		}
+		deadline = time.Now().Add(d)
+	}
+
+	for i := 0; i < rounds || time.Now().Before(deadline); i++ {
+		fn()
+		if t.Failed() {
+			t.Logf("failed in round %d", i+1)
+			return
+		}
+	}
+}
+
+func raceUpdateDelete(t *testing.T, repo order.Repository, opts ConcurrencyOptions) {
+
+	ctx := context.Background()
+
+	o := NewOrder()
+	mustInsert(t, repo, o)
+
+	var deleted atomic.Bool
+	var wg sync.WaitGroup
+
+	for w := 0; w < opts.Writers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < 10; i++ {
+				update := o
+				update.LineItems = []model.LineItem{{ItemID: uuid.New(), Quantity: uint(w + 1), Price: uint(i + 1)}}
+
+				err := repo.Update(ctx, update)
+				switch {
+				case err == nil:
+				case errors.Is(err, order.ErrNotExist):
+					if !deleted.Load() {
+						t.Errorf("Update returned ErrNotExist before the order was deleted")
+					}
+					return
+				case opts.OptimisticLocking && errors.Is(err, order.ErrConflict):
+				default:
+					t.Errorf("Update racing Delete = %v", err)
+					return
+				}
+			}
+		}(w)
+	}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		deleted.Store(true)
+		if err := repo.DeleteByID(ctx, o.OrderID); err != nil {
+			t.Errorf("DeleteByID racing Update = %v", err)
+		}
+	}()
+
+	wg.Wait()
+
+	if _, err := repo.FindByID(ctx, o.OrderID); !errors.Is(err, order.ErrNotExist) {
+		t.Errorf("order %d came back after delete: FindByID = %v", o.OrderID, err)
+	}
+}
+
+func testNoLostUpdates(t *testing.T, repo order.Repository, opts ConcurrencyOptions) {
+
+	ctx := context.Background()
+
+	const increments = 10
+
+	o := NewOrder()
+	o.LineItems[0].Quantity = 0
+	mustInsert(t, repo, o)
+
+	var wg sync.WaitGroup
+
+	for w := 0; w < opts.Writers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < increments; i++ {
+				for {
+					current, err := repo.FindByID(ctx, o.OrderID)
+					if err != nil {
+						t.Errorf("FindByID = %v", err)
+						return
+					}
+
+					current.LineItems[0].Quantity++
+
+					err = repo.Update(ctx, current)
+					if errors.Is(err, order.ErrConflict) {
+						continue
+					} else if err != nil {
+						t.Errorf("Update = %v", err)
+						return
+					}
+					break
+				}
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	got, err := repo.FindByID(ctx, o.OrderID)
+	if err != nil {
+		t.Fatalf("FindByID = %v", err)
+	}
+
+	if want := uint(opts.Writers * increments); got.LineItems[0].Quantity != want {
+		t.Errorf("lost updates: quantity %d, want %d", got.LineItems[0].Quantity, want)
+	}
+}
+
+// testNoLostApplies is testNoLostUpdates for read-modify-write through
+// Apply with Expect, which every backend must check.
+func testNoLostApplies(t *testing.T, repo order.Repository, opts ConcurrencyOptions) {
+
+	ctx := context.Background()
+
+	const increments = 10
+
+	o := NewOrder()
+	o.LineItems[0].Quantity = 0
+	mustInsert(t, repo, o)
+
+	var wg sync.WaitGroup
+
+	for w := 0; w < opts.Writers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < increments; i++ {
+				for {
+					current, err := repo.FindByID(ctx, o.OrderID)
+					if err != nil {
+						t.Errorf("FindByID = %v", err)
+						return
+					}
+
+					readAt := current.UpdatedAt
+					next := readAt.Add(time.Millisecond)
+
+					current.LineItems[0].Quantity++
+					current.UpdatedAt = &next
+
+					err = repo.Apply(ctx, order.Batch{
+						Update: []model.Order{current},
+						Expect: map[uint64]*time.Time{o.OrderID: readAt},
+					})
+					if errors.Is(err, order.ErrConflict) {
+						continue
+					} else if err != nil {
+						t.Errorf("Apply = %v", err)
+						return
+					}
+					break
+				}
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	got, err := repo.FindByID(ctx, o.OrderID)
+	if err != nil {
+		t.Fatalf("FindByID = %v", err)
+	}
+
+	if want := uint(opts.Writers * increments); got.LineItems[0].Quantity != want {
+		t.Errorf("lost applies: quantity %d, want %d", got.LineItems[0].Quantity, want)
+	}
+}
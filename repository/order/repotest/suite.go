@@ -0,0 +1,555 @@
+package repotest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/i101dev/microservices-NN/model"
+	"github.com/i101dev/microservices-NN/repository/order"
+)
+
+// Factory returns an empty repository. It is called once per subtest.
+type Factory func(t *testing.T) order.Repository
+
+func RunSuite(t *testing.T, factory Factory) {
+
+	t.Run("InsertAndFind", func(t *testing.T) { testInsertAndFind(t, factory(t)) })
+	t.Run("InsertDuplicate", func(t *testing.T) { testInsertDuplicate(t, factory(t)) })
+	t.Run("InsertAll", func(t *testing.T) { testInsertAll(t, factory(t)) })
+	t.Run("InsertAllConflict", func(t *testing.T) { testInsertAllConflict(t, factory(t)) })
+	t.Run("Apply", func(t *testing.T) { testApply(t, factory(t)) })
+	t.Run("ApplyStale", func(t *testing.T) { testApplyStale(t, factory(t)) })
+	t.Run("FindMissing", func(t *testing.T) { testFindMissing(t, factory(t)) })
+	t.Run("Update", func(t *testing.T) { testUpdate(t, factory(t)) })
+	t.Run("UpdateMissing", func(t *testing.T) { testUpdateMissing(t, factory(t)) })
+	t.Run("Delete", func(t *testing.T) { testDelete(t, factory(t)) })
+	t.Run("DeleteMissing", func(t *testing.T) { testDeleteMissing(t, factory(t)) })
+	t.Run("FindAllEmpty", func(t *testing.T) { testFindAllEmpty(t, factory(t)) })
+	t.Run("FindAllExhaustive", func(t *testing.T) { testFindAllExhaustive(t, factory(t)) })
+	t.Run("ConcurrentUpdates", func(t *testing.T) { testConcurrentUpdates(t, factory(t)) })
+	t.Run("UpdatePreservesTimestamps", func(t *testing.T) { testUpdatePreservesTimestamps(t, factory(t)) })
+}
+
+func NewOrder() model.Order {
+
+	now := time.Now().UTC().Truncate(time.Millisecond)
+
+	o := model.Order{
+		OrderID:    rand.Uint64(),
+		CustomerID: uuid.New(),
+		LineItems: []model.LineItem{
+			{ItemID: uuid.New(), Quantity: 2, Price: 1999},
+			{ItemID: uuid.New(), Quantity: 1, Price: 500},
+		},
+		CreatedAt: &now,
+		UpdatedAt: &now,
+		Shipping:  &model.Shipping{Method: "standard", Country: "GB"},
+		EstimatedDelivery: &model.DeliveryEstimate{
+			Earliest: now.Truncate(24*time.Hour).AddDate(0, 0, 2),
+			Latest:   now.Truncate(24*time.Hour).AddDate(0, 0, 5),
+			Carrier:  "royal-mail",
+			Rule:     "gb-standard",
+		},
+		Tracking: &model.Tracking{
+			Carrier: "dhl",
+			Number:  "JD014600006281230",
+			Status:  model.TrackingInTransit,
+			At:      &now,
+		},
+		Payment: &model.Payment{
+			Reference:    "pi_3NqL2e",
+			Status:       model.PaymentCaptured,
+			Authorized:   4498,
+			Captured:     4498,
+			AuthorizedAt: &now,
+			CapturedAt:   &now,
+		},
+	}
+
+	o.Backorder = &model.Backorder{SplitFrom: rand.Uint64(), Since: now, FulfilledAt: &now}
+	o.BackorderID = rand.Uint64()
+
+	o.Tax = &model.Tax{}
+	for _, item := range o.LineItems {
+		line := model.TaxLine{ItemID: item.ItemID, Jurisdiction: "GB", Rate: 0.2, Amount: item.Price * item.Quantity / 5}
+		o.Tax.Lines = append(o.Tax.Lines, line)
+		o.Tax.Total += line.Amount
+	}
+
+	o.Redemptions = []model.Redemption{
+		{Source: model.RedemptionGiftCard, Code: "GIFT-" + uuid.NewString()[:8], Amount: 1000, At: now},
+		{Source: model.RedemptionStoreCredit, Amount: 250, At: now},
+	}
+
+	o.QuoteID = uuid.NewString()
+	o.Tags = []string{"rush", "vip"}
+
+	return o
+}
+
+func mustInsert(t *testing.T, repo order.Repository, o model.Order) {
+	t.Helper()
+	if err := repo.Insert(context.Background(), o); err != nil {
+		t.Fatalf("Insert(%d) = %v", o.OrderID, err)
+	}
+}
+
+func assertEqual(t *testing.T, got, want model.Order) {
+	t.Helper()
+	if !equalOrders(got, want) {
+		t.Fatalf("order mismatch\n got: %+v\nwant: %+v", got, want)
+	}
+}
+
+func equalOrders(a, b model.Order) bool {
+
+	sameTime := func(x, y *time.Time) bool {
+		if x == nil || y == nil {
+			return x == y
+		}
+		return x.Equal(*y)
+	}
+
+	return a.OrderID == b.OrderID &&
+		a.CustomerID == b.CustomerID &&
+		reflect.DeepEqual(a.LineItems, b.LineItems) &&
+		sameTime(a.CreatedAt, b.CreatedAt) &&
+		sameTime(a.ShippedAt, b.ShippedAt) &&
+		sameTime(a.CompletedAt, b.CompletedAt) &&
+		sameTime(a.CancelledAt, b.CancelledAt) &&
+		sameTime(a.UpdatedAt, b.UpdatedAt) &&
+		sameTime(a.FlaggedAt, b.FlaggedAt) &&
+		sameTime(a.ApprovedAt, b.ApprovedAt) &&
+		a.ReviewReason == b.ReviewReason &&
+		a.PossibleDuplicate == b.PossibleDuplicate &&
+		reflect.DeepEqual(a.Shipping, b.Shipping) &&
+		sameEstimate(a.EstimatedDelivery, b.EstimatedDelivery) &&
+		sameTracking(a.Tracking, b.Tracking) &&
+		samePayment(a.Payment, b.Payment) &&
+		reflect.DeepEqual(a.Tax, b.Tax) &&
+		sameBackorder(a.Backorder, b.Backorder) &&
+		a.BackorderID == b.BackorderID &&
+		sameRedemptions(a.Redemptions, b.Redemptions) &&
+		a.QuoteID == b.QuoteID &&
+		slices.Equal(a.Tags, b.Tags)
+}
+
+func sameEstimate(a, b *model.DeliveryEstimate) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Earliest.Equal(b.Earliest) && a.Latest.Equal(b.Latest) && a.Carrier == b.Carrier && a.Rule == b.Rule
+}
+
+func sameTracking(a, b *model.Tracking) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	sameAt := a.At == b.At || a.At != nil && b.At != nil && a.At.Equal(*b.At)
+	return a.Carrier == b.Carrier && a.Number == b.Number && a.Status == b.Status && sameAt
+}
+
+func samePayment(a, b *model.Payment) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	sameAt := func(x, y *time.Time) bool {
+		return x == y || x != nil && y != nil && x.Equal(*y)
+	}
+	return a.Reference == b.Reference && a.Status == b.Status &&
+		a.Authorized == b.Authorized && a.Captured == b.Captured && a.Refunded == b.Refunded &&
+		sameAt(a.AuthorizedAt, b.AuthorizedAt) && sameAt(a.CapturedAt, b.CapturedAt) && sameAt(a.RefundedAt, b.RefundedAt)
+}
+
+func sameBackorder(a, b *model.Backorder) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	sameAt := a.FulfilledAt == b.FulfilledAt || a.FulfilledAt != nil && b.FulfilledAt != nil && a.FulfilledAt.Equal(*b.FulfilledAt)
+	return a.SplitFrom == b.SplitFrom && a.Since.Equal(b.Since) && sameAt
+}
+
+func sameRedemptions(a, b []model.Redemption) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].Source != b[i].Source || a[i].Code != b[i].Code || a[i].Amount != b[i].Amount || !a[i].At.Equal(b[i].At) {
+			return false
+		}
+	}
+	return true
+}
+
+func testInsertAndFind(t *testing.T, repo order.Repository) {
+
+	o := NewOrder()
+	mustInsert(t, repo, o)
+
+	got, err := repo.FindByID(context.Background(), o.OrderID)
+	if err != nil {
+		t.Fatalf("FindByID = %v", err)
+	}
+
+	assertEqual(t, got, o)
+}
+
+func testInsertDuplicate(t *testing.T, repo order.Repository) {
+
+	o := NewOrder()
+	mustInsert(t, repo, o)
+
+	dup := o
+	dup.CustomerID = uuid.New()
+
+	if err := repo.Insert(context.Background(), dup); !errors.Is(err, order.ErrExist) {
+		t.Fatalf("second Insert = %v, want ErrExist", err)
+	}
+
+	got, err := repo.FindByID(context.Background(), o.OrderID)
+	if err != nil {
+		t.Fatalf("FindByID = %v", err)
+	}
+
+	assertEqual(t, got, o)
+}
+
+func testInsertAll(t *testing.T, repo order.Repository) {
+
+	orders := []model.Order{NewOrder(), NewOrder(), NewOrder()}
+
+	if err := repo.InsertAll(context.Background(), orders); err != nil {
+		t.Fatalf("InsertAll = %v", err)
+	}
+
+	for _, o := range orders {
+		got, err := repo.FindByID(context.Background(), o.OrderID)
+		if err != nil {
+			t.Fatalf("FindByID(%d) = %v", o.OrderID, err)
+		}
+		assertEqual(t, got, o)
+	}
+
+	res, err := repo.FindAll(context.Background(), order.FindAllPage{Size: 10})
+	if err != nil {
+		t.Fatalf("FindAll = %v", err)
+	}
+
+	if len(res.Orders) != len(orders) {
+		t.Fatalf("FindAll listed %d orders, want %d", len(res.Orders), len(orders))
+	}
+}
+
+// testInsertAllConflict checks that one existing order keeps the rest of
+// the batch from being written, and that the error names it.
+func testInsertAllConflict(t *testing.T, repo order.Repository) {
+
+	existing := NewOrder()
+	mustInsert(t, repo, existing)
+
+	orders := []model.Order{NewOrder(), existing, NewOrder()}
+
+	err := repo.InsertAll(context.Background(), orders)
+
+	var oe *order.Error
+	if !errors.Is(err, order.ErrExist) || !errors.As(err, &oe) || oe.ID != existing.OrderID {
+		t.Fatalf("InsertAll = %v, want ErrExist for order %d", err, existing.OrderID)
+	}
+
+	for _, o := range []model.Order{orders[0], orders[2]} {
+		if _, err := repo.FindByID(context.Background(), o.OrderID); !errors.Is(err, order.ErrNotExist) {
+			t.Fatalf("InsertAll wrote order %d despite the conflict: FindByID = %v", o.OrderID, err)
+		}
+	}
+}
+
+func testApply(t *testing.T, repo order.Repository) {
+
+	existing := NewOrder()
+	mustInsert(t, repo, existing)
+
+	later := existing.UpdatedAt.Add(time.Second)
+	updated := existing
+	updated.LineItems = updated.LineItems[:1]
+	updated.UpdatedAt = &later
+
+	inserted := NewOrder()
+
+	err := repo.Apply(context.Background(), order.Batch{
+		Insert: []model.Order{inserted},
+		Update: []model.Order{updated},
+		Expect: map[uint64]*time.Time{existing.OrderID: existing.UpdatedAt},
+	})
+	if err != nil {
+		t.Fatalf("Apply = %v", err)
+	}
+
+	for _, want := range []model.Order{inserted, updated} {
+		got, err := repo.FindByID(context.Background(), want.OrderID)
+		if err != nil {
+			t.Fatalf("FindByID(%d) = %v", want.OrderID, err)
+		}
+		assertEqual(t, got, want)
+	}
+}
+
+// testApplyStale checks that an update of an order that changed since it
+// was read keeps the whole batch from being written.
+func testApplyStale(t *testing.T, repo order.Repository) {
+
+	existing := NewOrder()
+	mustInsert(t, repo, existing)
+
+	readAt := existing.UpdatedAt
+	later := readAt.Add(time.Second)
+	existing.UpdatedAt = &later
+
+	if err := repo.Update(context.Background(), existing); err != nil {
+		t.Fatalf("Update = %v", err)
+	}
+
+	inserted := NewOrder()
+
+	err := repo.Apply(context.Background(), order.Batch{
+		Insert: []model.Order{inserted},
+		Update: []model.Order{existing},
+		Expect: map[uint64]*time.Time{existing.OrderID: readAt},
+	})
+	if !errors.Is(err, order.ErrConflict) {
+		t.Fatalf("Apply = %v, want ErrConflict", err)
+	}
+
+	if _, err := repo.FindByID(context.Background(), inserted.OrderID); !errors.Is(err, order.ErrNotExist) {
+		t.Fatalf("Apply wrote order %d despite the conflict: FindByID = %v", inserted.OrderID, err)
+	}
+}
+
+func testFindMissing(t *testing.T, repo order.Repository) {
+
+	if _, err := repo.FindByID(context.Background(), rand.Uint64()); !errors.Is(err, order.ErrNotExist) {
+		t.Fatalf("FindByID = %v, want ErrNotExist", err)
+	}
+}
+
+func testUpdate(t *testing.T, repo order.Repository) {
+
+	o := NewOrder()
+	mustInsert(t, repo, o)
+
+	if err := o.Ship(time.Now().UTC().Truncate(time.Millisecond)); err != nil {
+		t.Fatalf("Ship = %v", err)
+	}
+
+	if err := repo.Update(context.Background(), o); err != nil {
+		t.Fatalf("Update = %v", err)
+	}
+
+	got, err := repo.FindByID(context.Background(), o.OrderID)
+	if err != nil {
+		t.Fatalf("FindByID = %v", err)
+	}
+
+	assertEqual(t, got, o)
+}
+
+// testUpdatePreservesTimestamps walks an order through its lifecycle and
+// checks that every stored timestamp survives each Update unchanged.
+func testUpdatePreservesTimestamps(t *testing.T, repo order.Repository) {
+
+	o := NewOrder()
+	created := *o.CreatedAt
+	mustInsert(t, repo, o)
+
+	steps := []struct {
+		status string
+		at     func(*model.Order) *time.Time
+	}{
+		{model.StatusShipped, func(o *model.Order) *time.Time { return o.ShippedAt }},
+		{model.StatusCompleted, func(o *model.Order) *time.Time { return o.CompletedAt }},
+	}
+
+	for i, step := range steps {
+		now := created.Add(time.Duration(i+1) * time.Minute)
+
+		if err := o.Transition(step.status, now); err != nil {
+			t.Fatalf("Transition(%s) = %v", step.status, err)
+		}
+		o.UpdatedAt = &now
+
+		if err := repo.Update(context.Background(), o); err != nil {
+			t.Fatalf("Update = %v", err)
+		}
+
+		got, err := repo.FindByID(context.Background(), o.OrderID)
+		if err != nil {
+			t.Fatalf("FindByID = %v", err)
+		}
+
+		assertEqual(t, got, o)
+
+		if got.CreatedAt == nil || !got.CreatedAt.Equal(created) {
+			t.Fatalf("CreatedAt after %s = %v, want %v", step.status, got.CreatedAt, created)
+		}
+
+		if at := step.at(&got); at == nil || !at.Equal(now) {
+			t.Fatalf("%s timestamp = %v, want %v", step.status, at, now)
+		}
+
+		if got.UpdatedAt == nil || !got.UpdatedAt.Equal(now) {
+			t.Fatalf("UpdatedAt after %s = %v, want %v", step.status, got.UpdatedAt, now)
+		}
+	}
+}
+
+func testUpdateMissing(t *testing.T, repo order.Repository) {
+
+	o := NewOrder()
+
+	if err := repo.Update(context.Background(), o); !errors.Is(err, order.ErrNotExist) {
+		t.Fatalf("Update = %v, want ErrNotExist", err)
+	}
+
+	if _, err := repo.FindByID(context.Background(), o.OrderID); !errors.Is(err, order.ErrNotExist) {
+		t.Fatalf("Update of a missing order created it: FindByID = %v", err)
+	}
+}
+
+func testDelete(t *testing.T, repo order.Repository) {
+
+	o := NewOrder()
+	mustInsert(t, repo, o)
+
+	if err := repo.DeleteByID(context.Background(), o.OrderID); err != nil {
+		t.Fatalf("DeleteByID = %v", err)
+	}
+
+	if _, err := repo.FindByID(context.Background(), o.OrderID); !errors.Is(err, order.ErrNotExist) {
+		t.Fatalf("FindByID after delete = %v, want ErrNotExist", err)
+	}
+
+	res, err := repo.FindAll(context.Background(), order.FindAllPage{Size: 10})
+	if err != nil {
+		t.Fatalf("FindAll = %v", err)
+	}
+
+	for _, got := range res.Orders {
+		if got.OrderID == o.OrderID {
+			t.Fatalf("deleted order %d still listed", o.OrderID)
+		}
+	}
+}
+
+func testDeleteMissing(t *testing.T, repo order.Repository) {
+
+	if err := repo.DeleteByID(context.Background(), rand.Uint64()); !errors.Is(err, order.ErrNotExist) {
+		t.Fatalf("DeleteByID = %v, want ErrNotExist", err)
+	}
+}
+
+func testFindAllEmpty(t *testing.T, repo order.Repository) {
+
+	res, err := repo.FindAll(context.Background(), order.FindAllPage{Size: 10})
+	if err != nil {
+		t.Fatalf("FindAll = %v", err)
+	}
+
+	if res.Orders == nil || len(res.Orders) != 0 || res.Cursor != 0 {
+		t.Fatalf("FindAll on empty repository = %+v, want empty non-nil page", res)
+	}
+}
+
+func testFindAllExhaustive(t *testing.T, repo order.Repository) {
+
+	const total = 137
+	const size = 10
+
+	want := map[uint64]model.Order{}
+
+	for i := 0; i < total; i++ {
+		o := NewOrder()
+		mustInsert(t, repo, o)
+		want[o.OrderID] = o
+	}
+
+	seen := map[uint64]bool{}
+	pages := 0
+
+	err := order.ForEachPage(context.Background(), repo, size, func(orders []model.Order) error {
+
+		pages++
+		if pages > total {
+			return fmt.Errorf("pagination did not terminate after %d pages", pages)
+		}
+
+		for _, got := range orders {
+			o, ok := want[got.OrderID]
+			if !ok {
+				return fmt.Errorf("unexpected order %d", got.OrderID)
+			}
+			if !equalOrders(got, o) {
+				return fmt.Errorf("order %d mismatch: got %+v, want %+v", got.OrderID, got, o)
+			}
+			seen[got.OrderID] = true
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(seen) != total {
+		t.Fatalf("saw %d of %d orders across %d pages", len(seen), total, pages)
+	}
+}
+
+func testConcurrentUpdates(t *testing.T, repo order.Repository) {
+
+	o := NewOrder()
+	mustInsert(t, repo, o)
+
+	const writers = 16
+
+	written := make([]model.Order, writers)
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < writers; i++ {
+
+		update := o
+		update.LineItems = []model.LineItem{{ItemID: uuid.New(), Quantity: uint(i + 1), Price: 100}}
+		written[i] = update
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := repo.Update(context.Background(), update); err != nil {
+				t.Errorf("concurrent Update = %v", err)
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	got, err := repo.FindByID(context.Background(), o.OrderID)
+	if err != nil {
+		t.Fatalf("FindByID = %v", err)
+	}
+
+	for _, w := range written {
+		if equalOrders(got, w) {
+			return
+		}
+	}
+
+	t.Fatalf("final order %+v matches none of the concurrent writes", got)
+}
@@ -0,0 +1,83 @@
+package respcache
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/hashicorp/golang-lru/v2/expirable"
+)
+
+const stripes = 256
+
+// rendered maps a codec name to the encoded order. Entries are replaced,
+// never mutated, so readers need no locking.
+type rendered map[string][]byte
+
+type Cache struct {
+	lru  *expirable.LRU[uint64, rendered]
+	gens [stripes]atomic.Uint64
+	hits atomic.Uint64
+	miss atomic.Uint64
+}
+
+func New(size int, ttl time.Duration) *Cache {
+	return &Cache{
+		lru: expirable.NewLRU[uint64, rendered](size, nil, ttl),
+	}
+}
+
+func (c *Cache) Get(id uint64, codec string) ([]byte, bool) {
+
+	entry, _ := c.lru.Get(id)
+	data, ok := entry[codec]
+
+	if ok {
+		c.hits.Add(1)
+	} else {
+		c.miss.Add(1)
+	}
+
+	return data, ok
+}
+
+// Generation must be read before fetching the value that is later passed to
+// Add. If the order is written in between, Add drops the stale rendering.
+func (c *Cache) Generation(id uint64) uint64 {
+	return c.gens[id%stripes].Load()
+}
+
+func (c *Cache) Add(id uint64, codec string, gen uint64, data []byte) {
+
+	if c.Generation(id) != gen {
+		return
+	}
+
+	prev, _ := c.lru.Peek(id)
+
+	entry := make(rendered, len(prev)+1)
+	for name, v := range prev {
+		entry[name] = v
+	}
+	entry[codec] = data
+
+	c.lru.Add(id, entry)
+}
+
+func (c *Cache) Invalidate(id uint64) {
+	c.gens[id%stripes].Add(1)
+	c.lru.Remove(id)
+}
+
+type Stats struct {
+	Entries int    `json:"entries"`
+	Hits    uint64 `json:"hits"`
+	Misses  uint64 `json:"misses"`
+}
+
+func (c *Cache) Stats() Stats {
+	return Stats{
+		Entries: c.lru.Len(),
+		Hits:    c.hits.Load(),
+		Misses:  c.miss.Load(),
+	}
+}
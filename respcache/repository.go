@@ -0,0 +1,55 @@
+package respcache
+
+import (
+	"context"
+
+	"github.com/i101dev/microservices-NN/model"
+	"github.com/i101dev/microservices-NN/repository/order"
+)
+
+// Repository invalidates cached renderings on every write that goes through
+// this process. Other replicas only see the change once their TTL expires.
+type Repository struct {
+	order.Repository
+	Cache *Cache
+}
+
+func (r *Repository) Insert(ctx context.Context, o model.Order) error {
+	defer r.Cache.Invalidate(o.OrderID)
+	return r.Repository.Insert(ctx, o)
+}
+
+func (r *Repository) InsertAll(ctx context.Context, orders []model.Order) error {
+
+	defer func() {
+		for _, o := range orders {
+			r.Cache.Invalidate(o.OrderID)
+		}
+	}()
+
+	return r.Repository.InsertAll(ctx, orders)
+}
+
+func (r *Repository) Update(ctx context.Context, o model.Order) error {
+	defer r.Cache.Invalidate(o.OrderID)
+	return r.Repository.Update(ctx, o)
+}
+
+func (r *Repository) DeleteByID(ctx context.Context, id uint64) error {
+	defer r.Cache.Invalidate(id)
+	return r.Repository.DeleteByID(ctx, id)
+}
+
+func (r *Repository) Apply(ctx context.Context, batch order.Batch) error {
+
+	defer func() {
+		for _, o := range batch.Insert {
+			r.Cache.Invalidate(o.OrderID)
+		}
+		for _, o := range batch.Update {
+			r.Cache.Invalidate(o.OrderID)
+		}
+	}()
+
+	return r.Repository.Apply(ctx, batch)
+}
@@ -0,0 +1,68 @@
+package retention
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/google/uuid"
+	"github.com/i101dev/microservices-NN/model"
+	"github.com/redis/go-redis/v9"
+)
+
+const archiveKey = "orders:archive"
+
+// Archive keeps orders removed from the live store as JSON in a single
+// hash keyed by order ID. Archived orders are not served by the API.
+type Archive struct {
+	Client *redis.Client
+}
+
+func (a *Archive) Put(ctx context.Context, o model.Order) error {
+
+	data, err := json.Marshal(o)
+	if err != nil {
+		return fmt.Errorf("failed to encode order: %w", err)
+	}
+
+	if err := a.Client.HSet(ctx, archiveKey, strconv.FormatUint(o.OrderID, 10), data).Err(); err != nil {
+		return fmt.Errorf("failed to archive order: %w", err)
+	}
+
+	return nil
+}
+
+// ForgetCustomer deletes the customer's archived orders.
+func (a *Archive) ForgetCustomer(ctx context.Context, customer uuid.UUID) error {
+
+	iter := a.Client.HScan(ctx, archiveKey, 0, "*", 100).Iterator()
+
+	var ids []string
+
+	for iter.Next(ctx) {
+		id := iter.Val()
+		if !iter.Next(ctx) {
+			break
+		}
+
+		var o model.Order
+		if err := json.Unmarshal([]byte(iter.Val()), &o); err == nil && o.CustomerID == customer {
+			ids = append(ids, id)
+		}
+	}
+
+	if err := iter.Err(); err != nil {
+		return fmt.Errorf("failed to scan archive: %w", err)
+	}
+
+	if len(ids) == 0 {
+		return nil
+	}
+
+	if err := a.Client.HDel(ctx, archiveKey, ids...).Err(); err != nil {
+		return fmt.Errorf("failed to delete archived orders: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,261 @@
+package retention
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/i101dev/microservices-NN/clock"
+	"github.com/i101dev/microservices-NN/erasure"
+	"github.com/i101dev/microservices-NN/model"
+	"github.com/i101dev/microservices-NN/repository/order"
+	"github.com/redis/go-redis/v9"
+)
+
+const reportKey = "retention:report"
+
+var ErrNoReport = errors.New("retention has not run yet")
+
+type Action string
+
+const (
+	// ActionPurge deletes the order.
+	ActionPurge Action = "purge"
+	// ActionArchive moves the order to the Archive before deleting it.
+	ActionArchive Action = "archive"
+)
+
+// Policy applies Action to orders that have been in Status for longer
+// than After.
+type Policy struct {
+	Status string
+	After  time.Duration
+	Action Action
+}
+
+// ParsePolicies reads "status:age:action" entries separated by commas,
+// e.g. "cancelled:90d:purge,completed:365d:archive". Ages take a "d" suffix
+// for days as well as anything time.ParseDuration accepts.
+func ParsePolicies(s string) ([]Policy, error) {
+
+	var policies []Policy
+
+	for _, entry := range strings.Split(s, ",") {
+		parts := strings.Split(strings.TrimSpace(entry), ":")
+		if len(parts) != 3 {
+			return nil, fmt.Errorf("invalid retention policy %q", entry)
+		}
+
+		switch parts[0] {
+		case model.StatusPending, model.StatusReview, model.StatusBackordered, model.StatusShipped, model.StatusCompleted, model.StatusCancelled:
+		default:
+			return nil, fmt.Errorf("invalid retention policy %q: unknown status", entry)
+		}
+
+		after, err := parseAge(parts[1])
+		if err != nil || after <= 0 {
+			return nil, fmt.Errorf("invalid retention policy %q: bad age", entry)
+		}
+
+		action := Action(parts[2])
+		if action != ActionPurge && action != ActionArchive {
+			return nil, fmt.Errorf("invalid retention policy %q: unknown action", entry)
+		}
+
+		policies = append(policies, Policy{
+			Status: parts[0],
+			After:  after,
+			Action: action,
+		})
+	}
+
+	return policies, nil
+}
+
+func parseAge(s string) (time.Duration, error) {
+
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		return time.Duration(n) * 24 * time.Hour, err
+	}
+
+	return time.ParseDuration(s)
+}
+
+// since is when the order entered its current status.
+func since(o model.Order) *time.Time {
+
+	switch o.Status() {
+	case model.StatusCancelled:
+		return o.CancelledAt
+	case model.StatusCompleted:
+		return o.CompletedAt
+	case model.StatusShipped:
+		return o.ShippedAt
+	case model.StatusReview:
+		return o.FlaggedAt
+	case model.StatusBackordered:
+		return &o.Backorder.Since
+	default:
+		return o.CreatedAt
+	}
+}
+
+type PolicyReport struct {
+	Status string `json:"status"`
+	After  string `json:"after"`
+	Action Action `json:"action"`
+	// Matched counts the orders the policy covered; Applied the ones it
+	// actually purged or archived. Applied stays zero on a dry run.
+	Matched int `json:"matched"`
+	Applied int `json:"applied"`
+}
+
+type Report struct {
+	DryRun     bool           `json:"dry_run"`
+	StartedAt  time.Time      `json:"started_at"`
+	FinishedAt time.Time      `json:"finished_at"`
+	Policies   []PolicyReport `json:"policies"`
+	Error      string         `json:"error,omitempty"`
+}
+
+// Enforcer applies the policies to every order in Repo and keeps the
+// report of the last run in Redis.
+type Enforcer struct {
+	Client   *redis.Client
+	Repo     order.Repository
+	Archive  *Archive
+	Policies []Policy
+	// OrderData is dropped for every order removed, archived or not, as
+	// the archive keeps only the order itself.
+	OrderData []erasure.OrderData
+	Clock     clock.Clock
+}
+
+func (e *Enforcer) now() time.Time {
+	if e.Clock == nil {
+		return time.Now().UTC()
+	}
+	return e.Clock.Now().UTC()
+}
+
+// Run enforces the policies. With dryRun it only counts what they would
+// remove.
+func (e *Enforcer) Run(ctx context.Context, dryRun bool) (Report, error) {
+
+	report := Report{
+		DryRun:    dryRun,
+		StartedAt: e.now(),
+		Policies:  make([]PolicyReport, len(e.Policies)),
+	}
+
+	for i, p := range e.Policies {
+		report.Policies[i].Status = p.Status
+		report.Policies[i].After = p.After.String()
+		report.Policies[i].Action = p.Action
+	}
+
+	err := order.ForEachPage(ctx, e.Repo, 100, func(orders []model.Order) error {
+
+		for _, o := range orders {
+			i := e.match(o, report.StartedAt)
+			if i < 0 {
+				continue
+			}
+
+			report.Policies[i].Matched++
+
+			if dryRun {
+				continue
+			}
+
+			err := e.apply(ctx, e.Policies[i].Action, o)
+			if errors.Is(err, order.ErrNotExist) {
+				continue
+			} else if err != nil {
+				return fmt.Errorf("failed to %s order %d: %w", e.Policies[i].Action, o.OrderID, err)
+			}
+
+			report.Policies[i].Applied++
+		}
+
+		return nil
+	})
+
+	report.FinishedAt = e.now()
+	if err != nil {
+		report.Error = err.Error()
+	}
+
+	if err := e.save(ctx, report); err != nil {
+		fmt.Println("failed to save retention report:", err)
+	}
+
+	return report, err
+}
+
+// match returns the index of the first policy covering the order, or -1.
+func (e *Enforcer) match(o model.Order, now time.Time) int {
+
+	at := since(o)
+	if at == nil {
+		return -1
+	}
+
+	for i, p := range e.Policies {
+		if p.Status == o.Status() && now.Sub(*at) >= p.After {
+			return i
+		}
+	}
+
+	return -1
+}
+
+func (e *Enforcer) apply(ctx context.Context, action Action, o model.Order) error {
+
+	if action == ActionArchive {
+		if err := e.Archive.Put(ctx, o); err != nil {
+			return err
+		}
+	}
+
+	for _, data := range e.OrderData {
+		if err := data.Drop(ctx, o.OrderID); err != nil {
+			return err
+		}
+	}
+
+	return e.Repo.DeleteByID(ctx, o.OrderID)
+}
+
+func (e *Enforcer) save(ctx context.Context, report Report) error {
+
+	data, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+
+	return e.Client.Set(ctx, reportKey, data, 0).Err()
+}
+
+// LastReport returns the report of the most recent run, dry or not.
+func (e *Enforcer) LastReport(ctx context.Context) (Report, error) {
+
+	data, err := e.Client.Get(ctx, reportKey).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return Report{}, ErrNoReport
+	} else if err != nil {
+		return Report{}, fmt.Errorf("failed to get retention report: %w", err)
+	}
+
+	var report Report
+	if err := json.Unmarshal(data, &report); err != nil {
+		return Report{}, fmt.Errorf("failed to decode retention report: %w", err)
+	}
+
+	return report, nil
+}
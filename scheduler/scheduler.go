@@ -0,0 +1,84 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/i101dev/microservices-NN/leader"
+	"github.com/redis/go-redis/v9"
+	"github.com/robfig/cron/v3"
+)
+
+// Scheduler runs periodic tasks on whichever replica currently holds the
+// scheduler lease. The others keep their cron ticking but skip every run.
+type Scheduler struct {
+	client  *redis.Client
+	cron    *cron.Cron
+	elector *leader.Elector
+}
+
+func New(client *redis.Client) *Scheduler {
+	return &Scheduler{
+		client:  client,
+		cron:    cron.New(),
+		elector: leader.New(client, "scheduler:leader", 15*time.Second),
+	}
+}
+
+// Add registers fn under a standard five-field cron spec or a descriptor
+// such as "@hourly" or "@every 5m". A run that is still going when the next
+// one is due causes that next run to be skipped.
+func (s *Scheduler) Add(name string, spec string, fn func(ctx context.Context) error) error {
+
+	job := cron.FuncJob(func() {
+
+		token := s.elector.Token()
+		if token == 0 {
+			return
+		}
+
+		ctx := s.elector.Context()
+
+		if err := leader.Fence(ctx, s.client, "scheduler:"+name, token); err != nil {
+			fmt.Printf("skipping %s: %v\n", name, err)
+			return
+		}
+
+		start := time.Now()
+
+		if err := fn(ctx); err != nil {
+			fmt.Printf("failed to run %s: %v\n", name, err)
+			return
+		}
+
+		fmt.Printf("ran %s in %s\n", name, time.Since(start).Round(time.Millisecond))
+	})
+
+	skip := cron.SkipIfStillRunning(cron.DiscardLogger)
+
+	if _, err := s.cron.AddJob(spec, skip(job)); err != nil {
+		return fmt.Errorf("failed to schedule %s: %w", name, err)
+	}
+
+	return nil
+}
+
+// Run blocks until ctx is cancelled, then waits for in-progress tasks and
+// gives up the lease.
+func (s *Scheduler) Run(ctx context.Context) {
+
+	done := make(chan struct{})
+
+	go func() {
+		s.elector.Run(ctx)
+		close(done)
+	}()
+
+	s.cron.Start()
+
+	<-ctx.Done()
+
+	<-s.cron.Stop().Done()
+	<-done
+}
@@ -0,0 +1,119 @@
+// Package search keeps a read model of the orders in OpenSearch (or
+// Elasticsearch, which speaks the same API) for queries the Redis indexes
+// cannot answer, such as free text and aggregations. The documents are
+// projected from the changefeed, so they lag the store by a batch.
+package search
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/i101dev/microservices-NN/model"
+	"github.com/i101dev/microservices-NN/orderindex"
+)
+
+// document is an order as it is indexed. Order is kept whole but not
+// indexed, so hits can be answered without reading the store.
+type document struct {
+	OrderID    string       `json:"order_id"`
+	CustomerID string       `json:"customer_id"`
+	OrgID      string       `json:"org_id,omitempty"`
+	Status     string       `json:"status"`
+	Tags       []string     `json:"tags,omitempty"`
+	ItemIDs    []string     `json:"item_ids"`
+	ItemCount  int          `json:"item_count"`
+	Total      uint         `json:"total"`
+	Country    string       `json:"country,omitempty"`
+	Carrier    string       `json:"carrier,omitempty"`
+	SLA        string       `json:"sla,omitempty"`
+	Warehouses []string     `json:"warehouses,omitempty"`
+	Dispute    string       `json:"dispute,omitempty"`
+	CreatedAt  *time.Time   `json:"created_at,omitempty"`
+	UpdatedAt  *time.Time   `json:"updated_at,omitempty"`
+	Text       string       `json:"text"`
+	Order      *model.Order `json:"order"`
+}
+
+// mapping is the index's mapping. The order itself is stored but not
+// indexed, and fields not listed here are ignored.
+var mapping = map[string]any{
+	"mappings": map[string]any{
+		"dynamic": false,
+		"properties": map[string]any{
+			"order_id":    map[string]any{"type": "keyword"},
+			"customer_id": map[string]any{"type": "keyword"},
+			"org_id":      map[string]any{"type": "keyword"},
+			"status":      map[string]any{"type": "keyword"},
+			"tags":        map[string]any{"type": "keyword"},
+			"item_ids":    map[string]any{"type": "keyword"},
+			"item_count":  map[string]any{"type": "integer"},
+			"total":       map[string]any{"type": "long"},
+			"country":     map[string]any{"type": "keyword"},
+			"carrier":     map[string]any{"type": "keyword"},
+			"sla":         map[string]any{"type": "keyword"},
+			"warehouses":  map[string]any{"type": "keyword"},
+			"dispute":     map[string]any{"type": "keyword"},
+			"created_at":  map[string]any{"type": "date"},
+			"updated_at":  map[string]any{"type": "date"},
+			"text":        map[string]any{"type": "text"},
+			"order":       map[string]any{"type": "object", "enabled": false},
+		},
+	},
+}
+
+func newDocument(o model.Order) document {
+
+	d := document{
+		OrderID:    strconv.FormatUint(o.OrderID, 10),
+		CustomerID: o.CustomerID.String(),
+		Status:     o.Status(),
+		Tags:       o.Tags,
+		Warehouses: o.Warehouses(),
+		ItemIDs:    make([]string, len(o.LineItems)),
+		ItemCount:  len(o.LineItems),
+		Total:      o.Total(),
+		CreatedAt:  o.CreatedAt,
+		UpdatedAt:  o.UpdatedAt,
+		Order:      &o,
+	}
+
+	if o.OrgID != nil {
+		d.OrgID = o.OrgID.String()
+	}
+
+	for i, item := range o.LineItems {
+		d.ItemIDs[i] = item.ItemID.String()
+	}
+
+	// Free text matches anything a person might search for an order by.
+	text := []string{d.OrderID, d.CustomerID, d.Status, o.ReviewReason, o.QuoteID}
+	text = append(text, o.Tags...)
+	text = append(text, d.ItemIDs...)
+
+	if o.Shipping != nil {
+		d.Country = o.Shipping.Country
+		text = append(text, o.Shipping.Method, o.Shipping.Country, o.Shipping.Region)
+	}
+
+	if o.SLABreached {
+		d.SLA = orderindex.SLABreached
+	}
+
+	if o.HasOpenDispute() {
+		d.Dispute = "open"
+	}
+
+	if o.Tracking != nil {
+		d.Carrier = o.Tracking.Carrier
+		text = append(text, o.Tracking.Carrier, o.Tracking.Number)
+	}
+
+	if o.Payment != nil {
+		text = append(text, o.Payment.Reference)
+	}
+
+	d.Text = strings.Join(strings.Fields(strings.Join(text, " ")), " ")
+
+	return d
+}
@@ -0,0 +1,220 @@
+package search
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/i101dev/microservices-NN/model"
+	"github.com/i101dev/microservices-NN/repository/order"
+)
+
+// Index is an OpenSearch index of orders, one document per order with the
+// order ID as its ID.
+type Index struct {
+	URL  string
+	Name string
+	// Username and Password, when set, are sent with basic auth.
+	Username string
+	Password string
+	Client   *http.Client
+}
+
+// apiError is an error answer from OpenSearch.
+type apiError struct {
+	Status int
+	Body   string
+}
+
+func (e *apiError) Error() string {
+	return fmt.Sprintf("opensearch answered %d: %s", e.Status, e.Body)
+}
+
+// EnsureIndex creates the index with its mapping, or brings the mapping of
+// the existing index up to date.
+func (x *Index) EnsureIndex(ctx context.Context) error {
+
+	err := x.call(ctx, http.MethodHead, "", nil, "", nil)
+
+	var apiErr *apiError
+	if errors.As(err, &apiErr) && apiErr.Status == http.StatusNotFound {
+		data, _ := json.Marshal(mapping)
+		if err := x.call(ctx, http.MethodPut, "", data, "application/json", nil); err != nil {
+			return fmt.Errorf("failed to create search index: %w", err)
+		}
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("failed to look up search index: %w", err)
+	}
+
+	// Indexes made before a field was added get it; documents indexed
+	// before then only have it once the index is rebuilt.
+	data, _ := json.Marshal(mapping["mappings"])
+	if err := x.call(ctx, http.MethodPut, "/_mapping", data, "application/json", nil); err != nil {
+		return fmt.Errorf("failed to update search index mapping: %w", err)
+	}
+
+	return nil
+}
+
+// Bulk indexes orders and deletes the documents of deleted ones, in one
+// request. Deleting a document that is not there is not an error.
+func (x *Index) Bulk(ctx context.Context, orders []model.Order, deleted []uint64) error {
+
+	if len(orders) == 0 && len(deleted) == 0 {
+		return nil
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+
+	for _, o := range orders {
+		enc.Encode(map[string]any{"index": map[string]string{"_id": strconv.FormatUint(o.OrderID, 10)}})
+		if err := enc.Encode(newDocument(o)); err != nil {
+			return fmt.Errorf("failed to encode search document: %w", err)
+		}
+	}
+
+	for _, id := range deleted {
+		enc.Encode(map[string]any{"delete": map[string]string{"_id": strconv.FormatUint(id, 10)}})
+	}
+
+	var res struct {
+		Errors bool `json:"errors"`
+		Items  []map[string]struct {
+			ID     string `json:"_id"`
+			Status int    `json:"status"`
+			Error  *struct {
+				Type   string `json:"type"`
+				Reason string `json:"reason"`
+			} `json:"error"`
+		} `json:"items"`
+	}
+
+	if err := x.call(ctx, http.MethodPost, "/_bulk", buf.Bytes(), "application/x-ndjson", &res); err != nil {
+		return fmt.Errorf("failed to index orders: %w", err)
+	}
+
+	if !res.Errors {
+		return nil
+	}
+
+	var failed int
+	var first string
+
+	for _, item := range res.Items {
+		for op, r := range item {
+			if r.Error == nil || (op == "delete" && r.Status == http.StatusNotFound) {
+				continue
+			}
+			if failed == 0 {
+				first = fmt.Sprintf("%s %s: %s: %s", op, r.ID, r.Error.Type, r.Error.Reason)
+			}
+			failed++
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("failed to index %d orders, first %s", failed, first)
+	}
+
+	return nil
+}
+
+// Search runs q and returns the orders it matched.
+func (x *Index) Search(ctx context.Context, q Query) (Result, error) {
+
+	if err := q.Validate(); err != nil {
+		return Result{}, err
+	}
+
+	data, err := json.Marshal(q.body())
+	if err != nil {
+		return Result{}, fmt.Errorf("failed to encode search: %w", err)
+	}
+
+	var res response
+
+	if err := x.call(ctx, http.MethodPost, "/_search", data, "application/json", &res); err != nil {
+		return Result{}, fmt.Errorf("failed to search orders: %w", err)
+	}
+
+	return res.result(q), nil
+}
+
+// Rebuild indexes every order in repo, and returns how many it indexed.
+// Documents of orders deleted since are left to the changefeed.
+func (x *Index) Rebuild(ctx context.Context, repo order.Repository) (int, error) {
+
+	if err := x.EnsureIndex(ctx); err != nil {
+		return 0, err
+	}
+
+	var n int
+
+	err := order.ForEachPage(ctx, repo, 100, func(orders []model.Order) error {
+		if err := x.Bulk(ctx, orders, nil); err != nil {
+			return err
+		}
+		n += len(orders)
+		return nil
+	})
+
+	return n, err
+}
+
+func (x *Index) call(ctx context.Context, method, path string, body []byte, contentType string, out any) error {
+
+	u := strings.TrimSuffix(x.URL, "/") + "/" + url.PathEscape(x.Name) + path
+
+	var r io.Reader
+	if body != nil {
+		r = bytes.NewReader(body)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, u, r)
+	if err != nil {
+		return fmt.Errorf("failed to build search request: %w", err)
+	}
+
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	if x.Username != "" {
+		req.SetBasicAuth(x.Username, x.Password)
+	}
+
+	client := x.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		data, _ := io.ReadAll(io.LimitReader(res.Body, 1024))
+		return &apiError{Status: res.StatusCode, Body: string(bytes.TrimSpace(data))}
+	}
+
+	if out == nil {
+		return nil
+	}
+
+	if err := json.NewDecoder(res.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode opensearch answer: %w", err)
+	}
+
+	return nil
+}
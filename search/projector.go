@@ -0,0 +1,81 @@
+package search
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/i101dev/microservices-NN/changefeed"
+	"github.com/i101dev/microservices-NN/model"
+	"github.com/i101dev/microservices-NN/repository/order"
+	"github.com/redis/go-redis/v9"
+)
+
+const group = "order-search"
+
+// Projector keeps the index up to date, following the changefeed in its
+// own consumer group and indexing every order it names as it is now.
+// Orders written before it ran, or lost to the changefeed being trimmed
+// while it was down, are indexed with Rebuild.
+type Projector struct {
+	Client *redis.Client
+	// Stream is the changefeed stream.
+	Stream string
+	Repo   order.Repository
+	Index  *Index
+	// Interval is how long changes wait to be indexed together. Zero means
+	// a second.
+	Interval time.Duration
+}
+
+// Run creates the index if it is missing, then projects the changes into
+// it until ctx is cancelled.
+func (p *Projector) Run(ctx context.Context) error {
+
+	for {
+		err := p.Index.EnsureIndex(ctx)
+		if err == nil {
+			break
+		}
+
+		fmt.Println("failed to set up search index, retrying later:", err)
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(time.Minute):
+		}
+	}
+
+	interval := p.Interval
+	if interval <= 0 {
+		interval = time.Second
+	}
+
+	feed := &changefeed.Consumer{
+		Client:   p.Client,
+		Stream:   p.Stream,
+		Group:    group,
+		Repo:     p.Repo,
+		Interval: interval,
+		Handle:   p.project,
+	}
+
+	return feed.Run(ctx)
+}
+
+func (p *Projector) project(ctx context.Context, changes []changefeed.Change) error {
+
+	var orders []model.Order
+	var deleted []uint64
+
+	for _, c := range changes {
+		if c.Order == nil {
+			deleted = append(deleted, c.OrderID)
+		} else {
+			orders = append(orders, *c.Order)
+		}
+	}
+
+	return p.Index.Bulk(ctx, orders, deleted)
+}
@@ -0,0 +1,227 @@
+package search
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/google/uuid"
+	"github.com/i101dev/microservices-NN/model"
+	"github.com/i101dev/microservices-NN/orderindex"
+)
+
+var ErrInvalidQuery = errors.New("invalid search")
+
+// MaxResults is how deep into the results a search can page, the default
+// result window of OpenSearch.
+const MaxResults = 10_000
+
+// Aggregations are the breakdowns a search can ask for. They count the
+// orders matching the search by each value of a field, or by the day they
+// were created for created_day.
+var Aggregations = []string{"status", "tags", "customer_id", "country", "carrier", "created_day"}
+
+// Query is a search. Text matches order IDs, customers, items, tags,
+// shipping, tracking and payment references, all its words at once.
+// Orders come back best match first when Text is set, oldest first
+// otherwise.
+type Query struct {
+	Text         string
+	Filter       orderindex.Filter
+	Aggregations []string
+	From         int
+	Size         int
+	// RestrictOrgs keeps the search, its total and aggregations included,
+	// to orders placed for no organization or for one of Orgs.
+	RestrictOrgs bool
+	Orgs         []uuid.UUID
+}
+
+func (q Query) Validate() error {
+
+	if err := q.Filter.Validate(); err != nil {
+		return err
+	}
+
+	for _, a := range q.Aggregations {
+		if !slices.Contains(Aggregations, a) {
+			return fmt.Errorf("unknown aggregation %q: %w", a, ErrInvalidQuery)
+		}
+	}
+
+	if q.From < 0 || q.Size < 0 || q.From+q.Size > MaxResults {
+		return fmt.Errorf("results past the first %d cannot be paged to: %w", MaxResults, ErrInvalidQuery)
+	}
+
+	return nil
+}
+
+func (q Query) body() map[string]any {
+
+	f := q.Filter
+	var filters []any
+
+	term := func(field string, value any) {
+		filters = append(filters, map[string]any{"term": map[string]any{field: value}})
+	}
+
+	if f.Status != "" {
+		term("status", f.Status)
+	}
+
+	if f.CustomerID != nil {
+		term("customer_id", f.CustomerID.String())
+	}
+
+	for _, t := range f.Tags {
+		term("tags", t)
+	}
+
+	if f.From != nil || f.To != nil {
+		created := map[string]any{}
+		if f.From != nil {
+			created["gte"] = f.From.UTC()
+		}
+		if f.To != nil {
+			created["lt"] = f.To.UTC()
+		}
+		filters = append(filters, map[string]any{"range": map[string]any{"created_at": created}})
+	}
+
+	if f.SLA != "" {
+		term("sla", f.SLA)
+	}
+
+	if f.Warehouse != "" {
+		term("warehouses", f.Warehouse)
+	}
+
+	if f.HasOpenDispute {
+		term("dispute", "open")
+	}
+
+	if f.MinTotal > 0 {
+		filters = append(filters, map[string]any{"range": map[string]any{"total": map[string]any{"gte": f.MinTotal}}})
+	}
+
+	if q.RestrictOrgs {
+		orgs := make([]string, len(q.Orgs))
+		for i, id := range q.Orgs {
+			orgs[i] = id.String()
+		}
+		filters = append(filters, map[string]any{"bool": map[string]any{
+			"should": []any{
+				map[string]any{"bool": map[string]any{"must_not": map[string]any{"exists": map[string]any{"field": "org_id"}}}},
+				map[string]any{"terms": map[string]any{"org_id": orgs}},
+			},
+			"minimum_should_match": 1,
+		}})
+	}
+
+	boolean := map[string]any{"filter": filters}
+
+	sort := []any{map[string]string{"created_at": "asc"}, map[string]string{"order_id": "asc"}}
+
+	if q.Text != "" {
+		boolean["must"] = map[string]any{
+			"simple_query_string": map[string]any{
+				"query":            q.Text,
+				"fields":           []string{"text"},
+				"default_operator": "and",
+			},
+		}
+		sort = append([]any{"_score"}, sort...)
+	}
+
+	body := map[string]any{
+		"from":             q.From,
+		"size":             q.Size,
+		"track_total_hits": true,
+		"query":            map[string]any{"bool": boolean},
+		"sort":             sort,
+	}
+
+	if len(q.Aggregations) > 0 {
+		aggs := map[string]any{}
+		for _, a := range q.Aggregations {
+			if a == "created_day" {
+				aggs[a] = map[string]any{"date_histogram": map[string]any{
+					"field":             "created_at",
+					"calendar_interval": "day",
+					"format":            "yyyy-MM-dd",
+					"min_doc_count":     1,
+				}}
+				continue
+			}
+			aggs[a] = map[string]any{"terms": map[string]any{"field": a, "size": 20}}
+		}
+		body["aggs"] = aggs
+	}
+
+	return body
+}
+
+// Bucket is one value of an aggregation and how many orders have it.
+type Bucket struct {
+	Key   string `json:"key"`
+	Count int    `json:"count"`
+}
+
+type Result struct {
+	// Total is how many orders matched, of which Orders is one page.
+	Total        int                 `json:"total"`
+	Orders       []model.Order       `json:"items"`
+	Aggregations map[string][]Bucket `json:"aggregations,omitempty"`
+}
+
+type response struct {
+	Hits struct {
+		Total struct {
+			Value int `json:"value"`
+		} `json:"total"`
+		Hits []struct {
+			Source struct {
+				Order *model.Order `json:"order"`
+			} `json:"_source"`
+		} `json:"hits"`
+	} `json:"hits"`
+	Aggregations map[string]struct {
+		Buckets []struct {
+			Key         any    `json:"key"`
+			KeyAsString string `json:"key_as_string"`
+			DocCount    int    `json:"doc_count"`
+		} `json:"buckets"`
+	} `json:"aggregations"`
+}
+
+func (r response) result(q Query) Result {
+
+	res := Result{
+		Total:  r.Hits.Total.Value,
+		Orders: make([]model.Order, 0, len(r.Hits.Hits)),
+	}
+
+	for _, hit := range r.Hits.Hits {
+		if hit.Source.Order != nil {
+			res.Orders = append(res.Orders, *hit.Source.Order)
+		}
+	}
+
+	if len(q.Aggregations) > 0 {
+		res.Aggregations = map[string][]Bucket{}
+	}
+
+	for _, name := range q.Aggregations {
+		buckets := []Bucket{}
+		for _, b := range r.Aggregations[name].Buckets {
+			key := b.KeyAsString
+			if key == "" {
+				key = fmt.Sprint(b.Key)
+			}
+			buckets = append(buckets, Bucket{Key: key, Count: b.DocCount})
+		}
+		res.Aggregations[name] = buckets
+	}
+
+	return res
+}
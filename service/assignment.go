@@ -0,0 +1,81 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/i101dev/microservices-NN/fulfillment"
+	"github.com/i101dev/microservices-NN/model"
+	"github.com/i101dev/microservices-NN/orderindex"
+)
+
+// AssignWarehouses routes the order to the warehouses that send it. It
+// wraps model.ErrAlreadyAssigned for orders that were assigned before,
+// and model.ErrInvalidTransition for orders waiting for stock or past
+// shipping.
+func (s *Orders) AssignWarehouses(ctx context.Context, id uint64) (model.Order, error) {
+
+	o, err := s.Repo.FindByID(ctx, id)
+	if err != nil {
+		return model.Order{}, err
+	}
+
+	// Checked before asking for stock, which is wasted on these orders.
+	if err := o.Assignable(); err != nil {
+		return model.Order{}, fmt.Errorf("cannot assign %s order: %w", o.Status(), err)
+	}
+
+	assignments, err := s.Fulfillment.Assign(ctx, o)
+	if err != nil {
+		return model.Order{}, err
+	}
+
+	return s.change(ctx, id, "assigned", func(o *model.Order, now time.Time) error {
+		return o.Assign(assignments, now)
+	})
+}
+
+// PickList gathers what the warehouse has to pick for its pending orders,
+// only the ones placed on day when it is set. The orders are found in the
+// index, so only they are read. Keep, when set, drops orders from each
+// page, such as the ones the caller may not see.
+func (s *Orders) PickList(ctx context.Context, warehouse string, day *time.Time, keep func([]model.Order) ([]model.Order, error)) (fulfillment.PickList, error) {
+
+	f := orderindex.Filter{Warehouse: warehouse, Status: model.StatusPending}
+
+	if day != nil {
+		from := day.UTC().Truncate(24 * time.Hour)
+		to := from.AddDate(0, 0, 1)
+		f.From, f.To = &from, &to
+	}
+
+	var orders []model.Order
+
+	for cursor := uint64(0); ; {
+		page, err := s.Filter(ctx, f, cursor, 100)
+		if err != nil {
+			return fulfillment.PickList{}, err
+		}
+
+		items := page.Items
+		if keep != nil {
+			if items, err = keep(items); err != nil {
+				return fulfillment.PickList{}, err
+			}
+		}
+
+		orders = append(orders, items...)
+
+		if cursor = page.Next; cursor == 0 {
+			break
+		}
+	}
+
+	p := fulfillment.NewPickList(warehouse, orders, s.now())
+	if day != nil {
+		p.Date = f.From.Format(time.DateOnly)
+	}
+
+	return p, nil
+}
@@ -0,0 +1,122 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/i101dev/microservices-NN/model"
+	"github.com/i101dev/microservices-NN/storage"
+)
+
+// ErrNoBlobs is returned when files are attached to orders without blob
+// storage to keep them in.
+var ErrNoBlobs = errors.New("attachments are not enabled")
+
+// AttachmentKey is where the file of an attachment is kept in blob
+// storage.
+func AttachmentKey(orderID uint64, attachmentID string) string {
+	return fmt.Sprintf("orders/%d/attachments/%s", orderID, attachmentID)
+}
+
+// Attach adds an attachment of size bytes to the order, pending until its
+// file is uploaded and ConfirmAttachment is called.
+func (s *Orders) Attach(ctx context.Context, id uint64, name, contentType string, size int64) (model.Order, model.Attachment, error) {
+
+	if s.Blobs == nil {
+		return model.Order{}, model.Attachment{}, ErrNoBlobs
+	}
+
+	a := model.Attachment{
+		ID:          uuid.NewString(),
+		Name:        name,
+		ContentType: contentType,
+		Size:        size,
+		CreatedAt:   s.now(),
+	}
+
+	o, err := s.change(ctx, id, "attached", func(o *model.Order, _ time.Time) error {
+		return o.AddAttachment(a)
+	})
+	if err != nil {
+		return model.Order{}, model.Attachment{}, err
+	}
+
+	return o, a, nil
+}
+
+// ConfirmAttachment marks the attachment uploaded once blob storage holds
+// its file, of the size it was declared with. Files of another size are
+// deleted, for the upload to be retried.
+func (s *Orders) ConfirmAttachment(ctx context.Context, id uint64, attachmentID string) (model.Order, error) {
+
+	if s.Blobs == nil {
+		return model.Order{}, ErrNoBlobs
+	}
+
+	o, err := s.Repo.FindByID(ctx, id)
+	if err != nil {
+		return model.Order{}, err
+	}
+
+	a, err := o.FindAttachment(attachmentID)
+	if err != nil {
+		return model.Order{}, err
+	}
+
+	if a.UploadedAt != nil {
+		return o, nil
+	}
+
+	key := AttachmentKey(id, attachmentID)
+
+	obj, err := s.Blobs.Stat(ctx, key)
+	if errors.Is(err, storage.ErrNotExist) {
+		return model.Order{}, model.ErrAttachmentPending
+	} else if err != nil {
+		return model.Order{}, err
+	}
+
+	if obj.Size != a.Size {
+		if err := s.Blobs.Delete(ctx, key); err != nil {
+			fmt.Println("failed to delete attachment:", err)
+		}
+		return model.Order{}, fmt.Errorf("uploaded %d bytes of %d: %w", obj.Size, a.Size, model.ErrInvalidAttachment)
+	}
+
+	return s.change(ctx, id, "attachment uploaded", func(o *model.Order, now time.Time) error {
+		a, err := o.FindAttachment(attachmentID)
+		if err != nil {
+			return err
+		}
+		a.UploadedAt = &now
+		return nil
+	})
+}
+
+// Detach deletes the file of the attachment, then the attachment.
+func (s *Orders) Detach(ctx context.Context, id uint64, attachmentID string) (model.Order, error) {
+
+	if s.Blobs == nil {
+		return model.Order{}, ErrNoBlobs
+	}
+
+	o, err := s.Repo.FindByID(ctx, id)
+	if err != nil {
+		return model.Order{}, err
+	}
+
+	if _, err := o.FindAttachment(attachmentID); err != nil {
+		return model.Order{}, err
+	}
+
+	if err := s.Blobs.Delete(ctx, AttachmentKey(id, attachmentID)); err != nil {
+		return model.Order{}, err
+	}
+
+	return s.change(ctx, id, "detached", func(o *model.Order, _ time.Time) error {
+		return o.RemoveAttachment(attachmentID)
+	})
+}
@@ -0,0 +1,132 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/i101dev/microservices-NN/inventory"
+	"github.com/i101dev/microservices-NN/model"
+	"github.com/i101dev/microservices-NN/repository/order"
+)
+
+// ErrInventoryUnavailable is returned when stock could not be reserved for
+// a new order. The order is not taken.
+var ErrInventoryUnavailable = errors.New("inventory is unavailable")
+
+// reserve holds stock for a prepared order and returns the batch that
+// stores it. Items that are short are moved to a new backordered order
+// for the same customer and shipping, or the whole order is backordered
+// when nothing was in stock.
+func (s *Orders) reserve(ctx context.Context, o model.Order) (order.Batch, error) {
+
+	if s.Inventory == nil {
+		return order.Batch{Insert: []model.Order{o}}, nil
+	}
+
+	reserved, err := s.Inventory.Reserve(ctx, o.OrderID, o.LineItems)
+	if err != nil {
+		return order.Batch{}, fmt.Errorf("%w: %v", ErrInventoryUnavailable, err)
+	}
+
+	fulfilled, short := inventory.Shortfall(o.LineItems, reserved)
+
+	switch {
+	case len(short) == 0:
+		return order.Batch{Insert: []model.Order{o}}, nil
+	case len(fulfilled) == 0:
+		o.Backorder = &model.Backorder{Since: *o.CreatedAt}
+		return order.Batch{Insert: []model.Order{o}}, nil
+	}
+
+	b := s.New(o.CustomerID, short)
+	if o.Shipping != nil {
+		shipping := *o.Shipping
+		b.Shipping = &shipping
+	}
+	b.Backorder = &model.Backorder{SplitFrom: o.OrderID, Since: *b.CreatedAt}
+
+	o.LineItems = fulfilled
+	o.BackorderID = b.OrderID
+
+	if err := s.splitTax(ctx, &o, &b); err != nil {
+		s.release(ctx, o.OrderID)
+		return order.Batch{}, err
+	}
+
+	return order.Batch{
+		Insert: []model.Order{o, b},
+		History: []model.HistoryEntry{
+			{OrderID: o.OrderID, At: *b.CreatedAt, Action: model.HistoryBackorderedInto, Related: []uint64{b.OrderID}, LineItems: short},
+			{OrderID: b.OrderID, At: *b.CreatedAt, Action: model.HistoryBackorderedFrom, Related: []uint64{o.OrderID}},
+		},
+	}, nil
+}
+
+// release gives back the stock held for orders that were never stored.
+func (s *Orders) release(ctx context.Context, ids ...uint64) {
+
+	if s.Inventory == nil {
+		return
+	}
+
+	for _, id := range ids {
+		if err := s.Inventory.Release(ctx, id); err != nil {
+			fmt.Printf("failed to release stock for order %d: %v\n", id, err)
+		}
+	}
+}
+
+// store writes a reserve batch, as a plain insert when nothing was split
+// off.
+func (s *Orders) store(ctx context.Context, batch order.Batch) error {
+
+	if len(batch.Insert) == 1 && len(batch.History) == 0 {
+		return s.Repo.Insert(ctx, batch.Insert[0])
+	}
+
+	return s.Repo.Apply(ctx, batch)
+}
+
+// FulfillBackorder tries to reserve the stock a backordered order waits
+// for, and makes it pending again once all of it is held. The returned
+// bool is false when the order is not backordered or there is still not
+// enough stock.
+func (s *Orders) FulfillBackorder(ctx context.Context, id uint64) (model.Order, bool, error) {
+
+	o, err := s.Repo.FindByID(ctx, id)
+	if err != nil {
+		return model.Order{}, false, err
+	}
+
+	if o.Status() != model.StatusBackordered || s.Inventory == nil {
+		return o, false, nil
+	}
+
+	reserved, err := s.Inventory.Reserve(ctx, id, o.LineItems)
+	if err != nil {
+		return model.Order{}, false, fmt.Errorf("%w: %v", ErrInventoryUnavailable, err)
+	}
+
+	if _, short := inventory.Shortfall(o.LineItems, reserved); len(short) > 0 {
+		if len(reserved) > 0 {
+			s.release(ctx, id)
+		}
+		return o, false, nil
+	}
+
+	o, err = s.change(ctx, id, model.StatusPending, func(o *model.Order, now time.Time) error {
+		if err := o.Fulfill(now); err != nil {
+			return err
+		}
+		s.estimate(o, now)
+		return nil
+	})
+	if err != nil {
+		s.release(ctx, id)
+		return model.Order{}, false, err
+	}
+
+	return o, true, nil
+}
@@ -0,0 +1,82 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/i101dev/microservices-NN/model"
+)
+
+// OpenDispute, UpdateDispute and CloseDispute record what the payment
+// provider reports about a chargeback, and return the dispute as it is
+// after. They wrap model.ErrInvalidDispute for steps out of order, such
+// as an update to a dispute that is closed already.
+
+func (s *Orders) OpenDispute(ctx context.Context, id uint64, d model.Dispute) (model.Order, model.Dispute, error) {
+	return s.changeDispute(ctx, id, d.ID, "dispute_opened", func(o *model.Order, now time.Time) error {
+		return o.OpenDispute(d, now)
+	})
+}
+
+// UpdateDispute moves an open dispute on. A nil dueBy keeps the evidence
+// deadline it has.
+func (s *Orders) UpdateDispute(ctx context.Context, id uint64, disputeID, status string, dueBy *time.Time) (model.Order, model.Dispute, error) {
+	return s.changeDispute(ctx, id, disputeID, "dispute_updated", func(o *model.Order, now time.Time) error {
+		return o.UpdateDispute(disputeID, status, dueBy, now)
+	})
+}
+
+// CloseDispute settles a dispute as won or lost.
+func (s *Orders) CloseDispute(ctx context.Context, id uint64, disputeID, status string) (model.Order, model.Dispute, error) {
+
+	if status != model.DisputeWon && status != model.DisputeLost {
+		return model.Order{}, model.Dispute{}, fmt.Errorf("dispute cannot close as %q: %w", status, model.ErrInvalidDispute)
+	}
+
+	return s.changeDispute(ctx, id, disputeID, "dispute_closed", func(o *model.Order, now time.Time) error {
+		return o.UpdateDispute(disputeID, status, nil, now)
+	})
+}
+
+// AddDisputeEvidence refers an open dispute to uploaded attachments of
+// the order.
+func (s *Orders) AddDisputeEvidence(ctx context.Context, id uint64, disputeID string, attachmentIDs []string) (model.Order, model.Dispute, error) {
+	return s.changeDispute(ctx, id, disputeID, "dispute_evidence", func(o *model.Order, now time.Time) error {
+		return o.AddDisputeEvidence(disputeID, attachmentIDs, now)
+	})
+}
+
+// MissDisputeDeadlines marks the disputes of the order that still need a
+// response after their evidence deadline, and returns them. It wraps
+// model.ErrNoDeadlineMissed when there are none.
+func (s *Orders) MissDisputeDeadlines(ctx context.Context, id uint64) (model.Order, []model.Dispute, error) {
+
+	var missed []model.Dispute
+
+	o, err := s.change(ctx, id, "dispute_deadline_missed", func(o *model.Order, now time.Time) error {
+		var err error
+		missed, err = o.MissDisputeDeadlines(now)
+		return err
+	})
+	if err != nil {
+		return model.Order{}, nil, err
+	}
+
+	return o, missed, nil
+}
+
+func (s *Orders) changeDispute(ctx context.Context, id uint64, disputeID, to string, fn func(*model.Order, time.Time) error) (model.Order, model.Dispute, error) {
+
+	o, err := s.change(ctx, id, to, fn)
+	if err != nil {
+		return model.Order{}, model.Dispute{}, err
+	}
+
+	d, err := o.FindDispute(disputeID)
+	if err != nil {
+		return model.Order{}, model.Dispute{}, err
+	}
+
+	return o, *d, nil
+}
@@ -0,0 +1,108 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/i101dev/microservices-NN/model"
+	"github.com/i101dev/microservices-NN/repository/order"
+)
+
+// RedeemGiftCard pays up to amount of what is due on an order from a gift
+// card, or all of it when amount is zero. A card holding less than that
+// is emptied. It fails with giftcard.ErrUnknownCard or
+// giftcard.ErrNoBalance, and wraps model.ErrNotRedeemable for orders that
+// have shipped or have nothing left to pay.
+func (s *Orders) RedeemGiftCard(ctx context.Context, id uint64, code string, amount uint) (model.Order, error) {
+
+	return s.redeem(ctx, id, amount, model.Redemption{Source: model.RedemptionGiftCard, Code: code},
+		func(o model.Order, want uint) (uint, error) { return s.Balances.RedeemCard(ctx, code, want) },
+		func(o model.Order, taken uint) error { return s.Balances.RefundCard(ctx, code, taken) },
+	)
+}
+
+// RedeemStoreCredit pays from the store credit of the order's customer,
+// like RedeemGiftCard.
+func (s *Orders) RedeemStoreCredit(ctx context.Context, id uint64, amount uint) (model.Order, error) {
+
+	return s.redeem(ctx, id, amount, model.Redemption{Source: model.RedemptionStoreCredit},
+		func(o model.Order, want uint) (uint, error) { return s.Balances.RedeemCredit(ctx, o.CustomerID, want) },
+		func(o model.Order, taken uint) error { return s.Balances.AddCredit(ctx, o.CustomerID, taken) },
+	)
+}
+
+// redeem takes the balance first and records it on the order after, so
+// two orders can never spend the same balance. The order is written only
+// if it has not changed since it was read, and what was taken is given
+// back if it cannot be written, conflicts included.
+func (s *Orders) redeem(ctx context.Context, id uint64, amount uint, r model.Redemption, take func(model.Order, uint) (uint, error), giveBack func(model.Order, uint) error) (model.Order, error) {
+
+	o, err := s.Repo.FindByID(ctx, id)
+	if err != nil {
+		return model.Order{}, err
+	}
+
+	if !o.Redeemable() {
+		return model.Order{}, fmt.Errorf("cannot redeem on %s order with %d due: %w", o.Status(), o.Due(), model.ErrNotRedeemable)
+	}
+
+	want := o.Due()
+	if amount > 0 {
+		want = min(want, amount)
+	}
+
+	r.Amount, err = take(o, want)
+	if err != nil {
+		return model.Order{}, err
+	}
+
+	updated, err := s.record(ctx, o, r)
+	if err != nil {
+		if err := giveBack(o, r.Amount); err != nil {
+			fmt.Printf("failed to give back %d redeemed on order %d: %v\n", r.Amount, id, err)
+		}
+		return model.Order{}, err
+	}
+
+	return updated, nil
+}
+
+// record writes the redemption on the order, failing with
+// order.ErrConflict when the order changed since it was read.
+func (s *Orders) record(ctx context.Context, o model.Order, r model.Redemption) (model.Order, error) {
+
+	readAt := o.UpdatedAt
+	now := s.now()
+
+	r.At = now
+	if err := o.Redeem(r); err != nil {
+		return model.Order{}, fmt.Errorf("cannot redeem on %s order: %w", o.Status(), err)
+	}
+
+	o.UpdatedAt = &now
+
+	batch := order.Batch{
+		Update: []model.Order{o},
+		Expect: map[uint64]*time.Time{o.OrderID: readAt},
+	}
+
+	if err := s.Repo.Apply(ctx, batch); err != nil {
+		return model.Order{}, fmt.Errorf("failed to redeem: %w", err)
+	}
+
+	return o, nil
+}
+
+// creditRedeemed gives the customer of a cancelled order what it was paid
+// with gift cards and store credit as store credit.
+func (s *Orders) creditRedeemed(ctx context.Context, o model.Order) {
+
+	if s.Balances == nil || len(o.Redemptions) == 0 {
+		return
+	}
+
+	if err := s.Balances.AddCredit(ctx, o.CustomerID, o.Redeemed()); err != nil {
+		fmt.Printf("failed to credit %d back for cancelled order %d: %v\n", o.Redeemed(), o.OrderID, err)
+	}
+}
@@ -0,0 +1,209 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/i101dev/microservices-NN/model"
+	"github.com/i101dev/microservices-NN/repository/order"
+)
+
+var (
+	ErrNotMergeable = errors.New("orders cannot be merged")
+	ErrInvalidSplit = errors.New("order cannot be split like this")
+)
+
+// Merge moves the items of every order in ids into the first one and
+// cancels the others. The orders must be distinct, pending and placed by
+// the same customer for the same organization, if any. It fails with order.ErrConflict if any of them
+// changes while the merge is written.
+func (s *Orders) Merge(ctx context.Context, ids []uint64) (model.Order, error) {
+
+	if len(ids) < 2 {
+		return model.Order{}, fmt.Errorf("%w: at least two orders are needed", ErrNotMergeable)
+	}
+
+	orders := make([]model.Order, len(ids))
+	expect := make(map[uint64]*time.Time, len(ids))
+
+	for i, id := range ids {
+		if slices.Contains(ids[:i], id) {
+			return model.Order{}, fmt.Errorf("%w: order %d is listed twice", ErrNotMergeable, id)
+		}
+
+		o, err := s.Repo.FindByID(ctx, id)
+		if err != nil {
+			return model.Order{}, err
+		}
+
+		if i > 0 && o.CustomerID != orders[0].CustomerID {
+			return model.Order{}, fmt.Errorf("%w: order %d belongs to another customer", ErrNotMergeable, id)
+		}
+
+		if i > 0 && !sameOrg(o.OrgID, orders[0].OrgID) {
+			return model.Order{}, fmt.Errorf("%w: order %d is for another organization", ErrNotMergeable, id)
+		}
+
+		if status := o.Status(); status != model.StatusPending {
+			return model.Order{}, fmt.Errorf("%w: order %d is %s", ErrNotMergeable, id, status)
+		}
+
+		orders[i] = o
+		expect[id] = o.UpdatedAt
+	}
+
+	now := s.now()
+	target := orders[0]
+
+	batch := order.Batch{
+		Expect: expect,
+		History: []model.HistoryEntry{
+			{OrderID: target.OrderID, At: now, Action: model.HistoryMergedFrom, Related: ids[1:]},
+		},
+	}
+
+	for _, o := range orders[1:] {
+		target.LineItems = model.CombineLineItems(target.LineItems, o.LineItems)
+
+		// What was redeemed on the merged orders now pays for the target,
+		// so cancelling them here gives nothing back.
+		target.Redemptions = append(slices.Clone(target.Redemptions), o.Redemptions...)
+		o.Redemptions = nil
+
+		batch.History = append(batch.History, model.HistoryEntry{
+			OrderID:   o.OrderID,
+			At:        now,
+			Action:    model.HistoryMergedInto,
+			Related:   []uint64{target.OrderID},
+			LineItems: o.LineItems,
+		})
+
+		o.CancelledAt = &now
+		o.UpdatedAt = &now
+		batch.Update = append(batch.Update, o)
+	}
+
+	if err := s.calculateTax(ctx, &target); err != nil {
+		return model.Order{}, err
+	}
+
+	// The merged items may not be where the target was sent from, so it is
+	// routed again.
+	target.Assignments = nil
+	target.UpdatedAt = &now
+	batch.Update = append([]model.Order{target}, batch.Update...)
+
+	if err := s.Repo.Apply(ctx, batch); err != nil {
+		return model.Order{}, fmt.Errorf("failed to merge: %w", err)
+	}
+
+	return target, nil
+}
+
+// sameOrg reports whether two orders are for the same organization, or
+// both for none.
+func sameOrg(a, b *uuid.UUID) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// Split moves the given quantities of items out of a pending order into a
+// new one for the same customer, organization and shipping, and returns
+// both. Prices in
+// items are ignored: moved lines keep the price they were ordered at.
+// Gift card and store credit redemptions stay with the original order.
+// Both are routed to warehouses afresh.
+func (s *Orders) Split(ctx context.Context, id uint64, items []model.LineItem) (model.Order, model.Order, error) {
+
+	o, err := s.Repo.FindByID(ctx, id)
+	if err != nil {
+		return model.Order{}, model.Order{}, err
+	}
+
+	if status := o.Status(); status != model.StatusPending {
+		return model.Order{}, model.Order{}, fmt.Errorf("%w: order is %s", ErrInvalidSplit, status)
+	}
+
+	if len(items) == 0 {
+		return model.Order{}, model.Order{}, fmt.Errorf("%w: no items to move", ErrInvalidSplit)
+	}
+
+	readAt := o.UpdatedAt
+	remaining := slices.Clone(o.LineItems)
+	var moved []model.LineItem
+
+	for _, item := range items {
+		if item.Quantity == 0 {
+			return model.Order{}, model.Order{}, fmt.Errorf("%w: item %s has no quantity", ErrInvalidSplit, item.ItemID)
+		}
+
+		left := item.Quantity
+
+		for i := range remaining {
+			if left == 0 {
+				break
+			}
+			if remaining[i].ItemID != item.ItemID || remaining[i].Quantity == 0 {
+				continue
+			}
+			n := min(left, remaining[i].Quantity)
+			remaining[i].Quantity -= n
+			left -= n
+			moved = model.CombineLineItems(moved, []model.LineItem{{ItemID: item.ItemID, Quantity: n, Price: remaining[i].Price}})
+		}
+
+		if left > 0 {
+			return model.Order{}, model.Order{}, fmt.Errorf("%w: the order has fewer than %d of item %s", ErrInvalidSplit, item.Quantity, item.ItemID)
+		}
+	}
+
+	remaining = slices.DeleteFunc(remaining, func(l model.LineItem) bool { return l.Quantity == 0 })
+
+	if len(remaining) == 0 {
+		return model.Order{}, model.Order{}, fmt.Errorf("%w: it would leave the order empty", ErrInvalidSplit)
+	}
+
+	split := s.New(o.CustomerID, moved)
+	now := *split.CreatedAt
+
+	if o.OrgID != nil {
+		orgID := *o.OrgID
+		split.OrgID = &orgID
+	}
+
+	if o.Shipping != nil {
+		shipping := *o.Shipping
+		split.Shipping = &shipping
+		s.estimate(&split, now)
+	}
+
+	o.LineItems = remaining
+	o.Assignments = nil
+	o.UpdatedAt = &now
+
+	if err := s.splitTax(ctx, &o, &split); err != nil {
+		return model.Order{}, model.Order{}, err
+	}
+
+	batch := order.Batch{
+		Insert: []model.Order{split},
+		Update: []model.Order{o},
+		Expect: map[uint64]*time.Time{o.OrderID: readAt},
+		History: []model.HistoryEntry{
+			{OrderID: o.OrderID, At: now, Action: model.HistorySplitInto, Related: []uint64{split.OrderID}, LineItems: moved},
+			{OrderID: split.OrderID, At: now, Action: model.HistorySplitFrom, Related: []uint64{o.OrderID}},
+		},
+	}
+
+	if err := s.Repo.Apply(ctx, batch); err != nil {
+		return model.Order{}, model.Order{}, fmt.Errorf("failed to split: %w", err)
+	}
+
+	return o, split, nil
+}
@@ -0,0 +1,536 @@
+// Package service is the order lifecycle without any transport. The HTTP
+// and GraphQL APIs are built on it, and other Go programs can embed it
+// directly with their own repository:
+//
+//	orders := &service.Orders{Repo: repo}
+//	o, err := orders.Create(ctx, customerID, items)
+//	o, err = orders.Ship(ctx, o.OrderID)
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/i101dev/microservices-NN/cancellation"
+	"github.com/i101dev/microservices-NN/catalog"
+	"github.com/i101dev/microservices-NN/clock"
+	"github.com/i101dev/microservices-NN/dupcheck"
+	"github.com/i101dev/microservices-NN/eta"
+	"github.com/i101dev/microservices-NN/fraud"
+	"github.com/i101dev/microservices-NN/fulfillment"
+	"github.com/i101dev/microservices-NN/giftcard"
+	"github.com/i101dev/microservices-NN/inventory"
+	"github.com/i101dev/microservices-NN/model"
+	"github.com/i101dev/microservices-NN/orderindex"
+	"github.com/i101dev/microservices-NN/quote"
+	"github.com/i101dev/microservices-NN/repository/order"
+	"github.com/i101dev/microservices-NN/storage"
+	"github.com/i101dev/microservices-NN/tax"
+	"github.com/i101dev/microservices-NN/tenant"
+)
+
+// Orders owns the order lifecycle rules shared by every API. Timestamps are
+// always stamped here and never taken from callers.
+type Orders struct {
+	Repo  order.Repository
+	Clock clock.Clock
+	// Duplicates, when set, is consulted for every new order.
+	Duplicates *dupcheck.Detector
+	// Fraud screens every new order. Nil allows everything.
+	Fraud fraud.Checker
+	// Catalog prices the items of duplicated orders. Nil keeps the prices
+	// they were ordered at.
+	Catalog catalog.Catalog
+	// ETA estimates the delivery of orders with shipping details when they
+	// are created and when they ship. Nil leaves them without estimate.
+	ETA eta.Estimator
+	// Tax works out the tax of new orders, and of orders whose items change
+	// in a merge or split. Nil leaves them untaxed.
+	Tax tax.Calculator
+	// Inventory reserves stock for new orders, which backorders the items
+	// that are short. Nil takes every order as in stock.
+	Inventory inventory.Reserver
+	// Balances, when set, lets orders be paid with gift cards and store
+	// credit, and takes the redeemed amounts of cancelled orders back as
+	// store credit.
+	Balances *giftcard.Balances
+	// Quotes, when set, keeps priced drafts until they are converted into
+	// orders.
+	Quotes *quote.Store
+	// Index, when set, lets orders be listed by status, customer and tag.
+	Index *orderindex.Index
+	// Blobs, when set, keeps the files attached to orders.
+	Blobs storage.Blob
+	// SLA, when set, gives orders a deadline to ship by once their payment
+	// is authorized.
+	SLA *SLAPolicy
+	// Fulfillment, when set, routes orders to the warehouses that send
+	// them.
+	Fulfillment *fulfillment.Assigner
+	// Cancellation decides which cancellations callers may make, and what
+	// they cost. Nil only keeps orders that have shipped from being
+	// cancelled.
+	Cancellation *cancellation.Policy
+}
+
+var (
+	// ErrNothingToOrder is returned when every item of an order to
+	// duplicate has been discontinued.
+	ErrNothingToOrder = errors.New("no items of the order are still sold")
+	// ErrTaxUnavailable is returned when the tax of an order could not be
+	// worked out. The order is not taken.
+	ErrTaxUnavailable = errors.New("tax calculation is unavailable")
+)
+
+func (s *Orders) now() time.Time {
+	if s.Clock == nil {
+		return time.Now().UTC()
+	}
+	return s.Clock.Now().UTC()
+}
+
+// New builds an order ready to insert, with a fresh ID and CreatedAt and
+// UpdatedAt set to now.
+func (s *Orders) New(customerID uuid.UUID, items []model.LineItem) model.Order {
+
+	now := s.now()
+
+	return model.Order{
+		OrderID:    rand.Uint64(),
+		CustomerID: customerID,
+		LineItems:  items,
+		CreatedAt:  &now,
+		UpdatedAt:  &now,
+	}
+}
+
+// Prepare is New followed by the duplicate check and the fraud check. It
+// returns an error wrapping dupcheck.ErrDuplicate when duplicates are
+// rejected, and otherwise flags the order. Orders the fraud check holds
+// come back with status review. Call Discard if the order is not stored.
+func (s *Orders) Prepare(ctx context.Context, customerID uuid.UUID, items []model.LineItem) (model.Order, error) {
+	return s.PrepareDraft(ctx, Draft{CustomerID: customerID, LineItems: items})
+}
+
+// PrepareDraft is Prepare for a draft, which can also carry shipping
+// details.
+func (s *Orders) PrepareDraft(ctx context.Context, d Draft) (model.Order, error) {
+
+	o := s.New(d.CustomerID, d.LineItems)
+	o.OrgID = d.OrgID
+	o.Metadata = d.Metadata
+
+	if d.Priority == model.PriorityExpedited {
+		o.Priority = d.Priority
+		o.ExpeditedAt = o.CreatedAt
+	}
+
+	if d.Shipping != nil {
+		shipping := *d.Shipping
+		o.Shipping = &shipping
+		s.estimate(&o, *o.CreatedAt)
+	}
+
+	if err := s.calculateTax(ctx, &o); err != nil {
+		return model.Order{}, err
+	}
+
+	if err := s.checkDuplicate(ctx, &o); err != nil {
+		return model.Order{}, err
+	}
+
+	s.screen(ctx, &o)
+
+	return o, nil
+}
+
+func (s *Orders) checkDuplicate(ctx context.Context, o *model.Order) error {
+
+	if s.Duplicates == nil {
+		return nil
+	}
+
+	prev, err := s.Duplicates.Check(ctx, tenant.FromContext(ctx), *o)
+	if err != nil {
+		// A broken check must not stop orders from being taken.
+		fmt.Println("failed to check for duplicate order:", err)
+		return nil
+	}
+
+	if prev == 0 {
+		return nil
+	}
+
+	if s.Duplicates.Reject {
+		return fmt.Errorf("%w of order %d", dupcheck.ErrDuplicate, prev)
+	}
+
+	o.PossibleDuplicate = true
+
+	return nil
+}
+
+// screen holds the order for review when the fraud check asks for it, or
+// when the check itself fails.
+func (s *Orders) screen(ctx context.Context, o *model.Order) {
+
+	if s.Fraud == nil {
+		return
+	}
+
+	v, err := s.Fraud.Check(ctx, *o)
+	if err != nil {
+		fmt.Printf("failed to fraud check order %d, holding it for review: %v\n", o.OrderID, err)
+		v = fraud.Verdict{Decision: fraud.DecisionReview, Reason: "fraud check unavailable"}
+	}
+
+	if v.Decision != fraud.DecisionReview {
+		return
+	}
+
+	o.FlaggedAt = o.CreatedAt
+	o.ReviewReason = v.Reason
+}
+
+// Discard undoes the bookkeeping Prepare did for orders that were never
+// stored.
+func (s *Orders) Discard(ctx context.Context, orders ...model.Order) {
+
+	if s.Duplicates == nil {
+		return
+	}
+
+	for _, o := range orders {
+		if err := s.Duplicates.Release(ctx, tenant.FromContext(ctx), o); err != nil {
+			fmt.Println("failed to discard order:", err)
+		}
+	}
+}
+
+// estimate sets the delivery estimate of an order handed over at from. An
+// order the estimator has no rule for keeps the estimate it had.
+func (s *Orders) estimate(o *model.Order, from time.Time) {
+
+	if s.ETA == nil {
+		return
+	}
+
+	if e, ok := s.ETA.Estimate(*o, from); ok {
+		o.EstimatedDelivery = &e
+	}
+}
+
+// calculateTax sets the tax of an order from its current items and
+// shipping.
+func (s *Orders) calculateTax(ctx context.Context, o *model.Order) error {
+
+	if s.Tax == nil {
+		return nil
+	}
+
+	t, err := s.Tax.Calculate(ctx, *o)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrTaxUnavailable, err)
+	}
+
+	o.Tax = &t
+
+	return nil
+}
+
+func (s *Orders) Create(ctx context.Context, customerID uuid.UUID, items []model.LineItem) (model.Order, error) {
+	return s.CreateDraft(ctx, Draft{CustomerID: customerID, LineItems: items})
+}
+
+func (s *Orders) CreateDraft(ctx context.Context, d Draft) (model.Order, error) {
+
+	o, err := s.PrepareDraft(ctx, d)
+	if err != nil {
+		return model.Order{}, err
+	}
+
+	return s.insert(ctx, o)
+}
+
+// insert reserves the stock of a prepared order and stores it, undoing
+// what Prepare did if either fails.
+func (s *Orders) insert(ctx context.Context, o model.Order) (model.Order, error) {
+
+	batch, err := s.reserve(ctx, o)
+	if err != nil {
+		s.Discard(ctx, o)
+		return model.Order{}, err
+	}
+
+	if err := s.store(ctx, batch); err != nil {
+		s.Discard(ctx, o)
+		s.release(ctx, o.OrderID)
+		return model.Order{}, fmt.Errorf("failed to insert: %w", err)
+	}
+
+	return batch.Insert[0], nil
+}
+
+type Draft struct {
+	CustomerID uuid.UUID
+	OrgID      *uuid.UUID
+	LineItems  []model.LineItem
+	Shipping   *model.Shipping
+	Metadata   map[string]json.RawMessage
+	// Priority is model.PriorityExpedited for orders to fulfill first.
+	Priority string
+}
+
+// CreateAll creates an order for every draft, or none of them if any
+// insert fails.
+func (s *Orders) CreateAll(ctx context.Context, drafts []Draft) ([]model.Order, error) {
+
+	orders := make([]model.Order, 0, len(drafts))
+	var batch order.Batch
+
+	// undo releases the stock and fingerprints of every order so far.
+	undo := func() {
+		s.Discard(ctx, orders...)
+		for _, o := range orders {
+			s.release(ctx, o.OrderID)
+		}
+	}
+
+	for _, d := range drafts {
+		o, err := s.PrepareDraft(ctx, d)
+		if err != nil {
+			undo()
+			return nil, err
+		}
+
+		b, err := s.reserve(ctx, o)
+		if err != nil {
+			s.Discard(ctx, o)
+			undo()
+			return nil, err
+		}
+
+		orders = append(orders, b.Insert[0])
+		batch.Insert = append(batch.Insert, b.Insert...)
+		batch.History = append(batch.History, b.History...)
+	}
+
+	var err error
+	if len(batch.History) == 0 {
+		err = s.Repo.InsertAll(ctx, orders)
+	} else {
+		err = s.Repo.Apply(ctx, batch)
+	}
+
+	if err != nil {
+		undo()
+		return nil, fmt.Errorf("failed to insert all: %w", err)
+	}
+
+	return orders, nil
+}
+
+// Duplicate places a new order for the customer of order id with the same
+// items and shipping, at their current catalog prices, leaving out
+// discontinued ones.
+// The new order goes through the same checks as any other.
+func (s *Orders) Duplicate(ctx context.Context, id uint64) (model.Order, error) {
+
+	src, err := s.Repo.FindByID(ctx, id)
+	if err != nil {
+		return model.Order{}, err
+	}
+
+	items, err := s.reprice(ctx, src.LineItems)
+	if err != nil {
+		return model.Order{}, err
+	}
+
+	if len(items) == 0 {
+		return model.Order{}, fmt.Errorf("cannot duplicate order %d: %w", id, ErrNothingToOrder)
+	}
+
+	return s.CreateDraft(ctx, Draft{CustomerID: src.CustomerID, LineItems: items, Shipping: src.Shipping})
+}
+
+func (s *Orders) reprice(ctx context.Context, items []model.LineItem) ([]model.LineItem, error) {
+
+	if s.Catalog == nil {
+		return slices.Clone(items), nil
+	}
+
+	ids := make([]uuid.UUID, 0, len(items))
+	for _, item := range items {
+		if !slices.Contains(ids, item.ItemID) {
+			ids = append(ids, item.ItemID)
+		}
+	}
+
+	current, err := s.Catalog.Lookup(ctx, ids)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up prices: %w", err)
+	}
+
+	repriced := make([]model.LineItem, 0, len(items))
+
+	for _, item := range items {
+		c, ok := current[item.ItemID]
+		if !ok || c.Discontinued {
+			continue
+		}
+		item.Price = c.Price
+		repriced = append(repriced, item)
+	}
+
+	return repriced, nil
+}
+
+func (s *Orders) Get(ctx context.Context, id uint64) (model.Order, error) {
+	return s.Repo.FindByID(ctx, id)
+}
+
+const defaultPageSize = 50
+
+// List returns a page of orders in no particular order. Pass the returned
+// Next as cursor for the following page; it is zero after the last one.
+// A size of zero uses the default page size.
+func (s *Orders) List(ctx context.Context, cursor uint64, size uint64) (model.OrderPage, error) {
+
+	if size == 0 {
+		size = defaultPageSize
+	}
+
+	res, err := s.Repo.FindAll(ctx, order.FindAllPage{
+		Offset: cursor,
+		Size:   size,
+	})
+
+	if err != nil {
+		return model.OrderPage{}, err
+	}
+
+	return model.OrderPage{
+		Items: res.Orders,
+		Next:  res.Cursor,
+	}, nil
+}
+
+// ListConsistent is List over a snapshot of the orders, so pages read
+// while orders are written neither repeat nor miss any. An empty snapshot
+// takes a new one; pass the returned Snapshot back with Next.
+func (s *Orders) ListConsistent(ctx context.Context, snapshot string, cursor uint64, size uint64) (model.OrderPage, error) {
+
+	if size == 0 {
+		size = defaultPageSize
+	}
+
+	res, err := s.Repo.FindAll(ctx, order.FindAllPage{
+		Offset:     cursor,
+		Size:       size,
+		Consistent: true,
+		Snapshot:   snapshot,
+	})
+
+	if err != nil {
+		return model.OrderPage{}, err
+	}
+
+	return model.OrderPage{
+		Items:    res.Orders,
+		Next:     res.Cursor,
+		Snapshot: res.Snapshot,
+	}, nil
+}
+
+func (s *Orders) Ship(ctx context.Context, id uint64) (model.Order, error) {
+	return s.Transition(ctx, id, model.StatusShipped)
+}
+
+func (s *Orders) Complete(ctx context.Context, id uint64) (model.Order, error) {
+	return s.Transition(ctx, id, model.StatusCompleted)
+}
+
+// Cancel cancels an order for a caller, if the cancellation policy allows
+// it, and records the fee it sets and the reason given. It wraps a
+// *cancellation.Rejection with every reason when the policy does not.
+// Cancellations the store makes itself go through Transition instead.
+func (s *Orders) Cancel(ctx context.Context, id uint64, reason string) (model.Order, error) {
+
+	return s.change(ctx, id, model.StatusCancelled, func(o *model.Order, now time.Time) error {
+		d, err := s.Cancellation.Evaluate(*o, now)
+		if err != nil {
+			return err
+		}
+		return o.CancelFor(model.Cancellation{Fee: d.Fee, Rule: d.Rule, Reason: reason}, now)
+	})
+}
+
+// Transition moves an order to status and bumps UpdatedAt. It returns
+// order.ErrNotExist for unknown IDs and wraps model.ErrInvalidTransition
+// when the move is not allowed. Shipping an order estimates its delivery
+// again from the day it left.
+func (s *Orders) Transition(ctx context.Context, id uint64, status string) (model.Order, error) {
+
+	return s.change(ctx, id, status, func(o *model.Order, now time.Time) error {
+		if err := o.Transition(status, now); err != nil {
+			return err
+		}
+		if status == model.StatusShipped {
+			s.estimate(o, now)
+		}
+		return nil
+	})
+}
+
+// Approve releases an order held for review. It fails with
+// model.ErrInvalidTransition for orders that are not in review.
+func (s *Orders) Approve(ctx context.Context, id uint64) (model.Order, error) {
+	return s.change(ctx, id, "approved", (*model.Order).Approve)
+}
+
+// Expedite gives an order that is still to ship the expedited priority.
+// It wraps model.ErrAlreadyExpedited for orders that have it already.
+func (s *Orders) Expedite(ctx context.Context, id uint64) (model.Order, error) {
+	return s.change(ctx, id, model.PriorityExpedited, (*model.Order).Expedite)
+}
+
+func (s *Orders) change(ctx context.Context, id uint64, to string, fn func(*model.Order, time.Time) error) (model.Order, error) {
+
+	o, err := s.Repo.FindByID(ctx, id)
+	if err != nil {
+		return model.Order{}, err
+	}
+
+	now := s.now()
+	from := o.Status()
+	readAt := o.UpdatedAt
+
+	if err := fn(&o, now); err != nil {
+		return model.Order{}, fmt.Errorf("cannot move order from %s to %s: %w", from, to, err)
+	}
+
+	o.UpdatedAt = &now
+
+	// The write only lands on the order as read, so two concurrent changes
+	// cannot both cancel it and pay its redemptions back twice.
+	batch := order.Batch{
+		Update: []model.Order{o},
+		Expect: map[uint64]*time.Time{id: readAt},
+	}
+
+	if err := s.Repo.Apply(ctx, batch); err != nil {
+		return model.Order{}, fmt.Errorf("failed to update: %w", err)
+	}
+
+	if from != model.StatusCancelled && o.Status() == model.StatusCancelled {
+		s.creditRedeemed(ctx, o)
+	}
+
+	return o, nil
+}
@@ -0,0 +1,40 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/i101dev/microservices-NN/model"
+)
+
+// AuthorizePayment, CapturePayment and RefundPayment record what the
+// payment provider did with the money for an order. They wrap
+// model.ErrInvalidPayment for steps out of order, such as a capture
+// before the authorization.
+
+func (s *Orders) AuthorizePayment(ctx context.Context, id uint64, reference string, amount uint) (model.Order, error) {
+
+	return s.change(ctx, id, model.PaymentAuthorized, func(o *model.Order, now time.Time) error {
+		if err := o.AuthorizePayment(reference, amount, now); err != nil {
+			return err
+		}
+		s.startSLA(o, now)
+		return nil
+	})
+}
+
+func (s *Orders) CapturePayment(ctx context.Context, id uint64, amount uint) (model.Order, error) {
+
+	return s.change(ctx, id, model.PaymentCaptured, func(o *model.Order, now time.Time) error {
+		return o.CapturePayment(amount, now)
+	})
+}
+
+// RefundPayment cancels an order that is refunded in full before it
+// ships.
+func (s *Orders) RefundPayment(ctx context.Context, id uint64, amount uint) (model.Order, error) {
+
+	return s.change(ctx, id, model.PaymentRefunded, func(o *model.Order, now time.Time) error {
+		return o.RefundPayment(amount, now)
+	})
+}
@@ -0,0 +1,229 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/i101dev/microservices-NN/model"
+	"github.com/i101dev/microservices-NN/quote"
+	"github.com/i101dev/microservices-NN/repository/order"
+	"github.com/i101dev/microservices-NN/tax"
+)
+
+// Quote prices a draft without placing it: items are priced from the
+// catalog when there is one, discontinued ones are left out, and tax is
+// worked out. The quote holds those prices until ttl from now.
+func (s *Orders) Quote(ctx context.Context, d Draft, ttl time.Duration) (quote.Quote, error) {
+
+	items, err := s.reprice(ctx, d.LineItems)
+	if err != nil {
+		return quote.Quote{}, err
+	}
+
+	if len(items) == 0 {
+		return quote.Quote{}, fmt.Errorf("cannot quote: %w", ErrNothingToOrder)
+	}
+
+	now := s.now()
+
+	// The tax calculator only sees orders, so the quote is priced as one.
+	o := model.Order{CustomerID: d.CustomerID, LineItems: items, Shipping: d.Shipping}
+	if err := s.calculateTax(ctx, &o); err != nil {
+		return quote.Quote{}, err
+	}
+
+	q := quote.Quote{
+		ID:         uuid.NewString(),
+		CustomerID: d.CustomerID,
+		LineItems:  items,
+		Shipping:   d.Shipping,
+		Tax:        o.Tax,
+		Total:      o.Total(),
+		ExpiresAt:  now.Add(ttl),
+		CreatedAt:  now,
+	}
+	q.Refresh(now)
+
+	if err := s.Quotes.Insert(ctx, q); err != nil {
+		return quote.Quote{}, err
+	}
+
+	return q, nil
+}
+
+func (s *Orders) GetQuote(ctx context.Context, id string) (quote.Quote, error) {
+
+	q, err := s.Quotes.Get(ctx, id)
+	if err != nil {
+		return quote.Quote{}, err
+	}
+
+	if err := s.refreshQuote(ctx, &q, s.now()); err != nil {
+		return quote.Quote{}, err
+	}
+
+	return q, nil
+}
+
+// refreshQuote is Quote.Refresh for a quote whose order may never have
+// been stored: a conversion that stopped between marking the quote and
+// writing the order leaves it converted into nothing, and it is accepted
+// again once ConvertTimeout has passed, or expired.
+func (s *Orders) refreshQuote(ctx context.Context, q *quote.Quote, now time.Time) error {
+
+	q.Refresh(now)
+
+	if q.Status != quote.StatusConverted || now.Sub(*q.ConvertedAt) < quote.ConvertTimeout {
+		return nil
+	}
+
+	_, err := s.Repo.FindByID(ctx, q.OrderID)
+	if errors.Is(err, order.ErrNotExist) {
+		return q.Unconvert(q.OrderID, now)
+	} else if err != nil {
+		return fmt.Errorf("failed to find converted order: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Orders) AcceptQuote(ctx context.Context, id string) (quote.Quote, error) {
+
+	now := s.now()
+
+	return s.Quotes.Update(ctx, id, func(q *quote.Quote) error {
+		if err := q.Accept(now); err != nil {
+			return fmt.Errorf("cannot accept %s quote: %w", q.Status, err)
+		}
+		return nil
+	})
+}
+
+// ConvertQuote places the order of an accepted quote at the quoted prices
+// and tax. The quote is marked converted before the order is written and
+// put back if the write fails, so it turns into at most one order however
+// many times it is converted at once. The write is given up after
+// quote.ConvertTimeout, so that a quote still converted into no order
+// after that was left behind, and can be converted again.
+func (s *Orders) ConvertQuote(ctx context.Context, id string) (model.Order, error) {
+
+	o := s.New(uuid.Nil, nil)
+
+	q, err := s.Quotes.Update(ctx, id, func(q *quote.Quote) error {
+		if err := s.refreshQuote(ctx, q, *o.CreatedAt); err != nil {
+			return err
+		}
+		if err := q.Convert(o.OrderID, *o.CreatedAt); err != nil {
+			return fmt.Errorf("cannot convert %s quote: %w", q.Status, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return model.Order{}, err
+	}
+
+	o.CustomerID = q.CustomerID
+	o.LineItems = q.LineItems
+	o.QuoteID = q.ID
+
+	if q.Shipping != nil {
+		shipping := *q.Shipping
+		o.Shipping = &shipping
+		s.estimate(&o, *o.CreatedAt)
+	}
+
+	if q.Tax != nil {
+		t := *q.Tax
+		o.Tax = &t
+	}
+
+	placeCtx, cancel := context.WithTimeout(ctx, quote.ConvertTimeout)
+	defer cancel()
+
+	placed, err := s.place(placeCtx, o)
+	if err != nil {
+		_, undoErr := s.Quotes.Update(context.WithoutCancel(ctx), id, func(q *quote.Quote) error {
+			return q.Unconvert(o.OrderID, s.now())
+		})
+		return model.Order{}, errors.Join(err, undoErr)
+	}
+
+	return placed, nil
+}
+
+// place runs the checks Prepare would on an order that was built
+// elsewhere, and inserts it.
+func (s *Orders) place(ctx context.Context, o model.Order) (model.Order, error) {
+
+	if err := s.checkDuplicate(ctx, &o); err != nil {
+		return model.Order{}, err
+	}
+
+	s.screen(ctx, &o)
+
+	return s.insert(ctx, o)
+}
+
+// splitTax taxes an order and the one split off it. Orders converted from a
+// quote share the quoted tax between them instead of being taxed again.
+func (s *Orders) splitTax(ctx context.Context, o, split *model.Order) error {
+
+	if o.QuoteID != "" && o.Tax != nil {
+		o.Tax, split.Tax = prorate(*o.Tax, o.LineItems, split.LineItems)
+		split.QuoteID = o.QuoteID
+		return nil
+	}
+
+	for _, taxed := range []*model.Order{o, split} {
+		if err := s.calculateTax(ctx, taxed); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// prorate divides the quoted tax of an order between the items it keeps
+// and the items moved off it, by quantity. The moved share is
+// what is left after the kept one, so the two still add up to the quote.
+func prorate(t model.Tax, kept, moved []model.LineItem) (*model.Tax, *model.Tax) {
+
+	keptQty := map[uuid.UUID]uint{}
+	totalQty := map[uuid.UUID]uint{}
+
+	for _, item := range kept {
+		keptQty[item.ItemID] += item.Quantity
+		totalQty[item.ItemID] += item.Quantity
+	}
+	for _, item := range moved {
+		totalQty[item.ItemID] += item.Quantity
+	}
+
+	var keptLines, movedLines []model.TaxLine
+
+	for _, line := range t.Lines {
+		if totalQty[line.ItemID] == 0 {
+			continue
+		}
+
+		k := line
+		k.Amount = line.Amount * keptQty[line.ItemID] / totalQty[line.ItemID]
+
+		m := line
+		m.Amount = line.Amount - k.Amount
+
+		if keptQty[line.ItemID] > 0 {
+			keptLines = append(keptLines, k)
+		}
+		if keptQty[line.ItemID] < totalQty[line.ItemID] {
+			movedLines = append(movedLines, m)
+		}
+	}
+
+	keptTax, movedTax := tax.Total(keptLines), tax.Total(movedLines)
+
+	return &keptTax, &movedTax
+}
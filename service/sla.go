@@ -0,0 +1,47 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/i101dev/microservices-NN/model"
+)
+
+// SLAPolicy is how long orders have to ship once their payment is
+// authorized. Methods overrides ShipWithin for orders sent by those
+// shipping methods; a zero target leaves orders without SLA.
+type SLAPolicy struct {
+	ShipWithin time.Duration
+	Methods    map[string]time.Duration
+}
+
+// Target returns how long the order has to ship.
+func (p *SLAPolicy) Target(o model.Order) time.Duration {
+
+	if o.Shipping != nil {
+		if target, ok := p.Methods[o.Shipping.Method]; ok {
+			return target
+		}
+	}
+
+	return p.ShipWithin
+}
+
+// startSLA sets the deadline of the order, counted from now, if the policy
+// gives it one.
+func (s *Orders) startSLA(o *model.Order, now time.Time) {
+
+	if s.SLA == nil {
+		return
+	}
+
+	if target := s.SLA.Target(*o); target > 0 {
+		o.SLA = &model.SLA{ShipBy: now.Add(target)}
+	}
+}
+
+// BreachSLA marks the order as having missed its SLA. It wraps
+// model.ErrSLANotBreached for orders that did not, or were marked already.
+func (s *Orders) BreachSLA(ctx context.Context, id uint64) (model.Order, error) {
+	return s.change(ctx, id, "sla_breached", (*model.Order).BreachSLA)
+}
@@ -0,0 +1,70 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/i101dev/microservices-NN/model"
+	"github.com/i101dev/microservices-NN/orderindex"
+	"github.com/i101dev/microservices-NN/repository/order"
+)
+
+// ErrNotIndexed is returned when orders are filtered without an index to
+// filter them with.
+var ErrNotIndexed = errors.New("orders are not indexed")
+
+func (s *Orders) Tag(ctx context.Context, id uint64, tags []string) (model.Order, error) {
+
+	return s.change(ctx, id, "tagged", func(o *model.Order, _ time.Time) error {
+		return o.AddTags(tags...)
+	})
+}
+
+func (s *Orders) Untag(ctx context.Context, id uint64, tag string) (model.Order, error) {
+
+	return s.change(ctx, id, "untagged", func(o *model.Order, _ time.Time) error {
+		o.RemoveTag(tag)
+		return nil
+	})
+}
+
+// Filter lists the orders matching f, a page at a time like List, oldest
+// first. Orders deleted since the search are skipped, so pages can come
+// back short.
+func (s *Orders) Filter(ctx context.Context, f orderindex.Filter, cursor uint64, size uint64) (model.OrderPage, error) {
+
+	if s.Index == nil {
+		return model.OrderPage{}, ErrNotIndexed
+	}
+
+	if err := f.Validate(); err != nil {
+		return model.OrderPage{}, err
+	}
+
+	if size == 0 {
+		size = defaultPageSize
+	}
+
+	ids, next, err := s.Index.Find(ctx, f, cursor, size)
+	if err != nil {
+		return model.OrderPage{}, err
+	}
+
+	items := make([]model.Order, 0, len(ids))
+
+	for _, id := range ids {
+		o, err := s.Repo.FindByID(ctx, id)
+		if errors.Is(err, order.ErrNotExist) {
+			continue
+		} else if err != nil {
+			return model.OrderPage{}, err
+		}
+		items = append(items, o)
+	}
+
+	return model.OrderPage{
+		Items: items,
+		Next:  next,
+	}, nil
+}
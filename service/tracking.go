@@ -0,0 +1,84 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/i101dev/microservices-NN/model"
+	"github.com/i101dev/microservices-NN/repository/order"
+)
+
+// ErrUntracked is returned for a tracking update about a parcel the order
+// did not ship in.
+var ErrUntracked = errors.New("order did not ship under this tracking number")
+
+// ShipTracked ships an order like Ship and records the parcel it left in.
+// Any status or time on t is dropped, those come from the carrier.
+func (s *Orders) ShipTracked(ctx context.Context, id uint64, t model.Tracking) (model.Order, error) {
+
+	return s.change(ctx, id, model.StatusShipped, func(o *model.Order, now time.Time) error {
+		if err := o.Ship(now); err != nil {
+			return err
+		}
+		s.estimate(o, now)
+		o.Tracking = &model.Tracking{Carrier: t.Carrier, Number: t.Number}
+		return nil
+	})
+}
+
+// Track records a carrier update about the parcel an order shipped in.
+// A delivered parcel completes its order. Updates no newer than the last
+// one recorded are ignored, since carriers resend and reorder them, and
+// the returned bool is false for those. It fails with order.ErrConflict
+// when the order changes while the update is recorded.
+func (s *Orders) Track(ctx context.Context, id uint64, update model.Tracking) (model.Order, bool, error) {
+
+	o, err := s.Repo.FindByID(ctx, id)
+	if err != nil {
+		return model.Order{}, false, err
+	}
+
+	t := o.Tracking
+	if t == nil || t.Carrier != update.Carrier || t.Number != update.Number {
+		return model.Order{}, false, fmt.Errorf("%w: order %d, %s %s", ErrUntracked, id, update.Carrier, update.Number)
+	}
+
+	now := s.now()
+	readAt := o.UpdatedAt
+
+	at := now
+	if update.At != nil {
+		at = update.At.UTC()
+	}
+
+	if t.At != nil && !at.After(*t.At) {
+		return o, false, nil
+	}
+
+	if update.Status == model.TrackingDelivered && o.Status() == model.StatusShipped {
+		if err := o.Complete(now); err != nil {
+			return model.Order{}, false, err
+		}
+	}
+
+	o.Tracking = &model.Tracking{
+		Carrier: t.Carrier,
+		Number:  t.Number,
+		Status:  update.Status,
+		At:      &at,
+	}
+	o.UpdatedAt = &now
+
+	batch := order.Batch{
+		Update: []model.Order{o},
+		Expect: map[uint64]*time.Time{id: readAt},
+	}
+
+	if err := s.Repo.Apply(ctx, batch); err != nil {
+		return model.Order{}, false, fmt.Errorf("failed to update: %w", err)
+	}
+
+	return o, true, nil
+}
@@ -0,0 +1,131 @@
+package shadow
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"sync/atomic"
+	"time"
+
+	"github.com/i101dev/microservices-NN/repository/order"
+)
+
+// Shadow copies every successful write to a second repository, so a new
+// storage backend can take production traffic before cutover. Copies are
+// applied in order by a single worker and never affect the caller: when
+// the buffer is full they are dropped, and failures are only logged.
+type Shadow struct {
+	Target  order.Repository
+	Timeout time.Duration
+
+	calls chan shadowCall
+
+	mirrored atomic.Uint64
+	failed   atomic.Uint64
+	dropped  atomic.Uint64
+}
+
+type shadowCall struct {
+	ctx  context.Context
+	call order.Call
+}
+
+type Stats struct {
+	Mirrored uint64 `json:"mirrored"`
+	Failed   uint64 `json:"failed"`
+	Dropped  uint64 `json:"dropped"`
+	Backlog  int    `json:"backlog"`
+}
+
+func New(target order.Repository, buffer int) *Shadow {
+	return &Shadow{
+		Target:  target,
+		Timeout: 5 * time.Second,
+		calls:   make(chan shadowCall, buffer),
+	}
+}
+
+// Intercept queues a copy of each write once the primary accepted it.
+func (s *Shadow) Intercept(ctx context.Context, call *order.Call, next order.Handler) error {
+
+	if err := next(ctx, call); err != nil {
+		return err
+	}
+
+	switch call.Op {
+	case order.OpInsert, order.OpInsertAll, order.OpUpdate, order.OpDeleteByID, order.OpApply:
+	default:
+		return nil
+	}
+
+	c := *call
+	c.Orders = slices.Clone(call.Orders)
+
+	select {
+	case s.calls <- shadowCall{ctx: context.WithoutCancel(ctx), call: c}:
+	default:
+		s.dropped.Add(1)
+	}
+
+	return nil
+}
+
+// Run applies the queued copies until ctx is cancelled.
+func (s *Shadow) Run(ctx context.Context) {
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case c := <-s.calls:
+			s.apply(c)
+		}
+	}
+}
+
+func (s *Shadow) apply(c shadowCall) {
+
+	ctx, cancel := context.WithTimeout(c.ctx, s.Timeout)
+	defer cancel()
+
+	var err error
+
+	switch c.call.Op {
+	case order.OpInsert:
+		err = s.Target.Insert(ctx, c.call.Order)
+	case order.OpInsertAll:
+		err = s.Target.InsertAll(ctx, c.call.Orders)
+	case order.OpUpdate:
+		err = s.Target.Update(ctx, c.call.Order)
+	case order.OpDeleteByID:
+		err = s.Target.DeleteByID(ctx, c.call.ID)
+	case order.OpApply:
+		// The target saw the same writes, so the expected versions hold
+		// there too unless it has drifted.
+		err = s.Target.Apply(ctx, c.call.Batch)
+	}
+
+	if err != nil {
+		s.failed.Add(1)
+		fmt.Printf("failed to shadow %s: %v\n", c.call.Op, err)
+		return
+	}
+
+	s.mirrored.Add(1)
+}
+
+func (s *Shadow) Stats() Stats {
+	return Stats{
+		Mirrored: s.mirrored.Load(),
+		Failed:   s.failed.Load(),
+		Dropped:  s.dropped.Load(),
+		Backlog:  len(s.calls),
+	}
+}
+
+func (s *Shadow) ServeStats(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.Stats())
+}
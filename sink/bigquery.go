@@ -0,0 +1,246 @@
+package sink
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// BigQueryScope is the OAuth scope BigQuery requests need.
+const BigQueryScope = "https://www.googleapis.com/auth/bigquery"
+
+// BigQuery writes rows to a BigQuery table with the streaming insert API,
+// using the mutation ID as the insert ID so BigQuery drops retried rows on
+// a best-effort basis. The table is created partitioned by day on
+// recorded_at and clustered by order_id.
+type BigQuery struct {
+	// Endpoint defaults to the public API. It can point at an emulator.
+	Endpoint string
+	Project  string
+	Dataset  string
+	Table    string
+	// Client authorizes the requests, e.g. with an oauth2.Transport.
+	Client *http.Client
+}
+
+type bigQueryField struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+	Mode string `json:"mode,omitempty"`
+}
+
+type bigQuerySchema struct {
+	Fields []bigQueryField `json:"fields"`
+}
+
+type bigQueryTable struct {
+	TableReference   map[string]string `json:"tableReference,omitempty"`
+	Schema           bigQuerySchema    `json:"schema"`
+	TimePartitioning map[string]string `json:"timePartitioning,omitempty"`
+	Clustering       map[string]any    `json:"clustering,omitempty"`
+}
+
+// bigQueryError is an error answer from the API.
+type bigQueryError struct {
+	Status  int
+	Message string
+}
+
+func (e *bigQueryError) Error() string {
+	return fmt.Sprintf("bigquery answered %d: %s", e.Status, e.Message)
+}
+
+func (b *BigQuery) endpoint() string {
+	if b.Endpoint == "" {
+		return "https://bigquery.googleapis.com/bigquery/v2"
+	}
+	return strings.TrimSuffix(b.Endpoint, "/")
+}
+
+func (b *BigQuery) tablePath() string {
+	return fmt.Sprintf("/projects/%s/datasets/%s/tables/%s", url.PathEscape(b.Project), url.PathEscape(b.Dataset), url.PathEscape(b.Table))
+}
+
+func (b *BigQuery) EnsureSchema(ctx context.Context, columns []Column) error {
+
+	var table bigQueryTable
+
+	err := b.call(ctx, http.MethodGet, b.tablePath(), nil, &table)
+
+	var apiErr *bigQueryError
+	if errors.As(err, &apiErr) && apiErr.Status == http.StatusNotFound {
+		return b.createTable(ctx, columns)
+	} else if err != nil {
+		return fmt.Errorf("failed to get sink table: %w", err)
+	}
+
+	fields := table.Schema.Fields
+	existing := map[string]bool{}
+	for _, f := range fields {
+		existing[f.Name] = true
+	}
+
+	var added bool
+	for _, c := range columns {
+		if !existing[c.Name] {
+			fields = append(fields, bigQueryField{Name: c.Name, Type: c.Type, Mode: "NULLABLE"})
+			added = true
+		}
+	}
+
+	if !added {
+		return nil
+	}
+
+	patch := map[string]any{"schema": bigQuerySchema{Fields: fields}}
+
+	if err := b.call(ctx, http.MethodPatch, b.tablePath(), patch, nil); err != nil {
+		return fmt.Errorf("failed to add sink columns: %w", err)
+	}
+
+	return nil
+}
+
+func (b *BigQuery) createTable(ctx context.Context, columns []Column) error {
+
+	table := bigQueryTable{
+		TableReference: map[string]string{
+			"projectId": b.Project,
+			"datasetId": b.Dataset,
+			"tableId":   b.Table,
+		},
+		TimePartitioning: map[string]string{"type": "DAY", "field": "recorded_at"},
+		Clustering:       map[string]any{"fields": []string{"order_id"}},
+	}
+
+	for _, c := range columns {
+		mode := "NULLABLE"
+		if c.Required {
+			mode = "REQUIRED"
+		}
+		table.Schema.Fields = append(table.Schema.Fields, bigQueryField{Name: c.Name, Type: c.Type, Mode: mode})
+	}
+
+	path := fmt.Sprintf("/projects/%s/datasets/%s/tables", url.PathEscape(b.Project), url.PathEscape(b.Dataset))
+
+	if err := b.call(ctx, http.MethodPost, path, table, nil); err != nil {
+		return fmt.Errorf("failed to create sink table: %w", err)
+	}
+
+	return nil
+}
+
+func (b *BigQuery) Write(ctx context.Context, rows []Row) error {
+
+	type insertRow struct {
+		InsertID string         `json:"insertId"`
+		JSON     map[string]any `json:"json"`
+	}
+
+	req := struct {
+		Rows []insertRow `json:"rows"`
+	}{}
+
+	for _, r := range rows {
+		values := r.Values()
+		fields := make(map[string]any, len(values))
+		for i, v := range values {
+			if v == nil {
+				continue
+			}
+			// BigQuery keeps timestamps to the microsecond.
+			if t, ok := v.(time.Time); ok {
+				v = t.Format("2006-01-02T15:04:05.999999Z07:00")
+			}
+			fields[Columns[i].Name] = v
+		}
+		req.Rows = append(req.Rows, insertRow{InsertID: r.MutationID, JSON: fields})
+	}
+
+	var res struct {
+		InsertErrors []struct {
+			Index  int `json:"index"`
+			Errors []struct {
+				Reason  string `json:"reason"`
+				Message string `json:"message"`
+			} `json:"errors"`
+		} `json:"insertErrors"`
+	}
+
+	if err := b.call(ctx, http.MethodPost, b.tablePath()+"/insertAll", req, &res); err != nil {
+		return fmt.Errorf("failed to insert sink rows: %w", err)
+	}
+
+	if len(res.InsertErrors) > 0 {
+		e := res.InsertErrors[0]
+		msg := "unknown error"
+		if len(e.Errors) > 0 {
+			msg = e.Errors[0].Reason + ": " + e.Errors[0].Message
+		}
+		return fmt.Errorf("failed to insert %d of %d sink rows, row %d: %s", len(res.InsertErrors), len(rows), e.Index, msg)
+	}
+
+	return nil
+}
+
+func (b *BigQuery) call(ctx context.Context, method, path string, in, out any) error {
+
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return fmt.Errorf("failed to encode bigquery request: %w", err)
+		}
+		body = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, b.endpoint()+path, body)
+	if err != nil {
+		return fmt.Errorf("failed to build bigquery request: %w", err)
+	}
+
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	client := b.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		var answer struct {
+			Error struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		data, _ := io.ReadAll(io.LimitReader(res.Body, 4096))
+		if json.Unmarshal(data, &answer) != nil || answer.Error.Message == "" {
+			answer.Error.Message = string(bytes.TrimSpace(data))
+		}
+		return &bigQueryError{Status: res.StatusCode, Message: answer.Error.Message}
+	}
+
+	if out == nil {
+		return nil
+	}
+
+	if err := json.NewDecoder(res.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode bigquery answer: %w", err)
+	}
+
+	return nil
+}
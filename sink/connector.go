@@ -0,0 +1,164 @@
+package sink
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/i101dev/microservices-NN/changefeed"
+	"github.com/i101dev/microservices-NN/model"
+	"github.com/i101dev/microservices-NN/repository/order"
+	"github.com/redis/go-redis/v9"
+)
+
+const group = "order-sink"
+
+// Connector streams order mutations into a warehouse, following the
+// changefeed in its own consumer group and writing a row for every order
+// it names. A batch the warehouse fails is retried once it has been idle
+// for Retry, so rows are written at least once. If the warehouse stays
+// down long enough for the changefeed to be trimmed past a batch,
+// Backfill writes out what was lost.
+type Connector struct {
+	Client *redis.Client
+	// Stream is the changefeed stream.
+	Stream    string
+	Repo      order.Repository
+	Warehouse Warehouse
+	// BatchSize is the most rows written at once. Zero means 500.
+	BatchSize int
+	// Interval is the longest a mutation waits for its batch to fill. Zero
+	// means 10 seconds.
+	Interval time.Duration
+	// Retry is how long a failed batch waits to be retried, and how long
+	// Run waits to check the schema again when it cannot. Zero means a
+	// minute.
+	Retry time.Duration
+	// Now is used to stamp rows. Nil means time.Now.
+	Now func() time.Time
+}
+
+func (c *Connector) batchSize() int {
+	if c.BatchSize <= 0 {
+		return 500
+	}
+	return c.BatchSize
+}
+
+func (c *Connector) retry() time.Duration {
+	if c.Retry <= 0 {
+		return time.Minute
+	}
+	return c.Retry
+}
+
+func (c *Connector) now() time.Time {
+	if c.Now == nil {
+		return time.Now()
+	}
+	return c.Now()
+}
+
+// Run makes sure the warehouse table is up to date, then writes the
+// changes to it until ctx is cancelled.
+func (c *Connector) Run(ctx context.Context) error {
+
+	for {
+		err := c.Warehouse.EnsureSchema(ctx, Columns)
+		if err == nil {
+			break
+		}
+
+		fmt.Println("failed to update sink schema, retrying later:", err)
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(c.retry()):
+		}
+	}
+
+	feed := &changefeed.Consumer{
+		Client:    c.Client,
+		Stream:    c.Stream,
+		Group:     group,
+		Repo:      c.Repo,
+		BatchSize: c.batchSize(),
+		Interval:  c.Interval,
+		Retry:     c.retry(),
+		Handle:    c.write,
+	}
+
+	return feed.Run(ctx)
+}
+
+// write writes one row per change, named after the change.
+func (c *Connector) write(ctx context.Context, changes []changefeed.Change) error {
+
+	now := c.now()
+	rows := make([]Row, len(changes))
+
+	for i, change := range changes {
+		if change.Order == nil {
+			rows[i] = deleteRow(change.ID, change.OrderID, now)
+		} else {
+			rows[i] = newRow(change.ID, *change.Order, now)
+		}
+	}
+
+	return c.Warehouse.Write(ctx, rows)
+}
+
+// Backfill writes a row for every order in repo, for orders written
+// before the sink was set up or lost while it was down, and returns how
+// many it wrote. Rows are named after the order and when it was last
+// updated, so running it twice over unchanged orders writes the same rows.
+func (c *Connector) Backfill(ctx context.Context, repo order.Repository) (int, error) {
+
+	if err := c.Warehouse.EnsureSchema(ctx, Columns); err != nil {
+		return 0, err
+	}
+
+	var n int
+	var rows []Row
+
+	write := func() error {
+		if len(rows) == 0 {
+			return nil
+		}
+		if err := c.Warehouse.Write(ctx, rows); err != nil {
+			return err
+		}
+		n += len(rows)
+		rows = rows[:0]
+		return nil
+	}
+
+	err := order.ForEachPage(ctx, repo, 100, func(orders []model.Order) error {
+
+		now := c.now()
+
+		for _, o := range orders {
+			var version int64
+			if o.UpdatedAt != nil {
+				version = o.UpdatedAt.UnixMilli()
+			} else if o.CreatedAt != nil {
+				version = o.CreatedAt.UnixMilli()
+			}
+
+			rows = append(rows, newRow(fmt.Sprintf("backfill-%d-%d", o.OrderID, version), o, now))
+
+			if len(rows) >= c.batchSize() {
+				if err := write(); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return n, err
+	}
+
+	return n, write()
+}
@@ -0,0 +1,114 @@
+// Package sink streams order mutations into an analytics warehouse, such
+// as BigQuery, one row per order written. Rows hold the order as it was
+// when the row was written, so the latest row of an order by recorded_at
+// is its current state.
+package sink
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/i101dev/microservices-NN/model"
+)
+
+const (
+	OpUpsert = "upsert"
+	OpDelete = "delete"
+)
+
+const (
+	TypeString    = "STRING"
+	TypeInteger   = "INTEGER"
+	TypeTimestamp = "TIMESTAMP"
+)
+
+// Column is one column of the sink table. Columns added to an existing
+// table are nullable whatever Required says, as warehouses cannot fill
+// them in for the rows already there.
+type Column struct {
+	Name     string
+	Type     string
+	Required bool
+}
+
+// Columns is the schema of the sink table, in the order Row.Values returns
+// them. New columns go at the end.
+var Columns = []Column{
+	{Name: "mutation_id", Type: TypeString, Required: true},
+	// Order IDs use all 64 bits, which warehouse integers are one short of.
+	{Name: "order_id", Type: TypeString, Required: true},
+	{Name: "op", Type: TypeString, Required: true},
+	{Name: "recorded_at", Type: TypeTimestamp, Required: true},
+	{Name: "status", Type: TypeString},
+	{Name: "customer_id", Type: TypeString},
+	{Name: "total", Type: TypeInteger},
+	{Name: "item_count", Type: TypeInteger},
+	{Name: "tags", Type: TypeString},
+	{Name: "created_at", Type: TypeTimestamp},
+	{Name: "updated_at", Type: TypeTimestamp},
+	{Name: "order_json", Type: TypeString},
+}
+
+// Warehouse is where the rows go. Rows can be written more than once, with
+// the same MutationID, so warehouses that can should dedupe on it.
+type Warehouse interface {
+	// EnsureSchema creates the table, or adds the columns it is missing.
+	EnsureSchema(ctx context.Context, columns []Column) error
+	Write(ctx context.Context, rows []Row) error
+}
+
+// Row is an order as it was written, or the deletion of one, in which
+// case only the first four columns are set.
+type Row struct {
+	MutationID string
+	OrderID    uint64
+	Op         string
+	RecordedAt time.Time
+	Order      *model.Order
+}
+
+func newRow(id string, o model.Order, now time.Time) Row {
+	return Row{MutationID: id, OrderID: o.OrderID, Op: OpUpsert, RecordedAt: now, Order: &o}
+}
+
+func deleteRow(id string, orderID uint64, now time.Time) Row {
+	return Row{MutationID: id, OrderID: orderID, Op: OpDelete, RecordedAt: now}
+}
+
+// Values returns the columns of r in the order of Columns, with nil for
+// the ones not set.
+func (r Row) Values() []any {
+
+	values := make([]any, len(Columns))
+	values[0] = r.MutationID
+	values[1] = strconv.FormatUint(r.OrderID, 10)
+	values[2] = r.Op
+	values[3] = r.RecordedAt.UTC()
+
+	o := r.Order
+	if o == nil {
+		return values
+	}
+
+	data, _ := json.Marshal(o)
+
+	values[4] = o.Status()
+	values[5] = o.CustomerID.String()
+	values[6] = o.Total()
+	values[7] = len(o.LineItems)
+	if len(o.Tags) > 0 {
+		values[8] = strings.Join(o.Tags, ",")
+	}
+	if o.CreatedAt != nil {
+		values[9] = o.CreatedAt.UTC()
+	}
+	if o.UpdatedAt != nil {
+		values[10] = o.UpdatedAt.UTC()
+	}
+	values[11] = string(data)
+
+	return values
+}
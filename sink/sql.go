@@ -0,0 +1,134 @@
+package sink
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// SQL writes rows to a table through database/sql, for warehouses with a
+// SQL driver rather than an API of their own. Table is put into the
+// statements as it is, so it must come from configuration. Nothing is
+// deduped: readers should take one row per mutation_id.
+type SQL struct {
+	DB    *sql.DB
+	Table string
+	// Placeholder returns the nth bind parameter, counting from one, such
+	// as "$1" for PostgreSQL. Nil means "?".
+	Placeholder func(n int) string
+	// Types maps column types to the database's. Missing types are written
+	// as they are.
+	Types map[string]string
+}
+
+// DefaultSQLTypes suit most SQL databases.
+var DefaultSQLTypes = map[string]string{
+	TypeString:    "TEXT",
+	TypeInteger:   "BIGINT",
+	TypeTimestamp: "TIMESTAMP",
+}
+
+func (s *SQL) columnType(t string) string {
+
+	types := s.Types
+	if types == nil {
+		types = DefaultSQLTypes
+	}
+
+	if name, ok := types[t]; ok {
+		return name
+	}
+	return t
+}
+
+func (s *SQL) placeholder(n int) string {
+	if s.Placeholder == nil {
+		return "?"
+	}
+	return s.Placeholder(n)
+}
+
+func (s *SQL) EnsureSchema(ctx context.Context, columns []Column) error {
+
+	defs := make([]string, len(columns))
+	for i, c := range columns {
+		defs[i] = c.Name + " " + s.columnType(c.Type)
+		if c.Required {
+			defs[i] += " NOT NULL"
+		}
+	}
+
+	create := fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (%s)", s.Table, strings.Join(defs, ", "))
+
+	if _, err := s.DB.ExecContext(ctx, create); err != nil {
+		return fmt.Errorf("failed to create sink table: %w", err)
+	}
+
+	// Selecting nothing is the one portable way to read a table's columns.
+	rows, err := s.DB.QueryContext(ctx, fmt.Sprintf("SELECT * FROM %s WHERE 1 = 0", s.Table))
+	if err != nil {
+		return fmt.Errorf("failed to read sink columns: %w", err)
+	}
+
+	names, err := rows.Columns()
+	rows.Close()
+	if err != nil {
+		return fmt.Errorf("failed to read sink columns: %w", err)
+	}
+
+	existing := map[string]bool{}
+	for _, name := range names {
+		existing[strings.ToLower(name)] = true
+	}
+
+	for _, c := range columns {
+		if existing[c.Name] {
+			continue
+		}
+
+		alter := fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", s.Table, c.Name, s.columnType(c.Type))
+
+		if _, err := s.DB.ExecContext(ctx, alter); err != nil {
+			return fmt.Errorf("failed to add sink column %s: %w", c.Name, err)
+		}
+	}
+
+	return nil
+}
+
+func (s *SQL) Write(ctx context.Context, rows []Row) error {
+
+	names := make([]string, len(Columns))
+	params := make([]string, len(Columns))
+	for i, c := range Columns {
+		names[i] = c.Name
+		params[i] = s.placeholder(i + 1)
+	}
+
+	insert := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)", s.Table, strings.Join(names, ", "), strings.Join(params, ", "))
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin sink transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, insert)
+	if err != nil {
+		return fmt.Errorf("failed to prepare sink insert: %w", err)
+	}
+	defer stmt.Close()
+
+	for _, r := range rows {
+		if _, err := stmt.ExecContext(ctx, r.Values()...); err != nil {
+			return fmt.Errorf("failed to insert sink row %s: %w", r.MutationID, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit sink rows: %w", err)
+	}
+
+	return nil
+}
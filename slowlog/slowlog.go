@@ -0,0 +1,200 @@
+package slowlog
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/redis/go-redis/v9"
+)
+
+type Entry struct {
+	At        time.Time     `json:"at"`
+	Command   string        `json:"command"`
+	Key       string        `json:"key,omitempty"`
+	Duration  time.Duration `json:"duration_ns"`
+	RequestID string        `json:"request_id,omitempty"`
+	Error     string        `json:"error,omitempty"`
+}
+
+// Log is a go-redis hook that logs every command slower than Threshold and
+// keeps the most recent Size of them in memory. Blocking reads are left out
+// since they are slow on purpose.
+type Log struct {
+	Threshold time.Duration
+	Logger    *slog.Logger
+
+	mu      sync.Mutex
+	entries []Entry
+	next    int
+	full    bool
+}
+
+func New(threshold time.Duration, size int) *Log {
+	return &Log{
+		Threshold: threshold,
+		Logger:    slog.Default(),
+		entries:   make([]Entry, size),
+	}
+}
+
+func (l *Log) DialHook(next redis.DialHook) redis.DialHook {
+	return next
+}
+
+func (l *Log) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+
+	return func(ctx context.Context, cmd redis.Cmder) error {
+
+		start := time.Now()
+		err := next(ctx, cmd)
+
+		if took := time.Since(start); took > l.Threshold && !blocking(cmd) {
+			l.record(ctx, cmd.Name(), key(cmd), took, cmd.Err())
+		}
+
+		return err
+	}
+}
+
+func (l *Log) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+
+		start := time.Now()
+		err := next(ctx, cmds)
+
+		if took := time.Since(start); took > l.Threshold && len(cmds) > 0 {
+			kind := "pipeline"
+
+			// Transactions arrive wrapped in MULTI and EXEC.
+			if cmds[0].Name() == "multi" && len(cmds) > 2 {
+				kind, cmds = "multi", cmds[1:len(cmds)-1]
+			}
+
+			names := make([]string, len(cmds))
+			for i, cmd := range cmds {
+				names[i] = cmd.Name()
+			}
+
+			l.record(ctx, kind+" "+strings.Join(names, ","), key(cmds[0]), took, err)
+		}
+
+		return err
+	}
+}
+
+func (l *Log) record(ctx context.Context, command string, key string, took time.Duration, err error) {
+
+	e := Entry{
+		At:        time.Now().UTC(),
+		Command:   command,
+		Key:       key,
+		Duration:  took,
+		RequestID: middleware.GetReqID(ctx),
+	}
+
+	// A nil reply is how Redis says "no such key", not a failure.
+	if err != nil && err != redis.Nil {
+		e.Error = err.Error()
+	}
+
+	l.Logger.WarnContext(ctx, "slow redis command", "command", e.Command, "key", e.Key, "duration", took, "request_id", e.RequestID)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if len(l.entries) == 0 {
+		return
+	}
+
+	l.entries[l.next] = e
+	l.next = (l.next + 1) % len(l.entries)
+	l.full = l.full || l.next == 0
+}
+
+// Recent returns up to limit entries, newest first.
+func (l *Log) Recent(limit int) []Entry {
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	n := l.next
+	if l.full {
+		n = len(l.entries)
+	}
+
+	if limit <= 0 || limit > n {
+		limit = n
+	}
+
+	recent := make([]Entry, 0, limit)
+	for i := 1; i <= limit; i++ {
+		recent = append(recent, l.entries[(l.next-i+len(l.entries))%len(l.entries)])
+	}
+
+	return recent
+}
+
+func (l *Log) ServeRecent(w http.ResponseWriter, r *http.Request) {
+
+	limit := 0
+
+	if s := r.URL.Query().Get("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 {
+			http.Error(w, "limit must be a positive integer", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(l.Recent(limit))
+}
+
+// key returns the first key the command names, as far as that can be told
+// from its arguments.
+func key(cmd redis.Cmder) string {
+
+	args := cmd.Args()
+
+	switch cmd.Name() {
+	case "eval", "evalsha", "evalsha_ro", "eval_ro", "fcall", "fcall_ro":
+		if len(args) > 3 && fmt.Sprint(args[2]) != "0" {
+			return fmt.Sprint(args[3])
+		}
+		return ""
+	}
+
+	if len(args) > 1 {
+		return fmt.Sprint(args[1])
+	}
+
+	return ""
+}
+
+func blocking(cmd redis.Cmder) bool {
+
+	switch cmd.Name() {
+	case "blpop", "brpop", "brpoplpush", "blmove", "blmpop", "bzpopmin", "bzpopmax", "bzmpop":
+		return true
+	case "xread", "xreadgroup":
+		for _, arg := range cmd.Args() {
+			if s, ok := arg.(string); ok && strings.EqualFold(s, "block") {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+var _ redis.Hook = (*Log)(nil)
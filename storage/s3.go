@@ -0,0 +1,364 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// unsignedPayload is the payload hash of presigned requests, whose bodies
+// are not known when they are signed.
+const unsignedPayload = "UNSIGNED-PAYLOAD"
+
+// S3 keeps objects in an S3 bucket, or any store with the same API, with
+// requests signed with AWS Signature Version 4. Objects are addressed in
+// path style, so Endpoint can point at MinIO or a local gateway.
+type S3 struct {
+	// Endpoint defaults to the regional AWS endpoint. Presigned URLs are
+	// made for it too, so clients must be able to reach it.
+	Endpoint     string
+	Region       string
+	Bucket       string
+	AccessKeyID  string
+	SecretKey    string
+	SessionToken string
+	Client       *http.Client
+	// Now is used to date requests. Nil means time.Now.
+	Now func() time.Time
+}
+
+var _ Blob = (*S3)(nil)
+
+func (s *S3) endpoint() string {
+	if s.Endpoint == "" {
+		return "https://s3." + s.Region + ".amazonaws.com"
+	}
+	return strings.TrimSuffix(s.Endpoint, "/")
+}
+
+func (s *S3) now() time.Time {
+	if s.Now == nil {
+		return time.Now().UTC()
+	}
+	return s.Now().UTC()
+}
+
+func (s *S3) client() *http.Client {
+	if s.Client == nil {
+		return http.DefaultClient
+	}
+	return s.Client
+}
+
+func (s *S3) objectURL(key string) (*url.URL, error) {
+
+	u, err := url.Parse(s.endpoint())
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse s3 endpoint: %w", err)
+	}
+
+	u.Path += "/" + s.Bucket + "/" + key
+	u.RawPath = escapePath(u.Path)
+
+	return u, nil
+}
+
+func (s *S3) Put(ctx context.Context, key string, data []byte, contentType string) error {
+
+	u, err := s.objectURL(key)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, u.String(), bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("failed to build s3 request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", contentType)
+	s.sign(req, sha256Hex(data))
+
+	res, err := s.client().Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to put %s: %w", key, err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to put %s: %w", key, answered(res))
+	}
+
+	return nil
+}
+
+func (s *S3) Stat(ctx context.Context, key string) (Object, error) {
+
+	res, err := s.do(ctx, http.MethodHead, key)
+	if err != nil {
+		return Object{}, fmt.Errorf("failed to stat %s: %w", key, err)
+	}
+	defer res.Body.Close()
+
+	switch res.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return Object{}, ErrNotExist
+	default:
+		return Object{}, fmt.Errorf("failed to stat %s: %w", key, answered(res))
+	}
+
+	return Object{
+		Size:        res.ContentLength,
+		ContentType: res.Header.Get("Content-Type"),
+	}, nil
+}
+
+func (s *S3) Delete(ctx context.Context, key string) error {
+
+	res, err := s.do(ctx, http.MethodDelete, key)
+	if err != nil {
+		return fmt.Errorf("failed to delete %s: %w", key, err)
+	}
+	defer res.Body.Close()
+
+	switch res.StatusCode {
+	case http.StatusOK, http.StatusNoContent, http.StatusNotFound:
+		return nil
+	}
+
+	return fmt.Errorf("failed to delete %s: %w", key, answered(res))
+}
+
+// do sends a request without a body for the object.
+func (s *S3) do(ctx context.Context, method, key string) (*http.Response, error) {
+
+	u, err := s.objectURL(key)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build s3 request: %w", err)
+	}
+
+	s.sign(req, sha256Hex(nil))
+
+	return s.client().Do(req)
+}
+
+func answered(res *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(res.Body, 512))
+	return fmt.Errorf("s3 answered %d: %s", res.StatusCode, bytes.TrimSpace(body))
+}
+
+func (s *S3) PresignPut(key, contentType string, size int64, ttl time.Duration) (string, error) {
+
+	u, err := s.objectURL(key)
+	if err != nil {
+		return "", err
+	}
+
+	return s.presign(http.MethodPut, u, map[string]string{
+		"content-length": strconv.FormatInt(size, 10),
+		"content-type":   contentType,
+	}, nil, ttl)
+}
+
+func (s *S3) PresignGet(key, filename string, ttl time.Duration) (string, error) {
+
+	u, err := s.objectURL(key)
+	if err != nil {
+		return "", err
+	}
+
+	query := url.Values{}
+	if disposition := mime.FormatMediaType("attachment", map[string]string{"filename": filename}); disposition != "" {
+		query.Set("response-content-disposition", disposition)
+	}
+
+	return s.presign(http.MethodGet, u, nil, query, ttl)
+}
+
+// presign signs the request in its query, for headers to be sent with it
+// as given, and returns its URL.
+func (s *S3) presign(method string, u *url.URL, headers map[string]string, query url.Values, ttl time.Duration) (string, error) {
+
+	if ttl <= 0 || ttl > MaxURLTTL {
+		return "", fmt.Errorf("presigned urls last up to %s, not %s", MaxURLTTL, ttl)
+	}
+
+	t := s.now()
+	day := t.Format("20060102")
+
+	names := []string{"host"}
+	for name := range headers {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		value := headers[name]
+		if name == "host" {
+			value = u.Host
+		}
+		canonicalHeaders.WriteString(name + ":" + strings.TrimSpace(value) + "\n")
+	}
+	signed := strings.Join(names, ";")
+
+	if query == nil {
+		query = url.Values{}
+	}
+	query.Set("X-Amz-Algorithm", "AWS4-HMAC-SHA256")
+	query.Set("X-Amz-Credential", s.AccessKeyID+"/"+s.scope(day))
+	query.Set("X-Amz-Date", t.Format("20060102T150405Z"))
+	query.Set("X-Amz-Expires", strconv.FormatInt(int64(ttl/time.Second), 10))
+	query.Set("X-Amz-SignedHeaders", signed)
+	if s.SessionToken != "" {
+		query.Set("X-Amz-Security-Token", s.SessionToken)
+	}
+
+	canonicalQuery := canonicalQuery(query)
+
+	canonical := strings.Join([]string{
+		method,
+		u.EscapedPath(),
+		canonicalQuery,
+		canonicalHeaders.String(),
+		signed,
+		unsignedPayload,
+	}, "\n")
+
+	presigned := *u
+	presigned.RawQuery = canonicalQuery + "&X-Amz-Signature=" + s.signature(t, canonical)
+
+	return presigned.String(), nil
+}
+
+// sign adds the SigV4 authorization header for a request with a
+// single-chunk payload hashing to payloadHash.
+func (s *S3) sign(req *http.Request, payloadHash string) {
+
+	t := s.now()
+	amzDate := t.Format("20060102T150405Z")
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if s.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", s.SessionToken)
+	}
+
+	names := []string{"host", "x-amz-content-sha256", "x-amz-date"}
+	if req.Header.Get("Content-Type") != "" {
+		names = append([]string{"content-type"}, names...)
+	}
+	if s.SessionToken != "" {
+		names = append(names, "x-amz-security-token")
+	}
+
+	var headers strings.Builder
+	for _, name := range names {
+		value := req.Header.Get(name)
+		if name == "host" {
+			value = req.URL.Host
+		}
+		headers.WriteString(name + ":" + strings.TrimSpace(value) + "\n")
+	}
+	signed := strings.Join(names, ";")
+
+	canonical := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		headers.String(),
+		signed,
+		payloadHash,
+	}, "\n")
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s", s.AccessKeyID, s.scope(t.Format("20060102")), signed, s.signature(t, canonical)))
+}
+
+func (s *S3) scope(day string) string {
+	return day + "/" + s.Region + "/s3/aws4_request"
+}
+
+// signature signs a canonical request made at t.
+func (s *S3) signature(t time.Time, canonical string) string {
+
+	day := t.Format("20060102")
+	toSign := "AWS4-HMAC-SHA256\n" + t.Format("20060102T150405Z") + "\n" + s.scope(day) + "\n" + sha256Hex([]byte(canonical))
+
+	key := hmacSHA256([]byte("AWS4"+s.SecretKey), day)
+	key = hmacSHA256(key, s.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+
+	return hex.EncodeToString(hmacSHA256(key, toSign))
+}
+
+// canonicalQuery encodes the query sorted by name, with every byte that
+// is not unreserved in RFC 3986 escaped, as SigV4 expects.
+func canonicalQuery(query url.Values) string {
+
+	names := make([]string, 0, len(query))
+	for name := range query {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	var pairs []string
+	for _, name := range names {
+		for _, value := range query[name] {
+			pairs = append(pairs, escape(name, false)+"="+escape(value, false))
+		}
+	}
+
+	return strings.Join(pairs, "&")
+}
+
+// escapePath encodes every byte of p that is not unreserved in RFC 3986,
+// apart from slashes, as SigV4 expects of S3 object keys.
+func escapePath(p string) string {
+	return escape(p, true)
+}
+
+func escape(s string, keepSlashes bool) string {
+
+	var b strings.Builder
+
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~', c == '/' && keepSlashes:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+
+	return b.String()
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
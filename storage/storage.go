@@ -0,0 +1,37 @@
+// Package storage keeps large binary objects, such as the files attached
+// to orders, in object storage. Clients move the bytes in and out with
+// presigned URLs, so they never go through the service.
+package storage
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var ErrNotExist = errors.New("object does not exist")
+
+// MaxURLTTL is the longest presigned URLs can be valid for.
+const MaxURLTTL = 7 * 24 * time.Hour
+
+// Object describes a stored object.
+type Object struct {
+	Size        int64
+	ContentType string
+}
+
+// Blob is a store of objects by key.
+type Blob interface {
+	// PresignPut returns a URL that takes an upload of exactly size bytes
+	// of contentType, sent with those as its Content-Length and
+	// Content-Type, until ttl passes.
+	PresignPut(key, contentType string, size int64, ttl time.Duration) (string, error)
+	// PresignGet returns a URL that downloads the object as a file named
+	// filename until ttl passes.
+	PresignGet(key, filename string, ttl time.Duration) (string, error)
+	// Stat describes the object, or fails with ErrNotExist.
+	Stat(ctx context.Context, key string) (Object, error)
+	// Delete removes the object. Removing one that does not exist is not
+	// an error.
+	Delete(ctx context.Context, key string) error
+}
@@ -0,0 +1,81 @@
+package subscription
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/i101dev/microservices-NN/model"
+)
+
+var ErrDeclined = errors.New("payment declined")
+
+// Charger takes payment for an order a subscription placed, with whatever
+// the customer keeps on file at the payment provider, and returns the
+// provider's payment reference.
+type Charger interface {
+	Charge(ctx context.Context, s Subscription, o model.Order) (string, error)
+}
+
+// HTTPCharger posts {"subscription_id", "customer_id", "order_id",
+// "amount"} as JSON to URL and expects {"reference"} back. A 402 is a
+// decline.
+type HTTPCharger struct {
+	URL    string
+	Client *http.Client
+}
+
+func (c *HTTPCharger) httpClient() *http.Client {
+	if c.Client == nil {
+		return http.DefaultClient
+	}
+	return c.Client
+}
+
+func (c *HTTPCharger) Charge(ctx context.Context, s Subscription, o model.Order) (string, error) {
+
+	data, err := json.Marshal(map[string]any{
+		"subscription_id": s.ID,
+		"customer_id":     s.CustomerID,
+		"order_id":        o.OrderID,
+		"amount":          o.Due(),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to encode charge: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.URL, bytes.NewReader(data))
+	if err != nil {
+		return "", fmt.Errorf("failed to build request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := c.httpClient().Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to call payment provider: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusPaymentRequired {
+		body, _ := io.ReadAll(io.LimitReader(res.Body, 512))
+		return "", fmt.Errorf("%w: %s", ErrDeclined, bytes.TrimSpace(body))
+	} else if res.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(res.Body, 512))
+		return "", fmt.Errorf("payment provider returned %d: %s", res.StatusCode, bytes.TrimSpace(body))
+	}
+
+	var body struct {
+		Reference string `json:"reference"`
+	}
+
+	if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+		return "", fmt.Errorf("failed to decode charge: %w", err)
+	}
+
+	return body.Reference, nil
+}
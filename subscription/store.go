@@ -0,0 +1,138 @@
+package subscription
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+const dueKey = "subscriptions:due"
+
+// Store keeps each subscription as JSON, with a sorted set of the active
+// ones by when they are next due and a set of them per customer.
+type Store struct {
+	Client *redis.Client
+}
+
+func subscriptionKey(id string) string {
+	return "subscription:" + id
+}
+
+func customerKey(customer uuid.UUID) string {
+	return "subscriptions:customer:" + customer.String()
+}
+
+func (st *Store) Save(ctx context.Context, s Subscription) error {
+
+	data, err := json.Marshal(s)
+	if err != nil {
+		return fmt.Errorf("failed to encode subscription: %w", err)
+	}
+
+	pipe := st.Client.TxPipeline()
+
+	pipe.Set(ctx, subscriptionKey(s.ID), data, 0)
+	pipe.SAdd(ctx, customerKey(s.CustomerID), s.ID)
+
+	if s.Status == StatusActive && s.NextRunAt != nil {
+		pipe.ZAdd(ctx, dueKey, redis.Z{Score: float64(s.NextRunAt.UnixMilli()), Member: s.ID})
+	} else {
+		pipe.ZRem(ctx, dueKey, s.ID)
+	}
+
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to save subscription: %w", err)
+	}
+
+	return nil
+}
+
+func (st *Store) Get(ctx context.Context, id string) (Subscription, error) {
+
+	data, err := st.Client.Get(ctx, subscriptionKey(id)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return Subscription{}, ErrNotExist
+	} else if err != nil {
+		return Subscription{}, fmt.Errorf("failed to get subscription: %w", err)
+	}
+
+	var s Subscription
+
+	if err := json.Unmarshal(data, &s); err != nil {
+		return Subscription{}, fmt.Errorf("failed to decode subscription: %w", err)
+	}
+
+	return s, nil
+}
+
+// ForCustomer returns the subscriptions of a customer, cancelled ones
+// included, oldest first.
+func (st *Store) ForCustomer(ctx context.Context, customer uuid.UUID) ([]Subscription, error) {
+
+	ids, err := st.Client.SMembers(ctx, customerKey(customer)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list subscriptions: %w", err)
+	}
+
+	subs := make([]Subscription, 0, len(ids))
+
+	for _, id := range ids {
+		s, err := st.Get(ctx, id)
+		if errors.Is(err, ErrNotExist) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		subs = append(subs, s)
+	}
+
+	slices.SortFunc(subs, func(a, b Subscription) int { return a.CreatedAt.Compare(b.CreatedAt) })
+
+	return subs, nil
+}
+
+// Due returns up to limit active subscriptions due at or before now,
+// the longest overdue first.
+func (st *Store) Due(ctx context.Context, now time.Time, limit int64) ([]string, error) {
+
+	ids, err := st.Client.ZRangeByScore(ctx, dueKey, &redis.ZRangeBy{
+		Min:   "-inf",
+		Max:   strconv.FormatInt(now.UnixMilli(), 10),
+		Count: limit,
+	}).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list due subscriptions: %w", err)
+	}
+
+	return ids, nil
+}
+
+// ForgetCustomer deletes every subscription of a customer.
+func (st *Store) ForgetCustomer(ctx context.Context, customer uuid.UUID) error {
+
+	ids, err := st.Client.SMembers(ctx, customerKey(customer)).Result()
+	if err != nil {
+		return fmt.Errorf("failed to list subscriptions: %w", err)
+	}
+
+	pipe := st.Client.TxPipeline()
+
+	for _, id := range ids {
+		pipe.Del(ctx, subscriptionKey(id))
+		pipe.ZRem(ctx, dueKey, id)
+	}
+	pipe.Del(ctx, customerKey(customer))
+
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to drop subscriptions: %w", err)
+	}
+
+	return nil
+}
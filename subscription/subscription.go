@@ -0,0 +1,187 @@
+// Package subscription keeps the templates of orders that are placed for
+// a customer again and again on a cron schedule.
+package subscription
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/i101dev/microservices-NN/model"
+	"github.com/i101dev/microservices-NN/tenant"
+	"github.com/robfig/cron/v3"
+)
+
+var (
+	ErrNotExist          = errors.New("subscription does not exist")
+	ErrInvalidSchedule   = errors.New("invalid subscription schedule")
+	ErrInvalidTransition = errors.New("invalid subscription transition")
+)
+
+const (
+	StatusActive = "active"
+	StatusPaused = "paused"
+	// StatusPastDue is a subscription whose payment failed too many times
+	// in a row. It is resumed like a paused one.
+	StatusPastDue   = "past_due"
+	StatusCancelled = "cancelled"
+)
+
+// Subscription places an order with LineItems and Shipping for its
+// customer at every time Schedule names, in UTC. NextRunAt is only set
+// while it is active.
+type Subscription struct {
+	ID         string           `json:"id"`
+	Tenant     tenant.ID        `json:"tenant,omitempty"`
+	CustomerID uuid.UUID        `json:"customer_id"`
+	LineItems  []model.LineItem `json:"line_items"`
+	Shipping   *model.Shipping  `json:"shipping,omitempty"`
+	Schedule   string           `json:"schedule"`
+	Status     string           `json:"status"`
+	NextRunAt  *time.Time       `json:"next_run_at,omitempty"`
+	// Orders counts the orders placed so far, the last of them being
+	// LastOrderID.
+	Orders      int    `json:"orders"`
+	LastOrderID uint64 `json:"last_order_id,omitempty"`
+	// Failures counts the payments that failed since the last one that
+	// went through, and LastError says why the last run failed.
+	Failures  int       `json:"failures,omitempty"`
+	LastError string    `json:"last_error,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// ParseSchedule accepts a standard five-field cron spec or a descriptor
+// such as "@weekly" or "@every 720h".
+func ParseSchedule(spec string) (cron.Schedule, error) {
+
+	s, err := cron.ParseStandard(spec)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidSchedule, err)
+	}
+
+	return s, nil
+}
+
+// New returns an active subscription whose first order is due at the
+// first time the schedule names after now.
+func New(t tenant.ID, customer uuid.UUID, items []model.LineItem, shipping *model.Shipping, schedule string, now time.Time) (Subscription, error) {
+
+	s := Subscription{
+		ID:         uuid.NewString(),
+		Tenant:     t,
+		CustomerID: customer,
+		LineItems:  items,
+		Shipping:   shipping,
+		Schedule:   schedule,
+		Status:     StatusActive,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+
+	if err := s.schedule(now); err != nil {
+		return Subscription{}, err
+	}
+
+	return s, nil
+}
+
+// schedule sets NextRunAt to the first run after now.
+func (s *Subscription) schedule(now time.Time) error {
+
+	sched, err := ParseSchedule(s.Schedule)
+	if err != nil {
+		return err
+	}
+
+	next := sched.Next(now.UTC())
+	s.NextRunAt = &next
+
+	return nil
+}
+
+func (s *Subscription) Pause(now time.Time) error {
+
+	if s.Status != StatusActive {
+		return ErrInvalidTransition
+	}
+
+	s.Status = StatusPaused
+	s.NextRunAt = nil
+	s.UpdatedAt = now
+
+	return nil
+}
+
+// Resume picks a paused or past due subscription up again from the next
+// run after now. Runs missed in between are skipped.
+func (s *Subscription) Resume(now time.Time) error {
+
+	if s.Status != StatusPaused && s.Status != StatusPastDue {
+		return ErrInvalidTransition
+	}
+
+	if err := s.schedule(now); err != nil {
+		return err
+	}
+
+	s.Status = StatusActive
+	s.Failures = 0
+	s.UpdatedAt = now
+
+	return nil
+}
+
+func (s *Subscription) Cancel(now time.Time) error {
+
+	if s.Status == StatusCancelled {
+		return ErrInvalidTransition
+	}
+
+	s.Status = StatusCancelled
+	s.NextRunAt = nil
+	s.UpdatedAt = now
+
+	return nil
+}
+
+// Placed records an order placed and paid for, and schedules the next one.
+func (s *Subscription) Placed(orderID uint64, now time.Time) error {
+
+	s.Orders++
+	s.LastOrderID = orderID
+	s.Failures = 0
+	s.LastError = ""
+	s.UpdatedAt = now
+
+	return s.schedule(now)
+}
+
+// Retry records a run that failed before an order could be placed, and
+// tries again after retry.
+func (s *Subscription) Retry(reason string, retry time.Duration, now time.Time) {
+
+	next := now.Add(retry)
+	s.NextRunAt = &next
+	s.LastError = reason
+	s.UpdatedAt = now
+}
+
+// PaymentFailed records a payment that did not go through. It is retried
+// after retry, doubling with every failure in a row, until maxFailures
+// is reached and the subscription becomes past due.
+func (s *Subscription) PaymentFailed(reason string, retry time.Duration, maxFailures int, now time.Time) {
+
+	s.Failures++
+
+	if s.Failures >= maxFailures {
+		s.Status = StatusPastDue
+		s.NextRunAt = nil
+		s.LastError = reason
+		s.UpdatedAt = now
+		return
+	}
+
+	s.Retry(reason, retry<<(s.Failures-1), now)
+}
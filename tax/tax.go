@@ -0,0 +1,111 @@
+// Package tax works out the tax due on an order when it is placed.
+package tax
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+
+	"github.com/i101dev/microservices-NN/model"
+)
+
+// Calculator returns the tax due on an order. The order's shipping, when
+// set, says where it is taxed.
+type Calculator interface {
+	Calculate(ctx context.Context, o model.Order) (model.Tax, error)
+}
+
+// FlatRate taxes every line at Rate. Orders are taxed in their shipping
+// country, or in Jurisdiction when they have no shipping details.
+type FlatRate struct {
+	Rate         float64
+	Jurisdiction string
+}
+
+// Calculate rounds each line half away from zero.
+func (f FlatRate) Calculate(ctx context.Context, o model.Order) (model.Tax, error) {
+
+	jurisdiction := f.Jurisdiction
+	if o.Shipping != nil && o.Shipping.Country != "" {
+		jurisdiction = o.Shipping.Country
+	}
+
+	lines := make([]model.TaxLine, len(o.LineItems))
+
+	for i, item := range o.LineItems {
+		lines[i] = model.TaxLine{
+			ItemID:       item.ItemID,
+			Jurisdiction: jurisdiction,
+			Rate:         f.Rate,
+			Amount:       uint(math.Round(float64(item.Price*item.Quantity) * f.Rate)),
+		}
+	}
+
+	return Total(lines), nil
+}
+
+// Total adds up lines into a model.Tax.
+func Total(lines []model.TaxLine) model.Tax {
+
+	t := model.Tax{Lines: lines}
+	for _, line := range lines {
+		t.Total += line.Amount
+	}
+
+	return t
+}
+
+// HTTPCalculator posts the order as JSON to URL and expects
+// {"lines": [...]} back, one line per item and jurisdiction. The total is
+// always added up here.
+type HTTPCalculator struct {
+	URL    string
+	Client *http.Client
+}
+
+func (c *HTTPCalculator) httpClient() *http.Client {
+	if c.Client == nil {
+		return http.DefaultClient
+	}
+	return c.Client
+}
+
+func (c *HTTPCalculator) Calculate(ctx context.Context, o model.Order) (model.Tax, error) {
+
+	data, err := json.Marshal(o)
+	if err != nil {
+		return model.Tax{}, fmt.Errorf("failed to encode order: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.URL, bytes.NewReader(data))
+	if err != nil {
+		return model.Tax{}, fmt.Errorf("failed to build request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := c.httpClient().Do(req)
+	if err != nil {
+		return model.Tax{}, fmt.Errorf("failed to call tax provider: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(res.Body, 512))
+		return model.Tax{}, fmt.Errorf("tax provider returned %d: %s", res.StatusCode, bytes.TrimSpace(body))
+	}
+
+	var body struct {
+		Lines []model.TaxLine `json:"lines"`
+	}
+
+	if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+		return model.Tax{}, fmt.Errorf("failed to decode tax lines: %w", err)
+	}
+
+	return Total(body.Lines), nil
+}
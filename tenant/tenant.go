@@ -0,0 +1,54 @@
+package tenant
+
+import (
+	"context"
+	"net/http"
+	"regexp"
+)
+
+type ID string
+
+// Default is the tenant of requests that do not name one.
+const Default ID = "default"
+
+const Header = "X-Tenant-ID"
+
+var validID = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,64}$`)
+
+type contextKey struct{}
+
+func NewContext(ctx context.Context, id ID) context.Context {
+	return context.WithValue(ctx, contextKey{}, id)
+}
+
+// FromContext returns the tenant attached to ctx, or Default when there is
+// none.
+func FromContext(ctx context.Context) ID {
+
+	if id, ok := ctx.Value(contextKey{}).(ID); ok {
+		return id
+	}
+
+	return Default
+}
+
+// Middleware attaches the tenant named by the X-Tenant-ID header, rejecting
+// malformed IDs with a 400.
+func Middleware(next http.Handler) http.Handler {
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		header := r.Header.Get(Header)
+		if header == "" {
+			next.ServeHTTP(w, r.WithContext(NewContext(r.Context(), Default)))
+			return
+		}
+
+		if !validID.MatchString(header) {
+			http.Error(w, "invalid "+Header, http.StatusBadRequest)
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(NewContext(r.Context(), ID(header))))
+	})
+}
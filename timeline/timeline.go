@@ -0,0 +1,265 @@
+// Package timeline lays out everything that happened to a customer's
+// orders as one feed, oldest first: the orders placed, the statuses they
+// moved through, their payments, parcels and disputes, the merges and
+// splits the store keeps in their history, and the comments the customer
+// was sent on them.
+package timeline
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/i101dev/microservices-NN/comment"
+	"github.com/i101dev/microservices-NN/model"
+	"github.com/i101dev/microservices-NN/orderindex"
+	"github.com/i101dev/microservices-NN/org"
+	"github.com/i101dev/microservices-NN/repository/order"
+	"github.com/i101dev/microservices-NN/service"
+)
+
+var ErrInvalidCursor = errors.New("invalid timeline cursor")
+
+// History entries take the type of their action, such as merged_into.
+const (
+	TypeOrderPlaced   = "order_placed"
+	TypeStatusChanged = "status_changed"
+	TypePayment       = "payment"
+	TypeTracking      = "tracking"
+	TypeDispute       = "dispute"
+	TypeNotification  = "notification"
+)
+
+// Entry is one thing that happened to an order. ID is unique in the feed
+// and is the cursor of the entries after it.
+type Entry struct {
+	ID      string    `json:"id"`
+	At      time.Time `json:"at"`
+	Type    string    `json:"type"`
+	OrderID uint64    `json:"order_id"`
+	// Status is what the entry moved the order, payment, parcel or dispute
+	// to.
+	Status string `json:"status,omitempty"`
+	Amount uint   `json:"amount,omitempty"`
+	// Related names the other orders of a merge or split.
+	Related []uint64 `json:"related,omitempty"`
+	// Message is the body of a notification.
+	Message string `json:"message,omitempty"`
+}
+
+func newEntry(at time.Time, kind string, orderID uint64, key string) Entry {
+	at = at.UTC()
+	return Entry{
+		ID:      fmt.Sprintf("%d-%d-%s", at.UnixNano(), orderID, key),
+		At:      at,
+		Type:    kind,
+		OrderID: orderID,
+	}
+}
+
+// Of returns what happened to the order, from its own fields, its
+// history and its comments, of which only the ones for the customer are
+// kept.
+func Of(o model.Order, history []model.HistoryEntry, comments []comment.Comment) []Entry {
+
+	var entries []Entry
+
+	status := func(at *time.Time, to, key string) {
+		if at != nil {
+			e := newEntry(*at, TypeStatusChanged, o.OrderID, key)
+			e.Status = to
+			entries = append(entries, e)
+		}
+	}
+
+	if o.CreatedAt != nil {
+		entries = append(entries, newEntry(*o.CreatedAt, TypeOrderPlaced, o.OrderID, "placed"))
+	}
+
+	status(o.FlaggedAt, model.StatusReview, "flagged")
+	status(o.ApprovedAt, model.StatusPending, "approved")
+	if b := o.Backorder; b != nil {
+		status(&b.Since, model.StatusBackordered, "backordered")
+		status(b.FulfilledAt, model.StatusPending, "restocked")
+	}
+	status(o.ShippedAt, model.StatusShipped, "shipped")
+	status(o.CompletedAt, model.StatusCompleted, "completed")
+	status(o.CancelledAt, model.StatusCancelled, "cancelled")
+
+	if p := o.Payment; p != nil {
+		for _, step := range []struct {
+			at     *time.Time
+			status string
+			amount uint
+		}{
+			{p.AuthorizedAt, model.PaymentAuthorized, p.Authorized},
+			{p.CapturedAt, model.PaymentCaptured, p.Captured},
+			{p.RefundedAt, model.PaymentRefunded, p.Refunded},
+		} {
+			if step.at != nil {
+				e := newEntry(*step.at, TypePayment, o.OrderID, "payment-"+step.status)
+				e.Status, e.Amount = step.status, step.amount
+				entries = append(entries, e)
+			}
+		}
+	}
+
+	if t := o.Tracking; t != nil && t.At != nil {
+		e := newEntry(*t.At, TypeTracking, o.OrderID, "tracking")
+		e.Status = t.Status
+		entries = append(entries, e)
+	}
+
+	for i, d := range o.Disputes {
+		e := newEntry(d.OpenedAt, TypeDispute, o.OrderID, "dispute-"+strconv.Itoa(i)+"-opened")
+		e.Status, e.Amount = model.DisputeNeedsResponse, d.Amount
+		entries = append(entries, e)
+
+		if d.ClosedAt != nil {
+			e := newEntry(*d.ClosedAt, TypeDispute, o.OrderID, "dispute-"+strconv.Itoa(i)+"-closed")
+			e.Status, e.Amount = d.Status, d.Amount
+			entries = append(entries, e)
+		}
+	}
+
+	for i, h := range history {
+		e := newEntry(h.At, h.Action, o.OrderID, "history-"+strconv.Itoa(i))
+		e.Related = h.Related
+		entries = append(entries, e)
+	}
+
+	for _, c := range comments {
+		if c.Visibility != comment.VisibilityCustomer {
+			continue
+		}
+		e := newEntry(c.CreatedAt, TypeNotification, o.OrderID, "comment-"+c.ID)
+		e.Message = c.Body
+		entries = append(entries, e)
+	}
+
+	return entries
+}
+
+// compare orders entries by time, and entries at the same time by ID.
+func compare(a, b Entry) int {
+	return cmp.Or(a.At.Compare(b.At), strings.Compare(a.ID, b.ID))
+}
+
+// Page returns up to size entries after the one cursor names, and the
+// cursor of the rest, empty at the end. entries must be sorted. The
+// cursor holds the time of its entry, so it keeps its place however the
+// feed changes.
+func Page(entries []Entry, cursor string, size int) ([]Entry, string, error) {
+
+	start := 0
+
+	if cursor != "" {
+		nanos, _, _ := strings.Cut(cursor, "-")
+		unix, err := strconv.ParseInt(nanos, 10, 64)
+		if err != nil {
+			return nil, "", ErrInvalidCursor
+		}
+
+		after := Entry{ID: cursor, At: time.Unix(0, unix).UTC()}
+		start, _ = slices.BinarySearchFunc(entries, after, compare)
+		if start < len(entries) && entries[start].ID == cursor {
+			start++
+		}
+	}
+
+	end := min(start+size, len(entries))
+	page := entries[start:end]
+
+	if end < len(entries) {
+		return page, page[len(page)-1].ID, nil
+	}
+
+	return page, "", nil
+}
+
+// Builder gathers the timeline of a customer from the orders the index
+// lists for them.
+type Builder struct {
+	Orders *service.Orders
+	// History, when set, adds the merges and splits of the orders.
+	History *order.RedisRepo
+	// Comments, when set, adds the comments the customer was sent.
+	Comments *comment.Store
+	// Orgs, when set, leaves out the orders the caller may not see.
+	Orgs *org.Store
+}
+
+// Build returns the whole timeline of the customer, sorted.
+func (b *Builder) Build(ctx context.Context, customer uuid.UUID) ([]Entry, error) {
+
+	entries := []Entry{}
+
+	var cursor uint64
+
+	for {
+		page, err := b.Orders.Filter(ctx, orderindex.Filter{CustomerID: &customer}, cursor, 100)
+		if err != nil {
+			return nil, err
+		}
+
+		orders := page.Items
+		if b.Orgs != nil {
+			if orders, err = b.Orgs.Visible(ctx, orders); err != nil {
+				return nil, err
+			}
+		}
+
+		for _, o := range orders {
+			var history []model.HistoryEntry
+			if b.History != nil {
+				if history, err = b.History.History(ctx, o.OrderID); err != nil {
+					return nil, err
+				}
+			}
+
+			var comments []comment.Comment
+			if b.Comments != nil {
+				if comments, err = b.comments(ctx, o.OrderID); err != nil {
+					return nil, err
+				}
+			}
+
+			entries = append(entries, Of(o, history, comments)...)
+		}
+
+		if page.Next == 0 {
+			break
+		}
+		cursor = page.Next
+	}
+
+	slices.SortFunc(entries, compare)
+
+	return entries, nil
+}
+
+func (b *Builder) comments(ctx context.Context, orderID uint64) ([]comment.Comment, error) {
+
+	var comments []comment.Comment
+	var cursor string
+
+	for {
+		page, err := b.Comments.List(ctx, orderID, comment.VisibilityCustomer, cursor, 100)
+		if err != nil {
+			return nil, err
+		}
+
+		comments = append(comments, page.Items...)
+
+		if page.Next == "" {
+			return comments, nil
+		}
+		cursor = page.Next
+	}
+}
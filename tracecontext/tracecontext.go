@@ -0,0 +1,190 @@
+package tracecontext
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// W3C Trace Context headers.
+const (
+	ParentHeader = "traceparent"
+	StateHeader  = "tracestate"
+)
+
+// Span identifies the current span of a trace, in the form it travels in a
+// traceparent header. Nothing here records spans; it only carries their
+// identity on to the services and messages downstream.
+type Span struct {
+	TraceID [16]byte
+	SpanID  [8]byte
+	Flags   byte
+	// State is the vendor tracestate, passed along untouched.
+	State string
+}
+
+// New starts a sampled trace.
+func New() Span {
+
+	var s Span
+	rand.Read(s.TraceID[:])
+	rand.Read(s.SpanID[:])
+	s.Flags = 1
+
+	return s
+}
+
+// Child returns a new span of the same trace.
+func (s Span) Child() Span {
+
+	rand.Read(s.SpanID[:])
+
+	return s
+}
+
+func (s Span) TraceIDString() string {
+	return hex.EncodeToString(s.TraceID[:])
+}
+
+// String formats the span as a traceparent value.
+func (s Span) String() string {
+	return fmt.Sprintf("00-%x-%x-%02x", s.TraceID, s.SpanID, s.Flags)
+}
+
+// Parse reads a traceparent value. It accepts later versions as long as
+// they start with the version 00 fields, as the specification asks.
+func Parse(traceparent string) (Span, bool) {
+
+	var s Span
+
+	parts := strings.Split(strings.TrimSpace(traceparent), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" {
+		return s, false
+	}
+	if parts[0] == "00" && len(parts) != 4 {
+		return s, false
+	}
+
+	var flags [1]byte
+
+	if !decode(s.TraceID[:], parts[1]) || !decode(s.SpanID[:], parts[2]) || !decode(flags[:], parts[3]) {
+		return s, false
+	}
+
+	if s.TraceID == [16]byte{} || s.SpanID == [8]byte{} {
+		return s, false
+	}
+
+	s.Flags = flags[0]
+
+	return s, true
+}
+
+func decode(dst []byte, s string) bool {
+
+	if len(s) != 2*len(dst) || strings.ToLower(s) != s {
+		return false
+	}
+
+	_, err := hex.Decode(dst, []byte(s))
+
+	return err == nil
+}
+
+type contextKey struct{}
+
+func NewContext(ctx context.Context, s Span) context.Context {
+	return context.WithValue(ctx, contextKey{}, s)
+}
+
+func FromContext(ctx context.Context) (Span, bool) {
+	s, ok := ctx.Value(contextKey{}).(Span)
+	return s, ok
+}
+
+// Resume continues the trace named by a stored traceparent, such as one
+// captured when a job was enqueued, in a child span. Values that do not
+// parse leave ctx as it is.
+func Resume(ctx context.Context, traceparent string, state string) context.Context {
+
+	parent, ok := Parse(traceparent)
+	if !ok {
+		return ctx
+	}
+
+	s := parent.Child()
+	s.State = state
+
+	return NewContext(ctx, s)
+}
+
+// Middleware continues the trace of the incoming traceparent header, or
+// starts one, and gives the request its own span.
+func Middleware(next http.Handler) http.Handler {
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		s := New()
+
+		if parent, ok := Parse(r.Header.Get(ParentHeader)); ok {
+			s = parent.Child()
+			s.State = r.Header.Get(StateHeader)
+		}
+
+		next.ServeHTTP(w, r.WithContext(NewContext(r.Context(), s)))
+	})
+}
+
+// Inject sets the headers for a call made from the span in ctx. Each call
+// gets a span of its own.
+func Inject(ctx context.Context, h http.Header) {
+
+	for k, v := range Values(ctx) {
+		h.Set(k, v)
+	}
+}
+
+// Values returns the headers Inject would set, for carriers other than
+// HTTP such as stream entries and queued jobs. Resume reads them back.
+func Values(ctx context.Context) map[string]string {
+
+	s, ok := FromContext(ctx)
+	if !ok {
+		return nil
+	}
+
+	values := map[string]string{ParentHeader: s.Child().String()}
+
+	if s.State != "" {
+		values[StateHeader] = s.State
+	}
+
+	return values
+}
+
+// Transport injects the trace context of each request's context into its
+// headers before handing it to Base, or http.DefaultTransport when Base is
+// nil.
+type Transport struct {
+	Base http.RoundTripper
+}
+
+func (t *Transport) RoundTrip(r *http.Request) (*http.Response, error) {
+
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	if _, ok := FromContext(r.Context()); !ok {
+		return base.RoundTrip(r)
+	}
+
+	r = r.Clone(r.Context())
+	Inject(r.Context(), r.Header)
+
+	return base.RoundTrip(r)
+}
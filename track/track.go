@@ -0,0 +1,142 @@
+// Package track backs the public tracking links sent to customers. A link
+// names its order with a signed token instead of the order ID, so links
+// cannot be guessed from one another, and answers with nothing but where
+// the order is and when it should arrive.
+package track
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/i101dev/microservices-NN/clock"
+	"github.com/i101dev/microservices-NN/model"
+	"github.com/redis/go-redis/v9"
+)
+
+const version = "R1"
+
+var ErrInvalid = errors.New("tracking token is invalid")
+
+// Signer issues tracking tokens that name an order. They are signed with
+// an HMAC of Key and do not expire, so a link sent with the order
+// confirmation keeps working until the order is gone.
+type Signer struct {
+	Key []byte
+}
+
+// Sign returns the token of the order, such as R1.2n9c.<signature>. The
+// same order always gets the same token.
+func (s *Signer) Sign(orderID uint64) string {
+
+	payload := version + "." + strconv.FormatUint(orderID, 36)
+
+	return payload + "." + s.signature(payload)
+}
+
+func (s *Signer) Verify(token string) (uint64, error) {
+
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 || parts[0] != version {
+		return 0, ErrInvalid
+	}
+
+	payload := strings.Join(parts[:2], ".")
+
+	if !hmac.Equal([]byte(parts[2]), []byte(s.signature(payload))) {
+		return 0, ErrInvalid
+	}
+
+	orderID, err := strconv.ParseUint(parts[1], 36, 64)
+	if err != nil {
+		return 0, ErrInvalid
+	}
+
+	return orderID, nil
+}
+
+// signature is truncated to 128 bits to keep links short.
+func (s *Signer) signature(payload string) string {
+
+	mac := hmac.New(sha256.New, s.Key)
+	mac.Write([]byte(payload))
+
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil)[:16])
+}
+
+// Status is all a tracking link tells: no customer, address, items or
+// amounts.
+type Status struct {
+	Status string `json:"status"`
+	// Parcel is the carrier's latest status of the shipment.
+	Parcel            string  `json:"parcel,omitempty"`
+	EstimatedDelivery *Window `json:"estimated_delivery,omitempty"`
+}
+
+type Window struct {
+	Earliest time.Time `json:"earliest"`
+	Latest   time.Time `json:"latest"`
+}
+
+func StatusOf(o model.Order) Status {
+
+	s := Status{Status: o.Status()}
+
+	if t := o.Tracking; t != nil {
+		s.Parcel = t.Status
+	}
+
+	if e := o.EstimatedDelivery; e != nil {
+		s.EstimatedDelivery = &Window{Earliest: e.Earliest, Latest: e.Latest}
+	}
+
+	return s
+}
+
+// Limiter allows each order Limit lookups in every Window, counted in
+// fixed windows in Redis.
+type Limiter struct {
+	Client *redis.Client
+	Limit  int64
+	Window time.Duration
+	Clock  clock.Clock
+}
+
+func (l *Limiter) now() time.Time {
+	if l.Clock == nil {
+		return time.Now()
+	}
+	return l.Clock.Now()
+}
+
+// Allow counts a lookup of the order, and reports whether it is within
+// the limit and, when it is not, how long until the window ends. Only
+// verified tokens are counted, so forged ones cannot use up the lookups
+// of an order.
+func (l *Limiter) Allow(ctx context.Context, orderID uint64) (bool, time.Duration, error) {
+
+	now := l.now()
+	window := now.UnixNano() / int64(l.Window)
+
+	key := "track:rate:" + strconv.FormatUint(orderID, 10) + ":" + strconv.FormatInt(window, 10)
+
+	pipe := l.Client.TxPipeline()
+	count := pipe.Incr(ctx, key)
+	pipe.Expire(ctx, key, l.Window)
+
+	if _, err := pipe.Exec(ctx); err != nil {
+		return true, 0, fmt.Errorf("failed to count tracking lookup: %w", err)
+	}
+
+	if count.Val() <= l.Limit {
+		return true, 0, nil
+	}
+
+	return false, time.Unix(0, (window+1)*int64(l.Window)).Sub(now), nil
+}
@@ -0,0 +1,43 @@
+package transport
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"time"
+
+	"golang.org/x/net/http2"
+)
+
+// Pooled returns a transport that keeps up to idlePerHost connections
+// open to each host. The standard transport keeps two, so concurrent
+// callers of one service mostly open a fresh connection per request and
+// pay for the handshake every time.
+func Pooled(idlePerHost int, idleTimeout time.Duration) *http.Transport {
+
+	t := http.DefaultTransport.(*http.Transport).Clone()
+
+	t.MaxIdleConnsPerHost = idlePerHost
+	t.IdleConnTimeout = idleTimeout
+	t.ForceAttemptHTTP2 = true
+
+	return t
+}
+
+// H2C returns a transport that speaks HTTP/2 without TLS to services that
+// accept it, as this one does with H2C_ENABLED. Every request to a host
+// shares one connection.
+func H2C(idleTimeout time.Duration) *http2.Transport {
+
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+
+	return &http2.Transport{
+		AllowHTTP: true,
+		DialTLSContext: func(ctx context.Context, network, addr string, _ *tls.Config) (net.Conn, error) {
+			return dialer.DialContext(ctx, network, addr)
+		},
+		IdleConnTimeout: idleTimeout,
+		ReadIdleTimeout: 30 * time.Second,
+	}
+}
@@ -0,0 +1,220 @@
+// Package warehouse exports the order event stream to object storage as
+// Parquet files for the analytics warehouse to ingest. Files are
+// partitioned by the UTC day the events were published, under
+// <prefix>/dt=YYYY-MM-DD/.
+package warehouse
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/parquet-go/parquet-go"
+	"github.com/redis/go-redis/v9"
+)
+
+const bookmarkKey = "warehouse:bookmark"
+
+// Store is where the exported files go.
+type Store interface {
+	Put(ctx context.Context, key string, data []byte, contentType string) error
+}
+
+// Row is one event in an exported file. EventID is the stream entry ID,
+// unique per event, which the warehouse should dedupe on: an export that
+// fails part way is retried from the same place, so files can overlap.
+type Row struct {
+	EventID       string    `parquet:"event_id"`
+	Type          string    `parquet:"type"`
+	SchemaVersion int32     `parquet:"schema_version"`
+	OccurredAt    time.Time `parquet:"occurred_at,timestamp(millisecond)"`
+	Tenant        string    `parquet:"tenant,optional"`
+	OrderID       *uint64   `parquet:"order_id,optional"`
+	CustomerID    string    `parquet:"customer_id,optional"`
+	// Payload is the event as published, in JSON.
+	Payload string `parquet:"payload"`
+}
+
+// Exporter copies the events after its bookmark into the store, and
+// moves the bookmark past them once every file is written. Events are
+// exported at least once.
+type Exporter struct {
+	Client *redis.Client
+	Stream string
+	Store  Store
+	Prefix string
+	// BatchSize is how many events go into one round of files. Zero means
+	// 10000.
+	BatchSize int64
+}
+
+func (x *Exporter) batchSize() int64 {
+	if x.BatchSize <= 0 {
+		return 10000
+	}
+	return x.BatchSize
+}
+
+// Flush exports every event published since the last flush, and returns
+// how many it exported.
+func (x *Exporter) Flush(ctx context.Context) (int, error) {
+
+	var total int
+
+	for {
+		n, err := x.flushBatch(ctx)
+		total += n
+
+		if err != nil || int64(n) < x.batchSize() {
+			return total, err
+		}
+	}
+}
+
+func (x *Exporter) bookmark(ctx context.Context) (string, error) {
+
+	id, err := x.Client.Get(ctx, bookmarkKey).Result()
+	if errors.Is(err, redis.Nil) {
+		return "", nil
+	} else if err != nil {
+		return "", fmt.Errorf("failed to get export bookmark: %w", err)
+	}
+
+	return id, nil
+}
+
+func (x *Exporter) flushBatch(ctx context.Context) (int, error) {
+
+	bookmark, err := x.bookmark(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	start := "-"
+	if bookmark != "" {
+		start = "(" + bookmark
+	}
+
+	entries, err := x.Client.XRangeN(ctx, x.Stream, start, "+", x.batchSize()).Result()
+	if err != nil {
+		return 0, fmt.Errorf("failed to read %s: %w", x.Stream, err)
+	}
+
+	if len(entries) == 0 {
+		return 0, nil
+	}
+
+	days := map[string][]Row{}
+
+	for _, entry := range entries {
+		day := entryTime(entry.ID).Format(time.DateOnly)
+		days[day] = append(days[day], newRow(entry))
+	}
+
+	for _, day := range sortedKeys(days) {
+		rows := days[day]
+
+		data, err := encode(rows)
+		if err != nil {
+			return 0, err
+		}
+
+		if err := x.Store.Put(ctx, x.objectKey(day, rows), data, "application/vnd.apache.parquet"); err != nil {
+			return 0, err
+		}
+	}
+
+	last := entries[len(entries)-1].ID
+
+	if err := x.Client.Set(ctx, bookmarkKey, last, 0).Err(); err != nil {
+		return 0, fmt.Errorf("failed to move export bookmark: %w", err)
+	}
+
+	return len(entries), nil
+}
+
+// objectKey names a file by the first and last events in it, so a batch
+// retried from the same bookmark overwrites the file it wrote before.
+func (x *Exporter) objectKey(day string, rows []Row) string {
+	return fmt.Sprintf("%s/dt=%s/%s_%s.parquet", strings.Trim(x.Prefix, "/"), day, rows[0].EventID, rows[len(rows)-1].EventID)
+}
+
+// entryTime is when a stream entry was added, from the milliseconds in its
+// ID.
+func entryTime(id string) time.Time {
+	ms, _, _ := strings.Cut(id, "-")
+	n, _ := strconv.ParseInt(ms, 10, 64)
+	return time.UnixMilli(n).UTC()
+}
+
+func newRow(entry redis.XMessage) Row {
+
+	row := Row{EventID: entry.ID, OccurredAt: entryTime(entry.ID)}
+
+	row.Type, _ = entry.Values["type"].(string)
+	row.Payload, _ = entry.Values["payload"].(string)
+
+	if v, ok := entry.Values["version"].(string); ok {
+		n, _ := strconv.ParseInt(v, 10, 32)
+		row.SchemaVersion = int32(n)
+	}
+
+	var fields struct {
+		OccurredAt time.Time  `json:"occurred_at"`
+		Tenant     string     `json:"tenant"`
+		OrderID    *uint64    `json:"order_id"`
+		CustomerID *uuid.UUID `json:"customer_id"`
+	}
+
+	if err := json.Unmarshal([]byte(row.Payload), &fields); err != nil {
+		fmt.Printf("failed to decode event %s for export: %v\n", entry.ID, err)
+		return row
+	}
+
+	if !fields.OccurredAt.IsZero() {
+		row.OccurredAt = fields.OccurredAt.UTC()
+	}
+	row.Tenant = fields.Tenant
+	row.OrderID = fields.OrderID
+	if fields.CustomerID != nil {
+		row.CustomerID = fields.CustomerID.String()
+	}
+
+	return row
+}
+
+func encode(rows []Row) ([]byte, error) {
+
+	var buf bytes.Buffer
+
+	w := parquet.NewGenericWriter[Row](&buf, parquet.Compression(&parquet.Snappy))
+
+	if _, err := w.Write(rows); err != nil {
+		return nil, fmt.Errorf("failed to write parquet rows: %w", err)
+	}
+
+	if err := w.Close(); err != nil {
+		return nil, fmt.Errorf("failed to finish parquet file: %w", err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+func sortedKeys(m map[string][]Row) []string {
+
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+
+	slices.Sort(keys)
+
+	return keys
+}